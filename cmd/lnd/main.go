@@ -1,24 +1,119 @@
 package main
 
 import (
+	"cmp"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/sysatom/lnd/internal/app"
+	"github.com/sysatom/lnd/internal/collector"
 	"github.com/sysatom/lnd/internal/config"
+	"github.com/sysatom/lnd/internal/metrics"
+	"github.com/sysatom/lnd/internal/report"
+	"github.com/sysatom/lnd/internal/ui"
 )
 
 func main() {
 	configPath := flag.String("config", "", "Path to configuration file (default: ~/.lnd.yaml)")
+	targetsPath := flag.String("targets", "", "Path to a file with connectivity targets, one per line")
+	pushURL := flag.String("push", "", "Push metrics to this Prometheus Pushgateway URL instead of starting the UI")
+	pushJob := flag.String("push-job", "lnd", "Job label for pushed metrics")
+	pushInstance := flag.String("push-instance", "", "Instance label for pushed metrics (default: hostname)")
+	pushInterval := flag.Duration("push-interval", 15*time.Second, "Interval between metric pushes")
+	jsonOut := flag.Bool("json", false, "Collect once, print a JSON report and exit instead of starting the UI")
+	fields := flag.String("fields", "", "Comma separated report sections for --json, e.g. dns,connectivity (default: all)")
+	verbose := flag.Bool("verbose", false, "Include per-record detail in --json output, not just summaries")
+	saveBaseline := flag.String("save-baseline", "", "Collect once and save the result as a named known good baseline, then exit")
+	baselineName := flag.String("baseline", "", "Start the UI in monitoring mode, flagging deviations from this saved baseline")
+	theme := flag.String("theme", "", "Color theme: default, deuteranopia, protanopia or tritanopia (overrides the config)")
+	strict := flag.Bool("strict", false, "Reject unknown config keys at any level instead of warning about unknown top-level keys")
 	flag.Parse()
 
-	cfg, err := config.Load(*configPath)
+	load := config.Load
+	if *strict {
+		load = config.LoadStrict
+	}
+	cfg, err := load(*configPath)
 	if err != nil {
-		fmt.Printf("Error loading config: %v\n", err)
+		var invalid *config.ValidationError
+		if errors.As(err, &invalid) {
+			fmt.Fprintf(os.Stderr, "Invalid config %s:\n", invalid.Path)
+			for _, p := range invalid.Problems {
+				fmt.Fprintf(os.Stderr, "  %s\n", p)
+			}
+		} else {
+			fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		}
 		os.Exit(1)
 	}
+	for _, w := range cfg.Warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s: %s\n", cfg.Path, w)
+	}
+
+	if *targetsPath != "" {
+		targets, err := config.LoadTargets(*targetsPath)
+		if err != nil {
+			fmt.Printf("Error loading targets: %v\n", err)
+			os.Exit(1)
+		}
+		cfg.AddTargets(targets)
+	}
+
+	// The flag only styles this run, ctrl+s keeps the file's theme
+	if err := ui.SetTheme(cmp.Or(*theme, cfg.Theme)); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+
+	if *jsonOut {
+		sections, err := report.ParseFields(*fields)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --fields: %v\n", err)
+			os.Exit(2)
+		}
+		if err := runJSON(cfg, report.SelectOptions{Fields: sections, Verbose: *verbose}); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *saveBaseline != "" {
+		path, err := runSaveBaseline(cfg, *saveBaseline)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Baseline %q saved to %s\n", *saveBaseline, path)
+		return
+	}
+
+	var baseline *report.Baseline
+	if *baselineName != "" {
+		b, err := report.LoadBaseline(*baselineName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading baseline: %v\n", err)
+			os.Exit(1)
+		}
+		baseline = &b
+	}
+
+	if *pushURL != "" {
+		instance := *pushInstance
+		if instance == "" {
+			instance, _ = os.Hostname()
+		}
+		runPush(cfg, metrics.PushConfig{URL: *pushURL, Job: *pushJob, Instance: instance, Interval: *pushInterval})
+		return
+	}
 
 	// Root Check
 	if os.Geteuid() != 0 {
@@ -28,9 +123,224 @@ func main() {
 		fmt.Scanln()
 	}
 
-	p := tea.NewProgram(app.NewModel(cfg), tea.WithAltScreen())
+	model := app.NewModel(cfg)
+	model.Baseline = baseline
+	p := tea.NewProgram(model, tea.WithAltScreen())
+	// Edits to the config file are picked up while the UI runs
+	if cfg.Path != "" {
+		w, err := config.Watch(cfg.Path, load,
+			func(c *config.Config) { p.Send(app.ConfigReloadMsg{Config: c}) },
+			func(err error) { p.Send(app.ConfigReloadMsg{Error: err}) })
+		if err != nil {
+			go p.Send(app.ConfigWatchMsg{Error: err}) // Delivered once the program runs
+		} else {
+			defer w.Close()
+		}
+	}
 	if _, err := p.Run(); err != nil {
 		fmt.Printf("Alas, there's been an error: %v", err)
 		os.Exit(1)
 	}
 }
+
+// runPush samples the collectors and pushes metrics until interrupted
+func runPush(cfg *config.Config, pushCfg metrics.PushConfig) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Collectors disabled in the config stay nil and are skipped when sampling
+	conn := app.NewConnectivityCollector(cfg)
+	var kernel *collector.KernelCollector
+	if cfg.CollectorEnabled(config.CollectorKernel) {
+		kernel, _ = collector.NewKernelCollector() // Nil without procfs access
+	}
+	var traffic *collector.TrafficCollector
+	if cfg.CollectorEnabled(config.CollectorTraffic) {
+		traffic = collector.NewTrafficCollector()
+		if cfg.TrafficSource != "" {
+			traffic.Source = collector.TrafficSource(cfg.TrafficSource)
+		}
+	}
+
+	fmt.Printf("Pushing metrics to %s every %s (job=%s, instance=%s)\n", pushCfg.URL, pushCfg.Interval, pushCfg.Job, pushCfg.Instance)
+	pusher := metrics.NewPusher(pushCfg, metrics.New())
+	pusher.Run(ctx, metrics.Collectors{
+		Connectivity: conn,
+		Traffic:      traffic,
+		Kernel:       kernel,
+	}, func(err error) {
+		fmt.Fprintln(os.Stderr, err)
+	})
+}
+
+// errSectionsFailed means the report was printed but a collector behind
+// one of its sections failed, see its Errors
+var errSectionsFailed = errors.New("some sections failed, see Errors in the report")
+
+// runJSON runs the collectors behind the selected sections once and prints
+// the report. Sections the UI only fills on request, like a DNS lookup or
+// the bufferbloat test, stay empty. It returns errSectionsFailed after
+// printing when a collector failed.
+func runJSON(cfg *config.Config, opts report.SelectOptions) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	s := report.NewSnapshot()
+	s.Config = cfg
+	var mu sync.Mutex
+	logErr := func(source string, err error) {
+		if err != nil {
+			mu.Lock()
+			s.Errors = append(s.Errors, fmt.Sprintf("%s: %v", source, err))
+			mu.Unlock()
+		}
+	}
+
+	conn := app.NewConnectivityCollector(cfg)
+	collectors := map[string]func(){
+		"host": func() {
+			var err error
+			s.Host, err = collector.NewSystemCollector().Collect()
+			logErr("System", err)
+		},
+		"connectivity": func() {
+			var err error
+			s.Connectivity, err = conn.Collect()
+			logErr("Connectivity", err)
+		},
+		"traffic": func() {
+			// Rates need two samples
+			traffic := collector.NewTrafficCollector()
+			if cfg.TrafficSource != "" {
+				traffic.Source = collector.TrafficSource(cfg.TrafficSource)
+			}
+			traffic.Collect()
+			time.Sleep(time.Second)
+			var err error
+			s.Traffic, err = traffic.Collect()
+			logErr("Traffic", err)
+		},
+		"softirqs": func() {
+			softirqs := collector.NewSoftirqCollector()
+			softirqs.Collect()
+			time.Sleep(time.Second)
+			var err error
+			s.Softirqs, err = softirqs.Collect()
+			logErr("Softirqs", err)
+		},
+		"kernel": func() {
+			kernel, err := collector.NewKernelCollector()
+			if err == nil {
+				s.Kernel, err = kernel.Collect()
+			}
+			logErr("Kernel", err)
+		},
+		"nat": func() {
+			var err error
+			s.NAT, err = app.NewNatCollector(cfg).Collect()
+			logErr("NAT", err)
+		},
+		"publicip": func() { s.PublicIP = app.NewPublicIPCollector(cfg).Collect() },
+		"dhcp": func() {
+			dhcp := collector.NewDHCPCollector().Collect()
+			s.DHCP = &dhcp
+		},
+		"dnsbreakdown": func() {
+			ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+			defer cancel()
+			breakdown := collector.NewDNSCollector().Breakdown(ctx, conn.DNSDomain)
+			s.DNSBreakdown = &breakdown
+		},
+		"mss": func() { s.MSS = collector.NewMSSCollector(collector.MSSTargets(conn.Targets)).Collect(ctx) },
+		"tfo": func() {
+			tfo := collector.NewTFOCollector(collector.TFOTargets(conn.Targets)).Collect(ctx)
+			s.TFO = &tfo
+		},
+		"tunnels": func() { s.Tunnels = collector.NewTunnelCollector(cfg.Tunnels).Collect() },
+	}
+	// Sections of collectors disabled in the config stay empty
+	for section, name := range map[string]string{
+		"traffic":      config.CollectorTraffic,
+		"softirqs":     config.CollectorTraffic,
+		"kernel":       config.CollectorKernel,
+		"nat":          config.CollectorSTUN,
+		"publicip":     config.CollectorPublicIP,
+		"dhcp":         config.CollectorDHCP,
+		"dnsbreakdown": config.CollectorDNS,
+		"tunnels":      config.CollectorTunnels,
+	} {
+		if !cfg.CollectorEnabled(name) {
+			delete(collectors, section)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, section := range opts.Fields {
+		if collect, ok := collectors[section]; ok {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				collect()
+			}()
+		}
+	}
+	wg.Wait()
+
+	data, err := report.SelectJSON(s, opts)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stdout.Write(data); err != nil {
+		return err
+	}
+	if len(s.Errors) > 0 {
+		return errSectionsFailed
+	}
+	return nil
+}
+
+// runSaveBaseline collects what a baseline compares, once, and saves it
+func runSaveBaseline(cfg *config.Config, name string) (string, error) {
+	if _, err := report.BaselinePath(name); err != nil {
+		return "", err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	s := report.NewSnapshot()
+	var wg sync.WaitGroup
+	run := func(collect func()) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			collect()
+		}()
+	}
+	run(func() {
+		s.Connectivity, _ = app.NewConnectivityCollector(cfg).Collect()
+		gw := collector.NewGatewayCollector().Check(ctx, s.Connectivity)
+		s.Gateway = &gw
+	})
+	run(func() { s.Host, _ = collector.NewSystemCollector().Collect() })
+	if cfg.CollectorEnabled(config.CollectorKernel) {
+		run(func() {
+			// The retransmission rate needs two samples
+			kernel, err := collector.NewKernelCollector()
+			if err != nil {
+				return
+			}
+			kernel.Collect()
+			time.Sleep(time.Second)
+			s.Kernel, _ = kernel.Collect()
+		})
+	}
+	if cfg.CollectorEnabled(config.CollectorSTUN) {
+		run(func() { s.NAT, _ = app.NewNatCollector(cfg).Collect() })
+	}
+	if cfg.CollectorEnabled(config.CollectorPublicIP) {
+		run(func() { s.PublicIP = app.NewPublicIPCollector(cfg).Collect() })
+	}
+	wg.Wait()
+
+	return report.SaveBaseline(report.NewBaseline(name, s))
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/sysatom/lnd/internal/collector"
+	"github.com/sysatom/lnd/internal/config"
+	"github.com/sysatom/lnd/internal/report"
+)
+
+func TestRunSaveBaseline_SavesRoutes(t *testing.T) {
+	if host, _ := collector.NewSystemCollector().Collect(); len(host.Routes) == 0 {
+		t.Skip("no main table routes on this host")
+	}
+	t.Setenv("HOME", t.TempDir())
+
+	cfg := config.Default()
+	cfg.Targets = []string{"127.0.0.1"}
+	cfg.Collectors = map[string]bool{
+		config.CollectorKernel: false, config.CollectorSTUN: false, config.CollectorPublicIP: false,
+	}
+	if _, err := runSaveBaseline(cfg, "home"); err != nil {
+		t.Fatalf("runSaveBaseline() error = %v", err)
+	}
+	b, err := report.LoadBaseline("home")
+	if err != nil {
+		t.Fatalf("LoadBaseline() error = %v", err)
+	}
+	if len(b.Routes) == 0 {
+		t.Error("a saved baseline should record the routes to compare against")
+	}
+}
@@ -1,13 +1,24 @@
 package app
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
+	"maps"
+	"math"
 	"net"
+	"net/netip"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/atotto/clipboard"
 	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
@@ -15,6 +26,7 @@ import (
 	"github.com/sysatom/lnd/internal/build"
 	"github.com/sysatom/lnd/internal/collector"
 	"github.com/sysatom/lnd/internal/config"
+	"github.com/sysatom/lnd/internal/report"
 	"github.com/sysatom/lnd/internal/ui"
 	"github.com/sysatom/lnd/internal/ui/components"
 )
@@ -27,9 +39,10 @@ const (
 	TabTunnels      = 4
 	TabKernel       = 5
 	TabAbout        = 6
+	TabSpeedTest    = 7
 )
 
-var tabs = []string{"Dashboard", "Interfaces", "Connectivity", "DNS", "Tunnels", "Kernel", "About"}
+var tabs = []string{"Dashboard", "Interfaces", "Connectivity", "DNS", "Tunnels", "Kernel", "About", "Speed Test"}
 
 var dnsRecordTypes = []collector.DNSRecordType{
 	"Auto", collector.RecordA, collector.RecordAAAA, collector.RecordCNAME, collector.RecordMX,
@@ -37,36 +50,115 @@ var dnsRecordTypes = []collector.DNSRecordType{
 }
 
 var dnsProtocols = []collector.DNSProtocol{
-	collector.ProtoUDP, collector.ProtoTCP, collector.ProtoDoT, collector.ProtoDoH,
+	collector.ProtoUDP, collector.ProtoTCP, collector.ProtoDoT, collector.ProtoDoH, collector.ProtoDoH3, collector.ProtoDoQ,
 }
 
 type Model struct {
 	ActiveTab int
+	Tabs      []int // Visible tabs in order, without those of disabled collectors
 	Width     int
 	Height    int
 	Ready     bool
 	Viewport  viewport.Model
 
+	// Dashboard UI State
+	ShowIdleInterfaces bool
+	RateInBits         bool // Show traffic rates in bits per second
+
+	// Power saving, see refreshScale
+	Power        collector.PowerSource
+	powerChecked time.Time // Last read of the power supplies
+	readPower    func() collector.PowerSource
+	lastInput    time.Time // Last key press, for idle detection
+
+	// Interfaces UI State
+	SelectedInterface int       // Cursor in the Interfaces tab
+	systemRefreshed   time.Time // Start of the last link state read, carrier flaps are counted between two
+	refreshingSystem  bool      // A link state read is running, unlike LoadingSystem the tab keeps showing the last info
+
+	// Kernel UI State
+	topSocketsTable     *components.Table // Top sockets, keeps its sort order and cursor across refreshes
+	topSocketsRefreshed time.Time         // Start of the last top sockets collection
+	listenTable         *components.Table // Listening sockets, keeps its sort order and cursor across refreshes
+	listenRefreshed     time.Time         // Start of the last listening sockets collection
+
+	// Speed Test UI State
+	SelectedSpeedTestServer int
+	speedTestServers        []collector.SpeedTestServer
+	speedTestCancel         context.CancelFunc // Stops the running test
+	speedTestUpdates        chan tea.Msg       // Progress, then the result of the running test
+
 	// Data
-	HostInfo      collector.HostInfo
-	Connectivity  collector.ConnectivityStats
-	Traffic       collector.TrafficStats
-	Kernel        collector.KernelStats
-	NatInfo       []collector.NatInfo
-	PublicIP      collector.PublicIPInfo
-	DNSResult     *collector.DNSLookupResult
-	DNSPing       *collector.PingResult
-	TunnelResults []collector.TunnelResult
+	HostInfo            collector.HostInfo
+	Connectivity        collector.ConnectivityStats
+	ConnectivitySkipped bool // The last cycle was skipped by the probe budget
+	Gateway             *collector.GatewayHealth
+	Traffic             collector.TrafficStats
+	Softirqs            collector.SoftirqStats
+	Kernel              collector.KernelStats
+	TopSockets          []collector.SocketInfo // Worst established sockets, while ShowTopSockets
+	ShowTopSockets      bool
+	ListenSockets       *collector.ListenResult // While ShowListenSockets
+	ShowListenSockets   bool
+	NatInfo             []collector.NatInfo
+	PublicIP            collector.PublicIPInfo
+	DNSResult           *collector.DNSLookupResult
+	DNSPing             *collector.PingResult
+	SRVChecks           []collector.SRVCheck
+	URLDiagnosis        *collector.URLDiagnosis
+	DNSConsistency      *collector.DNSConsistencyResult
+	DNSCapabilities     *collector.ResolverCapabilities
+	ZoneTransfer        *collector.ZoneTransferResult
+	DNSTrace            *collector.DNSTraceResult
+	DNSCache            *collector.DNSCacheResult
+	PortScan            *collector.PortScan
+	DNSBatch            *collector.DNSBatchResult
+	DNSCompare          *collector.DNSComparison
+	SpeedTest           *collector.SpeedTestResult
+	SpeedTestProgress   *collector.SpeedTestProgress
+	ResolveConnect      *collector.ResolveConnectResult
+	TunnelResults       []collector.TunnelResult
+	Matrix              *collector.ConnectivityMatrix
+	Regions             []collector.RegionLatency
+	DNSBreakdown        *collector.DNSBreakdown
+	DHCP                *collector.DHCPInfo
+	Bufferbloat         *collector.BufferbloatResult
+	MSS                 []collector.MSSResult
+	Egress              []collector.EgressRoute
+	DNSEgress           []collector.EgressRoute // Egress route of the DNS tab host
+	TFO                 *collector.TFOReport
+	ICMPQueries         []collector.ICMPQueryResult
+	PathMTU             []collector.PathMTUResult
+	SelfTest            []collector.SelfCheck
+	PingHistory         map[string][]float64 // Per-target RTT samples in ms (NaN = lost), oldest first
+	RxHistory           map[string][]float64 // Per-interface RX rate samples in bytes/s, oldest first
+	TxHistory           map[string][]float64 // Per-interface TX rate samples in bytes/s, oldest first
 
 	// Collectors
 	sysCollector      *collector.SystemCollector
 	connCollector     *collector.ConnectivityCollector
 	trafficCollector  *collector.TrafficCollector
+	softirqCollector  *collector.SoftirqCollector
 	kernelCollector   *collector.KernelCollector
+	listenCollector   *collector.ListenCollector
 	natCollector      *collector.NatCollector
 	publicIPCollector *collector.PublicIPCollector
 	dnsCollector      *collector.DNSCollector
 	tunnelCollector   *collector.TunnelCollector
+	matrixCollector   *collector.MatrixCollector
+	regionCollector   *collector.RegionCollector
+	urlDiagnoser      *collector.URLDiagnoser
+	dhcpCollector     *collector.DHCPCollector
+	bufferbloat       *collector.BufferbloatCollector
+	mssCollector      *collector.MSSCollector
+	egressCollector   *collector.EgressCollector
+	tfoCollector      *collector.TFOCollector
+	icmpQuery         *collector.ICMPQueryCollector
+	pathMTU           *collector.PathMTUCollector
+	gatewayCollector  *collector.GatewayCollector
+	portScanner       *collector.PortScanner
+	speedTest         *collector.SpeedTestCollector
+	selfTest          *collector.SelfTestCollector
 
 	// DNS UI State
 	DNSServers         []collector.DNSServer
@@ -76,22 +168,159 @@ type Model struct {
 	SelectedDNSServer  int
 	SelectedRecordType int
 	SelectedProtocol   int // 0: UDP, 1: TCP, 2: DoT, 3: DoH
+	DNSCookie          bool
+	DNSSEC             bool           // Set the DO bit to request DNSSEC records
+	DNSWildcard        bool           // Also query a random sibling name to detect a wildcard record
+	dnsUDPSize         uint16         // EDNS0 UDP payload size, from dns_query.udp_size
+	DNSVerbose         bool           // Show all response sections instead of only the answers
+	SelectedConnectApp int            // Index into collector.ConnectApps for resolve and connect
+	DNSForm            *dnsServerForm // Add/edit server form, nil when closed
 
 	// Loading states
-	LoadingSystem   bool
-	LoadingConn     bool
-	LoadingTraffic  bool
-	LoadingKernel   bool
-	LoadingNat      bool
-	LoadingPublicIP bool
-	LoadingDNS      bool
-	LoadingDNSPing  bool
-	LoadingTunnels  bool
+	LoadingSystem          bool
+	LoadingConn            bool
+	LoadingTraffic         bool
+	LoadingSoftirqs        bool
+	LoadingKernel          bool
+	LoadingTopSockets      bool
+	LoadingListenSockets   bool
+	LoadingNat             bool
+	LoadingPublicIP        bool
+	LoadingDNS             bool
+	LoadingDNSPing         bool
+	LoadingSRVChecks       bool
+	LoadingURLDiagnosis    bool
+	LoadingDNSConsistency  bool
+	LoadingDNSCapabilities bool
+	LoadingZoneTransfer    bool
+	LoadingDNSTrace        bool
+	LoadingDNSCache        bool
+	LoadingPortScan        bool
+	LoadingDNSBatch        bool
+	LoadingDNSCompare      bool
+	RunningSpeedTest       bool
+	LoadingResolveConnect  bool
+	LoadingTunnels         bool
+	LoadingMatrix          bool
+	LoadingRegions         bool
+	LoadingDNSBreakdown    bool
+	LoadingBufferbloat     bool
+	LoadingMSS             bool
+	LoadingEgress          bool
+	LoadingDNSEgress       bool
+	LoadingTFO             bool
+	LoadingICMPQueries     bool
+	LoadingPathMTU         bool
+	LoadingSelfTest        bool
+	kernelReady            bool // At least one kernel sample received
+
+	// Error history
+	ErrorLog     []ErrorEntry // Oldest first, bounded by maxErrorLog
+	ShowErrorLog bool
+
+	// PrivacyMode masks public IPs, MACs and the hostname on screen for
+	// screen sharing. Stored data and exports are left untouched.
+	PrivacyMode  bool
+	hostnameMask *regexp.Regexp // The hostname, compiled when privacy mode is on and it is read
+
+	// Baseline is the known good state monitored against, nil outside monitoring mode
+	Baseline     *report.Baseline
+	baselineDevs []report.Deviation // Live state against Baseline, refreshed as compared data arrives
+
+	// Notice is a confirmation shown in the status line until a newer error
+	Notice     string
+	NoticeTime time.Time
+
+	cfg               *config.Config // Config the UI was started with, updated by reloads, written back by ctrl+s
+	builtinDNSServers int            // Number of built-in servers at the start of DNSServers
+	configGen         int            // Bumped by each reload, results of the collectors it replaced are dropped
+	tunnelsDue        time.Time      // Due time of the scheduled tunnel refresh, zero while fetching
+	connDue           time.Time      // Due time of the scheduled connectivity refresh, zero while fetching
+	tickDue           time.Time      // Due time of the scheduled tick
 }
 
-func NewModel(cfg *config.Config) Model {
-	k, _ := collector.NewKernelCollector() // Handle error gracefully in Collect if nil
+// ErrorEntry is a non-fatal collector error kept for the status line and error log
+type ErrorEntry struct {
+	Time   time.Time
+	Source string
+	Err    error
+	Count  int // Consecutive repeats of the same error
+}
+
+const maxErrorLog = 50
+
+// maxPingHistory bounds the per-target RTT history (10 minutes at one sample per 5s)
+const maxPingHistory = 120
+
+// maxTrafficHistory bounds the per-interface rate history, one sample per traffic refresh
+const maxTrafficHistory = 60
+
+// DNSServerFromConfig converts a configured DNS server
+func DNSServerFromConfig(s config.DNSServerConfig) collector.DNSServer {
+	return collector.DNSServer{
+		Name:       s.Name,
+		Address:    s.Address,
+		Proto:      collector.DNSProtocol(s.Proto),
+		SourcePort: s.SourcePort,
+		H3Fallback: s.H3Fallback,
+		Family:     collector.DNSFamily(s.Family),
+	}
+}
+
+// NewConnectivityCollector applies the targets, ping and DNS check settings of cfg
+func NewConnectivityCollector(cfg *config.Config) *collector.ConnectivityCollector {
+	c := collector.NewConnectivityCollector()
+	if len(cfg.Targets) > 0 {
+		c.Targets = cfg.Targets
+	}
+	c.DSCP = cfg.Ping.DSCP
+	c.Size = cfg.Ping.Size
+	c.Pattern = collector.PayloadPattern(cfg.Ping.Pattern)
+	c.Count = cfg.Ping.Count
+	c.Interval = cfg.Ping.Interval
+	c.Timeout = cfg.Ping.Timeout
+	if cfg.Ping.Privileged != nil {
+		c.Privileged = *cfg.Ping.Privileged
+	}
+	if cfg.Ping.ReverseDNS != nil {
+		c.ReverseDNS = *cfg.Ping.ReverseDNS
+	}
+	if cfg.DNSCheck.Domain != "" {
+		c.DNSDomain = cfg.DNSCheck.Domain
+	}
+	if cfg.DNSCheck.Local.Address != "" {
+		c.LocalDNS = DNSServerFromConfig(cfg.DNSCheck.Local)
+	}
+	if d := cfg.Connectivity.DNSProbeDomain; d != "" {
+		c.DNSDomain = d
+	}
+	switch spec := cfg.Connectivity.PublicResolver; {
+	case spec != "":
+		public, err := collector.ParseResolver(spec)
+		if err != nil {
+			// Load rejects this, a config built in code reports it on the tab
+			public, c.PublicDNSErr = collector.DNSServer{Name: spec}, fmt.Errorf("public resolver: %w", err)
+		}
+		c.PublicDNS = public
+	case cfg.DNSCheck.Public.Address != "":
+		c.PublicDNS = DNSServerFromConfig(cfg.DNSCheck.Public)
+	default:
+		c.PublicCandidates = collector.PublicDNSCandidates // Race them once, a configured resolver skips this
+	}
+	return c
+}
+
+// NewPublicIPCollector builds the public IP collector from the config
+func NewPublicIPCollector(cfg *config.Config) *collector.PublicIPCollector {
+	c := collector.NewPublicIPCollector()
+	if cfg.PublicIP.GeoURL != "" {
+		c.GeoURL = cfg.PublicIP.GeoURL
+	}
+	return c
+}
 
+// NewNatCollector builds the STUN NAT collector from the config
+func NewNatCollector(cfg *config.Config) *collector.NatCollector {
 	var stunTargets []collector.StunTarget
 	for _, s := range cfg.StunServers {
 		host, portStr, err := net.SplitHostPort(s)
@@ -111,6 +340,45 @@ func NewModel(cfg *config.Config) Model {
 		})
 	}
 
+	natCollector := collector.NewNatCollector(stunTargets)
+	if cfg.STUN.Retries > 0 {
+		natCollector.Retries = cfg.STUN.Retries
+	}
+	if cfg.STUN.RTO > 0 {
+		natCollector.RTO = cfg.STUN.RTO
+	}
+	if cfg.STUN.Timeout > 0 {
+		natCollector.Timeout = cfg.STUN.Timeout
+	}
+	return natCollector
+}
+
+// visibleTabs drops the tabs that only show a disabled collector
+func visibleTabs(cfg *config.Config) []int {
+	var out []int
+	for i := range tabs {
+		switch {
+		case i == TabDNS && !cfg.CollectorEnabled(config.CollectorDNS),
+			i == TabTunnels && !cfg.CollectorEnabled(config.CollectorTunnels),
+			i == TabKernel && !cfg.CollectorEnabled(config.CollectorKernel):
+			continue
+		}
+		out = append(out, i)
+	}
+	return out
+}
+
+func NewModel(cfg *config.Config) Model {
+	// Disabled collectors stay nil and are never scheduled
+	var k *collector.KernelCollector
+	if cfg.CollectorEnabled(config.CollectorKernel) {
+		k, _ = collector.NewKernelCollector() // Handle error gracefully in Collect if nil
+	}
+	var natCollector *collector.NatCollector
+	if cfg.CollectorEnabled(config.CollectorSTUN) {
+		natCollector = NewNatCollector(cfg)
+	}
+
 	// Initialize DNS Servers
 	// Start with defaults (excluding Custom)
 	var dnsServers []collector.DNSServer
@@ -129,14 +397,11 @@ func NewModel(cfg *config.Config) Model {
 	} else {
 		dnsServers = append(dnsServers, defaults...)
 	}
+	builtinDNSServers := len(dnsServers)
 
 	// Add Configured Servers
 	for _, s := range cfg.DNSServers {
-		dnsServers = append(dnsServers, collector.DNSServer{
-			Name:    s.Name,
-			Address: s.Address,
-			Proto:   collector.DNSProtocol(s.Proto),
-		})
+		dnsServers = append(dnsServers, DNSServerFromConfig(s))
 	}
 
 	// Add Custom at the end
@@ -155,26 +420,168 @@ func NewModel(cfg *config.Config) Model {
 	si.CharLimit = 255
 	si.Width = 30
 
+	var regions []collector.RegionEndpoint
+	for _, r := range cfg.Regions {
+		regions = append(regions, collector.RegionEndpoint{Name: r.Name, Address: r.Address})
+	}
+
+	var trafficCollector *collector.TrafficCollector
+	var softirqCollector *collector.SoftirqCollector
+	if cfg.CollectorEnabled(config.CollectorTraffic) {
+		trafficCollector = collector.NewTrafficCollector()
+		softirqCollector = collector.NewSoftirqCollector()
+		if cfg.TrafficSource != "" {
+			trafficCollector.Source = collector.TrafficSource(cfg.TrafficSource)
+		}
+	}
+
+	bufferbloat := collector.NewBufferbloatCollector()
+	bb := cfg.Bufferbloat
+	if bb.Target != "" {
+		bufferbloat.Target = bb.Target
+	}
+	if bb.DownloadURL != "" {
+		bufferbloat.DownloadURL = bb.DownloadURL
+	}
+	if bb.UploadURL != "" {
+		bufferbloat.UploadURL = bb.UploadURL
+	}
+	if bb.Duration > 0 {
+		bufferbloat.Duration = bb.Duration
+	}
+	if bb.MaxMB > 0 {
+		bufferbloat.MaxBytes = bb.MaxMB << 20
+	}
+
+	connCollector := NewConnectivityCollector(cfg)
+	var dnsCollector *collector.DNSCollector
+	if cfg.CollectorEnabled(config.CollectorDNS) {
+		dnsCollector = collector.NewDNSCollector()
+		dnsCollector.Source = connCollector.Source // One active interface for every diagnostic
+	}
+
+	egressCollector := collector.NewEgressCollector(connCollector.Targets)
+	egressCollector.Source = connCollector.Source
+
+	portScanner := collector.NewPortScanner()
+	portScanner.Source = connCollector.Source
+	if len(cfg.PortScan.Ports) > 0 {
+		portScanner.Ports = cfg.PortScan.Ports
+	}
+	portScanner.GrabBanner = cfg.PortScan.Banners
+
+	speedTest := collector.NewSpeedTestCollector()
+	speedTestServers := collector.DefaultSpeedTestServers
+	if len(cfg.SpeedTest) > 0 {
+		speedTestServers = nil
+		for _, st := range cfg.SpeedTest {
+			speedTestServers = append(speedTestServers, collector.SpeedTestServer{Name: st.Name, DownloadURL: st.DownloadURL, UploadURL: st.UploadURL})
+		}
+	}
+
+	// One budget across the periodic probes and the load tests
+	budget := collector.NewBudget(cfg.Budget.ProbesPerMinute, cfg.Budget.KBPerMinute<<10)
+	connCollector.Budget = budget
+	bufferbloat.Budget = budget
+	speedTest.Budget = budget
+	var tunnelCollector *collector.TunnelCollector
+	if cfg.CollectorEnabled(config.CollectorTunnels) {
+		tunnelCollector = collector.NewTunnelCollector(cfg.Tunnels)
+		tunnelCollector.Budget = budget
+	}
+	var publicIPCollector *collector.PublicIPCollector
+	if cfg.CollectorEnabled(config.CollectorPublicIP) {
+		publicIPCollector = NewPublicIPCollector(cfg)
+	}
+	var dhcpCollector *collector.DHCPCollector
+	if cfg.CollectorEnabled(config.CollectorDHCP) {
+		dhcpCollector = collector.NewDHCPCollector()
+	}
+
 	m := Model{
 		sysCollector:      collector.NewSystemCollector(),
-		connCollector:     collector.NewConnectivityCollector(),
-		trafficCollector:  collector.NewTrafficCollector(),
+		connCollector:     connCollector,
+		trafficCollector:  trafficCollector,
+		softirqCollector:  softirqCollector,
 		kernelCollector:   k,
-		natCollector:      collector.NewNatCollector(stunTargets),
-		publicIPCollector: collector.NewPublicIPCollector(),
-		dnsCollector:      collector.NewDNSCollector(),
-		tunnelCollector:   collector.NewTunnelCollector(cfg.Tunnels),
+		listenCollector:   collector.NewListenCollector(),
+		natCollector:      natCollector,
+		publicIPCollector: publicIPCollector,
+		dnsCollector:      dnsCollector,
+		DNSSEC:            cfg.DNSQuery.DNSSEC,
+		DNSWildcard:       cfg.DNSQuery.Wildcard,
+		dnsUDPSize:        uint16(cmp.Or(cfg.DNSQuery.UDPSize, 4096)),
+		tunnelCollector:   tunnelCollector,
+		matrixCollector:   collector.NewMatrixCollector(connCollector.Targets),
+		regionCollector:   collector.NewRegionCollector(regions),
+		urlDiagnoser:      collector.NewURLDiagnoser(),
+		dhcpCollector:     dhcpCollector,
+		bufferbloat:       bufferbloat,
+		mssCollector:      collector.NewMSSCollector(collector.MSSTargets(connCollector.Targets)),
+		egressCollector:   egressCollector,
+		tfoCollector:      collector.NewTFOCollector(collector.TFOTargets(connCollector.Targets)),
+		icmpQuery:         collector.NewICMPQueryCollector(connCollector.Targets),
+		pathMTU:           collector.NewPathMTUCollector(connCollector.Targets),
+		gatewayCollector:  collector.NewGatewayCollector(),
+		portScanner:       portScanner,
+		speedTest:         speedTest,
+		speedTestServers:  speedTestServers,
+		selfTest:          collector.NewSelfTestCollector(),
+		RateInBits:        cfg.TrafficUnits == "bits",
+		Power:             collector.PowerSourceNow(),
+		readPower:         collector.PowerSourceNow,
+		powerChecked:      time.Now(),
+		systemRefreshed:   time.Now(),
+		topSocketsTable:   newTopSocketsTable(),
+		listenTable:       newListenTable(),
+		lastInput:         time.Now(),
+		cfg:               cfg,
+		builtinDNSServers: builtinDNSServers,
 		DNSServers:        dnsServers,
 		DNSInput:          ti,
 		DNSServerInput:    si,
 		LoadingSystem:     true,
 		LoadingConn:       true,
-		LoadingNat:        true,
-		LoadingPublicIP:   true,
-		LoadingTunnels:    true,
+		Tabs:              visibleTabs(cfg),
+		LoadingNat:        natCollector != nil,
+		LoadingPublicIP:   publicIPCollector != nil,
+		LoadingTunnels:    tunnelCollector != nil,
+		LoadingSelfTest:   true,
 		// Traffic and Kernel start as false, will be triggered by Init/Tick
 	}
 
+	// The on-demand probes ping the way the connectivity check does, from
+	// the active interface
+	m.matrixCollector.PingOptions = connCollector.PingOptions
+	m.matrixCollector.Source = connCollector.Source
+	m.urlDiagnoser.PingOptions = connCollector.PingOptions
+	m.urlDiagnoser.Source = connCollector.Source
+	m.bufferbloat.PingOptions = connCollector.PingOptions
+	m.bufferbloat.Source = connCollector.Source
+	m.speedTest.PingOptions = connCollector.PingOptions
+	m.speedTest.Source = connCollector.Source
+	m.gatewayCollector.Source = connCollector.Source
+	m.pathMTU.Source = connCollector.Source
+
+	// The on-demand diagnostics draw from the same budget
+	m.matrixCollector.Budget = budget
+	m.regionCollector.Budget = budget
+	m.mssCollector.Budget = budget
+	m.tfoCollector.Budget = budget
+	m.icmpQuery.Budget = budget
+	m.gatewayCollector.Budget = budget
+	m.portScanner.Budget = budget
+	m.pathMTU.Budget = budget
+	if dnsCollector != nil {
+		dnsCollector.Budget = budget
+	}
+	if natCollector != nil {
+		natCollector.Budget = budget
+	}
+	if publicIPCollector != nil {
+		publicIPCollector.Budget = budget
+	}
+
 	// Sync initial protocol
 	if len(m.DNSServers) > 0 {
 		proto := m.DNSServers[0].Proto
@@ -190,30 +597,137 @@ func NewModel(cfg *config.Config) Model {
 }
 
 func (m Model) Init() tea.Cmd {
-	return tea.Batch(
+	cmds := []tea.Cmd{
 		fetchSystemInfo(m.sysCollector),
 		fetchConnectivity(m.connCollector),
-		fetchNatInfo(m.natCollector),
-		fetchPublicIP(m.publicIPCollector),
-		fetchTunnels(m.tunnelCollector),
+		fetchSelfTest(m.selfTest),
 		// Start the tick loop
 		tea.Tick(1*time.Second, func(t time.Time) tea.Msg {
 			return TickMsg(t)
 		}),
-	)
+	}
+	if m.natCollector != nil {
+		cmds = append(cmds, m.configScoped(fetchNatInfo(m.natCollector)))
+	}
+	if m.publicIPCollector != nil {
+		cmds = append(cmds, fetchPublicIP(m.publicIPCollector))
+	}
+	if m.tunnelCollector != nil {
+		cmds = append(cmds, m.configScoped(fetchTunnels(m.tunnelCollector)))
+	}
+	if m.dhcpCollector != nil {
+		cmds = append(cmds, fetchDHCP(m.dhcpCollector))
+	}
+	return tea.Batch(cmds...)
+}
+
+// budgetStatus shows what is left of the probe budget this minute
+func budgetStatus(b *collector.Budget) string {
+	var parts []string
+	probes, bytes := b.Remaining()
+	if probes >= 0 {
+		parts = append(parts, fmt.Sprintf("%d/%d probes", probes, b.ProbesPerMinute))
+	}
+	if bytes >= 0 {
+		parts = append(parts, fmt.Sprintf("%d/%d KB", bytes>>10, b.BytesPerMinute>>10))
+	}
+	return "budget " + strings.Join(parts, ", ") + " per min"
 }
 
 // Messages
 type SystemInfoMsg collector.HostInfo
+type LinkStateMsg map[string]collector.LinkState
 type ConnectivityMsg collector.ConnectivityStats
 type TrafficMsg collector.TrafficStats
+type SoftirqMsg collector.SoftirqStats
 type KernelMsg collector.KernelStats
+type TopSocketsMsg []collector.SocketInfo
+type ListenSocketsMsg collector.ListenResult
 type NatMsg []collector.NatInfo
 type PublicIPMsg collector.PublicIPInfo
+type GeolocationMsg collector.PublicIPInfo // PublicIPMsg with the ASN and location added
+type DHCPMsg collector.DHCPInfo
 type DNSMsg collector.DNSLookupResult
 type DNSPingMsg collector.PingResult
+type SRVCheckMsg []collector.SRVCheck
+type URLDiagnosisMsg collector.URLDiagnosis
+type DNSConsistencyMsg collector.DNSConsistencyResult
+type DNSCapabilitiesMsg collector.ResolverCapabilities
+type ZoneTransferMsg collector.ZoneTransferResult
+type DNSTraceMsg collector.DNSTraceResult
+type DNSCacheMsg collector.DNSCacheResult
+type PortScanMsg collector.PortScan
+type DNSBatchMsg collector.DNSBatchResult
+type DNSCompareMsg collector.DNSComparison
+type SpeedTestProgressMsg collector.SpeedTestProgress
+type SpeedTestMsg collector.SpeedTestResult
+type ResolveConnectMsg collector.ResolveConnectResult
+type DNSBreakdownMsg collector.DNSBreakdown
 type TunnelMsg []collector.TunnelResult
+type MatrixMsg collector.ConnectivityMatrix
+type RegionsMsg []collector.RegionLatency
+type BufferbloatMsg collector.BufferbloatResult
+type MSSMsg []collector.MSSResult
+type EgressMsg []collector.EgressRoute
+type DNSEgressMsg []collector.EgressRoute
+type TFOMsg collector.TFOReport
+type ICMPQueryMsg []collector.ICMPQueryResult
+type PathMTUMsg []collector.PathMTUResult
+
+// ConfigReloadMsg carries the config file after it changed on disk, or the
+// error that kept it from loading
+type ConfigReloadMsg struct {
+	Config *config.Config
+	Error  error
+}
+
+// ConfigWatchMsg reports that edits to the config file will not be picked
+// up because the file could not be watched
+type ConfigWatchMsg struct {
+	Error error
+}
+
+// configScopedMsg is the result of a collector a reload may replace, tagged
+// with the config generation it was started under
+type configScopedMsg struct {
+	gen int
+	msg tea.Msg
+}
+
+// tunnelTickMsg is a due tunnel refresh, identified by its due time
+type tunnelTickMsg time.Time
+
+// connTickMsg and scheduledTickMsg are a due connectivity refresh and a
+// due tick, identified by their due time so a sooner one can replace them
+type connTickMsg time.Time
+type scheduledTickMsg time.Time
+type GatewayHealthMsg collector.GatewayHealth
+type SelfTestMsg []collector.SelfCheck
+
+type DNSPasteMsg struct {
+	Host  string
+	Error error
+}
 type TickMsg time.Time
+type ConfigSavedMsg struct {
+	Path   string
+	Config *config.Config // What was written
+	Error  error
+}
+
+// SnapshotExportedMsg reports where the JSON snapshot was written
+type SnapshotExportedMsg struct {
+	Path  string
+	Error error
+}
+
+// BundleMsg reports where the diagnostic bundle ended up
+type BundleMsg struct {
+	Path     string // Empty if the file could not be written
+	Copied   bool
+	CopyErr  error
+	WriteErr error
+}
 
 // Commands
 func fetchSystemInfo(c *collector.SystemCollector) tea.Cmd {
@@ -226,11 +740,21 @@ func fetchSystemInfo(c *collector.SystemCollector) tea.Cmd {
 	}
 }
 
+func fetchLinkStates(c *collector.SystemCollector, ifaces []collector.InterfaceInfo) tea.Cmd {
+	names := make([]string, len(ifaces))
+	for i, iface := range ifaces {
+		names[i] = iface.Name
+	}
+	return func() tea.Msg {
+		return LinkStateMsg(c.LinkStates(names))
+	}
+}
+
 func fetchConnectivity(c *collector.ConnectivityCollector) tea.Cmd {
 	return func() tea.Msg {
 		stats, err := c.Collect()
 		if err != nil {
-			// Handle error in stats
+			stats.Error = err
 		}
 		return ConnectivityMsg(stats)
 	}
@@ -255,22 +779,159 @@ func fetchPublicIP(c *collector.PublicIPCollector) tea.Cmd {
 	}
 }
 
+func fetchGeolocation(c *collector.PublicIPCollector, info collector.PublicIPInfo) tea.Cmd {
+	return func() tea.Msg {
+		return GeolocationMsg(c.Geolocate(info))
+	}
+}
+
+func fetchDHCP(c *collector.DHCPCollector) tea.Cmd {
+	return func() tea.Msg {
+		return DHCPMsg(c.Collect())
+	}
+}
+
 func fetchTunnels(c *collector.TunnelCollector) tea.Cmd {
 	return func() tea.Msg {
 		return TunnelMsg(c.Collect())
 	}
 }
 
+// configScoped tags the result of cmd with the current config generation
+func (m Model) configScoped(cmd tea.Cmd) tea.Cmd {
+	gen := m.configGen
+	return func() tea.Msg {
+		return configScopedMsg{gen: gen, msg: cmd()}
+	}
+}
+
+func fetchRegions(c *collector.RegionCollector) tea.Cmd {
+	return func() tea.Msg {
+		return RegionsMsg(c.Collect())
+	}
+}
+
+// startSpeedTest runs the speed test in the background. Progress and the
+// result arrive on updates, read one at a time by waitSpeedTest.
+func startSpeedTest(ctx context.Context, c *collector.SpeedTestCollector, server collector.SpeedTestServer, updates chan tea.Msg) tea.Cmd {
+	go func() {
+		res := c.Run(ctx, server, func(p collector.SpeedTestProgress) {
+			select {
+			case updates <- SpeedTestProgressMsg(p):
+			default: // The UI is behind, skip this update
+			}
+		})
+		updates <- SpeedTestMsg(res)
+	}()
+	return waitSpeedTest(updates)
+}
+
+func waitSpeedTest(updates chan tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		return <-updates
+	}
+}
+
+func fetchBufferbloat(c *collector.BufferbloatCollector) tea.Cmd {
+	return func() tea.Msg {
+		return BufferbloatMsg(c.Measure(context.Background()))
+	}
+}
+
+func fetchEgress(c *collector.EgressCollector) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		return EgressMsg(c.Collect(ctx))
+	}
+}
+
+func fetchDNSEgress(c *collector.EgressCollector, host string) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		return DNSEgressMsg(c.Lookup(ctx, host))
+	}
+}
+
+func fetchMSS(c *collector.MSSCollector) tea.Cmd {
+	return func() tea.Msg {
+		return MSSMsg(c.Collect(context.Background()))
+	}
+}
+
+func fetchTFO(c *collector.TFOCollector) tea.Cmd {
+	return func() tea.Msg {
+		return TFOMsg(c.Collect(context.Background()))
+	}
+}
+
+func fetchICMPQueries(c *collector.ICMPQueryCollector) tea.Cmd {
+	return func() tea.Msg {
+		return ICMPQueryMsg(c.Collect(context.Background()))
+	}
+}
+
+func fetchPathMTU(c *collector.PathMTUCollector) tea.Cmd {
+	return func() tea.Msg {
+		return PathMTUMsg(c.Collect(context.Background()))
+	}
+}
+
+func fetchGatewayHealth(c *collector.GatewayCollector, stats collector.ConnectivityStats) tea.Cmd {
+	return func() tea.Msg {
+		return GatewayHealthMsg(c.Check(context.Background(), stats))
+	}
+}
+
+func fetchSelfTest(c *collector.SelfTestCollector) tea.Cmd {
+	return func() tea.Msg {
+		return SelfTestMsg(c.Run(context.Background()))
+	}
+}
+
+func fetchMatrix(c *collector.MatrixCollector) tea.Cmd {
+	return func() tea.Msg {
+		return MatrixMsg(c.Collect())
+	}
+}
+
+func fetchSoftirqs(c *collector.SoftirqCollector) tea.Cmd {
+	return func() tea.Msg {
+		stats, _ := c.Collect()
+		return SoftirqMsg(stats)
+	}
+}
+
 func fetchTraffic(c *collector.TrafficCollector) tea.Cmd {
 	return func() tea.Msg {
 		stats, err := c.Collect()
 		if err != nil {
-			// Handle error
+			stats.Error = err
 		}
 		return TrafficMsg(stats)
 	}
 }
 
+// topSocketsCount is how many sockets the Kernel tab drill-down lists
+const topSocketsCount = 10
+
+func fetchTopSockets(c *collector.KernelCollector, by collector.SocketOrder) tea.Cmd {
+	return func() tea.Msg {
+		if c == nil {
+			return TopSocketsMsg(nil)
+		}
+		return TopSocketsMsg(c.TopSockets(topSocketsCount, by))
+	}
+}
+
+func fetchListenSockets(c *collector.ListenCollector) tea.Cmd {
+	return func() tea.Msg {
+		sockets, err := c.Collect()
+		return ListenSocketsMsg{Sockets: sockets, Error: err}
+	}
+}
+
 func fetchKernel(c *collector.KernelCollector) tea.Cmd {
 	return func() tea.Msg {
 		if c == nil {
@@ -284,98 +945,475 @@ func fetchKernel(c *collector.KernelCollector) tea.Cmd {
 	}
 }
 
-func fetchDNS(c *collector.DNSCollector, domain string, recordType collector.DNSRecordType, server collector.DNSServer) tea.Cmd {
+func fetchDNS(c *collector.DNSCollector, domain string, recordType collector.DNSRecordType, server collector.DNSServer, opts collector.DNSQueryOptions) tea.Cmd {
 	return func() tea.Msg {
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
-		// Handle Auto type
-		if recordType == "Auto" {
-			// If IP, use PTR. If Domain, use A.
-			if net.ParseIP(domain) != nil {
-				recordType = collector.RecordPTR
-			} else {
-				recordType = collector.RecordA
-			}
-		}
-		return DNSMsg(c.Lookup(ctx, domain, recordType, server))
+		return DNSMsg(c.LookupWithOptions(ctx, domain, resolveRecordType(domain, recordType), server, opts))
 	}
 }
 
-func fetchSinglePing(c *collector.ConnectivityCollector, target string) tea.Cmd {
+// dnsConsistencyQueries is the number of repeated queries for the consistency check
+const dnsConsistencyQueries = 10
+
+// dnsSafeUDPSize is the DNS Flag Day 2020 EDNS buffer size, larger UDP answers risk fragmentation
+const dnsSafeUDPSize = 1232
+
+func fetchDNSCapabilities(c *collector.DNSCollector, server collector.DNSServer) tea.Cmd {
 	return func() tea.Msg {
-		return DNSPingMsg(c.Ping(target))
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		return DNSCapabilitiesMsg(c.Capabilities(ctx, server))
 	}
 }
 
-func tickTraffic() tea.Cmd {
-	return tea.Tick(1*time.Second, func(t time.Time) tea.Msg {
-		return TickMsg(t)
-	})
+func fetchZoneTransfer(c *collector.DNSCollector, zone string, server collector.DNSServer) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		return ZoneTransferMsg(c.ZoneTransferCheck(ctx, zone, server))
+	}
 }
 
-// Removed duplicate tickKernel and tickTraffic usage in Init
-
-func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
-	var (
-		cmds []tea.Cmd
-	)
+func fetchDNSTrace(c *collector.DNSCollector, domain string, recordType collector.DNSRecordType) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		steps, err := c.Trace(ctx, domain, recordType)
+		return DNSTraceMsg{Domain: domain, Type: recordType, Steps: steps, Error: err}
+	}
+}
 
-	switch msg := msg.(type) {
-	case tea.KeyMsg:
-		switch msg.String() {
-		case "q", "ctrl+c":
-			return m, tea.Quit
-		case "tab":
-			m.ActiveTab = (m.ActiveTab + 1) % len(tabs)
-			return m, nil
-		case "shift+tab":
-			m.ActiveTab = (m.ActiveTab - 1 + len(tabs)) % len(tabs)
-			return m, nil
-		}
+func fetchDNSCache(c *collector.DNSCollector, domain string, recordType collector.DNSRecordType, server collector.DNSServer) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer cancel()
+		cached, ttl1, ttl2, err := c.CacheProbe(ctx, domain, resolveRecordType(domain, recordType), server)
+		return DNSCacheMsg{Domain: domain, Server: server.Name, Cached: cached, TTL1: ttl1, TTL2: ttl2, Error: err}
+	}
+}
 
-		if m.ActiveTab == TabDNS {
-			isCustom := m.DNSServers[m.SelectedDNSServer].Name == "Custom"
+func fetchDNSBatch(c *collector.DNSCollector, tmpl string, server collector.DNSServer) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+		defer cancel()
+		return DNSBatchMsg(c.Batch(ctx, tmpl, server))
+	}
+}
 
-			switch msg.String() {
-			case "enter":
-				m.LoadingDNS = true
-				m.DNSResult = nil // Clear previous result
-				m.DNSPing = nil   // Clear previous ping
-				server := m.DNSServers[m.SelectedDNSServer]
-				if isCustom {
-					server.Address = m.DNSServerInput.Value()
-				}
-				server.Proto = dnsProtocols[m.SelectedProtocol]
-				cmds = append(cmds, fetchDNS(m.dnsCollector, m.DNSInput.Value(), dnsRecordTypes[m.SelectedRecordType], server))
-				return m, tea.Batch(cmds...)
+func fetchDNSCompare(c *collector.DNSCollector, domain string, recordType collector.DNSRecordType, servers []collector.DNSServer) tea.Cmd {
+	return func() tea.Msg {
+		return DNSCompareMsg(c.Compare(context.Background(), domain, resolveRecordType(domain, recordType), servers))
+	}
+}
 
-			case "down":
-				m.SelectedDNSServer = (m.SelectedDNSServer + 1) % len(m.DNSServers)
-				m.DNSFocus = 0
-				m.DNSInput.Focus()
-				m.DNSServerInput.Blur()
-				// Sync protocol
-				proto := m.DNSServers[m.SelectedDNSServer].Proto
-				for i, p := range dnsProtocols {
-					if p == proto {
-						m.SelectedProtocol = i
-						break
-					}
-				}
+func fetchResolveConnect(c *collector.DNSCollector, name, app string, server collector.DNSServer) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer cancel()
+		return ResolveConnectMsg(c.ResolveConnect(ctx, name, app, server))
+	}
+}
 
-			case "up":
-				m.SelectedDNSServer = (m.SelectedDNSServer - 1 + len(m.DNSServers)) % len(m.DNSServers)
-				m.DNSFocus = 0
-				m.DNSInput.Focus()
-				m.DNSServerInput.Blur()
-				// Sync protocol
-				proto := m.DNSServers[m.SelectedDNSServer].Proto
-				for i, p := range dnsProtocols {
-					if p == proto {
-						m.SelectedProtocol = i
-						break
-					}
+func fetchPortScan(s *collector.PortScanner, target string) tea.Cmd {
+	return func() tea.Msg {
+		return PortScanMsg(s.Scan(context.Background(), target))
+	}
+}
+
+func fetchDNSConsistency(c *collector.DNSCollector, domain string, recordType collector.DNSRecordType, server collector.DNSServer) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		return DNSConsistencyMsg(c.ConsistencyCheck(ctx, domain, resolveRecordType(domain, recordType), server, dnsConsistencyQueries))
+	}
+}
+
+func fetchSRVChecks(c *collector.DNSCollector, records []collector.SRVRecord) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		return SRVCheckMsg(c.CheckSRVTargets(ctx, records))
+	}
+}
+
+func fetchURLDiagnosis(d *collector.URLDiagnoser, rawURL string) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		return URLDiagnosisMsg(d.Diagnose(ctx, rawURL))
+	}
+}
+
+// fetchDNSBreakdown times the resolution of domain, the connectivity probe
+// domain, step by step
+func fetchDNSBreakdown(c *collector.DNSCollector, domain string) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		return DNSBreakdownMsg(c.Breakdown(ctx, domain))
+	}
+}
+
+// resolveRecordType handles the "Auto" type: PTR for IPs, A for domains
+func resolveRecordType(domain string, recordType collector.DNSRecordType) collector.DNSRecordType {
+	if recordType != "Auto" {
+		return recordType
+	}
+	if net.ParseIP(domain) != nil {
+		return collector.RecordPTR
+	}
+	return collector.RecordA
+}
+
+func fetchSinglePing(c *collector.ConnectivityCollector, target string) tea.Cmd {
+	return func() tea.Msg {
+		return DNSPingMsg(c.Ping(target))
+	}
+}
+
+// pasteHost reads the clipboard and extracts the host from a pasted URL/IP
+func pasteHost() tea.Cmd {
+	return func() tea.Msg {
+		text, err := clipboard.ReadAll()
+		if err != nil {
+			return DNSPasteMsg{Error: err}
+		}
+		host, err := collector.ExtractHost(text)
+		return DNSPasteMsg{Host: host, Error: err}
+	}
+}
+
+func saveConfig(cfg *config.Config) tea.Cmd {
+	return func() tea.Msg {
+		return ConfigSavedMsg{Path: cfg.Path, Config: cfg, Error: config.Save(cfg, cfg.Path)}
+	}
+}
+
+// exportBundle renders the diagnostic bundle, copies it to the clipboard and
+// saves it next to the user's home so it survives a missing clipboard
+func exportBundle(s report.Snapshot, opts report.BundleOptions) tea.Cmd {
+	return func() tea.Msg {
+		text, err := report.Bundle(s, opts)
+		if err != nil {
+			return BundleMsg{WriteErr: err, CopyErr: err}
+		}
+		var msg BundleMsg
+		if msg.CopyErr = clipboard.WriteAll(text); msg.CopyErr == nil {
+			msg.Copied = true
+		}
+		path := report.DefaultBundlePath(s.Time)
+		if msg.WriteErr = os.WriteFile(path, []byte(text), 0o600); msg.WriteErr == nil {
+			msg.Path = path
+		}
+		return msg
+	}
+}
+
+// exportSnapshot writes the snapshot as JSON next to the user's home
+func exportSnapshot(s report.Snapshot, opts report.BundleOptions) tea.Cmd {
+	return func() tea.Msg {
+		path := report.DefaultExportPath(s.Time)
+		return SnapshotExportedMsg{Path: path, Error: report.ExportSnapshot(s, path, opts)}
+	}
+}
+
+func tickTraffic() tea.Cmd {
+	return tea.Tick(1*time.Second, func(t time.Time) tea.Msg {
+		return TickMsg(t)
+	})
+}
+
+// powerCheckInterval is how often the power supplies are re-read
+const powerCheckInterval = 30 * time.Second
+
+// connectivityRefreshInterval is how often the targets are pinged, one
+// latency heatmap cell each time
+const connectivityRefreshInterval = 5 * time.Second
+
+// systemRefreshInterval is how often the link state of the interfaces is
+// re-read, so state changes show up and a flapping carrier stands out. The
+// rest of the system info is collected once at startup.
+const systemRefreshInterval = 10 * time.Second
+
+// minCarrierFlaps is how many carrier changes between two reads of the
+// interfaces count as a flapping link
+const minCarrierFlaps = 2
+
+// topSocketsRefreshInterval is how often the top sockets are re-read, long
+// enough for the retransmission counters to move between two reads
+const topSocketsRefreshInterval = 3 * time.Second
+
+// listenRefreshInterval is how often the listening sockets are re-read, a
+// walk of every process's file descriptors that rarely changes between ticks
+const listenRefreshInterval = 5 * time.Second
+
+// Power save defaults for zero config values
+const (
+	defaultBatteryScale = 3
+	defaultIdleScale    = 5
+	defaultIdleAfter    = 5 * time.Minute
+)
+
+// refreshScale returns the factor the periodic refresh intervals are
+// stretched by: the battery multiplier on battery, the idle multiplier
+// after idle without input, the larger of both when both apply, and 1 on
+// AC with recent input or with power saving disabled.
+func refreshScale(cfg config.PowerSaveConfig, power collector.PowerSource, idle time.Duration) int {
+	if cfg.Disabled {
+		return 1
+	}
+	battery, idleScale := cfg.Battery, cfg.Idle
+	if battery == 0 {
+		battery = defaultBatteryScale
+	}
+	if idleScale == 0 {
+		idleScale = defaultIdleScale
+	}
+
+	scale := 1
+	if power == collector.PowerBattery {
+		scale = max(scale, battery)
+	}
+	if idle >= idleThreshold(cfg) {
+		scale = max(scale, idleScale)
+	}
+	return scale
+}
+
+// idleThreshold is the time without input after which the refresh slows
+func idleThreshold(cfg config.PowerSaveConfig) time.Duration {
+	if cfg.IdleAfter == 0 {
+		return defaultIdleAfter
+	}
+	return cfg.IdleAfter
+}
+
+// refreshInterval stretches a base refresh interval by the current scale
+func (m Model) refreshInterval(base time.Duration) time.Duration {
+	return base * time.Duration(m.refreshScale())
+}
+
+func (m Model) refreshScale() int {
+	return refreshScale(m.cfg.PowerSave, m.Power, time.Since(m.lastInput))
+}
+
+// powerSaveStatus describes a slowed refresh for the footer, empty at full speed
+func (m Model) powerSaveStatus() string {
+	scale := m.refreshScale()
+	if scale == 1 {
+		return ""
+	}
+	var why []string
+	if m.Power == collector.PowerBattery {
+		why = append(why, "on battery")
+	}
+	if time.Since(m.lastInput) >= idleThreshold(m.cfg.PowerSave) {
+		why = append(why, "idle")
+	}
+	return fmt.Sprintf("refresh %dx slower (%s)", scale, strings.Join(why, ", "))
+}
+
+// wake refreshes at once what a stretched interval still holds back, for
+// when the refresh speeds up again. The fresh results schedule the next
+// refreshes at the new pace and the stale schedules are dropped.
+func (m *Model) wake() tea.Cmd {
+	var cmds []tea.Cmd
+	if !m.connDue.IsZero() {
+		m.connDue = time.Time{}
+		cmds = append(cmds, fetchConnectivity(m.connCollector))
+	}
+	if !m.tunnelsDue.IsZero() && m.tunnelCollector != nil {
+		m.tunnelsDue = time.Time{}
+		cmds = append(cmds, m.configScoped(fetchTunnels(m.tunnelCollector)))
+	}
+	return tea.Batch(cmds...)
+}
+
+// Removed duplicate tickKernel and tickTraffic usage in Init
+
+func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var (
+		cmds []tea.Cmd
+	)
+
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		scale := m.refreshScale()
+		m.lastInput = time.Now()
+		if m.refreshScale() < scale {
+			// The key press ends idle: handle it, then catch up at once
+			wake := m.wake()
+			updated, cmd := m.Update(msg)
+			return updated, tea.Batch(cmd, wake, func() tea.Msg { return TickMsg(time.Now()) })
+		}
+		switch msg.String() {
+		case "ctrl+c":
+			return m, tea.Quit
+		case "ctrl+s":
+			cfg := m.currentConfig()
+			if cfg.Path == "" {
+				cfg.Path = config.DefaultPath()
+			}
+			return m, saveConfig(cfg)
+		case "ctrl+b":
+			return m, exportBundle(m.snapshot(), report.BundleOptions{RedactPublicIPs: m.cfg.Report.RedactPublicIPs})
+		case "ctrl+y": // Not ctrl+e, the DNS text inputs use it for end of line
+			return m, exportSnapshot(m.snapshot(), report.BundleOptions{RedactPublicIPs: m.cfg.Report.RedactPublicIPs})
+		case "ctrl+x":
+			m.PrivacyMode = !m.PrivacyMode
+			m.updateHostnameMask()
+			return m, nil
+		case "ctrl+l":
+			m.ShowErrorLog = !m.ShowErrorLog
+			if m.ShowErrorLog {
+				m.Viewport.SetContent(m.renderErrorLog())
+				m.Viewport.GotoBottom()
+			}
+			return m, nil
+		}
+
+		if m.ShowErrorLog {
+			switch msg.String() {
+			case "esc", "q":
+				m.ShowErrorLog = false
+				return m, nil
+			}
+			var cmd tea.Cmd
+			m.Viewport, cmd = m.Viewport.Update(msg)
+			return m, cmd
+		}
+
+		if m.DNSForm != nil {
+			return m.updateDNSForm(msg)
+		}
+
+		switch msg.String() {
+		case "q":
+			return m, tea.Quit
+		case "tab":
+			m.switchTab(1)
+			return m, nil
+		case "shift+tab":
+			m.switchTab(-1)
+			return m, nil
+		}
+
+		if m.ActiveTab == TabDNS {
+			isCustom := m.DNSServers[m.SelectedDNSServer].Name == "Custom"
+
+			switch msg.String() {
+			case "enter":
+				m.LoadingDNS = true
+				m.DNSResult = nil // Clear previous result
+				m.DNSPing = nil   // Clear previous ping
+				m.SRVChecks = nil
+				cmds = append(cmds, fetchDNS(m.dnsCollector, m.DNSInput.Value(), dnsRecordTypes[m.SelectedRecordType], m.selectedDNSServer(), m.dnsQueryOptions()))
+				return m, tea.Batch(cmds...)
+
+			case "ctrl+o":
+				if !m.LoadingDNSConsistency {
+					m.LoadingDNSConsistency = true
+					m.DNSConsistency = nil
+					return m, fetchDNSConsistency(m.dnsCollector, m.DNSInput.Value(), dnsRecordTypes[m.SelectedRecordType], m.selectedDNSServer())
+				}
+				return m, nil
+
+			case "ctrl+f":
+				if !m.LoadingDNSCapabilities {
+					m.LoadingDNSCapabilities = true
+					m.DNSCapabilities = nil
+					return m, fetchDNSCapabilities(m.dnsCollector, m.selectedDNSServer())
+				}
+				return m, nil
+
+			case "alt+z":
+				if !m.LoadingZoneTransfer {
+					m.LoadingZoneTransfer = true
+					m.ZoneTransfer = nil
+					return m, fetchZoneTransfer(m.dnsCollector, m.DNSInput.Value(), m.selectedDNSServer())
+				}
+				return m, nil
+
+			case "ctrl+r":
+				if !m.LoadingDNSTrace {
+					m.LoadingDNSTrace = true
+					m.DNSTrace = nil
+					return m, fetchDNSTrace(m.dnsCollector, m.DNSInput.Value(), dnsRecordTypes[m.SelectedRecordType])
+				}
+				return m, nil
+
+			case "alt+k":
+				if !m.LoadingDNSCache {
+					m.LoadingDNSCache = true
+					m.DNSCache = nil
+					return m, fetchDNSCache(m.dnsCollector, m.DNSInput.Value(), dnsRecordTypes[m.SelectedRecordType], m.selectedDNSServer())
+				}
+				return m, nil
+
+			case "alt+m": // Not ctrl+a, the DNS text input uses it for start of line
+				if !m.LoadingDNSCompare {
+					m.LoadingDNSCompare = true
+					m.DNSCompare = nil
+					return m, fetchDNSCompare(m.dnsCollector, m.DNSInput.Value(), dnsRecordTypes[m.SelectedRecordType], m.comparedDNSServers())
+				}
+				return m, nil
+
+			case "alt+b":
+				if !m.LoadingDNSBatch {
+					m.LoadingDNSBatch = true
+					m.DNSBatch = nil
+					return m, fetchDNSBatch(m.dnsCollector, m.DNSInput.Value(), m.selectedDNSServer())
+				}
+				return m, nil
+
+			case "alt+r":
+				if !m.LoadingResolveConnect {
+					m.LoadingResolveConnect = true
+					m.ResolveConnect = nil
+					return m, fetchResolveConnect(m.dnsCollector, m.DNSInput.Value(), collector.ConnectApps[m.SelectedConnectApp], m.selectedDNSServer())
+				}
+				return m, nil
+
+			case "alt+s":
+				if !m.LoadingPortScan {
+					m.LoadingPortScan = true
+					m.PortScan = nil
+					return m, fetchPortScan(m.portScanner, m.DNSInput.Value())
+				}
+				return m, nil
+
+			case "alt+o":
+				if !m.LoadingDNSEgress {
+					m.LoadingDNSEgress = true
+					m.DNSEgress = nil
+					return m, fetchDNSEgress(m.egressCollector, m.DNSInput.Value())
+				}
+				return m, nil
+
+			case "ctrl+g":
+				if !m.LoadingURLDiagnosis {
+					m.LoadingURLDiagnosis = true
+					m.URLDiagnosis = nil
+					return m, fetchURLDiagnosis(m.urlDiagnoser, m.DNSInput.Value())
+				}
+				return m, nil
+
+			case "ctrl+n":
+				m.DNSForm = newDNSServerForm(nil, -1)
+				return m, nil
+
+			case "alt+e":
+				if m.isConfiguredDNSServer(m.SelectedDNSServer) {
+					m.DNSForm = newDNSServerForm(&m.DNSServers[m.SelectedDNSServer], m.SelectedDNSServer)
 				}
+				return m, nil
+
+			case "down":
+				m.selectDNSServer((m.SelectedDNSServer + 1) % len(m.DNSServers))
+
+			case "up":
+				m.selectDNSServer((m.SelectedDNSServer - 1 + len(m.DNSServers)) % len(m.DNSServers))
 
 			case "ctrl+down":
 				if isCustom {
@@ -389,10 +1427,30 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.DNSInput.Focus()
 				m.DNSServerInput.Blur()
 
+			case "ctrl+v":
+				if m.DNSFocus == 0 {
+					return m, pasteHost()
+				}
+
 			case "ctrl+t":
 				m.SelectedRecordType = (m.SelectedRecordType + 1) % len(dnsRecordTypes)
 			case "ctrl+p":
 				m.SelectedProtocol = (m.SelectedProtocol + 1) % len(dnsProtocols)
+			case "alt+c":
+				m.DNSCookie = !m.DNSCookie
+				return m, nil
+			case "alt+d":
+				m.DNSSEC = !m.DNSSEC
+				return m, nil
+			case "alt+w":
+				m.DNSWildcard = !m.DNSWildcard
+				return m, nil
+			case "alt+v":
+				m.DNSVerbose = !m.DNSVerbose
+				return m, nil
+			case "alt+a":
+				m.SelectedConnectApp = (m.SelectedConnectApp + 1) % len(collector.ConnectApps)
+				return m, nil
 			}
 			var cmd tea.Cmd
 			if m.DNSFocus == 0 {
@@ -404,51 +1462,297 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, tea.Batch(cmds...)
 		}
 
+		if m.ActiveTab == TabDashboard {
+			switch msg.String() {
+			case "i":
+				m.ShowIdleInterfaces = !m.ShowIdleInterfaces
+				return m, nil
+			case "u":
+				m.RateInBits = !m.RateInBits
+				return m, nil
+			}
+		}
+
+		if m.ActiveTab == TabInterfaces && len(m.HostInfo.Interfaces) > 0 {
+			switch msg.String() {
+			case "down":
+				m.SelectedInterface = (m.SelectedInterface + 1) % len(m.HostInfo.Interfaces)
+				return m, nil
+			case "up":
+				n := len(m.HostInfo.Interfaces)
+				m.SelectedInterface = (m.SelectedInterface - 1 + n) % n
+				return m, nil
+			case "enter":
+				m.toggleActiveInterface()
+				return m, nil
+			}
+		}
+
+		if m.ActiveTab == TabKernel {
+			switch msg.String() {
+			case "s":
+				m.ShowTopSockets = !m.ShowTopSockets
+				m.TopSockets = nil
+				if m.ShowTopSockets && !m.LoadingTopSockets {
+					m.LoadingTopSockets = true
+					m.topSocketsRefreshed = time.Now()
+					return m, fetchTopSockets(m.kernelCollector, m.topSocketOrder())
+				}
+				return m, nil
+			case "l":
+				m.ShowListenSockets = !m.ShowListenSockets
+				m.ListenSockets = nil
+				if m.ShowListenSockets && !m.LoadingListenSockets {
+					m.LoadingListenSockets = true
+					m.listenRefreshed = time.Now()
+					return m, fetchListenSockets(m.listenCollector)
+				}
+				return m, nil
+			default:
+				// One table takes the keys: the longer listening list while
+				// shown, the top sockets otherwise
+				table := m.topSocketsTable
+				if m.ShowListenSockets {
+					table = m.listenTable
+				} else if !m.ShowTopSockets {
+					break
+				}
+				order := m.topSocketOrder()
+				if table.HandleKey(msg.String()) {
+					// Sorting by RTT asks for the highest RTT sockets, not
+					// just a reordered list of the retransmitting ones
+					if m.topSocketOrder() != order && !m.LoadingTopSockets {
+						m.LoadingTopSockets = true
+						m.topSocketsRefreshed = time.Now()
+						return m, fetchTopSockets(m.kernelCollector, m.topSocketOrder())
+					}
+					return m, nil
+				}
+			}
+		}
+
+		if m.ActiveTab == TabSpeedTest {
+			switch msg.String() {
+			case "s":
+				if m.RunningSpeedTest {
+					m.speedTestCancel()
+					return m, nil
+				}
+				ctx, cancel := context.WithCancel(context.Background())
+				m.RunningSpeedTest, m.speedTestCancel = true, cancel
+				m.SpeedTest, m.SpeedTestProgress = nil, nil
+				m.speedTestUpdates = make(chan tea.Msg, 1)
+				return m, startSpeedTest(ctx, m.speedTest, m.speedTestServers[m.SelectedSpeedTestServer], m.speedTestUpdates)
+			case "down":
+				if !m.RunningSpeedTest {
+					m.SelectedSpeedTestServer = (m.SelectedSpeedTestServer + 1) % len(m.speedTestServers)
+				}
+				return m, nil
+			case "up":
+				if !m.RunningSpeedTest {
+					n := len(m.speedTestServers)
+					m.SelectedSpeedTestServer = (m.SelectedSpeedTestServer - 1 + n) % n
+				}
+				return m, nil
+			}
+		}
+
+		if m.ActiveTab == TabConnectivity {
+			switch msg.String() {
+			case "m":
+				if !m.LoadingMatrix {
+					m.LoadingMatrix = true
+					return m, fetchMatrix(m.matrixCollector)
+				}
+				return m, nil
+			case "r":
+				if !m.LoadingRegions {
+					m.LoadingRegions = true
+					return m, fetchRegions(m.regionCollector)
+				}
+				return m, nil
+			case "b":
+				if m.dnsCollector == nil {
+					m.Notice, m.NoticeTime = "DNS collector disabled in config", time.Now()
+					return m, nil
+				}
+				if !m.LoadingDNSBreakdown {
+					m.LoadingDNSBreakdown = true
+					return m, fetchDNSBreakdown(m.dnsCollector, m.connCollector.DNSDomain)
+				}
+				return m, nil
+			case "l":
+				if !m.LoadingBufferbloat {
+					m.LoadingBufferbloat = true
+					m.Bufferbloat = nil
+					return m, fetchBufferbloat(m.bufferbloat)
+				}
+				return m, nil
+			case "u":
+				if !m.LoadingMSS {
+					m.LoadingMSS = true
+					return m, fetchMSS(m.mssCollector)
+				}
+				return m, nil
+			case "e":
+				if !m.LoadingEgress {
+					m.LoadingEgress = true
+					return m, fetchEgress(m.egressCollector)
+				}
+				return m, nil
+			case "f":
+				if !m.LoadingTFO {
+					m.LoadingTFO = true
+					return m, fetchTFO(m.tfoCollector)
+				}
+				return m, nil
+			case "t":
+				if !m.LoadingICMPQueries {
+					m.LoadingICMPQueries = true
+					return m, fetchICMPQueries(m.icmpQuery)
+				}
+				return m, nil
+			case "p":
+				if !m.LoadingPathMTU {
+					m.LoadingPathMTU = true
+					return m, fetchPathMTU(m.pathMTU)
+				}
+				return m, nil
+			}
+		}
+
 		switch msg.String() {
 		case "right":
-			m.ActiveTab = (m.ActiveTab + 1) % len(tabs)
+			m.switchTab(1)
 		case "left":
-			m.ActiveTab = (m.ActiveTab - 1 + len(tabs)) % len(tabs)
+			m.switchTab(-1)
 		}
 
 	case tea.WindowSizeMsg:
 		m.Width = msg.Width
 		m.Height = msg.Height
 		if !m.Ready {
-			m.Viewport = viewport.New(msg.Width, msg.Height-5) // Reserve space for header/footer
+			m.Viewport = viewport.New(msg.Width-4, msg.Height-8) // Reserve space for header/status/footer and the log title
 			m.Ready = true
 		} else {
-			m.Viewport.Width = msg.Width
-			m.Viewport.Height = msg.Height - 5
+			m.Viewport.Width = msg.Width - 4
+			m.Viewport.Height = msg.Height - 8
 		}
+		// Reflow the DNS form inputs to the new width
+		m.DNSInput.Width = dnsInputWidth(msg.Width)
+		m.DNSServerInput.Width = dnsInputWidth(msg.Width)
 
 	case SystemInfoMsg:
 		m.HostInfo = collector.HostInfo(msg)
+		m.updateHostnameMask()
 		m.LoadingSystem = false
+		m.refreshingSystem = false
+		m.recordError("System", m.HostInfo.Error)
+		m.refreshDeviations()
+
+	case LinkStateMsg:
+		m.refreshingSystem = false
+		// A copy, older models share the slice
+		ifaces := slices.Clone(m.HostInfo.Interfaces)
+		for i, iface := range ifaces {
+			if state, ok := msg[iface.Name]; ok {
+				ifaces[i].OperState, ifaces[i].CarrierChanges, ifaces[i].CarrierFlaps = state.OperState, state.CarrierChanges, state.CarrierFlaps
+				ifaces[i].SpeedMbps, ifaces[i].Duplex = state.SpeedMbps, state.Duplex
+				ifaces[i].MaxSpeedMbps, ifaces[i].PeerMaxSpeedMbps = state.MaxSpeedMbps, state.PeerMaxSpeedMbps
+				if state.IPv6Read {
+					ifaces[i].IPv6, ifaces[i].IPv6Privacy = state.IPv6, state.IPv6Privacy
+				}
+				if state.Queues != nil {
+					ifaces[i].Queues = state.Queues
+				}
+			}
+		}
+		m.HostInfo.Interfaces = ifaces
 
 	case ConnectivityMsg:
-		m.Connectivity = collector.ConnectivityStats(msg)
 		m.LoadingConn = false
+		if errors.Is(msg.Error, collector.ErrBudgetExceeded) {
+			// Skipped cycle, keep the last results on screen
+			m.ConnectivitySkipped = true
+		} else {
+			m.Connectivity = collector.ConnectivityStats(msg)
+			m.ConnectivitySkipped = false
+			m.recordPingHistory(m.Connectivity.Targets)
+			m.recordError("Connectivity", m.Connectivity.Error)
+			m.recordError("DNS", m.Connectivity.DNS.Error)
+			m.recordError("DNS "+m.Connectivity.DNS.PublicResolver, m.Connectivity.DNS.PublicError)
+			m.refreshDeviations()
+			cmds = append(cmds, fetchGatewayHealth(m.gatewayCollector, m.Connectivity))
+		}
 		// Schedule next update
-		cmds = append(cmds, tea.Tick(5*time.Second, func(t time.Time) tea.Msg {
-			return fetchConnectivity(m.connCollector)()
+		interval := m.refreshInterval(connectivityRefreshInterval)
+		due := time.Now().Add(interval)
+		m.connDue = due
+		cmds = append(cmds, tea.Tick(interval, func(time.Time) tea.Msg {
+			return connTickMsg(due)
 		}))
 
+	case connTickMsg:
+		if time.Time(msg).Equal(m.connDue) {
+			m.connDue = time.Time{}
+			cmds = append(cmds, fetchConnectivity(m.connCollector))
+		}
+
 	case NatMsg:
 		m.NatInfo = []collector.NatInfo(msg)
 		m.LoadingNat = false
+		for _, info := range m.NatInfo {
+			m.recordError("NAT "+info.Target, info.Error)
+		}
+		m.refreshDeviations()
 
 	case PublicIPMsg:
 		m.PublicIP = collector.PublicIPInfo(msg)
 		m.LoadingPublicIP = false
+		m.recordError("Public IP", m.PublicIP.Error)
+		m.refreshDeviations()
+		if m.PublicIP.Error == nil && m.publicIPCollector != nil {
+			cmds = append(cmds, fetchGeolocation(m.publicIPCollector, m.PublicIP))
+		}
+
+	case GeolocationMsg:
+		// Drop a lookup for an address that has changed since
+		if msg.IP == m.PublicIP.IP {
+			m.PublicIP = collector.PublicIPInfo(msg)
+		}
 
 	case TrafficMsg:
 		m.LoadingTraffic = false
 		m.Traffic = collector.TrafficStats(msg)
+		m.recordError("Traffic", m.Traffic.Error)
+		m.recordTrafficHistory(m.Traffic)
+
+	case SoftirqMsg:
+		m.LoadingSoftirqs = false
+		m.Softirqs = collector.SoftirqStats(msg)
+		m.recordError("Softirqs", m.Softirqs.Error)
 
 	case KernelMsg:
 		m.LoadingKernel = false
 		m.Kernel = collector.KernelStats(msg)
+		m.kernelReady = true
+		m.recordError("Kernel", m.Kernel.Error)
+		m.refreshDeviations()
+
+	case TopSocketsMsg:
+		m.LoadingTopSockets = false
+		if m.ShowTopSockets {
+			m.TopSockets = msg
+			m.topSocketsTable.SetRows(topSocketRows(msg))
+		}
+
+	case ListenSocketsMsg:
+		m.LoadingListenSockets = false
+		if m.ShowListenSockets {
+			res := collector.ListenResult(msg)
+			m.ListenSockets = &res
+			m.listenTable.SetRows(listenRows(msg.Sockets))
+		}
 
 	case DNSMsg:
 		m.LoadingDNS = false
@@ -481,42 +1785,574 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.LoadingDNSPing = true
 				cmds = append(cmds, fetchSinglePing(m.connCollector, target))
 			}
+
+			// SRV lookups usually precede a connection, check the targets right away
+			if len(res.SRV) > 0 {
+				m.LoadingSRVChecks = true
+				cmds = append(cmds, fetchSRVChecks(m.dnsCollector, res.SRV))
+			}
 		}
 
-	case DNSPingMsg:
-		m.LoadingDNSPing = false
-		res := collector.PingResult(msg)
-		m.DNSPing = &res
+	case URLDiagnosisMsg:
+		m.LoadingURLDiagnosis = false
+		res := collector.URLDiagnosis(msg)
+		m.URLDiagnosis = &res
 
-	case TunnelMsg:
-		m.LoadingTunnels = false
-		m.TunnelResults = []collector.TunnelResult(msg)
-		// Schedule next update (e.g., every 30 seconds or manual refresh)
-		// For now, let's refresh every 60 seconds
-		cmds = append(cmds, tea.Tick(60*time.Second, func(t time.Time) tea.Msg {
-			return fetchTunnels(m.tunnelCollector)()
-		}))
+	case DHCPMsg:
+		info := collector.DHCPInfo(msg)
+		m.DHCP = &info
+		m.addDHCPServers(info.Leases)
 
-	case TickMsg:
-		// Trigger updates if not already loading
-		if !m.LoadingTraffic {
-			m.LoadingTraffic = true
-			cmds = append(cmds, fetchTraffic(m.trafficCollector))
+	case BundleMsg:
+		switch {
+		case msg.Copied && msg.Path != "":
+			m.Notice = "Diagnostic bundle copied to the clipboard and saved to " + msg.Path
+		case msg.Path != "":
+			m.Notice = fmt.Sprintf("Diagnostic bundle saved to %s (clipboard unavailable: %v)", msg.Path, msg.CopyErr)
+		case msg.Copied:
+			m.Notice = fmt.Sprintf("Diagnostic bundle copied to the clipboard (not saved: %v)", msg.WriteErr)
+		default:
+			m.recordError("Bundle", msg.WriteErr)
 		}
-		if !m.LoadingKernel {
-			m.LoadingKernel = true
-			cmds = append(cmds, fetchKernel(m.kernelCollector))
+		if msg.Copied || msg.Path != "" {
+			m.NoticeTime = time.Now()
 		}
 
-		// Schedule next tick
-		cmds = append(cmds, tea.Tick(1*time.Second, func(t time.Time) tea.Msg {
-			return TickMsg(t)
-		}))
-	}
-
+	case SnapshotExportedMsg:
+		if msg.Error != nil {
+			m.recordError("Export", msg.Error)
+		} else {
+			m.Notice = "Snapshot exported to " + msg.Path
+			m.NoticeTime = time.Now()
+		}
+
+	case ConfigReloadMsg:
+		if msg.Error != nil {
+			err := msg.Error
+			// The status line has room for one problem
+			var invalid *config.ValidationError
+			if errors.As(err, &invalid) && len(invalid.Problems) > 1 {
+				err = fmt.Errorf("%s (and %d more)", invalid.Problems[0], len(invalid.Problems)-1)
+			}
+			m.recordError("Config", fmt.Errorf("reload failed, keeping the previous config: %w", err))
+			break
+		}
+		// Unknown keys load fine, a typo in one should not go unnoticed
+		warning := ""
+		if w := msg.Config.Warnings; len(w) > 0 {
+			warning = "; " + w[0]
+			if len(w) > 1 {
+				warning += fmt.Sprintf(" (and %d more)", len(w)-1)
+			}
+		}
+		if !m.reloadChanges(msg.Config) {
+			// Our own save, or an edit to settings read only at startup
+			if warning != "" {
+				m.Notice, m.NoticeTime = "Config reloaded, nothing to update"+warning, time.Now()
+			}
+			break
+		}
+		cmds = append(cmds, m.applyConfig(msg.Config))
+		m.Notice = "Config reloaded: DNS servers, STUN and tunnels updated, other settings apply after a restart" + warning
+		m.NoticeTime = time.Now()
+
+	case ConfigWatchMsg:
+		m.recordError("Config", fmt.Errorf("not watching the file, edits apply after a restart: %w", msg.Error))
+
+	case configScopedMsg:
+		if msg.gen == m.configGen {
+			return m.Update(msg.msg)
+		}
+		// From a collector a reload has replaced since
+
+	case ConfigSavedMsg:
+		if msg.Error != nil {
+			m.recordError("Config", msg.Error)
+		} else {
+			// The file now lists the servers of the UI, its reload changes nothing
+			next := *m.cfg
+			next.DNSServers = msg.Config.DNSServers
+			m.cfg = &next
+			m.Notice = "Config saved to " + msg.Path
+			m.NoticeTime = time.Now()
+		}
+
+	case SRVCheckMsg:
+		m.LoadingSRVChecks = false
+		m.SRVChecks = []collector.SRVCheck(msg)
+
+	case DNSPingMsg:
+		m.LoadingDNSPing = false
+		res := collector.PingResult(msg)
+		m.DNSPing = &res
+
+	case DNSCapabilitiesMsg:
+		m.LoadingDNSCapabilities = false
+		res := collector.ResolverCapabilities(msg)
+		m.DNSCapabilities = &res
+
+	case ZoneTransferMsg:
+		m.LoadingZoneTransfer = false
+		res := collector.ZoneTransferResult(msg)
+		m.ZoneTransfer = &res
+		m.recordError("AXFR "+res.Zone, res.Error)
+
+	case DNSTraceMsg:
+		m.LoadingDNSTrace = false
+		res := collector.DNSTraceResult(msg)
+		m.DNSTrace = &res
+
+	case DNSCacheMsg:
+		m.LoadingDNSCache = false
+		res := collector.DNSCacheResult(msg)
+		m.DNSCache = &res
+
+	case ResolveConnectMsg:
+		m.LoadingResolveConnect = false
+		res := collector.ResolveConnectResult(msg)
+		m.ResolveConnect = &res
+		m.recordError("Resolve and Connect "+res.Name, res.Error)
+
+	case DNSCompareMsg:
+		m.LoadingDNSCompare = false
+		res := collector.DNSComparison(msg)
+		m.DNSCompare = &res
+
+	case DNSBatchMsg:
+		m.LoadingDNSBatch = false
+		res := collector.DNSBatchResult(msg)
+		m.DNSBatch = &res
+		m.recordError("DNS batch", res.Error)
+
+	case PortScanMsg:
+		m.LoadingPortScan = false
+		res := collector.PortScan(msg)
+		m.PortScan = &res
+		m.recordError("Port scan "+res.Target, res.Error)
+
+	case DNSConsistencyMsg:
+		m.LoadingDNSConsistency = false
+		res := collector.DNSConsistencyResult(msg)
+		m.DNSConsistency = &res
+
+	case DNSBreakdownMsg:
+		m.LoadingDNSBreakdown = false
+		res := collector.DNSBreakdown(msg)
+		m.DNSBreakdown = &res
+
+	case TunnelMsg:
+		m.LoadingTunnels = false
+		m.TunnelResults = []collector.TunnelResult(msg)
+		// Refresh every 60 seconds. A reload fetches right away, which
+		// schedules another refresh; only the latest one is kept.
+		interval := m.refreshInterval(60 * time.Second)
+		due := time.Now().Add(interval)
+		m.tunnelsDue = due
+		cmds = append(cmds, tea.Tick(interval, func(time.Time) tea.Msg {
+			return tunnelTickMsg(due)
+		}))
+
+	case tunnelTickMsg:
+		if time.Time(msg).Equal(m.tunnelsDue) && m.tunnelCollector != nil {
+			m.tunnelsDue = time.Time{}
+			cmds = append(cmds, m.configScoped(fetchTunnels(m.tunnelCollector)))
+		}
+
+	case DNSPasteMsg:
+		if msg.Error != nil {
+			m.DNSResult = &collector.DNSLookupResult{Error: fmt.Errorf("clipboard paste failed: %v", msg.Error)}
+		} else {
+			m.DNSInput.SetValue(msg.Host)
+			m.DNSInput.CursorEnd()
+		}
+
+	case MSSMsg:
+		m.LoadingMSS = false
+		m.MSS = msg
+
+	case EgressMsg:
+		m.LoadingEgress = false
+		m.Egress = msg
+
+	case DNSEgressMsg:
+		m.LoadingDNSEgress = false
+		m.DNSEgress = msg
+
+	case TFOMsg:
+		m.LoadingTFO = false
+		report := collector.TFOReport(msg)
+		m.TFO = &report
+		m.recordError("TFO", report.KernelError)
+
+	case ICMPQueryMsg:
+		m.LoadingICMPQueries = false
+		m.ICMPQueries = msg
+
+	case PathMTUMsg:
+		m.LoadingPathMTU = false
+		m.PathMTU = msg
+
+	case GatewayHealthMsg:
+		health := collector.GatewayHealth(msg)
+		m.Gateway = &health
+		m.recordError("Gateway", health.Error)
+		m.recordError("Gateway second hop", health.SecondHopError)
+		m.refreshDeviations()
+
+	case SelfTestMsg:
+		m.LoadingSelfTest = false
+		m.SelfTest = msg
+
+	case SpeedTestProgressMsg:
+		progress := collector.SpeedTestProgress(msg)
+		m.SpeedTestProgress = &progress
+		return m, waitSpeedTest(m.speedTestUpdates)
+
+	case SpeedTestMsg:
+		res := collector.SpeedTestResult(msg)
+		m.RunningSpeedTest, m.speedTestCancel = false, nil
+		m.SpeedTest, m.SpeedTestProgress = &res, nil
+		m.recordError("Speed test", res.Error)
+
+	case BufferbloatMsg:
+		m.LoadingBufferbloat = false
+		res := collector.BufferbloatResult(msg)
+		m.Bufferbloat = &res
+
+	case RegionsMsg:
+		m.LoadingRegions = false
+		m.Regions = []collector.RegionLatency(msg)
+
+	case MatrixMsg:
+		m.LoadingMatrix = false
+		matrix := collector.ConnectivityMatrix(msg)
+		m.Matrix = &matrix
+
+	case scheduledTickMsg:
+		if time.Time(msg).Equal(m.tickDue) {
+			return m.Update(TickMsg(time.Now()))
+		}
+
+	case TickMsg:
+		if time.Since(m.powerChecked) >= powerCheckInterval {
+			scale := m.refreshScale()
+			m.Power = m.readPower()
+			m.powerChecked = time.Now()
+			if m.refreshScale() < scale {
+				// Back on AC power
+				cmds = append(cmds, m.wake())
+			}
+		}
+		// Trigger updates if not already loading
+		if !m.LoadingSystem && !m.refreshingSystem && time.Since(m.systemRefreshed) >= m.refreshInterval(systemRefreshInterval) {
+			m.refreshingSystem = true
+			m.systemRefreshed = time.Now()
+			cmds = append(cmds, fetchLinkStates(m.sysCollector, m.HostInfo.Interfaces))
+		}
+		if !m.LoadingTraffic && m.trafficCollector != nil {
+			m.LoadingTraffic = true
+			cmds = append(cmds, fetchTraffic(m.trafficCollector))
+		}
+		if !m.LoadingSoftirqs && m.softirqCollector != nil {
+			m.LoadingSoftirqs = true
+			cmds = append(cmds, fetchSoftirqs(m.softirqCollector))
+		}
+		if !m.LoadingKernel && m.cfg.CollectorEnabled(config.CollectorKernel) {
+			m.LoadingKernel = true
+			cmds = append(cmds, fetchKernel(m.kernelCollector))
+		}
+		if m.ShowTopSockets && !m.LoadingTopSockets && m.ActiveTab == TabKernel &&
+			time.Since(m.topSocketsRefreshed) >= m.refreshInterval(topSocketsRefreshInterval) {
+			m.LoadingTopSockets = true
+			m.topSocketsRefreshed = time.Now()
+			cmds = append(cmds, fetchTopSockets(m.kernelCollector, m.topSocketOrder()))
+		}
+		if m.ShowListenSockets && !m.LoadingListenSockets && m.ActiveTab == TabKernel &&
+			time.Since(m.listenRefreshed) >= m.refreshInterval(listenRefreshInterval) {
+			m.LoadingListenSockets = true
+			m.listenRefreshed = time.Now()
+			cmds = append(cmds, fetchListenSockets(m.listenCollector))
+		}
+
+		// Schedule next tick; a sooner TickMsg replaces it
+		interval := m.refreshInterval(time.Second)
+		due := time.Now().Add(interval)
+		m.tickDue = due
+		cmds = append(cmds, tea.Tick(interval, func(time.Time) tea.Msg {
+			return scheduledTickMsg(due)
+		}))
+	}
+
 	return m, tea.Batch(cmds...)
 }
 
+// switchTab moves delta tabs through the visible ones, wrapping around
+func (m *Model) switchTab(delta int) {
+	i := slices.Index(m.Tabs, m.ActiveTab)
+	n := len(m.Tabs)
+	m.ActiveTab = m.Tabs[((i+delta)%n+n)%n]
+}
+
+// selectedDNSServer returns the server chosen in the DNS tab with the
+// custom address and protocol override applied
+func (m Model) selectedDNSServer() collector.DNSServer {
+	server := m.DNSServers[m.SelectedDNSServer]
+	if server.Name == "Custom" {
+		server.Address = m.DNSServerInput.Value()
+	}
+	server.Proto = dnsProtocols[m.SelectedProtocol]
+	return server
+}
+
+// comparedDNSServers returns every server in the list, each with its own
+// protocol; the Custom entry only once an address is entered
+func (m Model) comparedDNSServers() []collector.DNSServer {
+	var servers []collector.DNSServer
+	for _, server := range m.DNSServers {
+		if server.Name == "Custom" {
+			server.Address = m.DNSServerInput.Value()
+			if server.Address == "" {
+				continue
+			}
+			server.Proto = dnsProtocols[m.SelectedProtocol]
+		}
+		servers = append(servers, server)
+	}
+	return servers
+}
+
+// selectDNSServer selects a server, moves focus back to the domain input
+// and syncs the protocol selector
+func (m *Model) selectDNSServer(i int) {
+	m.SelectedDNSServer = i
+	m.DNSFocus = 0
+	m.DNSInput.Focus()
+	m.DNSServerInput.Blur()
+	proto := m.DNSServers[i].Proto
+	for i, p := range dnsProtocols {
+		if p == proto {
+			m.SelectedProtocol = i
+			break
+		}
+	}
+}
+
+// addDHCPServers lists the DNS servers offered by DHCP after the built-in
+// ones. They count as built in, so saving the config does not persist them.
+func (m *Model) addDHCPServers(leases []collector.DHCPLease) {
+	var added []collector.DNSServer
+	seen := make(map[string]bool)
+	for _, s := range m.DNSServers {
+		seen[s.Address] = true
+	}
+	for _, lease := range leases {
+		for _, ip := range lease.DNSServers {
+			address := net.JoinHostPort(ip, "53")
+			if seen[address] {
+				continue
+			}
+			seen[address] = true
+			added = append(added, collector.DNSServer{
+				Name:    fmt.Sprintf("DHCP %s (%s)", ip, lease.Interface),
+				Address: address,
+				Proto:   collector.ProtoUDP,
+			})
+		}
+	}
+	if len(added) == 0 {
+		return
+	}
+
+	idx := m.builtinDNSServers
+	m.DNSServers = append(m.DNSServers[:idx], append(added, m.DNSServers[idx:]...)...)
+	m.builtinDNSServers += len(added)
+	if m.SelectedDNSServer >= idx {
+		m.SelectedDNSServer += len(added)
+	}
+}
+
+// customDNSServerIndex is the index of the trailing "Custom" slot, or len(DNSServers)
+func (m Model) customDNSServerIndex() int {
+	if n := len(m.DNSServers); n > 0 && m.DNSServers[n-1].Name == "Custom" {
+		return n - 1
+	}
+	return len(m.DNSServers)
+}
+
+// isConfiguredDNSServer reports whether a server came from the config or the
+// form, i.e. is neither built in nor the Custom slot
+func (m Model) isConfiguredDNSServer(i int) bool {
+	return i >= m.builtinDNSServers && i < m.customDNSServerIndex()
+}
+
+// dnsServerForm adds a named DNS server, or edits one added from config
+type dnsServerForm struct {
+	Name      textinput.Model
+	Address   textinput.Model
+	Protocol  int // Index into dnsProtocols
+	Focus     int // 0: name, 1: address, 2: protocol
+	EditIndex int // Index in DNSServers being edited, -1 to add
+	Err       error
+}
+
+func newDNSServerForm(server *collector.DNSServer, editIndex int) *dnsServerForm {
+	name := textinput.New()
+	name.Placeholder = "e.g. Quad9"
+	name.CharLimit = 64
+	name.Width = 30
+	name.Focus()
+
+	address := textinput.New()
+	address.Placeholder = "host[:port] or https://host/dns-query"
+	address.CharLimit = 255
+	address.Width = 40
+
+	f := &dnsServerForm{Name: name, Address: address, EditIndex: editIndex}
+	if server != nil {
+		f.Name.SetValue(server.Name)
+		f.Address.SetValue(server.Address)
+		for i, p := range dnsProtocols {
+			if p == server.Proto {
+				f.Protocol = i
+			}
+		}
+	}
+	return f
+}
+
+func (f *dnsServerForm) setFocus(i int) {
+	f.Focus = (i + 3) % 3
+	f.Name.Blur()
+	f.Address.Blur()
+	switch f.Focus {
+	case 0:
+		f.Name.Focus()
+	case 1:
+		f.Address.Focus()
+	}
+}
+
+// server validates the form and returns the server it describes
+func (f *dnsServerForm) server() (collector.DNSServer, error) {
+	name := strings.TrimSpace(f.Name.Value())
+	if name == "" {
+		return collector.DNSServer{}, fmt.Errorf("name is required")
+	}
+	proto := dnsProtocols[f.Protocol]
+	address, err := collector.NormalizeDNSServerAddress(proto, f.Address.Value())
+	if err != nil {
+		return collector.DNSServer{}, err
+	}
+	return collector.DNSServer{Name: name, Address: address, Proto: proto}, nil
+}
+
+// saveDNSServer validates the form and adds or replaces the server in the
+// selectable list, keeping Custom last
+func (m *Model) saveDNSServer() error {
+	f := m.DNSForm
+	server, err := f.server()
+	if err != nil {
+		return err
+	}
+	for i, s := range m.DNSServers {
+		if i != f.EditIndex && strings.EqualFold(s.Name, server.Name) {
+			return fmt.Errorf("a server named %q already exists", s.Name)
+		}
+	}
+
+	idx := f.EditIndex
+	if idx >= 0 {
+		// Keep per-server options the form does not edit
+		old := m.DNSServers[idx]
+		server.SourcePort, server.H3Fallback, server.Family = old.SourcePort, old.H3Fallback, old.Family
+		m.DNSServers[idx] = server
+	} else {
+		idx = m.customDNSServerIndex()
+		m.DNSServers = append(m.DNSServers[:idx], append([]collector.DNSServer{server}, m.DNSServers[idx:]...)...)
+	}
+	m.selectDNSServer(idx)
+	return nil
+}
+
+func (m Model) updateDNSForm(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	f := m.DNSForm
+	switch msg.String() {
+	case "esc":
+		m.DNSForm = nil
+		return m, nil
+	case "enter":
+		if err := m.saveDNSServer(); err != nil {
+			f.Err = err
+			return m, nil
+		}
+		m.DNSForm = nil
+		m.Notice = "DNS server added, press ctrl+s to save it to the config"
+		if f.EditIndex >= 0 {
+			m.Notice = "DNS server updated, press ctrl+s to save it to the config"
+		}
+		m.NoticeTime = time.Now()
+		return m, nil
+	case "tab", "down":
+		f.setFocus(f.Focus + 1)
+		return m, nil
+	case "shift+tab", "up":
+		f.setFocus(f.Focus - 1)
+		return m, nil
+	case "ctrl+p":
+		f.Protocol = (f.Protocol + 1) % len(dnsProtocols)
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	switch f.Focus {
+	case 0:
+		f.Name, cmd = f.Name.Update(msg)
+	case 1:
+		f.Address, cmd = f.Address.Update(msg)
+	case 2:
+		switch msg.String() {
+		case "left":
+			f.Protocol = (f.Protocol - 1 + len(dnsProtocols)) % len(dnsProtocols)
+		case "right", " ":
+			f.Protocol = (f.Protocol + 1) % len(dnsProtocols)
+		}
+	}
+	return m, cmd
+}
+
+func (m Model) renderDNSForm() string {
+	f := m.DNSForm
+	title := "Add DNS Server"
+	if f.EditIndex >= 0 {
+		title = "Edit DNS Server"
+	}
+	s := ui.TitleStyle.Render(title) + "\n\n"
+
+	proto := string(dnsProtocols[f.Protocol])
+	if f.Focus == 2 {
+		proto = ui.SubtitleStyle.Render("< " + proto + " >")
+	}
+	s += fmt.Sprintf("Name:      %s\n", f.Name.View())
+	s += fmt.Sprintf("Address:   %s\n", f.Address.View())
+	s += fmt.Sprintf("Protocol:  %s\n", proto)
+
+	if f.Err != nil {
+		s += "\n" + ui.Status(ui.LevelFail, f.Err.Error()) + "\n"
+	}
+	s += ui.SubtleStyle.Render("\nTab/Up/Down to move, Left/Right or Ctrl+p to change protocol, Enter to save, Esc to cancel") + "\n"
+	return s
+}
+
+// dnsQueryOptions returns the query options toggled in the DNS tab
+func (m Model) dnsQueryOptions() collector.DNSQueryOptions {
+	return collector.DNSQueryOptions{
+		Cookie:          m.DNSCookie,
+		Wildcard:        m.DNSWildcard,
+		UDPSize:         m.dnsUDPSize,
+		DNSSECRequested: m.DNSSEC,
+	}
+}
+
 func (m Model) View() string {
 	if m.Width < 60 {
 		return "Terminal too small, please resize."
@@ -527,11 +2363,15 @@ func (m Model) View() string {
 
 	// Header
 	header := components.Header("LND", build.Version)
+	if summary := m.renderHealthSummary(m.Width - lipgloss.Width(header) - 2); summary != "" {
+		header = lipgloss.JoinHorizontal(lipgloss.Top, header, "  ", summary)
+	}
 
 	// Tabs
 	var tabViews []string
-	for i, t := range tabs {
+	for _, i := range m.Tabs {
 		style := ui.TabStyle
+		t := tabs[i]
 		if i == m.ActiveTab {
 			style = ui.ActiveTabStyle
 		}
@@ -556,166 +2396,1696 @@ func (m Model) View() string {
 		content = m.renderTunnels()
 	case TabAbout:
 		content = m.renderAbout()
+	case TabSpeedTest:
+		content = m.renderSpeedTest()
+	}
+
+	if m.ShowErrorLog {
+		// Refresh with errors recorded since the log was opened, keeping the scroll position
+		vp := m.Viewport
+		vp.SetContent(m.renderErrorLog())
+		content = fmt.Sprintf("Error Log (last %d, Esc to close, up/down to scroll):\n\n", maxErrorLog) + vp.View()
 	}
 
 	// Footer
-	footer := components.Footer("Press 'q' to quit, 'tab' to switch views")
+	help := "'q' quit, 'tab' switch views, 'ctrl+l' error log, 'ctrl+s' save config, 'ctrl+b' bug report bundle, 'ctrl+y' export JSON, 'ctrl+x' privacy"
+	if m.PrivacyMode {
+		help += " (on)"
+	}
+	if b := m.connCollector.Budget; b.Enabled() {
+		help += " | " + budgetStatus(b)
+	}
+	if ps := m.powerSaveStatus(); ps != "" {
+		help += " | " + ps
+	}
+	if m.Baseline != nil {
+		help += fmt.Sprintf(" | baseline '%s': %d deviations", m.Baseline.Name, len(m.baselineDevs))
+	}
+	footer := components.Footer(help)
+
+	status := m.statusLine()
+	if m.PrivacyMode {
+		content = maskSensitive(content, m.hostnameMask)
+		status = maskSensitive(status, m.hostnameMask)
+	}
 
 	return lipgloss.JoinVertical(lipgloss.Left,
 		header,
 		tabsRow,
-		ui.BoxStyle.Width(m.Width-2).Height(m.Height-5).Render(content),
+		ui.BoxStyle.Width(m.Width-2).Height(m.Height-6).Render(content),
+		status,
 		footer,
 	)
 }
 
-// Render Helpers
-func (m Model) renderInterfaces() string {
-	if m.LoadingSystem {
-		return "Loading System Info..."
+var macAddress = regexp.MustCompile(`(?i)\b([0-9a-f]{2})([:-])([0-9a-f]{2})(?:[:-][0-9a-f]{2}){4}\b`)
+
+// maskSensitive hides what identifies a user on a shared screen: the OUI
+// half of MACs survives (aa:bb:**:**:**:**), public IPs keep their first
+// half (8.8.x.x) and the hostname is replaced. Private addresses stay, they
+// are what local diagnostics are about. The text is rendered, so only the
+// runs between ANSI escape sequences are masked: the "m" ending a color
+// code would otherwise defeat the word boundaries around a styled value.
+func maskSensitive(text string, hostname *regexp.Regexp) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range ansiSequence.FindAllStringIndex(text, -1) {
+		b.WriteString(maskPlain(text[last:loc[0]], hostname))
+		b.WriteString(text[loc[0]:loc[1]])
+		last = loc[1]
 	}
-	info := m.HostInfo
+	b.WriteString(maskPlain(text[last:], hostname))
+	return b.String()
+}
 
-	s := "Network Interfaces:\n"
-	for _, iface := range info.Interfaces {
-		s += fmt.Sprintf("  %s: %s (MTU: %d)\n", iface.Name, iface.IP, iface.MTU)
-		if iface.Driver != "" {
-			s += fmt.Sprintf("    Driver: %s\n", iface.Driver)
+// ansiSequence matches a CSI escape sequence such as a color code
+var ansiSequence = regexp.MustCompile(`\x1b\[[0-?]*[ -/]*[@-~]`)
+
+// maskPlain masks text free of escape sequences, see maskSensitive
+func maskPlain(text string, hostname *regexp.Regexp) string {
+	text = macAddress.ReplaceAllString(text, "$1$2$3$2**$2**$2**$2**")
+	text = report.ReplacePublicIPs(text, func(addr netip.Addr) string {
+		if addr.Is4() {
+			b := addr.As4()
+			return fmt.Sprintf("%d.%d.x.x", b[0], b[1])
 		}
+		b := addr.As16()
+		return fmt.Sprintf("%x:%x:x:x:x:x:x:x", uint16(b[0])<<8|uint16(b[1]), uint16(b[2])<<8|uint16(b[3]))
+	})
+	if hostname != nil {
+		text = maskHostname(text, hostname)
 	}
-	return s
+	return text
 }
 
-func (m Model) renderConnectivity() string {
-	if m.LoadingConn {
-		return "Probing Connectivity..."
+// updateHostnameMask compiles the pattern privacy mode hides the hostname
+// with, so View does not compile it every frame. Hostnames are matched
+// case-insensitively.
+func (m *Model) updateHostnameMask() {
+	m.hostnameMask = nil
+	if m.PrivacyMode && m.HostInfo.Hostname != "" {
+		m.hostnameMask = regexp.MustCompile(`(?i)` + regexp.QuoteMeta(m.HostInfo.Hostname))
 	}
-	s := "Ping Targets:\n"
-	for target, res := range m.Connectivity.Targets {
-		status := "OK"
-		style := ui.SubtitleStyle
-		if res.PacketLoss > 0 || res.Error != nil {
-			status = "FAIL"
-			style = ui.ErrorStyle
-		}
+}
 
-		rtt := fmt.Sprintf("%.2fms", float64(res.AvgRtt.Microseconds())/1000.0)
-		if res.Error != nil {
-			rtt = "N/A"
+// maskHostname replaces the hostname where it stands alone. Unlike \b the
+// boundary excludes hyphens, so "web1" is not masked inside "web1-backup".
+func maskHostname(text string, hostname *regexp.Regexp) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range hostname.FindAllStringIndex(text, -1) {
+		if isHostnameChar(text, loc[0]-1) || isHostnameChar(text, loc[1]) {
+			continue
 		}
+		b.WriteString(text[last:loc[0]])
+		b.WriteString("<hostname>")
+		last = loc[1]
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
 
-		s += fmt.Sprintf("  %s: %s (Loss: %.0f%%, RTT: %s)\n",
-			target, style.Render(status), res.PacketLoss, rtt)
+// isHostnameChar reports whether text[i] can be part of a hostname label
+func isHostnameChar(text string, i int) bool {
+	if i < 0 || i >= len(text) {
+		return false
 	}
+	c := text[i]
+	return c == '-' || c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
 
-	s += "\nDNS Performance:\n"
-	dns := m.Connectivity.DNS
-	s += fmt.Sprintf("  Local Resolver: %s\n", dns.LocalResolverTime)
-	s += fmt.Sprintf("  Public (1.1.1.1): %s\n", dns.PublicResolverTime)
+// healthLevel grades one item of the header health summary
+type healthLevel = ui.Level
 
-	s += "\nNAT Status:\n"
-	if m.LoadingNat {
-		s += "  Probing NAT Type...\n"
-	} else {
+const (
+	healthUnknown = ui.LevelUnknown
+	healthOK      = ui.LevelOK
+	healthWarn    = ui.LevelWarn
+	healthFail    = ui.LevelFail
+)
+
+type healthItem struct {
+	Label string
+	Value string
+	Level healthLevel
+}
+
+// highRetransRate is the TCP retransmission percentage flagged as degraded
+const highRetransRate = 1.0
+
+// healthSummary derives the at-a-glance status from the latest collector results
+func (m Model) healthSummary() []healthItem {
+	var items []healthItem
+
+	// Reachability of connectivity targets
+	reach := healthItem{Label: "NET", Value: "...", Level: healthUnknown}
+	if !m.LoadingConn && len(m.Connectivity.Targets) > 0 {
+		down := 0
+		for _, res := range m.Connectivity.Targets {
+			if res.Error != nil || res.PacketLoss >= 100 {
+				down++
+			}
+		}
+		switch {
+		case down == 0:
+			reach.Value, reach.Level = "OK", healthOK
+		case down == len(m.Connectivity.Targets):
+			reach.Value, reach.Level = "DOWN", healthFail
+		default:
+			reach.Value, reach.Level = fmt.Sprintf("%d/%d DOWN", down, len(m.Connectivity.Targets)), healthWarn
+		}
+	}
+	items = append(items, reach)
+
+	dns := healthItem{Label: "DNS", Value: "...", Level: healthUnknown}
+	if !m.LoadingConn {
+		switch {
+		case m.Connectivity.DNS.Error != nil:
+			dns.Value, dns.Level = "FAIL", healthFail
+		case m.Connectivity.DNS.LocalResolverTime > collector.SlowDNSThreshold:
+			dns.Value, dns.Level = "SLOW", healthWarn
+		default:
+			dns.Value, dns.Level = "OK", healthOK
+		}
+	}
+	items = append(items, dns)
+
+	nat := healthItem{Label: "NAT", Value: "...", Level: healthUnknown}
+	if !m.LoadingNat {
 		for _, info := range m.NatInfo {
-			s += fmt.Sprintf("  Target: %s\n", info.Target)
 			if info.Error != nil {
-				s += fmt.Sprintf("    Error: %v\n", info.Error)
-			} else {
-				s += fmt.Sprintf("    Type: %s\n", info.NatType)
-				s += fmt.Sprintf("    Public IP: %s\n", info.PublicIP)
-				s += fmt.Sprintf("    Local IP: %s\n", info.LocalIP)
+				continue
 			}
-			s += "\n"
+			nat.Value, nat.Level = string(info.NatType), healthOK
+			switch info.NatType {
+			case collector.NatSymmetric, collector.NatUdpBlocked:
+				nat.Level = healthWarn
+			case collector.NatUnknown:
+				nat.Level = healthUnknown
+			}
+			break
 		}
 	}
+	if m.natCollector != nil {
+		items = append(items, nat)
+	}
 
-	return s
+	retrans := healthItem{Label: "RETRANS", Value: "...", Level: healthUnknown}
+	if m.kernelReady && m.Kernel.Error == nil {
+		retrans.Value = fmt.Sprintf("%.1f%%", m.Kernel.TCPRetransRate)
+		retrans.Level = healthOK
+		if m.Kernel.TCPRetransRate > highRetransRate {
+			retrans.Level = healthWarn
+		}
+	}
+	if m.cfg.CollectorEnabled(config.CollectorKernel) {
+		items = append(items, retrans)
+	}
+
+	return items
 }
 
-func (m Model) renderDashboard() string {
-	s := ""
+// renderHealthSummary renders the summary items that fit in width, dropping the rest
+func (m Model) renderHealthSummary(width int) string {
+	var parts []string
+	used := 0
+	for _, item := range m.healthSummary() {
+		text := ui.Status(item.Level, item.Label+":"+item.Value)
+		extra := lipgloss.Width(text)
+		if len(parts) > 0 {
+			extra++ // Separator
+		}
+		if used+extra > width {
+			break
+		}
+		used += extra
+		parts = append(parts, text)
+	}
+	return strings.Join(parts, " ")
+}
 
-	// System Info
-	if m.LoadingSystem {
-		s += "Loading System Info...\n\n"
-	} else {
-		info := m.HostInfo
-		s += "System Information:\n"
-		s += fmt.Sprintf("  Hostname:         %s\n", ui.TitleStyle.Render(info.Hostname))
-		s += fmt.Sprintf("  Operating System: %s %s (%s)\n", info.Platform, info.PlatformVersion, info.OS)
-		s += fmt.Sprintf("  Kernel:           %s\n", info.KernelVersion)
-		s += fmt.Sprintf("  Architecture:     %s\n", info.Arch)
-		if info.VirtualizationSystem != "" {
-			s += fmt.Sprintf("  Virtualization:   %s (%s)\n", info.VirtualizationSystem, info.VirtualizationRole)
+// recordPingHistory appends one RTT sample per target to its bounded history
+func (m *Model) recordPingHistory(results map[string]collector.PingResult) {
+	if m.PingHistory == nil {
+		m.PingHistory = make(map[string][]float64)
+	}
+	for target, res := range results {
+		sample := math.NaN()
+		if res.Error == nil && res.PacketLoss < 100 {
+			sample = float64(res.AvgRtt.Microseconds()) / 1000.0
 		}
-		s += fmt.Sprintf("  Uptime:           %s\n", info.Uptime)
-		s += fmt.Sprintf("  Load Average:     %.2f, %.2f, %.2f\n\n", info.Load1, info.Load5, info.Load15)
+		m.PingHistory[target] = appendBounded(m.PingHistory[target], sample, maxPingHistory)
 	}
+}
+
+// recordTrafficHistory appends the RX and TX rates of every interface to
+// their bounded histories and drops interfaces that have disappeared
+func (m *Model) recordTrafficHistory(stats collector.TrafficStats) {
+	if m.RxHistory == nil {
+		m.RxHistory = make(map[string][]float64)
+		m.TxHistory = make(map[string][]float64)
+	}
+	for name, t := range stats.Interfaces {
+		m.RxHistory[name] = appendBounded(m.RxHistory[name], t.RxRate, maxTrafficHistory)
+		m.TxHistory[name] = appendBounded(m.TxHistory[name], t.TxRate, maxTrafficHistory)
+	}
+	// A partial collection may have missed interfaces that still exist
+	if stats.Partial {
+		return
+	}
+	for name := range m.RxHistory {
+		if _, ok := stats.Interfaces[name]; !ok {
+			delete(m.RxHistory, name)
+			delete(m.TxHistory, name)
+		}
+	}
+}
+
+// appendBounded appends v, keeping at most limit of the newest values
+func appendBounded(history []float64, v float64, limit int) []float64 {
+	history = append(history, v)
+	if len(history) > limit {
+		history = history[len(history)-limit:]
+	}
+	return history
+}
+
+// toggleActiveInterface binds diagnostics to the interface under the cursor,
+// or back to the default route if it is already active
+func (m *Model) toggleActiveInterface() {
+	if m.SelectedInterface >= len(m.HostInfo.Interfaces) {
+		return
+	}
+	src := m.connCollector.Source
+	name := m.HostInfo.Interfaces[m.SelectedInterface].Name
+	if src.Name() == name {
+		name = ""
+	}
+	if err := src.Set(name); err != nil {
+		m.recordError("Interface "+name, err)
+		m.Notice = fmt.Sprintf("Cannot use %s: %v", name, err)
+	} else if name == "" {
+		m.Notice = "Diagnostics use the default route again"
+	} else {
+		m.Notice = "Pings and DNS queries now leave through " + name
+	}
+	m.NoticeTime = time.Now()
+}
+
+// recordError appends a non-fatal error to the bounded error log.
+// Repeats of the latest error are collapsed into a counter.
+func (m *Model) recordError(source string, err error) {
+	if err == nil {
+		return
+	}
+	now := time.Now()
+	if n := len(m.ErrorLog); n > 0 {
+		last := &m.ErrorLog[n-1]
+		if last.Source == source && last.Err.Error() == err.Error() {
+			last.Time = now
+			last.Count++
+			return
+		}
+	}
+	m.ErrorLog = append(m.ErrorLog, ErrorEntry{Time: now, Source: source, Err: err, Count: 1})
+	if len(m.ErrorLog) > maxErrorLog {
+		m.ErrorLog = m.ErrorLog[len(m.ErrorLog)-maxErrorLog:]
+	}
+}
+
+// reloadChanges reports whether cfg differs from the running config in
+// what applyConfig swaps in. DNS servers are compared with the last loaded
+// file, servers edited in the UI are not a change of the file.
+func (m Model) reloadChanges(cfg *config.Config) bool {
+	cur := m.currentConfig()
+	return !slices.Equal(m.cfg.DNSServers, cfg.DNSServers) ||
+		!slices.Equal(cur.StunServers, cfg.StunServers) || cur.STUN != cfg.STUN ||
+		!slices.EqualFunc(cur.Tunnels, cfg.Tunnels, func(a, b config.TunnelConfig) bool { return reflect.DeepEqual(a, b) }) ||
+		cur.CollectorEnabled(config.CollectorSTUN) != cfg.CollectorEnabled(config.CollectorSTUN) ||
+		cur.CollectorEnabled(config.CollectorTunnels) != cfg.CollectorEnabled(config.CollectorTunnels)
+}
+
+// applyConfig swaps in a reloaded config: the configured DNS servers, if
+// the file changed them, the STUN collector and the tunnel collector are
+// rebuilt from it and rerun. The other collectors keep their startup settings.
+func (m *Model) applyConfig(cfg *config.Config) tea.Cmd {
+	if !slices.Equal(m.cfg.DNSServers, cfg.DNSServers) {
+		m.setConfiguredDNSServers(m.cfg.DNSServers, cfg.DNSServers)
+	}
+
+	next := *m.cfg
+	next.DNSServers = cfg.DNSServers
+	next.StunServers, next.STUN = cfg.StunServers, cfg.STUN
+	next.Tunnels = cfg.Tunnels
+	// Only the switches of the rebuilt collectors: the others were not
+	// created at startup, showing their tabs would run a nil collector
+	next.Collectors = maps.Clone(m.cfg.Collectors)
+	for _, name := range []string{config.CollectorSTUN, config.CollectorTunnels} {
+		if on, ok := cfg.Collectors[name]; ok {
+			if next.Collectors == nil {
+				next.Collectors = map[string]bool{}
+			}
+			next.Collectors[name] = on
+		} else {
+			delete(next.Collectors, name)
+		}
+	}
+	m.cfg = &next
+
+	var cmds []tea.Cmd
+	m.configGen++ // Results still running on the replaced collectors are dropped
+	m.natCollector, m.NatInfo, m.LoadingNat = nil, nil, false
+	if cfg.CollectorEnabled(config.CollectorSTUN) {
+		m.natCollector = NewNatCollector(cfg)
+		m.natCollector.Budget = m.connCollector.Budget
+		m.LoadingNat = true
+		cmds = append(cmds, m.configScoped(fetchNatInfo(m.natCollector)))
+	}
+	m.tunnelCollector, m.TunnelResults, m.LoadingTunnels = nil, nil, false
+	if cfg.CollectorEnabled(config.CollectorTunnels) {
+		m.tunnelCollector = collector.NewTunnelCollector(cfg.Tunnels)
+		m.tunnelCollector.Budget = m.connCollector.Budget // Shared with the collectors that stay
+		m.LoadingTunnels = true
+		cmds = append(cmds, m.configScoped(fetchTunnels(m.tunnelCollector)))
+	}
+
+	m.Tabs = visibleTabs(m.cfg)
+	if !slices.Contains(m.Tabs, m.ActiveTab) {
+		m.ActiveTab = TabDashboard
+	}
+	return tea.Batch(cmds...)
+}
+
+// setConfiguredDNSServers replaces the servers loaded from the file, was,
+// with the ones it lists now, keeping the built-in ones, the Custom slot
+// and, if it is still listed, the selection. Servers added or removed in
+// the UI and not saved yet stay added or removed.
+func (m *Model) setConfiguredDNSServers(was, servers []config.DNSServerConfig) {
+	selected := m.DNSServers[m.SelectedDNSServer].Name
+	edited := m.currentConfig().DNSServers
+	var configured []collector.DNSServer
+	for _, s := range servers {
+		if slices.Contains(was, s) && !slices.Contains(edited, s) {
+			continue // Removed in the UI
+		}
+		configured = append(configured, DNSServerFromConfig(s))
+	}
+	for _, s := range edited {
+		if !slices.Contains(was, s) && !slices.Contains(servers, s) {
+			configured = append(configured, DNSServerFromConfig(s)) // Added in the UI
+		}
+	}
+	custom := m.DNSServers[m.customDNSServerIndex():]
+	m.DNSServers = slices.Concat(m.DNSServers[:m.builtinDNSServers], configured, custom)
+
+	if i := slices.IndexFunc(m.DNSServers, func(s collector.DNSServer) bool { return s.Name == selected }); i >= 0 {
+		m.SelectedDNSServer = i
+	} else {
+		m.selectDNSServer(0)
+	}
+	// An open edit form points at an index that may have moved
+	if m.DNSForm != nil && m.DNSForm.EditIndex >= 0 {
+		m.DNSForm = nil
+	}
+}
+
+// currentConfig returns a copy of the startup config updated with the
+// state edited in the UI
+func (m Model) currentConfig() *config.Config {
+	cfg := *m.cfg
+	cfg.DNSServers = nil
+	for _, s := range m.DNSServers[m.builtinDNSServers:m.customDNSServerIndex()] {
+		cfg.DNSServers = append(cfg.DNSServers, config.DNSServerConfig{
+			Name:       s.Name,
+			Address:    s.Address,
+			Proto:      string(s.Proto),
+			SourcePort: s.SourcePort,
+			H3Fallback: s.H3Fallback,
+			Family:     string(s.Family),
+		})
+	}
+	return &cfg
+}
+
+// snapshot gathers the current collector results for the diagnostic bundle
+func (m Model) snapshot() report.Snapshot {
+	s := report.NewSnapshot()
+	s.Config = m.currentConfig()
+	s.Host = m.HostInfo
+	s.Connectivity = m.Connectivity
+	s.Traffic = m.Traffic
+	s.Softirqs = m.Softirqs
+	s.Kernel = m.Kernel
+	s.ListenSockets = m.ListenSockets
+	s.NAT = m.NatInfo
+	s.PublicIP = m.PublicIP
+	s.DHCP = m.DHCP
+	s.Matrix = m.Matrix
+	s.Regions = m.Regions
+	s.DNSBreakdown = m.DNSBreakdown
+	s.Bufferbloat = m.Bufferbloat
+	s.SpeedTest = m.SpeedTest
+	s.MSS = m.MSS
+	s.Egress = m.Egress
+	s.TFO = m.TFO
+	s.ICMPQueries = m.ICMPQueries
+	s.PathMTU = m.PathMTU
+	s.Gateway = m.Gateway
+	s.Tunnels = m.TunnelResults
+	s.DNSLookup = m.DNSResult
+	s.URLDiagnosis = m.URLDiagnosis
+	s.ZoneTransfer = m.ZoneTransfer
+	s.DNSTrace = m.DNSTrace
+	s.DNSCache = m.DNSCache
+	s.PortScan = m.PortScan
+	s.DNSBatch = m.DNSBatch
+	s.DNSCompare = m.DNSCompare
+	s.ResolveConnect = m.ResolveConnect
+	for _, e := range m.ErrorLog {
+		s.Errors = append(s.Errors, fmt.Sprintf("%s %s: %v", e.Time.Format(time.RFC3339), e.Source, e.Err))
+	}
+	return s
+}
+
+// statusLine shows the most recent collector error, or a newer notice
+func (m Model) statusLine() string {
+	if m.Notice != "" && (len(m.ErrorLog) == 0 || m.NoticeTime.After(m.ErrorLog[len(m.ErrorLog)-1].Time)) {
+		return ui.SubtitleStyle.Render(truncate(m.Notice, m.Width-1))
+	}
+	if len(m.ErrorLog) == 0 {
+		return ui.SubtleStyle.Render("No errors")
+	}
+	last := m.ErrorLog[len(m.ErrorLog)-1]
+	line := fmt.Sprintf("[%s] %s: %v", last.Time.Format("15:04:05"), last.Source, last.Err)
+	return ui.Status(ui.LevelFail, truncate(line, m.Width-1-ui.StatusPrefixWidth()))
+}
+
+func (m Model) renderErrorLog() string {
+	if len(m.ErrorLog) == 0 {
+		return "  No errors recorded\n"
+	}
+	s := ""
+	for _, e := range m.ErrorLog {
+		s += fmt.Sprintf("  [%s] %s: %v", e.Time.Format("15:04:05"), ui.SubtitleStyle.Render(e.Source), e.Err)
+		if e.Count > 1 {
+			s += ui.SubtleStyle.Render(fmt.Sprintf(" (x%d)", e.Count))
+		}
+		s += "\n"
+	}
+	return s
+}
+
+// Render Helpers
+func (m Model) renderInterfaces() string {
+	if m.LoadingSystem {
+		return "Loading System Info..."
+	}
+	info := m.HostInfo
+
+	active := m.connCollector.Source.Name()
+	s := ""
+	if info.Partial {
+		s += partialNotice(info.Error)
+	}
+	s += "Network Interfaces:\n"
+	for i, iface := range info.Interfaces {
+		cursor := "  "
+		if i == m.SelectedInterface {
+			cursor = "> "
+		}
+		line := fmt.Sprintf("%s%s: %s (MTU: %d)", cursor, iface.Name, iface.IP, iface.MTU)
+		switch {
+		case iface.Down():
+			line = ui.SubtleStyle.Render(line + " [" + iface.OperState + "]")
+		case iface.Name == active:
+			line = ui.SubtitleStyle.Render(line + " [active]")
+		}
+		s += line + "\n"
+		// One change is a cable plugged in or a port reset, flapping takes more
+		switch {
+		case iface.CarrierFlaps >= minCarrierFlaps:
+			s += "    " + ui.Status(ui.LevelWarn, fmt.Sprintf("Carrier changes since the last check: %d (%d in total), the link is flapping",
+				iface.CarrierFlaps, iface.CarrierChanges)) + "\n"
+		case iface.CarrierFlaps > 0:
+			s += ui.SubtleStyle.Render(fmt.Sprintf("    Carrier changed since the last check (%d in total)", iface.CarrierChanges)) + "\n"
+		}
+		if iface.MAC != "" {
+			s += fmt.Sprintf("    MAC: %s\n", iface.MAC)
+		}
+		s += renderLinkSpeed(iface)
+		if iface.Driver != "" {
+			driver := iface.Driver
+			if iface.DriverVersion != "" {
+				driver += " " + iface.DriverVersion
+			}
+			if iface.FirmwareVersion != "" {
+				driver += ", firmware " + iface.FirmwareVersion
+			}
+			s += fmt.Sprintf("    Driver: %s\n", driver)
+		}
+		if len(iface.Offload) > 0 {
+			s += "    Offload: " + renderOffload(iface.Offload) + "\n"
+		}
+		s += renderQueues(iface)
+		for _, addr := range iface.IPv6 {
+			line := fmt.Sprintf("    IPv6: %s/%d [%s] preferred: %s, valid: %s",
+				addr.Address, addr.PrefixLen, addr.Scope,
+				formatLifetime(addr.PreferredLft), formatLifetime(addr.ValidLft))
+			switch {
+			case addr.Temporary:
+				line = ui.SubtleStyle.Render(line + " (temporary)") // Privacy working as intended, not a fault
+			case addr.Deprecated:
+				line = ui.SubtleStyle.Render(line + " (deprecated)")
+			}
+			s += line + "\n"
+		}
+		if p := iface.IPv6Privacy; p != nil && len(iface.IPv6) > 0 {
+			s += fmt.Sprintf("    IPv6 privacy: %s\n", p.Mode)
+			switch p.SourceKind {
+			case collector.IPv6SourceTemporary:
+				s += fmt.Sprintf("    Outgoing IPv6: %s (temporary)\n", p.Source)
+			case collector.IPv6SourceStable:
+				line := fmt.Sprintf("    Outgoing IPv6: %s (stable, shows up in reverse DNS and reputation lists)", p.Source)
+				if p.Mode != collector.IPv6PrivacyPreferred {
+					line = indentedStatus(ui.LevelWarn, line)
+				}
+				s += line + "\n"
+			}
+		}
+	}
+	s += "\n" + ui.SubtleStyle.Render("Press 'up'/'down' to select and 'enter' to send pings and DNS queries through an interface") + "\n"
+	return s
+}
+
+// renderLinkSpeed shows the negotiated speed and duplex, flagging half
+// duplex and a speed below what the NIC supports
+func renderLinkSpeed(iface collector.InterfaceInfo) string {
+	if iface.SpeedMbps == 0 && iface.Duplex == "" {
+		return ""
+	}
+	var parts []string
+	if iface.SpeedMbps > 0 {
+		parts = append(parts, formatLinkSpeed(iface.SpeedMbps))
+	}
+	if iface.Duplex != "" {
+		parts = append(parts, iface.Duplex+" duplex")
+	}
+	line := "    Link: " + strings.Join(parts, ", ")
+	var problems []string
+	if iface.HalfDuplex() {
+		problems = append(problems, "half duplex, check for a duplex mismatch")
+	}
+	if iface.SpeedBelowMax() {
+		problems = append(problems, "both ends support "+formatLinkSpeed(iface.PeerMaxSpeedMbps)+", check the cable and switch port")
+	}
+	if len(problems) > 0 {
+		line = indentedStatus(ui.LevelWarn, line+" ("+strings.Join(problems, "; ")+")")
+	}
+	if !iface.SpeedBelowMax() && iface.SpeedMbps > 0 && iface.SpeedMbps < iface.MaxSpeedMbps {
+		// The partner may offer no more, so this is only informational
+		line += ui.SubtleStyle.Render(" (the NIC supports " + formatLinkSpeed(iface.MaxSpeedMbps) + ")")
+	}
+	return line + "\n"
+}
+
+// formatLinkSpeed shows a speed in Mbps the way link modes are named,
+// e.g. 100M, 1G, 2.5G
+func formatLinkSpeed(mbps int) string {
+	if mbps >= 1000 {
+		return strconv.FormatFloat(float64(mbps)/1000, 'f', -1, 64) + "G"
+	}
+	return strconv.Itoa(mbps) + "M"
+}
+
+// renderOffload lists the offloads in a fixed order, e.g. "TSO on, GSO on, GRO on, LRO off"
+func renderOffload(offload map[string]bool) string {
+	var parts []string
+	for _, name := range []string{"TSO", "GSO", "GRO", "LRO"} {
+		if on, ok := offload[name]; ok {
+			state := "off"
+			if on {
+				state = "on"
+			}
+			parts = append(parts, name+" "+state)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// renderQueues shows ring sizes and per-queue packets, flagging rings below
+// their maximum and traffic that lands on a single queue
+func renderQueues(iface collector.InterfaceInfo) string {
+	s := ""
+	if r := iface.Ring; r != nil {
+		line := fmt.Sprintf("    Rings: RX %d/%d  TX %d/%d", r.RxPending, r.RxMax, r.TxPending, r.TxMax)
+		if r.RxUndersized() {
+			line = indentedStatus(ui.LevelWarn, line+" (RX ring below maximum)")
+		}
+		s += line + "\n"
+	}
+
+	if len(iface.Queues) > 0 {
+		var parts []string
+		var total, busiest uint64
+		for _, q := range iface.Queues {
+			if !q.HasPackets {
+				parts = append(parts, q.Name)
+				continue
+			}
+			parts = append(parts, fmt.Sprintf("%s: %d", q.Name, q.Packets))
+			if strings.HasPrefix(q.Name, "rx-") {
+				total += q.Packets
+				busiest = max(busiest, q.Packets)
+			}
+		}
+		s += fmt.Sprintf("    Queues: %s\n", strings.Join(parts, "  "))
+		if rxQueues := countRxQueues(iface.Queues); rxQueues > 1 && total > 0 && busiest*10 >= total*9 {
+			s += "    " + ui.Status(ui.LevelWarn, "Most RX traffic is on a single queue (check RSS)") + "\n"
+		}
+	}
+
+	if iface.QueueError != nil {
+		s += ui.SubtleStyle.Render(fmt.Sprintf("    Queue stats unavailable: %v", iface.QueueError)) + "\n"
+	}
+	return s
+}
+
+func countRxQueues(queues []collector.QueueStats) int {
+	n := 0
+	for _, q := range queues {
+		if strings.HasPrefix(q.Name, "rx-") {
+			n++
+		}
+	}
+	return n
+}
+
+// renderEgress shows which interface and source address each target is
+// reached from, flagging routes picked by a policy routing table
+func (m Model) renderEgress(routes []collector.EgressRoute) string {
+	s := ""
+	for _, r := range routes {
+		name := truncate(r.Target, 24)
+		if r.Error != nil {
+			s += "  " + ui.Status(ui.LevelFail, fmt.Sprintf("%-24s %v", name, r.Error)) + "\n"
+			continue
+		}
+		line := fmt.Sprintf("  %-24s %s from %s via %s", name, r.Address, orDash(r.Source), orDash(r.Interface))
+		if r.Gateway != "" {
+			line += " gw " + r.Gateway
+		}
+		if r.PolicyRouted() {
+			line += " " + ui.Status(ui.LevelWarn, fmt.Sprintf("(table %d)", r.Table))
+		}
+		s += line + "\n"
+	}
+	return s
+}
+
+// renderDNSEgress shows how traffic to the DNS tab host leaves the machine
+func (m Model) renderDNSEgress() string {
+	if m.LoadingDNSEgress {
+		return "\nEgress Route: asking the kernel...\n"
+	}
+	if len(m.DNSEgress) == 0 {
+		return ""
+	}
+	return "\nEgress Route:\n" + m.renderEgress(m.DNSEgress)
+}
+
+func (m Model) renderPathMTU(results []collector.PathMTUResult) string {
+	s := ""
+	for _, r := range results {
+		name := truncate(r.Target, 24)
+		switch {
+		case r.Error != nil:
+			s += "  " + ui.Status(ui.LevelFail, fmt.Sprintf("%-24s %v", name, r.Error)) + "\n"
+		case r.Fallback:
+			s += fmt.Sprintf("  %-24s %s\n", name, ui.SubtleStyle.Render(fmt.Sprintf("interface MTU %d (no raw socket, not probed)", r.MTU)))
+		case r.Reduced():
+			s += "  " + ui.Status(ui.LevelWarn, fmt.Sprintf("%-24s path MTU %d, interface %d: larger packets are dropped", name, r.MTU, r.InterfaceMTU)) + "\n"
+		default:
+			s += fmt.Sprintf("  %-24s path MTU %d\n", name, r.MTU)
+		}
+	}
+	s += ui.SubtleStyle.Render("  Press 'p' to probe again") + "\n"
+	return s
+}
+
+func (m Model) renderMSS(results []collector.MSSResult) string {
+	s := ""
+	for _, r := range results {
+		name := truncate(r.Target, 24)
+		switch {
+		case r.Error != nil:
+			s += "  " + ui.Status(ui.LevelFail, fmt.Sprintf("%-24s %v", name, r.Error)) + "\n"
+		case r.Lowered:
+			s += "  " + ui.Status(ui.LevelWarn, fmt.Sprintf("%-24s MSS %d (advertised %d), path MTU %d: lower MSS on path", name, r.Effective, r.Advertised, r.PathMTU)) + "\n"
+		default:
+			s += fmt.Sprintf("  %-24s MSS %d, path MTU %d\n", name, r.Effective, r.PathMTU)
+		}
+	}
+	s += ui.SubtleStyle.Render("  Press 'u' to measure again") + "\n"
+	return s
+}
+
+func (m Model) renderTFO(report collector.TFOReport) string {
+	if report.KernelError != nil {
+		return "  " + ui.Status(ui.LevelFail, fmt.Sprintf("Cannot read net.ipv4.tcp_fastopen: %v", report.KernelError)) + "\n"
+	}
+	k := report.Kernel
+	enabled := func(on bool) string {
+		if on {
+			return "enabled"
+		}
+		return "disabled"
+	}
+	line := fmt.Sprintf("  Kernel: client %s, server %s (net.ipv4.tcp_fastopen = %d)", enabled(k.Client), enabled(k.Server), k.Value)
+	if !k.Client {
+		line = indentedStatus(ui.LevelWarn, line)
+	}
+	s := line + "\n"
+	if !k.Client {
+		return s
+	}
+	for _, r := range report.Results {
+		name := truncate(r.Target, 24)
+		switch {
+		case r.Error != nil:
+			s += "  " + ui.Status(ui.LevelFail, fmt.Sprintf("%-24s %v", name, r.Error)) + "\n"
+		case r.Accepted:
+			s += fmt.Sprintf("  %-24s %s\n", name, ui.SubtitleStyle.Render("cookie accepted, data sent in the SYN"))
+		default:
+			s += "  " + ui.Status(ui.LevelWarn, fmt.Sprintf("%-24s not accepted (server without TFO or option stripped on the path)", name)) + "\n"
+		}
+	}
+	s += ui.SubtleStyle.Render("  Press 'f' to probe again") + "\n"
+	return s
+}
+
+// renderGateway shows the default gateway verdict, with the ARP state and
+// second hop behind it
+func (m Model) renderGateway(h collector.GatewayHealth) string {
+	name := "none"
+	if h.Gateway != "" {
+		name = h.Gateway
+		if h.Interface != "" {
+			name += " via " + h.Interface
+		}
+	}
+	level := ui.LevelFail
+	switch h.Verdict {
+	case collector.GatewayHealthy:
+		level = ui.LevelOK
+	case collector.GatewayBeyondISP:
+		level = ui.LevelWarn
+	}
+	s := fmt.Sprintf("Default gateway: %s: %s\n", name, ui.Status(level, string(h.Verdict)))
+	s += ui.SubtleStyle.Render("  "+h.Detail) + "\n"
+	if h.NeighborState != "" {
+		s += fmt.Sprintf("  ARP: %s\n", h.NeighborState)
+	}
+	if h.SecondHop != "" {
+		s += fmt.Sprintf("  Second hop: %s\n", h.SecondHop)
+	} else if h.SecondHopError != nil {
+		s += "  " + ui.Status(ui.LevelWarn, fmt.Sprintf("Second hop: %v", h.SecondHopError)) + "\n"
+	}
+	return s
+}
+
+func (m Model) renderICMPQueries(results []collector.ICMPQueryResult) string {
+	s := ""
+	for _, r := range results {
+		name := truncate(r.Target, 24)
+		var parts []string
+		switch ts := r.Timestamp; {
+		case r.TimestampError != nil:
+			parts = append(parts, fmt.Sprintf("timestamp: %v", r.TimestampError))
+		case ts.NonStandard:
+			parts = append(parts, "timestamp: non-standard clock")
+		default:
+			parts = append(parts, fmt.Sprintf("clock offset %+dms (rtt %dms)", ts.ClockOffset.Milliseconds(), ts.RTT.Milliseconds()))
+		}
+		if r.NetmaskError != nil {
+			parts = append(parts, fmt.Sprintf("mask: %v", r.NetmaskError))
+		} else {
+			parts = append(parts, "netmask "+net.IP(r.Netmask).String())
+		}
+		line := fmt.Sprintf("  %-24s %s", name, strings.Join(parts, ", "))
+		if r.TimestampError != nil && r.NetmaskError != nil {
+			line = ui.SubtleStyle.Render(line)
+		}
+		s += line + "\n"
+	}
+	s += ui.SubtleStyle.Render("  Press 't' to query again") + "\n"
+	return s
+}
+
+func (m Model) renderBufferbloat(res collector.BufferbloatResult) string {
+	if res.Error != nil && res.Grade == "" {
+		return "  " + ui.Status(ui.LevelFail, fmt.Sprintf("Error: %v", res.Error)) + "\n"
+	}
+	level := ui.LevelOK
+	switch res.Grade {
+	case "C", "D":
+		level = ui.LevelWarn
+	case "F":
+		level = ui.LevelFail
+	}
+	s := fmt.Sprintf("  Grade: %s  (+%dms under load)\n", ui.Status(level, res.Grade), res.Increase.Milliseconds())
+	s += fmt.Sprintf("  Idle RTT: %dms  Loaded RTT: %dms  (%s)\n", res.Baseline.Milliseconds(), res.Loaded.Milliseconds(), res.Target)
+	if res.Lost > 0 {
+		s += "  " + ui.Status(ui.LevelWarn, fmt.Sprintf("%d of %d pings lost under load", res.Lost, res.Samples)) + "\n"
+	}
+	s += ui.SubtleStyle.Render(fmt.Sprintf("  Transferred %s down, %s up", m.formatBytes(uint64(res.Downloaded)), m.formatBytes(uint64(res.Uploaded)))) + "\n"
+	if res.Error != nil {
+		s += "  " + ui.Status(ui.LevelFail, fmt.Sprintf("%v", res.Error)) + "\n"
+	}
+	return s
+}
+
+// renderPingHeatmap draws one row of latency cells per target, bounded by the window width
+func (m Model) renderPingHeatmap() string {
+	if len(m.PingHistory) == 0 {
+		return ""
+	}
+	targets := make([]string, 0, len(m.PingHistory))
+	nameWidth := 0
+	for target := range m.PingHistory {
+		targets = append(targets, target)
+		nameWidth = max(nameWidth, len(target))
+	}
+	sort.Strings(targets)
+
+	width := min(m.Width-nameWidth-10, maxPingHistory)
+	if width < 10 {
+		return ""
+	}
+	s := fmt.Sprintf("\nLatency Heatmap (one cell per %s, newest right):\n", m.refreshInterval(connectivityRefreshInterval))
+	for _, target := range targets {
+		s += fmt.Sprintf("  %-*s %s\n", nameWidth, target, components.Heatmap(m.PingHistory[target], width))
+	}
+	s += "  " + components.HeatmapLegend() + "\n"
+	return s
+}
+
+func formatLifetime(d time.Duration) string {
+	if d == collector.IPv6LifetimeForever {
+		return "forever"
+	}
+	return d.String()
+}
+
+func (m Model) renderConnectivity() string {
+	if m.LoadingConn {
+		return "Probing Connectivity..."
+	}
+	s := ""
+	if name := m.connCollector.Source.Name(); name != "" {
+		v4, v6 := m.connCollector.Source.Addrs()
+		var addrs []string
+		for _, ip := range []net.IP{v4, v6} {
+			if ip != nil {
+				addrs = append(addrs, ip.String())
+			}
+		}
+		s += ui.SubtitleStyle.Render(fmt.Sprintf("Source interface: %s (%s)", name, strings.Join(addrs, ", "))) + "\n\n"
+	}
+	if m.Connectivity.Partial {
+		s += partialNotice(m.Connectivity.Error) + "\n"
+	}
+	if m.ConnectivitySkipped {
+		s += ui.Status(ui.LevelWarn, "Probe budget exhausted, showing the last results until it refills") + "\n\n"
+	}
+	if m.Gateway != nil {
+		s += m.renderGateway(*m.Gateway) + "\n"
+	}
+	if opts := m.connCollector.PingOptions.String(); opts != "" {
+		s += fmt.Sprintf("Ping Targets (%s):\n", opts)
+	} else {
+		s += "Ping Targets:\n"
+	}
+	s += "  IPv4:\n" + renderPingResults(m.Connectivity.Targets)
+	if m.Connectivity.IPv6Targets == nil {
+		s += "  IPv6: " + ui.SubtleStyle.Render("no IPv6 (no default route)") + "\n"
+	} else {
+		s += fmt.Sprintf("  IPv6 (gateway %s):\n", m.Connectivity.IPv6Gateway) + renderPingResults(m.Connectivity.IPv6Targets)
+	}
+
+	s += m.renderPingHeatmap()
+
+	s += "\nConnectivity Matrix:\n"
+	if m.LoadingMatrix {
+		s += "  Probing targets over ICMP/TCP/UDP...\n"
+	} else if m.Matrix != nil {
+		s += m.renderMatrix(*m.Matrix)
+	} else {
+		s += ui.SubtleStyle.Render("  Press 'm' to probe every target over ICMP, TCP:80, TCP:443 and UDP:53") + "\n"
+	}
+
+	s += "\nRegion Latency:\n"
+	if m.LoadingRegions {
+		s += "  Measuring TCP connect time to regional endpoints...\n"
+	} else if len(m.Regions) > 0 {
+		s += m.renderRegions(m.Regions)
+	} else {
+		s += ui.SubtleStyle.Render("  Press 'r' to measure latency to well-known regions") + "\n"
+	}
+
+	s += "\nBufferbloat (latency under load):\n"
+	if m.LoadingBufferbloat {
+		s += fmt.Sprintf("  Saturating the link for up to %s while pinging %s...\n", m.bufferbloat.Duration, m.bufferbloat.Target)
+	} else if m.Bufferbloat != nil {
+		s += m.renderBufferbloat(*m.Bufferbloat)
+	} else {
+		s += ui.SubtleStyle.Render(fmt.Sprintf("  Press 'l' to test (loads the link for up to %s / %d MB)",
+			m.bufferbloat.Duration, m.bufferbloat.MaxBytes>>20)) + "\n"
+	}
+
+	s += "\nEgress Routes:\n"
+	if m.LoadingEgress {
+		s += "  Asking the kernel for the route to each target...\n"
+	} else if len(m.Egress) > 0 {
+		s += m.renderEgress(m.Egress)
+		s += ui.SubtleStyle.Render("  Press 'e' to look up again") + "\n"
+	} else {
+		s += ui.SubtleStyle.Render("  Press 'e' to show the source address and interface used for each target") + "\n"
+	}
+
+	s += "\nTCP MSS (path MTU without ICMP):\n"
+	if m.LoadingMSS {
+		s += "  Connecting to targets on port 443...\n"
+	} else if len(m.MSS) > 0 {
+		s += m.renderMSS(m.MSS)
+	} else {
+		s += ui.SubtleStyle.Render("  Press 'u' to check for a lower MSS on the path") + "\n"
+	}
+
+	s += "\nPath MTU (ICMP, DF set):\n"
+	if m.LoadingPathMTU {
+		s += "  Searching packet sizes to each target...\n"
+	} else if len(m.PathMTU) > 0 {
+		s += m.renderPathMTU(m.PathMTU)
+	} else {
+		s += ui.SubtleStyle.Render("  Press 'p' to find the largest unfragmented packet to each target (needs root)") + "\n"
+	}
+
+	s += "\nTCP Fast Open:\n"
+	if m.LoadingTFO {
+		s += "  Connecting to targets on port 80 twice...\n"
+	} else if m.TFO != nil {
+		s += m.renderTFO(*m.TFO)
+	} else {
+		s += ui.SubtleStyle.Render("  Press 'f' to check whether the path and servers accept TFO") + "\n"
+	}
+
+	s += "\nICMP Timestamp / Address Mask:\n"
+	if m.LoadingICMPQueries {
+		s += "  Querying targets...\n"
+	} else if len(m.ICMPQueries) > 0 {
+		s += m.renderICMPQueries(m.ICMPQueries)
+	} else {
+		s += ui.SubtleStyle.Render("  Press 't' to query clock offsets and netmasks (legacy ICMP, needs root)") + "\n"
+	}
+
+	s += "\nDNS Performance:\n"
+	dns := m.Connectivity.DNS
+	s += fmt.Sprintf("  Local (%s): %s%s\n", dns.LocalResolver, dns.LocalResolverTime, renderRcode(dns.LocalRcode))
+	public := dns.PublicResolver
+	if dns.PublicAutoSelected {
+		public += ", fastest"
+	}
+	s += fmt.Sprintf("  Public (%s): %s%s\n", public, dns.PublicResolverTime, renderRcode(dns.PublicRcode))
+	if len(dns.Nameservers) > 0 {
+		s += "  System nameservers:\n"
+		for _, ns := range dns.Nameservers {
+			if ns.Error != nil {
+				s += fmt.Sprintf("    %s: %s\n", ns.Server, ui.Status(ui.LevelFail, ns.Error.Error()))
+				continue
+			}
+			s += fmt.Sprintf("    %s: %s%s\n", ns.Server, ns.Latency, renderRcode(ns.Rcode))
+		}
+	}
+	if m.LoadingDNSBreakdown {
+		s += "  Measuring stub and upstream resolvers...\n"
+	} else if m.DNSBreakdown != nil {
+		s += m.renderDNSBreakdown(*m.DNSBreakdown)
+	} else if dns.LocalResolverTime > collector.SlowDNSThreshold {
+		s += "  " + ui.Status(ui.LevelWarn, "Local resolution is slow, press 'b' for a breakdown") + "\n"
+	} else {
+		s += ui.SubtleStyle.Render("  Press 'b' for a stub/upstream breakdown") + "\n"
+	}
+
+	if m.publicIPCollector != nil {
+		s += "\nPublic IP:\n" + m.renderPublicIP()
+	}
+
+	if m.natCollector == nil {
+		return s // STUN disabled in config
+	}
+	s += "\nNAT Status:\n"
+	if m.LoadingNat {
+		s += "  Probing NAT Type...\n"
+	} else {
+		for _, info := range m.NatInfo {
+			s += fmt.Sprintf("  Target: %s\n", info.Target)
+			if info.Error != nil {
+				s += fmt.Sprintf("    Error: %v\n", info.Error)
+			} else {
+				s += fmt.Sprintf("    Type: %s\n", info.NatType)
+				if explanation := info.NatType.Explanation(); explanation != "" {
+					s += fmt.Sprintf("    %s\n", ui.SubtleStyle.Render(explanation))
+				}
+				s += fmt.Sprintf("    Public IP: %s\n", info.PublicIP)
+				s += fmt.Sprintf("    Local IP: %s\n", info.LocalIP)
+				s += fmt.Sprintf("    RTT: %s\n", info.RTT.Round(100*time.Microsecond))
+				if info.Attempts > 1 {
+					s += ui.SubtleStyle.Render(fmt.Sprintf("    Answered after %d binding requests (lossy path)", info.Attempts)) + "\n"
+				}
+			}
+			s += "\n"
+		}
+	}
+
+	return s
+}
+
+// renderRcode notes a resolver that answered the probe domain with an
+// error code, e.g. NXDOMAIN when the domain does not resolve
+func renderRcode(rcode string) string {
+	if rcode == "" {
+		return ""
+	}
+	return " " + ui.Status(ui.LevelWarn, "("+rcode+" for the probe domain)")
+}
+
+// renderPublicIP shows the HTTP public IP lookup and the provider that
+// answered, or why every provider failed
+func (m Model) renderPublicIP() string {
+	if m.LoadingPublicIP {
+		return "  Querying...\n"
+	}
+	info := m.PublicIP
+	if info.Error != nil {
+		return fmt.Sprintf("  %s\n", ui.Status(ui.LevelFail, fmt.Sprintf("Error: %v", info.Error)))
+	}
+	s := fmt.Sprintf("  %s %s\n", ui.SubtitleStyle.Render(info.IP), ui.SubtleStyle.Render("(via "+info.Provider+")"))
+	switch {
+	case info.GeoError != nil:
+		s += ui.SubtleStyle.Render(fmt.Sprintf("  Geolocation failed: %v", info.GeoError)) + "\n"
+	case info.Org != "" || info.Country != "":
+		network := strings.TrimSpace(info.ASN + " " + info.Org)
+		var place []string
+		for _, p := range []string{info.City, info.Country} {
+			if p != "" {
+				place = append(place, p)
+			}
+		}
+		s += fmt.Sprintf("  %s, %s\n", network, strings.Join(place, ", "))
+	}
+	return s
+}
+
+func (m Model) renderMatrix(matrix collector.ConnectivityMatrix) string {
+	wTarget := 20
+	wCell := 12
+
+	s := fmt.Sprintf("  %-*s", wTarget, "Target")
+	for _, method := range matrix.Methods {
+		s += fmt.Sprintf("%-*s", wCell, method)
+	}
+	s = ui.SubtitleStyle.Render(s) + "\n"
+
+	for _, target := range matrix.Targets {
+		row := fmt.Sprintf("  %-*s", wTarget, truncate(target, wTarget-1))
+		for _, method := range matrix.Methods {
+			cell, ok := matrix.Cell(target, method)
+			switch {
+			case !ok:
+				row += fmt.Sprintf("%-*s", wCell, "-")
+			case cell.Reachable:
+				text := fmt.Sprintf("OK %dms", cell.Latency.Milliseconds())
+				row += padStyled(ui.Status(ui.LevelOK, text), wCell)
+			default:
+				row += padStyled(ui.Status(ui.LevelFail, "BLOCKED"), wCell)
+			}
+		}
+		s += row + "\n"
+	}
+	s += ui.SubtleStyle.Render("  Press 'm' to probe again") + "\n"
+	return s
+}
+
+func (m Model) renderDNSBreakdown(b collector.DNSBreakdown) string {
+	timing := func(label string, t collector.DNSTiming) string {
+		name := fmt.Sprintf("    %-10s %-24s", label, t.Server)
+		if t.Error != nil {
+			return name + ui.Status(ui.LevelFail, fmt.Sprintf("error: %v", t.Error)) + "\n"
+		}
+		text := fmt.Sprintf("connect %dms + query %dms", t.Connect.Milliseconds(), t.Query.Milliseconds())
+		if t.Total() > collector.SlowDNSThreshold {
+			return name + ui.Status(ui.LevelWarn, text) + "\n"
+		}
+		return name + text + "\n"
+	}
+
+	s := "  Breakdown:\n"
+	if b.Stub != nil {
+		s += timing("Stub", *b.Stub)
+	}
+	for _, u := range b.Upstreams {
+		s += timing("Upstream", u)
+	}
+	s += fmt.Sprintf("    %s\n", ui.SubtitleStyle.Render(b.Verdict))
+	return s
+}
+
+func (m Model) renderRegions(regions []collector.RegionLatency) string {
+	wName := 32
+	s := ""
+	for _, r := range regions {
+		name := fmt.Sprintf("  %-*s", wName, truncate(r.Name, wName-1))
+		if r.Error != nil {
+			s += name + ui.Status(ui.LevelFail, "unreachable") + "\n"
+			continue
+		}
+		level := ui.LevelOK
+		if r.Latency > highLatencyRtt {
+			level = ui.LevelWarn
+		}
+		s += name + ui.Status(level, fmt.Sprintf("%dms", r.Latency.Milliseconds())) + "\n"
+	}
+	s += ui.SubtleStyle.Render("  Press 'r' to measure again") + "\n"
+	return s
+}
+
+// refreshDeviations compares the live state against the monitored
+// baseline. It runs when compared data arrives, not on every View.
+func (m *Model) refreshDeviations() {
+	m.baselineDevs = nil
+	if m.Baseline != nil {
+		m.baselineDevs = report.Diff(*m.Baseline, report.NewBaseline("", m.snapshot()), report.NewTolerances(m.cfg.Baseline))
+	}
+}
+
+// renderBaseline lists the live values outside the baseline's tolerances
+func (m Model) renderBaseline() string {
+	b := m.Baseline
+	s := fmt.Sprintf("Baseline '%s' (captured %s):\n", b.Name, b.Time.Format("2006-01-02 15:04"))
+	devs := m.baselineDevs
+	if len(devs) == 0 {
+		return s + "  " + ui.SubtitleStyle.Render("Within tolerance") + "\n\n"
+	}
+	for _, d := range devs {
+		s += fmt.Sprintf("  %s %s (baseline %s)\n", ui.Status(ui.LevelWarn, d.Metric+":"), d.Live, d.Baseline)
+	}
+	return s + "\n"
+}
+
+func (m Model) renderDashboard() string {
+	s := ""
+	if m.Baseline != nil {
+		s += m.renderBaseline()
+	}
+
+	// System Info
+	if m.LoadingSystem {
+		s += "Loading System Info...\n\n"
+	} else {
+		info := m.HostInfo
+		if info.Partial {
+			s += partialNotice(info.Error)
+		}
+		s += "System Information:\n"
+		s += fmt.Sprintf("  Hostname:         %s\n", ui.TitleStyle.Render(info.Hostname))
+		s += fmt.Sprintf("  Operating System: %s %s (%s)\n", info.Platform, info.PlatformVersion, info.OS)
+		s += fmt.Sprintf("  Kernel:           %s\n", info.KernelVersion)
+		s += fmt.Sprintf("  Architecture:     %s\n", info.Arch)
+		if info.VirtualizationSystem != "" {
+			s += fmt.Sprintf("  Virtualization:   %s (%s)\n", info.VirtualizationSystem, info.VirtualizationRole)
+		}
+		s += fmt.Sprintf("  Uptime:           %s\n", info.Uptime)
+		s += fmt.Sprintf("  Load Average:     %.2f, %.2f, %.2f\n\n", info.Load1, info.Load5, info.Load15)
+	}
+
+	if m.publicIPCollector != nil {
+		s += "Public IP:\n" + m.renderPublicIP() + "\n"
+	}
+
+	if m.trafficCollector == nil {
+		return s // Disabled under collectors:
+	}
+	s += fmt.Sprintf("Traffic (Last %s", m.refreshInterval(time.Second))
+	if start := m.Traffic.SessionStart; !start.IsZero() {
+		s += fmt.Sprintf(", session since %s", start.Format("15:04:05"))
+	}
+	s += "):\n"
+	if m.Traffic.Partial {
+		s += "  " + partialNotice(m.Traffic.Error)
+	}
+	for _, iface := range dashboardInterfaces(m.Traffic.Interfaces, m.ShowIdleInterfaces) {
+		t := iface.Traffic
+		switch iface.State {
+		case linkDown:
+			s += fmt.Sprintf("  %s %s\n", ui.SubtleStyle.Render(iface.Name+":"), ui.Status(ui.LevelFail, "(down)"))
+			continue
+		case linkIdle:
+			s += fmt.Sprintf("  %s %s\n", ui.SubtitleStyle.Render(iface.Name+":"), ui.SubtleStyle.Render("(up, idle)"))
+		default:
+			s += fmt.Sprintf("  %s:\n", ui.SubtitleStyle.Render(iface.Name))
+		}
+		s += fmt.Sprintf("    RX: %s  TX: %s\n", m.formatRate(t.RxRate), m.formatRate(t.TxRate))
+		s += m.renderTrafficHistory(iface.Name)
+		s += m.renderSession(t.Session)
+		s += fmt.Sprintf("    Drops: %d  Errors: %d  Collisions: %d\n", t.Drop, t.Errors, t.Collisions)
+		if t.FifoErrors+t.FrameErrors+t.CarrierErrors > 0 {
+			s += "    " + ui.Status(ui.LevelWarn, fmt.Sprintf("FIFO: %d  Frame: %d  Carrier: %d", t.FifoErrors, t.FrameErrors, t.CarrierErrors)) + "\n"
+		}
+	}
+
+	if m.softirqCollector != nil {
+		s += m.renderSoftirqs()
+	}
+
+	units := "bits"
+	if m.RateInBits {
+		units = "bytes"
+	}
+	if m.ShowIdleInterfaces {
+		s += ui.SubtleStyle.Render("\nPress 'i' to hide idle and down links, 'u' to show rates in "+units) + "\n"
+	} else {
+		s += ui.SubtleStyle.Render("\nPress 'i' to show idle and down links, 'u' to show rates in "+units) + "\n"
+	}
+	return s
+}
+
+// renderSession shows what an interface moved while lnd was running
+func (m Model) renderSession(st collector.SessionTraffic) string {
+	s := ui.SubtleStyle.Render(fmt.Sprintf("    Session: RX %s (%d pkts)  TX %s (%d pkts)",
+		m.formatBytes(st.RxBytes), st.RxPackets,
+		m.formatBytes(st.TxBytes), st.TxPackets))
+	if st.Resets > 0 {
+		s += " " + ui.Status(ui.LevelWarn, fmt.Sprintf("counters reset %dx, kept counting", st.Resets))
+	}
+	return s + "\n"
+}
+
+// maxSoftirqCPUs bounds the per-CPU lines on the dashboard
+const maxSoftirqCPUs = 16
+
+// renderSoftirqs lists the CPUs doing network interrupt work, so a single
+// core bottleneck shows up next to the traffic rates
+func (m Model) renderSoftirqs() string {
+	st := m.Softirqs
+	var active []collector.CPUNetLoad
+	for _, cpu := range st.CPUs {
+		if cpu.NetRXRate+cpu.NetTXRate+cpu.IRQRate > 0 {
+			active = append(active, cpu)
+		}
+	}
+	if len(active) == 0 {
+		return ""
+	}
+
+	s := "\nNetwork interrupts per CPU:\n"
+	if st.SingleCore >= 0 {
+		s += "  " + ui.Status(ui.LevelWarn, fmt.Sprintf("CPU%d handles %.0f%% of receive softirqs, RSS/RPS is not spreading the load",
+			st.SingleCore, st.CPUs[st.SingleCore].RXShare*100)) + "\n"
+	}
+	for i, cpu := range active {
+		if i == maxSoftirqCPUs {
+			s += ui.SubtleStyle.Render(fmt.Sprintf("  ... %d more", len(active)-i)) + "\n"
+			break
+		}
+		line := fmt.Sprintf("  CPU%-3d NET_RX %7s (%3.0f%%)  NET_TX %7s  IRQ %7s  softirq %3.0f%%",
+			cpu.CPU, formatPerSec(cpu.NetRXRate), cpu.RXShare*100, formatPerSec(cpu.NetTXRate), formatPerSec(cpu.IRQRate), cpu.SoftirqPct)
+		if cpu.Saturated {
+			line = indentedStatus(ui.LevelFail, line+"  saturated")
+		}
+		s += line + "\n"
+	}
+	return s
+}
+
+// formatPerSec renders an event rate, e.g. 950/s or 12.3k/s
+func formatPerSec(v float64) string {
+	switch {
+	case v >= 1e6:
+		return fmt.Sprintf("%.1fM/s", v/1e6)
+	case v >= 1e4:
+		return fmt.Sprintf("%.1fk/s", v/1e3)
+	default:
+		return fmt.Sprintf("%.0f/s", v)
+	}
+}
+
+// formatRate renders a bytes-per-second rate in the configured units
+func (m Model) formatRate(bytesPerSec float64) string {
+	return components.FormatRate(bytesPerSec, m.RateInBits)
+}
+
+// formatBytes renders a transfer volume in the configured units
+func (m Model) formatBytes(bytes uint64) string {
+	return components.FormatBytes(bytes, m.RateInBits)
+}
+
+type linkState int
+
+const (
+	linkActive linkState = iota
+	linkIdle             // Up but no traffic in the last interval
+	linkDown
+)
+
+type dashboardInterface struct {
+	Name    string
+	Traffic collector.InterfaceTraffic
+	State   linkState
+}
+
+// dashboardInterfaces returns the interfaces to render sorted by name.
+// Unless showIdle is set, links without any traffic are hidden.
+func dashboardInterfaces(traffic map[string]collector.InterfaceTraffic, showIdle bool) []dashboardInterface {
+	var result []dashboardInterface
+	for name, t := range traffic {
+		state := linkActive
+		switch {
+		case !t.Up:
+			state = linkDown
+		case t.RxRate == 0 && t.TxRate == 0:
+			state = linkIdle
+		}
+
+		// By default only show interfaces that carry traffic
+		if !showIdle && t.RxRate == 0 && t.TxRate == 0 && t.RxBytes == 0 {
+			continue
+		}
+		result = append(result, dashboardInterface{Name: name, Traffic: t, State: state})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// renderTrafficHistory draws RX and TX sparklines of an interface's recent
+// rates, bounded by the window width
+func (m Model) renderTrafficHistory(name string) string {
+	width := min(m.Width-12, maxTrafficHistory)
+	if width < 10 || len(m.RxHistory[name]) < 2 {
+		return ""
+	}
+	return fmt.Sprintf("    RX %s\n    TX %s\n",
+		components.Sparkline(m.RxHistory[name], width), components.Sparkline(m.TxHistory[name], width))
+}
+
+// partialNotice flags a section whose collector stopped early, the data
+// shown is what was gathered before err
+func partialNotice(err error) string {
+	return ui.Status(ui.LevelWarn, fmt.Sprintf("Partial results: %v", err)) + "\n"
+}
+
+func (m Model) renderKernel() string {
+	k := m.Kernel
+	if k.Error != nil && !k.Partial {
+		return ui.Status(ui.LevelFail, fmt.Sprintf("Error: %v", k.Error))
+	}
+
+	s := ""
+	if k.Partial {
+		s += partialNotice(k.Error)
+	}
+	s += "TCP Health:\n"
+	retransLevel := ui.LevelOK
+	if k.TCPRetransRate > 1.0 {
+		retransLevel = ui.LevelWarn
+	}
+	s += fmt.Sprintf("  Retransmission Rate: %s\n", ui.Status(retransLevel, fmt.Sprintf("%.2f%%", k.TCPRetransRate)))
+
+	s += "\nTCP States:\n"
+	s += fmt.Sprintf("  ESTABLISHED: %d\n", k.TCPEstablished)
+	s += fmt.Sprintf("  TIME_WAIT:   %d\n", k.TCPTimeWait)
+	s += fmt.Sprintf("  CLOSE_WAIT:  %d\n", k.TCPCloseWait)
+
+	s += m.renderTopSockets()
+	s += m.renderListenSockets()
+
+	s += "\nUDP Issues:\n"
+	s += fmt.Sprintf("  RcvbufErrors: %d\n", k.UDPRcvbufErrors)
+
+	if k.ConntrackMax > 0 {
+		s += "\nConntrack:\n"
+		line := fmt.Sprintf("  Entries: %d / %d (%.0f%%)", k.ConntrackCount, k.ConntrackMax, k.ConntrackUtilization())
+		if k.ConntrackUtilization() > highConntrackUtilization {
+			line = indentedStatus(ui.LevelWarn, line+" - new connections are dropped when the table is full")
+		}
+		s += line + "\n"
+	}
+
+	// System Limits & Sysctl (from HostInfo)
+	if !m.LoadingSystem {
+		s += "\nSystem Limits:\n"
+		s += fmt.Sprintf("  Max Open Files: %d\n", m.HostInfo.MaxOpenFiles)
+		s += fmt.Sprintf("  File Max:       %d\n", m.HostInfo.FileMax)
+
+		if m.HostInfo.TCPCongestion != "" {
+			s += "\nTCP Congestion Control:\n"
+			s += fmt.Sprintf("  Active:    %s\n", ui.SubtitleStyle.Render(m.HostInfo.TCPCongestion))
+			s += fmt.Sprintf("  Available: %s\n", strings.Join(m.HostInfo.TCPCongestionAvail, ", "))
+			if m.HostInfo.TCPCongestion != "bbr" {
+				if rtt := m.maxTargetRtt(); rtt > highLatencyRtt {
+					advice := fmt.Sprintf("  Tip: high-latency path detected (%dms); BBR usually performs better than %s here.",
+						rtt.Milliseconds(), m.HostInfo.TCPCongestion)
+					if !m.HostInfo.BBRAvailable() {
+						advice += " Load it with 'modprobe tcp_bbr'."
+					}
+					s += indentedStatus(ui.LevelWarn, advice) + "\n"
+				}
+			}
+		}
+
+		if len(m.HostInfo.SysctlParams) > 0 {
+			s += "\nSysctl Parameters:\n"
+			for k, v := range m.HostInfo.SysctlParams {
+				s += fmt.Sprintf("  %s: %s\n", k, v)
+			}
+		}
+	}
+
+	return s
+}
+
+// renderTopSockets lists the established sockets with the most
+// retransmissions and highest RTT, or a hint while the list is hidden
+func (m Model) renderTopSockets() string {
+	if !m.ShowTopSockets {
+		return ui.SubtleStyle.Render("  Press 's' to list the sockets with the most retransmissions and highest RTT") + "\n"
+	}
+	keys := "1-6 to sort"
+	if m.ShowListenSockets {
+		keys = "hide the listening list with 'l' to sort" // It takes the table keys
+	}
+	by := "retransmissions, then RTT"
+	if m.topSocketOrder() == collector.ByRTT {
+		by = "RTT, then retransmissions"
+	}
+	s := fmt.Sprintf("\nTop %d Sockets (by %s; 's' to hide, %s):\n", topSocketsCount, by, keys)
+	if len(m.TopSockets) == 0 {
+		if m.LoadingTopSockets {
+			return s + "  Reading socket info...\n"
+		}
+		return s + ui.SubtleStyle.Render("  No established TCP sockets, or socket diagnostics are unavailable") + "\n"
+	}
+	return s + indent(m.topSocketsTable.View(), "  ") + "\n"
+}
+
+// topSocketOrder lists the highest RTT sockets while the table is sorted by
+// RTT, the most retransmitting ones otherwise
+func (m Model) topSocketOrder() collector.SocketOrder {
+	if col, _ := m.topSocketsTable.SortColumn(); col == topSocketsRTTColumn {
+		return collector.ByRTT
+	}
+	return collector.ByRetrans
+}
+
+// topSocketsRTTColumn is the RTT column of the top sockets table
+const topSocketsRTTColumn = 2
+
+func newTopSocketsTable() *components.Table {
+	return components.NewTable([]components.Column{
+		{Title: "Local"},
+		{Title: "Remote"},
+		{Title: "RTT", Right: true},
+		{Title: "RTT var", Right: true},
+		{Title: "Retrans", Right: true},
+		{Title: "Note"},
+	}, 0)
+}
+
+// topSocketRows turns sockets into table rows, noting loss and high RTT
+func topSocketRows(sockets []collector.SocketInfo) [][]string {
+	rows := make([][]string, 0, len(sockets))
+	for _, sock := range sockets {
+		var notes []string
+		if sock.Lost > 0 {
+			notes = append(notes, fmt.Sprintf("%d lost", sock.Lost))
+		}
+		if sock.RTT > highLatencyRtt {
+			notes = append(notes, "high RTT")
+		}
+		rows = append(rows, []string{sock.Local, sock.Remote, formatSocketRTT(sock.RTT),
+			formatSocketRTT(sock.RTTVar), strconv.Itoa(int(sock.Retrans)), strings.Join(notes, ", ")})
+	}
+	return rows
+}
+
+// renderListenSockets lists the listening sockets with their owners, or a
+// hint while the list is hidden
+func (m Model) renderListenSockets() string {
+	if !m.ShowListenSockets {
+		return ui.SubtleStyle.Render("  Press 'l' to list the listening sockets and their processes") + "\n"
+	}
+	s := "\nListening Sockets ('l' to hide, up/down to scroll, 1-3 to sort):\n"
+	res := m.ListenSockets
+	switch {
+	case res == nil:
+		return s + "  Reading sockets...\n"
+	case res.Error != nil:
+		return s + "  " + ui.Status(ui.LevelFail, fmt.Sprintf("%v", res.Error)) + "\n"
+	case len(res.Sockets) == 0:
+		return s + ui.SubtleStyle.Render("  Nothing is listening") + "\n"
+	}
+
+	s += indent(m.listenTable.View(), "  ") + "\n"
+	unowned := 0
+	for _, sock := range res.Sockets {
+		if sock.PID == 0 {
+			unowned++
+		}
+	}
+	if unowned > 0 && os.Geteuid() != 0 {
+		s += ui.SubtleStyle.Render(fmt.Sprintf("  %d sockets belong to other users' processes, run as root to see them", unowned)) + "\n"
+	}
+	return s
+}
+
+// listenSocketsHeight is how many listening sockets show at once
+const listenSocketsHeight = 15
+
+func newListenTable() *components.Table {
+	return components.NewTable([]components.Column{
+		{Title: "Proto"},
+		{Title: "Address", Less: lessHostPort},
+		{Title: "Process"},
+	}, listenSocketsHeight)
+}
+
+// listenRows turns listening sockets into table rows
+func listenRows(sockets []collector.ListenSocket) [][]string {
+	rows := make([][]string, 0, len(sockets))
+	for _, sock := range sockets {
+		owner := "-"
+		if sock.PID > 0 {
+			owner = fmt.Sprintf("%s (%d)", sock.Process, sock.PID)
+		}
+		rows = append(rows, []string{sock.Proto, net.JoinHostPort(sock.Addr, strconv.Itoa(sock.Port)), owner})
+	}
+	return rows
+}
+
+// lessHostPort orders host:port cells by port, then address
+func lessHostPort(a, b string) bool {
+	hostA, portA, _ := net.SplitHostPort(a)
+	hostB, portB, _ := net.SplitHostPort(b)
+	pa, _ := strconv.Atoi(portA)
+	pb, _ := strconv.Atoi(portB)
+	if pa != pb {
+		return pa < pb
+	}
+	return hostA < hostB
+}
+
+// formatSocketRTT shows a kernel RTT estimate in milliseconds
+func formatSocketRTT(d time.Duration) string {
+	return fmt.Sprintf("%.1fms", float64(d.Microseconds())/1000)
+}
+
+// highConntrackUtilization is the conntrack table fill in percent flagged as pressure
+const highConntrackUtilization = 80.0
+
+// highLatencyRtt is the average RTT above which a path is considered high-latency
+const highLatencyRtt = 100 * time.Millisecond
+
+// renderPingResults lists ping results by target
+func renderPingResults(results map[string]collector.PingResult) string {
+	targets := make([]string, 0, len(results))
+	for target := range results {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+
+	s := ""
+	for _, target := range targets {
+		res := results[target]
+		status := "OK"
+		level := ui.LevelOK
+		if res.PacketLoss > 0 || res.Error != nil {
+			status = "FAIL"
+			level = ui.LevelFail
+		}
+		// TCP fallback verdicts
+		switch res.Reachability {
+		case collector.ReachOpen:
+			status = fmt.Sprintf("OK (TCP:%d open)", res.Port)
+		case collector.ReachClosed:
+			status = fmt.Sprintf("UP (TCP:%d closed)", res.Port)
+			level = ui.LevelWarn
+		case collector.ReachFiltered:
+			status = fmt.Sprintf("FILTERED (TCP:%d)", res.Port)
+		}
 
-	// Public IP
-	s += "Public IP:\n"
-	if m.LoadingPublicIP {
-		s += "  Querying...\n"
-	} else {
-		info := m.PublicIP
-		if info.Error != nil {
-			s += fmt.Sprintf("  %s\n", ui.ErrorStyle.Render(fmt.Sprintf("Error: %v", info.Error)))
-		} else {
-			s += fmt.Sprintf("  %s (via %s)\n", ui.SubtitleStyle.Render(info.IP), info.Provider)
+		rtt := fmt.Sprintf("%.2fms", float64(res.AvgRtt.Microseconds())/1000.0)
+		if res.Error != nil {
+			rtt = "N/A"
+		} else if res.Reachability == collector.ReachUnknown {
+			rtt += ", Jitter: " + renderJitter(res)
 		}
-	}
-	s += "\n"
 
-	s += "Traffic (Last 1s):\n"
-	for name, t := range m.Traffic.Interfaces {
-		// Only show active interfaces
-		if t.RxRate == 0 && t.TxRate == 0 && t.RxBytes == 0 {
-			continue
+		name := target
+		if res.Hostname != "" {
+			name += " " + ui.SubtleStyle.Render("("+res.Hostname+")")
+		}
+		s += fmt.Sprintf("    %s: %s (Loss: %.0f%%, RTT: %s)\n",
+			name, ui.Status(level, status), res.PacketLoss, rtt)
+		if res.DSCPError != nil {
+			s += "      " + ui.Status(ui.LevelWarn, fmt.Sprintf("%v, sent unmarked", res.DSCPError)) + "\n"
 		}
-		s += fmt.Sprintf("  %s:\n", ui.SubtitleStyle.Render(name))
-		s += fmt.Sprintf("    RX: %.2f KB/s  TX: %.2f KB/s\n", t.RxRate/1024, t.TxRate/1024)
-		s += fmt.Sprintf("    Drops: %d  Errors: %d\n", t.Drop, t.Errors)
 	}
 	return s
 }
 
-func (m Model) renderKernel() string {
-	k := m.Kernel
-	if k.Error != nil {
-		return ui.ErrorStyle.Render(fmt.Sprintf("Error: %v", k.Error))
-	}
+// highJitter is the jitter above which voice and game traffic suffers
+const highJitter = 30 * time.Millisecond
 
-	s := "TCP Health:\n"
-	retransStyle := ui.SubtitleStyle
-	if k.TCPRetransRate > 1.0 {
-		retransStyle = ui.WarningStyle
+// renderJitter shows a ping's jitter and RTT standard deviation, warning
+// when the jitter is high
+func renderJitter(res collector.PingResult) string {
+	s := fmt.Sprintf("%.2fms (σ %.2fms)", float64(res.Jitter.Microseconds())/1000.0, float64(res.StdDevRtt.Microseconds())/1000.0)
+	if res.Jitter > highJitter {
+		return ui.Status(ui.LevelWarn, s)
 	}
-	s += fmt.Sprintf("  Retransmission Rate: %s\n", retransStyle.Render(fmt.Sprintf("%.2f%%", k.TCPRetransRate)))
-
-	s += "\nTCP States:\n"
-	s += fmt.Sprintf("  ESTABLISHED: %d\n", k.TCPEstablished)
-	s += fmt.Sprintf("  TIME_WAIT:   %d\n", k.TCPTimeWait)
-	s += fmt.Sprintf("  CLOSE_WAIT:  %d\n", k.TCPCloseWait)
-
-	s += "\nUDP Issues:\n"
-	s += fmt.Sprintf("  RcvbufErrors: %d\n", k.UDPRcvbufErrors)
-
-	// System Limits & Sysctl (from HostInfo)
-	if !m.LoadingSystem {
-		s += "\nSystem Limits:\n"
-		s += fmt.Sprintf("  Max Open Files: %d\n", m.HostInfo.MaxOpenFiles)
-		s += fmt.Sprintf("  File Max:       %d\n", m.HostInfo.FileMax)
+	return s
+}
 
-		if len(m.HostInfo.SysctlParams) > 0 {
-			s += "\nSysctl Parameters:\n"
-			for k, v := range m.HostInfo.SysctlParams {
-				s += fmt.Sprintf("  %s: %s\n", k, v)
-			}
+// maxTargetRtt returns the highest average RTT among reachable connectivity targets
+func (m Model) maxTargetRtt() time.Duration {
+	var max time.Duration
+	for _, res := range m.Connectivity.Targets {
+		if res.Error == nil && res.AvgRtt > max {
+			max = res.AvgRtt
 		}
 	}
-
-	return s
+	return max
 }
 
 func (m Model) renderAbout() string {
@@ -730,18 +4100,100 @@ func (m Model) renderAbout() string {
 	s += "\n"
 	s += "A TUI-based network diagnostic tool for Linux.\n"
 	s += "Use 'tab' to switch between views.\n"
+	s += "\n" + m.renderSelfTest()
+	return s
+}
+
+func (m Model) renderSpeedTest() string {
+	s := ui.TitleStyle.Render("Bandwidth Speed Test") + "\n\n"
+	for i, server := range m.speedTestServers {
+		cursor := "  "
+		if i == m.SelectedSpeedTestServer {
+			cursor = "> "
+		}
+		s += fmt.Sprintf("%s%-16s %s\n", cursor, server.Name, truncate(server.DownloadURL, 60))
+	}
+	s += "\n"
+
+	if p := m.SpeedTestProgress; p != nil {
+		s += fmt.Sprintf("Running %s against %s: %.1f Mbps, %s in %s\n", p.Phase, p.Server, p.Mbps, m.formatBytes(uint64(p.Bytes)), p.Elapsed.Round(time.Second))
+	} else if m.RunningSpeedTest {
+		s += "Starting speed test...\n"
+	}
+
+	if res := m.SpeedTest; res != nil {
+		if res.Error != nil && res.Downloaded == 0 {
+			s += ui.Status(ui.LevelFail, fmt.Sprintf("Error: %v", res.Error)) + "\n"
+		} else {
+			s += fmt.Sprintf("Server:    %s\n", res.Server)
+			s += fmt.Sprintf("Idle RTT:  %dms\n", res.Idle.Milliseconds())
+			s += fmt.Sprintf("Download:  %.1f Mbps  (RTT %dms under load)\n", res.DownloadMbps, res.DownloadLatency.Milliseconds())
+			if res.Uploaded > 0 || res.UploadMbps > 0 {
+				s += fmt.Sprintf("Upload:    %.1f Mbps  (RTT %dms under load)\n", res.UploadMbps, res.UploadLatency.Milliseconds())
+			}
+			s += ui.SubtleStyle.Render(fmt.Sprintf("Transferred %s down, %s up", m.formatBytes(uint64(res.Downloaded)), m.formatBytes(uint64(res.Uploaded)))) + "\n"
+			if res.Stopped {
+				s += ui.Status(ui.LevelWarn, "Stopped before the end, the numbers cover what ran") + "\n"
+			} else if res.Error != nil {
+				s += ui.Status(ui.LevelFail, fmt.Sprintf("%v", res.Error)) + "\n"
+			}
+		}
+	}
+
+	help := "Press 's' to start the test, up/down to pick a server"
+	if m.RunningSpeedTest {
+		help = "Press 's' to stop the test"
+	}
+	s += "\n" + ui.SubtleStyle.Render(help) + "\n"
+	return s
+}
+
+// renderSelfTest lists which of lnd's capabilities work in this environment
+func (m Model) renderSelfTest() string {
+	if m.LoadingSelfTest {
+		return "Checking capabilities...\n"
+	}
+	s := "Capabilities:\n"
+	for _, check := range m.SelfTest {
+		if check.OK {
+			s += fmt.Sprintf("  %s %s: %s\n", ui.Mark(ui.LevelOK, "[✓]"), check.Name, check.Detail)
+		} else {
+			s += "  " + ui.Mark(ui.LevelWarn, fmt.Sprintf("[✗] %s: %s", check.Name, check.Detail)) + "\n"
+		}
+	}
 	return s
 }
 
+// renderWildcard describes the sibling name probe of a lookup
+func renderWildcard(w *collector.DNSWildcard) string {
+	switch {
+	case w == nil:
+		return ""
+	case w.Error != nil:
+		return ui.SubtleStyle.Render(fmt.Sprintf("Wildcard: probe failed (%v)", w.Error)) + "\n"
+	case !w.Detected:
+		return "Wildcard: none detected\n"
+	}
+	parent := w.Probe[strings.Index(w.Probe, ".")+1:]
+	line := fmt.Sprintf("Wildcard: *.%s answers (%s -> %s)", parent, w.Probe, strings.Join(w.Answers, ", "))
+	if w.Matches {
+		line += ", this answer likely comes from it"
+	}
+	return ui.Status(ui.LevelWarn, line) + "\n"
+}
+
 func (m Model) renderDNS() string {
+	if m.DNSForm != nil {
+		return m.renderDNSForm()
+	}
 	s := ui.TitleStyle.Render("DNS Lookup Tool") + "\n\n"
 
 	// Input
-	s += fmt.Sprintf("Domain/IP: %s\n", m.DNSInput.View())
+	s += fmt.Sprintf("Domain/IP: %s (Ctrl+v to paste a URL/host)\n", m.DNSInput.View())
 
 	// Settings
 	server := m.DNSServers[m.SelectedDNSServer]
-	s += fmt.Sprintf("Server:    %s (Use Up/Down to change)\n", server.Name)
+	s += fmt.Sprintf("Server:    %s (Use Up/Down to change, Ctrl+n to add, Alt+e to edit)\n", server.Name)
 
 	if server.Name == "Custom" {
 		s += fmt.Sprintf("  Address: %s (Ctrl+Down to edit)\n", m.DNSServerInput.View())
@@ -752,9 +4204,19 @@ func (m Model) renderDNS() string {
 
 	proto := dnsProtocols[m.SelectedProtocol]
 	s += fmt.Sprintf("Protocol:  %s (Use Ctrl+p to change)\n", proto)
+	s += fmt.Sprintf("Cookie:    %s (Use Alt+c to toggle)\n", onOff(m.DNSCookie))
+	s += fmt.Sprintf("DNSSEC:    %s (Use Alt+d to toggle)\n", onOff(m.DNSSEC))
+	s += fmt.Sprintf("Wildcard:  %s (Use Alt+w to toggle the sibling name probe)\n", onOff(m.DNSWildcard))
+	s += fmt.Sprintf("Verbose:   %s (Use Alt+v to toggle all sections)\n", onOff(m.DNSVerbose))
+	s += fmt.Sprintf("Check:     %s (Use Alt+a to change, Alt+r to resolve and connect)\n", collector.ConnectApps[m.SelectedConnectApp])
+	s += m.renderDHCP()
 
-	s += "\nPress Enter to Query\n"
-	s += ui.DividerStyle.Render(strings.Repeat("-", m.Width-4)) + "\n"
+	s += "\nPress Enter to Query, Ctrl+o to check answer consistency, Alt+m to compare all servers, Ctrl+g to diagnose as a URL\n"
+	s += "Ctrl+f to fingerprint the selected resolver (incl. NXDOMAIN hijacking), Alt+z to test zone transfers (AXFR)\n"
+	s += "Ctrl+r to trace the name from the root servers, following each delegation, Alt+k to check if the resolver caches it\n"
+	s += "Alt+s to scan the host's common service ports, Alt+o to show the interface and source address it is reached from\n"
+	s += "Alt+b to run the input as a batch, e.g. {a,aaaa,mx} a.com,b.com\n"
+	s += divider(m.Width-4) + "\n"
 
 	if m.LoadingDNS {
 		s += "\nQuerying...\n"
@@ -764,23 +4226,102 @@ func (m Model) renderDNS() string {
 			s += fmt.Sprintf("\nError: %v\n", res.Error)
 		} else {
 			s += fmt.Sprintf("\nServer: %s (%s)\n", res.Server, res.Protocol)
+			if res.UnicodeName != "" {
+				s += fmt.Sprintf("Name: %s (sent as %s)\n", res.UnicodeName, res.ASCIIName)
+			}
+			if res.SourcePort != 0 {
+				s += fmt.Sprintf("Source Port: %d\n", res.SourcePort)
+			}
+			if res.Family != "" {
+				s += fmt.Sprintf("Family: %s\n", res.Family)
+			}
+			if res.ALPN != "" {
+				s += fmt.Sprintf("ALPN: %s\n", res.ALPN)
+			}
+			if res.Fallback != "" {
+				s += ui.Status(ui.LevelWarn, res.Fallback) + "\n"
+			}
 			s += fmt.Sprintf("Latency: %s\n", res.Latency)
-			s += fmt.Sprintf("Response: %s\n", res.ResponseCode)
+			if res.ExtendedError != "" {
+				s += fmt.Sprintf("Response: %s %s\n", res.ResponseCode, ui.Status(ui.LevelWarn, "("+res.ExtendedError+")"))
+			} else {
+				s += fmt.Sprintf("Response: %s\n", res.ResponseCode)
+			}
+			if res.ResponseSize > 0 {
+				compression := "uncompressed names"
+				if res.Compressed {
+					compression = "compressed names"
+				}
+				line := fmt.Sprintf("Size: %d bytes, %s", res.ResponseSize, compression)
+				if res.Protocol == collector.ProtoUDP && res.ResponseSize > dnsSafeUDPSize {
+					line = ui.Status(ui.LevelWarn, fmt.Sprintf("%s (above %d bytes, may fragment over UDP)", line, dnsSafeUDPSize))
+				}
+				s += line + "\n"
+			}
+			if len(res.CNAMEChain) > 0 {
+				chain := strings.Join(res.CNAMEChain, " -> ")
+				if len(res.ChainAnswers) > 0 {
+					chain += " -> " + strings.Join(res.ChainAnswers, ", ")
+				}
+				s += fmt.Sprintf("CNAME chain: %s\n", chain)
+			}
+			s += renderWildcard(res.Wildcard)
+			if res.DNSSECRequested {
+				if res.Authenticated {
+					s += fmt.Sprintf("DNSSEC: %s\n", ui.Status(ui.LevelOK, "authenticated (AD)"))
+				} else {
+					s += fmt.Sprintf("DNSSEC: %s\n", ui.Status(ui.LevelWarn, "not validated by the resolver"))
+				}
+			}
+			if res.CookieSent {
+				if res.Cookie != nil && res.Cookie.Server != "" {
+					s += fmt.Sprintf("Cookie: %s (server cookie %s)\n", ui.Status(ui.LevelOK, "supported"), res.Cookie.Server)
+				} else {
+					s += fmt.Sprintf("Cookie: %s\n", ui.Status(ui.LevelWarn, "no server cookie returned"))
+				}
+			}
 
 			if res.CertInfo != nil {
 				s += "\nTLS Certificate:\n"
 				s += fmt.Sprintf("  Subject: %s\n", res.CertInfo.Subject)
 				s += fmt.Sprintf("  Issuer:  %s\n", res.CertInfo.Issuer)
 				s += fmt.Sprintf("  Expires: %s\n", res.CertInfo.NotAfter.Format(time.RFC822))
+				s += fmt.Sprintf("  OCSP:    %s\n", renderOCSP(res.CertInfo))
 				// s += fmt.Sprintf("  Version: TLS 1.%d\n", res.CertInfo.Version-0x0301+1)
 			}
 
-			s += "\nRecords:\n"
-			if len(res.Records) == 0 {
-				s += "  (No records found)\n"
+			if m.DNSVerbose {
+				s += renderDNSSections(res)
+			} else {
+				s += "\nRecords:\n"
+				if len(res.Records) == 0 {
+					s += "  (No records found)\n"
+				}
+				for _, rec := range res.Records {
+					s += fmt.Sprintf("  %s\n", rec)
+				}
+				s += renderCollapsedSections(res)
 			}
-			for _, rec := range res.Records {
-				s += fmt.Sprintf("  %s\n", rec)
+
+			if len(res.SRV) > 0 {
+				s += "\nSRV Targets (TCP):\n"
+				if m.LoadingSRVChecks {
+					s += "  Checking SRV targets...\n"
+				}
+				for _, check := range m.SRVChecks {
+					rec := check.Record
+					line := fmt.Sprintf("  [prio %d, weight %d] %s:%d ", rec.Priority, rec.Weight, rec.Target, rec.Port)
+					switch {
+					case rec.Target == "":
+						s += line + ui.SubtleStyle.Render("service not available (\".\" target)") + "\n"
+					case check.NotApplicable:
+						s += line + ui.SubtleStyle.Render("UDP service, not probed") + "\n"
+					case check.Reachable:
+						s += line + ui.Status(ui.LevelOK, fmt.Sprintf("OK %dms", check.Latency.Milliseconds())) + "\n"
+					default:
+						s += line + ui.Status(ui.LevelFail, fmt.Sprintf("unreachable (%v)", check.Error)) + "\n"
+					}
+				}
 			}
 
 			// Ping Result
@@ -790,21 +4331,509 @@ func (m Model) renderDNS() string {
 			} else if m.DNSPing != nil {
 				ping := m.DNSPing
 				if ping.Error != nil {
-					s += fmt.Sprintf("  %s: %s\n", ping.Target, ui.ErrorStyle.Render(fmt.Sprintf("Failed (%v)", ping.Error)))
+					s += fmt.Sprintf("  %s: %s\n", ping.Target, ui.Status(ui.LevelFail, fmt.Sprintf("Failed (%v)", ping.Error)))
 				} else {
-					status := "OK"
-					style := ui.SubtitleStyle
+					status, level := "OK", ui.LevelOK
 					if ping.PacketLoss > 0 {
-						status = "Lossy"
-						style = ui.WarningStyle
+						status, level = "Lossy", ui.LevelWarn
+					}
+					rtt := ping.AvgRtt.String()
+					if ping.Reachability == collector.ReachUnknown {
+						rtt += ", Jitter: " + renderJitter(*ping)
 					}
 					s += fmt.Sprintf("  %s: %s (Loss: %.0f%%, RTT: %s)\n",
-						ping.Target, style.Render(status), ping.PacketLoss, ping.AvgRtt)
+						ping.Target, ui.Status(level, status), ping.PacketLoss, rtt)
 				}
 			}
 		}
 	}
 
+	s += m.renderDNSCache()
+	s += m.renderDNSCapabilities()
+	s += m.renderZoneTransfer()
+	s += m.renderDNSTrace()
+	s += m.renderResolveConnect()
+	s += m.renderPortScan()
+	s += m.renderDNSEgress()
+	s += m.renderDNSBatch()
+	s += m.renderDNSCompare()
+	s += m.renderDNSConsistency()
+	s += m.renderURLDiagnosis()
+
+	return s
+}
+
+// certExpiryWarning is how close to expiry a tunnel certificate is flagged
+const certExpiryWarning = 14 * 24 * time.Hour
+
+// renderTunnelCert describes a tunnel's server certificate, warning-styled
+// when it expires within certExpiryWarning of now
+func renderTunnelCert(c *collector.CertInfo, now time.Time) string {
+	line := fmt.Sprintf("cert %s, issuer %s, expires %s", c.Subject, c.Issuer, c.NotAfter.Format("2006-01-02"))
+	switch left := c.NotAfter.Sub(now); {
+	case left < 0:
+		return "  └─ " + ui.Status(ui.LevelFail, line+" (expired)")
+	case left < certExpiryWarning:
+		return "  └─ " + ui.Status(ui.LevelWarn, fmt.Sprintf("%s (in %d days)", line, int(left.Hours()/24)))
+	}
+	return ui.SubtleStyle.Render("  └─ " + line)
+}
+
+// renderOCSP describes the stapled OCSP status of a resolver certificate
+func renderOCSP(c *collector.CertInfo) string {
+	switch c.OCSPStatus {
+	case collector.OCSPGood:
+		s := ui.Status(ui.LevelOK, "good") + " (stapled"
+		if !c.OCSPNextUpdate.IsZero() {
+			s += ", next update " + c.OCSPNextUpdate.Format(time.RFC822)
+		}
+		return s + ")"
+	case collector.OCSPRevoked:
+		return ui.Status(ui.LevelFail, "revoked since "+c.OCSPRevokedAt.Format(time.RFC822))
+	case collector.OCSPUnknown:
+		if c.OCSPError != nil {
+			return ui.Status(ui.LevelWarn, fmt.Sprintf("unreadable staple: %v", c.OCSPError))
+		}
+		return ui.Status(ui.LevelWarn, "unknown to the responder")
+	default:
+		return ui.Status(ui.LevelUnknown, "none (not stapled)")
+	}
+}
+
+// renderDHCP lists DHCP leases and warns about offered resolvers that are not in use
+func (m Model) renderDHCP() string {
+	if m.DHCP == nil {
+		return ""
+	}
+	s := ""
+	for _, lease := range m.DHCP.Leases {
+		s += ui.SubtleStyle.Render(fmt.Sprintf("DHCP:      %s gateway %s, DNS %s",
+			lease.Interface, lease.Gateway, strings.Join(lease.DNSServers, " "))) + "\n"
+	}
+	if len(m.DHCP.Unused) > 0 {
+		inUse := "no resolvers found in resolv.conf"
+		if len(m.DHCP.Resolvers) > 0 {
+			inUse = "the system resolves via " + strings.Join(m.DHCP.Resolvers, ", ")
+		}
+		s += ui.Status(ui.LevelWarn, fmt.Sprintf("DHCP offered %s, but %s", strings.Join(m.DHCP.Unused, ", "), inUse)) + "\n"
+	}
+	return s
+}
+
+// renderDNSSections shows the whole response like dig: header flags and
+// section counts, then every non-empty section
+func renderDNSSections(res *collector.DNSLookupResult) string {
+	s := fmt.Sprintf("\n;; flags: %s; QUERY: %d, ANSWER: %d, AUTHORITY: %d, ADDITIONAL: %d\n",
+		strings.Join(res.Flags, " "), len(res.Question), len(res.Records), len(res.Authority), len(res.Additional))
+	for _, section := range []struct {
+		name    string
+		records []string
+	}{
+		{"QUESTION", res.Question},
+		{"ANSWER", res.Records},
+		{"AUTHORITY", res.Authority},
+		{"ADDITIONAL", res.Additional},
+	} {
+		if len(section.records) == 0 {
+			continue
+		}
+		s += "\n" + ui.SubtitleStyle.Render(";; "+section.name+" SECTION:") + "\n"
+		for _, rec := range section.records {
+			s += fmt.Sprintf("  %s\n", rec)
+		}
+	}
+	return s
+}
+
+// renderCollapsedSections lists the non-empty authority and additional
+// sections as headings only, Alt+v expands them
+func renderCollapsedSections(res *collector.DNSLookupResult) string {
+	s := ""
+	for _, section := range []struct {
+		name    string
+		records []string
+	}{
+		{"Authority", res.Authority},
+		{"Additional", res.Additional},
+	} {
+		if n := len(section.records); n > 0 {
+			s += ui.SubtleStyle.Render(fmt.Sprintf("\n%s section (%d), Alt+v to expand", section.name, n)) + "\n"
+		}
+	}
+	return s
+}
+
+func (m Model) renderURLDiagnosis() string {
+	if m.LoadingURLDiagnosis {
+		return "\nDiagnosing URL (DNS, TCP, TLS, HTTP, ping)...\n"
+	}
+	d := m.URLDiagnosis
+	if d == nil {
+		return ""
+	}
+
+	s := fmt.Sprintf("\nDiagnosis of %s:\n", d.URL)
+	for _, step := range d.Steps {
+		var mark, detail string
+		switch {
+		case step.Skipped:
+			mark = ui.SubtleStyle.Render("[-]")
+			detail = ui.SubtleStyle.Render(step.Detail)
+		case step.Passed:
+			mark = ui.Mark(ui.LevelOK, "[✓]")
+			detail = step.Detail
+		default:
+			mark = ui.Mark(ui.LevelFail, "[✗]")
+			detail = ui.Mark(ui.LevelFail, fmt.Sprintf("%v", step.Error))
+		}
+		latency := ""
+		if !step.Skipped {
+			latency = fmt.Sprintf("%dms", step.Latency.Milliseconds())
+		}
+		s += fmt.Sprintf("  %s %-15s %7s  %s\n", mark, step.Name, latency, detail)
+	}
+	if failed := d.FirstFailure(); failed != nil {
+		s += "  " + ui.Status(ui.LevelWarn, "First failure: "+failed.Name) + "\n"
+	}
+	if d.Headers != nil {
+		s += renderSecurityHeaders(*d.Headers)
+	}
+	return s
+}
+
+// renderSecurityHeaders summarizes the security headers on two lines
+func renderSecurityHeaders(h collector.SecurityHeaders) string {
+	var parts []string
+	if h.HSTS != "" {
+		hsts := fmt.Sprintf("HSTS %dd", int(h.HSTSMaxAge.Hours()/24))
+		if h.HSTSSubdomains {
+			hsts += " +subdomains"
+		}
+		if h.HSTSPreload {
+			hsts += " +preload"
+		}
+		parts = append(parts, hsts)
+	}
+	if h.CSP {
+		parts = append(parts, "CSP")
+	}
+	if h.FrameOptions != "" {
+		parts = append(parts, "X-Frame-Options "+h.FrameOptions)
+	}
+	if h.Server != "" {
+		parts = append(parts, "Server "+h.Server)
+	}
+	s := ""
+	if len(parts) > 0 {
+		s += "  Headers: " + strings.Join(parts, " | ") + "\n"
+	}
+	if len(h.Missing) > 0 {
+		s += "  " + ui.Status(ui.LevelWarn, "Missing: "+strings.Join(h.Missing, ", ")) + "\n"
+	}
+	return s
+}
+
+func (m Model) renderDNSCapabilities() string {
+	if m.LoadingDNSCapabilities {
+		return "\nResolver Capabilities: probing...\n"
+	}
+	res := m.DNSCapabilities
+	if res == nil {
+		return ""
+	}
+
+	s := fmt.Sprintf("\nResolver Capabilities (%s via %s):\n", res.Server, res.Protocol)
+	for _, c := range res.Capabilities {
+		var mark, detail string
+		switch c.State {
+		case collector.CapabilityYes:
+			mark = ui.Mark(ui.LevelOK, "[✓]")
+		case collector.CapabilityNo:
+			mark = ui.Mark(ui.LevelWarn, "[✗]")
+		default:
+			mark = ui.SubtleStyle.Render("[?]")
+		}
+		detail = c.Detail
+		if c.Error != nil {
+			detail = ui.Status(ui.LevelFail, fmt.Sprintf("%v", c.Error))
+		} else if c.State == collector.CapabilityNA {
+			detail = ui.SubtleStyle.Render(c.Detail)
+		} else if c.Name == collector.CapNXDomain && c.State == collector.CapabilityNo {
+			detail = ui.Mark(ui.LevelFail, c.Detail) // Not a missing feature, the resolver lies
+		}
+		s += fmt.Sprintf("  %s %-19s %s\n", mark, c.Name, detail)
+	}
+	return s
+}
+
+// renderZoneTransfer lists the AXFR outcome per nameserver; an open zone is a finding
+func (m Model) renderZoneTransfer() string {
+	if m.LoadingZoneTransfer {
+		return "\nZone Transfer: asking each nameserver for AXFR...\n"
+	}
+	res := m.ZoneTransfer
+	if res == nil {
+		return ""
+	}
+
+	s := fmt.Sprintf("\nZone Transfer (%s):\n", res.Zone)
+	if res.Error != nil {
+		return s + "  " + ui.Status(ui.LevelFail, fmt.Sprintf("%v", res.Error)) + "\n"
+	}
+	if res.Exposed() {
+		s += "  " + ui.Status(ui.LevelFail, "Zone exposed: anyone can list every record") + "\n"
+	} else {
+		s += "  " + ui.SubtitleStyle.Render("No nameserver allows AXFR") + " " + ui.SubtleStyle.Render("(IXFR is not tested)") + "\n"
+	}
+	for _, ns := range res.Nameservers {
+		var line string
+		switch ns.Status {
+		case collector.ZoneTransferAllowed:
+			count := fmt.Sprintf("%d records", ns.Records)
+			if ns.Truncated {
+				count = fmt.Sprintf("%d+ records, stopped reading", ns.Records)
+			}
+			line = ui.Status(ui.LevelFail, "ALLOWED") + " (" + count + ")"
+		case collector.ZoneTransferRefused:
+			line = ui.Status(ui.LevelOK, "refused") + " " + ui.SubtleStyle.Render(ns.Detail)
+		default:
+			line = ui.Status(ui.LevelWarn, fmt.Sprintf("failed: %v", ns.Error))
+		}
+		s += fmt.Sprintf("  %-28s %s\n", truncate(ns.Nameserver, 28), line)
+	}
+	return s
+}
+
+// renderDNSTrace shows each server asked on the way down from the root:
+// the referral it gave or, last, the answer
+func (m Model) renderDNSTrace() string {
+	if m.LoadingDNSTrace {
+		return "\nDNS Trace: following referrals from the root servers...\n"
+	}
+	res := m.DNSTrace
+	if res == nil {
+		return ""
+	}
+
+	s := fmt.Sprintf("\nDNS Trace (%s %s):\n", res.Domain, res.Type)
+	for _, step := range res.Steps {
+		server := fmt.Sprintf("  %-16s %s (%s)", truncate(step.Zone, 16), step.Server, step.Address)
+		if step.Error != nil {
+			s += ui.Status(ui.LevelFail, fmt.Sprintf("%s %v", server, step.Error)) + "\n"
+			continue
+		}
+		s += fmt.Sprintf("%s %dms\n", server, step.Latency.Milliseconds())
+		switch {
+		case step.Referral != "":
+			glue := ui.SubtleStyle.Render("no glue")
+			if n := len(step.Glue); n > 0 {
+				glue = ui.SubtleStyle.Render(fmt.Sprintf("%d glue addresses", n))
+			}
+			s += fmt.Sprintf("    → %s: %s (%s)\n", step.Referral, strings.Join(step.NS, ", "), glue)
+		case step.Rcode != "NOERROR":
+			s += "    " + ui.Status(ui.LevelWarn, step.Rcode) + "\n"
+		case len(step.Answer) == 0:
+			s += "    " + ui.SubtleStyle.Render("no records of this type (NODATA)") + "\n"
+		default:
+			for _, rec := range step.Answer {
+				s += "    " + rec + "\n"
+			}
+		}
+	}
+	if res.Error != nil {
+		s += "  " + ui.Status(ui.LevelFail, fmt.Sprintf("%v", res.Error)) + "\n"
+	}
+	return s
+}
+
+// renderDNSCache shows whether the repeated answer came from the resolver's
+// cache, with the two TTLs it was told by
+func (m Model) renderDNSCache() string {
+	if m.LoadingDNSCache {
+		return "\nCached: querying twice, a second apart...\n"
+	}
+	res := m.DNSCache
+	if res == nil {
+		return ""
+	}
+	if res.Error != nil {
+		return "\nCached: " + ui.Status(ui.LevelFail, fmt.Sprintf("unknown (%v)", res.Error)) + "\n"
+	}
+	if res.Cached {
+		return fmt.Sprintf("\nCached: yes, %s via %s (TTL %ds, then %ds)\n", res.Domain, res.Server, res.TTL1, res.TTL2)
+	}
+	return fmt.Sprintf("\nCached: no, %s via %s (TTL %ds, then %ds again, uncached or round-robin)\n", res.Domain, res.Server, res.TTL1, res.TTL2)
+}
+
+// renderResolveConnect shows both steps, so it is clear whether the name or
+// the service is at fault
+func (m Model) renderResolveConnect() string {
+	if m.LoadingResolveConnect {
+		return "\nResolve and Connect: resolving, then checking the service...\n"
+	}
+	res := m.ResolveConnect
+	if res == nil {
+		return ""
+	}
+
+	s := fmt.Sprintf("\nResolve and Connect (%s, %s):\n", res.Name, res.App)
+	if res.Stage == "resolve" {
+		return s + "  Resolve: " + ui.Status(ui.LevelFail, fmt.Sprintf("%v", res.Error)) + "\n"
+	}
+	s += fmt.Sprintf("  Resolve: %s %s via %s\n", ui.Status(ui.LevelOK, strings.Join(res.Addresses, ", ")),
+		ui.SubtleStyle.Render(res.LookupLatency.Round(time.Millisecond).String()), res.Server)
+	if res.Error != nil {
+		return s + fmt.Sprintf("  Connect: %s %s\n", res.Address, ui.Status(ui.LevelFail, fmt.Sprintf("%v", res.Error)))
+	}
+	return s + fmt.Sprintf("  Connect: %s %s %s\n", res.Address, ui.Status(ui.LevelOK, res.App+" OK"),
+		ui.SubtleStyle.Render(res.CheckLatency.Round(time.Millisecond).String()))
+}
+
+// renderDNSCompare shows each server's answer to the same query side by
+// side, flagging those that differ from the majority
+func (m Model) renderDNSCompare() string {
+	if m.LoadingDNSCompare {
+		return "\nCompare: querying every server...\n"
+	}
+	res := m.DNSCompare
+	if res == nil {
+		return ""
+	}
+	divergent := 0
+	for _, d := range res.Divergent {
+		if d {
+			divergent++
+		}
+	}
+	s := fmt.Sprintf("\nCompare %s %s across %d servers", res.Type, res.Domain, len(res.Results))
+	switch {
+	case res.NoMajority:
+		s += ": " + ui.Status(ui.LevelWarn, "no majority, the servers split evenly")
+	case divergent > 0:
+		s += ": " + ui.Status(ui.LevelWarn, fmt.Sprintf("%d differ from the majority", divergent))
+	}
+	s += "\n" + ui.SubtleStyle.Render(fmt.Sprintf("  %-20s %-9s %8s  %s", "Server", "Rcode", "Latency", "Answers")) + "\n"
+	for i, r := range res.Results {
+		var status, answers string
+		switch {
+		case r.Error != nil:
+			status = ui.Status(ui.LevelFail, fmt.Sprintf("%-9s", "error"))
+			answers = ui.Status(ui.LevelFail, fmt.Sprintf("%v", r.Error))
+		case r.ResponseCode != "NOERROR":
+			status = ui.Status(ui.LevelWarn, fmt.Sprintf("%-9s", r.ResponseCode))
+			answers = ui.Status(ui.LevelWarn, r.ExtendedError)
+		default:
+			status = fmt.Sprintf("%-9s", r.ResponseCode)
+			answers = strings.Join(res.Answers(i), ", ")
+			switch {
+			case res.Divergent[i]:
+				answers = ui.Status(ui.LevelFail, answers+" (differs)")
+			case answers == "":
+				answers = ui.SubtleStyle.Render("(no data)")
+			}
+		}
+		s += fmt.Sprintf("  %-20s %s %8s  %s\n", truncate(res.Servers[i], 20), status, r.Latency.Round(time.Millisecond), answers)
+	}
+	return s
+}
+
+// renderDNSBatch tabulates a batch, one line per name and type
+func (m Model) renderDNSBatch() string {
+	if m.LoadingDNSBatch {
+		return "\nBatch: running queries...\n"
+	}
+	res := m.DNSBatch
+	if res == nil {
+		return ""
+	}
+
+	if res.Error != nil {
+		return "\nBatch:\n  " + ui.Status(ui.LevelFail, fmt.Sprintf("%v", res.Error)) + "\n"
+	}
+	s := fmt.Sprintf("\nBatch via %s: %d queries, %d failed\n", res.Server, len(res.Entries), res.Failed())
+	s += ui.SubtleStyle.Render(fmt.Sprintf("  %-32s %-6s %-9s %8s  %s", "Name", "Type", "Rcode", "Latency", "Answers")) + "\n"
+	for _, e := range res.Entries {
+		var status, answers string
+		switch {
+		case e.Result.Error != nil:
+			status = ui.Status(ui.LevelFail, fmt.Sprintf("%-9s", "error"))
+			answers = ui.Status(ui.LevelFail, fmt.Sprintf("%v", e.Result.Error))
+		case e.Result.ResponseCode != "NOERROR":
+			status = ui.Status(ui.LevelWarn, fmt.Sprintf("%-9s", e.Result.ResponseCode))
+			answers = ui.Status(ui.LevelWarn, e.Result.ExtendedError)
+		default:
+			status = fmt.Sprintf("%-9s", e.Result.ResponseCode)
+			answers = strings.Join(e.Answers(), ", ")
+			if answers == "" {
+				answers = ui.SubtleStyle.Render("(no data)")
+			}
+		}
+		s += fmt.Sprintf("  %-32s %-6s %s %8s  %s\n", truncate(e.Query.Domain, 32), e.Query.Type, status,
+			e.Result.Latency.Round(time.Millisecond), answers)
+	}
+	return s
+}
+
+// renderPortScan lists the verdict per scanned port, open ports first in color
+func (m Model) renderPortScan() string {
+	if m.LoadingPortScan {
+		return "\nPort Scan: connecting to common service ports...\n"
+	}
+	res := m.PortScan
+	if res == nil {
+		return ""
+	}
+
+	if res.Error != nil {
+		return fmt.Sprintf("\nPort Scan (%s):\n  %s\n", res.Target, ui.Status(ui.LevelFail, fmt.Sprintf("%v", res.Error)))
+	}
+	s := fmt.Sprintf("\nPort Scan (%s, %s): %d of %d open\n", res.Target, res.Address, len(res.Open()), len(res.Ports))
+	for _, p := range res.Ports {
+		var line string
+		switch p.Reachability {
+		case collector.ReachOpen:
+			line = ui.Status(ui.LevelOK, "open") + " " + ui.SubtleStyle.Render(p.Latency.Round(time.Microsecond).String())
+		case collector.ReachClosed:
+			line = ui.Status(ui.LevelUnknown, "closed")
+		case collector.ReachFiltered:
+			line = ui.Status(ui.LevelWarn, "filtered")
+		default:
+			line = ui.Status(ui.LevelFail, fmt.Sprintf("%v", p.Error))
+		}
+		if p.Banner != "" {
+			line += "  " + ui.SubtleStyle.Render(truncate(p.Banner, 60))
+		}
+		s += fmt.Sprintf("  %-5d %-14s %s\n", p.Port, p.Service, line)
+	}
+	return s
+}
+
+func (m Model) renderDNSConsistency() string {
+	if m.LoadingDNSConsistency {
+		return fmt.Sprintf("\nConsistency: sending %d queries...\n", dnsConsistencyQueries)
+	}
+	res := m.DNSConsistency
+	if res == nil {
+		return ""
+	}
+
+	s := fmt.Sprintf("\nConsistency (%s via %s, %d queries):\n", res.Domain, res.Server, res.Queries)
+	switch {
+	case len(res.AnswerSets) == 0:
+		s += "  " + ui.Status(ui.LevelFail, fmt.Sprintf("All queries failed: %v", res.Error)) + "\n"
+		return s
+	case res.Stable():
+		s += "  " + ui.SubtitleStyle.Render("Stable: every query returned the same answers") + "\n"
+	case len(res.AnswerSets) > 1:
+		s += "  " + ui.Status(ui.LevelWarn, fmt.Sprintf("Rotating: %d distinct answer sets (round-robin/CDN)", len(res.AnswerSets))) + "\n"
+	}
+	if res.Failures > 0 {
+		s += "  " + ui.Status(ui.LevelFail, fmt.Sprintf("Flapping: %d/%d queries failed (last: %v)", res.Failures, res.Queries, res.Error)) + "\n"
+	}
+
+	for i, set := range res.AnswerSets {
+		s += fmt.Sprintf("  Set %d (%dx): %s\n", i+1, set.Count, strings.Join(set.Answers, ", "))
+	}
 	return s
 }
 
@@ -835,9 +4864,9 @@ func (m Model) renderTunnels() string {
 	s += ui.DividerStyle.Render(strings.Repeat("-", len(header))) + "\n"
 
 	for _, res := range m.TunnelResults {
-		statusStyle := ui.SubtitleStyle
+		level := ui.LevelOK
 		if res.Status != "OK" {
-			statusStyle = ui.ErrorStyle
+			level = ui.LevelFail
 		}
 
 		latency := fmt.Sprintf("%dms", res.Latency.Milliseconds())
@@ -850,7 +4879,7 @@ func (m Model) renderTunnels() string {
 			wApp, res.App,
 			wTrans, res.Transport,
 			wTarget, truncate(res.Target, wTarget-1),
-			wStatus, statusStyle.Render(res.Status),
+			wStatus, ui.Status(level, res.Status),
 			wLatency, latency,
 		)
 		s += row + "\n"
@@ -860,11 +4889,71 @@ func (m Model) renderTunnels() string {
 			errMsg := fmt.Sprintf("  └─ %v", res.Error)
 			s += ui.SubtleStyle.Render(errMsg) + "\n"
 		}
+		if res.DSCPError != nil {
+			s += "  └─ " + ui.Status(ui.LevelWarn, fmt.Sprintf("%v, sent unmarked", res.DSCPError)) + "\n"
+		}
+		if res.CertInfo != nil {
+			s += renderTunnelCert(res.CertInfo, time.Now()) + "\n"
+		}
+	}
+
+	return s
+}
+
+// dnsInputWidth sizes the DNS text inputs so the form line (label, input
+// and key hint, ~50 columns) fits within the terminal width
+func dnsInputWidth(termWidth int) int {
+	w := termWidth - 50
+	if w < 10 {
+		w = 10
+	}
+	if w > 60 {
+		w = 60
+	}
+	return w
+}
+
+// divider renders a horizontal rule, tolerating non-positive widths
+func divider(width int) string {
+	if width < 0 {
+		width = 0
+	}
+	return ui.DividerStyle.Render(strings.Repeat("-", width))
+}
+
+func onOff(b bool) string {
+	if b {
+		return "on"
 	}
+	return "off"
+}
 
+// orDash stands in for an unknown value
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
 	return s
 }
 
+// indentedStatus renders an indented line with ui.Status, the symbol goes
+// after the indentation
+func indentedStatus(level ui.Level, line string) string {
+	text := strings.TrimLeft(line, " ")
+	return line[:len(line)-len(text)] + ui.Status(level, text)
+}
+
+// padStyled pads a styled string to width visible columns, which %-*s
+// cannot do as it counts the escape codes
+func padStyled(s string, width int) string {
+	return s + strings.Repeat(" ", max(0, width-lipgloss.Width(s)))
+}
+
+// indent prefixes every line of s
+func indent(s, prefix string) string {
+	return prefix + strings.ReplaceAll(s, "\n", "\n"+prefix)
+}
+
 func truncate(s string, max int) string {
 	if len(s) > max {
 		return s[:max-3] + "..."
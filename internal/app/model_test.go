@@ -0,0 +1,1695 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+	"github.com/sysatom/lnd/internal/collector"
+	"github.com/sysatom/lnd/internal/config"
+	"github.com/sysatom/lnd/internal/report"
+	"github.com/sysatom/lnd/internal/ui"
+)
+
+func newTestModel() Model {
+	m := NewModel(config.Default())
+	updated, _ := m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	return updated.(Model)
+}
+
+func keyRunes(s string) tea.KeyMsg {
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)}
+}
+
+func TestDashboard_IdleToggle(t *testing.T) {
+	m := newTestModel()
+	m.ActiveTab = TabDashboard
+	m.Traffic = collector.TrafficStats{
+		Interfaces: map[string]collector.InterfaceTraffic{
+			"eth0":  {RxBytes: 1000, RxRate: 2048, Up: true},
+			"wlan0": {Up: true},  // Up but never carried traffic
+			"eth1":  {Up: false}, // Down
+		},
+	}
+
+	out := m.renderDashboard()
+	if !strings.Contains(out, "eth0") {
+		t.Error("active interface should always render")
+	}
+	if strings.Contains(out, "wlan0") || strings.Contains(out, "eth1") {
+		t.Error("idle and down interfaces should be hidden by default")
+	}
+
+	updated, _ := m.Update(keyRunes("i"))
+	m = updated.(Model)
+	if !m.ShowIdleInterfaces {
+		t.Fatal("'i' should enable showing idle interfaces")
+	}
+
+	out = m.renderDashboard()
+	for _, want := range []string{"eth0", "wlan0", "(up, idle)", "eth1", "(down)"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in dashboard with idle links shown", want)
+		}
+	}
+}
+
+func TestRateUnits_AcrossTabs(t *testing.T) {
+	m := newTestModel()
+	m.SpeedTest = &collector.SpeedTestResult{DownloadMbps: 8, Downloaded: 1 << 20}
+	m.Bufferbloat = &collector.BufferbloatResult{Downloaded: 1 << 20}
+
+	views := func() string {
+		return m.renderSpeedTest() + m.renderBufferbloat(*m.Bufferbloat)
+	}
+	if out := views(); strings.Count(out, "Transferred 1.00 MB down") != 2 {
+		t.Errorf("byte mode volumes not humanized:\n%s", out)
+	}
+
+	m.RateInBits = true
+	if out := views(); strings.Count(out, "Transferred 8.39 Mb down") != 2 {
+		t.Errorf("bit mode volumes not in bits:\n%s", out)
+	}
+}
+
+func TestDNSTab_Resize(t *testing.T) {
+	m := newTestModel()
+	m.ActiveTab = TabDNS
+
+	for _, width := range []int{60, 3, 0} {
+		updated, _ := m.Update(tea.WindowSizeMsg{Width: width, Height: 30})
+		m = updated.(Model)
+
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Fatalf("render at width %d panicked: %v", width, r)
+				}
+			}()
+			m.View()
+			m.renderDNS()
+		}()
+
+		if m.DNSInput.Width < 10 {
+			t.Errorf("width %d: DNS input width = %d, want >= 10", width, m.DNSInput.Width)
+		}
+	}
+
+	updated, _ := m.Update(tea.WindowSizeMsg{Width: 60, Height: 30})
+	m = updated.(Model)
+	if out := m.View(); !strings.Contains(out, "Domain/IP") {
+		t.Error("DNS form should render at the 60-column minimum")
+	}
+}
+
+func TestStatusLine_ErrorHistory(t *testing.T) {
+	m := newTestModel()
+
+	if !strings.Contains(m.statusLine(), "No errors") {
+		t.Errorf("expected empty status line, got %q", m.statusLine())
+	}
+
+	updated, _ := m.Update(KernelMsg{Error: fmt.Errorf("netlink: permission denied")})
+	m = updated.(Model)
+	updated, _ = m.Update(TrafficMsg{Error: fmt.Errorf("read /proc/net/dev: no such file")})
+	m = updated.(Model)
+
+	status := m.statusLine()
+	if !strings.Contains(status, "Traffic") || !strings.Contains(status, "/proc/net/dev") {
+		t.Errorf("status line should show the latest error, got %q", status)
+	}
+	if !strings.Contains(m.View(), "/proc/net/dev") {
+		t.Error("status line should be part of the view")
+	}
+
+	if len(m.ErrorLog) != 2 || m.ErrorLog[0].Source != "Kernel" {
+		t.Fatalf("expected kernel and traffic errors in history, got %+v", m.ErrorLog)
+	}
+
+	// Repeated errors collapse into one entry
+	updated, _ = m.Update(TrafficMsg{Error: fmt.Errorf("read /proc/net/dev: no such file")})
+	m = updated.(Model)
+	if len(m.ErrorLog) != 2 || m.ErrorLog[1].Count != 2 {
+		t.Errorf("expected repeated error to be collapsed, got %+v", m.ErrorLog)
+	}
+
+	// History is bounded
+	for i := 0; i < maxErrorLog+10; i++ {
+		m.recordError("Test", fmt.Errorf("error %d", i))
+	}
+	if len(m.ErrorLog) != maxErrorLog {
+		t.Errorf("error log length = %d, want %d", len(m.ErrorLog), maxErrorLog)
+	}
+
+	// Ctrl+L opens the log
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyCtrlL})
+	m = updated.(Model)
+	if !m.ShowErrorLog || !strings.Contains(m.View(), "Error Log") {
+		t.Error("ctrl+l should open the error log")
+	}
+}
+
+func TestHealthSummary_Degraded(t *testing.T) {
+	m := newTestModel()
+	m.LoadingConn = false
+	m.LoadingNat = false
+	m.Connectivity = collector.ConnectivityStats{
+		Targets: map[string]collector.PingResult{
+			"8.8.8.8": {Target: "8.8.8.8", AvgRtt: 10 * time.Millisecond},
+			"1.1.1.1": {Target: "1.1.1.1", AvgRtt: 12 * time.Millisecond},
+		},
+	}
+	m.NatInfo = []collector.NatInfo{{Target: "stun", NatType: collector.NatSymmetric}}
+
+	levels := func() map[string]healthItem {
+		items := make(map[string]healthItem)
+		for _, item := range m.healthSummary() {
+			items[item.Label] = item
+		}
+		return items
+	}
+
+	items := levels()
+	if items["NET"].Level != healthOK || items["DNS"].Level != healthOK {
+		t.Errorf("expected healthy NET and DNS, got %+v", items)
+	}
+	if items["NAT"].Level != healthWarn || items["NAT"].Value != string(collector.NatSymmetric) {
+		t.Errorf("symmetric NAT should be a warning, got %+v", items["NAT"])
+	}
+
+	// One target goes down
+	m.Connectivity.Targets["1.1.1.1"] = collector.PingResult{Target: "1.1.1.1", PacketLoss: 100, Error: fmt.Errorf("timeout")}
+	items = levels()
+	if items["NET"].Level != healthWarn || items["NET"].Value != "1/2 DOWN" {
+		t.Errorf("down target should flip NET to a warning, got %+v", items["NET"])
+	}
+
+	// Slow local resolver and high retransmissions
+	m.Connectivity.DNS.LocalResolverTime = 300 * time.Millisecond
+	updated, _ := m.Update(KernelMsg{TCPRetransRate: 4.2})
+	m = updated.(Model)
+	items = levels()
+	if items["DNS"].Level != healthWarn {
+		t.Errorf("slow resolver should flag DNS, got %+v", items["DNS"])
+	}
+	if items["RETRANS"].Level != healthWarn || items["RETRANS"].Value != "4.2%" {
+		t.Errorf("high retransmissions should be a warning, got %+v", items["RETRANS"])
+	}
+
+	if !strings.Contains(m.View(), "NET:1/2 DOWN") {
+		t.Error("header should contain the health summary")
+	}
+
+	// Narrow widths drop trailing items instead of wrapping
+	if out := m.renderHealthSummary(12); strings.Contains(out, "DNS") || !strings.Contains(out, "NET") {
+		t.Errorf("narrow summary = %q, want only NET", out)
+	}
+}
+
+func TestDNSTab_SRVFollowUp(t *testing.T) {
+	m := newTestModel()
+	m.ActiveTab = TabDNS
+
+	records := []collector.SRVRecord{{Priority: 10, Weight: 5, Port: 5060, Target: "sip.example.com"}}
+	updated, cmd := m.Update(DNSMsg{Records: []string{"_sip._tcp.example.com. 60 IN SRV 10 5 5060 sip.example.com."}, SRV: records})
+	m = updated.(Model)
+	if !m.LoadingSRVChecks || cmd == nil {
+		t.Fatal("SRV answer should trigger a reachability check")
+	}
+
+	updated, _ = m.Update(SRVCheckMsg{{Record: records[0], Reachable: true}})
+	m = updated.(Model)
+	if out := m.renderDNS(); !strings.Contains(out, "sip.example.com:5060") || !strings.Contains(out, "OK") {
+		t.Errorf("SRV check result not rendered:\n%s", out)
+	}
+}
+
+func TestSaveConfig_StatusLine(t *testing.T) {
+	cfg := config.Default()
+	cfg.Path = filepath.Join(t.TempDir(), "lnd.yaml")
+	cfg.DNSServers = []config.DNSServerConfig{{Name: "Lab", Address: "192.0.2.53:53", Proto: "UDP"}}
+	m := NewModel(cfg)
+	updated, _ := m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	m = updated.(Model)
+
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyCtrlS})
+	if cmd == nil {
+		t.Fatal("expected a save command")
+	}
+	updated, _ = m.Update(cmd())
+	m = updated.(Model)
+
+	if !strings.Contains(m.statusLine(), "Config saved to "+cfg.Path) {
+		t.Errorf("status line = %q", m.statusLine())
+	}
+	saved, err := config.Load(cfg.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved.DNSServers) != 1 || saved.DNSServers[0].Name != "Lab" {
+		t.Errorf("saved DNS servers = %+v", saved.DNSServers)
+	}
+}
+
+func TestDNSServerForm_AddServer(t *testing.T) {
+	m := newTestModel()
+	m.ActiveTab = TabDNS
+	builtin := len(m.DNSServers)
+
+	send := func(msg tea.KeyMsg) {
+		updated, _ := m.Update(msg)
+		m = updated.(Model)
+	}
+	typeText := func(s string) {
+		for _, r := range s {
+			send(keyRunes(string(r)))
+		}
+	}
+
+	send(tea.KeyMsg{Type: tea.KeyCtrlN})
+	if m.DNSForm == nil {
+		t.Fatal("ctrl+n should open the form")
+	}
+
+	// Submitting without a name fails
+	send(tea.KeyMsg{Type: tea.KeyEnter})
+	if m.DNSForm == nil || m.DNSForm.Err == nil {
+		t.Fatal("expected a validation error for the empty form")
+	}
+
+	typeText("Quad9")
+	send(tea.KeyMsg{Type: tea.KeyTab})
+	typeText("dns.google")
+	send(tea.KeyMsg{Type: tea.KeyTab})
+	send(tea.KeyMsg{Type: tea.KeyRight}) // UDP -> TCP
+	send(tea.KeyMsg{Type: tea.KeyRight}) // TCP -> DoT
+	send(tea.KeyMsg{Type: tea.KeyRight}) // DoT -> DoH
+
+	// A bare host is not a valid DoH address
+	send(tea.KeyMsg{Type: tea.KeyEnter})
+	if m.DNSForm == nil || m.DNSForm.Err == nil {
+		t.Fatal("expected a validation error for a DoH address without https://")
+	}
+
+	send(tea.KeyMsg{Type: tea.KeyLeft}) // Back to DoT
+	send(tea.KeyMsg{Type: tea.KeyEnter})
+	if m.DNSForm != nil {
+		t.Fatalf("form should close after a valid submit, error: %v", m.DNSForm.Err)
+	}
+
+	if len(m.DNSServers) != builtin+1 {
+		t.Fatalf("expected %d servers, got %d", builtin+1, len(m.DNSServers))
+	}
+	added := m.DNSServers[m.SelectedDNSServer]
+	if added.Name != "Quad9" || added.Address != "dns.google:853" || added.Proto != collector.ProtoDoT {
+		t.Errorf("selected server = %+v", added)
+	}
+	if m.DNSServers[len(m.DNSServers)-1].Name != "Custom" {
+		t.Error("Custom should stay the last server")
+	}
+	if dnsProtocols[m.SelectedProtocol] != collector.ProtoDoT {
+		t.Errorf("protocol selector = %s, want DoT", dnsProtocols[m.SelectedProtocol])
+	}
+	if got := m.currentConfig().DNSServers; len(got) != 1 || got[0].Name != "Quad9" {
+		t.Errorf("config DNS servers = %+v", got)
+	}
+
+	// Names must be unique
+	send(tea.KeyMsg{Type: tea.KeyCtrlN})
+	typeText("google")
+	send(tea.KeyMsg{Type: tea.KeyTab})
+	typeText("8.8.4.4")
+	send(tea.KeyMsg{Type: tea.KeyEnter})
+	if m.DNSForm == nil || m.DNSForm.Err == nil || !strings.Contains(m.DNSForm.Err.Error(), "already exists") {
+		t.Errorf("expected duplicate name error, got %+v", m.DNSForm)
+	}
+}
+
+func TestDHCPServers_Selectable(t *testing.T) {
+	m := newTestModel()
+	builtin := m.builtinDNSServers
+	last := len(m.DNSServers) - 1
+	m.SelectedDNSServer = last // Custom
+
+	updated, _ := m.Update(DHCPMsg{
+		Leases: []collector.DHCPLease{{Interface: "eth0", Gateway: "192.168.1.1", DNSServers: []string{"192.168.1.1", "8.8.8.8"}}},
+		Unused: []string{"192.168.1.1"},
+	})
+	m = updated.(Model)
+
+	// 8.8.8.8:53 is already built in, only the router is added
+	if len(m.DNSServers) != last+2 {
+		t.Fatalf("expected one DHCP server to be added, got %d servers", len(m.DNSServers))
+	}
+	added := m.DNSServers[builtin]
+	if added.Address != "192.168.1.1:53" || !strings.Contains(added.Name, "eth0") {
+		t.Errorf("DHCP server = %+v", added)
+	}
+	if m.DNSServers[m.SelectedDNSServer].Name != "Custom" {
+		t.Error("selection should stay on the same server")
+	}
+	if len(m.currentConfig().DNSServers) != 0 {
+		t.Error("DHCP servers must not be saved to the config")
+	}
+	m.ActiveTab = TabDNS
+	if !strings.Contains(m.renderDNS(), "DHCP offered 192.168.1.1") {
+		t.Error("expected the unused DHCP resolver warning")
+	}
+}
+
+func TestPingHistory_Bounded(t *testing.T) {
+	m := newTestModel()
+	for i := 0; i < maxPingHistory+5; i++ {
+		res := collector.PingResult{Target: "1.1.1.1", AvgRtt: time.Duration(i) * time.Millisecond}
+		if i == maxPingHistory+4 {
+			res = collector.PingResult{Target: "1.1.1.1", PacketLoss: 100, Error: fmt.Errorf("timeout")}
+		}
+		updated, _ := m.Update(ConnectivityMsg{Targets: map[string]collector.PingResult{"1.1.1.1": res}})
+		m = updated.(Model)
+	}
+
+	history := m.PingHistory["1.1.1.1"]
+	if len(history) != maxPingHistory {
+		t.Fatalf("history length = %d, want %d", len(history), maxPingHistory)
+	}
+	if history[0] != 5 {
+		t.Errorf("oldest sample = %v, want 5 (older ones dropped)", history[0])
+	}
+	if !math.IsNaN(history[len(history)-1]) {
+		t.Errorf("lost sample should be NaN, got %v", history[len(history)-1])
+	}
+	if !strings.Contains(m.renderConnectivity(), "Latency Heatmap (one cell per 5s") {
+		t.Error("expected the heatmap in the connectivity tab")
+	}
+	m.Power = collector.PowerBattery
+	want := fmt.Sprintf("one cell per %s,", defaultBatteryScale*connectivityRefreshInterval)
+	if out := m.renderConnectivity(); !strings.Contains(out, want) {
+		t.Errorf("on battery the heatmap label should read %q:\n%s", want, out)
+	}
+}
+
+func TestTrafficHistory_TrimmedPerInterface(t *testing.T) {
+	m := newTestModel()
+	m.Width = 100
+	for i := 0; i < maxTrafficHistory+5; i++ {
+		updated, _ := m.Update(TrafficMsg{Interfaces: map[string]collector.InterfaceTraffic{
+			"eth0":  {Up: true, RxRate: float64(i), TxRate: 1, RxBytes: 1},
+			"wlan0": {Up: true, RxRate: 2, TxRate: 2, RxBytes: 1},
+		}})
+		m = updated.(Model)
+	}
+	if h := m.RxHistory["eth0"]; len(h) != maxTrafficHistory || h[0] != 5 {
+		t.Fatalf("eth0 history: %d samples starting at %v, want %d starting at 5", len(h), h[0], maxTrafficHistory)
+	}
+	if !strings.Contains(m.renderDashboard(), "    RX ") {
+		t.Error("expected RX/TX sparklines on the dashboard")
+	}
+
+	// A partial collection keeps the history of interfaces it missed
+	updated, _ := m.Update(TrafficMsg{Interfaces: map[string]collector.InterfaceTraffic{"eth0": {Up: true}}, Partial: true})
+	m = updated.(Model)
+	if _, ok := m.RxHistory["wlan0"]; !ok {
+		t.Error("partial results should not drop interfaces")
+	}
+	updated, _ = m.Update(TrafficMsg{Interfaces: map[string]collector.InterfaceTraffic{"eth0": {Up: true}}})
+	m = updated.(Model)
+	if _, ok := m.RxHistory["wlan0"]; ok {
+		t.Error("the history of a vanished interface should be dropped")
+	}
+	if _, ok := m.TxHistory["wlan0"]; ok {
+		t.Error("the TX history of a vanished interface should be dropped")
+	}
+}
+
+func TestSnapshot_Bundle(t *testing.T) {
+	m := newTestModel()
+	m.HostInfo = collector.HostInfo{Hostname: "testhost"}
+	m.DHCP = &collector.DHCPInfo{Unused: []string{"192.168.1.1"}}
+	m.DNSCache = &collector.DNSCacheResult{Domain: "cached.example.com", Cached: true, TTL1: 300, TTL2: 299}
+	m.recordError("Ping", fmt.Errorf("network unreachable"))
+
+	s := m.snapshot()
+	if s.Config == nil || s.Version == "" {
+		t.Fatal("snapshot is missing config or version")
+	}
+	out, err := report.Bundle(s, report.BundleOptions{})
+	if err != nil {
+		t.Fatalf("Bundle() error = %v", err)
+	}
+	for _, want := range []string{"testhost", "192.168.1.1", "cached.example.com", "Ping: network unreachable"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("bundle is missing %q", want)
+		}
+	}
+}
+
+func TestInterfaces_ActiveInterface(t *testing.T) {
+	lo := ""
+	ifaces, _ := net.Interfaces()
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 && iface.Flags&net.FlagUp != 0 {
+			lo = iface.Name
+		}
+	}
+	if lo == "" {
+		t.Skip("no loopback interface")
+	}
+
+	m := newTestModel()
+	m.ActiveTab = TabInterfaces
+	m.LoadingSystem = false
+	m.HostInfo = collector.HostInfo{Interfaces: []collector.InterfaceInfo{{Name: "lnd-missing0"}, {Name: lo}}}
+
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = updated.(Model)
+	if m.connCollector.Source.Name() != "" || len(m.ErrorLog) == 0 {
+		t.Fatal("selecting a missing interface should fail and be logged")
+	}
+
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	m = updated.(Model)
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = updated.(Model)
+	if got := m.connCollector.Source.Name(); got != lo {
+		t.Fatalf("active interface = %q, want %q", got, lo)
+	}
+	if m.dnsCollector.Source.Name() != lo {
+		t.Error("the DNS collector should share the active interface")
+	}
+	if !strings.Contains(m.renderInterfaces(), "[active]") {
+		t.Error("the active interface should be marked")
+	}
+
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = updated.(Model)
+	if m.connCollector.Source.Name() != "" {
+		t.Error("enter on the active interface should clear it")
+	}
+}
+
+func TestAbout_SelfTest(t *testing.T) {
+	m := newTestModel()
+	m.ActiveTab = TabAbout
+	if !strings.Contains(m.renderAbout(), "Checking capabilities") {
+		t.Error("self-test should show as running until its result arrives")
+	}
+
+	updated, _ := m.Update(SelfTestMsg{
+		{Name: collector.SelfCheckICMP, Detail: "needs root or CAP_NET_RAW, pings fall back to TCP connects"},
+		{Name: collector.SelfCheckDNS, OK: true, Detail: "system resolver answers"},
+	})
+	m = updated.(Model)
+	out := m.renderAbout()
+	for _, want := range []string{"[✗] Raw ICMP sockets", "CAP_NET_RAW", "[✓]", "DNS resolution"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("About tab is missing %q", want)
+		}
+	}
+}
+
+func TestDNSTab_WildcardToggle(t *testing.T) {
+	m := newTestModel()
+	m.ActiveTab = TabDNS
+	if m.dnsQueryOptions().Wildcard {
+		t.Fatal("the wildcard probe should be off by default")
+	}
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("w"), Alt: true})
+	m = updated.(Model)
+	if !m.dnsQueryOptions().Wildcard {
+		t.Error("Alt+w should turn the wildcard probe on")
+	}
+	if out := m.renderDNS(); !strings.Contains(out, "Wildcard:  on") {
+		t.Errorf("toggle not shown:\n%s", out)
+	}
+}
+
+func TestDNSTab_ResolveConnectError(t *testing.T) {
+	m := newTestModel()
+	m.LoadingResolveConnect = true
+	updated, _ := m.Update(ResolveConnectMsg{Name: "svc.test", App: "tls", Stage: "connect", Error: errors.New("connection refused")})
+	m = updated.(Model)
+	if len(m.ErrorLog) != 1 || m.ErrorLog[0].Source != "Resolve and Connect svc.test" {
+		t.Errorf("resolve and connect failure not in the error log: %+v", m.ErrorLog)
+	}
+}
+
+func TestDashboard_TrafficDisabled(t *testing.T) {
+	cfg := config.Default()
+	cfg.Collectors = map[string]bool{config.CollectorTraffic: false}
+	m := NewModel(cfg)
+
+	out := m.renderDashboard()
+	for _, unwanted := range []string{"Traffic (Last", "idle and down links"} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("disabled traffic collector still renders %q:\n%s", unwanted, out)
+		}
+	}
+}
+
+func TestDashboard_RateUnitsToggle(t *testing.T) {
+	m := newTestModel()
+	m.ActiveTab = TabDashboard
+	if out := m.renderDashboard(); !strings.Contains(out, "'u' to show rates in bits") {
+		t.Errorf("units hint missing:\n%s", out)
+	}
+
+	updated, _ := m.Update(keyRunes("u"))
+	m = updated.(Model)
+	if !m.RateInBits {
+		t.Fatal("'u' should switch the rates to bits")
+	}
+	if out := m.renderDashboard(); !strings.Contains(out, "'u' to show rates in bytes") {
+		t.Errorf("units hint not switched:\n%s", out)
+	}
+
+	// Without the traffic collector there are no rates or interrupts to show
+	cfg := config.Default()
+	cfg.Collectors = map[string]bool{config.CollectorTraffic: false}
+	m = NewModel(cfg)
+	m.Softirqs = collector.SoftirqStats{CPUs: []collector.CPUNetLoad{{CPU: 0, NetRXRate: 100}}}
+	out := m.renderDashboard()
+	for _, unwanted := range []string{"Network interrupts", "'u' to show rates"} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("disabled traffic collector still renders %q:\n%s", unwanted, out)
+		}
+	}
+}
+
+func TestNewModel_DisabledCollectors(t *testing.T) {
+	initCmds := func(m Model) int {
+		return len(m.Init()().(tea.BatchMsg))
+	}
+	enabled := NewModel(config.Default())
+
+	cfg := config.Default()
+	cfg.Collectors = map[string]bool{config.CollectorSTUN: false, config.CollectorKernel: false}
+	m := NewModel(cfg)
+
+	if m.natCollector != nil || m.kernelCollector != nil {
+		t.Error("disabled collectors should not be instantiated")
+	}
+	if got, want := initCmds(m), initCmds(enabled)-1; got != want {
+		t.Errorf("Init() scheduled %d commands, want %d without STUN", got, want)
+	}
+	if m.LoadingNat {
+		t.Error("a disabled collector should not show as loading")
+	}
+
+	updated, _ := m.Update(TickMsg(time.Now()))
+	if updated.(Model).LoadingKernel {
+		t.Error("the tick should not fetch a disabled kernel collector")
+	}
+
+	if slices.Contains(m.Tabs, TabKernel) || !slices.Contains(m.Tabs, TabDNS) {
+		t.Errorf("Tabs = %v, want Kernel hidden and DNS shown", m.Tabs)
+	}
+	m.ActiveTab = TabTunnels
+	m.switchTab(1)
+	if m.ActiveTab != TabAbout {
+		t.Errorf("switching past Tunnels should skip the hidden Kernel tab, got %d", m.ActiveTab)
+	}
+}
+
+func TestNewModel_PingOptions(t *testing.T) {
+	cfg := config.Default()
+	cfg.Ping = config.PingConfig{Count: 5, Interval: 200 * time.Millisecond, Timeout: time.Second}
+	m := NewModel(cfg)
+
+	want := m.connCollector.PingOptions
+	if want.Count != 5 || want.Interval != 200*time.Millisecond || want.Timeout != time.Second {
+		t.Fatalf("connectivity ping options = %+v", want)
+	}
+	for name, got := range map[string]collector.PingOptions{
+		"URL diagnosis": m.urlDiagnoser.PingOptions,
+		"matrix":        m.matrixCollector.PingOptions,
+		"bufferbloat":   m.bufferbloat.PingOptions,
+		"speed test":    m.speedTest.PingOptions,
+	} {
+		if got != want {
+			t.Errorf("%s pings with %+v, want the configured %+v", name, got, want)
+		}
+	}
+}
+
+func TestKernel_PartialResults(t *testing.T) {
+	m := newTestModel()
+	updated, _ := m.Update(KernelMsg(collector.KernelStats{
+		TCPRetransRate: 2.5,
+		Error:          fmt.Errorf("panic in KernelCollector: boom"),
+		Partial:        true,
+	}))
+	out := updated.(Model).renderKernel()
+	if !strings.Contains(out, "Partial results") || !strings.Contains(out, "2.50%") {
+		t.Errorf("partial kernel stats should show the data gathered and a notice:\n%s", out)
+	}
+}
+
+func TestKernel_Conntrack(t *testing.T) {
+	m := newTestModel()
+	if strings.Contains(m.renderKernel(), "Conntrack") {
+		t.Error("conntrack should be hidden when nf_conntrack is not loaded")
+	}
+	updated, _ := m.Update(KernelMsg(collector.KernelStats{ConntrackCount: 60000, ConntrackMax: 65536}))
+	out := updated.(Model).renderKernel()
+	if !strings.Contains(out, "Entries: 60000 / 65536 (92%)") || !strings.Contains(out, "new connections are dropped") {
+		t.Errorf("conntrack pressure not flagged:\n%s", out)
+	}
+}
+
+func TestKernel_TopSockets(t *testing.T) {
+	m := newTestModel()
+	m.ActiveTab = TabKernel
+	if out := m.renderKernel(); !strings.Contains(out, "Press 's' to list the sockets") {
+		t.Errorf("the socket list should be behind a key press:\n%s", out)
+	}
+
+	updated, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("s")})
+	m = updated.(Model)
+	if !m.ShowTopSockets || !m.LoadingTopSockets || cmd == nil {
+		t.Fatal("'s' should show and fetch the top sockets")
+	}
+	updated, _ = m.Update(TopSocketsMsg{
+		{Local: "192.0.2.10:50000", Remote: "198.51.100.3:443", RTT: 250 * time.Millisecond, RTTVar: 30 * time.Millisecond, Retrans: 12},
+		{Local: "192.0.2.10:50001", Remote: "198.51.100.2:22", RTT: 1500 * time.Microsecond},
+	})
+	m = updated.(Model)
+	out := m.renderKernel()
+	for _, want := range []string{"Top 10 Sockets", "198.51.100.3:443", "250.0ms", "1.5ms", "12"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("top sockets missing %q:\n%s", want, out)
+		}
+	}
+
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("s")})
+	if out := updated.(Model).renderKernel(); strings.Contains(out, "198.51.100.3") {
+		t.Errorf("'s' again should hide the list:\n%s", out)
+	}
+
+	// Keys the socket list does not use still reach the tab switching
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyRight})
+	if updated.(Model).ActiveTab == TabKernel {
+		t.Error("right should leave the Kernel tab while the socket list is shown")
+	}
+}
+
+func TestKernel_ListenSockets(t *testing.T) {
+	m := newTestModel()
+	m.ActiveTab = TabKernel
+	if out := m.renderKernel(); !strings.Contains(out, "Press 'l' to list the listening sockets") {
+		t.Errorf("the listening sockets should be behind a key press:\n%s", out)
+	}
+
+	updated, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("l")})
+	m = updated.(Model)
+	if !m.ShowListenSockets || !m.LoadingListenSockets || cmd == nil {
+		t.Fatal("'l' should show and fetch the listening sockets")
+	}
+	updated, _ = m.Update(ListenSocketsMsg{Sockets: []collector.ListenSocket{
+		{Proto: "tcp", Addr: "0.0.0.0", Port: 22, PID: 812, Process: "sshd"},
+		{Proto: "tcp", Addr: "::", Port: 443},
+	}})
+	out := updated.(Model).renderKernel()
+	for _, want := range []string{"Listening Sockets", "0.0.0.0:22", "sshd (812)", "[::]:443"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("listening sockets missing %q:\n%s", want, out)
+		}
+	}
+	if s := updated.(Model).snapshot(); s.ListenSockets == nil || len(s.ListenSockets.Sockets) != 2 {
+		t.Errorf("listening sockets not in the snapshot: %+v", s.ListenSockets)
+	}
+
+	updated, _ = m.Update(ListenSocketsMsg{Error: errors.New("tcp sockets: permission denied")})
+	if out := updated.(Model).renderKernel(); !strings.Contains(out, "permission denied") {
+		t.Errorf("collection error not shown:\n%s", out)
+	}
+}
+
+func TestKernel_TopSocketsTable(t *testing.T) {
+	m := newTestModel()
+	m.ActiveTab = TabKernel
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("s")})
+	updated, _ = updated.Update(TopSocketsMsg{
+		{Local: "192.0.2.10:50000", Remote: "198.51.100.3:443", RTT: 250 * time.Millisecond, Retrans: 12, Lost: 2},
+		{Local: "192.0.2.10:50001", Remote: "198.51.100.2:22", RTT: 1500 * time.Microsecond},
+	})
+	m = updated.(Model)
+	if out := m.renderKernel(); !strings.Contains(out, "2 lost, high RTT") {
+		t.Errorf("lossy, slow socket not noted:\n%s", out)
+	}
+
+	updated, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("3")})
+	m = updated.(Model)
+	if out := m.renderKernel(); strings.Index(out, "198.51.100.2:22") > strings.Index(out, "198.51.100.3:443") {
+		t.Errorf("'3' should sort by RTT:\n%s", out)
+	}
+	if !m.LoadingTopSockets || cmd == nil || m.topSocketOrder() != collector.ByRTT {
+		t.Fatal("sorting by RTT should fetch the highest RTT sockets")
+	}
+	updated, _ = m.Update(TopSocketsMsg{
+		{Local: "192.0.2.10:50002", Remote: "198.51.100.9:443", RTT: 900 * time.Millisecond},
+	})
+	m = updated.(Model)
+	if out := m.renderKernel(); !strings.Contains(out, "by RTT, then retransmissions") || !strings.Contains(out, "198.51.100.9:443") {
+		t.Errorf("RTT list not shown:\n%s", out)
+	}
+
+	updated, _ = m.Update(TickMsg(time.Now()))
+	if updated.(Model).LoadingTopSockets {
+		t.Error("top sockets re-read on every tick")
+	}
+	m.topSocketsRefreshed = time.Now().Add(-topSocketsRefreshInterval)
+	updated, _ = m.Update(TickMsg(time.Now()))
+	if !updated.(Model).LoadingTopSockets {
+		t.Error("top sockets not re-read after the refresh interval")
+	}
+}
+
+func TestKernel_BothSocketTables(t *testing.T) {
+	m := newTestModel()
+	m.ActiveTab = TabKernel
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("s")})
+	updated, _ = updated.Update(TopSocketsMsg{
+		{Local: "192.0.2.10:50000", Remote: "198.51.100.3:443", RTT: 250 * time.Millisecond},
+		{Local: "192.0.2.10:50001", Remote: "198.51.100.2:22", RTT: 1500 * time.Microsecond},
+	})
+	updated, _ = updated.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("l")})
+	m = updated.(Model)
+	out := m.renderKernel()
+	if strings.Contains(out, "1-6 to sort") || !strings.Contains(out, "hide the listening list with 'l' to sort") {
+		t.Errorf("the top sockets should not promise sort keys while the listening list takes them:\n%s", out)
+	}
+
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("3")})
+	m = updated.(Model)
+	if out := m.renderKernel(); strings.Index(out, "198.51.100.2:22") < strings.Index(out, "198.51.100.3:443") {
+		t.Errorf("'3' went to the top sockets while the listening list is shown:\n%s", out)
+	}
+}
+
+func TestKernel_ListenSocketsTable(t *testing.T) {
+	m := newTestModel()
+	m.ActiveTab = TabKernel
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("l")})
+	updated, _ = updated.Update(ListenSocketsMsg{Sockets: []collector.ListenSocket{
+		{Proto: "tcp", Addr: "127.0.0.1", Port: 8080},
+		{Proto: "udp", Addr: "0.0.0.0", Port: 53},
+		{Proto: "tcp", Addr: "0.0.0.0", Port: 443},
+	}})
+	m = updated.(Model)
+
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("2")})
+	m = updated.(Model)
+	out := m.renderKernel()
+	if i, j, k := strings.Index(out, ":53"), strings.Index(out, ":443"), strings.Index(out, ":8080"); i < 0 || i > j || j > k {
+		t.Errorf("'2' should sort by port:\n%s", out)
+	}
+
+	updated, _ = m.Update(TickMsg(time.Now()))
+	if updated.(Model).LoadingListenSockets {
+		t.Error("listening sockets re-read on every tick")
+	}
+	m.listenRefreshed = time.Now().Add(-listenRefreshInterval)
+	updated, _ = m.Update(TickMsg(time.Now()))
+	if !updated.(Model).LoadingListenSockets {
+		t.Error("listening sockets not re-read after the refresh interval")
+	}
+}
+
+func TestPrivacyMode_MasksRenderedOutput(t *testing.T) {
+	m := newTestModel()
+	m.LoadingSystem, m.LoadingPublicIP = false, false
+	m.HostInfo = collector.HostInfo{
+		Hostname:   "alice-laptop",
+		Interfaces: []collector.InterfaceInfo{{Name: "eth0", MAC: "aa:bb:cc:dd:ee:ff", IP: "192.168.1.20", MTU: 1500}},
+	}
+	m.PublicIP = collector.PublicIPInfo{IP: "203.0.113.45", Provider: "test"}
+
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyCtrlX})
+	m = updated.(Model)
+	if !m.PrivacyMode {
+		t.Fatal("ctrl+x should turn privacy mode on")
+	}
+
+	m.ActiveTab = TabDashboard
+	dashboard := m.View()
+	if strings.Contains(dashboard, "203.0.113.45") || !strings.Contains(dashboard, "203.0.x.x") {
+		t.Errorf("public IP not masked:\n%s", dashboard)
+	}
+	if strings.Contains(dashboard, "alice-laptop") {
+		t.Errorf("hostname not masked:\n%s", dashboard)
+	}
+	m.ActiveTab = TabInterfaces
+	interfaces := m.View()
+	if strings.Contains(interfaces, "cc:dd:ee:ff") || !strings.Contains(interfaces, "aa:bb:**:**:**:**") {
+		t.Errorf("MAC not masked:\n%s", interfaces)
+	}
+	if !strings.Contains(interfaces, "192.168.1.20") {
+		t.Error("private addresses should stay visible")
+	}
+
+	// Masking happens at render time only
+	if m.PublicIP.IP != "203.0.113.45" || m.HostInfo.Interfaces[0].MAC != "aa:bb:cc:dd:ee:ff" || m.HostInfo.Hostname != "alice-laptop" {
+		t.Error("privacy mode must not change the stored data")
+	}
+}
+
+func TestRefreshScale(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   config.PowerSaveConfig
+		power collector.PowerSource
+		idle  time.Duration
+		want  int
+	}{
+		{"ac, active", config.PowerSaveConfig{}, collector.PowerAC, time.Second, 1},
+		{"no battery, active", config.PowerSaveConfig{}, collector.PowerUnknown, time.Second, 1},
+		{"battery", config.PowerSaveConfig{}, collector.PowerBattery, time.Second, defaultBatteryScale},
+		{"ac, idle", config.PowerSaveConfig{}, collector.PowerAC, 10 * time.Minute, defaultIdleScale},
+		{"battery and idle take the larger", config.PowerSaveConfig{Battery: 8}, collector.PowerBattery, 10 * time.Minute, 8},
+		{"custom idle threshold", config.PowerSaveConfig{IdleAfter: time.Minute, Idle: 2}, collector.PowerAC, 2 * time.Minute, 2},
+		{"disabled", config.PowerSaveConfig{Disabled: true}, collector.PowerBattery, time.Hour, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := refreshScale(tt.cfg, tt.power, tt.idle); got != tt.want {
+				t.Errorf("refreshScale() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	// A key press restores the full refresh rate
+	m := newTestModel()
+	m.Power = collector.PowerAC
+	m.lastInput = time.Now().Add(-time.Hour)
+	if got := m.refreshInterval(time.Second); got != defaultIdleScale*time.Second {
+		t.Errorf("idle interval = %s", got)
+	}
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x")})
+	if got := updated.(Model).refreshInterval(time.Second); got != time.Second {
+		t.Errorf("interval after input = %s, want 1s", got)
+	}
+}
+
+func TestDNSTab_IDNShowsBothForms(t *testing.T) {
+	m := newTestModel()
+	m.ActiveTab = TabDNS
+	updated, _ := m.Update(DNSMsg{Server: "Mock", Protocol: collector.ProtoUDP, ResponseCode: "NOERROR",
+		UnicodeName: "münchen.de", ASCIIName: "xn--mnchen-3ya.de"})
+	m = updated.(Model)
+	if out := m.renderDNS(); !strings.Contains(out, "münchen.de (sent as xn--mnchen-3ya.de)") {
+		t.Errorf("IDN forms not rendered:\n%s", out)
+	}
+}
+
+func TestDNSTab_DNSSECToggle(t *testing.T) {
+	m := newTestModel()
+	m.ActiveTab = TabDNS
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("d"), Alt: true})
+	m = updated.(Model)
+	if opts := m.dnsQueryOptions(); !opts.DNSSECRequested || opts.UDPSize != 4096 {
+		t.Fatalf("options = %+v, want DNSSEC with a 4096 byte buffer", opts)
+	}
+
+	updated, _ = m.Update(DNSMsg{Server: "Mock", Protocol: collector.ProtoUDP, ResponseCode: "NOERROR",
+		DNSSECRequested: true, Authenticated: true})
+	m = updated.(Model)
+	if out := m.renderDNS(); !strings.Contains(out, "authenticated (AD)") {
+		t.Errorf("AD bit not rendered:\n%s", out)
+	}
+}
+
+func TestDNSTab_SectionsCollapsed(t *testing.T) {
+	m := newTestModel()
+	m.ActiveTab = TabDNS
+	updated, _ := m.Update(DNSMsg{Server: "Mock", Protocol: collector.ProtoUDP, ResponseCode: "NOERROR",
+		Authority: []string{"example.com. 60 IN NS ns1.example.com."}, Additional: []string{"ns1.example.com. 60 IN A 192.0.2.53"}})
+	m = updated.(Model)
+	out := m.renderDNS()
+	if !strings.Contains(out, "Authority section (1), Alt+v to expand") || strings.Contains(out, "ns1.example.com.") {
+		t.Errorf("sections should be collapsed:\n%s", out)
+	}
+
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("v"), Alt: true})
+	out = updated.(Model).renderDNS()
+	if !strings.Contains(out, "AUTHORITY SECTION") || !strings.Contains(out, "IN A 192.0.2.53") {
+		t.Errorf("sections should be expanded:\n%s", out)
+	}
+}
+
+func TestDNSTab_Trace(t *testing.T) {
+	m := newTestModel()
+	m.ActiveTab = TabDNS
+	m.LoadingDNSTrace = true
+	updated, _ := m.Update(DNSTraceMsg{Domain: "www.example.com", Type: collector.RecordA, Steps: []collector.DNSTraceStep{
+		{Zone: ".", Server: "a.root-servers.net", Address: "198.41.0.4:53", Rcode: "NOERROR", Referral: "com",
+			NS: []string{"a.gtld-servers.net"}, Glue: []string{"a.gtld-servers.net 192.5.6.30"}},
+		{Zone: "com", Server: "a.gtld-servers.net", Address: "192.5.6.30:53", Error: errors.New("i/o timeout")},
+		{Zone: "example.com", Server: "ns1.example.com", Address: "192.0.2.53:53", Rcode: "NOERROR",
+			Answer: []string{"www.example.com. 300 IN A 192.0.2.80"}},
+	}})
+	m = updated.(Model)
+	if m.LoadingDNSTrace {
+		t.Error("trace should have finished loading")
+	}
+	out := m.renderDNSTrace()
+	for _, want := range []string{"DNS Trace (www.example.com A)", "→ com: a.gtld-servers.net", "1 glue addresses", "i/o timeout", "IN A 192.0.2.80"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("trace output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestDNSTab_Cache(t *testing.T) {
+	m := newTestModel()
+	m.ActiveTab = TabDNS
+	m.LoadingDNSCache = true
+	updated, _ := m.Update(DNSCacheMsg{Domain: "example.com", Server: "Mock", Cached: true, TTL1: 300, TTL2: 299})
+	m = updated.(Model)
+	if out := m.renderDNS(); !strings.Contains(out, "Cached: yes, example.com via Mock (TTL 300s, then 299s)") {
+		t.Errorf("cached answer not rendered:\n%s", out)
+	}
+	updated, _ = m.Update(DNSCacheMsg{Domain: "example.com", Server: "Mock", Error: errors.New("no A records to compare TTLs of (NXDOMAIN)")})
+	if out := updated.(Model).renderDNS(); !strings.Contains(out, "Cached: ") || !strings.Contains(out, "NXDOMAIN") {
+		t.Errorf("probe error not rendered:\n%s", out)
+	}
+}
+
+func TestDNSTab_PortScanBanner(t *testing.T) {
+	m := newTestModel()
+	m.LoadingPortScan = true
+	updated, _ := m.Update(PortScanMsg{Target: "example.com", Address: "192.0.2.1", Ports: []collector.PortState{
+		{Port: 22, Service: "ssh", Reachability: collector.ReachOpen, Banner: "SSH-2.0-OpenSSH_9.6"},
+		{Port: 80, Service: "http", Reachability: collector.ReachOpen, Banner: "HTTP/1.1 200 OK, Server: " + strings.Repeat("x", 80)},
+		{Port: 443, Service: "https", Reachability: collector.ReachClosed},
+	}})
+	out := updated.(Model).renderPortScan()
+	for _, want := range []string{"SSH-2.0-OpenSSH_9.6", "HTTP/1.1 200 OK, Server: xxx", "xxx..."} {
+		if !strings.Contains(out, want) {
+			t.Errorf("port scan is missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestDNSTab_Egress(t *testing.T) {
+	m := newTestModel()
+	m.ActiveTab = TabDNS
+	m.DNSInput.SetValue("example.com")
+	updated, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'o'}, Alt: true})
+	m = updated.(Model)
+	if !m.LoadingDNSEgress || cmd == nil {
+		t.Fatal("Alt+o should look up the egress route of the host")
+	}
+
+	updated, _ = m.Update(DNSEgressMsg{{Target: "example.com", Address: "192.0.2.1", Source: "10.0.0.2", Interface: "wlan0", Gateway: "10.0.0.1", Table: 100}})
+	out := updated.(Model).renderDNS()
+	if !strings.Contains(out, "192.0.2.1 from 10.0.0.2 via wlan0 gw 10.0.0.1") || !strings.Contains(out, "(table 100)") {
+		t.Errorf("egress route not rendered:\n%s", out)
+	}
+}
+
+func TestTunnelsTab_Cert(t *testing.T) {
+	m := newTestModel()
+	m.LoadingTunnels = false
+	m.TunnelResults = []collector.TunnelResult{{Name: "H3", App: "h3", Transport: "quic", Status: "OK",
+		CertInfo: &collector.CertInfo{Subject: "CN=example.com", Issuer: "CN=Test CA", NotAfter: time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC)}}}
+	if out := m.renderTunnels(); !strings.Contains(out, "cert CN=example.com, issuer CN=Test CA, expires 2030-01-02") {
+		t.Errorf("certificate not rendered:\n%s", out)
+	}
+
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	soon := &collector.CertInfo{Subject: "CN=a", Issuer: "CN=b", NotAfter: now.Add(3 * 24 * time.Hour)}
+	if out := renderTunnelCert(soon, now); !strings.Contains(out, "(in 3 days)") {
+		t.Errorf("expiring certificate not flagged: %q", out)
+	}
+	if out := renderTunnelCert(soon, now.Add(30*24*time.Hour)); !strings.Contains(out, "(expired)") {
+		t.Errorf("expired certificate not flagged: %q", out)
+	}
+}
+
+func TestTunnelsTab_CertColorBlindSymbol(t *testing.T) {
+	if err := ui.SetTheme("deuteranopia"); err != nil {
+		t.Fatal(err)
+	}
+	defer ui.SetTheme("")
+
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	expired := &collector.CertInfo{Subject: "CN=a", Issuer: "CN=b", NotAfter: now.Add(-time.Hour)}
+	if out := renderTunnelCert(expired, now); !strings.Contains(out, "✗") {
+		t.Errorf("expired certificate without a symbol: %q", out)
+	}
+}
+
+func TestDNSTab_CtrlEEndOfLine(t *testing.T) {
+	m := newTestModel()
+	m.ActiveTab = TabDNS
+	m.DNSInput.SetValue("example.com")
+	m.DNSInput.SetCursor(0)
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyCtrlE})
+	m = updated.(Model)
+	if got := m.DNSInput.Position(); got != len("example.com") {
+		t.Errorf("cursor at %d after ctrl+e, want the end of the domain", got)
+	}
+}
+
+func TestDNSTab_CompareHighlightsDivergent(t *testing.T) {
+	m := newTestModel()
+	m.ActiveTab = TabDNS
+	servers := m.comparedDNSServers()
+	for _, s := range servers {
+		if s.Name == "Custom" {
+			t.Error("Custom without an address should not be compared")
+		}
+	}
+
+	updated, _ := m.Update(DNSCompareMsg{
+		Domain:  "example.com",
+		Type:    collector.RecordA,
+		Servers: []string{"Google", "Cloudflare", "ISP"},
+		Results: []collector.DNSLookupResult{
+			{ResponseCode: "NOERROR", Records: []string{"example.com. 60 IN A 192.0.2.1"}},
+			{ResponseCode: "NOERROR", Records: []string{"example.com. 30 IN A 192.0.2.1"}},
+			{ResponseCode: "NOERROR", Records: []string{"example.com. 60 IN A 10.0.0.1"}},
+		},
+		Divergent: []bool{false, false, true},
+	})
+	m = updated.(Model)
+	out := m.renderDNS()
+	if !strings.Contains(out, "1 differ from the majority") || !strings.Contains(out, "A 10.0.0.1 (differs)") {
+		t.Errorf("divergent server not highlighted:\n%s", out)
+	}
+	if strings.Count(out, "(differs)") != 1 {
+		t.Errorf("only the ISP should be flagged:\n%s", out)
+	}
+}
+
+func TestSpeedTestTab_ProgressAndResult(t *testing.T) {
+	m := newTestModel()
+	m.ActiveTab = TabSpeedTest
+	cancelled := false
+	m.RunningSpeedTest, m.speedTestCancel = true, func() { cancelled = true }
+	m.speedTestUpdates = make(chan tea.Msg, 1)
+
+	updated, cmd := m.Update(SpeedTestProgressMsg{Server: "Cloudflare", Phase: collector.SpeedTestDownload, Bytes: 3 << 20, Mbps: 42.5})
+	m = updated.(Model)
+	if cmd == nil {
+		t.Error("progress should wait for the next update")
+	}
+	if out := m.renderSpeedTest(); !strings.Contains(out, "download against Cloudflare: 42.5 Mbps") {
+		t.Errorf("progress not rendered:\n%s", out)
+	}
+
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'s'}})
+	m = updated.(Model)
+	if !cancelled {
+		t.Error("'s' during a test should stop it")
+	}
+
+	updated, _ = m.Update(SpeedTestMsg{Server: "Cloudflare", DownloadMbps: 93.4, UploadMbps: 11.2, Uploaded: 1 << 20,
+		Idle: 12 * time.Millisecond, DownloadLatency: 80 * time.Millisecond, Downloaded: 100 << 20, Stopped: true})
+	m = updated.(Model)
+	if m.RunningSpeedTest || m.SpeedTestProgress != nil {
+		t.Error("the result should end the test")
+	}
+	out := m.renderSpeedTest()
+	for _, want := range []string{"93.4 Mbps  (RTT 80ms under load)", "Upload:    11.2 Mbps", "Stopped before the end"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("result is missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestConnectivityTab_Jitter(t *testing.T) {
+	m := newTestModel()
+	m.LoadingConn = false
+	m.Connectivity = collector.ConnectivityStats{Targets: map[string]collector.PingResult{
+		"8.8.8.8":     {Target: "8.8.8.8", AvgRtt: 20 * time.Millisecond, Jitter: 45 * time.Millisecond, StdDevRtt: 30 * time.Millisecond},
+		"example.com": {Target: "example.com", Reachability: collector.ReachOpen, Port: 443},
+	}}
+	out := m.renderConnectivity()
+	if !strings.Contains(out, "RTT: 20.00ms, Jitter: 45.00ms (σ 30.00ms)") {
+		t.Errorf("jitter not shown next to the RTT:\n%s", out)
+	}
+	if strings.Count(out, "Jitter:") != 1 {
+		t.Error("a TCP connect has no jitter to show")
+	}
+}
+
+func TestConnectivityTab_Nameservers(t *testing.T) {
+	m := newTestModel()
+	m.LoadingConn = false
+	m.Connectivity = collector.ConnectivityStats{DNS: collector.DNSResult{
+		LocalResolver: "System", LocalResolverTime: 5 * time.Millisecond, LocalRcode: "NXDOMAIN",
+		Nameservers: []collector.DNSResolverTime{
+			{Server: "192.0.2.53:53 (UDP)", Latency: 12 * time.Millisecond},
+			{Server: "192.0.2.54:53 (UDP)", Error: errors.New("i/o timeout")},
+		},
+	}}
+	out := m.renderConnectivity()
+	for _, want := range []string{"Local (System): 5ms (NXDOMAIN for the probe domain)", "192.0.2.53:53 (UDP): 12ms", "192.0.2.54:53 (UDP): i/o timeout"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestConnectivityTab_PublicIP(t *testing.T) {
+	m := newTestModel()
+	m.LoadingConn = false
+	updated, _ := m.Update(PublicIPMsg{IP: "203.0.113.45", Provider: "https://api.ipify.org?format=text"})
+	m = updated.(Model)
+	if out := m.renderConnectivity(); !strings.Contains(out, "203.0.113.45") || !strings.Contains(out, "(via https://api.ipify.org?format=text)") {
+		t.Errorf("public IP not shown:\n%s", out)
+	}
+
+	updated, _ = m.Update(GeolocationMsg{IP: "203.0.113.45", Provider: "https://api.ipify.org?format=text",
+		ASN: "AS64500", Org: "Example Net", City: "Berlin", Country: "DE"})
+	m = updated.(Model)
+	if out := m.renderConnectivity(); !strings.Contains(out, "AS64500 Example Net, Berlin, DE") {
+		t.Errorf("geolocation not shown:\n%s", out)
+	}
+
+	updated, _ = m.Update(PublicIPMsg{Error: errors.New("all providers failed")})
+	m = updated.(Model)
+	if out := m.renderConnectivity(); !strings.Contains(out, "Error: all providers failed") {
+		t.Errorf("public IP error not shown:\n%s", out)
+	}
+}
+
+func TestConnectivityTab_IPv6Groups(t *testing.T) {
+	m := newTestModel()
+	m.LoadingConn = false
+	m.Connectivity = collector.ConnectivityStats{Targets: map[string]collector.PingResult{
+		"8.8.8.8": {Target: "8.8.8.8", AvgRtt: 20 * time.Millisecond},
+	}}
+	if out := m.renderConnectivity(); !strings.Contains(out, "IPv4:") || !strings.Contains(out, "no IPv6") {
+		t.Errorf("missing IPv6 route not reported:\n%s", out)
+	}
+
+	m.Connectivity.IPv6Gateway = "fe80::1%eth0"
+	m.Connectivity.IPv6Targets = map[string]collector.PingResult{
+		"2606:4700:4700::1111": {Target: "2606:4700:4700::1111", PacketLoss: 100, Error: errors.New("timeout")},
+	}
+	out := m.renderConnectivity()
+	v6 := strings.Index(out, "IPv6 (gateway fe80::1%eth0):")
+	if v6 < 0 || strings.Index(out, "2606:4700:4700::1111") < v6 || strings.Index(out, "8.8.8.8") > v6 {
+		t.Errorf("results not grouped by family:\n%s", out)
+	}
+}
+
+func TestInterfaces_DriverAndOffload(t *testing.T) {
+	m := newTestModel()
+	m.ActiveTab = TabInterfaces
+	m.LoadingSystem = false
+	m.HostInfo = collector.HostInfo{Interfaces: []collector.InterfaceInfo{{
+		Name: "eth0", Driver: "ixgbe", DriverVersion: "5.1.0-k", FirmwareVersion: "0x800008d3",
+		Offload: map[string]bool{"TSO": true, "GSO": true, "GRO": true, "LRO": false},
+	}}}
+	out := m.renderInterfaces()
+	for _, want := range []string{"Driver: ixgbe 5.1.0-k, firmware 0x800008d3", "Offload: TSO on, GSO on, GRO on, LRO off"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Interfaces tab is missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestInterfaces_LinkSpeed(t *testing.T) {
+	m := newTestModel()
+	m.ActiveTab = TabInterfaces
+	m.LoadingSystem = false
+	m.HostInfo = collector.HostInfo{Interfaces: []collector.InterfaceInfo{
+		{Name: "eth0", SpeedMbps: 1000, Duplex: "full", MaxSpeedMbps: 1000},
+		{Name: "eth1", SpeedMbps: 100, Duplex: "half", MaxSpeedMbps: 2500, PeerMaxSpeedMbps: 1000},
+		{Name: "eth2"}, // Down, sysfs reports -1 and unknown
+		{Name: "eth3", SpeedMbps: 100, Duplex: "full", MaxSpeedMbps: 1000, PeerMaxSpeedMbps: 100},
+	}}
+	out := m.renderInterfaces()
+	for _, want := range []string{
+		"Link: 1G, full duplex\n",
+		"Link: 100M, half duplex (half duplex, check for a duplex mismatch; both ends support 1G",
+		"Link: 100M, full duplex (the NIC supports 1G)\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Interfaces tab is missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "full duplex (the NIC supports 1G, check") {
+		t.Errorf("a partner that only offers 100M is not a fault:\n%s", out)
+	}
+	if strings.Count(out, "Link:") != 3 {
+		t.Errorf("a link of unknown speed should have no Link line:\n%s", out)
+	}
+}
+
+func TestInterfaces_LinkState(t *testing.T) {
+	m := newTestModel()
+	m.ActiveTab = TabInterfaces
+	m.LoadingSystem = false
+	updated, _ := m.Update(SystemInfoMsg{Interfaces: []collector.InterfaceInfo{
+		{Name: "eth0", OperState: "up", CarrierChanges: 12, CarrierFlaps: 5},
+		{Name: "eth1", OperState: "down"},
+		{Name: "tun0", OperState: "unknown"},
+		{Name: "eth2", OperState: "up", CarrierChanges: 3, CarrierFlaps: 1},
+	}})
+	out := updated.(Model).renderInterfaces()
+	for _, want := range []string{"eth1:  (MTU: 0) [down]", "Carrier changes since the last check: 5 (12 in total), the link is flapping",
+		"Carrier changed since the last check (3 in total)"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Interfaces tab is missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "[unknown]") || strings.Count(out, "flapping") != 1 {
+		t.Errorf("only eth1 is down and only eth0 flaps, one change on eth2 is not flapping:\n%s", out)
+	}
+}
+
+func TestInterfaces_LinkStateRefresh(t *testing.T) {
+	m := newTestModel()
+	m.ActiveTab = TabInterfaces
+	m.LoadingSystem = false
+	temporary := collector.IPv6Address{Address: "2001:db8::abcd", PrefixLen: 64, Scope: collector.IPv6ScopeGlobal, Temporary: true,
+		PreferredLft: 10 * time.Minute, ValidLft: 20 * time.Minute}
+	m.HostInfo = collector.HostInfo{Interfaces: []collector.InterfaceInfo{{Name: "eth0", OperState: "up", Driver: "e1000e", IPv6: []collector.IPv6Address{temporary}}}}
+
+	m.systemRefreshed = time.Now().Add(-systemRefreshInterval)
+	updated, cmd := m.Update(TickMsg(time.Now()))
+	if m = updated.(Model); !m.refreshingSystem || cmd == nil {
+		t.Fatal("the link state should be re-read after the refresh interval")
+	}
+	updated, _ = m.Update(LinkStateMsg{"eth0": {OperState: "down", CarrierChanges: 9, CarrierFlaps: 3}})
+	m = updated.(Model)
+	if got := m.HostInfo.Interfaces[0]; got.OperState != "down" || got.CarrierFlaps != 3 || got.Driver != "e1000e" {
+		t.Errorf("interface after the refresh = %+v, want the new link state and the rest kept", got)
+	}
+	if m.refreshingSystem {
+		t.Error("the refresh should be done")
+	}
+	if got := m.HostInfo.Interfaces[0].IPv6; len(got) != 1 || got[0].PreferredLft != 10*time.Minute {
+		t.Errorf("addresses not re-read should be kept: %+v", got)
+	}
+
+	// The lifetimes count down between two full collections
+	temporary.PreferredLft, temporary.ValidLft = 9*time.Minute, 19*time.Minute
+	updated, _ = m.Update(LinkStateMsg{"eth0": {OperState: "up", IPv6: []collector.IPv6Address{temporary}, IPv6Read: true}})
+	m = updated.(Model)
+	if out := m.renderInterfaces(); !strings.Contains(out, "preferred: 9m0s, valid: 19m0s") {
+		t.Errorf("lifetimes not refreshed:\n%s", out)
+	}
+
+	// So do the queue counters, a failed read keeps the last ones
+	m.HostInfo.Interfaces[0].Queues = []collector.QueueStats{{Name: "rx-0", Packets: 10, HasPackets: true}}
+	updated, _ = m.Update(LinkStateMsg{"eth0": {OperState: "up", Queues: []collector.QueueStats{{Name: "rx-0", Packets: 250, HasPackets: true}}}})
+	m = updated.(Model)
+	updated, _ = m.Update(LinkStateMsg{"eth0": {OperState: "up"}})
+	m = updated.(Model)
+	if out := m.renderInterfaces(); !strings.Contains(out, "Queues: rx-0: 250") {
+		t.Errorf("queue counters not refreshed:\n%s", out)
+	}
+
+	// A renegotiated link, e.g. after a cable change
+	updated, _ = m.Update(LinkStateMsg{"eth0": {OperState: "up", SpeedMbps: 100, Duplex: "half", MaxSpeedMbps: 1000, PeerMaxSpeedMbps: 1000}})
+	m = updated.(Model)
+	if out := m.renderInterfaces(); !strings.Contains(out, "half duplex, check for a duplex mismatch") {
+		t.Errorf("link speed not refreshed:\n%s", out)
+	}
+}
+
+func TestInterfaces_TemporaryAddressNeutral(t *testing.T) {
+	if err := ui.SetTheme("deuteranopia"); err != nil {
+		t.Fatal(err)
+	}
+	defer ui.SetTheme("")
+
+	m := newTestModel()
+	m.LoadingSystem = false
+	m.HostInfo = collector.HostInfo{Interfaces: []collector.InterfaceInfo{{Name: "eth0", OperState: "up", IPv6: []collector.IPv6Address{
+		{Address: "2001:db8::abcd", PrefixLen: 64, Scope: collector.IPv6ScopeGlobal, Temporary: true, PreferredLft: time.Hour, ValidLft: time.Hour},
+	}}}}
+	out := m.renderInterfaces()
+	if !strings.Contains(out, "(temporary)") || strings.Contains(out, "! ") {
+		t.Errorf("a privacy address is not a warning:\n%s", out)
+	}
+}
+
+func TestConnectivityTab_PathMTU(t *testing.T) {
+	m := newTestModel()
+	m.LoadingConn = false
+	updated, _ := m.Update(PathMTUMsg{
+		{Target: "vpn.example", MTU: 1420, InterfaceMTU: 1500},
+		{Target: "8.8.8.8", MTU: 1500, InterfaceMTU: 1500},
+		{Target: "1.1.1.1", MTU: 1500, InterfaceMTU: 1500, Fallback: true},
+		{Target: "down.example", Error: errors.New("no echo reply within 1s")},
+	})
+	m = updated.(Model)
+	out := m.renderConnectivity()
+	for _, want := range []string{"path MTU 1420, interface 1500", "path MTU 1500", "interface MTU 1500 (no raw socket, not probed)", "no echo reply within 1s", "Press 'p' to probe again"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestConnectivityTab_PingHostname(t *testing.T) {
+	m := newTestModel()
+	m.LoadingConn = false
+	m.Connectivity = collector.ConnectivityStats{Targets: map[string]collector.PingResult{
+		"192.168.1.1": {Target: "192.168.1.1", Hostname: "router.lan", AvgRtt: time.Millisecond},
+		"8.8.8.8":     {Target: "8.8.8.8", AvgRtt: 10 * time.Millisecond},
+	}}
+	out := m.renderConnectivity()
+	if !strings.Contains(out, "192.168.1.1 (router.lan):") {
+		t.Errorf("gateway PTR name not shown:\n%s", out)
+	}
+	if !strings.Contains(out, "8.8.8.8: ") {
+		t.Errorf("target without a PTR name changed:\n%s", out)
+	}
+}
+
+func TestConfigReload(t *testing.T) {
+	m := newTestModel()
+	m.ActiveTab = TabDNS
+	builtin := len(m.DNSServers)
+
+	cfg := config.Default()
+	cfg.DNSServers = []config.DNSServerConfig{{Name: "Office", Address: "10.0.0.53:53", Proto: "UDP"}}
+	cfg.Collectors = map[string]bool{config.CollectorSTUN: false, config.CollectorTunnels: false}
+	updated, _ := m.Update(ConfigReloadMsg{Config: cfg})
+	m = updated.(Model)
+	if m.ActiveTab != TabDNS {
+		t.Errorf("active tab = %d, want the DNS tab kept", m.ActiveTab)
+	}
+	if len(m.DNSServers) != builtin+1 || m.DNSServers[m.customDNSServerIndex()-1].Name != "Office" {
+		t.Errorf("configured server not added before Custom: %v", m.DNSServers)
+	}
+	if m.natCollector != nil || m.tunnelCollector != nil || slices.Contains(m.Tabs, TabTunnels) {
+		t.Error("collectors disabled by the reload should be dropped")
+	}
+	if !strings.Contains(m.statusLine(), "Config reloaded") {
+		t.Errorf("status line = %q", m.statusLine())
+	}
+
+	// A broken file keeps the running config
+	updated, _ = m.Update(ConfigReloadMsg{Error: errors.New("yaml: line 1: did not find expected node content")})
+	m = updated.(Model)
+	if len(m.DNSServers) != builtin+1 {
+		t.Errorf("servers changed by a failed reload: %v", m.DNSServers)
+	}
+	if !strings.Contains(m.statusLine(), "keeping the previous config") {
+		t.Errorf("status line = %q", m.statusLine())
+	}
+}
+
+func TestConfigReload_KeepsStartupCollectors(t *testing.T) {
+	cfg := config.Default()
+	cfg.Collectors = map[string]bool{config.CollectorDNS: false}
+	m := NewModel(cfg)
+	updated, _ := m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	m = updated.(Model)
+
+	// Turning dns back on needs a restart, the collector was never created
+	updated, _ = m.Update(ConfigReloadMsg{Config: config.Default()})
+	m = updated.(Model)
+	if m.dnsCollector != nil || slices.Contains(m.Tabs, TabDNS) {
+		t.Fatalf("DNS tab shown without a collector: %v", m.Tabs)
+	}
+	if m.cfg.CollectorEnabled(config.CollectorDNS) {
+		t.Error("dns switch taken from the reloaded file")
+	}
+	if !m.cfg.CollectorEnabled(config.CollectorSTUN) || m.natCollector == nil {
+		t.Error("STUN switch not applied")
+	}
+}
+
+func TestConfigReload_Warnings(t *testing.T) {
+	m := newTestModel()
+	cfg := config.Default()
+	cfg.Warnings = []string{`line 3: unknown key "stun_server" is ignored`, `line 9: unknown key "tunels" is ignored`}
+	updated, _ := m.Update(ConfigReloadMsg{Config: cfg})
+	m = updated.(Model)
+	if out := m.statusLine(); !strings.Contains(out, `unknown key "stun_server" is ignored (and 1 more)`) {
+		t.Errorf("status line = %q, want the unknown keys", out)
+	}
+}
+
+func TestConfigReload_KeepsUnsavedDNSServers(t *testing.T) {
+	m := newTestModel()
+	// Added in the DNS form, not saved yet
+	i := m.customDNSServerIndex()
+	m.DNSServers = slices.Insert(m.DNSServers, i, collector.DNSServer{Name: "Lab", Address: "10.1.0.53:53", Proto: collector.ProtoUDP})
+
+	// An edit to the tunnels leaves the servers alone
+	cfg := config.Default()
+	cfg.Tunnels = []config.TunnelConfig{{Name: "VPN", Target: "vpn.example.com:443"}}
+	updated, _ := m.Update(ConfigReloadMsg{Config: cfg})
+	m = updated.(Model)
+	if m.DNSServers[m.customDNSServerIndex()-1].Name != "Lab" {
+		t.Fatalf("unsaved server dropped by an unrelated reload: %v", m.DNSServers)
+	}
+
+	// A new server in the file joins it
+	cfg = config.Default()
+	cfg.DNSServers = []config.DNSServerConfig{{Name: "Office", Address: "10.0.0.53:53", Proto: "UDP"}}
+	updated, _ = m.Update(ConfigReloadMsg{Config: cfg})
+	m = updated.(Model)
+	var names []string
+	for _, s := range m.DNSServers[m.builtinDNSServers:m.customDNSServerIndex()] {
+		names = append(names, s.Name)
+	}
+	if !slices.Equal(names, []string{"Office", "Lab"}) {
+		t.Errorf("configured servers = %v, want the file's and the unsaved one", names)
+	}
+}
+
+func TestConfigReload_DropsReplacedResults(t *testing.T) {
+	m := newTestModel()
+	stale := m.configScoped(func() tea.Msg { return TunnelMsg{{Name: "old"}} })
+
+	cfg := config.Default()
+	cfg.Tunnels = []config.TunnelConfig{{Name: "new", Target: "vpn.example.com:443"}}
+	updated, _ := m.Update(ConfigReloadMsg{Config: cfg})
+	m = updated.(Model)
+	updated, _ = m.Update(stale())
+	if m = updated.(Model); !m.LoadingTunnels || m.TunnelResults != nil {
+		t.Errorf("result of the replaced tunnel collector kept: %+v", m.TunnelResults)
+	}
+
+	updated, _ = m.Update(m.configScoped(func() tea.Msg { return TunnelMsg{{Name: "new"}} })())
+	if m = updated.(Model); len(m.TunnelResults) != 1 || m.TunnelResults[0].Name != "new" {
+		t.Errorf("result of the current tunnel collector dropped: %+v", m.TunnelResults)
+	}
+}
+
+func TestNewPublicIPCollector_GeoURL(t *testing.T) {
+	if c := NewPublicIPCollector(config.Default()); c.GeoURL != collector.DefaultGeoURL {
+		t.Errorf("default geo URL = %q", c.GeoURL)
+	}
+	cfg := config.Default()
+	cfg.PublicIP.GeoURL = "https://geo.example/%s"
+	if c := NewPublicIPCollector(cfg); c.GeoURL != cfg.PublicIP.GeoURL {
+		t.Errorf("geo URL = %q, want the configured one", c.GeoURL)
+	}
+}
+
+func TestNewConnectivityCollector_ConnectivityKeys(t *testing.T) {
+	cfg := &config.Config{
+		DNSCheck: config.ConnectivityDNSConfig{Public: config.DNSServerConfig{Address: "1.1.1.1:53"}},
+		Connectivity: config.ConnectivityConfig{
+			DNSProbeDomain: "probe.example.org",
+			PublicResolver: "9.9.9.9",
+		},
+	}
+	c := NewConnectivityCollector(cfg)
+	if c.DNSDomain != "probe.example.org" {
+		t.Errorf("DNSDomain = %q, want probe.example.org", c.DNSDomain)
+	}
+	if c.PublicDNS.Address != "9.9.9.9:53" || c.PublicDNS.Proto != collector.ProtoUDP {
+		t.Errorf("PublicDNS = %+v, want 9.9.9.9:53 over UDP", c.PublicDNS)
+	}
+	if c.PublicCandidates != nil {
+		t.Errorf("PublicCandidates = %v, a configured resolver should skip the race", c.PublicCandidates)
+	}
+}
+
+func TestNewConnectivityCollector_BadPublicResolver(t *testing.T) {
+	cfg := &config.Config{Connectivity: config.ConnectivityConfig{PublicResolver: "9.9.9.9:99999"}}
+	c := NewConnectivityCollector(cfg)
+	if c.PublicDNSErr == nil || !strings.Contains(c.PublicDNSErr.Error(), `invalid port "99999"`) {
+		t.Errorf("PublicDNSErr = %v, want the parse error", c.PublicDNSErr)
+	}
+	if c.PublicCandidates != nil {
+		t.Errorf("PublicCandidates = %v, a configured resolver should skip the race", c.PublicCandidates)
+	}
+}
+
+func TestDNSTab_CompareNoMajority(t *testing.T) {
+	m := newTestModel()
+	m.ActiveTab = TabDNS
+	updated, _ := m.Update(DNSCompareMsg{
+		Domain:  "example.com",
+		Type:    collector.RecordA,
+		Servers: []string{"Google", "ISP"},
+		Results: []collector.DNSLookupResult{
+			{ResponseCode: "NOERROR", Records: []string{"example.com. 60 IN A 192.0.2.1"}},
+			{ResponseCode: "NOERROR", Records: []string{"example.com. 60 IN A 10.0.0.1"}},
+		},
+		Divergent:  []bool{false, false},
+		NoMajority: true,
+	})
+	m = updated.(Model)
+	if out := m.renderDNS(); !strings.Contains(out, "no majority") || strings.Contains(out, "(differs)") {
+		t.Errorf("a tie should be reported as no majority:\n%s", out)
+	}
+}
+
+func TestRefreshScale_WakeOnFullSpeed(t *testing.T) {
+	m := newTestModel()
+	m.Power = collector.PowerAC
+	m.lastInput = time.Now().Add(-time.Hour)
+	m.connDue = time.Now().Add(25 * time.Second) // Stretched while idle
+	updated, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x")})
+	m = updated.(Model)
+	if !m.connDue.IsZero() || cmd == nil {
+		t.Error("the key press ending idle should refresh connectivity at once")
+	}
+
+	// Only the latest scheduled tick runs
+	updated, _ = m.Update(TickMsg(time.Now()))
+	m = updated.(Model)
+	due := m.tickDue
+	if updated, _ = m.Update(scheduledTickMsg(due.Add(-4 * time.Second))); !updated.(Model).tickDue.Equal(due) {
+		t.Error("a stale scheduled tick should be dropped")
+	}
+	if updated, _ = m.Update(scheduledTickMsg(due)); updated.(Model).tickDue.Equal(due) {
+		t.Error("the current scheduled tick should run and schedule the next")
+	}
+
+	// Power coming back does the same
+	m.Power = collector.PowerBattery
+	m.readPower = func() collector.PowerSource { return collector.PowerAC }
+	m.powerChecked = time.Now().Add(-powerCheckInterval)
+	m.connDue = time.Now().Add(15 * time.Second)
+	updated, _ = m.Update(TickMsg(time.Now()))
+	if m = updated.(Model); m.Power != collector.PowerAC || !m.connDue.IsZero() {
+		t.Error("returning to AC power should refresh connectivity at once")
+	}
+}
+
+func TestPrivacyMode_MasksStyledOutput(t *testing.T) {
+	profile := lipgloss.ColorProfile()
+	lipgloss.SetColorProfile(termenv.TrueColor)
+	defer lipgloss.SetColorProfile(profile)
+
+	m := newTestModel()
+	m.LoadingSystem, m.LoadingPublicIP = false, false
+	m.HostInfo = collector.HostInfo{
+		Hostname:   "alice-laptop",
+		Interfaces: []collector.InterfaceInfo{{Name: "eth0", MAC: "aa:bb:cc:dd:ee:ff", IP: "192.168.1.20", MTU: 1500}},
+	}
+	m.PublicIP = collector.PublicIPInfo{IP: "203.0.113.45", Provider: "test"}
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyCtrlX})
+	m = updated.(Model)
+
+	for _, tab := range []int{TabDashboard, TabInterfaces} {
+		m.ActiveTab = tab
+		out := m.View()
+		if !strings.Contains(out, "\x1b[") {
+			t.Fatal("expected styled output with a forced color profile")
+		}
+		for _, leak := range []string{"alice-laptop", "cc:dd:ee:ff", "203.0.113.45"} {
+			if strings.Contains(out, leak) {
+				t.Errorf("tab %d leaks %q in styled output", tab, leak)
+			}
+		}
+	}
+	if got := maskSensitive("\x1b[1mweb1\x1b[0m \x1b[32maa:bb:cc:dd:ee:ff", regexp.MustCompile("web1")); got != "\x1b[1m<hostname>\x1b[0m \x1b[32maa:bb:**:**:**:**" {
+		t.Errorf("maskSensitive kept values after escape codes: %q", got)
+	}
+}
+
+func TestMaskHostname_Boundaries(t *testing.T) {
+	m := Model{PrivacyMode: true, HostInfo: collector.HostInfo{Hostname: "web1"}}
+	m.updateHostnameMask()
+	tests := []struct{ in, want string }{
+		{"Hostname: web1", "Hostname: <hostname>"},
+		{"web1.example.com", "<hostname>.example.com"},
+		{"WEB1 (web1)", "<hostname> (<hostname>)"},
+		{"web1-backup", "web1-backup"},
+		{"oldweb1 web10 web1_a", "oldweb1 web10 web1_a"},
+	}
+	for _, tt := range tests {
+		if got := maskSensitive(tt.in, m.hostnameMask); got != tt.want {
+			t.Errorf("maskSensitive(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	m.PrivacyMode = false
+	m.updateHostnameMask()
+	if m.hostnameMask != nil {
+		t.Error("the pattern should be dropped when privacy mode is off")
+	}
+}
+
+func TestBaseline_DeviationsRefreshOnData(t *testing.T) {
+	m := newTestModel()
+	m.Baseline = &report.Baseline{Name: "home", Routes: []string{"default via 192.168.1.1 dev eth0"}}
+	if out := m.renderBaseline(); !strings.Contains(out, "Within tolerance") {
+		t.Errorf("no data yet, want no deviations:\n%s", out)
+	}
+
+	updated, _ := m.Update(SystemInfoMsg(collector.HostInfo{Routes: []string{"default via 10.8.0.1 dev tun0"}}))
+	m = updated.(Model)
+	out := m.renderBaseline()
+	for _, want := range []string{"Route default via 10.8.0.1 dev tun0:", "added", "Route default via 192.168.1.1 dev eth0:", "missing"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("deviations lack %q:\n%s", want, out)
+		}
+	}
+	if !strings.Contains(m.View(), "baseline 'home': 2 deviations") {
+		t.Error("footer should count the cached deviations")
+	}
+}
+
+func TestStatusLines_ColorBlindSymbols(t *testing.T) {
+	if err := ui.SetTheme("deuteranopia"); err != nil {
+		t.Fatal(err)
+	}
+	defer ui.SetTheme("")
+
+	if out := renderOCSP(&collector.CertInfo{OCSPStatus: collector.OCSPRevoked}); !strings.Contains(out, "✗ revoked") {
+		t.Errorf("revoked staple without a symbol: %q", out)
+	}
+
+	m := newTestModel()
+	matrix := collector.ConnectivityMatrix{
+		Targets: []string{"192.0.2.1"},
+		Methods: []collector.MatrixMethod{collector.MethodICMP, collector.MethodTCP80},
+		Cells: map[string]map[collector.MatrixMethod]collector.MatrixCell{"192.0.2.1": {
+			collector.MethodICMP:  {Error: errors.New("no echo reply")},
+			collector.MethodTCP80: {Reachable: true, Latency: 5 * time.Millisecond},
+		}},
+	}
+	out := m.renderMatrix(matrix)
+	if !strings.Contains(out, "✗ BLOCKED") || !strings.Contains(out, "✓ OK 5ms") {
+		t.Errorf("matrix cells without symbols:\n%s", out)
+	}
+
+	m.recordError("Ping", errors.New("network unreachable"))
+	if out := m.statusLine(); !strings.Contains(out, "✗ [") {
+		t.Errorf("status line error without a symbol: %q", out)
+	}
+	if out := renderRcode("NXDOMAIN"); !strings.Contains(out, "! (NXDOMAIN") {
+		t.Errorf("probe domain rcode without a symbol: %q", out)
+	}
+	if out := m.renderPathMTU([]collector.PathMTUResult{{Target: "vpn.example", MTU: 1420, InterfaceMTU: 1500}}); !strings.Contains(out, "  ! vpn.example") {
+		t.Errorf("reduced path MTU without a symbol after the indentation:\n%s", out)
+	}
+}
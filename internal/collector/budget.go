@@ -0,0 +1,131 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrBudgetExceeded means a collect cycle was skipped to stay within the probe budget
+var ErrBudgetExceeded = errors.New("probe budget exceeded, cycle skipped")
+
+// ErrBudgetTooSmall means a run of probes needs more than the budget holds
+// per minute, so it can never fit until the limit is raised
+var ErrBudgetTooSmall = fmt.Errorf("%w: the run needs more than the whole budget per minute", ErrBudgetExceeded)
+
+// Rough wire cost of one probe, request plus reply with IP headers
+const (
+	pingProbeBytes    = 2 * (20 + 8 + 24) // ICMP echo with pro-bing's 24 byte payload
+	dnsProbeBytes     = 2 * 120           // Small UDP query and answer
+	tunnelProbeBytes  = 4096              // TCP/TLS handshake through a proxy
+	tcpProbeBytes     = 4 * 60            // TCP handshake and close without payload
+	stunProbeBytes    = 2 * (20 + 8 + 48) // STUN binding request and response
+	httpsProbeBytes   = 8192              // TLS handshake with certificates and a small HTTP exchange
+	axfrProbeBytes    = 64 << 10          // Reserved for a zone transfer, most are refused
+	natTestsPerTarget = 4                 // Test I, II, I against the other address and III
+)
+
+// Budget is a token bucket shared by the collectors that send traffic, so a
+// metered connection sees at most ProbesPerMinute probes and BytesPerMinute
+// bytes. The buckets start full and refill continuously. A zero limit is
+// unlimited, and a nil Budget allows everything.
+type Budget struct {
+	ProbesPerMinute int
+	BytesPerMinute  int64
+
+	mu     sync.Mutex
+	probes float64
+	bytes  float64
+	last   time.Time
+	now    func() time.Time
+}
+
+func NewBudget(probesPerMinute int, bytesPerMinute int64) *Budget {
+	return &Budget{
+		ProbesPerMinute: probesPerMinute,
+		BytesPerMinute:  bytesPerMinute,
+		probes:          float64(probesPerMinute),
+		bytes:           float64(bytesPerMinute),
+		now:             time.Now,
+	}
+}
+
+// Enabled reports whether any limit is set
+func (b *Budget) Enabled() bool {
+	return b != nil && (b.ProbesPerMinute > 0 || b.BytesPerMinute > 0)
+}
+
+// refill adds the tokens earned since the last call, b.mu must be held
+func (b *Budget) refill() {
+	now := b.now()
+	if !b.last.IsZero() {
+		minutes := now.Sub(b.last).Minutes()
+		b.probes = min(b.probes+minutes*float64(b.ProbesPerMinute), float64(b.ProbesPerMinute))
+		b.bytes = min(b.bytes+minutes*float64(b.BytesPerMinute), float64(b.BytesPerMinute))
+	}
+	b.last = now
+}
+
+// Allow takes probes and bytes from the budget if both fit, and takes
+// nothing otherwise. A request larger than a minute's limit is cut to it:
+// it waits for a full bucket and drains it, rather than never fitting.
+func (b *Budget) Allow(probes int, bytes int64) bool {
+	if !b.Enabled() {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill()
+	probes = min(probes, b.ProbesPerMinute)
+	bytes = min(bytes, b.BytesPerMinute)
+	if b.ProbesPerMinute > 0 && float64(probes) > b.probes {
+		return false
+	}
+	if b.BytesPerMinute > 0 && float64(bytes) > b.bytes {
+		return false
+	}
+	if b.ProbesPerMinute > 0 {
+		b.probes -= float64(probes)
+	}
+	if b.BytesPerMinute > 0 {
+		b.bytes -= float64(bytes)
+	}
+	return true
+}
+
+// Fits checks whether probes and bytes are available now without taking
+// them, so a run of many probes is refused as a whole rather than half done.
+// It returns ErrBudgetExceeded when the run has to wait for a refill and
+// ErrBudgetTooSmall when it is larger than a minute's limit.
+func (b *Budget) Fits(probes int, bytes int64) error {
+	if !b.Enabled() {
+		return nil
+	}
+	if b.ProbesPerMinute > 0 && probes > b.ProbesPerMinute || b.BytesPerMinute > 0 && bytes > b.BytesPerMinute {
+		return ErrBudgetTooSmall
+	}
+	p, by := b.Remaining()
+	if (p >= 0 && probes > p) || (by >= 0 && bytes > by) {
+		return ErrBudgetExceeded
+	}
+	return nil
+}
+
+// Remaining returns what is left right now, -1 for an unlimited dimension
+func (b *Budget) Remaining() (probes int, bytes int64) {
+	if !b.Enabled() {
+		return -1, -1
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill()
+	probes, bytes = -1, -1
+	if b.ProbesPerMinute > 0 {
+		probes = int(b.probes)
+	}
+	if b.BytesPerMinute > 0 {
+		bytes = int64(b.bytes)
+	}
+	return probes, bytes
+}
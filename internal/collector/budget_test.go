@@ -0,0 +1,153 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func fixedBudget(probes int, bytes int64) (*Budget, *time.Time) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	b := NewBudget(probes, bytes)
+	b.now = func() time.Time { return now }
+	return b, &now
+}
+
+func TestBudget_Refill(t *testing.T) {
+	b, now := fixedBudget(60, 0)
+	if !b.Allow(60, 1<<30) {
+		t.Fatal("a full bucket should allow its capacity, bytes are unlimited")
+	}
+	if b.Allow(1, 0) {
+		t.Fatal("an empty bucket should refuse")
+	}
+	*now = now.Add(10 * time.Second)
+	if probes, bytes := b.Remaining(); probes != 10 || bytes != -1 {
+		t.Errorf("Remaining() = %d, %d, want 10, -1", probes, bytes)
+	}
+	*now = now.Add(time.Hour)
+	if probes, _ := b.Remaining(); probes != 60 {
+		t.Errorf("refill should stop at capacity, got %d", probes)
+	}
+
+	var unlimited *Budget
+	if !unlimited.Allow(1000, 1<<40) {
+		t.Error("a nil budget should allow everything")
+	}
+}
+
+func TestBudget_LargerThanCapacity(t *testing.T) {
+	b, now := fixedBudget(10, 1000)
+	if err := b.Fits(11, 0); !errors.Is(err, ErrBudgetTooSmall) || !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("Fits() over capacity = %v, want ErrBudgetTooSmall", err)
+	}
+	if err := b.Fits(10, 1000); err != nil {
+		t.Errorf("Fits() at capacity = %v", err)
+	}
+
+	// A single request larger than a minute's limit drains a full bucket
+	if !b.Allow(50, 200<<20) {
+		t.Fatal("an oversized request should be allowed once the bucket is full")
+	}
+	if probes, bytes := b.Remaining(); probes != 0 || bytes != 0 {
+		t.Errorf("Remaining() = %d, %d, want a drained bucket", probes, bytes)
+	}
+	if b.Allow(50, 200<<20) {
+		t.Error("an oversized request should wait for the bucket to refill")
+	}
+	if err := b.Fits(1, 0); !errors.Is(err, ErrBudgetExceeded) || errors.Is(err, ErrBudgetTooSmall) {
+		t.Errorf("Fits() on an empty bucket = %v, want ErrBudgetExceeded", err)
+	}
+	*now = now.Add(time.Minute)
+	if !b.Allow(50, 200<<20) {
+		t.Error("an oversized request should be allowed again after a full refill")
+	}
+}
+
+func TestConnectivityCollector_BudgetSkipsCycle(t *testing.T) {
+	b, _ := fixedBudget(100, 0)
+	b.Allow(99, 0) // One probe left, fewer than a cycle needs
+	c := NewConnectivityCollector()
+	c.Targets = []string{"192.0.2.1"}
+	c.Budget = b
+
+	stats, err := c.Collect()
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("Collect() error = %v, want ErrBudgetExceeded", err)
+	}
+	if len(stats.Targets) != 0 {
+		t.Errorf("a skipped cycle should not ping, got %v", stats.Targets)
+	}
+	if probes, _ := b.Remaining(); probes != 1 {
+		t.Errorf("a skipped cycle should not consume budget, %d left", probes)
+	}
+}
+
+func TestDNSCollector_BudgetPerQuery(t *testing.T) {
+	var queries atomic.Int32
+	addr := startMockDNS(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		queries.Add(1)
+		answerA("192.0.2.1")(w, r)
+	})
+	b, _ := fixedBudget(1, 0)
+	c := NewDNSCollector()
+	c.Budget = b
+	server := DNSServer{Name: "Mock", Address: addr, Proto: ProtoUDP}
+
+	if res := c.Lookup(context.Background(), "example.com", RecordA, server); res.Error != nil {
+		t.Fatalf("first Lookup() error = %v", res.Error)
+	}
+	if res := c.Lookup(context.Background(), "example.com", RecordA, server); !errors.Is(res.Error, ErrBudgetExceeded) {
+		t.Errorf("second Lookup() error = %v, want ErrBudgetExceeded", res.Error)
+	}
+	if n := queries.Load(); n != 1 {
+		t.Errorf("server saw %d queries, want 1", n)
+	}
+}
+
+func TestRegionCollector_BudgetSkipsEndpoints(t *testing.T) {
+	b, _ := fixedBudget(2, 0)
+	c := NewRegionCollector([]RegionEndpoint{{Name: "a", Address: "a:443"}, {Name: "b", Address: "b:443"}, {Name: "c", Address: "c:443"}})
+	var dials atomic.Int32
+	c.dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+		dials.Add(1)
+		client, server := net.Pipe()
+		server.Close()
+		return client, nil
+	}
+	c.Budget = b
+
+	skipped := 0
+	for _, r := range c.Collect() {
+		if errors.Is(r.Error, ErrBudgetExceeded) {
+			skipped++
+		}
+	}
+	if dials.Load() != 2 || skipped != 1 {
+		t.Errorf("dials = %d, skipped = %d, want 2 and 1", dials.Load(), skipped)
+	}
+}
+
+func TestMatrixCollector_BudgetRunsOutMidRow(t *testing.T) {
+	// ICMP costs three probes, TCP:80 one: the row's TCP:443 and UDP:53 are skipped
+	b, _ := fixedBudget(4, 0)
+	c := NewMatrixCollector([]string{"host.example"})
+	for _, method := range c.Methods {
+		c.probes[method] = func(string) MatrixCell { return MatrixCell{Reachable: true} }
+	}
+	c.Budget = b
+
+	matrix := c.Collect()
+	for _, method := range c.Methods {
+		cell, _ := matrix.Cell("host.example", method)
+		skipped := errors.Is(cell.Error, ErrBudgetExceeded)
+		if want := method == MethodTCP443 || method == MethodUDPDNS; skipped != want {
+			t.Errorf("%s: skipped = %v, want %v", method, skipped, want)
+		}
+	}
+}
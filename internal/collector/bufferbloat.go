@@ -0,0 +1,320 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Bufferbloat test defaults. The load phase stops at whichever of duration
+// and data volume is reached first.
+const (
+	DefaultBufferbloatTarget   = "1.1.1.1"
+	DefaultBufferbloatDownload = "https://speed.cloudflare.com/__down?bytes=100000000"
+	DefaultBufferbloatUpload   = "https://speed.cloudflare.com/__up"
+	DefaultBufferbloatDuration = 10 * time.Second
+	DefaultBufferbloatMaxBytes = 200 << 20
+
+	bufferbloatBaselineSamples = 5
+	bufferbloatPingInterval    = 200 * time.Millisecond
+	bufferbloatUploadChunk     = 8 << 20
+)
+
+// BufferbloatResult compares idle RTT with RTT while the link is saturated
+type BufferbloatResult struct {
+	Target     string
+	Baseline   time.Duration // Median idle RTT
+	Loaded     time.Duration // Median RTT under load
+	Increase   time.Duration
+	Grade      string // A (best) to F
+	Samples    int    // RTT samples taken under load
+	Lost       int    // Samples lost under load
+	Downloaded int64
+	Uploaded   int64
+	Error      error
+}
+
+type BufferbloatCollector struct {
+	Target      string // Pinged during the test
+	DownloadURL string
+	UploadURL   string
+	Duration    time.Duration // Length of the load phase
+	MaxBytes    int64         // Upper bound for downloaded plus uploaded bytes
+	Streams     int           // Concurrent transfers per direction
+	Budget      *Budget       // Shared probe budget, MaxBytes is reserved up front
+	PingOptions
+	Source *SourceInterface // Binds the RTT samples to one interface's addresses
+
+	client *http.Client
+	rtt    func(ctx context.Context, target string) (time.Duration, error)
+}
+
+func NewBufferbloatCollector() *BufferbloatCollector {
+	c := &BufferbloatCollector{
+		Target:      DefaultBufferbloatTarget,
+		DownloadURL: DefaultBufferbloatDownload,
+		UploadURL:   DefaultBufferbloatUpload,
+		Duration:    DefaultBufferbloatDuration,
+		MaxBytes:    DefaultBufferbloatMaxBytes,
+		Streams:     4,
+		client:      &http.Client{},
+	}
+	c.Privileged = true
+	c.rtt = func(ctx context.Context, target string) (time.Duration, error) {
+		return sampleRTT(ctx, target, c.PingOptions, c.Source)
+	}
+	return c
+}
+
+// Measure takes a baseline, then saturates the link in both directions while
+// pinging the target
+func (c *BufferbloatCollector) Measure(ctx context.Context) BufferbloatResult {
+	res := BufferbloatResult{Target: c.Target}
+	probes := bufferbloatBaselineSamples + int(c.Duration/bufferbloatPingInterval)
+	if !c.Budget.Allow(probes, c.MaxBytes+int64(probes*pingProbeBytes)) {
+		res.Error = ErrBudgetExceeded
+		return res
+	}
+
+	var baseline []time.Duration
+	for i := 0; i < bufferbloatBaselineSamples; i++ {
+		if rtt, err := c.rtt(ctx, c.Target); err == nil {
+			baseline = append(baseline, rtt)
+		}
+		if !sleepCtx(ctx, bufferbloatPingInterval) {
+			break
+		}
+	}
+	if len(baseline) == 0 {
+		res.Error = fmt.Errorf("no reply from %s, cannot take a baseline", c.Target)
+		return res
+	}
+
+	loadCtx, cancel := context.WithTimeout(ctx, c.Duration)
+	defer cancel()
+
+	var downloaded, uploaded atomic.Int64
+	budget := func(n int64, counter *atomic.Int64) bool {
+		counter.Add(n)
+		if downloaded.Load()+uploaded.Load() >= c.MaxBytes {
+			cancel()
+			return false
+		}
+		return true
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var loadErr error
+	keepErr := func(err error) {
+		mu.Lock()
+		if loadErr == nil {
+			loadErr = err
+		}
+		mu.Unlock()
+	}
+	for i := 0; i < c.Streams; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if err := loadDownload(loadCtx, c.client, c.DownloadURL, func(n int64) bool { return budget(n, &downloaded) }); err != nil {
+				keepErr(err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if err := loadUpload(loadCtx, c.client, c.UploadURL, func(n int64) bool { return budget(n, &uploaded) }); err != nil {
+				keepErr(err)
+			}
+		}()
+	}
+
+	// Give the transfers a moment to fill the queues before sampling
+	sleepCtx(loadCtx, bufferbloatPingInterval)
+	var loaded []time.Duration
+	for loadCtx.Err() == nil {
+		res.Samples++
+		if rtt, err := c.rtt(loadCtx, c.Target); err == nil {
+			loaded = append(loaded, rtt)
+		} else if loadCtx.Err() == nil {
+			res.Lost++
+		} else {
+			res.Samples-- // Cut short by the end of the test
+		}
+		sleepCtx(loadCtx, bufferbloatPingInterval)
+	}
+	cancel()
+	wg.Wait()
+
+	res.Downloaded, res.Uploaded = downloaded.Load(), uploaded.Load()
+	if res.Downloaded+res.Uploaded == 0 {
+		if loadErr != nil {
+			res.Error = fmt.Errorf("could not load the link: %v", loadErr)
+		} else {
+			res.Error = fmt.Errorf("could not load the link")
+		}
+		return res
+	}
+	if len(loaded) == 0 {
+		res.Error = fmt.Errorf("no reply from %s under load", c.Target)
+		res.Baseline = median(baseline)
+		res.Grade = "F"
+		return res
+	}
+
+	res.Baseline, res.Loaded = median(baseline), median(loaded)
+	res.Increase, res.Grade = gradeBufferbloat(baseline, loaded)
+	return res
+}
+
+// gradeBufferbloat grades the median RTT increase under load on the
+// DSLReports/Waveform scale
+func gradeBufferbloat(baseline, loaded []time.Duration) (time.Duration, string) {
+	increase := median(loaded) - median(baseline)
+	if increase < 0 {
+		increase = 0
+	}
+	switch {
+	case increase < 30*time.Millisecond:
+		return increase, "A"
+	case increase < 60*time.Millisecond:
+		return increase, "B"
+	case increase < 200*time.Millisecond:
+		return increase, "C"
+	case increase < 400*time.Millisecond:
+		return increase, "D"
+	default:
+		return increase, "F"
+	}
+}
+
+func median(samples []time.Duration) time.Duration {
+	if len(samples) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+// loadDownload fetches url repeatedly until the context ends or account
+// refuses more bytes
+func loadDownload(ctx context.Context, client *http.Client, url string, account func(int64) bool) error {
+	buf := make([]byte, 64<<10)
+	for ctx.Err() == nil {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		for {
+			n, err := resp.Body.Read(buf)
+			if n > 0 && !account(int64(n)) {
+				resp.Body.Close()
+				return nil
+			}
+			if err != nil {
+				break
+			}
+		}
+		resp.Body.Close()
+	}
+	return nil
+}
+
+// loadUpload posts zero-filled chunks to url until the context ends or
+// account refuses more bytes
+func loadUpload(ctx context.Context, client *http.Client, url string, account func(int64) bool) error {
+	for ctx.Err() == nil {
+		body := &countingReader{r: io.LimitReader(zeroReader{}, bufferbloatUploadChunk), account: account}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, body)
+		if err != nil {
+			return err
+		}
+		req.ContentLength = bufferbloatUploadChunk
+		resp, err := client.Do(req)
+		if err != nil {
+			if ctx.Err() != nil || body.stopped.Load() {
+				return nil
+			}
+			return err
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if body.stopped.Load() {
+			return nil
+		}
+	}
+	return nil
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+// countingReader reports bytes read and stops once the budget is spent
+type countingReader struct {
+	r       io.Reader
+	account func(int64) bool
+	stopped atomic.Bool
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	if c.stopped.Load() {
+		return 0, io.EOF
+	}
+	n, err := c.r.Read(p)
+	if n > 0 && !c.account(int64(n)) {
+		c.stopped.Store(true)
+	}
+	return n, err
+}
+
+// sampleRTT takes one ICMP echo from src with opts' privilege and payload,
+// falling back to a TCP connect to port 443
+func sampleRTT(ctx context.Context, target string, opts PingOptions, src *SourceInterface) (time.Duration, error) {
+	opts.Count, opts.Timeout = 1, time.Second
+	if pinger, err := newICMPPinger(target, 0, opts, src); err == nil {
+		if err := pinger.RunWithContext(ctx); err == nil {
+			if stats := pinger.Statistics(); stats.PacketsRecv > 0 {
+				return stats.AvgRtt, nil
+			}
+		}
+	}
+
+	res := tcpProbe(&markedDialer{Source: src, Timeout: time.Second}, target, 443)
+	if res.Reachability == ReachOpen || res.Reachability == ReachClosed {
+		return res.AvgRtt, nil
+	}
+	return 0, res.Error
+}
+
+// sleepCtx waits for d, returning false if the context ended first
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
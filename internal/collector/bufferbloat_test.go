@@ -0,0 +1,132 @@
+package collector
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func ms(values ...int) []time.Duration {
+	var d []time.Duration
+	for _, v := range values {
+		d = append(d, time.Duration(v)*time.Millisecond)
+	}
+	return d
+}
+
+func TestGradeBufferbloat(t *testing.T) {
+	tests := []struct {
+		name         string
+		baseline     []time.Duration
+		loaded       []time.Duration
+		wantIncrease time.Duration
+		wantGrade    string
+	}{
+		{"no bloat", ms(20, 21, 19), ms(22, 25, 21), 2 * time.Millisecond, "A"},
+		{"loaded faster than idle", ms(30, 30, 30), ms(25, 28, 29), 0, "A"},
+		{"just below B", ms(10, 10), ms(39, 39), 29 * time.Millisecond, "A"},
+		{"B boundary", ms(10, 10), ms(40, 40), 30 * time.Millisecond, "B"},
+		{"C", ms(15, 12, 18), ms(90, 300, 110, 95), 87500 * time.Microsecond, "C"},
+		{"D", ms(20), ms(250, 260, 270), 240 * time.Millisecond, "D"},
+		{"F", ms(20, 20, 20), ms(600, 800, 700), 680 * time.Millisecond, "F"},
+	}
+	for _, tt := range tests {
+		increase, grade := gradeBufferbloat(tt.baseline, tt.loaded)
+		if increase != tt.wantIncrease || grade != tt.wantGrade {
+			t.Errorf("%s: got %s %s, want %s %s", tt.name, increase, grade, tt.wantIncrease, tt.wantGrade)
+		}
+	}
+}
+
+// throttledServer streams data both ways at about 1.6 MB/s per transfer
+func throttledServer(t *testing.T) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf := make([]byte, 32<<10)
+		if r.Method == http.MethodPost {
+			for {
+				if _, err := io.ReadFull(r.Body, buf); err != nil {
+					return
+				}
+				time.Sleep(20 * time.Millisecond)
+			}
+		}
+		for r.Context().Err() == nil {
+			if _, err := w.Write(buf); err != nil {
+				return
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newTestBufferbloatCollector(srv *httptest.Server) *BufferbloatCollector {
+	// RTT grows once the transfers are running
+	var loading atomic.Bool
+	c := NewBufferbloatCollector()
+	c.Target = "test"
+	c.DownloadURL = srv.URL
+	c.UploadURL = srv.URL
+	c.Streams = 2
+	c.client = srv.Client()
+	wrapped := c.client.Transport
+	c.client.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		loading.Store(true)
+		return wrapped.RoundTrip(r)
+	})
+	c.rtt = func(ctx context.Context, target string) (time.Duration, error) {
+		if loading.Load() {
+			return 120 * time.Millisecond, nil
+		}
+		return 20 * time.Millisecond, nil
+	}
+	return c
+}
+
+func TestBufferbloatCollector_Measure(t *testing.T) {
+	c := newTestBufferbloatCollector(throttledServer(t))
+	c.Duration = 1500 * time.Millisecond
+
+	start := time.Now()
+	res := c.Measure(context.Background())
+	if res.Error != nil {
+		t.Fatalf("Measure() error = %v", res.Error)
+	}
+	if elapsed := time.Since(start); elapsed > 4*time.Second {
+		t.Errorf("test ran for %s, longer than baseline plus Duration", elapsed)
+	}
+	if res.Downloaded == 0 || res.Uploaded == 0 {
+		t.Errorf("expected traffic both ways, got down %d up %d", res.Downloaded, res.Uploaded)
+	}
+	if res.Baseline != 20*time.Millisecond || res.Loaded != 120*time.Millisecond || res.Increase != 100*time.Millisecond || res.Grade != "C" {
+		t.Errorf("result = %+v", res)
+	}
+	if res.Samples == 0 || res.Lost != 0 {
+		t.Errorf("samples = %d, lost = %d", res.Samples, res.Lost)
+	}
+}
+
+func TestBufferbloatCollector_ByteBudget(t *testing.T) {
+	c := newTestBufferbloatCollector(throttledServer(t))
+	c.Duration = 30 * time.Second
+	c.MaxBytes = 1 << 20
+
+	start := time.Now()
+	res := c.Measure(context.Background())
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("byte budget did not end the test, ran for %s", elapsed)
+	}
+	// Reads in flight may overshoot by what the socket buffers already accepted
+	if total := res.Downloaded + res.Uploaded; total < c.MaxBytes || total > c.MaxBytes+(32<<20) {
+		t.Errorf("transferred %d bytes with a budget of %d", total, c.MaxBytes)
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
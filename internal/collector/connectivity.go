@@ -2,31 +2,93 @@ package collector
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
 	"net"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
 
+	"github.com/miekg/dns"
 	ping "github.com/prometheus-community/pro-bing"
 	"github.com/vishvananda/netlink"
 )
 
 type ConnectivityCollector struct {
-	Targets []string
+	Targets     []string
+	IPv6Targets []string // Pinged with the IPv6 gateway when there is an IPv6 default route
+	PingOptions
+	Source *SourceInterface // Binds pings and the DNS check to one interface's addresses
+	Budget *Budget          // Shared probe budget, nil for unlimited
+
+	// DNS check
+	DNSDomain string    // Name looked up through both resolvers
+	LocalDNS  DNSServer // The system resolver by default
+	PublicDNS DNSServer // Cloudflare over UDP by default, any protocol of the DNS collector works
+	// PublicDNSErr, when set, is reported as the public resolver's error
+	// instead of timing PublicDNS, e.g. a configured resolver that does not parse
+	PublicDNSErr error
+
+	// PublicCandidates are raced on the first check and the fastest one
+	// replaces PublicDNS for the session; a race nobody answers is run
+	// again on the next check. Empty keeps PublicDNS as is.
+	PublicCandidates []DNSServer
+	publicMu         sync.Mutex
+	publicPicked     bool // The race has a winner, it is not run again
+	publicAuto       bool // PublicDNS came from the race
+
+	// ReverseDNS names IP targets, the gateway among them, by their PTR
+	// records looked up through LocalDNS in the background; a name shows
+	// from the refresh after its lookup finished
+	ReverseDNS bool
+	ptrMu      sync.Mutex
+	ptrNames   map[string]string // Answered lookups for the session, "" without a PTR record
+	ptrPending map[string]bool   // Lookups still running, not started again
+
+	dns         *DNSCollector
+	ping        func(target string) PingResult
+	gateway     func(family int) (string, error)
+	nameservers func() []DNSServer // System resolvers timed besides the two above
+	ptr         func(ip string) (name string, err error)
+}
+
+// PublicDNSCandidates are the well-known resolvers raced for the public DNS
+// check, so one blocked or slow resolver does not skew the benchmark
+var PublicDNSCandidates = []DNSServer{
+	{Name: "Cloudflare", Address: "1.1.1.1:53", Proto: ProtoUDP},
+	{Name: "Google", Address: "8.8.8.8:53", Proto: ProtoUDP},
+	{Name: "Quad9", Address: "9.9.9.9:53", Proto: ProtoUDP},
+	{Name: "AliDNS", Address: "223.5.5.5:53", Proto: ProtoUDP},
+	{Name: "114DNS", Address: "114.114.114.114:53", Proto: ProtoUDP},
 }
 
 func NewConnectivityCollector() *ConnectivityCollector {
-	return &ConnectivityCollector{
-		Targets: []string{"8.8.8.8", "bing.com", "114.114.114.114", "qq.com"},
+	c := &ConnectivityCollector{
+		Targets:     []string{"8.8.8.8", "bing.com", "114.114.114.114", "qq.com"},
+		IPv6Targets: []string{"2606:4700:4700::1111"},
+		Source:      &SourceInterface{},
+		DNSDomain:   "google.com",
+		LocalDNS:    DNSServer{Name: "System", Proto: ProtoUDP},
+		PublicDNS:   DNSServer{Name: "Cloudflare", Address: "1.1.1.1:53", Proto: ProtoUDP},
+		ReverseDNS:  true,
+		dns:         NewDNSCollector(),
+		gateway:     defaultGateway,
+		nameservers: func() []DNSServer { return resolvConfNameservers(resolvConfPath) },
 	}
+	c.dns.Source = c.Source // The DNS check leaves through the same interface
+	c.Privileged = true
+	c.ping = func(target string) PingResult { return c.PingWithOptions(target, c.PingOptions) }
+	c.ptr = c.lookupPTR
+	return c
 }
 
 func (c *ConnectivityCollector) Collect() (stats ConnectivityStats, err error) {
-	defer func() {
-		if r := recover(); r != nil {
-			err = fmt.Errorf("panic in ConnectivityCollector: %v", r)
-		}
-	}()
+	defer recoverPartial("ConnectivityCollector", &stats.Partial, &stats.Error, &err)
 
 	stats = ConnectivityStats{
 		Targets: make(map[string]PingResult),
@@ -39,85 +101,291 @@ func (c *ConnectivityCollector) Collect() (stats ConnectivityStats, err error) {
 	copy(targetsToPing, c.Targets)
 
 	// Add Gateway to targets (locally)
-	gw, err := getDefaultGateway()
-	if err == nil && gw != "" {
-		// Check if gw is already in targets
-		found := false
-		for _, t := range targetsToPing {
-			if t == gw {
-				found = true
-				break
-			}
-		}
-		if !found {
-			targetsToPing = append([]string{gw}, targetsToPing...)
-		}
+	gw, err := c.gateway(netlink.FAMILY_V4)
+	if err == nil && gw != "" && !slices.Contains(targetsToPing, gw) {
+		targetsToPing = append([]string{gw}, targetsToPing...)
+	}
+
+	// IPv6 is probed only with a default route, its gateway first
+	var targetsV6 []string
+	if gw6, err := c.gateway(netlink.FAMILY_V6); err == nil && gw6 != "" {
+		stats.IPv6Gateway = gw6
+		stats.IPv6Targets = make(map[string]PingResult)
+		targetsV6 = append([]string{gw6}, c.IPv6Targets...)
+	}
+
+	// The echoes to each target plus one query to each resolver, and one
+	// for each IP target not yet reverse resolved
+	var reverse []string
+	if c.ReverseDNS {
+		reverse = c.unnamed(append(slices.Clone(targetsToPing), targetsV6...))
+	}
+	pings := len(targetsToPing) + len(targetsV6)
+	queries := 2 + len(c.nameservers()) + len(reverse)
+	probes := pings*c.count() + queries
+	bytes := int64(pings*c.count()*c.probeBytes() + queries*dnsProbeBytes)
+	if !c.Budget.Allow(probes, bytes) {
+		return stats, ErrBudgetExceeded
 	}
 
 	var wg sync.WaitGroup
 	var mu sync.Mutex
+	// A panicking probe must not take the process down with it, keep the
+	// other results and record the panic where its result would have been
+	probeFailed := func(name string, r any) error {
+		mu.Lock()
+		defer mu.Unlock()
+		stats.Partial = true
+		err := fmt.Errorf("panic in %s: %v", name, r)
+		if stats.Error == nil {
+			stats.Error = err
+		}
+		return err
+	}
 
 	// Ping Targets
+	pingInto := func(results map[string]PingResult, t string) {
+		defer wg.Done()
+		defer func() {
+			if r := recover(); r != nil {
+				err := probeFailed("ping "+t, r)
+				mu.Lock()
+				results[t] = PingResult{Target: t, Error: err}
+				mu.Unlock()
+			}
+		}()
+		res := c.ping(t)
+		mu.Lock()
+		results[t] = res
+		mu.Unlock()
+	}
 	for _, target := range targetsToPing {
 		wg.Add(1)
-		go func(t string) {
-			defer wg.Done()
-			res := pingTarget(t)
-			mu.Lock()
-			stats.Targets[t] = res
-			mu.Unlock()
-		}(target)
+		go pingInto(stats.Targets, target)
+	}
+	for _, target := range targetsV6 {
+		wg.Add(1)
+		go pingInto(stats.IPv6Targets, target)
+	}
+
+	// PTR names, neither the pings nor this refresh wait for them: a name
+	// that is not back by the end of the refresh shows on a later one
+	for _, ip := range reverse {
+		if !c.startPTR(ip) {
+			continue
+		}
+		go c.resolvePTR(ip)
 	}
 
 	// DNS Check
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		dnsRes := checkDNS()
+		defer func() {
+			if r := recover(); r != nil {
+				err := probeFailed("DNS check", r)
+				mu.Lock()
+				stats.DNS.Error = err
+				mu.Unlock()
+			}
+		}()
+		dnsRes := c.checkDNS()
 		mu.Lock()
 		stats.DNS = dnsRes
 		mu.Unlock()
 	}()
 
 	wg.Wait()
-	return stats, nil
+	if c.ReverseDNS {
+		c.nameTargets(stats.Targets)
+		c.nameTargets(stats.IPv6Targets)
+	}
+	return stats, stats.Error
+}
+
+// unnamed returns the IP targets without a cached or running reverse
+// lookup, the ones a refresh has to pay for
+func (c *ConnectivityCollector) unnamed(targets []string) []string {
+	c.ptrMu.Lock()
+	defer c.ptrMu.Unlock()
+	var ips []string
+	for _, t := range targets {
+		ip, err := netip.ParseAddr(t)
+		if err != nil {
+			continue // A name already
+		}
+		key := ip.WithZone("").String()
+		if _, ok := c.ptrNames[key]; !ok && !c.ptrPending[key] && !slices.Contains(ips, key) {
+			ips = append(ips, key)
+		}
+	}
+	return ips
+}
+
+// startPTR marks a lookup of ip as running, false when one already is
+func (c *ConnectivityCollector) startPTR(ip string) bool {
+	c.ptrMu.Lock()
+	defer c.ptrMu.Unlock()
+	if c.ptrPending[ip] {
+		return false
+	}
+	if c.ptrPending == nil {
+		c.ptrPending = make(map[string]bool)
+	}
+	c.ptrPending[ip] = true
+	return true
+}
+
+// resolvePTR looks up one IP and caches the answer for the session. A
+// failed lookup is not cached, the next refresh tries again.
+func (c *ConnectivityCollector) resolvePTR(ip string) {
+	defer func() {
+		c.ptrMu.Lock()
+		delete(c.ptrPending, ip)
+		c.ptrMu.Unlock()
+	}()
+	name, err := c.ptr(ip)
+	if err != nil {
+		return
+	}
+	c.ptrMu.Lock()
+	defer c.ptrMu.Unlock()
+	if c.ptrNames == nil {
+		c.ptrNames = make(map[string]string)
+	}
+	c.ptrNames[ip] = name
+}
+
+// nameTargets copies the cached PTR names onto the results of IP targets
+func (c *ConnectivityCollector) nameTargets(results map[string]PingResult) {
+	c.ptrMu.Lock()
+	defer c.ptrMu.Unlock()
+	for target, res := range results {
+		ip, err := netip.ParseAddr(target)
+		if err != nil {
+			continue
+		}
+		if name := c.ptrNames[ip.WithZone("").String()]; name != "" {
+			res.Hostname = name
+			results[target] = res
+		}
+	}
+}
+
+// lookupPTR asks the local resolver for the name of ip. NXDOMAIN or an
+// answer without a PTR record is an empty name rather than an error.
+func (c *ConnectivityCollector) lookupPTR(ip string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dnsIOTimeout)
+	defer cancel()
+	res := c.dns.Lookup(ctx, ip, RecordPTR, c.LocalDNS)
+	switch {
+	case res.Error != nil:
+		return "", res.Error
+	case res.ResponseCode == dns.RcodeToString[dns.RcodeNameError]:
+		return "", nil
+	case res.ResponseCode != dns.RcodeToString[dns.RcodeSuccess]:
+		return "", fmt.Errorf("reverse lookup of %s: %s", ip, res.ResponseCode)
+	}
+	for _, rr := range res.msg.Answer {
+		if ptr, ok := rr.(*dns.PTR); ok {
+			return strings.TrimSuffix(ptr.Ptr, "."), nil
+		}
+	}
+	return "", nil
 }
 
-func getDefaultGateway() (string, error) {
-	routes, err := netlink.RouteList(nil, netlink.FAMILY_V4)
+// defaultGateway returns the default gateway of one address family. A
+// link-local IPv6 gateway carries its interface as zone, fe80::1%eth0.
+func defaultGateway(family int) (string, error) {
+	routes, err := netlink.RouteList(nil, family)
 	if err != nil {
 		return "", err
 	}
 
 	for _, r := range routes {
-		if r.Dst == nil { // Default route
-			return r.Gw.String(), nil
+		if !isDefaultRoute(r) || r.Gw == nil {
+			continue
+		}
+		gw := r.Gw.String()
+		if r.Gw.IsLinkLocalUnicast() {
+			if link, err := netlink.LinkByIndex(r.LinkIndex); err == nil {
+				gw += "%" + link.Attrs().Name
+			}
 		}
+		return gw, nil
 	}
 	return "", fmt.Errorf("no default gateway found")
 }
 
+// isDefaultRoute reports whether r matches every destination. Depending on
+// the kernel and netlink version the destination is nil or 0.0.0.0/0 (::/0).
+func isDefaultRoute(r netlink.Route) bool {
+	if r.Dst == nil {
+		return true
+	}
+	ones, _ := r.Dst.Mask.Size()
+	return ones == 0
+}
+
+// Ping pings target with the collector's options
 func (c *ConnectivityCollector) Ping(target string) PingResult {
-	return pingTarget(target)
+	return c.ping(target)
 }
 
-func pingTarget(target string) PingResult {
-	pinger, err := ping.NewPinger(target)
+// PingWithOptions pings target from the collector's source interface,
+// falling back to TCP connects when ICMP is not permitted
+func (c *ConnectivityCollector) PingWithOptions(target string, opts PingOptions) PingResult {
+	return pingTarget(target, opts, c.Source)
+}
+
+func pingTarget(target string, opts PingOptions, src *SourceInterface) PingResult {
+	dscp := opts.DSCP
+	var dscpErr error
+	tclass := 0
+	if dscp != 0 {
+		tclass, dscpErr = dscpTOS(dscp)
+	}
+
+	if opts.Pattern != PayloadDefault {
+		res, err := patternPing(target, opts, tclass, src)
+		if err != nil && tclass != 0 && strings.Contains(err.Error(), "traffic class") {
+			dscpErr = &DSCPError{DSCP: dscp, Err: err}
+			res, err = patternPing(target, opts, 0, src)
+		}
+		if err == nil {
+			res.DSCPError = dscpErr
+			return res
+		}
+		res = tcpPing(target, dscp, src)
+		if res.DSCPError == nil {
+			res.DSCPError = dscpErr
+		}
+		return res
+	}
+
+	pinger, err := newICMPPinger(target, tclass, opts, src)
 	if err != nil {
 		return PingResult{Target: target, Error: err}
 	}
 
-	pinger.Count = 3
-	pinger.Timeout = 2 * time.Second
-	pinger.SetPrivileged(true) // Try privileged (ICMP)
-
 	// Fallback to unprivileged if needed is handled by library usually,
 	// but on Linux usually requires root or sysctl net.ipv4.ping_group_range
 
 	err = pinger.Run()
+	if err != nil && tclass != 0 && strings.Contains(err.Error(), "traffic class") {
+		// Marking rejected, ping unmarked and report it
+		dscpErr = &DSCPError{DSCP: dscp, Err: err}
+		if pinger, err = newICMPPinger(target, 0, opts, src); err == nil {
+			err = pinger.Run()
+		}
+	}
 	if err != nil {
 		// Try TCP Ping if ICMP fails or permission denied
-		return tcpPing(target)
+		res := tcpPing(target, dscp, src)
+		if res.DSCPError == nil {
+			res.DSCPError = dscpErr
+		}
+		return res
 	}
 
 	stats := pinger.Statistics()
@@ -127,60 +395,310 @@ func pingTarget(target string) PingResult {
 		MinRtt:     stats.MinRtt,
 		AvgRtt:     stats.AvgRtt,
 		MaxRtt:     stats.MaxRtt,
+		StdDevRtt:  stats.StdDevRtt,
+		Jitter:     rttJitter(stats.Rtts),
+		DSCPError:  dscpErr,
 	}
 }
 
-func tcpPing(target string) PingResult {
-	start := time.Now()
-	conn, err := net.DialTimeout("tcp", net.JoinHostPort(target, "80"), 2*time.Second)
+// rttJitter is the mean absolute difference between consecutive RTTs, the
+// variation a real-time stream sees from one packet to the next
+func rttJitter(rtts []time.Duration) time.Duration {
+	if len(rtts) < 2 {
+		return 0
+	}
+	var sum time.Duration
+	for i := 1; i < len(rtts); i++ {
+		sum += (rtts[i] - rtts[i-1]).Abs()
+	}
+	return sum / time.Duration(len(rtts)-1)
+}
+
+// rttStdDev is the population standard deviation of rtts
+func rttStdDev(rtts []time.Duration) time.Duration {
+	if len(rtts) == 0 {
+		return 0
+	}
+	var mean float64
+	for _, rtt := range rtts {
+		mean += float64(rtt)
+	}
+	mean /= float64(len(rtts))
+	var variance float64
+	for _, rtt := range rtts {
+		variance += (float64(rtt) - mean) * (float64(rtt) - mean)
+	}
+	return time.Duration(math.Sqrt(variance / float64(len(rtts))))
+}
+
+// newICMPPinger prepares an ICMP pinger for opts with the given ToS byte,
+// sending from src's address of the target's family
+func newICMPPinger(target string, tclass int, opts PingOptions, src *SourceInterface) (*ping.Pinger, error) {
+	pinger, err := ping.NewPinger(target)
 	if err != nil {
-		// Try 443
-		conn, err = net.DialTimeout("tcp", net.JoinHostPort(target, "443"), 2*time.Second)
+		return nil, err
+	}
+	if ip := src.sourceFor(pinger.IPAddr().IP.String()); ip != nil {
+		pinger.Source = ip.String()
+	}
+	pinger.Count = opts.count()
+	pinger.Interval = opts.interval()
+	pinger.Size = opts.payloadSize()
+	pinger.Timeout = opts.duration() // pro-bing's timeout covers the whole run
+	pinger.SetPrivileged(opts.Privileged)
+	if tclass != 0 {
+		pinger.SetTrafficClass(uint8(tclass))
 	}
+	return pinger, nil
+}
 
+// tcpPing probes ports 80 and 443 and keeps the most telling verdict. A
+// refused connection still proves the host is up, so it counts as a reply.
+func tcpPing(target string, dscp int, src *SourceInterface) PingResult {
+	host, port, err := normalizeTCPTarget(target)
 	if err != nil {
-		return PingResult{Target: target, Error: err, PacketLoss: 100}
+		return PingResult{Target: target, PacketLoss: 100, Error: err}
+	}
+	ports := []int{80, 443}
+	if port != 0 {
+		ports = []int{port} // The user asked for this port, don't guess others
 	}
-	defer conn.Close()
 
-	rtt := time.Since(start)
-	return PingResult{
-		Target:     target,
-		PacketLoss: 0,
-		MinRtt:     rtt,
-		AvgRtt:     rtt,
-		MaxRtt:     rtt,
+	dialer := &markedDialer{DSCP: dscp, Source: src, Timeout: 2 * time.Second}
+	var res PingResult
+	for _, port := range ports {
+		probe := tcpProbe(dialer, host, port)
+		probe.Target = target
+		if res.Port == 0 || reachRank(probe.Reachability) > reachRank(res.Reachability) {
+			res = probe
+		}
+		if res.Reachability == ReachOpen {
+			break
+		}
+	}
+	res.DSCPError = dialer.MarkErr
+	return res
+}
+
+// normalizeTCPTarget splits a ping target into a dialable host and an
+// optional port (0 if none). It accepts IPs including zone-scoped IPv6
+// (fe80::1%eth0), bracketed IPv6, hostnames and host:port.
+func normalizeTCPTarget(target string) (string, int, error) {
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return "", 0, fmt.Errorf("empty target")
+	}
+	bare := target
+	if strings.HasPrefix(bare, "[") && strings.HasSuffix(bare, "]") {
+		bare = bare[1 : len(bare)-1]
+	}
+	if addr, err := netip.ParseAddr(bare); err == nil {
+		return addr.String(), 0, nil
+	}
+
+	host, port := target, 0
+	if h, p, err := net.SplitHostPort(target); err == nil {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 1 || n > 65535 {
+			return "", 0, fmt.Errorf("invalid port %q in target %q", p, target)
+		}
+		host, port = h, n
+		if addr, err := netip.ParseAddr(host); err == nil {
+			return addr.String(), port, nil
+		}
+	} else if strings.Count(target, ":") > 0 {
+		return "", 0, fmt.Errorf("invalid target %q: %v", target, err)
 	}
+
+	if _, ok := dns.IsDomainName(host); !ok || strings.ContainsAny(host, " /[]%@") {
+		return "", 0, fmt.Errorf("invalid target %q: not an IP address or hostname", target)
+	}
+	return host, port, nil
 }
 
-func checkDNS() DNSResult {
-	res := DNSResult{}
+// tcpProbeAddress is the host:port a TCP probe of target dials, port unless
+// the target names its own. A target that does not parse is kept as is for
+// the probe to report.
+func tcpProbeAddress(target string, port int) string {
+	host, p, err := normalizeTCPTarget(target)
+	if err != nil {
+		return target
+	}
+	if p == 0 {
+		p = port
+	}
+	return net.JoinHostPort(host, strconv.Itoa(p))
+}
 
-	// Local DNS
+// tcpProbe connects once and classifies the outcome
+func tcpProbe(dialer *markedDialer, target string, port int) PingResult {
+	res := PingResult{Target: target, Port: port}
 	start := time.Now()
-	_, err := net.LookupHost("google.com")
-	res.LocalResolverTime = time.Since(start)
-	if err != nil {
+	conn, err := dialer.Dial("tcp", net.JoinHostPort(target, strconv.Itoa(port)))
+	rtt := time.Since(start)
+	if conn != nil {
+		conn.Close()
+	}
+
+	res.Reachability = classifyDialError(err)
+	switch res.Reachability {
+	case ReachOpen, ReachClosed:
+		res.MinRtt, res.AvgRtt, res.MaxRtt = rtt, rtt, rtt
+	default:
+		res.PacketLoss = 100
 		res.Error = err
 	}
+	return res
+}
+
+// classifyDialError maps a TCP dial result to a reachability verdict
+func classifyDialError(err error) Reachability {
+	if err == nil {
+		return ReachOpen
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return ReachClosed
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return ReachFiltered
+	}
+	// ICMP unreachable from a router or a local firewall reject
+	if errors.Is(err, syscall.EHOSTUNREACH) || errors.Is(err, syscall.ENETUNREACH) ||
+		errors.Is(err, syscall.EACCES) || errors.Is(err, syscall.EPERM) {
+		return ReachFiltered
+	}
+	return ReachUnknown
+}
 
-	// Public DNS (1.1.1.1)
-	// We can't easily force a specific DNS server with pure Go net.Resolver without custom Dial
-	// So we will simulate it by creating a custom resolver
+// reachRank orders verdicts by how much they tell about the host
+func reachRank(r Reachability) int {
+	switch r {
+	case ReachOpen:
+		return 3
+	case ReachClosed:
+		return 2
+	case ReachFiltered:
+		return 1
+	}
+	return 0
+}
 
-	r := &net.Resolver{
-		PreferGo: true,
-		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
-			d := net.Dialer{
-				Timeout: 2 * time.Second,
-			}
-			return d.DialContext(ctx, "udp", "1.1.1.1:53")
-		},
+// checkDNS times a lookup through the local and the public resolver
+func (c *ConnectivityCollector) checkDNS() DNSResult {
+	publicDNS, auto := c.pickPublicDNS()
+	res := DNSResult{
+		LocalResolver:      describeDNSServer(c.LocalDNS),
+		PublicResolver:     describeDNSServer(publicDNS),
+		PublicAutoSelected: auto,
 	}
 
-	start = time.Now()
-	_, err = r.LookupHost(context.Background(), "google.com")
-	res.PublicResolverTime = time.Since(start)
+	// All resolvers at once, so the check takes as long as the slowest
+	nameservers := c.nameservers()
+	res.Nameservers = make([]DNSResolverTime, len(nameservers))
+	var local, public DNSResolverTime
+	var wg sync.WaitGroup
+	wg.Add(2 + len(nameservers))
+	go func() { defer wg.Done(); local = c.timeResolver(c.LocalDNS) }()
+	go func() {
+		defer wg.Done()
+		if c.PublicDNSErr != nil {
+			public = DNSResolverTime{Server: res.PublicResolver, Error: c.PublicDNSErr}
+			return
+		}
+		public = c.timeResolver(publicDNS)
+	}()
+	for i, server := range nameservers {
+		go func() { defer wg.Done(); res.Nameservers[i] = c.timeResolver(server) }()
+	}
+	wg.Wait()
 
+	// A probe domain that does not resolve still times the resolver, the
+	// response code is kept apart from transport errors
+	res.LocalResolverTime, res.Error, res.LocalRcode = local.Latency, local.Error, local.Rcode
+	res.PublicResolverTime, res.PublicError, res.PublicRcode = public.Latency, public.Error, public.Rcode
 	return res
 }
+
+// resolvConfNameservers lists the nameservers of a resolv.conf, nil if it
+// cannot be read
+func resolvConfNameservers(path string) []DNSServer {
+	config, err := dns.ClientConfigFromFile(path)
+	if err != nil {
+		return nil
+	}
+	var servers []DNSServer
+	for _, s := range config.Servers {
+		servers = append(servers, DNSServer{Name: s, Address: net.JoinHostPort(s, config.Port), Proto: ProtoUDP})
+	}
+	return servers
+}
+
+// timeResolver looks the probe domain up through server
+func (c *ConnectivityCollector) timeResolver(server DNSServer) DNSResolverTime {
+	res := c.timeDNS(server)
+	t := DNSResolverTime{Server: describeDNSServer(server), Latency: res.Latency, Error: res.Error}
+	if res.Error == nil && res.ResponseCode != dns.RcodeToString[dns.RcodeSuccess] {
+		t.Rcode = res.ResponseCode
+	}
+	return t
+}
+
+// pickPublicDNS returns the public resolver of this check, racing the
+// candidates while no race has produced a winner. The race queries are
+// charged to the budget; a race over it keeps PublicDNS for this check.
+func (c *ConnectivityCollector) pickPublicDNS() (DNSServer, bool) {
+	c.publicMu.Lock()
+	defer c.publicMu.Unlock()
+	n := len(c.PublicCandidates)
+	if c.publicPicked || n == 0 {
+		return c.PublicDNS, c.publicAuto
+	}
+	if !c.Budget.Allow(n, int64(n)*dnsProbeBytes) {
+		return c.PublicDNS, c.publicAuto
+	}
+	if fastest, ok := c.fastestPublicDNS(); ok {
+		c.PublicDNS, c.publicAuto, c.publicPicked = fastest, true, true
+	}
+	return c.PublicDNS, c.publicAuto
+}
+
+// fastestPublicDNS looks the check domain up through every candidate at
+// once and returns the first one to answer without error
+func (c *ConnectivityCollector) fastestPublicDNS() (DNSServer, bool) {
+	type answer struct {
+		server DNSServer
+		ok     bool
+	}
+	answers := make(chan answer, len(c.PublicCandidates)) // Buffered, losers finish on their own
+	for _, server := range c.PublicCandidates {
+		go func() {
+			res := c.timeDNS(server)
+			answers <- answer{server, res.Error == nil && res.ResponseCode == dns.RcodeToString[dns.RcodeSuccess]}
+		}()
+	}
+	for range c.PublicCandidates {
+		if a := <-answers; a.ok {
+			return a.server, true
+		}
+	}
+	return DNSServer{}, false
+}
+
+func (c *ConnectivityCollector) timeDNS(server DNSServer) DNSLookupResult {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return c.dns.Lookup(ctx, c.DNSDomain, RecordA, server)
+}
+
+// describeDNSServer names a server for display, e.g. "1.1.1.1:53 (UDP)"
+func describeDNSServer(s DNSServer) string {
+	if s.Address == "" {
+		return s.Name
+	}
+	proto := s.Proto
+	if proto == "" {
+		proto = ProtoUDP
+	}
+	return fmt.Sprintf("%s (%s)", s.Address, proto)
+}
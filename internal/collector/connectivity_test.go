@@ -1,7 +1,22 @@
 package collector
 
 import (
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/vishvananda/netlink"
 )
 
 func TestConnectivityCollector_Collect(t *testing.T) {
@@ -35,3 +50,621 @@ func TestConnectivityCollector_Collect(t *testing.T) {
 		t.Logf("DNS check failed: %v", stats.DNS.Error)
 	}
 }
+
+func TestTCPProbe_Reachability(t *testing.T) {
+	dialer := &markedDialer{Timeout: 500 * time.Millisecond}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	res := tcpProbe(dialer, "127.0.0.1", port)
+	if res.Reachability != ReachOpen || res.Error != nil {
+		t.Errorf("listening port: got %q (%v), want open", res.Reachability, res.Error)
+	}
+
+	// Nothing listening anymore: the kernel answers with RST
+	l.Close()
+	res = tcpProbe(dialer, "127.0.0.1", port)
+	if res.Reachability != ReachClosed {
+		t.Errorf("refused port: got %q (%v), want closed", res.Reachability, res.Error)
+	}
+	if res.PacketLoss != 0 || res.Error != nil {
+		t.Errorf("a closed port proves the host is up, got loss %.0f%% error %v", res.PacketLoss, res.Error)
+	}
+
+	// A listener with a full accept queue silently drops further SYNs, like a firewall
+	res = tcpProbe(dialer, "127.0.0.1", blackholePort(t))
+	if res.Reachability != ReachFiltered {
+		t.Errorf("black-holed address: got %q (%v), want filtered", res.Reachability, res.Error)
+	}
+	if res.PacketLoss != 100 || res.Error == nil {
+		t.Errorf("filtered probe should count as lost, got loss %.0f%% error %v", res.PacketLoss, res.Error)
+	}
+}
+
+// blackholePort returns a local port whose SYNs are dropped: a socket listening
+// with a zero backlog whose queue is already filled by unaccepted connections
+func blackholePort(t *testing.T) int {
+	t.Helper()
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		t.Skipf("socket: %v", err)
+	}
+	t.Cleanup(func() { syscall.Close(fd) })
+	if err := syscall.Bind(fd, &syscall.SockaddrInet4{Addr: [4]byte{127, 0, 0, 1}}); err != nil {
+		t.Skipf("bind: %v", err)
+	}
+	if err := syscall.Listen(fd, 0); err != nil {
+		t.Skipf("listen: %v", err)
+	}
+	sa, err := syscall.Getsockname(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := sa.(*syscall.SockaddrInet4).Port
+	address := net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+
+	// Fill the queue until a connect attempt goes unanswered
+	for i := 0; i < 8; i++ {
+		conn, err := net.DialTimeout("tcp", address, 200*time.Millisecond)
+		if err != nil {
+			return port
+		}
+		t.Cleanup(func() { conn.Close() })
+	}
+	t.Skip("could not saturate the accept queue")
+	return 0
+}
+
+func TestClassifyDialError(t *testing.T) {
+	_, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(freeTCPPort(t))))
+	if got := classifyDialError(err); got != ReachClosed {
+		t.Errorf("refused: got %q (%v)", got, err)
+	}
+	if got := classifyDialError(nil); got != ReachOpen {
+		t.Errorf("nil error: got %q", got)
+	}
+	_, err = net.Dial("tcp", "no-such-host.invalid:80")
+	if got := classifyDialError(err); got != ReachUnknown {
+		t.Errorf("resolution failure: got %q (%v), want unknown", got, err)
+	}
+}
+
+func TestRTTJitter(t *testing.T) {
+	ms := time.Millisecond
+	rtts := []time.Duration{10 * ms, 20 * ms, 10 * ms, 30 * ms}
+	if got := rttJitter(rtts); got != 40*ms/3 {
+		t.Errorf("rttJitter() = %s, want 13.333ms", got)
+	}
+	if got := rttStdDev(rtts); got.Round(ms) != 8*ms {
+		t.Errorf("rttStdDev() = %s, want about 8.3ms", got)
+	}
+	if rttJitter(rtts[:1]) != 0 || rttStdDev(nil) != 0 {
+		t.Error("one sample has no spread")
+	}
+}
+
+func TestCheckDNS_ConfiguredResolvers(t *testing.T) {
+	var localQueries, publicQueries atomic.Int32
+	local := startMockDNS(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		localQueries.Add(1)
+		answerA("192.0.2.10")(w, r)
+	})
+	doh := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		publicQueries.Add(1)
+		dohHandler("192.0.2.20").ServeHTTP(w, r)
+	}))
+	defer doh.Close()
+
+	c := NewConnectivityCollector()
+	c.DNSDomain = "example.com"
+	c.LocalDNS = DNSServer{Name: "Router", Address: local, Proto: ProtoTCP}
+	c.PublicDNS = DNSServer{Name: "Mock DoH", Address: doh.URL + "/dns-query", Proto: ProtoDoH}
+	c.nameservers = func() []DNSServer { return nil }
+	c.dns.rootCAs = x509.NewCertPool()
+	c.dns.rootCAs.AddCert(doh.Certificate())
+
+	res := c.checkDNS()
+	if res.Error != nil || res.PublicError != nil {
+		t.Fatalf("checkDNS() errors: local %v, public %v", res.Error, res.PublicError)
+	}
+	if localQueries.Load() != 1 || publicQueries.Load() != 1 {
+		t.Errorf("queries: local %d, public %d, want 1 each", localQueries.Load(), publicQueries.Load())
+	}
+	if res.PublicResolverTime <= 0 || res.LocalResolverTime <= 0 {
+		t.Errorf("timings not recorded: local %s, public %s", res.LocalResolverTime, res.PublicResolverTime)
+	}
+	if want := doh.URL + "/dns-query (DoH)"; res.PublicResolver != want {
+		t.Errorf("PublicResolver = %q, want %q", res.PublicResolver, want)
+	}
+	if want := local + " (TCP)"; res.LocalResolver != want {
+		t.Errorf("LocalResolver = %q, want %q", res.LocalResolver, want)
+	}
+}
+
+func TestCheckDNS_PublicResolverSpec(t *testing.T) {
+	var mu sync.Mutex
+	var asked []string
+	public := startMockDNS(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		mu.Lock()
+		asked = append(asked, r.Question[0].Name)
+		mu.Unlock()
+		answerA("192.0.2.20")(w, r)
+	})
+	local := startMockDNS(t, answerA("192.0.2.10"))
+
+	server, err := ParseResolver(public)
+	if err != nil {
+		t.Fatalf("ParseResolver(%q): %v", public, err)
+	}
+	c := NewConnectivityCollector()
+	c.DNSDomain = "probe.example.org"
+	c.LocalDNS = DNSServer{Name: "Router", Address: local, Proto: ProtoUDP}
+	c.PublicDNS = server
+	c.nameservers = func() []DNSServer { return nil }
+
+	res := c.checkDNS()
+	if res.PublicError != nil {
+		t.Fatalf("checkDNS() public error: %v", res.PublicError)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(asked) != 1 || asked[0] != "probe.example.org." {
+		t.Errorf("public resolver was asked %q, want [probe.example.org.]", asked)
+	}
+}
+
+func TestParseResolver(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    DNSServer
+		wantErr bool
+	}{
+		{in: "9.9.9.9", want: DNSServer{Name: "9.9.9.9", Address: "9.9.9.9:53", Proto: ProtoUDP}},
+		{in: "[2620:fe::fe]:5353", want: DNSServer{Name: "[2620:fe::fe]:5353", Address: "[2620:fe::fe]:5353", Proto: ProtoUDP}},
+		{in: "https://dns.quad9.net", want: DNSServer{Name: "https://dns.quad9.net", Address: "https://dns.quad9.net/dns-query", Proto: ProtoDoH}},
+		{in: "", wantErr: true},
+		{in: "9.9.9.9:99999", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseResolver(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseResolver(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("ParseResolver(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeTCPTarget(t *testing.T) {
+	tests := []struct {
+		in      string
+		host    string
+		port    int
+		wantErr bool
+	}{
+		{in: "8.8.8.8", host: "8.8.8.8"},
+		{in: "example.com", host: "example.com"},
+		{in: "example.com:8443", host: "example.com", port: 8443},
+		{in: "10.0.0.1:22", host: "10.0.0.1", port: 22},
+		{in: "2001:db8::1", host: "2001:db8::1"},
+		{in: "[2001:db8::1]", host: "2001:db8::1"},
+		{in: "[2001:db8::1]:443", host: "2001:db8::1", port: 443},
+		{in: "fe80::1%eth0", host: "fe80::1%eth0"},
+		{in: "[fe80::1%eth0]:8080", host: "fe80::1%eth0", port: 8080},
+		{in: "", wantErr: true},
+		{in: "example.com:http", wantErr: true},
+		{in: "example.com:70000", wantErr: true},
+		{in: "1.2.3.4:80:90", wantErr: true},
+		{in: "[2001:db8::1", wantErr: true},
+		{in: "exa mple.com", wantErr: true},
+		{in: "https://example.com/", wantErr: true},
+	}
+	for _, tt := range tests {
+		host, port, err := normalizeTCPTarget(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("normalizeTCPTarget(%q) = %q, %d; want an error", tt.in, host, port)
+			}
+			continue
+		}
+		if err != nil || host != tt.host || port != tt.port {
+			t.Errorf("normalizeTCPTarget(%q) = %q, %d, %v; want %q, %d", tt.in, host, port, err, tt.host, tt.port)
+		}
+	}
+}
+
+func TestTCPPing_TargetForms(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	// host:port probes only the given port
+	target := ln.Addr().String()
+	res := tcpPing(target, 0, nil)
+	port := ln.Addr().(*net.TCPAddr).Port
+	if res.Reachability != ReachOpen || res.Port != port {
+		t.Errorf("tcpPing(%q) = %s on port %d, want open on %d (err %v)", target, res.Reachability, res.Port, port, res.Error)
+	}
+	if res.Target != target {
+		t.Errorf("Target = %q, want the input %q", res.Target, target)
+	}
+
+	// Zone-scoped IPv6 with a port, the zone must survive into the dial address
+	if lo := loopbackName(t); lo != "" {
+		if ln6, err := net.Listen("tcp", "[::1]:0"); err == nil {
+			defer ln6.Close()
+			go func() {
+				if conn, err := ln6.Accept(); err == nil {
+					conn.Close()
+				}
+			}()
+			port6 := ln6.Addr().(*net.TCPAddr).Port
+			target := fmt.Sprintf("[::1%%%s]:%d", lo, port6)
+			if res := tcpPing(target, 0, nil); res.Reachability != ReachOpen || res.Port != port6 {
+				t.Errorf("tcpPing(%q) = %s on port %d (err %v), want open", target, res.Reachability, res.Port, res.Error)
+			}
+		}
+	}
+
+	res = tcpPing("example.com:notaport", 0, nil)
+	if res.Error == nil || res.PacketLoss != 100 {
+		t.Errorf("malformed target should fail clearly, got %+v", res)
+	}
+}
+
+func loopbackName(t *testing.T) string {
+	t.Helper()
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return ""
+	}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 {
+			return iface.Name
+		}
+	}
+	return ""
+}
+
+func TestCheckDNS_Nameservers(t *testing.T) {
+	ok := startMockDNS(t, answerA("192.0.2.1"))
+	nxdomain := startMockDNS(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		resp := new(dns.Msg)
+		resp.SetRcode(r, dns.RcodeNameError)
+		w.WriteMsg(resp)
+	})
+
+	c := NewConnectivityCollector()
+	c.DNSDomain = "does-not-exist.example"
+	c.LocalDNS = DNSServer{Name: "Local", Address: nxdomain, Proto: ProtoUDP}
+	c.PublicDNS = DNSServer{Name: "Public", Address: ok, Proto: ProtoUDP}
+	c.nameservers = func() []DNSServer {
+		return []DNSServer{{Name: "ns1", Address: ok, Proto: ProtoUDP}, {Name: "ns2", Address: nxdomain, Proto: ProtoUDP}}
+	}
+
+	res := c.checkDNS()
+	// The resolver answered, only the probe domain is missing
+	if res.Error != nil || res.LocalRcode != "NXDOMAIN" || res.LocalResolverTime <= 0 {
+		t.Errorf("local: err %v, rcode %q, time %s", res.Error, res.LocalRcode, res.LocalResolverTime)
+	}
+	if len(res.Nameservers) != 2 {
+		t.Fatalf("Nameservers = %+v, want 2", res.Nameservers)
+	}
+	if ns := res.Nameservers[0]; ns.Server != ok+" (UDP)" || ns.Error != nil || ns.Rcode != "" || ns.Latency <= 0 {
+		t.Errorf("first nameserver = %+v", ns)
+	}
+	if ns := res.Nameservers[1]; ns.Rcode != "NXDOMAIN" {
+		t.Errorf("second nameserver = %+v, want NXDOMAIN", ns)
+	}
+}
+
+func TestResolvConfNameservers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "resolv.conf")
+	if err := os.WriteFile(path, []byte("nameserver 192.0.2.53\nnameserver 2001:db8::53\nsearch lan\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	servers := resolvConfNameservers(path)
+	if len(servers) != 2 || servers[0].Address != "192.0.2.53:53" || servers[1].Address != "[2001:db8::53]:53" {
+		t.Errorf("servers = %+v", servers)
+	}
+	if resolvConfNameservers(filepath.Join(t.TempDir(), "missing")) != nil {
+		t.Error("a missing file lists no nameservers")
+	}
+}
+
+func TestCheckDNS_FastestPublicResolver(t *testing.T) {
+	delayed := func(d time.Duration) string {
+		return startMockDNS(t, func(w dns.ResponseWriter, r *dns.Msg) {
+			time.Sleep(d)
+			answerA("192.0.2.1")(w, r)
+		})
+	}
+	refused := startMockDNS(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		resp := new(dns.Msg)
+		resp.SetRcode(r, dns.RcodeRefused)
+		w.WriteMsg(resp)
+	})
+	slow, fast := delayed(300*time.Millisecond), delayed(20*time.Millisecond)
+
+	c := NewConnectivityCollector()
+	c.DNSDomain = "example.com"
+	c.LocalDNS = DNSServer{Name: "Local", Address: fast, Proto: ProtoUDP}
+	c.nameservers = func() []DNSServer { return nil }
+	c.PublicCandidates = []DNSServer{
+		{Name: "Slow", Address: slow, Proto: ProtoUDP},
+		{Name: "Refused", Address: refused, Proto: ProtoUDP}, // Answers first, but with an error
+		{Name: "Fast", Address: fast, Proto: ProtoUDP},
+	}
+
+	res := c.checkDNS()
+	if c.PublicDNS.Name != "Fast" || !res.PublicAutoSelected {
+		t.Fatalf("selected %q (auto %v), want Fast", c.PublicDNS.Name, res.PublicAutoSelected)
+	}
+	if want := fast + " (UDP)"; res.PublicResolver != want {
+		t.Errorf("PublicResolver = %q, want %q", res.PublicResolver, want)
+	}
+
+	// The selection is cached for the session
+	c.PublicCandidates[2].Address = slow
+	c.checkDNS()
+	if c.PublicDNS.Address != fast {
+		t.Errorf("the race should run once, PublicDNS moved to %s", c.PublicDNS.Address)
+	}
+}
+
+func TestCheckDNS_PublicRaceRetried(t *testing.T) {
+	var answering atomic.Bool
+	flaky := startMockDNS(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		if !answering.Load() {
+			resp := new(dns.Msg)
+			resp.SetRcode(r, dns.RcodeServerFailure)
+			w.WriteMsg(resp)
+			return
+		}
+		answerA("192.0.2.1")(w, r)
+	})
+
+	c := NewConnectivityCollector()
+	c.DNSDomain = "example.com"
+	c.LocalDNS = DNSServer{Name: "Local", Address: flaky, Proto: ProtoUDP}
+	c.nameservers = func() []DNSServer { return nil }
+	c.PublicCandidates = []DNSServer{{Name: "Flaky", Address: flaky, Proto: ProtoUDP}}
+	c.Budget, _ = fixedBudget(10, 0)
+
+	if res := c.checkDNS(); res.PublicAutoSelected {
+		t.Fatal("a race nobody answered should not select a resolver")
+	}
+	if probes, _ := c.Budget.Remaining(); probes != 9 {
+		t.Errorf("the race should be charged to the budget, %d probes left, want 9", probes)
+	}
+
+	answering.Store(true)
+	if res := c.checkDNS(); c.PublicDNS.Name != "Flaky" || !res.PublicAutoSelected {
+		t.Errorf("the race should run again after a failure, selected %q (auto %v)", c.PublicDNS.Name, res.PublicAutoSelected)
+	}
+	c.checkDNS()
+	if probes, _ := c.Budget.Remaining(); probes != 8 {
+		t.Errorf("a settled race should not run again, %d probes left, want 8", probes)
+	}
+}
+
+func TestConnectivityCollector_PartialOnPanic(t *testing.T) {
+	local := startMockDNS(t, answerA("192.0.2.10"))
+	c := NewConnectivityCollector()
+	c.Targets = []string{"192.0.2.1", "192.0.2.2"}
+	c.DNSDomain = "example.com"
+	c.LocalDNS = DNSServer{Name: "Local", Address: local, Proto: ProtoUDP}
+	c.PublicDNS = c.LocalDNS
+	c.ping = func(target string) PingResult {
+		if target == "192.0.2.2" {
+			panic("simulated probe failure")
+		}
+		return PingResult{Target: target, AvgRtt: time.Millisecond}
+	}
+
+	stats, err := c.Collect()
+	if err == nil || !stats.Partial {
+		t.Fatalf("Collect() = partial %v, error %v; want partial results with an error", stats.Partial, err)
+	}
+	if res := stats.Targets["192.0.2.1"]; res.Error != nil || res.AvgRtt != time.Millisecond {
+		t.Errorf("the healthy target should keep its result, got %+v", res)
+	}
+	if res := stats.Targets["192.0.2.2"]; res.Error == nil {
+		t.Error("the panicking target should carry the panic as its error")
+	}
+	if stats.DNS.Error != nil || stats.DNS.LocalResolverTime <= 0 {
+		t.Errorf("the DNS check should still complete, got %+v", stats.DNS)
+	}
+}
+
+func TestConnectivityCollector_IPv6(t *testing.T) {
+	local := startMockDNS(t, answerA("192.0.2.10"))
+	c := NewConnectivityCollector()
+	c.Targets = []string{"192.0.2.1"}
+	c.IPv6Targets = []string{"2001:db8::1"}
+	c.LocalDNS = DNSServer{Name: "Local", Address: local, Proto: ProtoUDP}
+	c.PublicDNS = c.LocalDNS
+	c.ping = func(target string) PingResult { return PingResult{Target: target, AvgRtt: time.Millisecond} }
+	c.gateway = func(family int) (string, error) {
+		if family == netlink.FAMILY_V6 {
+			return "fe80::1%eth0", nil
+		}
+		return "192.0.2.254", nil
+	}
+
+	stats, err := c.Collect()
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	if len(stats.Targets) != 2 || stats.Targets["192.0.2.254"].Target == "" {
+		t.Errorf("IPv4 results = %v, want the targets and the v4 gateway", stats.Targets)
+	}
+	if stats.IPv6Gateway != "fe80::1%eth0" || len(stats.IPv6Targets) != 2 || stats.IPv6Targets["2001:db8::1"].Target == "" {
+		t.Errorf("IPv6 gateway %q, results %v", stats.IPv6Gateway, stats.IPv6Targets)
+	}
+
+	// Without an IPv6 default route nothing is pinged over IPv6
+	c.gateway = func(family int) (string, error) {
+		if family == netlink.FAMILY_V6 {
+			return "", fmt.Errorf("no default gateway found")
+		}
+		return "192.0.2.254", nil
+	}
+	if stats, _ = c.Collect(); stats.IPv6Targets != nil {
+		t.Errorf("IPv6 results without a v6 route: %v", stats.IPv6Targets)
+	}
+}
+
+func TestIsDefaultRoute(t *testing.T) {
+	_, all, _ := net.ParseCIDR("::/0")
+	_, lan, _ := net.ParseCIDR("192.0.2.0/24")
+	if !isDefaultRoute(netlink.Route{}) || !isDefaultRoute(netlink.Route{Dst: all}) || isDefaultRoute(netlink.Route{Dst: lan}) {
+		t.Error("only nil and zero-length destinations are default routes")
+	}
+}
+
+func TestConnectivityCollector_ReverseDNS(t *testing.T) {
+	var ptrQueries atomic.Int32
+	local := startMockDNS(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		resp := new(dns.Msg)
+		resp.SetReply(r)
+		q := r.Question[0]
+		switch {
+		case q.Qtype != dns.TypePTR:
+			rr, _ := dns.NewRR(q.Name + " 60 IN A 192.0.2.10")
+			resp.Answer = append(resp.Answer, rr)
+		case q.Name == "254.2.0.192.in-addr.arpa.":
+			ptrQueries.Add(1)
+			rr, _ := dns.NewRR(q.Name + " 60 IN PTR gw.lan.")
+			resp.Answer = append(resp.Answer, rr)
+		default:
+			ptrQueries.Add(1)
+			resp.Rcode = dns.RcodeNameError
+		}
+		w.WriteMsg(resp)
+	})
+	c := NewConnectivityCollector()
+	c.Targets = []string{"192.0.2.1", "example.com"}
+	c.LocalDNS = DNSServer{Name: "Local", Address: local, Proto: ProtoUDP}
+	c.PublicDNS = c.LocalDNS
+	c.nameservers = func() []DNSServer { return nil }
+	c.ping = func(target string) PingResult { return PingResult{Target: target, AvgRtt: time.Millisecond} }
+	c.gateway = func(family int) (string, error) {
+		if family == netlink.FAMILY_V6 {
+			return "", fmt.Errorf("no default gateway found")
+		}
+		return "192.0.2.254", nil
+	}
+
+	if _, err := c.Collect(); err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	// The names are looked up in the background and show on a later refresh
+	for deadline := time.Now().Add(2 * time.Second); len(c.unnamed([]string{"192.0.2.1", "192.0.2.254"})) > 0; {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d PTR lookups finished, want 2", ptrQueries.Load())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	for range 2 {
+		stats, err := c.Collect()
+		if err != nil {
+			t.Fatalf("Collect() error = %v", err)
+		}
+		if got := stats.Targets["192.0.2.254"].Hostname; got != "gw.lan" {
+			t.Errorf("gateway hostname = %q, want gw.lan", got)
+		}
+		if got := stats.Targets["192.0.2.1"].Hostname; got != "" {
+			t.Errorf("NXDOMAIN target hostname = %q, want none", got)
+		}
+		if got := stats.Targets["example.com"].Hostname; got != "" {
+			t.Errorf("name target hostname = %q, want none", got)
+		}
+	}
+	// Later refreshes answer from the cache
+	if n := ptrQueries.Load(); n != 2 {
+		t.Errorf("%d PTR queries, want one per IP target", n)
+	}
+}
+
+func TestConnectivityCollector_SlowPTRDoesNotDelay(t *testing.T) {
+	local := startMockDNS(t, answerA("192.0.2.10"))
+	release := make(chan struct{})
+	defer close(release)
+	var lookups atomic.Int32
+	c := NewConnectivityCollector()
+	c.Targets = []string{"192.0.2.1"}
+	c.DNSDomain = "example.com"
+	c.LocalDNS = DNSServer{Name: "Local", Address: local, Proto: ProtoUDP}
+	c.PublicDNS = c.LocalDNS
+	c.nameservers = func() []DNSServer { return nil }
+	c.gateway = func(int) (string, error) { return "", fmt.Errorf("no default gateway found") }
+	c.ping = func(target string) PingResult { return PingResult{Target: target, AvgRtt: time.Millisecond} }
+	c.ptr = func(string) (string, error) {
+		lookups.Add(1)
+		<-release
+		return "slow.example", nil
+	}
+
+	for range 2 {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			c.Collect()
+		}()
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+			t.Fatal("Collect waited for a PTR lookup")
+		}
+	}
+	if n := lookups.Load(); n != 1 {
+		t.Errorf("%d lookups, a running one should not be started again", n)
+	}
+}
+
+func TestConnectivityCollector_PendingPTRNotCharged(t *testing.T) {
+	local := startMockDNS(t, answerA("192.0.2.10"))
+	release := make(chan struct{})
+	defer close(release)
+	c := NewConnectivityCollector()
+	c.Targets = []string{"192.0.2.1"}
+	c.DNSDomain = "example.com"
+	c.LocalDNS = DNSServer{Name: "Local", Address: local, Proto: ProtoUDP}
+	c.PublicDNS = c.LocalDNS
+	c.nameservers = func() []DNSServer { return nil }
+	c.gateway = func(int) (string, error) { return "", fmt.Errorf("no default gateway found") }
+	c.ping = func(target string) PingResult { return PingResult{Target: target, AvgRtt: time.Millisecond} }
+	c.ptr = func(string) (string, error) {
+		<-release
+		return "slow.example", nil
+	}
+	c.Budget, _ = fixedBudget(1000, 0)
+
+	spent := func() int {
+		before, _ := c.Budget.Remaining()
+		c.Collect()
+		after, _ := c.Budget.Remaining()
+		return before - after
+	}
+	first, second := spent(), spent()
+	if first-second != 1 {
+		t.Errorf("refreshes charged %d then %d probes, the running PTR lookup should not be paid for again", first, second)
+	}
+}
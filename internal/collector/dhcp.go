@@ -0,0 +1,211 @@
+package collector
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DefaultDHCPLeaseGlobs are the lease locations of dhclient, NetworkManager's
+// internal client and systemd-networkd
+var DefaultDHCPLeaseGlobs = []string{
+	"/var/lib/dhcp/*.leases",
+	"/var/lib/dhclient/*.leases",
+	"/var/lib/NetworkManager/*.lease",
+	"/run/systemd/netif/leases/*",
+}
+
+// DHCPLease is the DNS and routing information handed out by a DHCP server
+type DHCPLease struct {
+	Source     string // Lease file
+	Interface  string
+	DNSServers []string // IP addresses, in offered order
+	Gateway    string
+	Domain     string
+}
+
+// DHCPInfo cross-checks DHCP offered resolvers against the active configuration
+type DHCPInfo struct {
+	Leases    []DHCPLease `report:"detail"`
+	Resolvers []string    // Resolvers in use (resolv.conf, or the stub's upstreams)
+	Unused    []string    // DHCP offered DNS servers that are not in use
+}
+
+type DHCPCollector struct {
+	Globs        []string
+	ResolvConf   string
+	UpstreamConf string // systemd-resolved upstreams, used when resolv.conf points at a stub
+}
+
+func NewDHCPCollector() *DHCPCollector {
+	return &DHCPCollector{
+		Globs:        DefaultDHCPLeaseGlobs,
+		ResolvConf:   resolvConfPath,
+		UpstreamConf: resolvedUpstreamPath,
+	}
+}
+
+// Collect reads every lease file it can find. Unreadable files are skipped,
+// hosts without DHCP simply get no leases.
+func (c *DHCPCollector) Collect() DHCPInfo {
+	var info DHCPInfo
+	for _, pattern := range c.Globs {
+		paths, _ := filepath.Glob(pattern)
+		for _, path := range paths {
+			lease, ok := readDHCPLease(path)
+			if ok {
+				info.Leases = append(info.Leases, lease)
+			}
+		}
+	}
+
+	stub, upstreams := discoverResolvers(c.ResolvConf, c.UpstreamConf)
+	inUse := make(map[string]bool)
+	for _, r := range upstreams {
+		host, _, err := net.SplitHostPort(r)
+		if err != nil {
+			host = r
+		}
+		info.Resolvers = append(info.Resolvers, host)
+		inUse[host] = true
+	}
+	// Without known upstreams behind a stub, nothing can be called unused
+	if stub != "" && len(upstreams) == 0 {
+		return info
+	}
+
+	seen := make(map[string]bool)
+	for _, lease := range info.Leases {
+		for _, s := range lease.DNSServers {
+			if !inUse[s] && !seen[s] {
+				seen[s] = true
+				info.Unused = append(info.Unused, s)
+			}
+		}
+	}
+	return info
+}
+
+func readDHCPLease(path string) (DHCPLease, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return DHCPLease{}, false
+	}
+	defer f.Close()
+
+	var lease DHCPLease
+	if strings.HasSuffix(path, ".leases") {
+		lease = parseDhclientLeases(f)
+	} else {
+		lease = parseKeyValueLease(f)
+	}
+	lease.Source = path
+	if lease.Interface == "" {
+		lease.Interface = leaseInterfaceFromName(filepath.Base(path))
+	}
+	return lease, len(lease.DNSServers) > 0 || lease.Gateway != ""
+}
+
+// parseDhclientLeases parses ISC dhclient lease blocks. The file is
+// appended to on every renewal, so the last lease wins.
+func parseDhclientLeases(r io.Reader) DHCPLease {
+	var last, cur DHCPLease
+	inLease := false
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "lease"):
+			inLease = true
+			cur = DHCPLease{}
+			continue
+		case line == "}":
+			if inLease {
+				last = cur
+			}
+			inLease = false
+			continue
+		case !inLease:
+			continue
+		}
+
+		line = strings.TrimSuffix(line, ";")
+		key, value, _ := strings.Cut(line, " ")
+		value = strings.Trim(strings.TrimSpace(value), `"`)
+		if key == "interface" {
+			cur.Interface = value
+			continue
+		}
+		if key != "option" {
+			continue
+		}
+		name, value, _ := strings.Cut(value, " ")
+		value = strings.Trim(strings.TrimSpace(value), `"`)
+		switch name {
+		case "domain-name-servers":
+			cur.DNSServers = splitAddrs(value, ",")
+		case "routers":
+			if routers := splitAddrs(value, ","); len(routers) > 0 {
+				cur.Gateway = routers[0]
+			}
+		case "domain-name":
+			cur.Domain = value
+		}
+	}
+	return last
+}
+
+// parseKeyValueLease parses NetworkManager internal and systemd-networkd
+// lease files (KEY=value lines)
+func parseKeyValueLease(r io.Reader) DHCPLease {
+	var lease DHCPLease
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "DNS":
+			lease.DNSServers = splitAddrs(value, " ")
+		case "ROUTER":
+			if routers := splitAddrs(value, " "); len(routers) > 0 {
+				lease.Gateway = routers[0]
+			}
+		case "DOMAINNAME":
+			lease.Domain = value
+		}
+	}
+	return lease
+}
+
+// splitAddrs splits a list of IP addresses, dropping anything else
+func splitAddrs(s, sep string) []string {
+	var addrs []string
+	for _, part := range strings.Split(s, sep) {
+		if ip := net.ParseIP(strings.TrimSpace(part)); ip != nil {
+			addrs = append(addrs, ip.String())
+		}
+	}
+	return addrs
+}
+
+// leaseInterfaceFromName guesses the interface from names like
+// dhclient-eth0.leases, internal-<uuid>-wlan0.lease or a networkd ifindex
+func leaseInterfaceFromName(name string) string {
+	if index, err := strconv.Atoi(name); err == nil {
+		if iface, err := net.InterfaceByIndex(index); err == nil {
+			return iface.Name
+		}
+		return ""
+	}
+	name = strings.TrimSuffix(strings.TrimSuffix(name, ".leases"), ".lease")
+	if i := strings.LastIndexAny(name, "-."); i >= 0 && i < len(name)-1 {
+		return name[i+1:]
+	}
+	return ""
+}
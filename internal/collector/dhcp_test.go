@@ -0,0 +1,90 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const sampleDhclientLeases = `default-duid "\000\001\000\001";
+lease {
+  interface "eth0";
+  fixed-address 192.168.1.50;
+  option subnet-mask 255.255.255.0;
+  option routers 192.168.1.254;
+  option domain-name-servers 192.168.1.254;
+  renew 1 2026/10/12 08:00:00;
+}
+lease {
+  interface "eth0";
+  fixed-address 192.168.1.50;
+  option subnet-mask 255.255.255.0;
+  option routers 192.168.1.1;
+  option dhcp-lease-time 86400;
+  option domain-name-servers 192.168.1.1,9.9.9.9;
+  option domain-name "home.lan";
+  renew 4 2026/10/15 08:00:00;
+  expire 5 2026/10/16 08:00:00;
+}
+`
+
+func TestParseDhclientLeases(t *testing.T) {
+	lease := parseDhclientLeases(strings.NewReader(sampleDhclientLeases))
+
+	// The most recent lease block wins
+	if want := []string{"192.168.1.1", "9.9.9.9"}; !reflect.DeepEqual(lease.DNSServers, want) {
+		t.Errorf("DNSServers = %v, want %v", lease.DNSServers, want)
+	}
+	if lease.Gateway != "192.168.1.1" {
+		t.Errorf("Gateway = %q", lease.Gateway)
+	}
+	if lease.Interface != "eth0" || lease.Domain != "home.lan" {
+		t.Errorf("Interface/Domain = %q/%q", lease.Interface, lease.Domain)
+	}
+}
+
+func TestParseKeyValueLease(t *testing.T) {
+	content := "# This is private data. Do not parse.\nADDRESS=10.0.0.20\nROUTER=10.0.0.1\nDNS=10.0.0.1 1.1.1.1\nDOMAINNAME=corp.example\n"
+	lease := parseKeyValueLease(strings.NewReader(content))
+	if want := []string{"10.0.0.1", "1.1.1.1"}; !reflect.DeepEqual(lease.DNSServers, want) {
+		t.Errorf("DNSServers = %v, want %v", lease.DNSServers, want)
+	}
+	if lease.Gateway != "10.0.0.1" || lease.Domain != "corp.example" {
+		t.Errorf("lease = %+v", lease)
+	}
+}
+
+func TestDHCPCollector_CrossCheck(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "dhclient-eth0.leases"), []byte(sampleDhclientLeases), 0o644)
+	resolvConf := filepath.Join(dir, "resolv.conf")
+	os.WriteFile(resolvConf, []byte("nameserver 192.168.1.1\n"), 0o644)
+
+	c := &DHCPCollector{Globs: []string{filepath.Join(dir, "*.leases")}, ResolvConf: resolvConf}
+	info := c.Collect()
+
+	if len(info.Leases) != 1 || info.Leases[0].Interface != "eth0" {
+		t.Fatalf("leases = %+v", info.Leases)
+	}
+	if !reflect.DeepEqual(info.Resolvers, []string{"192.168.1.1"}) {
+		t.Errorf("Resolvers = %v", info.Resolvers)
+	}
+	if !reflect.DeepEqual(info.Unused, []string{"9.9.9.9"}) {
+		t.Errorf("Unused = %v, want [9.9.9.9]", info.Unused)
+	}
+}
+
+func TestLeaseInterfaceFromName(t *testing.T) {
+	for name, want := range map[string]string{
+		"dhclient-eth0.leases":  "eth0",
+		"dhclient.wlan0.leases": "wlan0",
+		"internal-9b1c4a37-1c59-4d6e-8f5b-0e1f2a3b4c5d-enp3s0.lease": "enp3s0",
+		"dhclient.leases": "",
+	} {
+		if got := leaseInterfaceFromName(name); got != want {
+			t.Errorf("leaseInterfaceFromName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,325 @@
+package collector
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Diagnosis step names, in execution order
+const (
+	StepDNS  = "DNS resolution"
+	StepTCP  = "TCP connect"
+	StepTLS  = "TLS handshake"
+	StepHTTP = "HTTP request"
+	StepPing = "Ping"
+)
+
+// DiagnoseStep is one item of the URL diagnosis checklist
+type DiagnoseStep struct {
+	Name    string
+	Passed  bool
+	Skipped bool // Not applicable (e.g. TLS for http://) or blocked by an earlier failure
+	Latency time.Duration
+	Detail  string
+	Error   error
+}
+
+// URLDiagnosis is the ordered checklist for one URL
+type URLDiagnosis struct {
+	URL     string
+	Steps   []DiagnoseStep
+	Headers *SecurityHeaders // From the HTTP response, nil if there was none
+}
+
+// SecurityHeaders are the security relevant headers of an HTTP response.
+// Missing headers are noted, they never fail the probe.
+type SecurityHeaders struct {
+	HSTS              string // Strict-Transport-Security value, empty if absent
+	HSTSMaxAge        time.Duration
+	HSTSSubdomains    bool
+	HSTSPreload       bool
+	CSP               bool   // A Content-Security-Policy is set
+	CSPFrameAncestors bool   // The CSP restricts framing, which supersedes X-Frame-Options
+	FrameOptions      string // X-Frame-Options value
+	Server            string // Server banner, may disclose software versions
+	Missing           []string
+}
+
+// FirstFailure returns the first failed step, nil if none failed
+func (d URLDiagnosis) FirstFailure() *DiagnoseStep {
+	for i := range d.Steps {
+		if !d.Steps[i].Passed && !d.Steps[i].Skipped {
+			return &d.Steps[i]
+		}
+	}
+	return nil
+}
+
+// URLDiagnoser runs the "this website won't load" checklist
+type URLDiagnoser struct {
+	PingOptions
+	Source *SourceInterface // Binds the connect and the ping to one interface's addresses
+
+	rootCAs  *x509.CertPool // nil uses the system pool
+	resolver *net.Resolver
+	ping     func(target string) PingResult
+}
+
+func NewURLDiagnoser() *URLDiagnoser {
+	d := &URLDiagnoser{resolver: net.DefaultResolver}
+	d.Privileged = true
+	d.ping = func(target string) PingResult { return pingTarget(target, d.PingOptions, d.Source) }
+	return d
+}
+
+// Diagnose resolves, connects, handshakes, requests and pings the URL's host.
+// Steps after a failure are marked skipped, except ping which still runs when
+// an address is known.
+func (d *URLDiagnoser) Diagnose(ctx context.Context, rawURL string) URLDiagnosis {
+	diag := URLDiagnosis{URL: rawURL}
+	add := func(step DiagnoseStep) { diag.Steps = append(diag.Steps, step) }
+	skipRest := func(from int) {
+		names := []string{StepDNS, StepTCP, StepTLS, StepHTTP}
+		for _, name := range names[from:] {
+			add(DiagnoseStep{Name: name, Skipped: true, Detail: "skipped"})
+		}
+	}
+
+	u, err := parseDiagnoseURL(rawURL)
+	if err != nil {
+		add(DiagnoseStep{Name: StepDNS, Error: err})
+		skipRest(1)
+		add(DiagnoseStep{Name: StepPing, Skipped: true, Detail: "skipped"})
+		return diag
+	}
+	diag.URL = u.String()
+	host, port := u.Hostname(), u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+
+	// 1. DNS
+	start := time.Now()
+	ips, err := d.resolver.LookupIPAddr(ctx, host)
+	dnsStep := DiagnoseStep{Name: StepDNS, Latency: time.Since(start), Error: err}
+	if err == nil && len(ips) == 0 {
+		dnsStep.Error = fmt.Errorf("no addresses for %s", host)
+	}
+	var ip string
+	if dnsStep.Error == nil {
+		ip = ips[0].IP.String()
+		dnsStep.Passed = true
+		dnsStep.Detail = fmt.Sprintf("%s -> %s", host, ip)
+		if len(ips) > 1 {
+			dnsStep.Detail += fmt.Sprintf(" (+%d more)", len(ips)-1)
+		}
+	}
+	add(dnsStep)
+	if ip == "" {
+		skipRest(1)
+		add(DiagnoseStep{Name: StepPing, Skipped: true, Detail: "skipped"})
+		return diag
+	}
+
+	// 2. TCP
+	address := net.JoinHostPort(ip, port)
+	dialer := &markedDialer{Source: d.Source, Timeout: 5 * time.Second}
+	start = time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	tcpStep := DiagnoseStep{Name: StepTCP, Latency: time.Since(start), Error: err, Passed: err == nil}
+	if err == nil {
+		tcpStep.Detail = address
+	}
+	add(tcpStep)
+
+	if err == nil {
+		d.diagnoseHTTP(ctx, &diag, conn, u, host)
+	} else {
+		skipRest(2)
+	}
+
+	// 5. Ping the resolved address, useful even when TCP failed
+	start = time.Now()
+	pingRes := d.ping(ip)
+	pingStep := DiagnoseStep{Name: StepPing, Latency: pingRes.AvgRtt, Error: pingRes.Error}
+	if pingRes.Error == nil && pingRes.PacketLoss < 100 {
+		pingStep.Passed = true
+		pingStep.Detail = fmt.Sprintf("%s loss %.0f%%", ip, pingRes.PacketLoss)
+	} else if pingStep.Error == nil {
+		pingStep.Error = fmt.Errorf("no reply from %s", ip)
+		pingStep.Latency = time.Since(start)
+	}
+	add(pingStep)
+
+	return diag
+}
+
+// diagnoseHTTP runs the TLS (https only) and HTTP steps over the connected socket
+func (d *URLDiagnoser) diagnoseHTTP(ctx context.Context, diag *URLDiagnosis, conn net.Conn, u *url.URL, host string) {
+	add := func(step DiagnoseStep) { diag.Steps = append(diag.Steps, step) }
+
+	// 3. TLS
+	if u.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: host, RootCAs: d.rootCAs, NextProtos: []string{"http/1.1"}})
+		start := time.Now()
+		err := tlsConn.HandshakeContext(ctx)
+		tlsStep := DiagnoseStep{Name: StepTLS, Latency: time.Since(start), Error: err, Passed: err == nil}
+		if err == nil {
+			tlsStep.Detail = certSummary(tlsConn.ConnectionState())
+		}
+		add(tlsStep)
+		if err != nil {
+			conn.Close()
+			add(DiagnoseStep{Name: StepHTTP, Skipped: true, Detail: "skipped"})
+			return
+		}
+		conn = tlsConn
+	} else {
+		add(DiagnoseStep{Name: StepTLS, Skipped: true, Detail: "not https"})
+	}
+
+	// 4. HTTP over the already established connection
+	used := false
+	transport := &http.Transport{
+		DisableKeepAlives: true,
+		DialContext: func(context.Context, string, string) (net.Conn, error) {
+			if used {
+				return nil, fmt.Errorf("connection already used")
+			}
+			used = true
+			return conn, nil
+		},
+	}
+	// The connection is already TLS, make the transport treat https as plain
+	reqURL := *u
+	reqURL.Scheme = "http"
+	defer transport.CloseIdleConnections()
+
+	var start, firstByte time.Time
+	trace := &httptrace.ClientTrace{
+		WroteRequest:         func(httptrace.WroteRequestInfo) { start = time.Now() },
+		GotFirstResponseByte: func() { firstByte = time.Now() },
+	}
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), http.MethodGet, reqURL.String(), nil)
+	if err != nil {
+		conn.Close()
+		add(DiagnoseStep{Name: StepHTTP, Error: err})
+		return
+	}
+	req.Host = u.Host
+	req.Header.Set("User-Agent", "lnd")
+
+	client := &http.Client{Transport: transport, Timeout: 10 * time.Second}
+	reqStart := time.Now()
+	resp, err := client.Do(req)
+	httpStep := DiagnoseStep{Name: StepHTTP, Error: err}
+	if err != nil {
+		httpStep.Latency = time.Since(reqStart)
+		add(httpStep)
+		return
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+	resp.Body.Close()
+	headers := parseSecurityHeaders(resp.Header, u.Scheme == "https")
+	diag.Headers = &headers
+
+	if !firstByte.IsZero() && !start.IsZero() {
+		httpStep.Latency = firstByte.Sub(start) // TTFB
+	} else {
+		httpStep.Latency = time.Since(reqStart)
+	}
+	httpStep.Detail = fmt.Sprintf("%s, TTFB %dms", resp.Status, httpStep.Latency.Milliseconds())
+	if resp.StatusCode >= 400 {
+		httpStep.Error = fmt.Errorf("server returned %s", resp.Status)
+	} else {
+		httpStep.Passed = true
+	}
+	add(httpStep)
+}
+
+// parseDiagnoseURL accepts full URLs or bare hosts (defaulting to https)
+func parseDiagnoseURL(raw string) (*url.URL, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, fmt.Errorf("empty URL")
+	}
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("no host in %q", raw)
+	}
+	return u, nil
+}
+
+// parseSecurityHeaders reads the security headers of a response. HSTS is
+// only expected over https, browsers ignore it on plain http.
+func parseSecurityHeaders(h http.Header, https bool) SecurityHeaders {
+	sh := SecurityHeaders{
+		HSTS:         h.Get("Strict-Transport-Security"),
+		FrameOptions: h.Get("X-Frame-Options"),
+		Server:       h.Get("Server"),
+	}
+	for _, directive := range strings.Split(sh.HSTS, ";") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		switch strings.ToLower(name) {
+		case "max-age":
+			if secs, err := strconv.ParseInt(strings.Trim(value, `"`), 10, 64); err == nil {
+				sh.HSTSMaxAge = time.Duration(secs) * time.Second
+			}
+		case "includesubdomains":
+			sh.HSTSSubdomains = true
+		case "preload":
+			sh.HSTSPreload = true
+		}
+	}
+	if csp := h.Get("Content-Security-Policy"); csp != "" {
+		sh.CSP = true
+		sh.CSPFrameAncestors = strings.Contains(strings.ToLower(csp), "frame-ancestors")
+	}
+
+	if https && sh.HSTS == "" {
+		sh.Missing = append(sh.Missing, "Strict-Transport-Security")
+	}
+	if !sh.CSP {
+		sh.Missing = append(sh.Missing, "Content-Security-Policy")
+	}
+	if sh.FrameOptions == "" && !sh.CSPFrameAncestors {
+		sh.Missing = append(sh.Missing, "X-Frame-Options")
+	}
+	return sh
+}
+
+// certSummary describes the leaf certificate and warns when it expires soon
+func certSummary(state tls.ConnectionState) string {
+	info := getCertInfo(state)
+	if info == nil {
+		return "no certificate"
+	}
+	days := int(time.Until(info.NotAfter).Hours() / 24)
+	s := fmt.Sprintf("%s, expires in %d days", tls.VersionName(state.Version), days)
+	if days < 14 {
+		s += " (renew soon!)"
+	}
+	return s
+}
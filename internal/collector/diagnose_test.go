@@ -0,0 +1,118 @@
+package collector
+
+import (
+	"context"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestURLDiagnoser_Diagnose(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(5 * time.Millisecond) // Measurable TTFB
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	var pinged string
+	d := NewURLDiagnoser()
+	d.rootCAs = x509.NewCertPool()
+	d.rootCAs.AddCert(ts.Certificate())
+	d.ping = func(target string) PingResult {
+		pinged = target
+		return PingResult{Target: target, AvgRtt: time.Millisecond}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	diag := d.Diagnose(ctx, ts.URL+"/health")
+
+	want := []string{StepDNS, StepTCP, StepTLS, StepHTTP, StepPing}
+	if len(diag.Steps) != len(want) {
+		t.Fatalf("expected %d steps, got %+v", len(want), diag.Steps)
+	}
+	for i, step := range diag.Steps {
+		if step.Name != want[i] {
+			t.Errorf("step %d = %s, want %s", i, step.Name, want[i])
+		}
+		if !step.Passed || step.Error != nil {
+			t.Errorf("%s failed: %v", step.Name, step.Error)
+		}
+		if step.Name != StepDNS && step.Latency <= 0 {
+			t.Errorf("%s was not timed", step.Name)
+		}
+	}
+	if diag.Steps[3].Latency < 5*time.Millisecond {
+		t.Errorf("TTFB = %s, want >= 5ms", diag.Steps[3].Latency)
+	}
+	if pinged != "127.0.0.1" {
+		t.Errorf("pinged %q, want the resolved IP", pinged)
+	}
+	if diag.FirstFailure() != nil {
+		t.Errorf("unexpected failure: %+v", diag.FirstFailure())
+	}
+}
+
+func TestURLDiagnoser_UntrustedCert(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	d := NewURLDiagnoser()
+	d.ping = func(target string) PingResult { return PingResult{Target: target} }
+
+	diag := d.Diagnose(context.Background(), ts.URL)
+	failed := diag.FirstFailure()
+	if failed == nil || failed.Name != StepTLS {
+		t.Fatalf("expected TLS step to fail, got %+v", failed)
+	}
+	if step := diag.Steps[3]; step.Name != StepHTTP || !step.Skipped {
+		t.Errorf("HTTP step should be skipped after TLS failure: %+v", step)
+	}
+}
+
+func TestParseDiagnoseURL(t *testing.T) {
+	u, err := parseDiagnoseURL("example.com/path")
+	if err != nil || u.Scheme != "https" || u.Hostname() != "example.com" {
+		t.Errorf("bare host: got %v, %v", u, err)
+	}
+	if _, err := parseDiagnoseURL("ftp://example.com"); err == nil {
+		t.Error("expected error for unsupported scheme")
+	}
+}
+
+func TestURLDiagnoser_SecurityHeaders(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Strict-Transport-Security", "max-age=31536000; includeSubDomains; preload")
+		w.Header().Set("Server", "nginx/1.25.3")
+		w.WriteHeader(http.StatusNotFound) // Headers are reported whatever the status
+	}))
+	defer ts.Close()
+
+	d := NewURLDiagnoser()
+	d.rootCAs = x509.NewCertPool()
+	d.rootCAs.AddCert(ts.Certificate())
+	d.ping = func(target string) PingResult { return PingResult{Target: target} }
+
+	h := d.Diagnose(context.Background(), ts.URL).Headers
+	if h == nil {
+		t.Fatal("no security headers reported")
+	}
+	if h.HSTSMaxAge != 365*24*time.Hour || !h.HSTSSubdomains || !h.HSTSPreload {
+		t.Errorf("HSTS = %+v", h)
+	}
+	if h.Server != "nginx/1.25.3" {
+		t.Errorf("Server = %q", h.Server)
+	}
+	want := []string{"Content-Security-Policy", "X-Frame-Options"}
+	if !slices.Equal(h.Missing, want) {
+		t.Errorf("Missing = %v, want %v", h.Missing, want)
+	}
+
+	plain := parseSecurityHeaders(http.Header{"Content-Security-Policy": {"frame-ancestors 'none'"}}, false)
+	if len(plain.Missing) != 0 {
+		t.Errorf("plain http with a framing CSP should miss nothing, got %v", plain.Missing)
+	}
+}
@@ -2,14 +2,27 @@ package collector
 
 import (
 	"context"
+	"crypto/rand"
 	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+	"github.com/sysatom/lnd/internal/config"
+	"golang.org/x/crypto/ocsp"
 )
 
 type DNSRecordType string
@@ -29,17 +42,30 @@ const (
 type DNSProtocol string
 
 const (
-	ProtoUDP DNSProtocol = "UDP"
-	ProtoTCP DNSProtocol = "TCP"
-	ProtoDoT DNSProtocol = "DoT"
-	ProtoDoH DNSProtocol = "DoH"
-	ProtoDoQ DNSProtocol = "DoQ" // Placeholder, might require quic-go
+	ProtoUDP  DNSProtocol = "UDP"
+	ProtoTCP  DNSProtocol = "TCP"
+	ProtoDoT  DNSProtocol = "DoT"
+	ProtoDoH  DNSProtocol = "DoH"
+	ProtoDoH3 DNSProtocol = "DoH3" // DoH over HTTP/3 (QUIC)
+	ProtoDoQ  DNSProtocol = "DoQ"  // DNS over dedicated QUIC connections, RFC 9250
+)
+
+// DNSFamily selects the IP family used to reach a DNS server
+type DNSFamily string
+
+const (
+	FamilyAuto DNSFamily = "auto"
+	FamilyIPv4 DNSFamily = "ipv4"
+	FamilyIPv6 DNSFamily = "ipv6"
 )
 
 type DNSServer struct {
-	Name    string
-	Address string // IP:Port or URL for DoH
-	Proto   DNSProtocol
+	Name       string
+	Address    string // IP:Port or URL for DoH
+	Proto      DNSProtocol
+	SourcePort int       // Local port to bind for UDP/TCP queries, 0 for ephemeral
+	H3Fallback bool      // Retry DoH3 queries over HTTP/2 when QUIC fails
+	Family     DNSFamily // Force IPv4 or IPv6 when the server name resolves to both, empty = auto
 }
 
 var DefaultDNSServers = []DNSServer{
@@ -50,14 +76,106 @@ var DefaultDNSServers = []DNSServer{
 	{Name: "Custom", Address: "", Proto: ProtoDoT},
 }
 
+// ParseResolver reads a resolver given as one string with the rules of
+// config.ParseResolver: an https:// URL is DoH, anything else host[:port]
+// over UDP
+func ParseResolver(spec string) (DNSServer, error) {
+	s, err := config.ParseResolver(spec)
+	if err != nil {
+		return DNSServer{}, err
+	}
+	proto := DNSProtocol(s.Proto)
+	address, err := NormalizeDNSServerAddress(proto, s.Address)
+	if err != nil {
+		return DNSServer{}, err
+	}
+	return DNSServer{Name: s.Name, Address: address, Proto: proto}, nil
+}
+
+// NormalizeDNSServerAddress validates a server address for a protocol and
+// fills in defaults: host[:port] with port 53 (UDP/TCP) or 853 (DoT/DoQ), and an
+// https:// URL with the /dns-query path for DoH and DoH3
+func NormalizeDNSServerAddress(proto DNSProtocol, address string) (string, error) {
+	address = strings.TrimSpace(address)
+	if address == "" {
+		return "", fmt.Errorf("address is required")
+	}
+
+	switch proto {
+	case ProtoDoH, ProtoDoH3:
+		u, err := url.Parse(address)
+		if err != nil || u.Scheme != "https" || u.Hostname() == "" {
+			return "", fmt.Errorf("%s needs an https:// URL, e.g. https://dns.google/dns-query", proto)
+		}
+		if u.Path == "" {
+			u.Path = "/dns-query"
+		}
+		return u.String(), nil
+
+	case ProtoUDP, ProtoTCP, ProtoDoT, ProtoDoQ:
+		port := "53"
+		if proto == ProtoDoT || proto == ProtoDoQ {
+			port = "853"
+		}
+		if ip, err := netip.ParseAddr(address); err == nil {
+			return net.JoinHostPort(ip.String(), port), nil
+		}
+		host := address
+		if h, p, err := net.SplitHostPort(address); err == nil {
+			if n, err := strconv.Atoi(p); err != nil || n < 1 || n > 65535 {
+				return "", fmt.Errorf("invalid port %q", p)
+			}
+			host, port = h, p
+		}
+		if _, err := netip.ParseAddr(host); err != nil {
+			if _, ok := dns.IsDomainName(host); !ok || strings.ContainsAny(host, " /:@") {
+				return "", fmt.Errorf("%s needs host[:port], got %q", proto, address)
+			}
+		}
+		return net.JoinHostPort(host, port), nil
+	}
+	return "", fmt.Errorf("unsupported protocol %q", proto)
+}
+
 type DNSLookupResult struct {
-	Records      []string
-	Latency      time.Duration
-	Server       string
-	Protocol     DNSProtocol
-	Error        error
-	CertInfo     *CertInfo // For encrypted protocols
-	ResponseCode string
+	Records         []string
+	Latency         time.Duration
+	Server          string
+	Protocol        DNSProtocol
+	Error           error
+	CertInfo        *CertInfo `report:"detail"` // For encrypted protocols
+	ResponseCode    string
+	UnicodeName     string // Internationalized query name as entered, empty for ASCII names
+	ASCIIName       string // A-label (xn--) form it was sent as, set along with UnicodeName
+	ExtendedError   string // RFC 8914 reason given by the resolver, e.g. "DNSSEC Bogus"
+	SourcePort      int    // Local port the query was sent from (UDP/TCP)
+	ALPN            string // Negotiated application protocol for encrypted transports, e.g. h2, h3
+	Fallback        string // Describes a transport fallback taken for this query
+	CookieSent      bool
+	Cookie          *DNSCookie // Cookie echoed by the server, nil if none
+	DNSSECRequested bool       // The query set the DO bit
+	Authenticated   bool       // AD bit: the resolver validated the answer with DNSSEC
+	Family          string     // IP family actually used to reach the server: IPv4 or IPv6
+	SRV             []SRVRecord
+	ResponseSize    int          // Bytes on the wire, without the TCP length prefix
+	Compressed      bool         // The server used name compression
+	CNAMEChain      []string     // Query name, then each CNAME target in order; nil without CNAMEs
+	ChainAnswers    []string     // Data of the records at the end of the chain, e.g. the final addresses
+	Wildcard        *DNSWildcard // Sibling name probe, nil unless requested
+
+	// Full message sections, dig style
+	Flags      []string `report:"detail"` // Header flags set in the response, e.g. qr rd ra
+	Question   []string `report:"detail"`
+	Authority  []string `report:"detail"`
+	Additional []string `report:"detail"` // Excludes the EDNS OPT pseudo-record
+
+	msg *dns.Msg // Parsed response, for probes that need more than the text sections
+}
+
+// DNSCookie holds the RFC 7873 cookies of a response
+type DNSCookie struct {
+	Client string // Hex encoded
+	Server string // Hex encoded, empty if the server does not support cookies
 }
 
 type CertInfo struct {
@@ -68,26 +186,160 @@ type CertInfo struct {
 	CipherSuite uint16
 	Version     uint16
 	DNSNames    []string
+
+	// Stapled OCSP response from the handshake
+	OCSPStatus     OCSPStatus
+	OCSPNextUpdate time.Time // When the responder promises fresh status, zero if unset
+	OCSPRevokedAt  time.Time
+	OCSPError      error // The staple could not be parsed or its signature is invalid
 }
 
+// OCSPStatus is the certificate status in a stapled OCSP response
+type OCSPStatus string
+
+const (
+	OCSPNone    OCSPStatus = "none" // The server did not staple a response
+	OCSPGood    OCSPStatus = "good"
+	OCSPRevoked OCSPStatus = "revoked"
+	OCSPUnknown OCSPStatus = "unknown" // The responder does not know the certificate, or the staple is unreadable
+)
+
+// dnsDialFunc opens a connection to a DNS server, optionally from a fixed source port
+type dnsDialFunc func(ctx context.Context, network, address string, sourcePort int) (net.Conn, error)
+
 type DNSCollector struct {
+	Source  *SourceInterface // Binds queries to one interface's addresses, nil = kernel's choice
+	Budget  *Budget          // Shared probe budget, every query on the wire counts
+	rootCAs *x509.CertPool   // Trusted roots for DoT/DoH, nil uses the system pool
+	roots   []traceServer    // Where Trace starts, nil uses rootHints
+	dial    dnsDialFunc
 }
 
 func NewDNSCollector() *DNSCollector {
-	return &DNSCollector{}
+	c := &DNSCollector{}
+	c.dial = func(ctx context.Context, network, address string, sourcePort int) (net.Conn, error) {
+		dialer := dnsDialer(network, sourcePort, 5*time.Second)
+		if local := c.Source.localAddr(network, address, sourcePort); local != nil {
+			dialer.LocalAddr = local
+		}
+		return dialer.DialContext(ctx, network, address)
+	}
+	return c
 }
 
+// DNSQueryOptions tunes how a query is built
+type DNSQueryOptions struct {
+	Cookie          bool   // Attach an RFC 7873 client cookie in an EDNS0 OPT record
+	Wildcard        bool   // Also query a random sibling name to detect a wildcard record
+	UDPSize         uint16 // UDP payload size advertised in an EDNS0 OPT record, 0 sends none unless another option needs it
+	DNSSECRequested bool   // Set the DO bit to ask for DNSSEC records, implies an OPT record
+}
+
+// defaultEDNSBufSize is the UDP payload size advertised in the OPT record
+const defaultEDNSBufSize = 4096
+
+// dnsIOTimeout bounds a query's write and read when the context has no
+// earlier deadline, the same as dns.Client's default
+const dnsIOTimeout = 2 * time.Second
+
 func (c *DNSCollector) Lookup(ctx context.Context, domain string, recordType DNSRecordType, server DNSServer) DNSLookupResult {
+	return c.LookupWithOptions(ctx, domain, recordType, server, DNSQueryOptions{})
+}
+
+func (c *DNSCollector) LookupWithOptions(ctx context.Context, domain string, recordType DNSRecordType, server DNSServer, opts DNSQueryOptions) DNSLookupResult {
+	msg, err := buildQuery(domain, recordType, opts)
+	if err != nil {
+		return DNSLookupResult{Error: err}
+	}
+
+	res := c.exchange(ctx, msg, server)
+	res = c.retryTruncated(ctx, msg, server, res)
+	res.CookieSent = opts.Cookie
+	res.DNSSECRequested = opts.DNSSECRequested
+	if !isASCII(domain) {
+		res.UnicodeName = strings.TrimSuffix(domain, ".")
+		res.ASCIIName = strings.TrimSuffix(msg.Question[0].Name, ".")
+	}
+	c.explainFailure(ctx, msg, server, &res)
+	if opts.Wildcard && res.Error == nil && msg.Question[0].Qtype != dns.TypePTR {
+		res.Wildcard = c.detectWildcard(ctx, domain, recordType, server, res)
+	}
+	return res
+}
+
+// retryTruncated repeats a query over TCP when the UDP answer had the TC
+// bit set, as a stub resolver would, and notes the fallback
+func (c *DNSCollector) retryTruncated(ctx context.Context, msg *dns.Msg, server DNSServer, res DNSLookupResult) DNSLookupResult {
+	if res.Error != nil || res.Protocol != ProtoUDP || res.msg == nil || !res.msg.Truncated {
+		return res
+	}
+	tcp := server
+	tcp.Proto = ProtoTCP
+	retry := c.exchange(ctx, msg, tcp)
+	if retry.Error != nil {
+		res.Fallback = fmt.Sprintf("UDP answer truncated at %d bytes, TCP retry failed: %v", res.ResponseSize, retry.Error)
+		return res
+	}
+	retry.Fallback = fmt.Sprintf("UDP answer truncated at %d bytes, retried over TCP", res.ResponseSize)
+	return retry
+}
+
+// exchangeBytes is what one query over proto costs the budget
+func exchangeBytes(proto DNSProtocol) int64 {
+	bytes := int64(dnsProbeBytes)
+	if proto != ProtoUDP && proto != ProtoTCP {
+		bytes += tunnelProbeBytes // TLS or QUIC handshake
+	}
+	return bytes
+}
+
+// lookupCost is the most a Lookup without options charges the budget: the
+// query, the TCP retry of a truncated UDP answer and the EDNS0 query
+// explainFailure sends after SERVFAIL or REFUSED
+func lookupCost(proto DNSProtocol) (probes int, bytes int64) {
+	probes, bytes = 2, 2*exchangeBytes(proto)
+	if proto == ProtoUDP {
+		probes, bytes = probes+1, bytes+exchangeBytes(ProtoTCP)
+	}
+	return probes, bytes
+}
+
+// exchange sends msg over the server's transport
+func (c *DNSCollector) exchange(ctx context.Context, msg *dns.Msg, server DNSServer) DNSLookupResult {
+	if !c.Budget.Allow(1, exchangeBytes(server.Proto)) {
+		return DNSLookupResult{Error: ErrBudgetExceeded, Server: server.Address, Protocol: server.Proto}
+	}
+	switch server.Proto {
+	case ProtoDoH:
+		return c.lookupDoH(ctx, msg, server)
+	case ProtoDoH3:
+		return c.lookupDoH3(ctx, msg, server)
+	case ProtoDoT:
+		return c.lookupDoT(ctx, msg, server)
+	case ProtoDoQ:
+		return c.lookupDoQ(ctx, msg, server)
+	default: // UDP/TCP
+		return c.lookupStandard(ctx, msg, server)
+	}
+}
+
+// buildQuery builds the query message for a domain and record type
+func buildQuery(domain string, recordType DNSRecordType, opts DNSQueryOptions) (*dns.Msg, error) {
 	// Handle Reverse Lookup (PTR) automatically if domain looks like an IP
 	if recordType == RecordPTR || isIP(domain) {
 		recordType = RecordPTR
 		var err error
 		domain, err = dns.ReverseAddr(domain)
 		if err != nil {
-			return DNSLookupResult{Error: fmt.Errorf("invalid IP for reverse lookup: %v", err)}
+			return nil, fmt.Errorf("invalid IP for reverse lookup: %v", err)
 		}
 	}
 
+	domain, err := ToASCIIName(domain)
+	if err != nil {
+		return nil, err
+	}
+
 	// Ensure domain ends with .
 	if !strings.HasSuffix(domain, ".") {
 		domain += "."
@@ -119,21 +371,61 @@ func (c *DNSCollector) Lookup(ctx context.Context, domain string, recordType DNS
 	msg.SetQuestion(domain, qType)
 	msg.RecursionDesired = true
 
-	switch server.Proto {
-	case ProtoDoH:
-		return c.lookupDoH(ctx, msg, server)
-	case ProtoDoT:
-		return c.lookupDoT(ctx, msg, server)
-	case ProtoDoQ:
-		return DNSLookupResult{Error: fmt.Errorf("DoQ not implemented yet")}
-	default: // UDP/TCP
-		return c.lookupStandard(ctx, msg, server)
+	if opts.UDPSize > 0 || opts.DNSSECRequested || opts.Cookie {
+		size := opts.UDPSize
+		if size == 0 {
+			size = defaultEDNSBufSize
+		}
+		msg.SetEdns0(size, opts.DNSSECRequested)
+	}
+	if opts.Cookie {
+		cookie, err := newClientCookie()
+		if err != nil {
+			return nil, fmt.Errorf("generating client cookie: %v", err)
+		}
+		opt := msg.IsEdns0()
+		opt.Option = append(opt.Option, &dns.EDNS0_COOKIE{Code: dns.EDNS0COOKIE, Cookie: cookie})
 	}
+
+	return msg, nil
+}
+
+// newClientCookie returns a random 8-byte client cookie, hex encoded
+func newClientCookie() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// extractCookie returns the client and server cookie echoed in a response OPT record.
+// The server cookie is empty when the server does not support DNS cookies.
+func extractCookie(r *dns.Msg) *DNSCookie {
+	opt := r.IsEdns0()
+	if opt == nil {
+		return nil
+	}
+	for _, o := range opt.Option {
+		cookie, ok := o.(*dns.EDNS0_COOKIE)
+		if !ok {
+			continue
+		}
+		// Client cookie is 8 bytes (16 hex chars), server cookie 8-32 bytes
+		if len(cookie.Cookie) < 16 {
+			return nil
+		}
+		return &DNSCookie{Client: cookie.Cookie[:16], Server: cookie.Cookie[16:]}
+	}
+	return nil
 }
 
 func (c *DNSCollector) lookupStandard(ctx context.Context, msg *dns.Msg, server DNSServer) DNSLookupResult {
-	client := new(dns.Client)
-	client.Net = "udp"
+	proto := ProtoUDP
+	if server.Proto == ProtoTCP {
+		proto = ProtoTCP
+	}
+	network := strings.ToLower(string(proto))
 
 	address := server.Address
 	if server.Name == "System" {
@@ -150,14 +442,107 @@ func (c *DNSCollector) lookupStandard(ctx context.Context, msg *dns.Msg, server
 	}
 
 	start := time.Now()
-	r, _, err := client.ExchangeContext(ctx, msg, address)
+	raw, err := c.dial(ctx, familyNetwork(network, server.Family), address, server.SourcePort)
+	if err != nil {
+		if errors.Is(err, syscall.EADDRINUSE) {
+			err = fmt.Errorf("source port %d is already in use", server.SourcePort)
+		}
+		return DNSLookupResult{Error: err, Latency: time.Since(start), Server: address, Protocol: proto}
+	}
+	conn := &dns.Conn{Conn: raw}
+	defer conn.Close()
+
+	sourcePort := 0
+	if addr, err := netip.ParseAddrPort(conn.LocalAddr().String()); err == nil {
+		sourcePort = int(addr.Port())
+	}
+	family := addrFamily(conn.RemoteAddr())
+
+	r, wire, err := exchangeConn(ctx, conn, msg)
 	latency := time.Since(start)
 
 	if err != nil {
-		return DNSLookupResult{Error: err, Latency: latency, Server: address, Protocol: ProtoUDP}
+		return DNSLookupResult{Error: err, Latency: latency, Server: address, Protocol: proto, SourcePort: sourcePort, Family: family}
 	}
 
-	return parseResponse(r, latency, address, ProtoUDP, nil)
+	res := parseResponse(r, wire, latency, address, proto, nil)
+	res.SourcePort = sourcePort
+	res.Family = family
+	return res
+}
+
+// exchangeConn sends msg and reads the reply, returning its wire form along
+// with the parsed message. Like dns.Client, replies with another ID are
+// skipped on UDP and rejected on streams.
+func exchangeConn(ctx context.Context, co *dns.Conn, msg *dns.Msg) (*dns.Msg, []byte, error) {
+	if opt := msg.IsEdns0(); opt != nil && opt.UDPSize() >= dns.MinMsgSize {
+		co.UDPSize = opt.UDPSize()
+	}
+	deadline := time.Now().Add(dnsIOTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	co.SetDeadline(deadline)
+
+	if err := co.WriteMsg(msg); err != nil {
+		return nil, nil, err
+	}
+	_, packet := co.Conn.(net.PacketConn)
+	for {
+		wire, err := co.ReadMsgHeader(nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		r := new(dns.Msg)
+		if err := r.Unpack(wire); err != nil {
+			return nil, nil, err
+		}
+		if r.Id == msg.Id {
+			return r, wire, nil
+		}
+		if !packet {
+			return nil, nil, dns.ErrId
+		}
+	}
+}
+
+// familyNetwork narrows a network ("udp", "tcp") to the requested IP family
+func familyNetwork(network string, family DNSFamily) string {
+	switch family {
+	case FamilyIPv4:
+		return network + "4"
+	case FamilyIPv6:
+		return network + "6"
+	}
+	return network
+}
+
+// addrFamily reports whether a remote address is IPv4 or IPv6
+func addrFamily(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	ap, err := netip.ParseAddrPort(addr.String())
+	if err != nil {
+		return ""
+	}
+	if ap.Addr().Unmap().Is4() {
+		return "IPv4"
+	}
+	return "IPv6"
+}
+
+// dnsDialer returns a dialer bound to the given local source port (0 = ephemeral)
+func dnsDialer(network string, sourcePort int, timeout time.Duration) *net.Dialer {
+	dialer := &net.Dialer{Timeout: timeout}
+	if sourcePort > 0 {
+		if strings.HasPrefix(network, "tcp") {
+			dialer.LocalAddr = &net.TCPAddr{Port: sourcePort}
+		} else {
+			dialer.LocalAddr = &net.UDPAddr{Port: sourcePort}
+		}
+	}
+	return dialer
 }
 
 func (c *DNSCollector) lookupDoT(ctx context.Context, msg *dns.Msg, server DNSServer) DNSLookupResult {
@@ -178,30 +563,25 @@ func (c *DNSCollector) lookupDoT(ctx context.Context, msg *dns.Msg, server DNSSe
 	// We need to capture TLS info. miekg/dns Client doesn't expose the conn easily in Exchange.
 	// We might need to dial manually.
 
-	dialer := &net.Dialer{Timeout: 5 * time.Second}
-	tlsConfig := &tls.Config{
-		InsecureSkipVerify: false, // Should verify for security
-	}
-
 	// Extract host for TLS verification
 	tlsHost, _, _ := net.SplitHostPort(address)
-	tlsConfig.ServerName = tlsHost
+	tlsConfig := c.tlsConfig(tlsHost)
 
 	start := time.Now()
-	conn, err := tls.DialWithDialer(dialer, "tcp", address, tlsConfig)
+	raw, err := c.dial(ctx, familyNetwork("tcp", server.Family), address, 0)
 	if err != nil {
 		return DNSLookupResult{Error: err, Latency: time.Since(start), Server: address, Protocol: ProtoDoT}
 	}
+	conn := tls.Client(raw, tlsConfig)
 	defer conn.Close()
+	if err := conn.HandshakeContext(ctx); err != nil {
+		return DNSLookupResult{Error: err, Latency: time.Since(start), Server: address, Protocol: ProtoDoT}
+	}
 
 	dnsConn := new(dns.Conn)
 	dnsConn.Conn = conn
 
-	if err := dnsConn.WriteMsg(msg); err != nil {
-		return DNSLookupResult{Error: err, Latency: time.Since(start), Server: address, Protocol: ProtoDoT}
-	}
-
-	r, err := dnsConn.ReadMsg()
+	r, wire, err := exchangeConn(ctx, dnsConn, msg)
 	latency := time.Since(start)
 	if err != nil {
 		return DNSLookupResult{Error: err, Latency: latency, Server: address, Protocol: ProtoDoT}
@@ -209,18 +589,173 @@ func (c *DNSCollector) lookupDoT(ctx context.Context, msg *dns.Msg, server DNSSe
 
 	certInfo := getCertInfo(conn.ConnectionState())
 
-	return parseResponse(r, latency, address, ProtoDoT, certInfo)
+	res := parseResponse(r, wire, latency, address, ProtoDoT, certInfo)
+	res.Family = addrFamily(conn.RemoteAddr())
+	return res
 }
 
-func (c *DNSCollector) lookupDoH(ctx context.Context, msg *dns.Msg, server DNSServer) DNSLookupResult {
-	// Pack message
-	packed, err := msg.Pack()
+// doqALPN is the application protocol DoQ servers negotiate, RFC 9250
+const doqALPN = "doq"
+
+// alertNoApplicationProtocol is the TLS alert for a failed ALPN
+// negotiation, carried as QUIC crypto error 0x100 + alert
+const alertNoApplicationProtocol = 120
+
+// lookupDoQ sends msg on its own stream of a QUIC connection to port 853,
+// framed with a 2-byte length prefix like DNS over TCP
+func (c *DNSCollector) lookupDoQ(ctx context.Context, msg *dns.Msg, server DNSServer) DNSLookupResult {
+	address := server.Address
+	if host, port, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, "853")
+	} else if port == "53" {
+		address = net.JoinHostPort(host, "853")
+	}
+	fail := func(err error, start time.Time) DNSLookupResult {
+		return DNSLookupResult{Error: err, Latency: time.Since(start), Server: address, Protocol: ProtoDoQ}
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, dnsIOTimeout)
+		defer cancel()
+	}
+	start := time.Now()
+	network := familyNetwork("udp", server.Family)
+	udpAddr, err := net.ResolveUDPAddr(network, address)
 	if err != nil {
-		return DNSLookupResult{Error: err}
+		return fail(err, start)
+	}
+	pc, err := c.listenUDP(network, address)
+	if err != nil {
+		return fail(err, start)
+	}
+	defer pc.Close()
+
+	tlsHost, _, _ := net.SplitHostPort(address)
+	tlsConfig := c.tlsConfig(tlsHost)
+	tlsConfig.NextProtos = []string{doqALPN}
+	conn, err := quic.Dial(ctx, pc, udpAddr, tlsConfig, &quic.Config{HandshakeIdleTimeout: 3 * time.Second})
+	if err != nil {
+		var te *quic.TransportError
+		if errors.As(err, &te) && te.ErrorCode == quic.TransportErrorCode(0x100+alertNoApplicationProtocol) {
+			err = fmt.Errorf("server does not speak DoQ (ALPN %q not negotiated): %w", doqALPN, err)
+		}
+		return fail(err, start)
+	}
+	defer conn.CloseWithError(0, "")
+	state := conn.ConnectionState().TLS
+	if state.NegotiatedProtocol != doqALPN {
+		return fail(fmt.Errorf("server negotiated ALPN %q instead of %q", state.NegotiatedProtocol, doqALPN), start)
+	}
+
+	// The message ID is always 0, the stream identifies the query
+	query := msg.Copy()
+	query.Id = 0
+	packed, err := query.Pack()
+	if err != nil {
+		return fail(err, start)
+	}
+	stream, err := conn.OpenStreamSync(ctx)
+	if err != nil {
+		return fail(err, start)
+	}
+	if d, ok := ctx.Deadline(); ok {
+		stream.SetDeadline(d)
 	}
+	frame := append([]byte{byte(len(packed) >> 8), byte(len(packed))}, packed...)
+	if _, err := stream.Write(frame); err != nil {
+		return fail(err, start)
+	}
+	// Closing the send side tells the server the query is complete
+	stream.Close()
+
+	var prefix [2]byte
+	if _, err := io.ReadFull(stream, prefix[:]); err != nil {
+		return fail(err, start)
+	}
+	wire := make([]byte, int(prefix[0])<<8|int(prefix[1]))
+	if _, err := io.ReadFull(stream, wire); err != nil {
+		return fail(err, start)
+	}
+	latency := time.Since(start)
+	r := new(dns.Msg)
+	if err := r.Unpack(wire); err != nil {
+		return fail(err, start)
+	}
+
+	res := parseResponse(r, wire, latency, address, ProtoDoQ, getCertInfo(state))
+	res.ALPN = state.NegotiatedProtocol
+	res.Family = addrFamily(udpAddr)
+	return res
+}
+
+func (c *DNSCollector) lookupDoH(ctx context.Context, msg *dns.Msg, server DNSServer) DNSLookupResult {
+	var family string
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = c.tlsConfig("")
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := c.dial(ctx, familyNetwork("tcp", server.Family), addr, 0)
+		if err == nil {
+			family = addrFamily(conn.RemoteAddr())
+		}
+		return conn, err
+	}
+	defer transport.CloseIdleConnections()
 
-	// Determine URL
-	url := server.Address
+	res := c.exchangeDoH(ctx, msg, dohURL(server.Address), transport, ProtoDoH)
+	res.Family = family
+	return res
+}
+
+// lookupDoH3 sends the DoH request over HTTP/3 (QUIC), optionally falling back to HTTP/2
+func (c *DNSCollector) lookupDoH3(ctx context.Context, msg *dns.Msg, server DNSServer) DNSLookupResult {
+	var family string
+	var packetConns []net.PacketConn
+	transport := &http3.Transport{
+		TLSClientConfig: c.tlsConfig(""),
+		QUICConfig:      &quic.Config{HandshakeIdleTimeout: 3 * time.Second},
+		Dial: func(ctx context.Context, addr string, tlsCfg *tls.Config, cfg *quic.Config) (*quic.Conn, error) {
+			network := familyNetwork("udp", server.Family)
+			udpAddr, err := net.ResolveUDPAddr(network, addr)
+			if err != nil {
+				return nil, err
+			}
+			pc, err := c.listenUDP(network, addr)
+			if err != nil {
+				return nil, err
+			}
+			packetConns = append(packetConns, pc)
+			family = addrFamily(udpAddr)
+			return quic.DialEarly(ctx, pc, udpAddr, tlsCfg, cfg)
+		},
+	}
+	defer func() {
+		transport.Close()
+		for _, pc := range packetConns {
+			pc.Close()
+		}
+	}()
+
+	res := c.exchangeDoH(ctx, msg, dohURL(server.Address), transport, ProtoDoH3)
+	res.Family = family
+	if res.Error != nil && server.H3Fallback {
+		fallback := c.lookupDoH(ctx, msg, server)
+		fallback.Fallback = fmt.Sprintf("HTTP/3 failed (%v), fell back to HTTP/2", res.Error)
+		return fallback
+	}
+	return res
+}
+
+// listenUDP opens the packet socket QUIC runs over, bound to the source
+// interface's address for reaching address when one is selected
+func (c *DNSCollector) listenUDP(network, address string) (*net.UDPConn, error) {
+	local, _ := c.Source.localAddr(network, address, 0).(*net.UDPAddr)
+	return net.ListenUDP(network, local)
+}
+
+// dohURL maps a server address to its DoH endpoint
+func dohURL(address string) string {
+	url := address
 	if !strings.HasPrefix(url, "https://") {
 		// Map common IPs to their DoH endpoints if not provided
 		if strings.HasPrefix(url, "8.8.8.8") {
@@ -234,6 +769,15 @@ func (c *DNSCollector) lookupDoH(ctx context.Context, msg *dns.Msg, server DNSSe
 			url = "https://" + url + "/dns-query"
 		}
 	}
+	return url
+}
+
+func (c *DNSCollector) exchangeDoH(ctx context.Context, msg *dns.Msg, url string, transport http.RoundTripper, proto DNSProtocol) DNSLookupResult {
+	// Pack message
+	packed, err := msg.Pack()
+	if err != nil {
+		return DNSLookupResult{Error: err}
+	}
 
 	req, err := http.NewRequestWithContext(ctx, "POST", url, strings.NewReader(string(packed)))
 	if err != nil {
@@ -243,69 +787,131 @@ func (c *DNSCollector) lookupDoH(ctx context.Context, msg *dns.Msg, server DNSSe
 	req.Header.Set("Accept", "application/dns-message")
 
 	start := time.Now()
-	// Custom transport to capture TLS info?
-	// Standard http client doesn't easily expose TLS state of the connection used.
-	// However, we can use httptrace or just inspect the response if we trust the client.
-	// Actually, Response.TLS contains the connection state!
-
-	client := &http.Client{Timeout: 5 * time.Second}
+	// Response.TLS contains the connection state, including the negotiated ALPN
+	client := &http.Client{Timeout: 5 * time.Second, Transport: transport}
 	resp, err := client.Do(req)
 	latency := time.Since(start)
 
 	if err != nil {
-		return DNSLookupResult{Error: err, Latency: latency, Server: url, Protocol: ProtoDoH}
+		return DNSLookupResult{Error: err, Latency: latency, Server: url, Protocol: proto}
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return DNSLookupResult{Error: fmt.Errorf("DoH server returned %d", resp.StatusCode), Latency: latency, Server: url, Protocol: ProtoDoH}
+		return DNSLookupResult{Error: fmt.Errorf("DoH server returned %d", resp.StatusCode), Latency: latency, Server: url, Protocol: proto}
 	}
 
-	// Read body
-	buf := make([]byte, 65535)
-	n, err := resp.Body.Read(buf)
-	if err != nil && err.Error() != "EOF" {
-		return DNSLookupResult{Error: err, Latency: latency, Server: url, Protocol: ProtoDoH}
+	// Read body (a DNS message is at most 64KiB)
+	body, err := io.ReadAll(io.LimitReader(resp.Body, dns.MaxMsgSize))
+	if err != nil {
+		return DNSLookupResult{Error: err, Latency: latency, Server: url, Protocol: proto}
 	}
 
 	r := new(dns.Msg)
-	if err := r.Unpack(buf[:n]); err != nil {
-		return DNSLookupResult{Error: err, Latency: latency, Server: url, Protocol: ProtoDoH}
+	if err := r.Unpack(body); err != nil {
+		return DNSLookupResult{Error: err, Latency: latency, Server: url, Protocol: proto}
 	}
 
 	var certInfo *CertInfo
+	alpn := ""
 	if resp.TLS != nil {
 		certInfo = getCertInfo(*resp.TLS)
+		alpn = resp.TLS.NegotiatedProtocol
 	}
 
-	return parseResponse(r, latency, url, ProtoDoH, certInfo)
+	res := parseResponse(r, body, latency, url, proto, certInfo)
+	res.ALPN = alpn
+	return res
+}
+
+// tlsConfig returns the client TLS configuration for encrypted transports
+func (c *DNSCollector) tlsConfig(serverName string) *tls.Config {
+	return &tls.Config{
+		ServerName: serverName,
+		RootCAs:    c.rootCAs, // nil uses the system pool
+	}
 }
 
-func parseResponse(r *dns.Msg, latency time.Duration, server string, proto DNSProtocol, cert *CertInfo) DNSLookupResult {
+// parseResponse flattens r for display. wire is the response as received,
+// it gives the size and shows whether the server compressed names.
+func parseResponse(r *dns.Msg, wire []byte, latency time.Duration, server string, proto DNSProtocol, cert *CertInfo) DNSLookupResult {
 	res := DNSLookupResult{
-		Latency:      latency,
-		Server:       server,
-		Protocol:     proto,
-		CertInfo:     cert,
-		ResponseCode: dns.RcodeToString[r.Rcode],
+		ResponseSize:  len(wire),
+		Compressed:    compressed(r, len(wire)),
+		Latency:       latency,
+		Server:        server,
+		Protocol:      proto,
+		CertInfo:      cert,
+		ResponseCode:  dns.RcodeToString[r.Rcode],
+		Cookie:        extractCookie(r),
+		ExtendedError: extendedError(r),
+		Authenticated: r.AuthenticatedData,
+		SRV:           parseSRV(r.Answer),
+		Flags:         headerFlags(r.MsgHdr),
+		msg:           r,
+	}
+	if chain, final := cnameChain(r); chain != nil {
+		res.CNAMEChain, res.ChainAnswers = chain, final
 	}
 
+	for _, q := range r.Question {
+		res.Question = append(res.Question, strings.ReplaceAll(strings.TrimPrefix(q.String(), ";"), "\t", " "))
+	}
 	for _, ans := range r.Answer {
 		// Format the answer nicely
 		// ans.String() returns the full record string (e.g., "google.com. 300 IN A 1.2.3.4")
 		// We might want to clean it up or just use it as is.
 		res.Records = append(res.Records, strings.ReplaceAll(ans.String(), "\t", " "))
 	}
+	for _, rr := range r.Ns {
+		res.Authority = append(res.Authority, strings.ReplaceAll(rr.String(), "\t", " "))
+	}
+	for _, rr := range r.Extra {
+		if rr.Header().Rrtype == dns.TypeOPT {
+			continue
+		}
+		res.Additional = append(res.Additional, strings.ReplaceAll(rr.String(), "\t", " "))
+	}
 
 	return res
 }
 
+// compressed reports whether a response of size bytes is smaller than r
+// packed without name compression
+func compressed(r *dns.Msg, size int) bool {
+	plain := r.Copy()
+	plain.Compress = false
+	return size > 0 && size < plain.Len()
+}
+
+// headerFlags lists the set header flags in dig's order
+func headerFlags(h dns.MsgHdr) []string {
+	var flags []string
+	for _, f := range []struct {
+		name string
+		set  bool
+	}{
+		{"qr", h.Response},
+		{"aa", h.Authoritative},
+		{"tc", h.Truncated},
+		{"rd", h.RecursionDesired},
+		{"ra", h.RecursionAvailable},
+		{"ad", h.AuthenticatedData},
+		{"cd", h.CheckingDisabled},
+	} {
+		if f.set {
+			flags = append(flags, f.name)
+		}
+	}
+	return flags
+}
+
 func getCertInfo(state tls.ConnectionState) *CertInfo {
 	if len(state.PeerCertificates) == 0 {
 		return nil
 	}
 	cert := state.PeerCertificates[0]
-	return &CertInfo{
+	info := &CertInfo{
 		Subject:     cert.Subject.String(),
 		Issuer:      cert.Issuer.String(),
 		NotBefore:   cert.NotBefore,
@@ -313,7 +919,35 @@ func getCertInfo(state tls.ConnectionState) *CertInfo {
 		CipherSuite: state.CipherSuite,
 		Version:     state.Version,
 		DNSNames:    cert.DNSNames,
+		OCSPStatus:  OCSPNone,
+	}
+	if len(state.OCSPResponse) == 0 {
+		return info
+	}
+
+	// The issuer verifies the staple's signature; without a chain only the
+	// status is read
+	var issuer *x509.Certificate
+	if len(state.VerifiedChains) > 0 && len(state.VerifiedChains[0]) > 1 {
+		issuer = state.VerifiedChains[0][1]
+	} else if len(state.PeerCertificates) > 1 {
+		issuer = state.PeerCertificates[1]
+	}
+	resp, err := ocsp.ParseResponseForCert(state.OCSPResponse, cert, issuer)
+	if err != nil {
+		info.OCSPStatus, info.OCSPError = OCSPUnknown, err
+		return info
+	}
+	switch resp.Status {
+	case ocsp.Good:
+		info.OCSPStatus = OCSPGood
+	case ocsp.Revoked:
+		info.OCSPStatus, info.OCSPRevokedAt = OCSPRevoked, resp.RevokedAt
+	default:
+		info.OCSPStatus = OCSPUnknown
 	}
+	info.OCSPNextUpdate = resp.NextUpdate
+	return info
 }
 
 func isIP(s string) bool {
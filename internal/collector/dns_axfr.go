@@ -0,0 +1,185 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// maxZoneTransferRecords bounds how much of an open zone is read, the count
+// is all the check needs
+const maxZoneTransferRecords = 50000
+
+// ZoneTransferStatus is the outcome of an AXFR attempt
+type ZoneTransferStatus string
+
+const (
+	ZoneTransferAllowed ZoneTransferStatus = "allowed" // The server sent the zone
+	ZoneTransferRefused ZoneTransferStatus = "refused" // The server answered but declined
+	ZoneTransferFailed  ZoneTransferStatus = "failed"  // No answer, says nothing about the policy
+)
+
+// ZoneTransfer is the AXFR attempt against one nameserver. The records are
+// counted, never kept.
+type ZoneTransfer struct {
+	Nameserver string
+	Address    string
+	Status     ZoneTransferStatus
+	Records    int    // Records received, the closing SOA excluded
+	Truncated  bool   // Stopped at maxZoneTransferRecords
+	Detail     string // Why the transfer was refused, e.g. REFUSED or NOTAUTH
+	Error      error
+}
+
+// ZoneTransferResult is the AXFR check of every nameserver of a zone
+type ZoneTransferResult struct {
+	Zone        string
+	Nameservers []ZoneTransfer // Sorted by nameserver name
+	Error       error          // The NS lookup failed
+}
+
+// Exposed reports whether any nameserver hands the zone out
+func (r ZoneTransferResult) Exposed() bool {
+	for _, ns := range r.Nameservers {
+		if ns.Status == ZoneTransferAllowed {
+			return true
+		}
+	}
+	return false
+}
+
+// ZoneTransferCheck looks up the nameservers of zone through server and
+// attempts an AXFR against each of them over TCP. IXFR is not tried: it
+// needs a serial to send changes from, and a server that refuses AXFR but
+// answers IXFR is not detected.
+func (c *DNSCollector) ZoneTransferCheck(ctx context.Context, zone string, server DNSServer) ZoneTransferResult {
+	res := ZoneTransferResult{Zone: strings.TrimSuffix(zone, ".")}
+	lookup := c.Lookup(ctx, zone, RecordNS, server)
+	if lookup.Error != nil {
+		res.Error = lookup.Error
+		return res
+	}
+	var names []string
+	if lookup.msg != nil {
+		for _, rr := range lookup.msg.Answer {
+			if ns, ok := rr.(*dns.NS); ok {
+				names = append(names, strings.TrimSuffix(ns.Ns, "."))
+			}
+		}
+	}
+	if len(names) == 0 {
+		res.Error = fmt.Errorf("no NS records for %s", res.Zone)
+		return res
+	}
+	sort.Strings(names)
+
+	res.Nameservers = make([]ZoneTransfer, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res.Nameservers[i] = c.transferZone(ctx, zone, net.JoinHostPort(name, "53"), maxZoneTransferRecords)
+			res.Nameservers[i].Nameserver = name
+		}()
+	}
+	wg.Wait()
+	return res
+}
+
+// transferZone requests zone from addr and counts the records it sends,
+// giving up after limit records
+func (c *DNSCollector) transferZone(ctx context.Context, zone, addr string, limit int) ZoneTransfer {
+	zt := ZoneTransfer{Address: addr, Status: ZoneTransferFailed}
+	if !c.Budget.Allow(1, axfrProbeBytes) {
+		zt.Error = ErrBudgetExceeded
+		return zt
+	}
+	conn, err := c.dial(ctx, "tcp", addr, 0)
+	if err != nil {
+		zt.Error = err
+		return zt
+	}
+	zt.Address = conn.RemoteAddr().String()
+	// Closing the connection is the only way to stop a running transfer
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	// The replies are read here rather than through Transfer.In, which
+	// reports a refusal as an error string without its rcode
+	t := &dns.Transfer{Conn: &dns.Conn{Conn: conn}}
+	defer conn.Close()
+	q := new(dns.Msg)
+	q.SetAxfr(dns.Fqdn(zone))
+	conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	if err := t.WriteMsg(q); err != nil {
+		zt.Error = err
+		return zt
+	}
+
+	for first := true; ; first = false {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		in, err := t.ReadMsg()
+		switch {
+		case err != nil:
+			classifyTransferError(&zt, err)
+			return zt
+		case in.Id != q.Id:
+			classifyTransferError(&zt, dns.ErrId)
+			return zt
+		case first && in.Rcode != dns.RcodeSuccess:
+			classifyTransferRcode(&zt, in.Rcode)
+			return zt
+		case first && (len(in.Answer) == 0 || in.Answer[0].Header().Rrtype != dns.TypeSOA):
+			classifyTransferError(&zt, dns.ErrSoa)
+			return zt
+		}
+		zt.Status = ZoneTransferAllowed
+		zt.Records += len(in.Answer)
+		if zt.Records >= limit {
+			zt.Records, zt.Truncated = limit, true
+			return zt
+		}
+		// The zone ends with its SOA again, the opening one may come alone
+		last := len(in.Answer) - 1
+		if last >= 0 && (!first || last > 0) && in.Answer[last].Header().Rrtype == dns.TypeSOA {
+			zt.Records-- // The closing SOA
+			return zt
+		}
+	}
+}
+
+// classifyTransferRcode sorts the answer code of a declined transfer: a
+// policy refusal, or a server that cannot serve the zone at all
+func classifyTransferRcode(zt *ZoneTransfer, rcode int) {
+	zt.Detail = dns.RcodeToString[rcode]
+	switch rcode {
+	case dns.RcodeRefused, dns.RcodeNotAuth, dns.RcodeNotImplemented:
+		zt.Status = ZoneTransferRefused
+	default: // e.g. SERVFAIL, says nothing about the policy
+		zt.Status = ZoneTransferFailed
+		zt.Error = fmt.Errorf("server answered %s", zt.Detail)
+	}
+}
+
+// classifyTransferError sorts a transfer error into a server that hung up
+// on the request, a refusal, or a failure to talk to it
+func classifyTransferError(zt *ZoneTransfer, err error) {
+	zt.Error = err
+	if zt.Status == ZoneTransferAllowed {
+		return // Broke off mid-zone, the server still allowed it
+	}
+	if errors.Is(err, io.EOF) {
+		zt.Status = ZoneTransferRefused
+		zt.Detail = "connection closed without an answer"
+		zt.Error = nil
+	}
+}
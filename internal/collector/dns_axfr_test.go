@@ -0,0 +1,91 @@
+package collector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// axfrHandler serves example.com. by AXFR when allow is set and answers
+// rcode otherwise
+func axfrHandler(t *testing.T, allow bool, rcode int) dns.HandlerFunc {
+	soa := mustRR(t, "example.com. 3600 IN SOA ns1.example.com. admin.example.com. 1 7200 3600 1209600 300")
+	records := []dns.RR{
+		soa,
+		mustRR(t, "example.com. 3600 IN NS ns1.example.com."),
+		mustRR(t, "www.example.com. 300 IN A 192.0.2.1"),
+		mustRR(t, "mail.example.com. 300 IN A 192.0.2.2"),
+		soa,
+	}
+	return func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		if !allow || r.Question[0].Qtype != dns.TypeAXFR {
+			m.SetRcode(r, rcode)
+			w.WriteMsg(m)
+			return
+		}
+		// Two envelopes, like a server splitting a larger zone
+		m.SetReply(r)
+		m.Answer = records[:2]
+		w.WriteMsg(m)
+		m = new(dns.Msg)
+		m.SetReply(r)
+		m.Answer = records[2:]
+		w.WriteMsg(m)
+	}
+}
+
+func TestTransferZone_Classification(t *testing.T) {
+	tests := []struct {
+		name      string
+		allow     bool
+		limit     int
+		rcode     int
+		status    ZoneTransferStatus
+		records   int
+		truncated bool
+		detail    string
+	}{
+		{name: "allowed", allow: true, limit: maxZoneTransferRecords, status: ZoneTransferAllowed, records: 4},
+		{name: "bounded", allow: true, limit: 2, status: ZoneTransferAllowed, records: 2, truncated: true},
+		{name: "refused", rcode: dns.RcodeRefused, limit: maxZoneTransferRecords, status: ZoneTransferRefused, detail: "REFUSED"},
+		{name: "not authoritative", rcode: dns.RcodeNotAuth, limit: maxZoneTransferRecords, status: ZoneTransferRefused, detail: "NOTAUTH"},
+		{name: "server failure", rcode: dns.RcodeServerFailure, limit: maxZoneTransferRecords, status: ZoneTransferFailed, detail: "SERVFAIL"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := startMockDNS(t, axfrHandler(t, tt.allow, tt.rcode))
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			zt := NewDNSCollector().transferZone(ctx, "example.com", addr, tt.limit)
+			if (zt.Error != nil) != (tt.status == ZoneTransferFailed) {
+				t.Fatalf("error = %v, want one only for a failed transfer", zt.Error)
+			}
+			if zt.Status != tt.status || zt.Records != tt.records || zt.Truncated != tt.truncated || zt.Detail != tt.detail {
+				t.Errorf("got status %q, %d records, truncated %v, detail %q; want %q, %d, %v, %q",
+					zt.Status, zt.Records, zt.Truncated, zt.Detail, tt.status, tt.records, tt.truncated, tt.detail)
+			}
+		})
+	}
+}
+
+func TestTransferZone_Unreachable(t *testing.T) {
+	zt := NewDNSCollector().transferZone(context.Background(), "example.com", "127.0.0.1:1", maxZoneTransferRecords)
+	if zt.Status != ZoneTransferFailed || zt.Error == nil {
+		t.Errorf("got status %q, error %v; want failed with an error", zt.Status, zt.Error)
+	}
+}
+
+func TestZoneTransferResult_Exposed(t *testing.T) {
+	res := ZoneTransferResult{Nameservers: []ZoneTransfer{{Status: ZoneTransferRefused}, {Status: ZoneTransferFailed}}}
+	if res.Exposed() {
+		t.Error("refused and failed transfers reported as exposed")
+	}
+	res.Nameservers = append(res.Nameservers, ZoneTransfer{Status: ZoneTransferAllowed})
+	if !res.Exposed() {
+		t.Error("allowed transfer not reported as exposed")
+	}
+}
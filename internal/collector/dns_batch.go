@@ -0,0 +1,211 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// maxDNSBatchQueries caps the cross-product of a batch template
+const maxDNSBatchQueries = 200
+
+// dnsBatchConcurrency bounds the queries in flight against one resolver
+const dnsBatchConcurrency = 8
+
+// batchRecordTypes are the types a template may name, matched case-insensitively
+var batchRecordTypes = []DNSRecordType{
+	RecordA, RecordAAAA, RecordCNAME, RecordMX, RecordTXT, RecordNS, RecordPTR, RecordSRV, RecordCAA,
+}
+
+// DNSBatchQuery is one name and type of an expanded template
+type DNSBatchQuery struct {
+	Domain string
+	Type   DNSRecordType
+}
+
+// DNSBatchEntry is the outcome of one batch query
+type DNSBatchEntry struct {
+	Query  DNSBatchQuery
+	Result DNSLookupResult
+}
+
+// Answers returns the record data without names and TTLs, sorted, for
+// comparing entries at a glance
+func (e DNSBatchEntry) Answers() []string {
+	return normalizeAnswers(e.Result.Records)
+}
+
+// DNSBatchResult is a whole batch against one resolver
+type DNSBatchResult struct {
+	Template string
+	Server   string
+	Entries  []DNSBatchEntry // In template order: each name with each type
+	Error    error           // The template could not be expanded or the batch exceeds the budget
+}
+
+// Failed counts the queries without an answer, errors and non-NOERROR codes alike
+func (r DNSBatchResult) Failed() int {
+	n := 0
+	for _, e := range r.Entries {
+		if e.Result.Error != nil || (e.Result.ResponseCode != "" && e.Result.ResponseCode != "NOERROR") {
+			n++
+		}
+	}
+	return n
+}
+
+// ExpandDNSTemplate turns a template like "{a,aaaa,mx} google.com,cloudflare.com"
+// into the cross-product of its names and types. The optional first word
+// lists record types, bare or in braces, and defaults to A. The rest are
+// comma separated names, which may use shell style alternation such as
+// "{www,mail}.example.com".
+func ExpandDNSTemplate(tmpl string) ([]DNSBatchQuery, error) {
+	words := strings.Fields(tmpl)
+	if len(words) == 0 {
+		return nil, fmt.Errorf("empty template")
+	}
+
+	types := []DNSRecordType{RecordA}
+	if t, ok := parseBatchTypes(words[0]); ok {
+		types = t
+		words = words[1:]
+	}
+
+	var names []string
+	for _, word := range words {
+		for _, part := range splitTopLevel(word) {
+			expanded, err := expandBraces(part)
+			if err != nil {
+				return nil, err
+			}
+			for _, name := range expanded {
+				if name != "" {
+					names = append(names, name)
+				}
+			}
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("template %q names no domains", tmpl)
+	}
+	if n := len(names) * len(types); n > maxDNSBatchQueries {
+		return nil, fmt.Errorf("template expands to %d queries, at most %d allowed", n, maxDNSBatchQueries)
+	}
+
+	queries := make([]DNSBatchQuery, 0, len(names)*len(types))
+	for _, name := range names {
+		for _, t := range types {
+			queries = append(queries, DNSBatchQuery{Domain: name, Type: t})
+		}
+	}
+	return queries, nil
+}
+
+// parseBatchTypes reads a word such as "{a,aaaa}" or "mx" as record types
+func parseBatchTypes(word string) ([]DNSRecordType, bool) {
+	word = strings.TrimSuffix(strings.TrimPrefix(word, "{"), "}")
+	var types []DNSRecordType
+	for _, s := range strings.Split(word, ",") {
+		found := false
+		for _, t := range batchRecordTypes {
+			if strings.EqualFold(s, string(t)) {
+				types = append(types, t)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, false
+		}
+	}
+	return types, true
+}
+
+// splitTopLevel splits on commas outside braces
+func splitTopLevel(s string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, r := range s {
+		switch r {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// expandBraces expands each {x,y} group of s, left to right
+func expandBraces(s string) ([]string, error) {
+	open := strings.IndexByte(s, '{')
+	if open < 0 {
+		if strings.ContainsRune(s, '}') {
+			return nil, fmt.Errorf("unbalanced brace in %q", s)
+		}
+		return []string{s}, nil
+	}
+	end := strings.IndexByte(s[open:], '}')
+	if end < 0 {
+		return nil, fmt.Errorf("unbalanced brace in %q", s)
+	}
+	end += open
+	if strings.ContainsRune(s[open+1:end], '{') {
+		return nil, fmt.Errorf("nested braces in %q", s)
+	}
+
+	rest, err := expandBraces(s[end+1:])
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, alt := range strings.Split(s[open+1:end], ",") {
+		for _, tail := range rest {
+			out = append(out, s[:open]+alt+tail)
+		}
+	}
+	return out, nil
+}
+
+// Batch expands tmpl and runs every query against server, a few at a time.
+// All queries share ctx's deadline, so a slow resolver cannot stretch the
+// batch beyond it; queries not sent in time report the context error.
+func (c *DNSCollector) Batch(ctx context.Context, tmpl string, server DNSServer) DNSBatchResult {
+	res := DNSBatchResult{Template: tmpl, Server: server.Name}
+	queries, err := ExpandDNSTemplate(tmpl)
+	if err != nil {
+		res.Error = err
+		return res
+	}
+	n := len(queries)
+	if err := c.Budget.Fits(n, int64(n)*dnsProbeBytes); err != nil {
+		res.Error = fmt.Errorf("%d queries: %w", n, err)
+		return res
+	}
+
+	res.Entries = make([]DNSBatchEntry, len(queries))
+	sem := make(chan struct{}, dnsBatchConcurrency)
+	var wg sync.WaitGroup
+	for i, q := range queries {
+		res.Entries[i].Query = q
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			res.Entries[i].Result = DNSLookupResult{Error: ctx.Err()}
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			res.Entries[i].Result = c.Lookup(ctx, q.Domain, q.Type, server)
+		}()
+	}
+	wg.Wait()
+	return res
+}
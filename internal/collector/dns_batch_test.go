@@ -0,0 +1,92 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestExpandDNSTemplate(t *testing.T) {
+	got, err := ExpandDNSTemplate("{a,aaaa,mx} google.com,{www,mail}.cloudflare.com")
+	if err != nil {
+		t.Fatalf("ExpandDNSTemplate() error = %v", err)
+	}
+	var want []DNSBatchQuery
+	for _, name := range []string{"google.com", "www.cloudflare.com", "mail.cloudflare.com"} {
+		for _, typ := range []DNSRecordType{RecordA, RecordAAAA, RecordMX} {
+			want = append(want, DNSBatchQuery{Domain: name, Type: typ})
+		}
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("ExpandDNSTemplate() = %v, want %v", got, want)
+	}
+
+	// Without a type word every name is looked up as A
+	got, err = ExpandDNSTemplate("example.com example.org")
+	if err != nil || !slices.Equal(got, []DNSBatchQuery{{"example.com", RecordA}, {"example.org", RecordA}}) {
+		t.Errorf("default type: %v, %v", got, err)
+	}
+
+	for _, bad := range []string{"", "{a,mx}", "{www.example.com", "{a{b,c}}.com"} {
+		if _, err := ExpandDNSTemplate(bad); err == nil {
+			t.Errorf("ExpandDNSTemplate(%q) should fail", bad)
+		}
+	}
+}
+
+func TestDNSCollector_Batch(t *testing.T) {
+	addr := startMockDNS(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		resp := new(dns.Msg)
+		resp.SetReply(r)
+		q := r.Question[0]
+		switch {
+		case q.Name == "missing.test.":
+			resp.Rcode = dns.RcodeNameError
+		case q.Qtype == dns.TypeA:
+			resp.Answer = append(resp.Answer, mustRR(t, q.Name+" 60 IN A 192.0.2.1"))
+		case q.Qtype == dns.TypeMX:
+			resp.Answer = append(resp.Answer, mustRR(t, q.Name+" 60 IN MX 10 mx."+q.Name))
+		}
+		w.WriteMsg(resp)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	res := NewDNSCollector().Batch(ctx, "{a,mx} one.test,two.test,missing.test", DNSServer{Name: "Mock", Address: addr, Proto: ProtoUDP})
+	if res.Error != nil {
+		t.Fatalf("Batch() error = %v", res.Error)
+	}
+	if len(res.Entries) != 6 {
+		t.Fatalf("got %d entries, want 6", len(res.Entries))
+	}
+	for _, e := range res.Entries {
+		if e.Query.Domain == "missing.test" {
+			if e.Result.ResponseCode != "NXDOMAIN" {
+				t.Errorf("%v: rcode %q, want NXDOMAIN", e.Query, e.Result.ResponseCode)
+			}
+			continue
+		}
+		if e.Result.Error != nil || len(e.Result.Records) != 1 {
+			t.Errorf("%v: records %v, err %v", e.Query, e.Result.Records, e.Result.Error)
+		}
+	}
+	if res.Failed() != 2 {
+		t.Errorf("Failed() = %d, want 2", res.Failed())
+	}
+}
+
+func TestDNSCollector_BatchOverBudget(t *testing.T) {
+	c := NewDNSCollector()
+	c.Budget, _ = fixedBudget(5, 0)
+	res := c.Batch(context.Background(), "{a,mx} one.test,two.test,three.test", DNSServer{Name: "Unused", Address: "192.0.2.53:53", Proto: ProtoUDP})
+	if !errors.Is(res.Error, ErrBudgetExceeded) || len(res.Entries) != 0 {
+		t.Fatalf("Batch() = %d entries, error %v, want none and ErrBudgetExceeded", len(res.Entries), res.Error)
+	}
+	if probes, _ := c.Budget.Remaining(); probes != 5 {
+		t.Errorf("a refused batch should not consume budget, %d left", probes)
+	}
+}
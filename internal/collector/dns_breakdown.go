@@ -0,0 +1,148 @@
+package collector
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// SlowDNSThreshold is the query time above which a resolver is considered slow
+const SlowDNSThreshold = 100 * time.Millisecond
+
+const (
+	resolvConfPath       = "/etc/resolv.conf"
+	resolvedUpstreamPath = "/run/systemd/resolve/resolv.conf" // systemd-resolved's real upstreams
+)
+
+// DNSTiming splits the time spent on a single resolver
+type DNSTiming struct {
+	Server  string
+	Connect time.Duration // TCP connect to the resolver
+	Query   time.Duration // Query round-trip over the established connection
+	Error   error
+}
+
+// Total is the connect time plus the query round-trip
+func (t DNSTiming) Total() time.Duration {
+	return t.Connect + t.Query
+}
+
+// DNSBreakdown explains where DNS resolution time goes
+type DNSBreakdown struct {
+	Stub      *DNSTiming // Local stub resolver (e.g. 127.0.0.53), nil if resolv.conf points at upstreams
+	Upstreams []DNSTiming
+	Verdict   string
+}
+
+// dnsTimingFunc measures one resolver; replaced in tests
+type dnsTimingFunc func(ctx context.Context, domain, server string) DNSTiming
+
+// Breakdown measures the stub resolver and its upstreams separately
+func (c *DNSCollector) Breakdown(ctx context.Context, domain string) DNSBreakdown {
+	stub, upstreams := discoverResolvers(resolvConfPath, resolvedUpstreamPath)
+	measure := func(ctx context.Context, domain, server string) DNSTiming {
+		if !c.Budget.Allow(1, tcpProbeBytes+dnsProbeBytes) {
+			return DNSTiming{Server: server, Error: ErrBudgetExceeded}
+		}
+		return measureDNSTiming(ctx, domain, server)
+	}
+	return dnsBreakdown(ctx, domain, stub, upstreams, measure)
+}
+
+// discoverResolvers returns the local stub (if resolv.conf points at loopback)
+// and the upstream resolvers it forwards to
+func discoverResolvers(resolvConf, upstreamConf string) (stub string, upstreams []string) {
+	config, err := dns.ClientConfigFromFile(resolvConf)
+	if err != nil || len(config.Servers) == 0 {
+		return "", nil
+	}
+
+	first := config.Servers[0]
+	if ip := net.ParseIP(first); ip == nil || !ip.IsLoopback() {
+		// No stub, resolv.conf lists the upstreams directly
+		for _, s := range config.Servers {
+			upstreams = append(upstreams, net.JoinHostPort(s, config.Port))
+		}
+		return "", upstreams
+	}
+
+	stub = net.JoinHostPort(first, config.Port)
+	if upstream, err := dns.ClientConfigFromFile(upstreamConf); err == nil {
+		for _, s := range upstream.Servers {
+			upstreams = append(upstreams, net.JoinHostPort(s, upstream.Port))
+		}
+	}
+	return stub, upstreams
+}
+
+func dnsBreakdown(ctx context.Context, domain, stub string, upstreams []string, measure dnsTimingFunc) DNSBreakdown {
+	var b DNSBreakdown
+	if stub != "" {
+		t := measure(ctx, domain, stub)
+		b.Stub = &t
+	}
+	for _, u := range upstreams {
+		b.Upstreams = append(b.Upstreams, measure(ctx, domain, u))
+	}
+	b.Verdict = dnsVerdict(b)
+	return b
+}
+
+// dnsVerdict points at the part of the resolution path that is slow
+func dnsVerdict(b DNSBreakdown) string {
+	var fastest *DNSTiming
+	for i := range b.Upstreams {
+		u := &b.Upstreams[i]
+		if u.Error == nil && (fastest == nil || u.Total() < fastest.Total()) {
+			fastest = u
+		}
+	}
+
+	if b.Stub != nil && b.Stub.Error != nil {
+		return "Local stub resolver is not answering"
+	}
+	if fastest == nil {
+		if len(b.Upstreams) == 0 {
+			return "No upstream resolvers discovered"
+		}
+		return "No upstream resolver is answering"
+	}
+	if fastest.Connect > SlowDNSThreshold {
+		return "Connecting to the upstream resolver is slow (network path)"
+	}
+	if fastest.Query > SlowDNSThreshold {
+		return "Upstream resolver is slow to answer"
+	}
+	if b.Stub != nil && b.Stub.Total() > SlowDNSThreshold {
+		return "Local stub resolver is slow, upstreams are fast"
+	}
+	return "DNS resolution looks healthy"
+}
+
+// measureDNSTiming connects to the resolver over TCP and times one query
+func measureDNSTiming(ctx context.Context, domain, server string) DNSTiming {
+	res := DNSTiming{Server: server}
+
+	msg, err := buildQuery(domain, RecordA, DNSQueryOptions{})
+	if err != nil {
+		res.Error = err
+		return res
+	}
+
+	client := &dns.Client{Net: "tcp", Timeout: 2 * time.Second}
+	start := time.Now()
+	conn, err := client.DialContext(ctx, server)
+	res.Connect = time.Since(start)
+	if err != nil {
+		res.Error = err
+		return res
+	}
+	defer conn.Close()
+
+	_, rtt, err := client.ExchangeWithConnContext(ctx, msg, conn)
+	res.Query = rtt
+	res.Error = err
+	return res
+}
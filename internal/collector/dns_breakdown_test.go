@@ -0,0 +1,84 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDNSBreakdown_MockedTimings(t *testing.T) {
+	timings := map[string]DNSTiming{
+		"127.0.0.53:53": {Connect: time.Millisecond, Query: 230 * time.Millisecond},
+		"192.0.2.1:53":  {Connect: 5 * time.Millisecond, Query: 12 * time.Millisecond},
+		"192.0.2.2:53":  {Error: fmt.Errorf("timeout")},
+	}
+	measure := func(ctx context.Context, domain, server string) DNSTiming {
+		tm := timings[server]
+		tm.Server = server
+		return tm
+	}
+
+	b := dnsBreakdown(context.Background(), "example.com", "127.0.0.53:53", []string{"192.0.2.1:53", "192.0.2.2:53"}, measure)
+
+	if b.Stub == nil {
+		t.Fatal("expected stub timing")
+	}
+	if b.Stub.Server != "127.0.0.53:53" || b.Stub.Query != 230*time.Millisecond || b.Stub.Connect != time.Millisecond {
+		t.Errorf("unexpected stub timing: %+v", *b.Stub)
+	}
+	if len(b.Upstreams) != 2 {
+		t.Fatalf("expected 2 upstreams, got %d", len(b.Upstreams))
+	}
+	if b.Upstreams[0].Total() != 17*time.Millisecond {
+		t.Errorf("upstream total = %s, want 17ms", b.Upstreams[0].Total())
+	}
+	if b.Upstreams[1].Error == nil {
+		t.Error("expected error for the second upstream")
+	}
+	if b.Verdict != "Local stub resolver is slow, upstreams are fast" {
+		t.Errorf("verdict = %q", b.Verdict)
+	}
+}
+
+func TestDNSVerdict(t *testing.T) {
+	tests := []struct {
+		name string
+		b    DNSBreakdown
+		want string
+	}{
+		{"slow upstream query", DNSBreakdown{Upstreams: []DNSTiming{{Connect: time.Millisecond, Query: 300 * time.Millisecond}}}, "Upstream resolver is slow to answer"},
+		{"slow connect", DNSBreakdown{Upstreams: []DNSTiming{{Connect: 300 * time.Millisecond, Query: time.Millisecond}}}, "Connecting to the upstream resolver is slow (network path)"},
+		{"no upstreams", DNSBreakdown{}, "No upstream resolvers discovered"},
+		{"healthy", DNSBreakdown{Upstreams: []DNSTiming{{Connect: time.Millisecond, Query: time.Millisecond}}}, "DNS resolution looks healthy"},
+	}
+	for _, tt := range tests {
+		if got := dnsVerdict(tt.b); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDiscoverResolvers(t *testing.T) {
+	dir := t.TempDir()
+	stubConf := filepath.Join(dir, "resolv.conf")
+	upstreamConf := filepath.Join(dir, "upstream.conf")
+	os.WriteFile(stubConf, []byte("nameserver 127.0.0.53\noptions edns0\n"), 0o644)
+	os.WriteFile(upstreamConf, []byte("nameserver 192.0.2.1\nnameserver 192.0.2.2\n"), 0o644)
+
+	stub, upstreams := discoverResolvers(stubConf, upstreamConf)
+	if stub != "127.0.0.53:53" {
+		t.Errorf("stub = %q", stub)
+	}
+	if len(upstreams) != 2 || upstreams[0] != "192.0.2.1:53" {
+		t.Errorf("upstreams = %v", upstreams)
+	}
+
+	// Without a stub, resolv.conf servers are the upstreams
+	stub, upstreams = discoverResolvers(upstreamConf, filepath.Join(dir, "missing"))
+	if stub != "" || len(upstreams) != 2 {
+		t.Errorf("expected no stub and 2 upstreams, got %q %v", stub, upstreams)
+	}
+}
@@ -0,0 +1,58 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// cacheProbeDelay is the wait between the two queries of a cache probe,
+// long enough for a cached TTL to count down
+var cacheProbeDelay = time.Second
+
+// DNSCacheResult is a cache probe as shown and reported: whether the
+// resolver answered the repeated query from its cache, told by the answer
+// TTL counting down
+type DNSCacheResult struct {
+	Domain     string
+	Server     string
+	Cached     bool
+	TTL1, TTL2 uint32
+	Error      error
+}
+
+// CacheProbe asks server for the same name twice, cacheProbeDelay apart, and
+// compares the TTLs of the answers. A resolver answering from its cache
+// hands out a TTL that counts down, one that fetches afresh each time (or an
+// authoritative server) repeats the full TTL.
+func (c *DNSCollector) CacheProbe(ctx context.Context, domain string, recordType DNSRecordType, server DNSServer) (cached bool, ttl1, ttl2 uint32, err error) {
+	if ttl1, err = c.answerTTL(ctx, domain, recordType, server); err != nil {
+		return false, 0, 0, err
+	}
+	select {
+	case <-ctx.Done():
+		return false, ttl1, 0, ctx.Err()
+	case <-time.After(cacheProbeDelay):
+	}
+	if ttl2, err = c.answerTTL(ctx, domain, recordType, server); err != nil {
+		return false, ttl1, 0, err
+	}
+	return ttl2 < ttl1, ttl1, ttl2, nil
+}
+
+// answerTTL looks up domain and returns the lowest TTL in the answer, the
+// one a cache expires the answer by
+func (c *DNSCollector) answerTTL(ctx context.Context, domain string, recordType DNSRecordType, server DNSServer) (uint32, error) {
+	res := c.Lookup(ctx, domain, recordType, server)
+	if res.Error != nil {
+		return 0, res.Error
+	}
+	if res.msg == nil || len(res.msg.Answer) == 0 {
+		return 0, fmt.Errorf("no %s records to compare TTLs of (%s)", recordType, res.ResponseCode)
+	}
+	ttl := res.msg.Answer[0].Header().Ttl
+	for _, rr := range res.msg.Answer[1:] {
+		ttl = min(ttl, rr.Header().Ttl)
+	}
+	return ttl, nil
+}
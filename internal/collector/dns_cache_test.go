@@ -0,0 +1,59 @@
+package collector
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestDNSCacheProbe(t *testing.T) {
+	defer func(d time.Duration) { cacheProbeDelay = d }(cacheProbeDelay)
+	cacheProbeDelay = 10 * time.Millisecond
+
+	// A caching resolver counts the TTL down between queries, an uncached
+	// one repeats it; the lowest TTL of the answer is the one compared
+	serve := func(ttls ...string) string {
+		var n atomic.Int32
+		return startMockDNS(t, func(w dns.ResponseWriter, r *dns.Msg) {
+			resp := new(dns.Msg)
+			resp.SetReply(r)
+			if i := int(n.Add(1)) - 1; i < len(ttls) && ttls[i] != "" {
+				rr, _ := dns.NewRR("example.com. 3600 IN CNAME cdn.example.net.")
+				resp.Answer = append(resp.Answer, rr)
+				rr, _ = dns.NewRR("cdn.example.net. " + ttls[i] + " IN A 192.0.2.1")
+				resp.Answer = append(resp.Answer, rr)
+			}
+			w.WriteMsg(resp)
+		})
+	}
+
+	tests := []struct {
+		name       string
+		ttls       []string
+		cached     bool
+		ttl1, ttl2 uint32
+		wantErr    bool
+	}{
+		{"cached", []string{"300", "299"}, true, 300, 299, false},
+		{"uncached", []string{"300", "300"}, false, 300, 300, false},
+		{"no records", []string{""}, false, 0, 0, true},
+	}
+	c := NewDNSCollector()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			server := DNSServer{Name: "Mock", Address: serve(tt.ttls...), Proto: ProtoUDP}
+			cached, ttl1, ttl2, err := c.CacheProbe(ctx, "example.com", RecordA, server)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if cached != tt.cached || ttl1 != tt.ttl1 || ttl2 != tt.ttl2 {
+				t.Errorf("CacheProbe = %v, %d, %d, want %v, %d, %d", cached, ttl1, ttl2, tt.cached, tt.ttl1, tt.ttl2)
+			}
+		})
+	}
+}
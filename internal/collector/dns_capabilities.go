@@ -0,0 +1,222 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// Resolver capability names, in display order
+const (
+	CapEDNS       = "EDNS"
+	CapDNSSEC     = "DNSSEC validation"
+	CapCookies    = "DNS cookies"
+	CapQNameMin   = "QNAME minimisation"
+	CapTCP        = "TCP fallback"
+	CapVersionTXT = "version.bind"
+	CapNXDomain   = "NXDOMAIN intact"
+)
+
+// CapabilityState is the verdict of one capability probe
+type CapabilityState string
+
+const (
+	CapabilityYes     CapabilityState = "yes"
+	CapabilityNo      CapabilityState = "no"
+	CapabilityNA      CapabilityState = "n/a"     // Does not apply to the server's transport
+	CapabilityUnknown CapabilityState = "unknown" // Probe failed or the answer was inconclusive
+)
+
+// ResolverCapability is one line of the resolver fingerprint
+type ResolverCapability struct {
+	Name   string
+	State  CapabilityState
+	Detail string
+	Error  error
+}
+
+// ResolverCapabilities profiles one resolver
+type ResolverCapabilities struct {
+	Server       string
+	Protocol     DNSProtocol
+	Capabilities []ResolverCapability // Ordered as the Cap* constants
+}
+
+const (
+	// dnssecProbeDomain is a signed zone, validating resolvers set AD for it
+	dnssecProbeDomain = "example.com."
+	// qnameMinProbeDomain answers TXT "HOORAY" only if it never saw the full name
+	// at the intermediate delegation, see https://github.com/internetstiftelsen/qnamemintest
+	qnameMinProbeDomain = "a.b.qnamemintest.internet.nl."
+)
+
+// Capabilities runs every capability probe against server concurrently
+func (c *DNSCollector) Capabilities(ctx context.Context, server DNSServer) ResolverCapabilities {
+	probes := []func(context.Context, DNSServer) (ResolverCapability, DNSLookupResult){
+		c.probeEDNS,
+		c.probeDNSSEC,
+		c.probeCookies,
+		c.probeQNameMin,
+		c.probeTCP,
+		c.probeVersion,
+		c.probeNXDomain,
+	}
+
+	res := ResolverCapabilities{Protocol: server.Proto, Capabilities: make([]ResolverCapability, len(probes))}
+	lookups := make([]DNSLookupResult, len(probes))
+	var wg sync.WaitGroup
+	for i, probe := range probes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res.Capabilities[i], lookups[i] = probe(ctx, server)
+		}()
+	}
+	wg.Wait()
+
+	for _, l := range lookups {
+		if l.Server != "" {
+			res.Server = l.Server
+			break
+		}
+	}
+	if res.Protocol == "" {
+		res.Protocol = ProtoUDP
+	}
+	return res
+}
+
+// capabilityQuery builds a recursive query, with an OPT record when bufSize > 0
+func capabilityQuery(name string, qtype uint16, bufSize uint16, do bool) *dns.Msg {
+	msg := new(dns.Msg)
+	msg.SetQuestion(name, qtype)
+	msg.RecursionDesired = true
+	if bufSize > 0 {
+		msg.SetEdns0(bufSize, do)
+	}
+	return msg
+}
+
+// failed turns a transport error into an unknown verdict
+func failed(name string, l DNSLookupResult) (ResolverCapability, bool) {
+	if l.Error != nil {
+		return ResolverCapability{Name: name, State: CapabilityUnknown, Error: l.Error}, true
+	}
+	if l.msg == nil {
+		return ResolverCapability{Name: name, State: CapabilityUnknown, Error: fmt.Errorf("no response")}, true
+	}
+	return ResolverCapability{}, false
+}
+
+// probeEDNS checks that the server answers with an OPT record and reports its buffer size
+func (c *DNSCollector) probeEDNS(ctx context.Context, server DNSServer) (ResolverCapability, DNSLookupResult) {
+	l := c.exchange(ctx, capabilityQuery(dnssecProbeDomain, dns.TypeA, defaultEDNSBufSize, false), server)
+	if verdict, ok := failed(CapEDNS, l); ok {
+		return verdict, l
+	}
+	opt := l.msg.IsEdns0()
+	if opt == nil || l.msg.Rcode == dns.RcodeFormatError {
+		return ResolverCapability{Name: CapEDNS, State: CapabilityNo, Detail: "no OPT record in the response"}, l
+	}
+	return ResolverCapability{Name: CapEDNS, State: CapabilityYes,
+		Detail: fmt.Sprintf("version %d, UDP buffer %d bytes", opt.Version(), opt.UDPSize())}, l
+}
+
+// probeDNSSEC sets DO and looks for the AD bit on a signed name
+func (c *DNSCollector) probeDNSSEC(ctx context.Context, server DNSServer) (ResolverCapability, DNSLookupResult) {
+	l := c.exchange(ctx, capabilityQuery(dnssecProbeDomain, dns.TypeA, defaultEDNSBufSize, true), server)
+	if verdict, ok := failed(CapDNSSEC, l); ok {
+		return verdict, l
+	}
+	rrsig := false
+	for _, rr := range l.msg.Answer {
+		if rr.Header().Rrtype == dns.TypeRRSIG {
+			rrsig = true
+		}
+	}
+	switch {
+	case l.msg.AuthenticatedData:
+		return ResolverCapability{Name: CapDNSSEC, State: CapabilityYes, Detail: "AD set for " + strings.TrimSuffix(dnssecProbeDomain, ".")}, l
+	case rrsig:
+		return ResolverCapability{Name: CapDNSSEC, State: CapabilityNo, Detail: "returns signatures (DO) but does not validate"}, l
+	default:
+		return ResolverCapability{Name: CapDNSSEC, State: CapabilityNo, Detail: "no AD bit, no signatures"}, l
+	}
+}
+
+// probeCookies sends a client cookie and expects a server cookie back
+func (c *DNSCollector) probeCookies(ctx context.Context, server DNSServer) (ResolverCapability, DNSLookupResult) {
+	msg, err := buildQuery(strings.TrimSuffix(dnssecProbeDomain, "."), RecordA, DNSQueryOptions{Cookie: true})
+	if err != nil {
+		return ResolverCapability{Name: CapCookies, State: CapabilityUnknown, Error: err}, DNSLookupResult{}
+	}
+	l := c.exchange(ctx, msg, server)
+	if verdict, ok := failed(CapCookies, l); ok {
+		return verdict, l
+	}
+	if l.Cookie != nil && l.Cookie.Server != "" {
+		return ResolverCapability{Name: CapCookies, State: CapabilityYes, Detail: "server cookie " + l.Cookie.Server}, l
+	}
+	return ResolverCapability{Name: CapCookies, State: CapabilityNo, Detail: "no server cookie returned"}, l
+}
+
+// probeQNameMin queries the internet.nl test zone, whose TXT answer tells
+// whether the resolver minimised the name on its way down
+func (c *DNSCollector) probeQNameMin(ctx context.Context, server DNSServer) (ResolverCapability, DNSLookupResult) {
+	l := c.exchange(ctx, capabilityQuery(qnameMinProbeDomain, dns.TypeTXT, 0, false), server)
+	if verdict, ok := failed(CapQNameMin, l); ok {
+		return verdict, l
+	}
+	for _, rr := range l.msg.Answer {
+		txt, ok := rr.(*dns.TXT)
+		if !ok {
+			continue
+		}
+		text := strings.Join(txt.Txt, "")
+		switch {
+		case strings.HasPrefix(text, "HOORAY"):
+			return ResolverCapability{Name: CapQNameMin, State: CapabilityYes, Detail: "minimises query names"}, l
+		case strings.HasPrefix(text, "NO"):
+			return ResolverCapability{Name: CapQNameMin, State: CapabilityNo, Detail: "sends full names to every server"}, l
+		}
+	}
+	return ResolverCapability{Name: CapQNameMin, State: CapabilityUnknown,
+		Detail: fmt.Sprintf("no verdict from the test zone (%s)", dns.RcodeToString[l.msg.Rcode])}, l
+}
+
+// probeTCP repeats a query over TCP, which truncated UDP answers fall back to
+func (c *DNSCollector) probeTCP(ctx context.Context, server DNSServer) (ResolverCapability, DNSLookupResult) {
+	switch server.Proto {
+	case ProtoDoT, ProtoDoH:
+		return ResolverCapability{Name: CapTCP, State: CapabilityNA, Detail: string(server.Proto) + " already runs over TCP"}, DNSLookupResult{}
+	case ProtoDoH3, ProtoDoQ:
+		return ResolverCapability{Name: CapTCP, State: CapabilityNA, Detail: string(server.Proto) + " runs over QUIC"}, DNSLookupResult{}
+	}
+	tcp := server
+	tcp.Proto = ProtoTCP
+	l := c.exchange(ctx, capabilityQuery(dnssecProbeDomain, dns.TypeA, 0, false), tcp)
+	if l.Error != nil {
+		return ResolverCapability{Name: CapTCP, State: CapabilityNo, Error: l.Error}, l
+	}
+	return ResolverCapability{Name: CapTCP, State: CapabilityYes, Detail: fmt.Sprintf("answered in %dms", l.Latency.Milliseconds())}, l
+}
+
+// probeVersion asks for the CHAOS TXT version.bind record
+func (c *DNSCollector) probeVersion(ctx context.Context, server DNSServer) (ResolverCapability, DNSLookupResult) {
+	msg := capabilityQuery("version.bind.", dns.TypeTXT, 0, false)
+	msg.Question[0].Qclass = dns.ClassCHAOS
+	l := c.exchange(ctx, msg, server)
+	if verdict, ok := failed(CapVersionTXT, l); ok {
+		return verdict, l
+	}
+	for _, rr := range l.msg.Answer {
+		if txt, ok := rr.(*dns.TXT); ok && len(txt.Txt) > 0 {
+			return ResolverCapability{Name: CapVersionTXT, State: CapabilityYes, Detail: strings.Join(txt.Txt, " ")}, l
+		}
+	}
+	return ResolverCapability{Name: CapVersionTXT, State: CapabilityNo,
+		Detail: fmt.Sprintf("hidden (%s)", dns.RcodeToString[l.msg.Rcode])}, l
+}
@@ -0,0 +1,118 @@
+package collector
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// capableResolver answers like a validating BIND with cookies and QNAME
+// minimisation, and tracks which transports it was asked over
+func capableResolver(w dns.ResponseWriter, r *dns.Msg) {
+	resp := new(dns.Msg)
+	resp.SetReply(r)
+	q := r.Question[0]
+
+	if opt := r.IsEdns0(); opt != nil {
+		resp.SetEdns0(1232, opt.Do())
+		for _, o := range opt.Option {
+			if cookie, ok := o.(*dns.EDNS0_COOKIE); ok {
+				resp.IsEdns0().Option = append(resp.IsEdns0().Option,
+					&dns.EDNS0_COOKIE{Code: dns.EDNS0COOKIE, Cookie: cookie.Cookie + "0102030405060708"})
+			}
+		}
+		resp.AuthenticatedData = opt.Do()
+	}
+
+	switch {
+	case q.Qclass == dns.ClassCHAOS && q.Name == "version.bind.":
+		rr, _ := dns.NewRR(`version.bind. 0 CH TXT "9.18.24"`)
+		resp.Answer = append(resp.Answer, rr)
+	case strings.HasPrefix(q.Name, nxdomainProbePrefix):
+		resp.Rcode = dns.RcodeNameError
+	case q.Name == qnameMinProbeDomain:
+		rr, _ := dns.NewRR(q.Name + ` 60 IN TXT "HOORAY - QNAME minimisation is enabled on your resolver :)!"`)
+		resp.Answer = append(resp.Answer, rr)
+	default:
+		rr, _ := dns.NewRR(q.Name + " 60 IN A 192.0.2.1")
+		resp.Answer = append(resp.Answer, rr)
+	}
+	w.WriteMsg(resp)
+}
+
+// legacyResolver ignores EDNS entirely, refuses CHAOS queries and answers
+// every other name, even nonexistent ones
+func legacyResolver(w dns.ResponseWriter, r *dns.Msg) {
+	resp := new(dns.Msg)
+	resp.SetReply(r)
+	q := r.Question[0]
+	switch {
+	case q.Qclass == dns.ClassCHAOS:
+		resp.Rcode = dns.RcodeRefused
+	case q.Name == qnameMinProbeDomain:
+		rr, _ := dns.NewRR(q.Name + ` 60 IN TXT "NO - QNAME minimisation is NOT enabled on your resolver :("`)
+		resp.Answer = append(resp.Answer, rr)
+	default:
+		rr, _ := dns.NewRR(q.Name + " 60 IN A 192.0.2.1")
+		resp.Answer = append(resp.Answer, rr)
+	}
+	w.WriteMsg(resp)
+}
+
+func TestDNSCapabilities(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c := NewDNSCollector()
+
+	states := func(res ResolverCapabilities) map[string]ResolverCapability {
+		m := make(map[string]ResolverCapability)
+		for _, capability := range res.Capabilities {
+			m[capability.Name] = capability
+		}
+		return m
+	}
+
+	capable := startMockDNS(t, capableResolver)
+	res := c.Capabilities(ctx, DNSServer{Name: "Mock", Address: capable, Proto: ProtoUDP})
+	if res.Server != capable {
+		t.Errorf("Server = %q, want %q", res.Server, capable)
+	}
+	if len(res.Capabilities) != 7 || res.Capabilities[0].Name != CapEDNS {
+		t.Fatalf("unexpected capability list: %+v", res.Capabilities)
+	}
+	got := states(res)
+	for _, name := range []string{CapEDNS, CapDNSSEC, CapCookies, CapQNameMin, CapTCP, CapVersionTXT, CapNXDomain} {
+		if got[name].State != CapabilityYes {
+			t.Errorf("capable resolver: %s = %s (%s, %v), want yes", name, got[name].State, got[name].Detail, got[name].Error)
+		}
+	}
+	if got[CapEDNS].Detail != "version 0, UDP buffer 1232 bytes" {
+		t.Errorf("EDNS detail = %q", got[CapEDNS].Detail)
+	}
+	if got[CapVersionTXT].Detail != "9.18.24" {
+		t.Errorf("version.bind detail = %q", got[CapVersionTXT].Detail)
+	}
+
+	legacy := startMockDNS(t, legacyResolver)
+	got = states(c.Capabilities(ctx, DNSServer{Name: "Legacy", Address: legacy, Proto: ProtoUDP}))
+	for _, name := range []string{CapEDNS, CapDNSSEC, CapCookies, CapQNameMin, CapVersionTXT, CapNXDomain} {
+		if got[name].State != CapabilityNo {
+			t.Errorf("legacy resolver: %s = %s (%s, %v), want no", name, got[name].State, got[name].Detail, got[name].Error)
+		}
+	}
+	if got[CapTCP].State != CapabilityYes {
+		t.Errorf("legacy resolver: TCP = %s, want yes", got[CapTCP].State)
+	}
+
+	// Transport errors leave the verdict open instead of claiming "no"
+	got = states(c.Capabilities(ctx, DNSServer{Name: "DoT", Address: "127.0.0.1:1", Proto: ProtoDoT}))
+	if got[CapEDNS].State != CapabilityUnknown || got[CapEDNS].Error == nil {
+		t.Errorf("unreachable DoT server: EDNS = %+v, want unknown with an error", got[CapEDNS])
+	}
+	if got[CapTCP].State != CapabilityNA {
+		t.Errorf("DoT server: TCP = %s, want n/a", got[CapTCP].State)
+	}
+}
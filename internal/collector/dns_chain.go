@@ -0,0 +1,125 @@
+package collector
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"slices"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// DNSWildcard is the answer to a random sibling of the queried name. An
+// answer to a name nobody registered means the parent zone has a wildcard.
+type DNSWildcard struct {
+	Probe    string   // The random sibling name queried
+	Detected bool     // The probe was answered
+	Answers  []string // Final addresses or data of the probe's answer
+	Matches  bool     // Same answer as the original query, which likely came from the wildcard too
+	Error    error
+}
+
+// cnameChain follows CNAMEs from the question name through the answer
+// section, which servers may return in any order. It returns the names in
+// order, starting with the question, and the data of the records at the
+// end of the chain. names is nil when there is no CNAME.
+func cnameChain(r *dns.Msg) (names, final []string) {
+	if len(r.Question) == 0 {
+		return nil, nil
+	}
+	targets := make(map[string]string)
+	for _, rr := range r.Answer {
+		if cname, ok := rr.(*dns.CNAME); ok {
+			targets[strings.ToLower(cname.Hdr.Name)] = cname.Target
+		}
+	}
+
+	name := r.Question[0].Name
+	seen := map[string]bool{strings.ToLower(name): true}
+	names = []string{strings.TrimSuffix(name, ".")}
+	for {
+		target, ok := targets[strings.ToLower(name)]
+		if !ok {
+			break
+		}
+		names = append(names, strings.TrimSuffix(target, "."))
+		name = target
+		if seen[strings.ToLower(name)] {
+			break // Loop, the last name shows where it closes
+		}
+		seen[strings.ToLower(name)] = true
+	}
+	final = answerData(r.Answer, name)
+	if len(names) == 1 {
+		return nil, final
+	}
+	return names, final
+}
+
+// answerData returns the data of the non-CNAME answers owned by name
+func answerData(answers []dns.RR, name string) []string {
+	var out []string
+	for _, rr := range answers {
+		h := rr.Header()
+		if h.Rrtype == dns.TypeCNAME || !strings.EqualFold(h.Name, name) {
+			continue
+		}
+		out = append(out, strings.TrimPrefix(rr.String(), h.String()))
+	}
+	return out
+}
+
+// wildcardProbeName is a random label under the parent of domain, or "" when
+// domain has no parent below the root
+func wildcardProbeName(domain string) (string, error) {
+	labels := dns.SplitDomainName(domain)
+	if len(labels) < 2 {
+		return "", nil
+	}
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "lnd-" + hex.EncodeToString(b) + "." + strings.Join(labels[1:], ".") + ".", nil
+}
+
+// detectWildcard queries a random sibling of domain and compares its answer
+// with res, the answer to domain itself
+func (c *DNSCollector) detectWildcard(ctx context.Context, domain string, recordType DNSRecordType, server DNSServer, res DNSLookupResult) *DNSWildcard {
+	probe, err := wildcardProbeName(domain)
+	if err != nil {
+		return &DNSWildcard{Error: err}
+	}
+	if probe == "" {
+		return nil
+	}
+	w := &DNSWildcard{Probe: strings.TrimSuffix(probe, ".")}
+	msg, err := buildQuery(probe, recordType, DNSQueryOptions{})
+	if err != nil {
+		w.Error = err
+		return w
+	}
+	sibling := c.exchange(ctx, msg, server)
+	if sibling.Error != nil {
+		w.Error = sibling.Error
+		return w
+	}
+	if sibling.msg == nil || sibling.msg.Rcode != dns.RcodeSuccess || len(sibling.msg.Answer) == 0 {
+		return w
+	}
+
+	w.Detected = true
+	_, w.Answers = cnameChain(sibling.msg)
+	if res.msg != nil {
+		_, original := cnameChain(res.msg)
+		w.Matches = len(original) > 0 && slices.Equal(sorted(original), sorted(w.Answers))
+	}
+	return w
+}
+
+func sorted(s []string) []string {
+	s = slices.Clone(s)
+	slices.Sort(s)
+	return s
+}
@@ -0,0 +1,84 @@
+package collector
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func mustRR(t *testing.T, s string) dns.RR {
+	t.Helper()
+	rr, err := dns.NewRR(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rr
+}
+
+func TestCNAMEChain_Order(t *testing.T) {
+	r := new(dns.Msg)
+	r.SetQuestion("www.example.com.", dns.TypeA)
+	// Out of order on purpose, the chain follows the names
+	r.Answer = []dns.RR{
+		mustRR(t, "edge.cdn.example.net. 60 IN A 192.0.2.10"),
+		mustRR(t, "cdn.example.net. 60 IN CNAME edge.cdn.example.net."),
+		mustRR(t, "WWW.example.com. 300 IN CNAME cdn.example.net."),
+		mustRR(t, "edge.cdn.example.net. 60 IN A 192.0.2.11"),
+	}
+
+	names, final := cnameChain(r)
+	want := []string{"www.example.com", "cdn.example.net", "edge.cdn.example.net"}
+	if !slices.Equal(names, want) {
+		t.Errorf("chain = %v, want %v", names, want)
+	}
+	if !slices.Equal(final, []string{"192.0.2.10", "192.0.2.11"}) {
+		t.Errorf("final answers = %v", final)
+	}
+
+	r.Answer = []dns.RR{mustRR(t, "www.example.com. 60 IN A 192.0.2.1")}
+	if names, _ := cnameChain(r); names != nil {
+		t.Errorf("no CNAME should give no chain, got %v", names)
+	}
+
+	r.Answer = []dns.RR{
+		mustRR(t, "www.example.com. 60 IN CNAME a.example.com."),
+		mustRR(t, "a.example.com. 60 IN CNAME www.example.com."),
+	}
+	if names, _ := cnameChain(r); len(names) != 3 {
+		t.Errorf("a CNAME loop should stop where it closes, got %v", names)
+	}
+}
+
+func TestDNSLookup_Wildcard(t *testing.T) {
+	addr := startMockDNS(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		resp := new(dns.Msg)
+		resp.SetReply(r)
+		name := r.Question[0].Name
+		switch {
+		case strings.HasSuffix(name, ".example.com."): // *.example.com wildcard
+			resp.Answer = append(resp.Answer, mustRR(t, name+" 60 IN A 192.0.2.1"))
+		default:
+			resp.Rcode = dns.RcodeNameError
+		}
+		w.WriteMsg(resp)
+	})
+
+	c := NewDNSCollector()
+	server := DNSServer{Name: "mock", Address: addr, Proto: ProtoUDP}
+	res := c.LookupWithOptions(context.Background(), "www.example.com", RecordA, server, DNSQueryOptions{Wildcard: true})
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	w := res.Wildcard
+	if w == nil || !w.Detected || !w.Matches || !strings.HasSuffix(w.Probe, ".example.com") {
+		t.Errorf("wildcard = %+v, want a matching detection", w)
+	}
+
+	res = c.LookupWithOptions(context.Background(), "api.example.org", RecordA, server, DNSQueryOptions{Wildcard: true})
+	if res.Wildcard == nil || res.Wildcard.Detected {
+		t.Errorf("NXDOMAIN sibling should not flag a wildcard, got %+v", res.Wildcard)
+	}
+}
@@ -0,0 +1,112 @@
+package collector
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dnsLookupAllTimeout bounds a comparison across servers, however many
+const dnsLookupAllTimeout = 5 * time.Second
+
+// LookupAll sends the same query to every server at once. The lookups
+// share one deadline, so a dead server costs no more than the timeout.
+// Results are in server order.
+func (c *DNSCollector) LookupAll(ctx context.Context, domain string, recordType DNSRecordType, servers []DNSServer) []DNSLookupResult {
+	ctx, cancel := context.WithTimeout(ctx, dnsLookupAllTimeout)
+	defer cancel()
+
+	results := make([]DNSLookupResult, len(servers))
+	var wg sync.WaitGroup
+	for i, server := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = c.Lookup(ctx, domain, recordType, server)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// DNSComparison is one query answered by several servers side by side
+type DNSComparison struct {
+	Domain    string
+	Type      DNSRecordType
+	Servers   []string // Server names, in the order of Results
+	Results   []DNSLookupResult
+	Majority  []string // Most common answer set, normalized like DNSBatchEntry.Answers
+	Divergent []bool   // Per result: answered NOERROR with a set other than the majority
+	// NoMajority is set when two or more answer sets tie for the top
+	// count; Majority is then nil and no result is flagged
+	NoMajority bool
+}
+
+// Answers returns the answer data of result i, normalized for comparison
+func (c DNSComparison) Answers(i int) []string {
+	return normalizeAnswers(c.Results[i].Records)
+}
+
+// Compare runs LookupAll and marks the servers that disagree with the
+// most common answer, which points at split-horizon or tampered answers.
+// Failed lookups and error codes are not counted towards the majority.
+// The worst case, retries included, is taken from the budget up front, so
+// a comparison runs whole or sends nothing; a probe running alongside
+// cannot drain the budget halfway through.
+func (c *DNSCollector) Compare(ctx context.Context, domain string, recordType DNSRecordType, servers []DNSServer) DNSComparison {
+	res := DNSComparison{Domain: domain, Type: recordType}
+	for _, s := range servers {
+		res.Servers = append(res.Servers, s.Name)
+	}
+	var probes int
+	var bytes int64
+	for _, s := range servers {
+		p, b := lookupCost(s.Proto)
+		probes, bytes = probes+p, bytes+b
+	}
+	err := c.Budget.Fits(probes, bytes) // Tells a budget too small from one to wait for
+	if err == nil && !c.Budget.Allow(probes, bytes) {
+		err = ErrBudgetExceeded // Taken by another probe since the check
+	}
+	if err != nil {
+		for _, s := range servers {
+			res.Results = append(res.Results, DNSLookupResult{Error: err, Server: s.Address, Protocol: s.Proto})
+		}
+		return res
+	}
+	// Paid for above, the lookups must not be charged again
+	unbudgeted := *c
+	unbudgeted.Budget = nil
+	res.Results = unbudgeted.LookupAll(ctx, domain, recordType, servers)
+	res.Majority, res.Divergent, res.NoMajority = divergentAnswers(res.Results)
+	return res
+}
+
+// divergentAnswers finds the most common answer set among the successful
+// results and flags those answering differently. A tie for the top count
+// has no majority to differ from, so nothing is flagged.
+func divergentAnswers(results []DNSLookupResult) (majority []string, divergent []bool, tied bool) {
+	answered := func(r DNSLookupResult) bool {
+		return r.Error == nil && r.ResponseCode == "NOERROR"
+	}
+	var answers [][]string
+	for _, r := range results {
+		if answered(r) {
+			answers = append(answers, r.Records)
+		}
+	}
+	divergent = make([]bool, len(results))
+	sets := tallyAnswerSets(answers)
+	if len(sets) == 0 {
+		return nil, divergent, false
+	}
+	if len(sets) > 1 && sets[1].Count == sets[0].Count {
+		return nil, divergent, true
+	}
+	key := strings.Join(sets[0].Answers, "\n")
+	for i, r := range results {
+		divergent[i] = answered(r) && strings.Join(normalizeAnswers(r.Records), "\n") != key
+	}
+	return sets[0].Answers, divergent, false
+}
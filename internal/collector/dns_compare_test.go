@@ -0,0 +1,129 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestDNSCollector_Compare(t *testing.T) {
+	answering := func(ip string) string {
+		return startMockDNS(t, answerA(ip))
+	}
+	servers := []DNSServer{
+		{Name: "One", Address: answering("192.0.2.1"), Proto: ProtoUDP},
+		{Name: "Two", Address: answering("192.0.2.1"), Proto: ProtoUDP},
+		{Name: "Poisoned", Address: answering("198.51.100.7"), Proto: ProtoUDP},
+		{Name: "Refusing", Address: startMockDNS(t, func(w dns.ResponseWriter, r *dns.Msg) {
+			resp := new(dns.Msg)
+			resp.SetRcode(r, dns.RcodeRefused)
+			w.WriteMsg(resp)
+		}), Proto: ProtoUDP},
+	}
+
+	res := NewDNSCollector().Compare(context.Background(), "example.com", RecordA, servers)
+	if !slices.Equal(res.Servers, []string{"One", "Two", "Poisoned", "Refusing"}) || len(res.Results) != 4 {
+		t.Fatalf("results out of server order: %v", res.Servers)
+	}
+	for i, r := range res.Results[:3] {
+		if r.Error != nil || r.Latency <= 0 {
+			t.Errorf("%s: err %v, latency %s", res.Servers[i], r.Error, r.Latency)
+		}
+	}
+	if res.Results[3].ResponseCode != "REFUSED" {
+		t.Errorf("Refusing answered %s", res.Results[3].ResponseCode)
+	}
+	if !slices.Equal(res.Majority, []string{"A 192.0.2.1"}) {
+		t.Errorf("Majority = %v", res.Majority)
+	}
+	if !slices.Equal(res.Divergent, []bool{false, false, true, false}) {
+		t.Errorf("Divergent = %v, want only the poisoned server", res.Divergent)
+	}
+}
+
+func TestDivergentAnswers_Tie(t *testing.T) {
+	results := []DNSLookupResult{
+		{ResponseCode: "NOERROR", Records: []string{"example.com. 60 IN A 192.0.2.1"}},
+		{ResponseCode: "NOERROR", Records: []string{"example.com. 60 IN A 198.51.100.7"}},
+	}
+	majority, divergent, tied := divergentAnswers(results)
+	if !tied || majority != nil {
+		t.Errorf("two servers that disagree: majority %v, tied %v, want no majority", majority, tied)
+	}
+	if slices.Contains(divergent, true) {
+		t.Errorf("Divergent = %v, a tie should flag no server", divergent)
+	}
+}
+
+func TestDNSCollector_CompareOverBudget(t *testing.T) {
+	c := NewDNSCollector()
+	c.Budget, _ = fixedBudget(2, 0)
+	servers := []DNSServer{
+		{Name: "A", Address: "192.0.2.1:53", Proto: ProtoUDP},
+		{Name: "B", Address: "192.0.2.2:53", Proto: ProtoUDP},
+		{Name: "C", Address: "192.0.2.3:53", Proto: ProtoUDP},
+	}
+	res := c.Compare(context.Background(), "example.com", RecordA, servers)
+	if len(res.Results) != len(servers) {
+		t.Fatalf("got %d results, want %d", len(res.Results), len(servers))
+	}
+	for i, r := range res.Results {
+		if !errors.Is(r.Error, ErrBudgetExceeded) {
+			t.Errorf("result %d error = %v, want ErrBudgetExceeded", i, r.Error)
+		}
+	}
+	if probes, _ := c.Budget.Remaining(); probes != 2 {
+		t.Errorf("a refused comparison should not consume budget, %d left", probes)
+	}
+}
+
+func TestDNSCollector_CompareTakesBudgetUpFront(t *testing.T) {
+	servers := []DNSServer{
+		{Name: "A", Address: startMockDNS(t, answerA("192.0.2.10")), Proto: ProtoUDP},
+		{Name: "B", Address: startMockDNS(t, answerA("192.0.2.10")), Proto: ProtoUDP},
+	}
+	c := NewDNSCollector()
+	c.Budget, _ = fixedBudget(10, 0)
+	res := c.Compare(context.Background(), "example.com", RecordA, servers)
+	for i, r := range res.Results {
+		if r.Error != nil {
+			t.Fatalf("result %d error = %v", i, r.Error)
+		}
+	}
+	// Two lookups of up to three exchanges each, charged once
+	if probes, _ := c.Budget.Remaining(); probes != 4 {
+		t.Errorf("%d probes left, want the worst case of 6 taken and nothing more", probes)
+	}
+}
+
+func TestDNSCollector_CompareChargesEncryptedServers(t *testing.T) {
+	servers := []DNSServer{
+		{Name: "Plain", Address: "192.0.2.1:53", Proto: ProtoUDP},
+		{Name: "DoT", Address: "192.0.2.2:853", Proto: ProtoDoT},
+		{Name: "DoH", Address: "https://192.0.2.3/dns-query", Proto: ProtoDoH},
+	}
+	// Enough for three plain queries, not for the TLS handshakes
+	c := NewDNSCollector()
+	c.Budget, _ = fixedBudget(0, 4*tunnelProbeBytes)
+	res := c.Compare(context.Background(), "example.com", RecordA, servers)
+	for i, r := range res.Results {
+		if !errors.Is(r.Error, ErrBudgetExceeded) {
+			t.Errorf("result %d error = %v, want ErrBudgetExceeded", i, r.Error)
+		}
+	}
+	if _, bytes := c.Budget.Remaining(); bytes != 4*tunnelProbeBytes {
+		t.Errorf("a refused comparison should not consume budget, %d bytes left", bytes)
+	}
+
+	probes, bytes := 0, int64(0)
+	for _, s := range servers {
+		p, b := lookupCost(s.Proto)
+		probes, bytes = probes+p, bytes+b
+	}
+	if want := int64(3*dnsProbeBytes + 2*(2*dnsProbeBytes+2*tunnelProbeBytes)); probes != 7 || bytes != want {
+		t.Errorf("comparison cost = %d probes, %d bytes, want 7 and %d", probes, bytes, want)
+	}
+}
@@ -0,0 +1,113 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/sysatom/lnd/internal/config"
+)
+
+// ConnectApps are the application checks offered after a lookup, the
+// tunnel checks that make sense against a plain server
+var ConnectApps = []string{"http", "tls", "ws", "tcp"}
+
+// connectAppPorts are the ports checked when the name carries none. A
+// plain TCP check has no usual port, so its name must carry one.
+var connectAppPorts = map[string]int{"http": 80, "tls": 443, "ws": 80, "tcp": 0}
+
+// ResolveConnectResult is a lookup followed by an application check
+// against the first address it returned
+type ResolveConnectResult struct {
+	Name          string
+	App           string
+	Server        string
+	Addresses     []string // Every A/AAAA answer
+	Address       string   // host:port that was checked
+	LookupLatency time.Duration
+	CheckLatency  time.Duration
+	Stage         string // Where it failed: "resolve" or "connect", empty on success
+	Error         error
+}
+
+// ResolveConnect resolves name through server and runs the tunnel
+// collector's application check for app against the first address.
+// name may carry a port, otherwise the app's usual port is used; the tcp
+// check has none and needs the port in name. The
+// check sends the name, not the address, as HTTP Host and TLS SNI, so it
+// reaches the same virtual host a client would.
+func (c *DNSCollector) ResolveConnect(ctx context.Context, name, app string, server DNSServer) ResolveConnectResult {
+	res := ResolveConnectResult{Name: name, App: app}
+	port, ok := connectAppPorts[app]
+	if !ok {
+		res.Stage, res.Error = "connect", fmt.Errorf("unsupported check %q", app)
+		return res
+	}
+	host := name
+	if h, p, err := net.SplitHostPort(name); err == nil {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 1 || n > 65535 {
+			res.Stage, res.Error = "resolve", fmt.Errorf("invalid port %q", p)
+			return res
+		}
+		host, port = h, n
+	}
+	if port == 0 {
+		res.Stage, res.Error = "resolve", fmt.Errorf("the %s check needs a port, as in %s:port", app, name)
+		return res
+	}
+
+	lookup := c.Lookup(ctx, host, RecordA, server)
+	if lookup.Error == nil && len(lookupAddresses(lookup)) == 0 {
+		lookup = c.Lookup(ctx, host, RecordAAAA, server)
+	}
+	res.Server, res.LookupLatency = lookup.Server, lookup.Latency
+	res.Addresses = lookupAddresses(lookup)
+	switch {
+	case lookup.Error != nil:
+		res.Stage, res.Error = "resolve", lookup.Error
+		return res
+	case len(res.Addresses) == 0:
+		res.Stage, res.Error = "resolve", fmt.Errorf("%s has no address (%s)", host, lookup.ResponseCode)
+		return res
+	}
+
+	res.Address = net.JoinHostPort(res.Addresses[0], strconv.Itoa(port))
+	if !c.Budget.Allow(1, tunnelProbeBytes) {
+		res.Stage, res.Error = "connect", ErrBudgetExceeded
+		return res
+	}
+	target := net.JoinHostPort(host, strconv.Itoa(port))
+	start := time.Now()
+	dialer := &markedDialer{Source: c.Source, Timeout: 5 * time.Second}
+	conn, err := dialer.DialContext(ctx, "tcp", res.Address)
+	if err == nil {
+		defer conn.Close()
+		err = (&TunnelCollector{}).checkApplication(conn, config.TunnelConfig{Target: target, App: app, Transport: "tcp"})
+	}
+	res.CheckLatency = time.Since(start)
+	if err != nil {
+		res.Stage, res.Error = "connect", err
+	}
+	return res
+}
+
+// lookupAddresses returns the A and AAAA data of a lookup's answer
+func lookupAddresses(res DNSLookupResult) []string {
+	if res.msg == nil {
+		return nil
+	}
+	var addrs []string
+	for _, rr := range res.msg.Answer {
+		switch rr := rr.(type) {
+		case *dns.A:
+			addrs = append(addrs, rr.A.String())
+		case *dns.AAAA:
+			addrs = append(addrs, rr.AAAA.String())
+		}
+	}
+	return addrs
+}
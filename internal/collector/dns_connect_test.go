@@ -0,0 +1,66 @@
+package collector
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestDNSCollector_ResolveConnect(t *testing.T) {
+	var host string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host = r.Host
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+	port := strconv.Itoa(ts.Listener.Addr().(*net.TCPAddr).Port)
+
+	addr := startMockDNS(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		resp := new(dns.Msg)
+		resp.SetReply(r)
+		if r.Question[0].Qtype == dns.TypeA {
+			resp.Answer = append(resp.Answer, mustRR(t, r.Question[0].Name+" 60 IN A 127.0.0.1"))
+		}
+		w.WriteMsg(resp)
+	})
+	server := DNSServer{Name: "Mock", Address: addr, Proto: ProtoUDP}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	res := NewDNSCollector().ResolveConnect(ctx, "svc.test:"+port, "http", server)
+	if res.Error != nil {
+		t.Fatalf("ResolveConnect() failed at %s: %v", res.Stage, res.Error)
+	}
+	if res.Address != "127.0.0.1:"+port {
+		t.Errorf("Address = %q", res.Address)
+	}
+	if host != "svc.test:"+port {
+		t.Errorf("server saw Host %q, want the looked up name", host)
+	}
+
+	// A plain TCP check has no usual port to fall back on
+	res = NewDNSCollector().ResolveConnect(ctx, "svc.test", "tcp", server)
+	if res.Stage != "resolve" || res.Error == nil || res.Server != "" {
+		t.Errorf("tcp without a port: stage %q, server %q, err %v", res.Stage, res.Server, res.Error)
+	}
+	res = NewDNSCollector().ResolveConnect(ctx, "svc.test:"+port, "tcp", server)
+	if res.Error != nil || res.Address != "127.0.0.1:"+port {
+		t.Errorf("tcp with a port: %+v", res)
+	}
+
+	// A name without addresses stops before connecting
+	res = NewDNSCollector().ResolveConnect(ctx, "svc.test", "tls", DNSServer{Name: "Mock", Address: startMockDNS(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		resp := new(dns.Msg)
+		resp.SetRcode(r, dns.RcodeNameError)
+		w.WriteMsg(resp)
+	}), Proto: ProtoUDP})
+	if res.Stage != "resolve" || res.Error == nil {
+		t.Errorf("NXDOMAIN: stage %q, err %v", res.Stage, res.Error)
+	}
+}
@@ -0,0 +1,101 @@
+package collector
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DNSAnswerSet is a distinct set of answers and how often it was observed
+type DNSAnswerSet struct {
+	Answers []string
+	Count   int
+}
+
+// DNSConsistencyResult summarizes repeated queries for the same name against one resolver
+type DNSConsistencyResult struct {
+	Domain     string
+	Server     string
+	Queries    int
+	Failures   int
+	AnswerSets []DNSAnswerSet // Sorted by frequency, most common first
+	Error      error
+}
+
+// Stable reports whether every successful query returned the same answer set
+func (r DNSConsistencyResult) Stable() bool {
+	return len(r.AnswerSets) <= 1 && r.Failures == 0
+}
+
+// ConsistencyCheck queries the same name count times and tallies the distinct answer sets,
+// separating round-robin/CDN rotation from intermittent failures.
+func (c *DNSCollector) ConsistencyCheck(ctx context.Context, domain string, recordType DNSRecordType, server DNSServer, count int) DNSConsistencyResult {
+	res := DNSConsistencyResult{Domain: domain}
+
+	var answers [][]string
+	for i := 0; i < count; i++ {
+		if ctx.Err() != nil {
+			break
+		}
+		lookup := c.Lookup(ctx, domain, recordType, server)
+		res.Queries++
+		res.Server = lookup.Server
+		if lookup.Error != nil {
+			res.Failures++
+			res.Error = lookup.Error
+		} else {
+			answers = append(answers, lookup.Records)
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	res.AnswerSets = tallyAnswerSets(answers)
+	return res
+}
+
+// tallyAnswerSets groups responses by their answer data, ignoring TTL and record order
+func tallyAnswerSets(answers [][]string) []DNSAnswerSet {
+	counts := make(map[string]*DNSAnswerSet)
+	var order []string
+
+	for _, records := range answers {
+		normalized := normalizeAnswers(records)
+		key := strings.Join(normalized, "\n")
+		if set, ok := counts[key]; ok {
+			set.Count++
+			continue
+		}
+		counts[key] = &DNSAnswerSet{Answers: normalized, Count: 1}
+		order = append(order, key)
+	}
+
+	sets := make([]DNSAnswerSet, 0, len(order))
+	for _, key := range order {
+		sets = append(sets, *counts[key])
+	}
+	sort.SliceStable(sets, func(i, j int) bool {
+		return sets[i].Count > sets[j].Count
+	})
+	return sets
+}
+
+// normalizeAnswers strips owner name, TTL and class from record strings
+// ("google.com. 300 IN A 1.2.3.4" -> "A 1.2.3.4") and sorts them.
+func normalizeAnswers(records []string) []string {
+	normalized := make([]string, 0, len(records))
+	for _, rec := range records {
+		fields := strings.Fields(rec)
+		if len(fields) >= 4 {
+			normalized = append(normalized, strings.Join(fields[3:], " "))
+		} else {
+			normalized = append(normalized, rec)
+		}
+	}
+	sort.Strings(normalized)
+	return normalized
+}
@@ -0,0 +1,36 @@
+package collector
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTallyAnswerSets(t *testing.T) {
+	setA := []string{"cdn.example. 60 IN A 192.0.2.1", "cdn.example. 60 IN A 192.0.2.2"}
+	// Same set, different order and TTL
+	setAReordered := []string{"cdn.example. 42 IN A 192.0.2.2", "cdn.example. 42 IN A 192.0.2.1"}
+	setB := []string{"cdn.example. 60 IN A 192.0.2.3"}
+
+	sets := tallyAnswerSets([][]string{setA, setB, setAReordered, setA, setB, setA})
+
+	if len(sets) != 2 {
+		t.Fatalf("expected 2 distinct sets, got %d: %+v", len(sets), sets)
+	}
+	if sets[0].Count != 4 || sets[1].Count != 2 {
+		t.Errorf("counts = %d/%d, want 4/2", sets[0].Count, sets[1].Count)
+	}
+	wantFirst := []string{"A 192.0.2.1", "A 192.0.2.2"}
+	if !reflect.DeepEqual(sets[0].Answers, wantFirst) {
+		t.Errorf("most common set = %v, want %v", sets[0].Answers, wantFirst)
+	}
+
+	res := DNSConsistencyResult{AnswerSets: sets}
+	if res.Stable() {
+		t.Error("rotating answers should not be reported as stable")
+	}
+
+	stable := DNSConsistencyResult{AnswerSets: tallyAnswerSets([][]string{setA, setAReordered})}
+	if !stable.Stable() {
+		t.Error("identical answer sets should be reported as stable")
+	}
+}
@@ -0,0 +1,54 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// extendedError returns the RFC 8914 Extended DNS Errors of a response as
+// readable reasons, e.g. "DNSSEC Bogus" or "Blocked (listed by policy)",
+// empty without any
+func extendedError(r *dns.Msg) string {
+	opt := r.IsEdns0()
+	if opt == nil {
+		return ""
+	}
+	var reasons []string
+	for _, o := range opt.Option {
+		ede, ok := o.(*dns.EDNS0_EDE)
+		if !ok {
+			continue
+		}
+		reason, ok := dns.ExtendedErrorCodeToString[ede.InfoCode]
+		if !ok {
+			reason = fmt.Sprintf("Extended error %d", ede.InfoCode)
+		}
+		if text := strings.TrimSpace(ede.ExtraText); text != "" {
+			reason += " (" + text + ")"
+		}
+		reasons = append(reasons, reason)
+	}
+	return strings.Join(reasons, "; ")
+}
+
+// explainFailure fills in the extended error of a SERVFAIL or REFUSED
+// answer to a query sent without EDNS. Resolvers only attach the reason
+// when the query carries an OPT record, so the query is repeated once with
+// one; everything else about the result stays as first answered.
+func (c *DNSCollector) explainFailure(ctx context.Context, msg *dns.Msg, server DNSServer, res *DNSLookupResult) {
+	if res.Error != nil || res.ExtendedError != "" || msg.IsEdns0() != nil {
+		return
+	}
+	if res.ResponseCode != dns.RcodeToString[dns.RcodeServerFailure] && res.ResponseCode != dns.RcodeToString[dns.RcodeRefused] {
+		return
+	}
+	retry := msg.Copy()
+	retry.Id = dns.Id()
+	retry.SetEdns0(defaultEDNSBufSize, false)
+	if again := c.exchange(ctx, retry, server); again.Error == nil {
+		res.ExtendedError = again.ExtendedError
+	}
+}
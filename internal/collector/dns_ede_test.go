@@ -0,0 +1,66 @@
+package collector
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestDNSLookup_ExtendedError(t *testing.T) {
+	var queries atomic.Int32
+	addr := startMockDNS(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		queries.Add(1)
+		resp := new(dns.Msg)
+		switch r.Question[0].Name {
+		case "bogus.test.":
+			resp.SetRcode(r, dns.RcodeServerFailure)
+		case "blocked.test.":
+			resp.SetRcode(r, dns.RcodeRefused)
+		default:
+			resp.SetReply(r)
+		}
+		// Like real resolvers, explain only to clients that speak EDNS
+		if r.IsEdns0() != nil {
+			resp.SetEdns0(1232, false)
+			code := uint16(dns.ExtendedErrorCodeDNSBogus)
+			if r.Question[0].Name == "blocked.test." {
+				code = dns.ExtendedErrorCodeBlocked
+			}
+			resp.IsEdns0().Option = append(resp.IsEdns0().Option, &dns.EDNS0_EDE{InfoCode: code, ExtraText: "signature expired"})
+		}
+		w.WriteMsg(resp)
+	})
+	server := DNSServer{Name: "Mock", Address: addr, Proto: ProtoUDP}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c := NewDNSCollector()
+
+	res := c.Lookup(ctx, "bogus.test", RecordA, server)
+	if res.ResponseCode != "SERVFAIL" || res.ExtendedError != "DNSSEC Bogus (signature expired)" {
+		t.Errorf("SERVFAIL: rcode %q, reason %q", res.ResponseCode, res.ExtendedError)
+	}
+	if n := queries.Swap(0); n != 2 {
+		t.Errorf("SERVFAIL sent %d queries, want the lookup and one EDNS retry", n)
+	}
+
+	res = c.Lookup(ctx, "blocked.test", RecordA, server)
+	if res.ResponseCode != "REFUSED" || res.ExtendedError != "Blocked (signature expired)" {
+		t.Errorf("REFUSED: rcode %q, reason %q", res.ResponseCode, res.ExtendedError)
+	}
+	queries.Store(0)
+
+	// A query that already carries EDNS reads the reason from the first answer
+	res = c.LookupWithOptions(ctx, "bogus.test", RecordA, server, DNSQueryOptions{Cookie: true})
+	if res.ExtendedError != "DNSSEC Bogus (signature expired)" || queries.Swap(0) != 1 {
+		t.Errorf("EDNS query: reason %q", res.ExtendedError)
+	}
+
+	// Successful answers are not repeated
+	res = c.Lookup(ctx, "ok.test", RecordA, server)
+	if res.ExtendedError != "" || queries.Load() != 1 {
+		t.Errorf("NOERROR: reason %q after %d queries", res.ExtendedError, queries.Load())
+	}
+}
@@ -0,0 +1,45 @@
+package collector
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+// idnProfile maps names the way browsers do (IDNA2008 with UTS #46), but
+// allows underscores so SRV and TXT names like _sip._tcp still pass
+var idnProfile = idna.New(
+	idna.MapForLookup(),
+	idna.BidiRule(),
+	idna.Transitional(false),
+	idna.StrictDomainName(false),
+)
+
+// ToASCIIName converts an internationalized name such as münchen.de to the
+// A-label form sent on the wire, xn--mnchen-3ya.de. ASCII names are
+// returned unchanged.
+func ToASCIIName(name string) (string, error) {
+	if isASCII(name) {
+		return name, nil
+	}
+	root := strings.HasSuffix(name, ".")
+	ascii, err := idnProfile.ToASCII(strings.TrimSuffix(name, "."))
+	if err != nil {
+		return "", fmt.Errorf("invalid internationalized name %q: %v", name, err)
+	}
+	if root {
+		ascii += "."
+	}
+	return ascii, nil
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
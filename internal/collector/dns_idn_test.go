@@ -0,0 +1,64 @@
+package collector
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestToASCIIName(t *testing.T) {
+	tests := []struct {
+		name, want string
+		wantErr    bool
+	}{
+		{"münchen.de", "xn--mnchen-3ya.de", false},
+		{"MÜNCHEN.de.", "xn--mnchen-3ya.de.", false},
+		{"例え.テスト", "xn--r8jz45g.xn--zckzah", false},
+		{"_sip._tcp.example.com", "_sip._tcp.example.com", false}, // ASCII passes untouched
+		{"bad\u200d.com", "", true},                               // Zero-width joiner outside its context
+	}
+	for _, tt := range tests {
+		got, err := ToASCIIName(tt.name)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ToASCIIName(%q) = %q, %v; want %q, error %v", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestDNSLookup_IDN(t *testing.T) {
+	var mu sync.Mutex
+	var asked string
+	addr := startMockDNS(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		mu.Lock()
+		asked = r.Question[0].Name
+		mu.Unlock()
+		resp := new(dns.Msg)
+		resp.SetReply(r)
+		resp.Answer = append(resp.Answer, mustRR(t, r.Question[0].Name+" 60 IN A 192.0.2.1"))
+		w.WriteMsg(resp)
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res := NewDNSCollector().Lookup(ctx, "münchen.de", RecordA, DNSServer{Name: "Mock", Address: addr, Proto: ProtoUDP})
+	if res.Error != nil {
+		t.Fatalf("Lookup() error = %v", res.Error)
+	}
+	mu.Lock()
+	if asked != "xn--mnchen-3ya.de." {
+		t.Errorf("server was asked for %q, want the A-label form", asked)
+	}
+	mu.Unlock()
+	if res.UnicodeName != "münchen.de" || res.ASCIIName != "xn--mnchen-3ya.de" {
+		t.Errorf("names = %q / %q", res.UnicodeName, res.ASCIIName)
+	}
+
+	res = NewDNSCollector().Lookup(ctx, "bad\u200d.com", RecordA, DNSServer{Name: "Mock", Address: addr, Proto: ProtoUDP})
+	if res.Error == nil || !strings.Contains(res.Error.Error(), "invalid internationalized name") {
+		t.Errorf("unmappable name error = %v", res.Error)
+	}
+}
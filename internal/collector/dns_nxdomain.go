@@ -0,0 +1,86 @@
+package collector
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// nxdomainProbePrefix starts every random name of the NXDOMAIN check
+const nxdomainProbePrefix = "lnd-nx-"
+
+// NXDomainCheck is a resolver's answer to a name that cannot exist. Some
+// ISP resolvers answer such names with the address of an ad or search
+// page instead of NXDOMAIN, which breaks typo detection and search
+// domains and leaks mistyped names.
+type NXDomainCheck struct {
+	Probe     string
+	Rcode     string
+	Hijacked  bool     // The resolver answered with addresses instead of NXDOMAIN
+	Addresses []string // Where nonexistent names are sent
+	Error     error
+}
+
+// nxdomainProbeName returns a random second level .com name, long enough
+// that nobody registered it. A real TLD catches hijackers that leave
+// reserved names like .invalid alone.
+func nxdomainProbeName() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return nxdomainProbePrefix + hex.EncodeToString(b) + ".com.", nil
+}
+
+// nxdomain queries a random nonexistent name through server and flags an
+// answer with addresses as NXDOMAIN hijacking. The raw lookup is returned
+// too.
+func (c *DNSCollector) nxdomain(ctx context.Context, server DNSServer) (NXDomainCheck, DNSLookupResult) {
+	probe, err := nxdomainProbeName()
+	if err != nil {
+		return NXDomainCheck{Error: err}, DNSLookupResult{}
+	}
+	l := c.exchange(ctx, capabilityQuery(probe, dns.TypeA, 0, false), server)
+	return classifyNXDomain(probe, l), l
+}
+
+// classifyNXDomain judges the answer l to the nonexistent name probe
+func classifyNXDomain(probe string, l DNSLookupResult) NXDomainCheck {
+	res := NXDomainCheck{Probe: strings.TrimSuffix(probe, "."), Error: l.Error}
+	if l.Error != nil {
+		return res
+	}
+	if l.msg == nil {
+		res.Error = fmt.Errorf("no response")
+		return res
+	}
+	res.Rcode = dns.RcodeToString[l.msg.Rcode]
+	res.Addresses = lookupAddresses(l)
+	res.Hijacked = l.msg.Rcode == dns.RcodeSuccess && len(res.Addresses) > 0
+	return res
+}
+
+// probeNXDomain turns the NXDOMAIN check into a fingerprint line
+func (c *DNSCollector) probeNXDomain(ctx context.Context, server DNSServer) (ResolverCapability, DNSLookupResult) {
+	check, l := c.nxdomain(ctx, server)
+	if check.Probe == "" { // No random name
+		return ResolverCapability{Name: CapNXDomain, State: CapabilityUnknown, Error: check.Error}, l
+	}
+	if verdict, ok := failed(CapNXDomain, l); ok {
+		return verdict, l
+	}
+	switch {
+	case check.Hijacked:
+		return ResolverCapability{Name: CapNXDomain, State: CapabilityNo,
+			Detail: "hijacked: nonexistent names resolve to " + strings.Join(check.Addresses, ", ")}, l
+	case check.Rcode == "NXDOMAIN":
+		return ResolverCapability{Name: CapNXDomain, State: CapabilityYes, Detail: "nonexistent names return NXDOMAIN"}, l
+	default:
+		return ResolverCapability{Name: CapNXDomain, State: CapabilityUnknown,
+			Detail: fmt.Sprintf("nonexistent name returned %s", check.Rcode)}, l
+	}
+}
@@ -0,0 +1,53 @@
+package collector
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestNXDomain(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c := NewDNSCollector()
+
+	// An ISP style resolver sends every unknown name to its search page
+	hijacker := startMockDNS(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		resp := new(dns.Msg)
+		resp.SetReply(r)
+		resp.Answer = append(resp.Answer, mustRR(t, r.Question[0].Name+" 300 IN A 198.51.100.7"))
+		w.WriteMsg(resp)
+	})
+	res, _ := c.nxdomain(ctx, DNSServer{Name: "ISP", Address: hijacker, Proto: ProtoUDP})
+	if res.Error != nil {
+		t.Fatalf("nxdomain() error = %v", res.Error)
+	}
+	if !res.Hijacked || !slices.Equal(res.Addresses, []string{"198.51.100.7"}) {
+		t.Errorf("hijacking resolver: %+v", res)
+	}
+	if !strings.HasPrefix(res.Probe, nxdomainProbePrefix) || !strings.HasSuffix(res.Probe, ".com") {
+		t.Errorf("probe name %q", res.Probe)
+	}
+
+	honest := startMockDNS(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		resp := new(dns.Msg)
+		resp.SetRcode(r, dns.RcodeNameError)
+		w.WriteMsg(resp)
+	})
+	res, _ = c.nxdomain(ctx, DNSServer{Name: "Honest", Address: honest, Proto: ProtoUDP})
+	if res.Hijacked || res.Rcode != "NXDOMAIN" {
+		t.Errorf("honest resolver: %+v", res)
+	}
+}
+
+func TestNXDomainProbeName_Random(t *testing.T) {
+	a, _ := nxdomainProbeName()
+	b, _ := nxdomainProbeName()
+	if a == b {
+		t.Errorf("probe names repeat: %q", a)
+	}
+}
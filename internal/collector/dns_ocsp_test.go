@@ -0,0 +1,149 @@
+package collector
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// testPKI is a CA and a leaf for dns.test issued by it
+type testPKI struct {
+	ca, leaf       *x509.Certificate
+	caKey, leafKey crypto.Signer
+}
+
+func newTestPKI(t *testing.T) testPKI {
+	t.Helper()
+	var p testPKI
+	var err error
+	if p.caKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+		t.Fatal(err)
+	}
+	if p.leafKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, p.caKey.Public(), p.caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.ca, err = x509.ParseCertificate(der); err != nil {
+		t.Fatal(err)
+	}
+	leafTmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "dns.test"},
+		DNSNames:     []string{"dns.test"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if der, err = x509.CreateCertificate(rand.Reader, leafTmpl, p.ca, p.leafKey.Public(), p.caKey); err != nil {
+		t.Fatal(err)
+	}
+	if p.leaf, err = x509.ParseCertificate(der); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// handshake serves the leaf with staple and returns the client's view of the handshake
+func (p testPKI) handshake(t *testing.T, staple []byte) tls.ConnectionState {
+	t.Helper()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{{
+		Certificate: [][]byte{p.leaf.Raw, p.ca.Raw},
+		PrivateKey:  p.leafKey,
+		OCSPStaple:  staple,
+	}}})
+	if err != nil {
+		t.Skipf("cannot listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(p.ca)
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 5 * time.Second}, "tcp", ln.Addr().String(), &tls.Config{RootCAs: roots, ServerName: "dns.test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	return conn.ConnectionState()
+}
+
+func TestGetCertInfo_OCSPStaple(t *testing.T) {
+	p := newTestPKI(t)
+	revokedAt := time.Now().Add(-time.Minute).Truncate(time.Second)
+	tests := []struct {
+		name   string
+		status int
+		want   OCSPStatus
+	}{
+		{"good", ocsp.Good, OCSPGood},
+		{"revoked", ocsp.Revoked, OCSPRevoked},
+		{"unknown", ocsp.Unknown, OCSPUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+			staple, err := ocsp.CreateResponse(p.ca, p.ca, ocsp.Response{
+				Status:       tt.status,
+				SerialNumber: p.leaf.SerialNumber,
+				ThisUpdate:   time.Now().Add(-time.Minute),
+				NextUpdate:   next,
+				RevokedAt:    revokedAt,
+			}, p.caKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			info := getCertInfo(p.handshake(t, staple))
+			if info.OCSPError != nil {
+				t.Fatalf("OCSPError = %v", info.OCSPError)
+			}
+			if info.OCSPStatus != tt.want {
+				t.Errorf("OCSPStatus = %q, want %q", info.OCSPStatus, tt.want)
+			}
+			if !info.OCSPNextUpdate.Equal(next) {
+				t.Errorf("OCSPNextUpdate = %v, want %v", info.OCSPNextUpdate, next)
+			}
+			if tt.want == OCSPRevoked && !info.OCSPRevokedAt.Equal(revokedAt) {
+				t.Errorf("OCSPRevokedAt = %v, want %v", info.OCSPRevokedAt, revokedAt)
+			}
+		})
+	}
+}
+
+func TestGetCertInfo_NoStaple(t *testing.T) {
+	p := newTestPKI(t)
+	info := getCertInfo(p.handshake(t, nil))
+	if info.OCSPStatus != OCSPNone || info.OCSPError != nil {
+		t.Errorf("OCSPStatus = %q (err %v), want none", info.OCSPStatus, info.OCSPError)
+	}
+}
@@ -0,0 +1,108 @@
+package collector
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// SRVRecord is a parsed SRV answer
+type SRVRecord struct {
+	Priority uint16
+	Weight   uint16
+	Port     uint16
+	Target   string // Without the trailing dot
+	Proto    string // Transport from the owner name, e.g. tcp for _sip._tcp.example.com
+}
+
+// SRVCheck is the reachability of one SRV target
+type SRVCheck struct {
+	Record    SRVRecord
+	Reachable bool
+	Latency   time.Duration
+	Error     error
+	// NotApplicable is set for UDP services: a datagram needs the
+	// service's own protocol to get an answer, so the target is not probed
+	NotApplicable bool
+}
+
+// parseSRV extracts SRV records from answers, ordered by priority (lowest first)
+// and weight (highest first)
+func parseSRV(answers []dns.RR) []SRVRecord {
+	var records []SRVRecord
+	for _, rr := range answers {
+		srv, ok := rr.(*dns.SRV)
+		if !ok {
+			continue
+		}
+		records = append(records, SRVRecord{
+			Priority: srv.Priority,
+			Weight:   srv.Weight,
+			Port:     srv.Port,
+			Target:   strings.TrimSuffix(srv.Target, "."),
+			Proto:    srvProto(srv.Hdr.Name),
+		})
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		if records[i].Priority != records[j].Priority {
+			return records[i].Priority < records[j].Priority
+		}
+		return records[i].Weight > records[j].Weight
+	})
+	return records
+}
+
+// srvProto returns the transport label of an SRV owner name, _service._proto.name
+func srvProto(name string) string {
+	labels := dns.SplitDomainName(name)
+	if len(labels) < 2 || !strings.HasPrefix(labels[1], "_") {
+		return ""
+	}
+	return strings.ToLower(strings.TrimPrefix(labels[1], "_"))
+}
+
+// CheckSRVTargets runs a TCP reachability check against every SRV target:port,
+// keeping the priority order. A "." target means the service is not available,
+// the targets of UDP services are marked not applicable.
+func (c *DNSCollector) CheckSRVTargets(ctx context.Context, records []SRVRecord) []SRVCheck {
+	checks := make([]SRVCheck, len(records))
+	var wg sync.WaitGroup
+
+	for i, rec := range records {
+		checks[i].Record = rec
+		if rec.Target == "" {
+			continue // Explicitly "no service" (RFC 2782)
+		}
+		if rec.Proto == "udp" {
+			checks[i].NotApplicable = true
+			continue
+		}
+		if !c.Budget.Allow(1, tcpProbeBytes) {
+			checks[i].Error = ErrBudgetExceeded
+			continue
+		}
+		wg.Add(1)
+		go func(i int, rec SRVRecord) {
+			defer wg.Done()
+			address := net.JoinHostPort(rec.Target, strconv.Itoa(int(rec.Port)))
+			start := time.Now()
+			conn, err := c.dial(ctx, "tcp", address, 0)
+			if err != nil {
+				checks[i].Error = err
+				return
+			}
+			checks[i].Latency = time.Since(start)
+			checks[i].Reachable = true
+			conn.Close()
+		}(i, rec)
+	}
+
+	wg.Wait()
+	return checks
+}
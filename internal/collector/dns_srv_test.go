@@ -0,0 +1,122 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestDNSLookup_SRVFollowUp(t *testing.T) {
+	// The primary target listens, the backup port is closed
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	accepted := make(chan struct{}, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err == nil {
+			conn.Close()
+			accepted <- struct{}{}
+		}
+	}()
+	openPort := l.Addr().(*net.TCPAddr).Port
+	closedPort := freeTCPPort(t)
+
+	addr := startMockDNS(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		resp := new(dns.Msg)
+		resp.SetReply(r)
+		name := r.Question[0].Name
+		for _, rec := range []string{
+			fmt.Sprintf("%s 60 IN SRV 20 0 %d 127.0.0.1.", name, closedPort),
+			fmt.Sprintf("%s 60 IN SRV 10 5 %d 127.0.0.1.", name, openPort),
+		} {
+			rr, _ := dns.NewRR(rec)
+			resp.Answer = append(resp.Answer, rr)
+		}
+		w.WriteMsg(resp)
+	})
+
+	c := NewDNSCollector()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res := c.Lookup(ctx, "_sip._tcp.example.com", RecordSRV, DNSServer{Name: "Mock", Address: addr, Proto: ProtoUDP})
+	if res.Error != nil {
+		t.Fatalf("SRV lookup failed: %v", res.Error)
+	}
+	if len(res.SRV) != 2 {
+		t.Fatalf("expected 2 SRV records, got %+v", res.SRV)
+	}
+	want := SRVRecord{Priority: 10, Weight: 5, Port: uint16(openPort), Target: "127.0.0.1", Proto: "tcp"}
+	if res.SRV[0] != want {
+		t.Errorf("highest priority record = %+v, want %+v", res.SRV[0], want)
+	}
+
+	checks := c.CheckSRVTargets(ctx, res.SRV)
+	if len(checks) != 2 {
+		t.Fatalf("expected 2 checks, got %d", len(checks))
+	}
+	if !checks[0].Reachable || checks[0].Error != nil {
+		t.Errorf("primary target should be reachable: %+v", checks[0])
+	}
+	if checks[1].Reachable || checks[1].Error == nil {
+		t.Errorf("backup target should be unreachable: %+v", checks[1])
+	}
+
+	select {
+	case <-accepted:
+	case <-time.After(time.Second):
+		t.Error("follow-up check never connected to the SRV target")
+	}
+}
+
+func TestParseSRV_NoService(t *testing.T) {
+	rr, _ := dns.NewRR("_x._tcp.example.com. 60 IN SRV 0 0 0 .")
+	records := parseSRV([]dns.RR{rr})
+	if len(records) != 1 || records[0].Target != "" {
+		t.Fatalf("unexpected records: %+v", records)
+	}
+
+	c := NewDNSCollector()
+	checks := c.CheckSRVTargets(context.Background(), records)
+	if checks[0].Reachable || checks[0].Error != nil {
+		t.Errorf("'.' target should not be dialed: %+v", checks[0])
+	}
+}
+
+func TestCheckSRVTargets_UDPNotApplicable(t *testing.T) {
+	rr, _ := dns.NewRR("_sip._udp.example.com. 60 IN SRV 10 5 5060 127.0.0.1.")
+	records := parseSRV([]dns.RR{rr})
+	if len(records) != 1 || records[0].Proto != "udp" {
+		t.Fatalf("unexpected records: %+v", records)
+	}
+
+	c := NewDNSCollector()
+	var dialed bool
+	c.dial = func(ctx context.Context, network, address string, sourcePort int) (net.Conn, error) {
+		dialed = true
+		return nil, fmt.Errorf("unexpected dial to %s", address)
+	}
+	checks := c.CheckSRVTargets(context.Background(), records)
+	if dialed || !checks[0].NotApplicable || checks[0].Error != nil {
+		t.Errorf("a UDP service should not be probed over TCP: %+v", checks[0])
+	}
+}
+
+// freeTCPPort returns a local TCP port with nothing listening on it
+func freeTCPPort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+	return port
+}
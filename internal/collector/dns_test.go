@@ -2,9 +2,22 @@ package collector
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
 )
 
 func TestDNSLookup_A(t *testing.T) {
@@ -141,3 +154,617 @@ func TestIsIP(t *testing.T) {
 		t.Error("google.com should not be IP")
 	}
 }
+
+// startMockDNS serves handler over both UDP and TCP on the same local port
+func startMockDNS(t *testing.T, handler dns.HandlerFunc) string {
+	t.Helper()
+	return startMockDNSOn(t, "127.0.0.1", handler)
+}
+
+// startMockDNSOn is startMockDNS bound to a specific loopback address
+func startMockDNSOn(t *testing.T, host string, handler dns.HandlerFunc) string {
+	t.Helper()
+
+	for attempt := 0; attempt < 5; attempt++ {
+		l, err := net.Listen("tcp", net.JoinHostPort(host, "0"))
+		if err != nil {
+			t.Skipf("cannot listen on %s: %v", host, err)
+		}
+		pc, err := net.ListenPacket("udp", l.Addr().String())
+		if err != nil {
+			l.Close()
+			continue
+		}
+
+		for _, srv := range []*dns.Server{
+			{PacketConn: pc, Handler: handler},
+			{Listener: l, Handler: handler},
+		} {
+			started := make(chan struct{})
+			srv.NotifyStartedFunc = func() { close(started) }
+			go srv.ActivateAndServe()
+			<-started
+			t.Cleanup(func() { srv.Shutdown() })
+		}
+		return l.Addr().String()
+	}
+
+	t.Fatal("could not bind mock DNS server")
+	return ""
+}
+
+// answerA replies to every query with a single A record
+func answerA(ip string) dns.HandlerFunc {
+	return func(w dns.ResponseWriter, r *dns.Msg) {
+		resp := new(dns.Msg)
+		resp.SetReply(r)
+		rr, _ := dns.NewRR(fmt.Sprintf("%s 60 IN A %s", r.Question[0].Name, ip))
+		resp.Answer = append(resp.Answer, rr)
+		w.WriteMsg(resp)
+	}
+}
+
+func freeUDPPort(t *testing.T) int {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	return pc.LocalAddr().(*net.UDPAddr).Port
+}
+
+func TestDNSDialer_SourcePort(t *testing.T) {
+	udp := dnsDialer("udp", 40053, time.Second)
+	if addr, ok := udp.LocalAddr.(*net.UDPAddr); !ok || addr.Port != 40053 {
+		t.Errorf("udp LocalAddr = %v, want port 40053", udp.LocalAddr)
+	}
+	tcp := dnsDialer("tcp", 40053, time.Second)
+	if addr, ok := tcp.LocalAddr.(*net.TCPAddr); !ok || addr.Port != 40053 {
+		t.Errorf("tcp LocalAddr = %v, want port 40053", tcp.LocalAddr)
+	}
+	if d := dnsDialer("udp", 0, time.Second); d.LocalAddr != nil {
+		t.Errorf("expected ephemeral source port, got %v", d.LocalAddr)
+	}
+}
+
+func TestDNSLookup_SourcePort(t *testing.T) {
+	addr := startMockDNS(t, answerA("192.0.2.1"))
+	port := freeUDPPort(t)
+
+	c := NewDNSCollector()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res := c.Lookup(ctx, "example.com", RecordA, DNSServer{Name: "Mock", Address: addr, Proto: ProtoUDP, SourcePort: port})
+	if res.Error != nil {
+		t.Fatalf("Lookup failed: %v", res.Error)
+	}
+	if res.SourcePort != port {
+		t.Errorf("SourcePort = %d, want %d", res.SourcePort, port)
+	}
+
+	// Hold the port so the next bind fails
+	pc, err := net.ListenPacket("udp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		t.Skipf("cannot hold port %d: %v", port, err)
+	}
+	defer pc.Close()
+
+	res = c.Lookup(ctx, "example.com", RecordA, DNSServer{Name: "Mock", Address: addr, Proto: ProtoUDP, SourcePort: port})
+	if res.Error == nil || !strings.Contains(res.Error.Error(), "already in use") {
+		t.Errorf("expected port-in-use error, got %v", res.Error)
+	}
+}
+
+func TestDNSLookup_ResponseSize(t *testing.T) {
+	var packedLen atomic.Int32
+	var compress atomic.Bool
+	addr := startMockDNS(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		resp := new(dns.Msg)
+		resp.SetReply(r)
+		resp.Compress = compress.Load()
+		for _, ip := range []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"} {
+			rr, _ := dns.NewRR(fmt.Sprintf("%s 60 IN A %s", r.Question[0].Name, ip))
+			resp.Answer = append(resp.Answer, rr)
+		}
+		packed, _ := resp.Pack()
+		packedLen.Store(int32(len(packed)))
+		w.WriteMsg(resp)
+	})
+
+	c := NewDNSCollector()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for _, proto := range []DNSProtocol{ProtoUDP, ProtoTCP} {
+		for _, on := range []bool{true, false} {
+			compress.Store(on)
+			res := c.Lookup(ctx, "example.com", RecordA, DNSServer{Name: "Mock", Address: addr, Proto: proto})
+			if res.Error != nil {
+				t.Fatalf("%s lookup failed: %v", proto, res.Error)
+			}
+			if want := int(packedLen.Load()); res.ResponseSize != want {
+				t.Errorf("%s compress=%v: ResponseSize = %d, want the packed length %d", proto, on, res.ResponseSize, want)
+			}
+			if res.Compressed != on {
+				t.Errorf("%s: Compressed = %v, want %v", proto, res.Compressed, on)
+			}
+		}
+	}
+}
+
+func TestBuildQuery_Cookie(t *testing.T) {
+	msg, err := buildQuery("example.com", RecordA, DNSQueryOptions{Cookie: true})
+	if err != nil {
+		t.Fatalf("buildQuery() error = %v", err)
+	}
+	opt := msg.IsEdns0()
+	if opt == nil {
+		t.Fatal("expected OPT record when cookie is requested")
+	}
+	var cookie *dns.EDNS0_COOKIE
+	for _, o := range opt.Option {
+		if c, ok := o.(*dns.EDNS0_COOKIE); ok {
+			cookie = c
+		}
+	}
+	if cookie == nil || len(cookie.Cookie) != 16 {
+		t.Fatalf("expected 8-byte client cookie, got %+v", cookie)
+	}
+
+	plain, _ := buildQuery("example.com", RecordA, DNSQueryOptions{})
+	if plain.IsEdns0() != nil {
+		t.Error("expected no OPT record without options")
+	}
+}
+
+func TestBuildQuery_EDNS(t *testing.T) {
+	msg, err := buildQuery("example.com", RecordTXT, DNSQueryOptions{UDPSize: 1232, DNSSECRequested: true})
+	if err != nil {
+		t.Fatalf("buildQuery() error = %v", err)
+	}
+	opt := msg.IsEdns0()
+	if opt == nil || opt.UDPSize() != 1232 || !opt.Do() {
+		t.Fatalf("OPT = %v, want size 1232 with DO", opt)
+	}
+
+	// DNSSEC alone advertises the default size
+	msg, _ = buildQuery("example.com", RecordA, DNSQueryOptions{DNSSECRequested: true})
+	if opt := msg.IsEdns0(); opt == nil || opt.UDPSize() != defaultEDNSBufSize || !opt.Do() {
+		t.Errorf("OPT = %v, want default size with DO", opt)
+	}
+}
+
+func TestDNSLookup_TruncatedRetriesTCP(t *testing.T) {
+	addr := startMockDNS(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		resp := new(dns.Msg)
+		resp.SetReply(r)
+		if opt := r.IsEdns0(); opt != nil && opt.Do() {
+			resp.AuthenticatedData = true
+		}
+		if w.RemoteAddr().Network() == "udp" {
+			resp.Truncated = true
+		} else {
+			resp.Answer = append(resp.Answer, mustRR(t, "example.com. 60 IN TXT \"long\""))
+		}
+		w.WriteMsg(resp)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	res := NewDNSCollector().LookupWithOptions(ctx, "example.com", RecordTXT, DNSServer{Name: "Mock", Address: addr, Proto: ProtoUDP}, DNSQueryOptions{DNSSECRequested: true})
+	if res.Error != nil {
+		t.Fatalf("Lookup failed: %v", res.Error)
+	}
+	if res.Protocol != ProtoTCP || len(res.Records) != 1 {
+		t.Errorf("Protocol = %s, Records = %v, want the TCP answer", res.Protocol, res.Records)
+	}
+	if !strings.Contains(res.Fallback, "truncated") {
+		t.Errorf("Fallback = %q, want the truncation noted", res.Fallback)
+	}
+	if !res.DNSSECRequested || !res.Authenticated {
+		t.Errorf("DNSSECRequested = %v, Authenticated = %v, want both", res.DNSSECRequested, res.Authenticated)
+	}
+}
+
+func TestDNSLookup_Cookie(t *testing.T) {
+	const serverCookie = "0102030405060708090a0b0c0d0e0f10"
+	var mu sync.Mutex
+	var sentCookie string
+
+	addr := startMockDNS(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		resp := new(dns.Msg)
+		resp.SetReply(r)
+		if opt := r.IsEdns0(); opt != nil {
+			for _, o := range opt.Option {
+				if c, ok := o.(*dns.EDNS0_COOKIE); ok {
+					mu.Lock()
+					sentCookie = c.Cookie
+					mu.Unlock()
+					resp.SetEdns0(4096, false)
+					respOpt := resp.IsEdns0()
+					respOpt.Option = append(respOpt.Option, &dns.EDNS0_COOKIE{Code: dns.EDNS0COOKIE, Cookie: c.Cookie + serverCookie})
+				}
+			}
+		}
+		w.WriteMsg(resp)
+	})
+
+	c := NewDNSCollector()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res := c.LookupWithOptions(ctx, "example.com", RecordA, DNSServer{Name: "Mock", Address: addr, Proto: ProtoUDP}, DNSQueryOptions{Cookie: true})
+	if res.Error != nil {
+		t.Fatalf("Lookup failed: %v", res.Error)
+	}
+	mu.Lock()
+	sent := sentCookie
+	mu.Unlock()
+	if !res.CookieSent || sent == "" {
+		t.Fatal("expected client cookie to be sent")
+	}
+	if res.Cookie == nil {
+		t.Fatal("expected cookie in result")
+	}
+	if res.Cookie.Client != sent {
+		t.Errorf("client cookie = %s, want %s", res.Cookie.Client, sent)
+	}
+	if res.Cookie.Server != serverCookie {
+		t.Errorf("server cookie = %s, want %s", res.Cookie.Server, serverCookie)
+	}
+
+	// A server without cookie support returns no OPT
+	plainAddr := startMockDNS(t, answerA("192.0.2.1"))
+	res = c.LookupWithOptions(ctx, "example.com", RecordA, DNSServer{Name: "Mock", Address: plainAddr, Proto: ProtoUDP}, DNSQueryOptions{Cookie: true})
+	if res.Error != nil {
+		t.Fatalf("Lookup failed: %v", res.Error)
+	}
+	if res.Cookie != nil && res.Cookie.Server != "" {
+		t.Errorf("expected no server cookie, got %+v", res.Cookie)
+	}
+}
+
+// dohHandler answers DoH POST requests with a single A record
+func dohHandler(ip string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		query := new(dns.Msg)
+		if err := query.Unpack(body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp := new(dns.Msg)
+		resp.SetReply(query)
+		rr, _ := dns.NewRR(fmt.Sprintf("%s 60 IN A %s", query.Question[0].Name, ip))
+		resp.Answer = append(resp.Answer, rr)
+		packed, _ := resp.Pack()
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(packed)
+	})
+}
+
+func TestDNSLookup_DoH3(t *testing.T) {
+	// Reuse the httptest certificate (valid for 127.0.0.1) for the QUIC listener
+	tlsSrv := httptest.NewTLSServer(dohHandler("192.0.2.2"))
+	defer tlsSrv.Close()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h3 := &http3.Server{
+		Handler:   dohHandler("192.0.2.3"),
+		TLSConfig: http3.ConfigureTLSConfig(&tls.Config{Certificates: tlsSrv.TLS.Certificates}),
+	}
+	go h3.Serve(pc)
+	defer h3.Close()
+
+	c := NewDNSCollector()
+	c.rootCAs = x509.NewCertPool()
+	c.rootCAs.AddCert(tlsSrv.Certificate())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	server := DNSServer{Name: "Mock", Address: fmt.Sprintf("https://%s/dns-query", pc.LocalAddr()), Proto: ProtoDoH3}
+	res := c.Lookup(ctx, "example.com", RecordA, server)
+	if res.Error != nil {
+		t.Fatalf("DoH3 Lookup failed: %v", res.Error)
+	}
+	if res.ALPN != "h3" {
+		t.Errorf("ALPN = %q, want h3", res.ALPN)
+	}
+	if res.Protocol != ProtoDoH3 {
+		t.Errorf("Protocol = %s, want DoH3", res.Protocol)
+	}
+	if len(res.Records) != 1 || !strings.Contains(res.Records[0], "192.0.2.3") {
+		t.Errorf("unexpected records: %v", res.Records)
+	}
+	if res.CertInfo == nil {
+		t.Error("expected CertInfo from the QUIC handshake")
+	}
+}
+
+func TestDNSLookup_DoH3Fallback(t *testing.T) {
+	// Only a TCP (HTTP/2) server: the HTTP/3 attempt fails and falls back
+	tlsSrv := httptest.NewUnstartedServer(dohHandler("192.0.2.2"))
+	tlsSrv.EnableHTTP2 = true
+	tlsSrv.StartTLS()
+	defer tlsSrv.Close()
+
+	c := NewDNSCollector()
+	c.rootCAs = x509.NewCertPool()
+	c.rootCAs.AddCert(tlsSrv.Certificate())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	server := DNSServer{Name: "Mock", Address: tlsSrv.URL + "/dns-query", Proto: ProtoDoH3, H3Fallback: true}
+	res := c.Lookup(ctx, "example.com", RecordA, server)
+	if res.Error != nil {
+		t.Fatalf("Lookup with fallback failed: %v", res.Error)
+	}
+	if res.Fallback == "" {
+		t.Error("expected fallback to be reported")
+	}
+	if res.Protocol != ProtoDoH || res.ALPN != "h2" {
+		t.Errorf("fallback protocol = %s/%s, want DoH/h2", res.Protocol, res.ALPN)
+	}
+}
+
+// startMockDoQ serves DoQ with the httptest certificate, valid for
+// 127.0.0.1, offering alpn. Queries with an ID other than 0 are refused.
+func startMockDoQ(t *testing.T, alpn string, handler dns.HandlerFunc) (string, *x509.Certificate) {
+	t.Helper()
+	tlsSrv := httptest.NewTLSServer(http.NotFoundHandler())
+	t.Cleanup(tlsSrv.Close)
+
+	ln, err := quic.ListenAddr("127.0.0.1:0", &tls.Config{Certificates: tlsSrv.TLS.Certificates, NextProtos: []string{alpn}}, nil)
+	if err != nil {
+		t.Skipf("cannot listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept(context.Background())
+			if err != nil {
+				return
+			}
+			go func() {
+				stream, err := conn.AcceptStream(context.Background())
+				if err != nil {
+					return
+				}
+				defer stream.Close()
+				var prefix [2]byte
+				if _, err := io.ReadFull(stream, prefix[:]); err != nil {
+					return
+				}
+				wire := make([]byte, int(prefix[0])<<8|int(prefix[1]))
+				if _, err := io.ReadFull(stream, wire); err != nil {
+					return
+				}
+				req := new(dns.Msg)
+				if req.Unpack(wire) != nil || req.Id != 0 {
+					return
+				}
+				w := &doqWriter{}
+				handler(w, req)
+				packed, _ := w.msg.Pack()
+				stream.Write(append([]byte{byte(len(packed) >> 8), byte(len(packed))}, packed...))
+			}()
+		}
+	}()
+	return ln.Addr().String(), tlsSrv.Certificate()
+}
+
+// doqWriter captures the reply of a dns.HandlerFunc
+type doqWriter struct {
+	dns.ResponseWriter
+	msg *dns.Msg
+}
+
+func (w *doqWriter) WriteMsg(m *dns.Msg) error {
+	w.msg = m
+	return nil
+}
+
+func TestDNSLookup_DoQ(t *testing.T) {
+	addr, cert := startMockDoQ(t, "doq", answerA("192.0.2.4"))
+	c := NewDNSCollector()
+	c.rootCAs = x509.NewCertPool()
+	c.rootCAs.AddCert(cert)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	res := c.Lookup(ctx, "example.com", RecordA, DNSServer{Name: "Mock", Address: addr, Proto: ProtoDoQ})
+	if res.Error != nil {
+		t.Fatalf("DoQ Lookup failed: %v", res.Error)
+	}
+	if res.Protocol != ProtoDoQ || res.ALPN != "doq" {
+		t.Errorf("protocol = %s/%s, want DoQ/doq", res.Protocol, res.ALPN)
+	}
+	if len(res.Records) != 1 || !strings.Contains(res.Records[0], "192.0.2.4") {
+		t.Errorf("unexpected records: %v", res.Records)
+	}
+	if res.CertInfo == nil {
+		t.Error("expected CertInfo from the QUIC handshake")
+	}
+
+	// A QUIC server for another protocol is reported as not speaking DoQ
+	addr, cert = startMockDoQ(t, "h3", answerA("192.0.2.4"))
+	c.rootCAs.AddCert(cert)
+	res = c.Lookup(ctx, "example.com", RecordA, DNSServer{Name: "Mock", Address: addr, Proto: ProtoDoQ})
+	if res.Error == nil || !strings.Contains(res.Error.Error(), "does not speak DoQ") {
+		t.Errorf("ALPN mismatch error = %v", res.Error)
+	}
+}
+
+func TestFamilyNetwork(t *testing.T) {
+	tests := []struct {
+		network string
+		family  DNSFamily
+		want    string
+	}{
+		{"udp", FamilyAuto, "udp"},
+		{"udp", "", "udp"},
+		{"udp", FamilyIPv4, "udp4"},
+		{"tcp", FamilyIPv6, "tcp6"},
+	}
+	for _, tt := range tests {
+		if got := familyNetwork(tt.network, tt.family); got != tt.want {
+			t.Errorf("familyNetwork(%q, %q) = %q, want %q", tt.network, tt.family, got, tt.want)
+		}
+	}
+}
+
+func TestDNSLookup_Family(t *testing.T) {
+	v4 := startMockDNSOn(t, "127.0.0.1", answerA("192.0.2.4"))
+	v6 := startMockDNSOn(t, "::1", answerA("192.0.2.6"))
+
+	// The custom dialer stands in for a resolver name with both A and AAAA records
+	var dialed []string
+	c := NewDNSCollector()
+	c.dial = func(ctx context.Context, network, address string, sourcePort int) (net.Conn, error) {
+		dialed = append(dialed, network)
+		target := v4
+		if strings.HasSuffix(network, "6") {
+			target = v6
+		}
+		var d net.Dialer
+		return d.DialContext(ctx, network, target)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tests := []struct {
+		family      DNSFamily
+		proto       DNSProtocol
+		wantNetwork string
+		wantFamily  string
+		wantRecord  string
+	}{
+		{FamilyIPv4, ProtoUDP, "udp4", "IPv4", "192.0.2.4"},
+		{FamilyIPv6, ProtoUDP, "udp6", "IPv6", "192.0.2.6"},
+		{FamilyIPv6, ProtoTCP, "tcp6", "IPv6", "192.0.2.6"},
+		{FamilyAuto, ProtoUDP, "udp", "IPv4", "192.0.2.4"},
+	}
+	for _, tt := range tests {
+		dialed = nil
+		server := DNSServer{Name: "Dual", Address: "dual.example:53", Proto: tt.proto, Family: tt.family}
+		res := c.Lookup(ctx, "example.com", RecordA, server)
+		if res.Error != nil {
+			t.Fatalf("%s/%s: lookup failed: %v", tt.family, tt.proto, res.Error)
+		}
+		if len(dialed) != 1 || dialed[0] != tt.wantNetwork {
+			t.Errorf("%s/%s: dialed %v, want %s", tt.family, tt.proto, dialed, tt.wantNetwork)
+		}
+		if res.Family != tt.wantFamily {
+			t.Errorf("%s/%s: Family = %q, want %q", tt.family, tt.proto, res.Family, tt.wantFamily)
+		}
+		if len(res.Records) != 1 || !strings.Contains(res.Records[0], tt.wantRecord) {
+			t.Errorf("%s/%s: records = %v, want %s", tt.family, tt.proto, res.Records, tt.wantRecord)
+		}
+	}
+}
+
+func TestDNSLookup_AuthorityAdditional(t *testing.T) {
+	// A referral: no answer, NS records in authority and glue in additional
+	addr := startMockDNS(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		resp := new(dns.Msg)
+		resp.SetReply(r)
+		resp.RecursionAvailable = false
+		ns, _ := dns.NewRR("example.com. 3600 IN NS ns1.example.com.")
+		glue, _ := dns.NewRR("ns1.example.com. 3600 IN A 192.0.2.53")
+		resp.Ns = append(resp.Ns, ns)
+		resp.Extra = append(resp.Extra, glue)
+		resp.SetEdns0(1232, false)
+		w.WriteMsg(resp)
+	})
+
+	c := NewDNSCollector()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res := c.Lookup(ctx, "www.example.com", RecordA, DNSServer{Name: "Mock", Address: addr, Proto: ProtoUDP})
+	if res.Error != nil {
+		t.Fatalf("Lookup failed: %v", res.Error)
+	}
+	if len(res.Question) != 1 || !strings.HasPrefix(res.Question[0], "www.example.com.") {
+		t.Errorf("question = %v", res.Question)
+	}
+	if len(res.Records) != 0 {
+		t.Errorf("expected empty answer section, got %v", res.Records)
+	}
+	if len(res.Authority) != 1 || !strings.Contains(res.Authority[0], "NS ns1.example.com.") {
+		t.Errorf("authority = %v", res.Authority)
+	}
+	// The OPT pseudo-record is not listed as additional data
+	if len(res.Additional) != 1 || !strings.Contains(res.Additional[0], "A 192.0.2.53") {
+		t.Errorf("additional = %v", res.Additional)
+	}
+	if strings.Join(res.Flags, " ") != "qr rd" {
+		t.Errorf("flags = %v, want [qr rd]", res.Flags)
+	}
+}
+
+func TestNormalizeDNSServerAddress(t *testing.T) {
+	tests := []struct {
+		proto   DNSProtocol
+		address string
+		want    string
+		wantErr bool
+	}{
+		{ProtoUDP, "9.9.9.9", "9.9.9.9:53", false},
+		{ProtoTCP, " 9.9.9.9:5353 ", "9.9.9.9:5353", false},
+		{ProtoUDP, "2620:fe::fe", "[2620:fe::fe]:53", false},
+		{ProtoUDP, "[2620:fe::fe]:53", "[2620:fe::fe]:53", false},
+		{ProtoUDP, "dns.example.net", "dns.example.net:53", false},
+		{ProtoDoT, "dns.quad9.net", "dns.quad9.net:853", false},
+		{ProtoDoT, "1.1.1.1:853", "1.1.1.1:853", false},
+		{ProtoDoH, "https://dns.google", "https://dns.google/dns-query", false},
+		{ProtoDoH3, "https://cloudflare-dns.com/dns-query", "https://cloudflare-dns.com/dns-query", false},
+
+		{ProtoUDP, "", "", true},
+		{ProtoUDP, "9.9.9.9:0", "", true},
+		{ProtoTCP, "9.9.9.9:dns", "", true},
+		{ProtoUDP, "https://dns.google", "", true},
+		{ProtoDoT, "bad host", "", true},
+		{ProtoDoH, "dns.google", "", true},
+		{ProtoDoH, "http://dns.google/dns-query", "", true},
+		{ProtoDoQ, "dns.adguard.com", "dns.adguard.com:853", false},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeDNSServerAddress(tt.proto, tt.address)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("NormalizeDNSServerAddress(%s, %q) error = %v, wantErr %v", tt.proto, tt.address, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("NormalizeDNSServerAddress(%s, %q) = %q, want %q", tt.proto, tt.address, got, tt.want)
+		}
+	}
+}
+
+func TestDNSCollector_ListenUDPUsesSource(t *testing.T) {
+	c := NewDNSCollector()
+	c.Source = &SourceInterface{}
+	if err := c.Source.setAddrs("lo", []net.Addr{ipNet("127.0.0.1/8")}); err != nil {
+		t.Fatal(err)
+	}
+	pc, err := c.listenUDP("udp4", "127.0.0.53:853")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	if got := pc.LocalAddr().(*net.UDPAddr).IP; !got.Equal(net.ParseIP("127.0.0.1")) {
+		t.Errorf("QUIC socket bound to %v, want the source address 127.0.0.1", got)
+	}
+}
@@ -0,0 +1,224 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// maxTraceSteps bounds the queries of one trace, referral loops included
+const maxTraceSteps = 30
+
+// maxTraceServers is how many nameservers of a zone are tried before the
+// trace gives up on it
+const maxTraceServers = 3
+
+// traceServer is a nameserver a trace can query
+type traceServer struct {
+	Name    string
+	Address string // host:port
+}
+
+// rootHints are the IPv4 addresses of the root servers, where a trace starts
+var rootHints = []traceServer{
+	{"a.root-servers.net", "198.41.0.4:53"},
+	{"b.root-servers.net", "170.247.170.2:53"},
+	{"c.root-servers.net", "192.33.4.12:53"},
+	{"d.root-servers.net", "199.7.91.13:53"},
+	{"e.root-servers.net", "192.203.230.10:53"},
+	{"f.root-servers.net", "192.5.5.241:53"},
+	{"g.root-servers.net", "192.112.36.4:53"},
+	{"h.root-servers.net", "198.97.190.53:53"},
+	{"i.root-servers.net", "192.36.148.17:53"},
+	{"j.root-servers.net", "192.58.128.30:53"},
+	{"k.root-servers.net", "193.0.14.129:53"},
+	{"l.root-servers.net", "199.7.83.42:53"},
+	{"m.root-servers.net", "202.12.27.33:53"},
+}
+
+// DNSTraceStep is one query of an iterative resolution. A referral names
+// the nameservers of the next zone down; the last step holds the answer.
+type DNSTraceStep struct {
+	Zone     string // Zone the queried server serves, "." for the root
+	Server   string // Nameserver queried
+	Address  string // Address it was queried at
+	Latency  time.Duration
+	Rcode    string
+	Referral string   // Zone delegated to, empty unless this step is a referral
+	NS       []string // Nameservers of the delegated zone
+	Glue     []string // Addresses of those nameservers given along, "ns1.example.com 192.0.2.1"
+	Answer   []string // Records of the final answer
+	Error    error    // The server did not answer, the next one of the zone is tried
+}
+
+// DNSTraceResult is a trace of one name as shown and reported
+type DNSTraceResult struct {
+	Domain string
+	Type   DNSRecordType
+	Steps  []DNSTraceStep
+	Error  error // Why the trace ended before an answer
+}
+
+// Trace resolves domain iteratively from the root servers, without
+// recursion, following each referral to the zone's authoritative servers.
+// The steps so far are returned along with the error that ended a trace
+// early.
+func (c *DNSCollector) Trace(ctx context.Context, domain string, recordType DNSRecordType) ([]DNSTraceStep, error) {
+	msg, err := buildQuery(domain, recordType, DNSQueryOptions{})
+	if err != nil {
+		return nil, err
+	}
+	msg.RecursionDesired = false
+	qname := msg.Question[0].Name
+
+	zone, servers := ".", c.roots
+	if servers == nil {
+		servers = rootHints
+	}
+	var steps []DNSTraceStep
+	for len(steps) < maxTraceSteps {
+		step, r := c.queryZone(ctx, msg, zone, servers, &steps)
+		if r == nil {
+			return steps, fmt.Errorf("no nameserver of %s answered: %w", zoneName(zone), step.Error)
+		}
+
+		next, ns := referral(r, zone, qname)
+		if r.Rcode != dns.RcodeSuccess || len(r.Answer) > 0 || next == "" {
+			for _, rr := range r.Answer {
+				step.Answer = append(step.Answer, strings.ReplaceAll(rr.String(), "\t", " "))
+			}
+			steps = append(steps, step)
+			if r.Rcode == dns.RcodeSuccess && len(r.Answer) == 0 && !hasSOA(r.Ns) && len(ns) > 0 {
+				return steps, fmt.Errorf("%s returned a referral back up to %s (lame delegation)", step.Server, zoneName(ns[0].Hdr.Name))
+			}
+			return steps, nil
+		}
+
+		step.Referral = zoneName(next)
+		servers = nil
+		glue := glueAddresses(r)
+		for _, rr := range ns {
+			name := rr.Ns
+			step.NS = append(step.NS, strings.TrimSuffix(name, "."))
+			for _, ip := range glue[strings.ToLower(name)] {
+				step.Glue = append(step.Glue, strings.TrimSuffix(name, ".")+" "+ip)
+				servers = append(servers, traceServer{Name: strings.TrimSuffix(name, "."), Address: net.JoinHostPort(ip, "53")})
+			}
+		}
+		steps = append(steps, step)
+		if len(servers) == 0 {
+			// Glueless delegation, the nameserver names live in another zone
+			servers = c.resolveNameservers(ctx, step.NS)
+			if len(servers) == 0 {
+				return steps, fmt.Errorf("no address for any nameserver of %s", step.Referral)
+			}
+		}
+		zone = next
+	}
+	return steps, fmt.Errorf("gave up after %d queries", maxTraceSteps)
+}
+
+// queryZone asks the servers of zone in turn until one answers. Servers
+// that fail are recorded in steps; the answering server's step is
+// returned to be completed by the caller, with a nil message if none did.
+func (c *DNSCollector) queryZone(ctx context.Context, msg *dns.Msg, zone string, servers []traceServer, steps *[]DNSTraceStep) (DNSTraceStep, *dns.Msg) {
+	var step DNSTraceStep
+	for i, s := range servers {
+		if i == maxTraceServers || ctx.Err() != nil {
+			break
+		}
+		server := DNSServer{Name: s.Name, Address: s.Address, Proto: ProtoUDP}
+		res := c.retryTruncated(ctx, msg, server, c.exchange(ctx, msg, server))
+		step = DNSTraceStep{Zone: zoneName(zone), Server: s.Name, Address: s.Address, Latency: res.Latency, Rcode: res.ResponseCode, Error: res.Error}
+		if res.Error == nil && res.msg != nil {
+			return step, res.msg
+		}
+		*steps = append(*steps, step)
+	}
+	if step.Error == nil {
+		step.Error = ctx.Err()
+	}
+	return step, nil
+}
+
+// referral returns the zone a response delegates qname to and its NS
+// records, or "" if it is not a referral to a zone below current
+func referral(r *dns.Msg, current, qname string) (string, []*dns.NS) {
+	var zone string
+	var ns []*dns.NS
+	for _, rr := range r.Ns {
+		rec, ok := rr.(*dns.NS)
+		if !ok {
+			continue
+		}
+		if zone == "" {
+			zone = rec.Hdr.Name
+		}
+		if strings.EqualFold(rec.Hdr.Name, zone) {
+			ns = append(ns, rec)
+		}
+	}
+	if zone == "" {
+		return "", nil
+	}
+	// Only a step down towards qname is progress
+	if !dns.IsSubDomain(zone, qname) || dns.CountLabel(zone) <= dns.CountLabel(current) || !dns.IsSubDomain(current, zone) {
+		return "", ns
+	}
+	return zone, ns
+}
+
+// glueAddresses maps nameserver names to the IPv4 addresses in the
+// additional section
+func glueAddresses(r *dns.Msg) map[string][]string {
+	glue := make(map[string][]string)
+	for _, rr := range r.Extra {
+		if a, ok := rr.(*dns.A); ok {
+			name := strings.ToLower(a.Hdr.Name)
+			glue[name] = append(glue[name], a.A.String())
+		}
+	}
+	return glue
+}
+
+// resolveNameservers looks up the addresses of glueless nameservers
+// through the system resolver
+func (c *DNSCollector) resolveNameservers(ctx context.Context, names []string) []traceServer {
+	var servers []traceServer
+	for _, name := range names {
+		res := c.Lookup(ctx, name, RecordA, DNSServer{Name: "System", Proto: ProtoUDP})
+		if res.msg == nil {
+			continue
+		}
+		for _, rr := range res.msg.Answer {
+			if a, ok := rr.(*dns.A); ok {
+				servers = append(servers, traceServer{Name: name, Address: net.JoinHostPort(a.A.String(), "53")})
+			}
+		}
+		if len(servers) >= maxTraceServers {
+			break
+		}
+	}
+	return servers
+}
+
+func hasSOA(rrs []dns.RR) bool {
+	for _, rr := range rrs {
+		if _, ok := rr.(*dns.SOA); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// zoneName shows a zone without its trailing dot, the root as "."
+func zoneName(zone string) string {
+	if zone == "." {
+		return zone
+	}
+	return strings.TrimSuffix(zone, ".")
+}
@@ -0,0 +1,106 @@
+package collector
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// delegate answers every query with a referral to zone, glue included
+func delegate(zone, ns, glue string) dns.HandlerFunc {
+	return func(w dns.ResponseWriter, r *dns.Msg) {
+		resp := new(dns.Msg)
+		resp.SetReply(r)
+		nsRR, _ := dns.NewRR(zone + " 3600 IN NS " + ns)
+		glueRR, _ := dns.NewRR(ns + " 3600 IN A " + glue)
+		resp.Ns = append(resp.Ns, nsRR)
+		resp.Extra = append(resp.Extra, glueRR)
+		w.WriteMsg(resp)
+	}
+}
+
+// traceCollector sends queries for the glue addresses in servers to the
+// mock listening for them
+func traceCollector(root string, servers map[string]string) *DNSCollector {
+	c := NewDNSCollector()
+	c.roots = []traceServer{{Name: "a.root-servers.test", Address: root}}
+	c.dial = func(ctx context.Context, network, address string, sourcePort int) (net.Conn, error) {
+		if mock, ok := servers[address]; ok {
+			address = mock
+		}
+		var d net.Dialer
+		return d.DialContext(ctx, network, address)
+	}
+	return c
+}
+
+func TestDNSTrace(t *testing.T) {
+	var recursion atomic.Bool
+	auth := startMockDNS(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		if r.RecursionDesired {
+			recursion.Store(true)
+		}
+		resp := new(dns.Msg)
+		resp.SetReply(r)
+		resp.Authoritative = true
+		rr, _ := dns.NewRR(r.Question[0].Name + " 300 IN A 192.0.2.80")
+		resp.Answer = append(resp.Answer, rr)
+		w.WriteMsg(resp)
+	})
+	tld := startMockDNS(t, delegate("example.com.", "ns1.example.com.", "192.0.2.2"))
+	root := startMockDNS(t, delegate("com.", "a.gtld-servers.test.", "192.0.2.1"))
+	c := traceCollector(root, map[string]string{"192.0.2.1:53": tld, "192.0.2.2:53": auth})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	steps, err := c.Trace(ctx, "www.example.com", RecordA)
+	if err != nil {
+		t.Fatalf("Trace() error = %v", err)
+	}
+	if len(steps) != 3 {
+		t.Fatalf("%d steps, want root, com and example.com: %+v", len(steps), steps)
+	}
+	if s := steps[0]; s.Zone != "." || s.Referral != "com" || len(s.Glue) != 1 || s.Glue[0] != "a.gtld-servers.test 192.0.2.1" {
+		t.Errorf("root step = %+v", s)
+	}
+	if s := steps[1]; s.Zone != "com" || s.Server != "a.gtld-servers.test" || s.Referral != "example.com" || s.NS[0] != "ns1.example.com" {
+		t.Errorf("com step = %+v", s)
+	}
+	if s := steps[2]; s.Zone != "example.com" || s.Referral != "" || len(s.Answer) != 1 || !strings.Contains(s.Answer[0], "192.0.2.80") {
+		t.Errorf("answer step = %+v", s)
+	}
+	if recursion.Load() {
+		t.Error("trace queries asked for recursion")
+	}
+}
+
+func TestDNSTrace_Failures(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// A delegation pointing back up is lame and ends the trace
+	root := startMockDNS(t, delegate("com.", "a.gtld-servers.test.", "192.0.2.1"))
+	lame := startMockDNS(t, delegate(".", "a.root-servers.test.", "192.0.2.9"))
+	c := traceCollector(root, map[string]string{"192.0.2.1:53": lame})
+	steps, err := c.Trace(ctx, "www.example.com", RecordA)
+	if err == nil || !strings.Contains(err.Error(), "lame delegation") || len(steps) != 2 {
+		t.Errorf("lame delegation: %d steps, error %v", len(steps), err)
+	}
+
+	// Every server of a zone failing is reported with the tries as steps
+	silent := freeUDPPort(t)
+	c = traceCollector(root, map[string]string{"192.0.2.1:53": net.JoinHostPort("127.0.0.1", strconv.Itoa(silent))})
+	steps, err = c.Trace(ctx, "www.example.com", RecordA)
+	if err == nil || !strings.Contains(err.Error(), "no nameserver of com answered") {
+		t.Errorf("unreachable zone error = %v", err)
+	}
+	if len(steps) != 2 || steps[1].Error == nil {
+		t.Errorf("steps = %+v, want the referral and the failed query", steps)
+	}
+}
@@ -0,0 +1,92 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// MaxDSCP is the largest 6-bit DSCP code point
+const MaxDSCP = 63
+
+// DSCPError reports that DSCP marking could not be applied to a socket
+type DSCPError struct {
+	DSCP int
+	Err  error
+}
+
+func (e *DSCPError) Error() string {
+	return fmt.Sprintf("DSCP %d not applied: %v", e.DSCP, e.Err)
+}
+
+func (e *DSCPError) Unwrap() error {
+	return e.Err
+}
+
+// dscpTOS returns the ToS / traffic class byte for a DSCP value (ECN bits left clear)
+func dscpTOS(dscp int) (int, error) {
+	if dscp < 0 || dscp > MaxDSCP {
+		return 0, &DSCPError{DSCP: dscp, Err: fmt.Errorf("out of range 0-%d", MaxDSCP)}
+	}
+	return dscp << 2, nil
+}
+
+// dscpControl returns a net.Dialer Control function that sets IP_TOS (IPv4)
+// or IPV6_TCLASS (IPv6) on the socket before it connects
+func dscpControl(dscp int) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		tos, err := dscpTOS(dscp)
+		if err != nil {
+			return err
+		}
+		var sockErr error
+		err = c.Control(func(fd uintptr) {
+			if strings.HasSuffix(network, "6") {
+				sockErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_TCLASS, tos)
+			} else {
+				sockErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_TOS, tos)
+			}
+		})
+		if err == nil {
+			err = sockErr
+		}
+		if err != nil {
+			return &DSCPError{DSCP: dscp, Err: err}
+		}
+		return nil
+	}
+}
+
+// markedDialer dials with DSCP marking. When the socket option is rejected
+// (e.g. missing permission) it retries unmarked and records why in MarkErr.
+type markedDialer struct {
+	DSCP    int              // 0 disables marking
+	Source  *SourceInterface // nil leaves the source address to the kernel
+	Timeout time.Duration
+	MarkErr error
+}
+
+func (d *markedDialer) Dial(network, address string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, address)
+}
+
+func (d *markedDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: d.Timeout, LocalAddr: d.Source.localAddr(network, address, 0)}
+	if d.DSCP == 0 {
+		return dialer.DialContext(ctx, network, address)
+	}
+
+	dialer.Control = dscpControl(d.DSCP)
+	conn, err := dialer.DialContext(ctx, network, address)
+	var dscpErr *DSCPError
+	if errors.As(err, &dscpErr) {
+		d.MarkErr = dscpErr
+		dialer.Control = nil
+		return dialer.DialContext(ctx, network, address)
+	}
+	return conn, err
+}
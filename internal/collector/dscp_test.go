@@ -0,0 +1,78 @@
+package collector
+
+import (
+	"net"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// socketTOS reads IP_TOS back from a connected socket
+func socketTOS(t *testing.T, conn net.Conn) int {
+	t.Helper()
+	raw, err := conn.(syscall.Conn).SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tos int
+	var sockErr error
+	raw.Control(func(fd uintptr) {
+		tos, sockErr = syscall.GetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_TOS)
+	})
+	if sockErr != nil {
+		t.Fatal(sockErr)
+	}
+	return tos
+}
+
+func TestDSCPControl(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	// EF (46) is 0xB8 in the ToS byte
+	dialer := &net.Dialer{Timeout: time.Second, Control: dscpControl(46)}
+	conn, err := dialer.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("dial with DSCP control failed: %v", err)
+	}
+	defer conn.Close()
+
+	if tos := socketTOS(t, conn); tos != 0xB8 {
+		t.Errorf("IP_TOS = %#x, want 0xb8", tos)
+	}
+}
+
+func TestMarkedDialer(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	d := &markedDialer{DSCP: 10, Timeout: time.Second}
+	conn, err := d.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tos := socketTOS(t, conn); tos != 10<<2 {
+		t.Errorf("IP_TOS = %d, want %d", tos, 10<<2)
+	}
+	conn.Close()
+	if d.MarkErr != nil {
+		t.Errorf("unexpected marking error: %v", d.MarkErr)
+	}
+
+	// An invalid code point falls back to an unmarked connection and reports why
+	d = &markedDialer{DSCP: 64, Timeout: time.Second}
+	conn, err = d.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("expected unmarked fallback, got %v", err)
+	}
+	conn.Close()
+	if d.MarkErr == nil {
+		t.Error("expected MarkErr for out of range DSCP")
+	}
+}
@@ -0,0 +1,140 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"syscall"
+
+	"github.com/vishvananda/netlink"
+)
+
+// EgressRoute is the kernel's choice of source address and interface for
+// one destination, what ip route get prints
+type EgressRoute struct {
+	Target    string
+	Address   string // Destination the route was looked up for
+	Source    string // Preferred source address, empty if the route leaves it open
+	Interface string
+	Gateway   string // Next hop, empty for on-link destinations
+	Table     int    // Matching routing table; policy routing picks tables other than main
+	Error     error
+}
+
+// PolicyRouted reports whether a table other than main matched
+func (r EgressRoute) PolicyRouted() bool {
+	return r.Table != 0 && r.Table != syscall.RT_TABLE_MAIN && r.Table != syscall.RT_TABLE_LOCAL
+}
+
+// EgressFor asks the kernel how traffic to dst leaves the host. With oif
+// set the lookup is restricted to that interface, as when a socket is
+// bound to it.
+func EgressFor(dst net.IP, oif string) EgressRoute {
+	res := EgressRoute{Target: dst.String(), Address: dst.String()}
+	var routes []netlink.Route
+	var err error
+	if oif != "" {
+		routes, err = netlink.RouteGetWithOptions(dst, &netlink.RouteGetOptions{Oif: oif})
+	} else {
+		routes, err = netlink.RouteGet(dst)
+	}
+	if err != nil {
+		res.Error = err
+		return res
+	}
+	if len(routes) == 0 {
+		res.Error = fmt.Errorf("no route to %s", dst)
+		return res
+	}
+
+	r := routes[0]
+	if r.Src != nil {
+		res.Source = r.Src.String()
+	}
+	if r.Gw != nil {
+		res.Gateway = r.Gw.String()
+	}
+	res.Table = r.Table
+	if link, err := netlink.LinkByIndex(r.LinkIndex); err == nil {
+		res.Interface = link.Attrs().Name
+	}
+	return res
+}
+
+// EgressCollector looks up the egress route of every connectivity target,
+// once per address family as IPv4 and IPv6 are routed independently
+type EgressCollector struct {
+	Targets []string
+	Source  *SourceInterface // Restricts the lookups to the active interface, nil = kernel's choice
+
+	lookup func(ctx context.Context, host string) ([]net.IPAddr, error)
+	egress func(dst net.IP, oif string) EgressRoute
+}
+
+func NewEgressCollector(targets []string) *EgressCollector {
+	return &EgressCollector{
+		Targets: targets,
+		lookup:  net.DefaultResolver.LookupIPAddr,
+		egress:  EgressFor,
+	}
+}
+
+// Collect resolves the targets concurrently, keeping the target order
+func (c *EgressCollector) Collect(ctx context.Context) []EgressRoute {
+	routes := make([][]EgressRoute, len(c.Targets))
+	oif := c.Source.Name()
+	var wg sync.WaitGroup
+	for i, target := range c.Targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			routes[i] = c.target(ctx, target, oif)
+		}()
+	}
+	wg.Wait()
+
+	var out []EgressRoute
+	for _, r := range routes {
+		out = append(out, r...)
+	}
+	return out
+}
+
+// Lookup returns the egress routes of any host, one per address family,
+// restricted to the active interface like the target lookups
+func (c *EgressCollector) Lookup(ctx context.Context, host string) []EgressRoute {
+	return c.target(ctx, host, c.Source.Name())
+}
+
+// target returns one route per address family of target
+func (c *EgressCollector) target(ctx context.Context, target, oif string) []EgressRoute {
+	host, _, err := normalizeTCPTarget(target)
+	if err != nil {
+		return []EgressRoute{{Target: target, Error: err}}
+	}
+	addrs, err := c.lookup(ctx, host)
+	if err != nil {
+		return []EgressRoute{{Target: target, Error: err}}
+	}
+
+	var out []EgressRoute
+	var v4, v6 bool
+	for _, a := range addrs {
+		if a.IP.To4() != nil {
+			if v4 {
+				continue
+			}
+			v4 = true
+		} else {
+			if v6 {
+				continue
+			}
+			v6 = true
+		}
+		r := c.egress(a.IP, oif)
+		r.Target = target
+		out = append(out, r)
+	}
+	return out
+}
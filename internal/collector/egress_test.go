@@ -0,0 +1,66 @@
+package collector
+
+import (
+	"context"
+	"net"
+	"testing"
+)
+
+func TestEgressFor_Loopback(t *testing.T) {
+	r := EgressFor(net.ParseIP("127.0.0.1"), "")
+	if r.Error != nil {
+		t.Skipf("route lookup unavailable: %v", r.Error)
+	}
+	if r.Source != "127.0.0.1" {
+		t.Errorf("Source = %q, want 127.0.0.1", r.Source)
+	}
+	if r.Interface != "lo" {
+		t.Errorf("Interface = %q, want lo", r.Interface)
+	}
+	if r.PolicyRouted() {
+		t.Errorf("loopback matched table %d, expected local", r.Table)
+	}
+}
+
+func TestEgressCollector_OneRoutePerFamily(t *testing.T) {
+	c := NewEgressCollector([]string{"dual.test", "192.0.2.7"})
+	c.lookup = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		if host == "dual.test" {
+			return []net.IPAddr{{IP: net.ParseIP("192.0.2.1")}, {IP: net.ParseIP("192.0.2.2")}, {IP: net.ParseIP("2001:db8::1")}}, nil
+		}
+		return []net.IPAddr{{IP: net.ParseIP(host)}}, nil
+	}
+	c.egress = func(dst net.IP, oif string) EgressRoute {
+		return EgressRoute{Address: dst.String(), Interface: "eth0"}
+	}
+
+	routes := c.Collect(context.Background())
+	want := []struct{ target, addr string }{
+		{"dual.test", "192.0.2.1"},
+		{"dual.test", "2001:db8::1"},
+		{"192.0.2.7", "192.0.2.7"},
+	}
+	if len(routes) != len(want) {
+		t.Fatalf("got %d routes, want %d: %+v", len(routes), len(want), routes)
+	}
+	for i, w := range want {
+		if routes[i].Target != w.target || routes[i].Address != w.addr {
+			t.Errorf("route %d = %s %s, want %s %s", i, routes[i].Target, routes[i].Address, w.target, w.addr)
+		}
+	}
+}
+
+func TestEgressCollector_LookupAnyHost(t *testing.T) {
+	c := NewEgressCollector(nil)
+	c.lookup = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		return []net.IPAddr{{IP: net.ParseIP("198.51.100.4")}}, nil
+	}
+	c.egress = func(dst net.IP, oif string) EgressRoute {
+		return EgressRoute{Address: dst.String(), Interface: "eth0"}
+	}
+
+	routes := c.Lookup(context.Background(), "example.test:443")
+	if len(routes) != 1 || routes[0].Target != "example.test:443" || routes[0].Address != "198.51.100.4" {
+		t.Fatalf("Lookup() = %+v", routes)
+	}
+}
@@ -0,0 +1,318 @@
+package collector
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// ethtool ioctl commands, see linux/ethtool.h
+const (
+	siocEthtool       = 0x8946
+	ethtoolGDrvInfo   = 0x03
+	ethtoolGRingParam = 0x10
+	ethtoolGStrings   = 0x1b
+	ethtoolGStats     = 0x1d
+	ethSSStats        = 1 // ETH_SS_STATS string set
+
+	ethGStringLen  = 32
+	ringParamSize  = 9 * 4               // struct ethtool_ringparam
+	drvInfoSize    = 4 + 5*32 + 12 + 5*4 // struct ethtool_drvinfo
+	drvInfoNStats  = 4 + 5*32 + 12 + 4   // offset of n_stats
+	maxEthtoolStat = 1 << 16             // sanity bound for driver stat counts
+	ifNameSize     = 16                  // IFNAMSIZ
+)
+
+// RingParams are the NIC descriptor ring sizes (ethtool -g)
+type RingParams struct {
+	RxPending uint32 // Current RX ring size
+	RxMax     uint32
+	TxPending uint32 // Current TX ring size
+	TxMax     uint32
+}
+
+// RxUndersized reports whether the RX ring could be grown
+func (r RingParams) RxUndersized() bool {
+	return r.RxMax > 0 && r.RxPending < r.RxMax
+}
+
+// QueueStats describes one hardware RX or TX queue
+type QueueStats struct {
+	Name       string // rx-0, tx-3, ...
+	Packets    uint64
+	HasPackets bool // The driver exposes a per-queue packet counter
+}
+
+// parseRingParam decodes a struct ethtool_ringparam returned by ETHTOOL_GRINGPARAM
+func parseRingParam(buf []byte) (RingParams, error) {
+	if len(buf) < ringParamSize {
+		return RingParams{}, fmt.Errorf("short ringparam: %d bytes", len(buf))
+	}
+	u32 := func(i int) uint32 { return binary.NativeEndian.Uint32(buf[i*4:]) }
+	if cmd := u32(0); cmd != ethtoolGRingParam {
+		return RingParams{}, fmt.Errorf("unexpected ethtool cmd %#x", cmd)
+	}
+	// Layout: cmd, rx_max, rx_mini_max, rx_jumbo_max, tx_max,
+	//         rx_pending, rx_mini_pending, rx_jumbo_pending, tx_pending
+	return RingParams{
+		RxMax:     u32(1),
+		TxMax:     u32(4),
+		RxPending: u32(5),
+		TxPending: u32(8),
+	}, nil
+}
+
+// queueStatPattern matches the common driver names for per-queue packet
+// counters: rx_queue_0_packets (virtio, ixgbe), rx0_packets (mlx5),
+// tx-0.packets, queue_0_tx_packets (ena)
+var queueStatPattern = regexp.MustCompile(`^(?:(rx|tx)[_-]?(?:queue[_-]?)?(\d+)|queue[_-](\d+)[_-](rx|tx))[_.]packets$`)
+
+// parseQueueStats merges the queues listed in sysfs with the per-queue packet
+// counters found among the driver's ethtool stats
+func parseQueueStats(queueDirs []string, names []string, values []uint64) []QueueStats {
+	byName := make(map[string]*QueueStats)
+	var queues []*QueueStats
+	get := func(name string) *QueueStats {
+		if q, ok := byName[name]; ok {
+			return q
+		}
+		q := &QueueStats{Name: name}
+		byName[name] = q
+		queues = append(queues, q)
+		return q
+	}
+
+	for _, dir := range queueDirs {
+		if strings.HasPrefix(dir, "rx-") || strings.HasPrefix(dir, "tx-") {
+			get(dir)
+		}
+	}
+	for i, name := range names {
+		if i >= len(values) {
+			break
+		}
+		m := queueStatPattern.FindStringSubmatch(name)
+		if m == nil {
+			continue
+		}
+		dir, idx := m[1], m[2]
+		if dir == "" {
+			dir, idx = m[4], m[3]
+		}
+		q := get(dir + "-" + idx)
+		q.Packets += values[i]
+		q.HasPackets = true
+	}
+
+	result := make([]QueueStats, 0, len(queues))
+	for _, q := range queues {
+		result = append(result, *q)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		di, ni := splitQueueName(result[i].Name)
+		dj, nj := splitQueueName(result[j].Name)
+		if di != dj {
+			return di < dj
+		}
+		return ni < nj
+	})
+	return result
+}
+
+func splitQueueName(name string) (string, int) {
+	dir, idx, _ := strings.Cut(name, "-")
+	n, _ := strconv.Atoi(idx)
+	return dir, n
+}
+
+// readQueueDirs lists /sys/class/net/<iface>/queues
+func readQueueDirs(sysRoot, iface string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(sysRoot, "class/net", iface, "queues"))
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	for _, e := range entries {
+		dirs = append(dirs, e.Name())
+	}
+	return dirs, nil
+}
+
+// readLinkSpeed reads the negotiated speed in Mbps and the duplex from
+// /sys/class/net/<iface>. Down links report -1 and "unknown", and some
+// drivers fail the read altogether; both come back as 0 and "".
+func readLinkSpeed(sysRoot, iface string) (int, string) {
+	dir := filepath.Join(sysRoot, "class/net", iface)
+	speed := 0
+	if b, err := os.ReadFile(filepath.Join(dir, "speed")); err == nil {
+		if n, err := strconv.Atoi(strings.TrimSpace(string(b))); err == nil && n > 0 {
+			speed = n
+		}
+	}
+	duplex := ""
+	if b, err := os.ReadFile(filepath.Join(dir, "duplex")); err == nil {
+		if d := strings.TrimSpace(string(b)); d == "full" || d == "half" {
+			duplex = d
+		}
+	}
+	return speed, duplex
+}
+
+// ifreqData is struct ifreq with the ifr_data member of the union in use
+type ifreqData struct {
+	name [ifNameSize]byte
+	data unsafe.Pointer
+	_    [24 - unsafe.Sizeof(uintptr(0))]byte // Pad the union to its full size
+}
+
+// ethtoolConn issues SIOCETHTOOL ioctls on a throwaway socket
+type ethtoolConn struct {
+	fd int
+}
+
+func newEthtoolConn() (*ethtoolConn, error) {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &ethtoolConn{fd: fd}, nil
+}
+
+func (e *ethtoolConn) Close() error {
+	return syscall.Close(e.fd)
+}
+
+// ioctl sends data (whose first u32 is the ethtool command) for iface;
+// the kernel writes the reply back into data
+func (e *ethtoolConn) ioctl(iface string, data []byte) error {
+	if len(iface) >= ifNameSize {
+		return fmt.Errorf("interface name too long: %s", iface)
+	}
+	ifr := ifreqData{data: unsafe.Pointer(&data[0])}
+	copy(ifr.name[:], iface)
+
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(e.fd), siocEthtool, uintptr(unsafe.Pointer(&ifr)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func (e *ethtoolConn) ringParam(iface string) (RingParams, error) {
+	buf := make([]byte, ringParamSize)
+	binary.NativeEndian.PutUint32(buf, ethtoolGRingParam)
+	if err := e.ioctl(iface, buf); err != nil {
+		return RingParams{}, err
+	}
+	return parseRingParam(buf)
+}
+
+func (e *ethtoolConn) drvInfo(iface string) (DriverInfo, error) {
+	buf := make([]byte, drvInfoSize)
+	binary.NativeEndian.PutUint32(buf, ethtoolGDrvInfo)
+	if err := e.ioctl(iface, buf); err != nil {
+		return DriverInfo{}, err
+	}
+	return parseDrvInfo(buf)
+}
+
+// stats returns the driver statistics names and values (ethtool -S)
+func (e *ethtoolConn) stats(iface string) ([]string, []uint64, error) {
+	info := make([]byte, drvInfoSize)
+	binary.NativeEndian.PutUint32(info, ethtoolGDrvInfo)
+	if err := e.ioctl(iface, info); err != nil {
+		return nil, nil, err
+	}
+	n := int(binary.NativeEndian.Uint32(info[drvInfoNStats:]))
+	if n == 0 {
+		return nil, nil, nil
+	}
+	if n > maxEthtoolStat {
+		return nil, nil, fmt.Errorf("implausible stat count %d", n)
+	}
+
+	strs := make([]byte, 12+n*ethGStringLen)
+	binary.NativeEndian.PutUint32(strs[0:], ethtoolGStrings)
+	binary.NativeEndian.PutUint32(strs[4:], ethSSStats)
+	binary.NativeEndian.PutUint32(strs[8:], uint32(n))
+	if err := e.ioctl(iface, strs); err != nil {
+		return nil, nil, err
+	}
+
+	vals := make([]byte, 8+n*8)
+	binary.NativeEndian.PutUint32(vals[0:], ethtoolGStats)
+	binary.NativeEndian.PutUint32(vals[4:], uint32(n))
+	if err := e.ioctl(iface, vals); err != nil {
+		return nil, nil, err
+	}
+
+	names := make([]string, n)
+	values := make([]uint64, n)
+	for i := 0; i < n; i++ {
+		raw := strs[12+i*ethGStringLen : 12+(i+1)*ethGStringLen]
+		names[i] = string(bytes.TrimRight(raw, "\x00"))
+		values[i] = binary.NativeEndian.Uint64(vals[8+i*8:])
+	}
+	return names, values, nil
+}
+
+// queueError wraps a failed ethtool request, nil when the driver does not
+// implement it: veth, bridges and tun devices have no rings to report
+func queueError(what string, err error) error {
+	if err == nil || errors.Is(err, syscall.EOPNOTSUPP) {
+		return nil
+	}
+	return fmt.Errorf("%s: %w", what, err)
+}
+
+// collectQueueInfo fills ring sizes and per-queue counters. Each part degrades
+// on its own: virtual devices and missing privileges just leave fields empty.
+// Drivers without ring or counter support (EOPNOTSUPP) are not an error.
+func collectQueueInfo(iface *InterfaceInfo) {
+	dirs, _ := readQueueDirs("/sys", iface.Name)
+
+	conn, err := newEthtoolConn()
+	if err != nil {
+		iface.QueueError = err
+		iface.Queues = parseQueueStats(dirs, nil, nil)
+		return
+	}
+	defer conn.Close()
+
+	ring, err := conn.ringParam(iface.Name)
+	if err == nil {
+		iface.Ring = &ring
+	}
+	iface.QueueError = queueError("ring parameters", err)
+
+	names, values, err := conn.stats(iface.Name)
+	if iface.QueueError == nil {
+		iface.QueueError = queueError("queue counters", err)
+	}
+	iface.Queues = parseQueueStats(dirs, names, values)
+}
+
+// readQueueCounters re-reads the per-queue packet counters of iface, nil if
+// the driver or the privileges do not allow it
+func readQueueCounters(iface string) []QueueStats {
+	conn, err := newEthtoolConn()
+	if err != nil {
+		return nil
+	}
+	defer conn.Close()
+	names, values, err := conn.stats(iface)
+	if err != nil {
+		return nil
+	}
+	dirs, _ := readQueueDirs("/sys", iface)
+	return parseQueueStats(dirs, names, values)
+}
@@ -0,0 +1,325 @@
+package collector
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+	"syscall"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
+)
+
+// ethtool generic netlink messages and attributes, see linux/ethtool_netlink.h
+const (
+	ethtoolGenlName        = "ethtool"
+	ethtoolGenlVersion     = 1
+	ethtoolMsgLinkModesGet = 4
+	ethtoolMsgFeaturesGet  = 11
+	ethtoolAHeaderDevName  = 2
+	ethtoolAFeaturesHeader = 1
+	ethtoolAFeaturesActive = 4
+	ethtoolALinkModesHdr   = 1
+	ethtoolALinkModesOurs  = 3
+	ethtoolALinkModesPeer  = 4
+	ethtoolALinkModesSpeed = 5
+	ethtoolALinkModesDplx  = 6
+	ethtoolABitsetNoMask   = 1
+	ethtoolABitsetBits     = 3
+	ethtoolABitsetBitsBit  = 1
+	ethtoolABitsetBitName  = 2
+	ethtoolABitsetBitValue = 3
+)
+
+// offloadFeatures maps the offloads shown per NIC to the kernel feature
+// names behind them; one active name is enough, as in ethtool -k
+var offloadFeatures = []struct {
+	name     string
+	features []string
+}{
+	{"TSO", []string{"tx-tcp-segmentation", "tx-tcp6-segmentation"}},
+	{"GSO", []string{"tx-generic-segmentation"}},
+	{"GRO", []string{"rx-gro"}},
+	{"LRO", []string{"rx-lro"}},
+}
+
+// ethtoolFamily resolves the ethtool generic netlink family once, kernels
+// before 5.6 do not have it
+var ethtoolFamily = sync.OnceValues(func() (uint16, error) {
+	family, err := netlink.GenlFamilyGet(ethtoolGenlName)
+	if err != nil {
+		return 0, fmt.Errorf("ethtool netlink: %w", err)
+	}
+	return family.ID, nil
+})
+
+// activeFeatures returns the names of the features enabled on iface
+// (ETHTOOL_MSG_FEATURES_GET). Verbose bitsets carry each bit's name, so no
+// ETHTOOL_MSG_STRSET_GET round trip is needed.
+func activeFeatures(iface string) (map[string]bool, error) {
+	family, err := ethtoolFamily()
+	if err != nil {
+		return nil, err
+	}
+	req := nl.NewNetlinkRequest(int(family), syscall.NLM_F_REQUEST)
+	req.AddData(&nl.Genlmsg{Command: ethtoolMsgFeaturesGet, Version: ethtoolGenlVersion})
+	header := nl.NewRtAttr(ethtoolAFeaturesHeader|int(nl.NLA_F_NESTED), nil)
+	header.AddRtAttr(ethtoolAHeaderDevName, nl.ZeroTerminated(iface))
+	req.AddData(header)
+
+	msgs, err := req.Execute(syscall.NETLINK_GENERIC, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(msgs) == 0 || len(msgs[0]) < nl.SizeofGenlmsg {
+		return nil, fmt.Errorf("empty ethtool features reply")
+	}
+	return parseFeaturesReply(msgs[0][nl.SizeofGenlmsg:])
+}
+
+// parseFeaturesReply finds the active bitset among the attributes of a
+// features reply
+func parseFeaturesReply(b []byte) (map[string]bool, error) {
+	attrs, err := nl.ParseRouteAttr(b)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range attrs {
+		if a.Attr.Type&nl.NLA_TYPE_MASK == ethtoolAFeaturesActive {
+			return parseBitset(a.Value)
+		}
+	}
+	return nil, fmt.Errorf("no active features in the reply")
+}
+
+// parseBitset decodes a verbose ethtool bitset into the names of its set
+// bits. In list form (NOMASK) every listed bit is set, otherwise a VALUE
+// flag marks them.
+func parseBitset(b []byte) (map[string]bool, error) {
+	set, _, err := parseBitsetMask(b)
+	return set, err
+}
+
+// parseBitsetMask is parseBitset that also returns every listed bit, the
+// mask of a bitset that has one, e.g. the supported half of a NIC's link
+// modes whose values are the advertised ones
+func parseBitsetMask(b []byte) (map[string]bool, map[string]bool, error) {
+	attrs, err := nl.ParseRouteAttr(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	list := false
+	var bits []byte
+	for _, a := range attrs {
+		switch a.Attr.Type & nl.NLA_TYPE_MASK {
+		case ethtoolABitsetNoMask:
+			list = true
+		case ethtoolABitsetBits:
+			bits = a.Value
+		}
+	}
+
+	set, mask := make(map[string]bool), make(map[string]bool)
+	entries, err := nl.ParseRouteAttr(bits)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, e := range entries {
+		if e.Attr.Type&nl.NLA_TYPE_MASK != ethtoolABitsetBitsBit {
+			continue
+		}
+		fields, err := nl.ParseRouteAttr(e.Value)
+		if err != nil {
+			return nil, nil, err
+		}
+		name, value := "", list
+		for _, f := range fields {
+			switch f.Attr.Type & nl.NLA_TYPE_MASK {
+			case ethtoolABitsetBitName:
+				name = string(bytes.TrimRight(f.Value, "\x00"))
+			case ethtoolABitsetBitValue:
+				value = true
+			}
+		}
+		if name == "" {
+			continue
+		}
+		mask[name] = true
+		if value {
+			set[name] = true
+		}
+	}
+	return set, mask, nil
+}
+
+// LinkModes is what ETHTOOL_MSG_LINKMODES_GET reports about a link
+type LinkModes struct {
+	SpeedMbps    int    // 0 if unknown
+	Duplex       string // "full" or "half", empty if unknown
+	MaxSpeedMbps int    // Fastest supported link mode, 0 if none is known
+	// PeerMaxSpeedMbps is the fastest mode both the NIC supports and the
+	// link partner advertises, 0 if the partner advertised none
+	PeerMaxSpeedMbps int
+}
+
+// linkModes asks the kernel for iface's speed, duplex and supported link
+// modes (ethtool <iface>)
+func linkModes(iface string) (LinkModes, error) {
+	family, err := ethtoolFamily()
+	if err != nil {
+		return LinkModes{}, err
+	}
+	req := nl.NewNetlinkRequest(int(family), syscall.NLM_F_REQUEST)
+	req.AddData(&nl.Genlmsg{Command: ethtoolMsgLinkModesGet, Version: ethtoolGenlVersion})
+	header := nl.NewRtAttr(ethtoolALinkModesHdr|int(nl.NLA_F_NESTED), nil)
+	header.AddRtAttr(ethtoolAHeaderDevName, nl.ZeroTerminated(iface))
+	req.AddData(header)
+
+	msgs, err := req.Execute(syscall.NETLINK_GENERIC, 0)
+	if err != nil {
+		return LinkModes{}, err
+	}
+	if len(msgs) == 0 || len(msgs[0]) < nl.SizeofGenlmsg {
+		return LinkModes{}, fmt.Errorf("empty ethtool link modes reply")
+	}
+	return parseLinkModesReply(msgs[0][nl.SizeofGenlmsg:])
+}
+
+// linkModeSpeed matches the speed of a link mode name, e.g. 1000baseT/Full
+var linkModeSpeed = regexp.MustCompile(`^(\d+)base`)
+
+// parseLinkModesReply decodes the speed, duplex and the fastest supported
+// mode of a link modes reply, alone and shared with the link partner. The
+// kernel sends SPEED_UNKNOWN (-1) and DUPLEX_UNKNOWN (0xff) for a link that
+// is down.
+func parseLinkModesReply(b []byte) (LinkModes, error) {
+	attrs, err := nl.ParseRouteAttr(b)
+	if err != nil {
+		return LinkModes{}, err
+	}
+	var modes LinkModes
+	var supported, peer map[string]bool
+	for _, a := range attrs {
+		switch a.Attr.Type & nl.NLA_TYPE_MASK {
+		case ethtoolALinkModesSpeed:
+			if len(a.Value) >= 4 {
+				if speed := int32(binary.NativeEndian.Uint32(a.Value)); speed > 0 {
+					modes.SpeedMbps = int(speed)
+				}
+			}
+		case ethtoolALinkModesDplx:
+			if len(a.Value) >= 1 {
+				switch a.Value[0] {
+				case 0: // DUPLEX_HALF
+					modes.Duplex = "half"
+				case 1: // DUPLEX_FULL
+					modes.Duplex = "full"
+				}
+			}
+		case ethtoolALinkModesOurs:
+			if _, supported, err = parseBitsetMask(a.Value); err != nil {
+				return LinkModes{}, err
+			}
+		case ethtoolALinkModesPeer:
+			if peer, _, err = parseBitsetMask(a.Value); err != nil {
+				return LinkModes{}, err
+			}
+		}
+	}
+	for name := range supported {
+		speed := linkModeMbps(name)
+		modes.MaxSpeedMbps = max(modes.MaxSpeedMbps, speed)
+		if peer[name] {
+			modes.PeerMaxSpeedMbps = max(modes.PeerMaxSpeedMbps, speed)
+		}
+	}
+	return modes, nil
+}
+
+// linkModeMbps is the speed of a link mode name, 0 for a name without one
+// such as Autoneg
+func linkModeMbps(name string) int {
+	m := linkModeSpeed.FindStringSubmatch(name)
+	if m == nil {
+		return 0
+	}
+	speed, _ := strconv.Atoi(m[1])
+	return speed
+}
+
+// offloadFlags reduces active feature names to the TSO/GSO/GRO/LRO summary
+func offloadFlags(active map[string]bool) map[string]bool {
+	flags := make(map[string]bool, len(offloadFeatures))
+	for _, o := range offloadFeatures {
+		for _, f := range o.features {
+			flags[o.name] = flags[o.name] || active[f]
+		}
+	}
+	return flags
+}
+
+// DriverInfo is what ETHTOOL_GDRVINFO reports about a NIC's driver
+type DriverInfo struct {
+	Driver          string
+	Version         string
+	FirmwareVersion string
+}
+
+// parseDrvInfo decodes the strings of a struct ethtool_drvinfo
+func parseDrvInfo(buf []byte) (DriverInfo, error) {
+	if len(buf) < drvInfoSize {
+		return DriverInfo{}, fmt.Errorf("short drvinfo: %d bytes", len(buf))
+	}
+	if cmd := binary.NativeEndian.Uint32(buf); cmd != ethtoolGDrvInfo {
+		return DriverInfo{}, fmt.Errorf("unexpected ethtool cmd %#x", cmd)
+	}
+	// Layout: cmd, driver[32], version[32], fw_version[32], ...
+	str := func(i int) string {
+		// Drivers leave stale bytes after the terminator, stop at the first NUL
+		s, _, _ := bytes.Cut(buf[4+i*32:4+(i+1)*32], []byte{0})
+		return string(s)
+	}
+	return DriverInfo{Driver: str(0), Version: str(1), FirmwareVersion: str(2)}, nil
+}
+
+// collectDriverInfo fills the driver version, firmware and offloads. The
+// driver name from sysfs is kept when ethtool cannot be asked, e.g. without
+// privileges or on kernels without the ethtool netlink family.
+func collectDriverInfo(iface *InterfaceInfo) {
+	if conn, err := newEthtoolConn(); err == nil {
+		if drv, err := conn.drvInfo(iface.Name); err == nil {
+			if iface.Driver == "" {
+				iface.Driver = drv.Driver
+			}
+			iface.DriverVersion = drv.Version
+			iface.FirmwareVersion = drv.FirmwareVersion
+		}
+		conn.Close()
+	}
+
+	if active, err := activeFeatures(iface.Name); err == nil {
+		iface.Offload = offloadFlags(active)
+	}
+}
+
+// collectLinkSpeed fills the link speed and duplex from sysfs, asking
+// ethtool netlink for what sysfs leaves unknown and for the fastest mode
+// the NIC supports
+func collectLinkSpeed(iface *InterfaceInfo) {
+	iface.SpeedMbps, iface.Duplex = readLinkSpeed("/sys", iface.Name)
+	modes, err := linkModes(iface.Name)
+	if err != nil {
+		return
+	}
+	if iface.SpeedMbps == 0 {
+		iface.SpeedMbps = modes.SpeedMbps
+	}
+	if iface.Duplex == "" {
+		iface.Duplex = modes.Duplex
+	}
+	iface.MaxSpeedMbps = modes.MaxSpeedMbps
+	iface.PeerMaxSpeedMbps = modes.PeerMaxSpeedMbps
+}
@@ -0,0 +1,115 @@
+package collector
+
+import (
+	"encoding/binary"
+	"maps"
+	"testing"
+
+	"github.com/vishvananda/netlink/nl"
+)
+
+// featureBit builds one ETHTOOL_A_BITSET_BITS_BIT entry
+func featureBit(parent *nl.RtAttr, index uint32, name string, value bool) {
+	bit := parent.AddRtAttr(ethtoolABitsetBitsBit|int(nl.NLA_F_NESTED), nil)
+	bit.AddRtAttr(1, nl.Uint32Attr(index)) // ETHTOOL_A_BITSET_BIT_INDEX
+	bit.AddRtAttr(ethtoolABitsetBitName, nl.ZeroTerminated(name))
+	if value {
+		bit.AddRtAttr(ethtoolABitsetBitValue, nil)
+	}
+}
+
+func TestParseFeaturesReply(t *testing.T) {
+	// List form, as the kernel sends the active features: listed bits are set
+	active := nl.NewRtAttr(ethtoolAFeaturesActive|int(nl.NLA_F_NESTED), nil)
+	active.AddRtAttr(ethtoolABitsetNoMask, nil)
+	bits := active.AddRtAttr(ethtoolABitsetBits|int(nl.NLA_F_NESTED), nil)
+	featureBit(bits, 16, "tx-tcp6-segmentation", false)
+	featureBit(bits, 21, "rx-gro", false)
+	header := nl.NewRtAttr(ethtoolAFeaturesHeader|int(nl.NLA_F_NESTED), nil)
+	header.AddRtAttr(ethtoolAHeaderDevName, nl.ZeroTerminated("eth0"))
+
+	got, err := parseFeaturesReply(append(header.Serialize(), active.Serialize()...))
+	if err != nil {
+		t.Fatalf("parseFeaturesReply() error = %v", err)
+	}
+	want := map[string]bool{"TSO": true, "GSO": false, "GRO": true, "LRO": false}
+	if flags := offloadFlags(got); !maps.Equal(flags, want) {
+		t.Errorf("offloadFlags() = %v, want %v", flags, want)
+	}
+
+	// With a mask only bits flagged with VALUE are set
+	masked := nl.NewRtAttr(ethtoolAFeaturesActive|int(nl.NLA_F_NESTED), nil)
+	bits = masked.AddRtAttr(ethtoolABitsetBits|int(nl.NLA_F_NESTED), nil)
+	featureBit(bits, 15, "rx-lro", false)
+	featureBit(bits, 12, "tx-generic-segmentation", true)
+	got, err = parseBitset(masked.Serialize()[4:])
+	if err != nil || !maps.Equal(got, map[string]bool{"tx-generic-segmentation": true}) {
+		t.Errorf("parseBitset() = %v, %v", got, err)
+	}
+
+	if _, err := parseFeaturesReply(header.Serialize()); err == nil {
+		t.Error("a reply without active features should fail")
+	}
+}
+
+func TestParseDrvInfo(t *testing.T) {
+	buf := make([]byte, drvInfoSize)
+	binary.NativeEndian.PutUint32(buf, ethtoolGDrvInfo)
+	copy(buf[4:], "ixgbe")
+	copy(buf[36:], "5.1.0-k\x00stale")
+	copy(buf[68:], "0x800008d3, 1.2.3")
+
+	got, err := parseDrvInfo(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := DriverInfo{Driver: "ixgbe", Version: "5.1.0-k", FirmwareVersion: "0x800008d3, 1.2.3"}
+	if got != want {
+		t.Errorf("parseDrvInfo() = %+v, want %+v", got, want)
+	}
+	if _, err := parseDrvInfo(buf[:16]); err == nil {
+		t.Error("expected error for a short buffer")
+	}
+}
+
+func TestParseLinkModesReply(t *testing.T) {
+	// A 1G NIC that negotiated 100M half duplex: supported modes are the
+	// mask, the advertised ones carry VALUE
+	ours := nl.NewRtAttr(ethtoolALinkModesOurs|int(nl.NLA_F_NESTED), nil)
+	bits := ours.AddRtAttr(ethtoolABitsetBits|int(nl.NLA_F_NESTED), nil)
+	featureBit(bits, 3, "100baseT/Full", true)
+	featureBit(bits, 5, "1000baseT/Full", false)
+	featureBit(bits, 6, "Autoneg", true)
+	speed := nl.NewRtAttr(ethtoolALinkModesSpeed, nl.Uint32Attr(100))
+	duplex := nl.NewRtAttr(ethtoolALinkModesDplx, []byte{0})
+
+	got, err := parseLinkModesReply(append(append(ours.Serialize(), speed.Serialize()...), duplex.Serialize()...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (LinkModes{SpeedMbps: 100, Duplex: "half", MaxSpeedMbps: 1000}); got != want {
+		t.Errorf("parseLinkModesReply() = %+v, want %+v", got, want)
+	}
+
+	// The partner advertises a list without a mask, every bit listed is set
+	peer := nl.NewRtAttr(ethtoolALinkModesPeer|int(nl.NLA_F_NESTED), nil)
+	peer.AddRtAttr(ethtoolABitsetNoMask, nil)
+	peerBits := peer.AddRtAttr(ethtoolABitsetBits|int(nl.NLA_F_NESTED), nil)
+	featureBit(peerBits, 3, "100baseT/Full", false)
+	featureBit(peerBits, 6, "Autoneg", false)
+	got, err = parseLinkModesReply(append(ours.Serialize(), peer.Serialize()...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.MaxSpeedMbps != 1000 || got.PeerMaxSpeedMbps != 100 {
+		t.Errorf("parseLinkModesReply() = %+v, want a 1G NIC and 100M shared with the partner", got)
+	}
+
+	// A down link reports SPEED_UNKNOWN and DUPLEX_UNKNOWN
+	speed = nl.NewRtAttr(ethtoolALinkModesSpeed, nl.Uint32Attr(0xffffffff))
+	duplex = nl.NewRtAttr(ethtoolALinkModesDplx, []byte{0xff})
+	got, err = parseLinkModesReply(append(speed.Serialize(), duplex.Serialize()...))
+	if err != nil || got != (LinkModes{}) {
+		t.Errorf("parseLinkModesReply() = %+v, %v, want unknown speed and duplex", got, err)
+	}
+}
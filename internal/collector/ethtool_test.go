@@ -0,0 +1,125 @@
+package collector
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestParseRingParam(t *testing.T) {
+	// ETHTOOL_GRINGPARAM reply captured from an ixgbe NIC (ethtool -g: RX 512/4096, TX 512/4096)
+	fields := []uint32{ethtoolGRingParam, 4096, 0, 0, 4096, 512, 0, 0, 512}
+	buf := make([]byte, ringParamSize)
+	for i, v := range fields {
+		binary.NativeEndian.PutUint32(buf[i*4:], v)
+	}
+
+	ring, err := parseRingParam(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := RingParams{RxPending: 512, RxMax: 4096, TxPending: 512, TxMax: 4096}
+	if ring != want {
+		t.Errorf("ring = %+v, want %+v", ring, want)
+	}
+	if !ring.RxUndersized() {
+		t.Error("RX ring at 512/4096 should be reported as undersized")
+	}
+
+	if _, err := parseRingParam(buf[:8]); err == nil {
+		t.Error("expected error for a short buffer")
+	}
+	binary.NativeEndian.PutUint32(buf, ethtoolGStats)
+	if _, err := parseRingParam(buf); err == nil {
+		t.Error("expected error for the wrong command")
+	}
+}
+
+func TestParseQueueStats(t *testing.T) {
+	dir := t.TempDir()
+	for _, q := range []string{"rx-0", "rx-1", "tx-0", "tx-1"} {
+		os.MkdirAll(filepath.Join(dir, "class/net/eth0/queues", q), 0o755)
+	}
+	dirs, err := readQueueDirs(dir, "eth0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{"rx_packets", "rx_queue_0_packets", "rx_queue_1_packets", "tx_queue_0_packets", "rx_queue_0_bytes", "queue_1_tx_packets"}
+	values := []uint64{1000, 990, 10, 500, 123456, 7}
+	queues := parseQueueStats(dirs, names, values)
+
+	want := []QueueStats{
+		{Name: "rx-0", Packets: 990, HasPackets: true},
+		{Name: "rx-1", Packets: 10, HasPackets: true},
+		{Name: "tx-0", Packets: 500, HasPackets: true},
+		{Name: "tx-1", Packets: 7, HasPackets: true},
+	}
+	if len(queues) != len(want) {
+		t.Fatalf("queues = %+v", queues)
+	}
+	for i := range want {
+		if queues[i] != want[i] {
+			t.Errorf("queue %d = %+v, want %+v", i, queues[i], want[i])
+		}
+	}
+
+	// Without driver counters the sysfs queues are still listed
+	queues = parseQueueStats([]string{"tx-0", "rx-0", "rx-10", "rx-2"}, nil, nil)
+	got := []string{}
+	for _, q := range queues {
+		got = append(got, q.Name)
+		if q.HasPackets {
+			t.Errorf("%s should have no packet counter", q.Name)
+		}
+	}
+	if len(got) != 4 || got[0] != "rx-0" || got[1] != "rx-2" || got[2] != "rx-10" || got[3] != "tx-0" {
+		t.Errorf("queue order = %v", got)
+	}
+}
+
+func TestReadLinkSpeed(t *testing.T) {
+	root := t.TempDir()
+	write := func(iface, speed, duplex string) {
+		dir := filepath.Join(root, "class/net", iface)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		os.WriteFile(filepath.Join(dir, "speed"), []byte(speed), 0o644)
+		os.WriteFile(filepath.Join(dir, "duplex"), []byte(duplex), 0o644)
+	}
+	write("eth0", "1000\n", "full\n")
+	write("eth1", "-1\n", "unknown\n") // Link down
+
+	tests := []struct {
+		iface  string
+		speed  int
+		duplex string
+	}{
+		{"eth0", 1000, "full"},
+		{"eth1", 0, ""},
+		{"wlan0", 0, ""}, // No sysfs files
+	}
+	for _, tt := range tests {
+		if speed, duplex := readLinkSpeed(root, tt.iface); speed != tt.speed || duplex != tt.duplex {
+			t.Errorf("readLinkSpeed(%s) = %d, %q, want %d, %q", tt.iface, speed, duplex, tt.speed, tt.duplex)
+		}
+	}
+}
+
+func TestQueueError(t *testing.T) {
+	if err := queueError("ring parameters", syscall.EOPNOTSUPP); err != nil {
+		t.Errorf("unsupported by the driver = %v, want nil", err)
+	}
+	if err := queueError("ring parameters", nil); err != nil {
+		t.Errorf("no error = %v, want nil", err)
+	}
+	err := queueError("queue counters", syscall.EPERM)
+	if !errors.Is(err, syscall.EPERM) || !strings.HasPrefix(err.Error(), "queue counters: ") {
+		t.Errorf("permission error = %v, want it wrapped", err)
+	}
+}
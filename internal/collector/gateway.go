@@ -0,0 +1,274 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+// GatewayVerdict summarizes the health of the default gateway
+type GatewayVerdict string
+
+const (
+	GatewayHealthy      GatewayVerdict = "healthy"
+	GatewayNoRoute      GatewayVerdict = "no default route"
+	GatewayNoARP        GatewayVerdict = "gateway not resolving"
+	GatewayUnreachable  GatewayVerdict = "gateway unreachable"
+	GatewayUpstreamDown GatewayVerdict = "upstream down"
+	GatewayBeyondISP    GatewayVerdict = "broken beyond the second hop"
+)
+
+// GatewayHealth combines the route table, the gateway's neighbor entry and
+// the connectivity pings into one verdict. It catches a gateway that
+// resolves and answers but silently drops forwarded traffic.
+type GatewayHealth struct {
+	Gateway        string
+	Interface      string
+	NeighborState  string // Kernel neighbor (ARP) state, e.g. REACHABLE, empty without an entry
+	NeighborOK     bool   // The gateway's link layer address is known
+	GatewayPing    *PingResult
+	BeyondOK       bool   // A target past the gateway answered
+	SecondHop      string // Router that answered a TTL 2 probe, empty if none
+	SecondHopError error
+	Verdict        GatewayVerdict
+	Detail         string
+	Error          error
+}
+
+// GatewayCollector judges the default gateway from the connectivity results
+type GatewayCollector struct {
+	SecondHopTarget string // Probed with TTL 2 when the gateway answers but nothing beyond it does
+	Timeout         time.Duration
+	Budget          *Budget          // Shared probe budget, only the second hop probe sends anything
+	Source          *SourceInterface // The second hop probe leaves from this interface's address
+
+	route     func() (gw net.IP, link string, linkIndex int, err error)
+	neighbor  func(linkIndex int, ip net.IP) (state int, found bool, err error)
+	secondHop func(ctx context.Context, target string, timeout time.Duration) (string, error)
+}
+
+func NewGatewayCollector() *GatewayCollector {
+	c := &GatewayCollector{
+		SecondHopTarget: "8.8.8.8",
+		Timeout:         2 * time.Second,
+		route:           defaultRoute,
+		neighbor:        neighborState,
+	}
+	c.secondHop = func(ctx context.Context, target string, timeout time.Duration) (string, error) {
+		return probeSecondHop(ctx, target, timeout, c.Source)
+	}
+	return c
+}
+
+// Check evaluates the gateway against stats, the latest connectivity
+// results, which already ping the gateway and the targets beyond it
+func (c *GatewayCollector) Check(ctx context.Context, stats ConnectivityStats) GatewayHealth {
+	var h GatewayHealth
+	gw, link, linkIndex, err := c.route()
+	if err != nil || gw == nil {
+		h.Error = err
+		gatewayVerdict(&h)
+		return h
+	}
+	h.Gateway, h.Interface = gw.String(), link
+
+	if state, found, err := c.neighbor(linkIndex, gw); err != nil {
+		h.Error = err
+	} else if found {
+		h.NeighborState = neighStateName(state)
+		h.NeighborOK = state&(netlink.NUD_FAILED|netlink.NUD_INCOMPLETE) == 0
+	}
+
+	for target, res := range stats.Targets {
+		if target == h.Gateway {
+			h.GatewayPing = &res
+		} else if pingAnswered(res) {
+			h.BeyondOK = true
+		}
+	}
+
+	// Escalate: the gateway is there but nothing past it answers
+	if gatewayAnswers(h) && !h.BeyondOK && c.SecondHopTarget != "" {
+		if c.Budget.Allow(1, pingProbeBytes) {
+			h.SecondHop, h.SecondHopError = c.secondHop(ctx, c.SecondHopTarget, c.Timeout)
+		} else {
+			h.SecondHopError = ErrBudgetExceeded
+		}
+	}
+	gatewayVerdict(&h)
+	return h
+}
+
+// pingAnswered reports whether at least one echo came back
+func pingAnswered(res PingResult) bool {
+	return res.Error == nil && res.PacketLoss < 100
+}
+
+// gatewayAnswers reports whether the gateway itself is alive, by ARP or ping
+func gatewayAnswers(h GatewayHealth) bool {
+	return h.NeighborOK || (h.GatewayPing != nil && pingAnswered(*h.GatewayPing))
+}
+
+// gatewayVerdict derives the verdict from the observations in h
+func gatewayVerdict(h *GatewayHealth) {
+	pinged := h.GatewayPing != nil && pingAnswered(*h.GatewayPing)
+	switch {
+	case h.Gateway == "":
+		h.Verdict, h.Detail = GatewayNoRoute, "no IPv4 default route, traffic has nowhere to go"
+	case h.BeyondOK:
+		h.Verdict, h.Detail = GatewayHealthy, "targets beyond the gateway answer"
+		if !pinged {
+			h.Detail += ", the gateway itself does not answer ping"
+		}
+	case h.NeighborState != "" && !h.NeighborOK && !pinged:
+		h.Verdict, h.Detail = GatewayNoARP, fmt.Sprintf("ARP for %s is %s, the link or the gateway is down", h.Gateway, h.NeighborState)
+	case !gatewayAnswers(*h):
+		h.Verdict, h.Detail = GatewayUnreachable, fmt.Sprintf("%s does not answer and nothing beyond it does", h.Gateway)
+	case h.SecondHop != "":
+		h.Verdict, h.Detail = GatewayBeyondISP, fmt.Sprintf("the gateway and the next router %s answer, traffic is lost further upstream", h.SecondHop)
+	default:
+		h.Verdict, h.Detail = GatewayUpstreamDown, fmt.Sprintf("%s answers but forwards nothing, its uplink is likely down", h.Gateway)
+	}
+}
+
+// defaultRoute returns the IPv4 default gateway and its interface
+func defaultRoute() (net.IP, string, int, error) {
+	routes, err := netlink.RouteList(nil, netlink.FAMILY_V4)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	for _, r := range routes {
+		if !isDefaultRoute(r) || r.Gw == nil {
+			continue
+		}
+		name := ""
+		if link, err := netlink.LinkByIndex(r.LinkIndex); err == nil {
+			name = link.Attrs().Name
+		}
+		return r.Gw, name, r.LinkIndex, nil
+	}
+	return nil, "", 0, nil
+}
+
+// neighborState looks ip up in the kernel neighbor table of one link
+func neighborState(linkIndex int, ip net.IP) (int, bool, error) {
+	neighs, err := netlink.NeighList(linkIndex, netlink.FAMILY_V4)
+	if err != nil {
+		return 0, false, err
+	}
+	for _, n := range neighs {
+		if n.IP.Equal(ip) {
+			return n.State, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+var neighStates = []struct {
+	flag int
+	name string
+}{
+	{netlink.NUD_PERMANENT, "PERMANENT"},
+	{netlink.NUD_NOARP, "NOARP"},
+	{netlink.NUD_REACHABLE, "REACHABLE"},
+	{netlink.NUD_STALE, "STALE"},
+	{netlink.NUD_DELAY, "DELAY"},
+	{netlink.NUD_PROBE, "PROBE"},
+	{netlink.NUD_INCOMPLETE, "INCOMPLETE"},
+	{netlink.NUD_FAILED, "FAILED"},
+}
+
+// neighStateName names a neighbor state the way ip neigh does
+func neighStateName(state int) string {
+	for _, s := range neighStates {
+		if state&s.flag != 0 {
+			return s.name
+		}
+	}
+	return "NONE"
+}
+
+// probeSecondHop sends an echo request with TTL 2 and returns the router
+// that reports it expired, i.e. the hop after the gateway
+func probeSecondHop(ctx context.Context, target string, timeout time.Duration, src *SourceInterface) (string, error) {
+	dst, err := net.ResolveIPAddr("ip4", target)
+	if err != nil {
+		return "", err
+	}
+	local := "0.0.0.0"
+	if ip, _ := src.Addrs(); ip != nil {
+		local = ip.String()
+	}
+	conn, err := icmp.ListenPacket("ip4:icmp", local)
+	if err != nil {
+		if errors.Is(err, syscall.EPERM) || errors.Is(err, syscall.EACCES) {
+			return "", fmt.Errorf("second hop probe needs root or CAP_NET_RAW: %w", err)
+		}
+		return "", err
+	}
+	defer conn.Close()
+	if err := conn.IPv4PacketConn().SetTTL(2); err != nil {
+		return "", err
+	}
+
+	id := os.Getpid() & 0xffff
+	wire, err := (&icmp.Message{Type: ipv4.ICMPTypeEcho, Body: &icmp.Echo{ID: id, Seq: 2, Data: []byte("lnd")}}).Marshal(nil)
+	if err != nil {
+		return "", err
+	}
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+	if _, err := conn.WriteTo(wire, dst); err != nil {
+		return "", err
+	}
+
+	buf := make([]byte, 1500)
+	for {
+		n, peer, err := conn.ReadFrom(buf)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				return "", fmt.Errorf("no router answered at hop 2 within %s", timeout)
+			}
+			return "", err
+		}
+		msg, err := icmp.ParseMessage(ipv4.ICMPTypeEcho.Protocol(), buf[:n])
+		if err != nil {
+			continue
+		}
+		switch body := msg.Body.(type) {
+		case *icmp.TimeExceeded:
+			if quotedEchoID(body.Data) == id {
+				return peer.String(), nil
+			}
+		case *icmp.Echo:
+			if msg.Type == ipv4.ICMPTypeEchoReply && body.ID == id {
+				return peer.String(), nil // The target is the second hop
+			}
+		}
+	}
+}
+
+// quotedEchoID returns the echo identifier in the original datagram quoted
+// by an ICMP error, -1 if it is not an echo request
+func quotedEchoID(data []byte) int {
+	if len(data) < ipv4.HeaderLen {
+		return -1
+	}
+	hdrLen := int(data[0]&0x0f) * 4
+	if len(data) < hdrLen+8 || data[hdrLen] != byte(ipv4.ICMPTypeEcho) {
+		return -1
+	}
+	return int(data[hdrLen+4])<<8 | int(data[hdrLen+5])
+}
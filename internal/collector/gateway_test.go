@@ -0,0 +1,96 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/vishvananda/netlink"
+)
+
+// mockGateway builds a collector over a fixed route and neighbor state and
+// records whether the second hop was probed
+func mockGateway(neighState int, secondHop string, probed *bool) *GatewayCollector {
+	c := NewGatewayCollector()
+	c.route = func() (net.IP, string, int, error) {
+		return net.ParseIP("192.168.1.1"), "eth0", 2, nil
+	}
+	c.neighbor = func(int, net.IP) (int, bool, error) {
+		return neighState, neighState != netlink.NUD_NONE, nil
+	}
+	c.secondHop = func(context.Context, string, time.Duration) (string, error) {
+		*probed = true
+		if secondHop == "" {
+			return "", errors.New("no router answered at hop 2")
+		}
+		return secondHop, nil
+	}
+	return c
+}
+
+func TestGatewayCollector_Verdicts(t *testing.T) {
+	up := PingResult{AvgRtt: time.Millisecond}
+	lost := PingResult{PacketLoss: 100}
+	tests := []struct {
+		name       string
+		neigh      int
+		gateway    PingResult
+		beyond     PingResult
+		secondHop  string
+		want       GatewayVerdict
+		wantProbed bool
+	}{
+		{"all fine", netlink.NUD_REACHABLE, up, up, "", GatewayHealthy, false},
+		{"gateway filters ping", netlink.NUD_STALE, lost, up, "", GatewayHealthy, false},
+		{"arp fails", netlink.NUD_FAILED, lost, lost, "", GatewayNoARP, false},
+		{"gateway up, internet down", netlink.NUD_REACHABLE, up, lost, "", GatewayUpstreamDown, true},
+		{"arp only, internet down", netlink.NUD_REACHABLE, lost, lost, "", GatewayUpstreamDown, true},
+		{"isp router answers", netlink.NUD_REACHABLE, up, lost, "100.64.0.1", GatewayBeyondISP, true},
+		{"nothing answers", netlink.NUD_NONE, lost, lost, "", GatewayUnreachable, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var probed bool
+			c := mockGateway(tt.neigh, tt.secondHop, &probed)
+			stats := ConnectivityStats{Targets: map[string]PingResult{
+				"192.168.1.1": tt.gateway,
+				"8.8.8.8":     tt.beyond,
+				"1.1.1.1":     lost,
+			}}
+			h := c.Check(context.Background(), stats)
+			if h.Verdict != tt.want {
+				t.Errorf("verdict = %q (%s), want %q", h.Verdict, h.Detail, tt.want)
+			}
+			if probed != tt.wantProbed {
+				t.Errorf("second hop probed = %v, want %v", probed, tt.wantProbed)
+			}
+			if h.Gateway != "192.168.1.1" || h.Interface != "eth0" {
+				t.Errorf("gateway = %s via %s", h.Gateway, h.Interface)
+			}
+		})
+	}
+}
+
+func TestGatewayCollector_NoRoute(t *testing.T) {
+	c := NewGatewayCollector()
+	c.route = func() (net.IP, string, int, error) { return nil, "", 0, nil }
+	if h := c.Check(context.Background(), ConnectivityStats{}); h.Verdict != GatewayNoRoute {
+		t.Errorf("verdict = %q, want %q", h.Verdict, GatewayNoRoute)
+	}
+}
+
+func TestQuotedEchoID(t *testing.T) {
+	// IPv4 header (IHL 5) followed by an echo request with ID 0x1234
+	data := make([]byte, 28)
+	data[0] = 0x45
+	data[20] = 8
+	data[24], data[25] = 0x12, 0x34
+	if got := quotedEchoID(data); got != 0x1234 {
+		t.Errorf("quotedEchoID() = %#x, want 0x1234", got)
+	}
+	if got := quotedEchoID(data[:10]); got != -1 {
+		t.Errorf("truncated datagram should give -1, got %d", got)
+	}
+}
@@ -0,0 +1,38 @@
+package collector
+
+import (
+	"fmt"
+	"net/netip"
+	"net/url"
+	"strings"
+)
+
+// ExtractHost returns the bare host from user input which may be a URL
+// (https://host:port/path), a host:port pair, a bracketed IPv6 literal or a plain host/IP.
+func ExtractHost(input string) (string, error) {
+	s := strings.TrimSpace(input)
+	if s == "" {
+		return "", fmt.Errorf("empty input")
+	}
+
+	// Bare IP, including unbracketed and zone-scoped IPv6
+	if addr, err := netip.ParseAddr(s); err == nil {
+		return addr.String(), nil
+	}
+
+	// Without a scheme, url.Parse would treat the host as a path
+	if !strings.Contains(s, "://") {
+		s = "//" + s
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return "", fmt.Errorf("cannot extract host from %q: %v", input, err)
+	}
+
+	host := u.Hostname()
+	if host == "" || strings.ContainsAny(host, " \t\r\n") {
+		return "", fmt.Errorf("cannot extract host from %q", input)
+	}
+	return host, nil
+}
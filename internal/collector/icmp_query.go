@@ -0,0 +1,227 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+const (
+	icmpTypeAddressMask      ipv4.ICMPType = 17 // RFC 950, not in the IANA table of x/net
+	icmpTypeAddressMaskReply ipv4.ICMPType = 18
+
+	msPerDay       = 24 * 60 * 60 * 1000
+	icmpNonStdTime = 1 << 31 // High bit: timestamp is not ms since midnight UT
+)
+
+// ICMPTimestamp is a parsed timestamp reply (RFC 792). Times are
+// milliseconds since midnight UT.
+type ICMPTimestamp struct {
+	Originate   uint32 // Our send time, echoed
+	Receive     uint32 // When the remote received the request
+	Transmit    uint32 // When the remote sent the reply
+	NonStandard bool   // The remote clock is not in ms since midnight UT, offsets are meaningless
+	RTT         time.Duration
+	ClockOffset time.Duration // Remote clock minus ours, positive if the remote is ahead
+}
+
+// ICMPQueryResult holds the legacy ICMP query replies of one target. Many
+// hosts and firewalls drop these, so a timeout is the common outcome; a
+// reply often comes from a middlebox or an old embedded stack.
+type ICMPQueryResult struct {
+	Target         string
+	Timestamp      *ICMPTimestamp
+	TimestampError error
+	Netmask        net.IPMask
+	NetmaskError   error
+}
+
+// ICMPQueryCollector sends ICMP timestamp (type 13) and address mask
+// (type 17) requests. Both need a raw socket and exist for IPv4 only.
+type ICMPQueryCollector struct {
+	Targets []string
+	Timeout time.Duration
+	Budget  *Budget // Shared probe budget, targets over it are skipped
+}
+
+func NewICMPQueryCollector(targets []string) *ICMPQueryCollector {
+	return &ICMPQueryCollector{Targets: targets, Timeout: 2 * time.Second}
+}
+
+// Collect queries every target concurrently, keeping the target order
+func (c *ICMPQueryCollector) Collect(ctx context.Context) []ICMPQueryResult {
+	results := make([]ICMPQueryResult, len(c.Targets))
+	var wg sync.WaitGroup
+	for i, target := range c.Targets {
+		if !c.Budget.Allow(2, 2*pingProbeBytes) { // Timestamp and address mask
+			results[i] = ICMPQueryResult{Target: target, TimestampError: ErrBudgetExceeded, NetmaskError: ErrBudgetExceeded}
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = c.query(ctx, target)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+func (c *ICMPQueryCollector) query(ctx context.Context, target string) ICMPQueryResult {
+	res := ICMPQueryResult{Target: target}
+	dst, err := net.DefaultResolver.LookupIP(ctx, "ip4", target)
+	if err != nil {
+		res.TimestampError, res.NetmaskError = err, err
+		return res
+	}
+	addr := &net.IPAddr{IP: dst[0]}
+
+	id := os.Getpid() & 0xffff
+	sent := time.Now()
+	reply, err := c.exchange(ctx, addr, ipv4.ICMPTypeTimestamp, ipv4.ICMPTypeTimestampReply, timestampRequest(id, 1, sent))
+	if err == nil {
+		res.Timestamp, err = parseTimestampReply(reply, time.Now())
+	}
+	res.TimestampError = err
+
+	reply, err = c.exchange(ctx, addr, icmpTypeAddressMask, icmpTypeAddressMaskReply, addressMaskRequest(id, 2))
+	if err == nil {
+		res.Netmask, err = parseAddressMaskReply(reply)
+	}
+	res.NetmaskError = err
+	return res
+}
+
+// exchange sends one request over a raw socket and waits for the matching
+// reply body (identifier onwards)
+func (c *ICMPQueryCollector) exchange(ctx context.Context, dst *net.IPAddr, typ, replyType ipv4.ICMPType, body []byte) ([]byte, error) {
+	conn, err := icmp.ListenPacket("ip4:icmp", "0.0.0.0")
+	if err != nil {
+		if errors.Is(err, syscall.EPERM) || errors.Is(err, syscall.EACCES) {
+			return nil, fmt.Errorf("raw ICMP socket needs root or CAP_NET_RAW: %w", err)
+		}
+		return nil, err
+	}
+	defer conn.Close()
+
+	wire, err := (&icmp.Message{Type: typ, Body: &icmp.RawBody{Data: body}}).Marshal(nil)
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(c.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+	if _, err := conn.WriteTo(wire, dst); err != nil {
+		return nil, err
+	}
+
+	// The raw socket sees every ICMP packet, keep the reply to this request
+	buf := make([]byte, 1500)
+	for {
+		n, peer, err := conn.ReadFrom(buf)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				return nil, fmt.Errorf("no reply within %s", c.Timeout)
+			}
+			return nil, err
+		}
+		if ip, ok := peer.(*net.IPAddr); !ok || !ip.IP.Equal(dst.IP) {
+			continue
+		}
+		msg, err := icmp.ParseMessage(ipv4.ICMPTypeEchoReply.Protocol(), buf[:n])
+		if err != nil || msg.Type != replyType {
+			continue
+		}
+		raw, ok := msg.Body.(*icmp.RawBody)
+		if !ok || len(raw.Data) < 4 || !bytes.Equal(raw.Data[:4], body[:4]) {
+			continue
+		}
+		return raw.Data, nil
+	}
+}
+
+// msSinceMidnight is t as an ICMP timestamp
+func msSinceMidnight(t time.Time) uint32 {
+	t = t.UTC()
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return uint32(t.Sub(midnight).Milliseconds())
+}
+
+// timestampRequest builds the body of a timestamp request: identifier,
+// sequence, originate, receive and transmit timestamps
+func timestampRequest(id, seq int, now time.Time) []byte {
+	b := make([]byte, 16)
+	binary.BigEndian.PutUint16(b[0:], uint16(id))
+	binary.BigEndian.PutUint16(b[2:], uint16(seq))
+	binary.BigEndian.PutUint32(b[4:], msSinceMidnight(now))
+	return b
+}
+
+// addressMaskRequest builds the body of an address mask request
+func addressMaskRequest(id, seq int) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint16(b[0:], uint16(id))
+	binary.BigEndian.PutUint16(b[2:], uint16(seq))
+	return b
+}
+
+// msDiff is a-b wrapped into half a day either way, so replies across
+// midnight UT still give small offsets
+func msDiff(a, b uint32) int64 {
+	d := (int64(a) - int64(b)) % msPerDay
+	switch {
+	case d > msPerDay/2:
+		d -= msPerDay
+	case d <= -msPerDay/2:
+		d += msPerDay
+	}
+	return d
+}
+
+// parseTimestampReply decodes a timestamp reply body received at arrival and
+// derives the round trip and clock offset the way NTP does
+func parseTimestampReply(body []byte, arrival time.Time) (*ICMPTimestamp, error) {
+	if len(body) < 16 {
+		return nil, fmt.Errorf("timestamp reply too short: %d bytes", len(body))
+	}
+	ts := &ICMPTimestamp{
+		Originate: binary.BigEndian.Uint32(body[4:]),
+		Receive:   binary.BigEndian.Uint32(body[8:]),
+		Transmit:  binary.BigEndian.Uint32(body[12:]),
+	}
+	if ts.Receive&icmpNonStdTime != 0 || ts.Transmit&icmpNonStdTime != 0 {
+		ts.NonStandard = true
+		return ts, nil
+	}
+	t4 := msSinceMidnight(arrival)
+	rtt := msDiff(t4, ts.Originate) - msDiff(ts.Transmit, ts.Receive)
+	offset := (msDiff(ts.Receive, ts.Originate) + msDiff(ts.Transmit, t4)) / 2
+	ts.RTT = time.Duration(max(rtt, 0)) * time.Millisecond
+	ts.ClockOffset = time.Duration(offset) * time.Millisecond
+	return ts, nil
+}
+
+// parseAddressMaskReply decodes an address mask reply body
+func parseAddressMaskReply(body []byte) (net.IPMask, error) {
+	if len(body) < 8 {
+		return nil, fmt.Errorf("address mask reply too short: %d bytes", len(body))
+	}
+	mask := net.IPMask(append([]byte(nil), body[4:8]...))
+	if ones, bits := mask.Size(); ones == 0 && bits == 0 {
+		return mask, fmt.Errorf("non-contiguous netmask %s", net.IP(mask))
+	}
+	return mask, nil
+}
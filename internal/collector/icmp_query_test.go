@@ -0,0 +1,96 @@
+package collector
+
+import (
+	"encoding/hex"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+// parseCaptured decodes a hex ICMP packet as read from the raw socket
+func parseCaptured(t *testing.T, packet string) (ipv4.ICMPType, []byte) {
+	t.Helper()
+	b, err := hex.DecodeString(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := icmp.ParseMessage(1, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, ok := msg.Body.(*icmp.RawBody)
+	if !ok {
+		t.Fatalf("body is %T, want raw", msg.Body)
+	}
+	return msg.Type.(ipv4.ICMPType), raw.Data
+}
+
+func TestParseTimestampReply(t *testing.T) {
+	// Reply from a router: originate 15:24:14.224, receive and transmit 78ms later
+	typ, body := parseCaptured(t, "0e00c1d112340001034e2a10034e2a5e034e2a5e")
+	if typ != ipv4.ICMPTypeTimestampReply {
+		t.Fatalf("type = %v, want timestamp reply", typ)
+	}
+	arrival := time.Date(2026, 10, 16, 15, 24, 14, 264e6, time.UTC)
+	ts, err := parseTimestampReply(body, arrival)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts.Originate != 55454224 || ts.Receive != 55454302 || ts.Transmit != 55454302 {
+		t.Errorf("timestamps = %d/%d/%d", ts.Originate, ts.Receive, ts.Transmit)
+	}
+	if ts.RTT != 40*time.Millisecond {
+		t.Errorf("RTT = %s, want 40ms", ts.RTT)
+	}
+	if ts.ClockOffset != 58*time.Millisecond {
+		t.Errorf("ClockOffset = %s, want 58ms", ts.ClockOffset)
+	}
+
+	// Non-standard clocks set the high bit
+	body[8] |= 0x80
+	if ts, err := parseTimestampReply(body, arrival); err != nil || !ts.NonStandard {
+		t.Errorf("high bit should mark the reply non-standard, got %+v (%v)", ts, err)
+	}
+
+	if _, err := parseTimestampReply(body[:10], arrival); err == nil {
+		t.Error("expected an error for a truncated reply")
+	}
+}
+
+func TestTimestampAcrossMidnight(t *testing.T) {
+	// Sent just before midnight UT, answered just after by a clock 5ms ahead
+	sent := time.Date(2026, 10, 16, 23, 59, 59, 990e6, time.UTC)
+	body := timestampRequest(1, 1, sent)
+	recv := msSinceMidnight(sent.Add(15 * time.Millisecond)) // 00:00:00.005
+	body[8], body[9], body[10], body[11] = byte(recv>>24), byte(recv>>16), byte(recv>>8), byte(recv)
+	copy(body[12:16], body[8:12])
+
+	ts, err := parseTimestampReply(body, sent.Add(20*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts.RTT != 20*time.Millisecond || ts.ClockOffset != 5*time.Millisecond {
+		t.Errorf("RTT %s, offset %s, want 20ms and 5ms", ts.RTT, ts.ClockOffset)
+	}
+}
+
+func TestParseAddressMaskReply(t *testing.T) {
+	typ, body := parseCaptured(t, "1200e5fd12340002ffffff00")
+	if typ != icmpTypeAddressMaskReply {
+		t.Fatalf("type = %v, want address mask reply", typ)
+	}
+	mask, err := parseAddressMaskReply(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ones, _ := mask.Size(); ones != 24 {
+		t.Errorf("mask = %s, want /24", net.IP(mask))
+	}
+
+	if _, err := parseAddressMaskReply([]byte{0x12, 0x34, 0, 2, 0xff, 0x00, 0xff, 0}); err == nil {
+		t.Error("expected an error for a non-contiguous mask")
+	}
+}
@@ -22,57 +22,222 @@ type HostInfo struct {
 	MaxOpenFiles         uint64
 	FileMax              uint64
 	Interfaces           []InterfaceInfo
-	SysctlParams         map[string]string
+	SysctlParams         map[string]string `report:"detail"`
+	TCPCongestion        string            // Active congestion control algorithm, e.g. cubic
+	TCPCongestionAvail   []string          `report:"detail"` // Algorithms currently available to the kernel
+	Routes               []string          `report:"detail"` // Main table routes as ip route prints them, sorted
 	Error                error
+	Partial              bool // Collection stopped early, the fields above are incomplete
+}
+
+// BBRAvailable reports whether the BBR congestion control algorithm can be selected
+func (h HostInfo) BBRAvailable() bool {
+	for _, algo := range h.TCPCongestionAvail {
+		if algo == "bbr" {
+			return true
+		}
+	}
+	return false
 }
 
 // InterfaceInfo contains details about a network interface
 type InterfaceInfo struct {
-	Name            string
-	IP              string
-	MAC             string
-	MTU             int
-	Driver          string
-	DriverVersion   string
-	FirmwareVersion string
-	Offload         map[string]bool // TSO, GSO, LRO
+	Name             string
+	IP               string
+	MAC              string
+	MTU              int
+	Driver           string
+	DriverVersion    string
+	FirmwareVersion  string
+	SpeedMbps        int             // Negotiated link speed, 0 if unknown, e.g. the link is down
+	Duplex           string          // "full" or "half", empty if unknown
+	MaxSpeedMbps     int             // Fastest link mode the NIC supports, 0 if unknown
+	PeerMaxSpeedMbps int             // Fastest mode the link partner also advertises, 0 if unknown
+	OperState        string          // RFC 2863 state from sysfs: up, down, lowerlayerdown, dormant, unknown, ...
+	CarrierChanges   uint64          // Carrier up/down transitions since the device was created
+	CarrierFlaps     uint64          // Carrier changes since the previous collection
+	Offload          map[string]bool `report:"detail"` // TSO, GSO, LRO
+	IPv6             []IPv6Address   `report:"detail"`
+	IPv6Privacy      *IPv6Privacy    // nil if IPv6 is disabled on the interface
+	Ring             *RingParams     `report:"detail"` // nil if the driver does not report ring sizes
+	Queues           []QueueStats    `report:"detail"` // Hardware RX/TX queues
+	QueueError       error           // Why ring or queue stats are incomplete
+}
+
+// Down reports whether the interface cannot pass traffic. Devices without
+// a carrier concept, e.g. tun, report "unknown" and are not down.
+func (i InterfaceInfo) Down() bool {
+	return i.OperState != "" && i.OperState != "up" && i.OperState != "unknown"
+}
+
+// HalfDuplex reports whether the link negotiated half duplex, usually a
+// duplex mismatch or a forced setting on one end
+func (i InterfaceInfo) HalfDuplex() bool {
+	return i.Duplex == "half"
+}
+
+// SpeedBelowMax reports whether the link runs slower than both ends can,
+// e.g. two 1G ports that fell back to 100M over a bad cable. A partner
+// that only offers 100M is not a fault, so without its modes this is false.
+func (i InterfaceInfo) SpeedBelowMax() bool {
+	return i.SpeedMbps > 0 && i.SpeedMbps < i.PeerMaxSpeedMbps
+}
+
+// IPv6Scope classifies an IPv6 address by its reachability scope
+type IPv6Scope string
+
+const (
+	IPv6ScopeGlobal      IPv6Scope = "global"
+	IPv6ScopeUniqueLocal IPv6Scope = "unique-local" // fc00::/7
+	IPv6ScopeSiteLocal   IPv6Scope = "site-local"   // fec0::/10 (deprecated)
+	IPv6ScopeLinkLocal   IPv6Scope = "link-local"   // fe80::/10
+	IPv6ScopeLoopback    IPv6Scope = "loopback"
+	IPv6ScopeMulticast   IPv6Scope = "multicast"
+)
+
+// IPv6LifetimeForever marks an address lifetime that never expires
+const IPv6LifetimeForever time.Duration = -1
+
+// IPv6Address contains an IPv6 address with its scope and SLAAC lifetimes
+type IPv6Address struct {
+	Address      string
+	PrefixLen    int
+	Scope        IPv6Scope
+	Temporary    bool          // Privacy extension address (RFC 4941)
+	Deprecated   bool          // Preferred lifetime expired, not used for new connections
+	PreferredLft time.Duration // Remaining preferred lifetime, IPv6LifetimeForever if infinite
+	ValidLft     time.Duration // Remaining valid lifetime, IPv6LifetimeForever if infinite
+}
+
+// IPv6PrivacyMode is the net.ipv6.conf.<iface>.use_tempaddr setting
+type IPv6PrivacyMode int
+
+const (
+	IPv6PrivacyDisabled  IPv6PrivacyMode = 0 // No temporary addresses (-1 and 0)
+	IPv6PrivacyEnabled   IPv6PrivacyMode = 1 // Temporary addresses exist, stable ones are preferred
+	IPv6PrivacyPreferred IPv6PrivacyMode = 2 // Temporary addresses are preferred for outgoing connections
+)
+
+func (m IPv6PrivacyMode) String() string {
+	switch m {
+	case IPv6PrivacyEnabled:
+		return "enabled, stable address preferred"
+	case IPv6PrivacyPreferred:
+		return "enabled, temporary address preferred"
+	default:
+		return "disabled"
+	}
+}
+
+// IPv6SourceKind tells whether outgoing IPv6 traffic uses a temporary address
+type IPv6SourceKind string
+
+const (
+	IPv6SourceNone      IPv6SourceKind = ""          // Outgoing IPv6 does not leave through this interface
+	IPv6SourceTemporary IPv6SourceKind = "temporary" // Privacy address, rotates
+	IPv6SourceStable    IPv6SourceKind = "stable"    // EUI-64 or stable-privacy address, visible in reverse DNS and reputation lists
+)
+
+// IPv6Privacy is the privacy extension status of an interface
+type IPv6Privacy struct {
+	Mode       IPv6PrivacyMode
+	Source     string // Address the kernel picks for outgoing global traffic
+	SourceKind IPv6SourceKind
 }
 
 // ConnectivityStats contains ping and DNS statistics
 type ConnectivityStats struct {
-	Targets map[string]PingResult
-	DNS     DNSResult
+	Targets     map[string]PingResult
+	IPv6Targets map[string]PingResult // Nil without an IPv6 default route
+	IPv6Gateway string
+	DNS         DNSResult
+	Error       error
+	Partial     bool // A probe panicked, its entry holds the error
 }
 
 type PingResult struct {
 	Target     string
+	Hostname   string // PTR name of an IP target, empty for names or without a PTR record
 	PacketLoss float64
 	MinRtt     time.Duration
 	AvgRtt     time.Duration
 	MaxRtt     time.Duration
+	StdDevRtt  time.Duration
+	Jitter     time.Duration // Mean difference between consecutive RTTs
 	Error      error
+	DSCPError  error // DSCP marking could not be applied, probes were sent unmarked
+
+	// TCP fallback probes only
+	Reachability Reachability
+	Port         int // Port the reachability verdict is for
 }
 
+// Reachability is the verdict of a TCP connect probe
+type Reachability string
+
+const (
+	ReachUnknown  Reachability = ""         // Not a TCP probe, or an error that says nothing about the path
+	ReachOpen     Reachability = "open"     // Handshake completed
+	ReachClosed   Reachability = "closed"   // RST received: host is up, port closed
+	ReachFiltered Reachability = "filtered" // Timeout or ICMP unreachable: likely firewalled
+)
+
 type DNSResult struct {
 	LocalResolverTime  time.Duration
 	PublicResolverTime time.Duration
-	Error              error
+	LocalResolver      string // Server description, e.g. "System" or "1.1.1.1:53 (UDP)"
+	PublicResolver     string
+	PublicAutoSelected bool  // PublicResolver was the fastest of the raced candidates
+	Error              error // Local resolver failure
+	PublicError        error
+	LocalRcode         string // Response code other than NOERROR, the probe domain did not resolve
+	PublicRcode        string
+	Nameservers        []DNSResolverTime // Each resolv.conf nameserver timed on its own
+}
+
+// DNSResolverTime is one resolver's answer to the probe domain
+type DNSResolverTime struct {
+	Server  string // Description, e.g. "192.168.1.1:53 (UDP)"
+	Latency time.Duration
+	Rcode   string // Response code other than NOERROR; the resolver answered
+	Error   error
 }
 
 // TrafficStats contains bandwidth and physical error counts
 type TrafficStats struct {
-	Interfaces map[string]InterfaceTraffic
-	Timestamp  time.Time
+	Interfaces   map[string]InterfaceTraffic
+	Timestamp    time.Time
+	SessionStart time.Time // First collection, the origin of the session counters
+	Error        error
+	Partial      bool // Collection stopped early, some interfaces may be missing
 }
 
 type InterfaceTraffic struct {
-	RxBytes    uint64
-	TxBytes    uint64
-	RxRate     float64 // Bytes per second
-	TxRate     float64 // Bytes per second
-	Drop       uint64
-	Errors     uint64
-	Collisions uint64
+	RxBytes       uint64
+	TxBytes       uint64
+	RxPackets     uint64
+	TxPackets     uint64
+	RxRate        float64 // Bytes per second
+	TxRate        float64 // Bytes per second
+	Drop          uint64
+	Errors        uint64
+	Collisions    uint64
+	FifoErrors    uint64 `report:"detail"` // rx + tx, /proc/net/dev only
+	FrameErrors   uint64 `report:"detail"` // rx, /proc/net/dev only
+	CarrierErrors uint64 `report:"detail"` // tx, /proc/net/dev only
+	Multicast     uint64 `report:"detail"` // rx packets, /proc/net/dev only
+	Up            bool   // Administratively up with a carrier (or no carrier concept, e.g. tun)
+	Session       SessionTraffic
+}
+
+// SessionTraffic is what an interface transferred since lnd started
+// watching it, unlike the counters above which run from boot
+type SessionTraffic struct {
+	RxBytes   uint64
+	TxBytes   uint64
+	RxPackets uint64
+	TxPackets uint64
+	Resets    int // Times the counters went backwards, e.g. driver reload
 }
 
 // KernelStats contains TCP/UDP kernel statistics
@@ -82,7 +247,18 @@ type KernelStats struct {
 	TCPTimeWait     uint64
 	TCPCloseWait    uint64
 	UDPRcvbufErrors uint64
+	ConntrackCount  uint64 // Tracked connections, 0 with ConntrackMax when nf_conntrack is not loaded
+	ConntrackMax    uint64
 	Error           error
+	Partial         bool // Collection stopped early, the counters after the failure are zero
+}
+
+// ConntrackUtilization is the conntrack table fill in percent, 0 if unknown
+func (k KernelStats) ConntrackUtilization() float64 {
+	if k.ConntrackMax == 0 {
+		return 0
+	}
+	return float64(k.ConntrackCount) / float64(k.ConntrackMax) * 100
 }
 
 // Collector defines the interface for data collection
@@ -0,0 +1,69 @@
+package collector
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// SocketInfo is one established TCP connection as the kernel sees it
+type SocketInfo struct {
+	Local   string        // host:port
+	Remote  string        // host:port
+	RTT     time.Duration // Smoothed RTT (tcpi_rtt)
+	RTTVar  time.Duration // RTT variance (tcpi_rttvar)
+	Retrans uint32        // Segments retransmitted over the connection's life
+	Lost    uint32        // Segments currently considered lost
+}
+
+// SocketOrder picks what makes a socket one of the worst for TopSockets
+type SocketOrder int
+
+const (
+	ByRetrans SocketOrder = iota // Most retransmissions first, then highest RTT
+	ByRTT                        // Highest RTT first, then most retransmissions
+)
+
+// TopSockets returns the n established TCP sockets, IPv4 and IPv6, that
+// fare worst in the given order. Sockets the kernel reports no TCP info
+// for are skipped.
+func (c *KernelCollector) TopSockets(n int, by SocketOrder) []SocketInfo {
+	var sockets []SocketInfo
+	for _, family := range []uint8{syscall.AF_INET, syscall.AF_INET6} {
+		diag, err := c.socketDiag(family)
+		if err != nil {
+			continue
+		}
+		for _, d := range diag {
+			if d.InetDiagMsg == nil || d.TCPInfo == nil || d.InetDiagMsg.State != TCP_ESTABLISHED {
+				continue
+			}
+			id := d.InetDiagMsg.ID
+			sockets = append(sockets, SocketInfo{
+				Local:   net.JoinHostPort(id.Source.String(), strconv.Itoa(int(id.SourcePort))),
+				Remote:  net.JoinHostPort(id.Destination.String(), strconv.Itoa(int(id.DestinationPort))),
+				RTT:     time.Duration(d.TCPInfo.Rtt) * time.Microsecond,
+				RTTVar:  time.Duration(d.TCPInfo.Rttvar) * time.Microsecond,
+				Retrans: d.TCPInfo.Total_retrans,
+				Lost:    d.TCPInfo.Lost,
+			})
+		}
+	}
+
+	sort.SliceStable(sockets, func(i, j int) bool {
+		a, b := sockets[i], sockets[j]
+		if by == ByRTT && a.RTT != b.RTT {
+			return a.RTT > b.RTT
+		}
+		if a.Retrans != b.Retrans {
+			return a.Retrans > b.Retrans
+		}
+		return a.RTT > b.RTT
+	})
+	if len(sockets) > n {
+		sockets = sockets[:n]
+	}
+	return sockets
+}
@@ -0,0 +1,56 @@
+package collector
+
+import (
+	"errors"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/vishvananda/netlink"
+)
+
+func TestKernelCollector_TopSockets(t *testing.T) {
+	sock := func(state uint8, remote string, rtt, retrans uint32) *netlink.InetDiagTCPInfoResp {
+		return &netlink.InetDiagTCPInfoResp{
+			InetDiagMsg: &netlink.Socket{State: state, ID: netlink.SocketID{
+				Source: net.ParseIP("192.0.2.10"), SourcePort: 50000,
+				Destination: net.ParseIP(remote), DestinationPort: 443,
+			}},
+			TCPInfo: &netlink.TCPInfo{Rtt: rtt, Rttvar: rtt / 4, Total_retrans: retrans},
+		}
+	}
+	c, _ := NewKernelCollector()
+	c.socketDiag = func(family uint8) ([]*netlink.InetDiagTCPInfoResp, error) {
+		if family == syscall.AF_INET6 {
+			return nil, errors.New("no IPv6")
+		}
+		return []*netlink.InetDiagTCPInfoResp{
+			sock(TCP_ESTABLISHED, "198.51.100.1", 20000, 0),
+			sock(TCP_ESTABLISHED, "198.51.100.2", 300000, 0),
+			sock(TCP_ESTABLISHED, "198.51.100.3", 5000, 12),
+			sock(TCP_TIME_WAIT, "198.51.100.4", 900000, 40),        // Not established
+			{InetDiagMsg: &netlink.Socket{State: TCP_ESTABLISHED}}, // No TCP info
+		}, nil
+	}
+
+	got := c.TopSockets(2, ByRetrans)
+	if len(got) != 2 {
+		t.Fatalf("TopSockets(2) = %+v, want 2 sockets", got)
+	}
+	if got[0].Remote != "198.51.100.3:443" || got[0].Retrans != 12 {
+		t.Errorf("first socket = %+v, want the retransmitting one", got[0])
+	}
+	if got[1].Remote != "198.51.100.2:443" || got[1].RTT != 300*time.Millisecond || got[1].RTTVar != 75*time.Millisecond {
+		t.Errorf("second socket = %+v, want the 300ms one", got[1])
+	}
+	if got[0].Local != "192.0.2.10:50000" {
+		t.Errorf("local address = %q", got[0].Local)
+	}
+
+	// By RTT the slowest sockets win even without retransmissions
+	got = c.TopSockets(2, ByRTT)
+	if len(got) != 2 || got[0].Remote != "198.51.100.2:443" || got[1].Remote != "198.51.100.1:443" {
+		t.Errorf("TopSockets(2, ByRTT) = %+v, want the 300ms and 20ms sockets", got)
+	}
+}
@@ -2,8 +2,11 @@ package collector
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
@@ -16,27 +19,25 @@ import (
 const (
 	TCP_ESTABLISHED = 1
 	TCP_TIME_WAIT   = 6
+	TCP_CLOSE       = 7 // Also an unconnected UDP socket
 	TCP_CLOSE_WAIT  = 8
+	TCP_LISTEN      = 10
 )
 
 type KernelCollector struct {
 	lastRetrans float64
 	lastOutSegs float64
 	mu          sync.Mutex
+
+	socketDiag func(family uint8) ([]*netlink.InetDiagTCPInfoResp, error)
 }
 
 func NewKernelCollector() (*KernelCollector, error) {
-	return &KernelCollector{}, nil
+	return &KernelCollector{socketDiag: netlink.SocketDiagTCPInfo}, nil
 }
 
 func (c *KernelCollector) Collect() (stats KernelStats, err error) {
-	defer func() {
-		if r := recover(); r != nil {
-			err = fmt.Errorf("panic in KernelCollector: %v", r)
-			// Ensure we return the stats we have so far, or empty stats with error
-			stats.Error = err
-		}
-	}()
+	defer recoverPartial("KernelCollector", &stats.Partial, &stats.Error, &err)
 
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -80,8 +81,13 @@ func (c *KernelCollector) Collect() (stats KernelStats, err error) {
 		}
 	}
 
-	// 2. TCP States via Netlink (InetDiag)
-	diag, err := netlink.SocketDiagTCPInfo(syscall.AF_INET)
+	// 2. Conntrack table, absent unless nf_conntrack is loaded
+	if count, max, err := readConntrack("/proc/sys"); err == nil {
+		stats.ConntrackCount, stats.ConntrackMax = count, max
+	}
+
+	// 3. TCP States via Netlink (InetDiag)
+	diag, err := c.socketDiag(syscall.AF_INET)
 	if err == nil {
 		for _, info := range diag {
 			switch info.InetDiagMsg.State {
@@ -98,6 +104,28 @@ func (c *KernelCollector) Collect() (stats KernelStats, err error) {
 	return stats, nil
 }
 
+// readConntrack reads the conntrack entry count and limit from a procfs
+// sysctl root (normally /proc/sys). Missing files mean the module is not
+// loaded and give zeros without an error.
+func readConntrack(root string) (count, max uint64, err error) {
+	read := func(name string) (uint64, error) {
+		content, err := os.ReadFile(filepath.Join(root, "net/netfilter", name))
+		if err != nil {
+			return 0, err
+		}
+		return strconv.ParseUint(strings.TrimSpace(string(content)), 10, 64)
+	}
+	if count, err = read("nf_conntrack_count"); errors.Is(err, fs.ErrNotExist) {
+		return 0, 0, nil
+	} else if err != nil {
+		return 0, 0, fmt.Errorf("nf_conntrack_count: %w", err)
+	}
+	if max, err = read("nf_conntrack_max"); err != nil {
+		return 0, 0, fmt.Errorf("nf_conntrack_max: %w", err)
+	}
+	return count, max, nil
+}
+
 func parseNetSnmp() (result map[string]map[string]float64, err error) {
 	defer func() {
 		if r := recover(); r != nil {
@@ -2,7 +2,10 @@ package collector
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
+
+	"github.com/vishvananda/netlink"
 )
 
 func TestKernelCollector_Collect(t *testing.T) {
@@ -45,3 +48,52 @@ func TestParseNetSnmp(t *testing.T) {
 		t.Error("Missing Tcp stats in SNMP data")
 	}
 }
+
+func TestKernelCollector_PartialOnPanic(t *testing.T) {
+	if _, err := os.Stat("/proc/net/snmp"); os.IsNotExist(err) {
+		t.Skip("/proc/net/snmp not found, skipping kernel stats test")
+	}
+	c, _ := NewKernelCollector()
+	c.socketDiag = func(uint8) ([]*netlink.InetDiagTCPInfoResp, error) {
+		panic("simulated netlink failure")
+	}
+
+	stats, err := c.Collect()
+	if err == nil || !stats.Partial || stats.Error == nil {
+		t.Fatalf("Collect() = %+v, %v; want partial stats with the panic as error", stats, err)
+	}
+	if c.lastRetrans > 0 && stats.TCPRetransRate == 0 {
+		t.Error("SNMP counters read before the panic were dropped")
+	}
+	// The collector stays usable, its lock was released
+	c.socketDiag = func(uint8) ([]*netlink.InetDiagTCPInfoResp, error) { return nil, nil }
+	if stats, err := c.Collect(); err != nil || stats.Partial {
+		t.Errorf("second Collect() = partial %v, error %v", stats.Partial, err)
+	}
+}
+
+func TestReadConntrack(t *testing.T) {
+	root := t.TempDir()
+	if count, max, err := readConntrack(root); err != nil || count != 0 || max != 0 {
+		t.Errorf("without nf_conntrack: %d/%d, %v; want zeros and no error", count, max, err)
+	}
+
+	dir := filepath.Join(root, "net/netfilter")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, "nf_conntrack_count"), []byte("54000\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "nf_conntrack_max"), []byte("65536\n"), 0o644)
+	count, max, err := readConntrack(root)
+	if err != nil || count != 54000 || max != 65536 {
+		t.Fatalf("readConntrack() = %d/%d, %v", count, max, err)
+	}
+	if u := (KernelStats{ConntrackCount: count, ConntrackMax: max}).ConntrackUtilization(); u < 82 || u > 83 {
+		t.Errorf("ConntrackUtilization() = %.1f, want about 82.4", u)
+	}
+
+	os.WriteFile(filepath.Join(dir, "nf_conntrack_max"), []byte("lots\n"), 0o644)
+	if _, _, err := readConntrack(root); err == nil {
+		t.Error("expected an error for a malformed limit")
+	}
+}
@@ -0,0 +1,139 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/vishvananda/netlink"
+)
+
+// ListenSocket is a socket waiting for connections or datagrams
+type ListenSocket struct {
+	Proto   string // tcp or udp
+	Addr    string // Local address, 0.0.0.0 or :: for all
+	Port    int
+	PID     int    // Owning process, 0 if unknown, e.g. another user's without root
+	Process string // Command name of PID
+}
+
+// ListenResult is the listening sockets as shown and reported
+type ListenResult struct {
+	Sockets []ListenSocket
+	Error   error
+}
+
+// ListenCollector lists listening TCP and unconnected UDP sockets with the
+// processes that own them
+type ListenCollector struct {
+	procRoot string
+	tcpDiag  func(family uint8) ([]*netlink.Socket, error)
+	udpDiag  func(family uint8) ([]*netlink.Socket, error)
+}
+
+func NewListenCollector() *ListenCollector {
+	return &ListenCollector{procRoot: "/proc", tcpDiag: netlink.SocketDiagTCP, udpDiag: netlink.SocketDiagUDP}
+}
+
+// Collect dumps the sockets over inet_diag and maps them to processes by
+// their inode. Without root only the caller's own processes can be read,
+// the other sockets are returned without an owner.
+func (c *ListenCollector) Collect() ([]ListenSocket, error) {
+	owners := socketOwners(c.procRoot)
+
+	var sockets []ListenSocket
+	var errs []error
+	dumps := []struct {
+		proto string
+		state uint8
+		diag  func(uint8) ([]*netlink.Socket, error)
+	}{
+		{"tcp", TCP_LISTEN, c.tcpDiag},
+		{"udp", TCP_CLOSE, c.udpDiag}, // Unconnected
+	}
+	for _, d := range dumps {
+		for _, family := range []uint8{syscall.AF_INET, syscall.AF_INET6} {
+			socks, err := d.diag(family)
+			// An interrupted dump is still worth showing
+			if err != nil && !errors.Is(err, netlink.ErrDumpInterrupted) {
+				errs = append(errs, fmt.Errorf("%s sockets: %w", d.proto, err))
+				continue
+			}
+			for _, s := range socks {
+				if s.State != d.state || s.ID.DestinationPort != 0 {
+					continue
+				}
+				ls := ListenSocket{Proto: d.proto, Addr: s.ID.Source.String(), Port: int(s.ID.SourcePort)}
+				if owner, ok := owners[s.INode]; ok {
+					ls.PID, ls.Process = owner.pid, owner.name
+				}
+				sockets = append(sockets, ls)
+			}
+		}
+	}
+	if len(errs) == len(dumps)*2 {
+		return nil, errs[0]
+	}
+
+	sort.SliceStable(sockets, func(i, j int) bool {
+		if sockets[i].Proto != sockets[j].Proto {
+			return sockets[i].Proto < sockets[j].Proto
+		}
+		if sockets[i].Port != sockets[j].Port {
+			return sockets[i].Port < sockets[j].Port
+		}
+		return sockets[i].Addr < sockets[j].Addr
+	})
+	return sockets, nil
+}
+
+type socketOwner struct {
+	pid  int
+	name string
+}
+
+// socketOwners maps socket inodes to the process holding them by reading
+// the socket:[inode] links in /proc/<pid>/fd. Processes that cannot be
+// read are skipped.
+func socketOwners(procRoot string) map[uint32]socketOwner {
+	owners := make(map[uint32]socketOwner)
+	entries, err := os.ReadDir(procRoot)
+	if err != nil {
+		return owners
+	}
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		fdDir := filepath.Join(procRoot, e.Name(), "fd")
+		fds, err := os.ReadDir(fdDir)
+		if err != nil {
+			continue
+		}
+		var name string
+		for _, fd := range fds {
+			link, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+			if err != nil || !strings.HasPrefix(link, "socket:[") {
+				continue
+			}
+			inode, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(link, "socket:["), "]"), 10, 32)
+			if err != nil {
+				continue
+			}
+			if name == "" {
+				comm, _ := os.ReadFile(filepath.Join(procRoot, e.Name(), "comm"))
+				name = strings.TrimSpace(string(comm))
+			}
+			if _, seen := owners[uint32(inode)]; !seen {
+				owners[uint32(inode)] = socketOwner{pid: pid, name: name}
+			}
+		}
+	}
+	return owners
+}
@@ -0,0 +1,77 @@
+package collector
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+)
+
+func TestListenCollector_Collect(t *testing.T) {
+	// sshd (pid 812) owns inode 1001; the socket of inode 1003 belongs to a
+	// process that cannot be read
+	proc := t.TempDir()
+	fd := filepath.Join(proc, "812", "fd")
+	if err := os.MkdirAll(fd, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(proc, "812", "comm"), []byte("sshd\n"), 0o644)
+	os.Symlink("socket:[1001]", filepath.Join(fd, "3"))
+	os.Symlink("/dev/null", filepath.Join(fd, "0"))
+	os.MkdirAll(filepath.Join(proc, "self"), 0o755)
+
+	sock := func(state uint8, addr string, port, remotePort uint16, inode uint32) *netlink.Socket {
+		return &netlink.Socket{State: state, INode: inode, ID: netlink.SocketID{
+			Source: net.ParseIP(addr), SourcePort: port, DestinationPort: remotePort,
+		}}
+	}
+	c := &ListenCollector{
+		procRoot: proc,
+		tcpDiag: func(family uint8) ([]*netlink.Socket, error) {
+			if family == syscall.AF_INET6 {
+				return []*netlink.Socket{sock(TCP_LISTEN, "::", 22, 0, 1002)}, nil
+			}
+			return []*netlink.Socket{
+				sock(TCP_LISTEN, "0.0.0.0", 22, 0, 1001),
+				sock(TCP_ESTABLISHED, "192.0.2.10", 22, 50000, 1004),
+			}, nil
+		},
+		udpDiag: func(family uint8) ([]*netlink.Socket, error) {
+			if family == syscall.AF_INET6 {
+				return nil, errors.New("no IPv6")
+			}
+			return []*netlink.Socket{
+				sock(TCP_CLOSE, "127.0.0.53", 53, 0, 1003),
+				sock(TCP_CLOSE, "192.0.2.10", 40000, 443, 1005), // Connected
+			}, nil
+		},
+	}
+
+	got, err := c.Collect()
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	want := []ListenSocket{
+		{Proto: "tcp", Addr: "0.0.0.0", Port: 22, PID: 812, Process: "sshd"},
+		{Proto: "tcp", Addr: "::", Port: 22},
+		{Proto: "udp", Addr: "127.0.0.53", Port: 53},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Collect() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("socket %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	fail := func(uint8) ([]*netlink.Socket, error) { return nil, errors.New("netlink: permission denied") }
+	c.tcpDiag, c.udpDiag = fail, fail
+	if _, err := c.Collect(); err == nil {
+		t.Error("Collect() should fail when no dump works")
+	}
+}
@@ -0,0 +1,179 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// MatrixMethod is a single probing method (a column of the connectivity matrix)
+type MatrixMethod string
+
+const (
+	MethodICMP   MatrixMethod = "ICMP"
+	MethodTCP80  MatrixMethod = "TCP:80"
+	MethodTCP443 MatrixMethod = "TCP:443"
+	MethodUDPDNS MatrixMethod = "UDP:53"
+)
+
+var DefaultMatrixMethods = []MatrixMethod{MethodICMP, MethodTCP80, MethodTCP443, MethodUDPDNS}
+
+// MatrixCell is the outcome of probing one target with one method
+type MatrixCell struct {
+	Reachable bool
+	Latency   time.Duration
+	Error     error
+}
+
+// ConnectivityMatrix holds reachability of targets (rows) over methods (columns)
+type ConnectivityMatrix struct {
+	Targets []string
+	Methods []MatrixMethod
+	Cells   map[string]map[MatrixMethod]MatrixCell
+}
+
+// Cell returns the result for a target/method pair
+func (m ConnectivityMatrix) Cell(target string, method MatrixMethod) (MatrixCell, bool) {
+	row, ok := m.Cells[target]
+	if !ok {
+		return MatrixCell{}, false
+	}
+	cell, ok := row[method]
+	return cell, ok
+}
+
+// MatrixProbe probes a single target with one method
+type MatrixProbe func(target string) MatrixCell
+
+type MatrixCollector struct {
+	Targets []string
+	Methods []MatrixMethod
+	PingOptions
+	Source *SourceInterface // Binds every probe to one interface's addresses
+	Budget *Budget          // Shared probe budget, cells over it are skipped
+	probes map[MatrixMethod]MatrixProbe
+}
+
+func NewMatrixCollector(targets []string) *MatrixCollector {
+	c := &MatrixCollector{
+		Targets: targets,
+		Methods: DefaultMatrixMethods,
+	}
+	c.Privileged = true
+	c.probes = map[MatrixMethod]MatrixProbe{
+		MethodICMP:   c.probeICMP,
+		MethodTCP80:  func(t string) MatrixCell { return c.probeTCP(t, 80) },
+		MethodTCP443: func(t string) MatrixCell { return c.probeTCP(t, 443) },
+		MethodUDPDNS: c.probeUDPDNS,
+	}
+	return c
+}
+
+func (c *MatrixCollector) Collect() ConnectivityMatrix {
+	matrix := ConnectivityMatrix{
+		Targets: c.Targets,
+		Methods: c.Methods,
+		Cells:   make(map[string]map[MatrixMethod]MatrixCell),
+	}
+	for _, target := range c.Targets {
+		matrix.Cells[target] = make(map[MatrixMethod]MatrixCell)
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+
+	for _, target := range c.Targets {
+		for _, method := range c.Methods {
+			// Probes of earlier methods already write this row, skipped
+			// cells go in under the same lock
+			probe, ok := c.probes[method]
+			if !ok {
+				mu.Lock()
+				matrix.Cells[target][method] = MatrixCell{Error: fmt.Errorf("unsupported method: %s", method)}
+				mu.Unlock()
+				continue
+			}
+			if probes, bytes := c.probeCost(method); !c.Budget.Allow(probes, bytes) {
+				mu.Lock()
+				matrix.Cells[target][method] = MatrixCell{Error: ErrBudgetExceeded}
+				mu.Unlock()
+				continue
+			}
+			wg.Add(1)
+			go func(t string, m MatrixMethod, p MatrixProbe) {
+				defer wg.Done()
+				defer func() {
+					if r := recover(); r != nil {
+						mu.Lock()
+						matrix.Cells[t][m] = MatrixCell{Error: fmt.Errorf("panic in %s probe: %v", m, r)}
+						mu.Unlock()
+					}
+				}()
+				cell := p(t)
+				mu.Lock()
+				matrix.Cells[t][m] = cell
+				mu.Unlock()
+			}(target, method, probe)
+		}
+	}
+
+	wg.Wait()
+	return matrix
+}
+
+// probeCost is what one cell of method sends
+func (c *MatrixCollector) probeCost(method MatrixMethod) (int, int64) {
+	switch method {
+	case MethodICMP:
+		return c.count(), int64(c.count() * c.probeBytes())
+	case MethodUDPDNS:
+		return 1, dnsProbeBytes
+	default:
+		return 1, tcpProbeBytes
+	}
+}
+
+// probeICMP pings the target like the connectivity check. A ping that had
+// to fall back to TCP connects means ICMP did not get through.
+func (c *MatrixCollector) probeICMP(target string) MatrixCell {
+	res := pingTarget(target, c.PingOptions, c.Source)
+	switch {
+	case res.Port != 0:
+		return MatrixCell{Error: fmt.Errorf("no echo reply")}
+	case res.Error != nil:
+		return MatrixCell{Error: res.Error}
+	case res.PacketLoss >= 100:
+		return MatrixCell{Error: fmt.Errorf("no echo reply")}
+	}
+	return MatrixCell{Reachable: true, Latency: res.AvgRtt}
+}
+
+// probeTCP connects to one port. Unlike the ping fallback a refused
+// connection does not count, the port itself has to be reachable.
+func (c *MatrixCollector) probeTCP(target string, port int) MatrixCell {
+	res := tcpPing(net.JoinHostPort(target, strconv.Itoa(port)), c.DSCP, c.Source)
+	switch res.Reachability {
+	case ReachOpen:
+		return MatrixCell{Reachable: true, Latency: res.AvgRtt}
+	case ReachClosed:
+		return MatrixCell{Error: fmt.Errorf("port %d closed", port)}
+	}
+	return MatrixCell{Error: res.Error}
+}
+
+// probeUDPDNS sends a DNS query over UDP. Any response (even REFUSED) proves UDP/53 passes.
+func (c *MatrixCollector) probeUDPDNS(target string) MatrixCell {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	server := DNSServer{Address: net.JoinHostPort(target, "53"), Proto: ProtoUDP}
+	resolver := NewDNSCollector()
+	resolver.Source = c.Source // Leaves through the same interface as the other probes
+	res := resolver.Lookup(ctx, "example.com", RecordA, server)
+	if res.Error != nil {
+		return MatrixCell{Error: res.Error}
+	}
+	return MatrixCell{Reachable: true, Latency: res.Latency}
+}
@@ -0,0 +1,59 @@
+package collector
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMatrixCollector_Collect(t *testing.T) {
+	c := NewMatrixCollector([]string{"open.example", "web-only.example"})
+
+	// Mock probes: web-only.example blocks ICMP and UDP DNS but allows TCP
+	blocked := map[string]bool{
+		"web-only.example/" + string(MethodICMP):   true,
+		"web-only.example/" + string(MethodUDPDNS): true,
+	}
+	for _, method := range c.Methods {
+		m := method
+		c.probes[m] = func(target string) MatrixCell {
+			if blocked[target+"/"+string(m)] {
+				return MatrixCell{Error: fmt.Errorf("timeout")}
+			}
+			return MatrixCell{Reachable: true, Latency: 10 * time.Millisecond}
+		}
+	}
+
+	matrix := c.Collect()
+
+	if len(matrix.Cells) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(matrix.Cells))
+	}
+
+	for _, target := range matrix.Targets {
+		for _, method := range matrix.Methods {
+			cell, ok := matrix.Cell(target, method)
+			if !ok {
+				t.Fatalf("missing cell %s/%s", target, method)
+			}
+			wantReachable := !blocked[target+"/"+string(method)]
+			if cell.Reachable != wantReachable {
+				t.Errorf("%s/%s: reachable = %v, want %v", target, method, cell.Reachable, wantReachable)
+			}
+			if !cell.Reachable && cell.Error == nil {
+				t.Errorf("%s/%s: expected error for blocked cell", target, method)
+			}
+		}
+	}
+}
+
+func TestMatrixCollector_UnsupportedMethod(t *testing.T) {
+	c := NewMatrixCollector([]string{"host.example"})
+	c.Methods = []MatrixMethod{"SCTP:80"}
+
+	matrix := c.Collect()
+	cell, ok := matrix.Cell("host.example", "SCTP:80")
+	if !ok || cell.Error == nil {
+		t.Errorf("expected error cell for unsupported method, got %+v", cell)
+	}
+}
@@ -0,0 +1,131 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/vishvananda/netlink"
+)
+
+const (
+	tcpiOptTimestamps = 1  // TCPI_OPT_TIMESTAMPS in tcp_info.tcpi_options
+	tcpTimestampLen   = 12 // Timestamp option plus padding, excluded from tcpi_snd_mss
+	ipv4TCPHeaders    = 40 // IPv4 + TCP header without options
+	ipv6TCPHeaders    = 60 // IPv6 + TCP header without options
+)
+
+// MSSResult compares the MSS this host advertised with the one the
+// connection ended up using. A router clamping MSS on the path (PPPoE,
+// VPN) rewrites the peer's SYN-ACK, so the effective MSS comes out lower;
+// so does a peer with a smaller MTU, the two look the same from here.
+type MSSResult struct {
+	Target     string // host:port
+	Advertised int    // MSS sent in our SYN, from the local route MTU
+	Effective  int    // MSS the connection sends with, TCP options included
+	PathMTU    int    // Effective MSS plus IP and TCP headers
+	Lowered    bool   // Effective below advertised: clamped on the path or a small peer MTU
+	Error      error
+}
+
+type MSSCollector struct {
+	Targets []string // host:port
+	Timeout time.Duration
+	Budget  *Budget // Shared probe budget, targets over it are skipped
+}
+
+func NewMSSCollector(targets []string) *MSSCollector {
+	return &MSSCollector{Targets: targets, Timeout: 3 * time.Second}
+}
+
+// Collect probes every target concurrently, keeping the target order
+func (c *MSSCollector) Collect(ctx context.Context) []MSSResult {
+	results := make([]MSSResult, len(c.Targets))
+	var wg sync.WaitGroup
+	for i, target := range c.Targets {
+		if !c.Budget.Allow(1, tcpProbeBytes) {
+			results[i] = MSSResult{Target: target, Error: ErrBudgetExceeded}
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = probeMSS(ctx, target, c.Timeout)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// probeMSS opens a TCP connection and reads its MSS from socket diag
+func probeMSS(ctx context.Context, target string, timeout time.Duration) MSSResult {
+	res := MSSResult{Target: target}
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", target)
+	if err != nil {
+		res.Error = err
+		return res
+	}
+	defer conn.Close()
+
+	local := conn.LocalAddr().(*net.TCPAddr)
+	remote := conn.RemoteAddr().(*net.TCPAddr)
+	info, err := tcpInfoFor(local, remote)
+	if err != nil {
+		res.Error = fmt.Errorf("socket diag: %w", err)
+		return res
+	}
+
+	headers := ipv4TCPHeaders
+	if local.IP.To4() == nil {
+		headers = ipv6TCPHeaders
+	}
+	res.Advertised, res.Effective, res.Lowered = classifyMSS(info)
+	res.PathMTU = res.Effective + headers
+	return res
+}
+
+// classifyMSS derives the advertised and effective MSS from tcp_info.
+// tcpi_snd_mss excludes the timestamp option while tcpi_advmss does not,
+// so the option is added back before comparing.
+func classifyMSS(info *netlink.TCPInfo) (advertised, effective int, lowered bool) {
+	advertised = int(info.Advmss)
+	effective = int(info.Snd_mss)
+	if info.Options&tcpiOptTimestamps != 0 {
+		effective += tcpTimestampLen
+	}
+	return advertised, effective, effective < advertised
+}
+
+// tcpInfoFor finds the connection local -> remote in the kernel's socket table
+func tcpInfoFor(local, remote *net.TCPAddr) (*netlink.TCPInfo, error) {
+	family := uint8(syscall.AF_INET)
+	if local.IP.To4() == nil {
+		family = syscall.AF_INET6
+	}
+	sockets, err := netlink.SocketDiagTCPInfo(family)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range sockets {
+		id := s.InetDiagMsg.ID
+		if int(id.SourcePort) == local.Port && int(id.DestinationPort) == remote.Port &&
+			id.Source.Equal(local.IP) && id.Destination.Equal(remote.IP) && s.TCPInfo != nil {
+			return s.TCPInfo, nil
+		}
+	}
+	return nil, fmt.Errorf("connection %s -> %s not found", local, remote)
+}
+
+// MSSTargets turns connectivity targets into probe addresses, port 443
+// unless a target has its own
+func MSSTargets(targets []string) []string {
+	var out []string
+	for _, t := range targets {
+		out = append(out, tcpProbeAddress(t, 443))
+	}
+	return out
+}
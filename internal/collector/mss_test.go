@@ -0,0 +1,98 @@
+package collector
+
+import (
+	"context"
+	"net"
+	"slices"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/vishvananda/netlink"
+)
+
+// listenWithMSS starts a TCP listener on loopback, advertising mss in its
+// SYN-ACK when mss > 0, like a peer behind an MSS-clamping router
+func listenWithMSS(t *testing.T, mss int) net.Listener {
+	t.Helper()
+	lc := net.ListenConfig{Control: func(network, address string, c syscall.RawConn) error {
+		if mss == 0 {
+			return nil
+		}
+		var serr error
+		err := c.Control(func(fd uintptr) {
+			serr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_MAXSEG, mss)
+		})
+		if err != nil {
+			return err
+		}
+		return serr
+	}}
+	ln, err := lc.Listen(context.Background(), "tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	return ln
+}
+
+func TestProbeMSS(t *testing.T) {
+	ln := listenWithMSS(t, 0)
+	res := probeMSS(context.Background(), ln.Addr().String(), time.Second)
+	if res.Error != nil {
+		t.Skipf("socket diag unavailable: %v", res.Error)
+	}
+	if res.Advertised <= 0 || res.Effective <= 0 {
+		t.Fatalf("MSS not read: advertised %d, effective %d", res.Advertised, res.Effective)
+	}
+	// No clamping verdict here: on loopback the kernel bounds the send MSS
+	// to half the peer's initial window, well below the 64k MTU
+	if res.PathMTU != res.Effective+ipv4TCPHeaders {
+		t.Errorf("PathMTU = %d, want %d", res.PathMTU, res.Effective+ipv4TCPHeaders)
+	}
+
+	clamped := listenWithMSS(t, 1200)
+	res = probeMSS(context.Background(), clamped.Addr().String(), time.Second)
+	if res.Error != nil {
+		t.Fatalf("probeMSS() error = %v", res.Error)
+	}
+	if !res.Lowered || res.Effective > 1200 {
+		t.Errorf("expected a lowered MSS of at most 1200, got %+v", res)
+	}
+}
+
+func TestClassifyMSS(t *testing.T) {
+	tests := []struct {
+		name      string
+		info      netlink.TCPInfo
+		effective int
+		lowered   bool
+	}{
+		{"timestamps, full size", netlink.TCPInfo{Advmss: 1460, Snd_mss: 1448, Options: tcpiOptTimestamps}, 1460, false},
+		{"no timestamps, full size", netlink.TCPInfo{Advmss: 1460, Snd_mss: 1460}, 1460, false},
+		{"PPPoE clamp", netlink.TCPInfo{Advmss: 1460, Snd_mss: 1440, Options: tcpiOptTimestamps}, 1452, true},
+	}
+	for _, tt := range tests {
+		_, effective, lowered := classifyMSS(&tt.info)
+		if effective != tt.effective || lowered != tt.lowered {
+			t.Errorf("%s: effective %d lowered %v, want %d %v", tt.name, effective, lowered, tt.effective, tt.lowered)
+		}
+	}
+}
+
+func TestMSSTargets(t *testing.T) {
+	got := MSSTargets([]string{"8.8.8.8", "example.com:8443", "2606:4700:4700::1111", "[2001:db8::1]:993", "bad target"})
+	want := []string{"8.8.8.8:443", "example.com:8443", "[2606:4700:4700::1111]:443", "[2001:db8::1]:993", "bad target"}
+	if !slices.Equal(got, want) {
+		t.Errorf("MSSTargets() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,300 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+const (
+	minIPv4MTU   = 68   // Smallest MTU an IPv4 link may have (RFC 791)
+	minIPv6MTU   = 1280 // Smallest MTU an IPv6 link may have (RFC 8200)
+	ipv4EchoHdrs = 28   // IPv4 + ICMP echo header
+	ipv6EchoHdrs = 48   // IPv6 + ICMPv6 echo header
+)
+
+// ErrNoRawSocket means path MTU probing needs privileges this process lacks
+var ErrNoRawSocket = errors.New("raw ICMP socket needs root or CAP_NET_RAW")
+
+// PathMTUResult is the largest packet that reaches a target unfragmented.
+// A VPN or tunnel that drops ICMP "fragmentation needed" black-holes
+// anything larger, while small packets such as pings still get through.
+type PathMTUResult struct {
+	Target       string
+	MTU          int  // Path MTU, the interface MTU when Fallback is set
+	InterfaceMTU int  // MTU of the route to the target
+	Fallback     bool // No raw socket, MTU was not probed
+	Error        error
+}
+
+// Reduced reports whether the path carries less than the interface does
+func (r PathMTUResult) Reduced() bool {
+	return !r.Fallback && r.MTU > 0 && r.MTU < r.InterfaceMTU
+}
+
+// PathMTUCollector binary-searches echo request sizes with the DF bit set
+type PathMTUCollector struct {
+	Targets []string
+	Timeout time.Duration    // Wait for each probe's reply
+	Budget  *Budget          // Shared probe budget, the search stops when it runs out
+	Source  *SourceInterface // Probes leave from this interface's addresses
+
+	probe    func(dst *net.IPAddr, size int, timeout time.Duration) (bool, error)
+	routeMTU func(dst net.IP) (int, error)
+}
+
+func NewPathMTUCollector(targets []string) *PathMTUCollector {
+	c := &PathMTUCollector{
+		Targets:  targets,
+		Timeout:  time.Second,
+		routeMTU: routeMTU,
+	}
+	c.probe = func(dst *net.IPAddr, size int, timeout time.Duration) (bool, error) {
+		return probeEcho(dst, size, timeout, c.Source)
+	}
+	return c
+}
+
+// Collect probes every target concurrently, keeping the target order
+func (c *PathMTUCollector) Collect(ctx context.Context) []PathMTUResult {
+	results := make([]PathMTUResult, len(c.Targets))
+	var wg sync.WaitGroup
+	for i, target := range c.Targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = c.collect(ctx, target)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+func (c *PathMTUCollector) collect(ctx context.Context, target string) PathMTUResult {
+	res := PathMTUResult{Target: target}
+	dst, err := c.resolve(ctx, target)
+	if err != nil {
+		res.Error = err
+		return res
+	}
+	res.InterfaceMTU, _ = c.routeMTU(dst.IP)
+	res.MTU, err = c.discover(dst)
+	if errors.Is(err, ErrNoRawSocket) {
+		res.Fallback = true
+		return res
+	}
+	res.Error = err
+	return res
+}
+
+// DiscoverPathMTU returns the largest packet size that reaches target with
+// the DF bit set. Without a raw socket it returns the interface MTU of the
+// route to target along with an error wrapping ErrNoRawSocket.
+func (c *PathMTUCollector) DiscoverPathMTU(target string) (int, error) {
+	dst, err := c.resolve(context.Background(), target)
+	if err != nil {
+		return 0, err
+	}
+	return c.discover(dst)
+}
+
+func (c *PathMTUCollector) resolve(ctx context.Context, target string) (*net.IPAddr, error) {
+	ips, err := net.DefaultResolver.LookupIP(ctx, "ip", target)
+	if err != nil {
+		return nil, err
+	}
+	return &net.IPAddr{IP: ips[0]}, nil
+}
+
+// discover searches between the protocol minimum, which every path must
+// carry, and the interface MTU. A size counts as too big when the reply
+// does not arrive in time, so a lossy path can read low.
+func (c *PathMTUCollector) discover(dst *net.IPAddr) (int, error) {
+	probe := func(size int) (bool, error) {
+		if !c.Budget.Allow(1, 2*int64(size)) { // The echo comes back as large
+			return false, ErrBudgetExceeded
+		}
+		return c.probe(dst, size, c.Timeout)
+	}
+	hi, err := c.routeMTU(dst.IP)
+	if err != nil {
+		return 0, fmt.Errorf("route MTU: %w", err)
+	}
+	lo := minIPv4MTU
+	if dst.IP.To4() == nil {
+		lo = minIPv6MTU
+	}
+
+	ok, err := probe(lo)
+	if err != nil {
+		if errors.Is(err, ErrNoRawSocket) {
+			return hi, err
+		}
+		return 0, err
+	}
+	if !ok {
+		return 0, fmt.Errorf("no echo reply within %s", c.Timeout)
+	}
+	if hi <= lo {
+		return lo, nil
+	}
+	if ok, err := probe(hi); err != nil || ok {
+		return hi, err
+	}
+	// Invariant: lo gets through, hi does not
+	for hi-lo > 1 {
+		mid := (lo + hi) / 2
+		ok, err := probe(mid)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return lo, nil
+}
+
+// routeMTU is the MTU of the route to dst: the route's mtu metric if set,
+// otherwise its interface's
+func routeMTU(dst net.IP) (int, error) {
+	routes, err := netlink.RouteGet(dst)
+	if err != nil {
+		return 0, err
+	}
+	if len(routes) == 0 {
+		return 0, fmt.Errorf("no route to %s", dst)
+	}
+	if routes[0].MTU > 0 {
+		return routes[0].MTU, nil
+	}
+	link, err := netlink.LinkByIndex(routes[0].LinkIndex)
+	if err != nil {
+		return 0, err
+	}
+	return link.Attrs().MTU, nil
+}
+
+// pmtuSeq numbers the probes, the raw socket sees every echo reply
+var pmtuSeq atomic.Uint32
+
+// probeEcho sends one echo request of size bytes, IP header included, with
+// fragmentation forbidden and the kernel's cached path MTU ignored. It
+// reports whether the reply came back.
+func probeEcho(dst *net.IPAddr, size int, timeout time.Duration, src *SourceInterface) (bool, error) {
+	network, level, opt, hdrs := "ip4:icmp", syscall.IPPROTO_IP, syscall.IP_MTU_DISCOVER, ipv4EchoHdrs
+	var echo, reply icmp.Type = ipv4.ICMPTypeEcho, ipv4.ICMPTypeEchoReply
+	var tooBig icmp.Type = ipv4.ICMPTypeDestinationUnreachable
+	proto := ipv4.ICMPTypeEcho.Protocol()
+	if dst.IP.To4() == nil {
+		network, level, opt, hdrs = "ip6:ipv6-icmp", syscall.IPPROTO_IPV6, syscall.IPV6_MTU_DISCOVER, ipv6EchoHdrs
+		echo, reply, tooBig = ipv6.ICMPTypeEchoRequest, ipv6.ICMPTypeEchoReply, ipv6.ICMPTypePacketTooBig
+		proto = ipv6.ICMPTypeEchoRequest.Protocol()
+	}
+
+	local := ""
+	if ip := src.sourceFor(dst.IP.String()); ip != nil {
+		local = ip.String()
+	}
+	conn, err := net.ListenPacket(network, local)
+	if err != nil {
+		if errors.Is(err, syscall.EPERM) || errors.Is(err, syscall.EACCES) {
+			return false, fmt.Errorf("%w: %v", ErrNoRawSocket, err)
+		}
+		return false, err
+	}
+	defer conn.Close()
+	// IP_PMTUDISC_PROBE and IPV6_PMTUDISC_PROBE share a value: set DF, ignore the cached PMTU
+	if err := setSockopt(conn.(*net.IPConn), level, opt, syscall.IP_PMTUDISC_PROBE); err != nil {
+		return false, fmt.Errorf("setting DF: %w", err)
+	}
+
+	id, seq := os.Getpid()&0xffff, int(pmtuSeq.Add(1)&0xffff)
+	wire, err := (&icmp.Message{Type: echo, Body: &icmp.Echo{ID: id, Seq: seq, Data: make([]byte, max(size-hdrs, 0))}}).Marshal(nil)
+	if err != nil {
+		return false, err
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+	if _, err := conn.WriteTo(wire, dst); err != nil {
+		if errors.Is(err, syscall.EMSGSIZE) {
+			return false, nil // Larger than a link on our side of the path
+		}
+		return false, err
+	}
+
+	buf := make([]byte, size+hdrs)
+	for {
+		n, peer, err := conn.ReadFrom(buf)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				return false, nil
+			}
+			return false, err
+		}
+		msg, err := icmp.ParseMessage(proto, buf[:n])
+		if err != nil {
+			continue
+		}
+		switch msg.Type {
+		case reply:
+			if e, ok := msg.Body.(*icmp.Echo); ok && e.ID == id && e.Seq == seq && peer.(*net.IPAddr).IP.Equal(dst.IP) {
+				return true, nil
+			}
+		case tooBig:
+			// A router on the path could not forward the probe; the
+			// quoted packet tells whether it was ours
+			if quotesDestination(msg, dst.IP) {
+				return false, nil
+			}
+		}
+	}
+}
+
+// quotesDestination reports whether an ICMP error quotes a packet to dst:
+// IPv4 fragmentation needed, or IPv6 packet too big
+func quotesDestination(msg *icmp.Message, dst net.IP) bool {
+	var data []byte
+	switch body := msg.Body.(type) {
+	case *icmp.DstUnreach:
+		if msg.Code != 4 { // Fragmentation needed and DF set
+			return false
+		}
+		data = body.Data
+	case *icmp.PacketTooBig:
+		data = body.Data
+	default:
+		return false
+	}
+	if dst.To4() != nil {
+		return len(data) >= 20 && net.IP(data[16:20]).Equal(dst)
+	}
+	return len(data) >= 40 && net.IP(data[24:40]).Equal(dst)
+}
+
+func setSockopt(conn *net.IPConn, level, opt, value int) error {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var sockErr error
+	if err := raw.Control(func(fd uintptr) {
+		sockErr = syscall.SetsockoptInt(int(fd), level, opt, value)
+	}); err != nil {
+		return err
+	}
+	return sockErr
+}
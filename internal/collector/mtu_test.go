@@ -0,0 +1,96 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+// fakePathMTU answers probes up to pathMTU, like a tunnel that drops the rest
+func fakePathMTU(pathMTU int, probes *atomic.Int32) func(*net.IPAddr, int, time.Duration) (bool, error) {
+	return func(_ *net.IPAddr, size int, _ time.Duration) (bool, error) {
+		probes.Add(1)
+		return size <= pathMTU, nil
+	}
+}
+
+func TestPathMTUCollector_Discover(t *testing.T) {
+	var probes atomic.Int32
+	c := NewPathMTUCollector([]string{"192.0.2.1", "2001:db8::1"})
+	c.routeMTU = func(net.IP) (int, error) { return 1500, nil }
+	c.probe = fakePathMTU(1420, &probes)
+
+	results := c.Collect(context.Background())
+	if r := results[0]; r.Error != nil || r.MTU != 1420 || r.InterfaceMTU != 1500 || !r.Reduced() {
+		t.Errorf("IPv4 = %+v, want 1420 of 1500", r)
+	}
+	if r := results[1]; r.Error != nil || r.MTU != 1420 {
+		t.Errorf("IPv6 = %+v, want 1420", r)
+	}
+	if n := probes.Load(); n > 2*(2+11) {
+		t.Errorf("%d probes, the search should be logarithmic", n)
+	}
+
+	// The full interface MTU gets through on the second probe
+	probes.Store(0)
+	c.probe = fakePathMTU(1500, &probes)
+	if mtu, err := c.DiscoverPathMTU("192.0.2.1"); err != nil || mtu != 1500 || probes.Load() != 2 {
+		t.Errorf("DiscoverPathMTU() = %d, %v after %d probes, want 1500 after 2", mtu, err, probes.Load())
+	}
+}
+
+func TestPathMTUCollector_Fallback(t *testing.T) {
+	c := NewPathMTUCollector([]string{"192.0.2.1"})
+	c.routeMTU = func(net.IP) (int, error) { return 1500, nil }
+	c.probe = func(*net.IPAddr, int, time.Duration) (bool, error) {
+		return false, ErrNoRawSocket
+	}
+	r := c.Collect(context.Background())[0]
+	if r.Error != nil || !r.Fallback || r.MTU != 1500 || r.Reduced() {
+		t.Errorf("result = %+v, want the interface MTU as fallback", r)
+	}
+
+	// A target that never answers has no path MTU to report
+	c.probe = func(*net.IPAddr, int, time.Duration) (bool, error) { return false, nil }
+	if _, err := c.DiscoverPathMTU("192.0.2.1"); err == nil || errors.Is(err, ErrNoRawSocket) {
+		t.Errorf("DiscoverPathMTU() error = %v, want no reply", err)
+	}
+}
+
+func TestPathMTUCollector_Budget(t *testing.T) {
+	var probes atomic.Int32
+	c := NewPathMTUCollector([]string{"192.0.2.1"})
+	c.routeMTU = func(net.IP) (int, error) { return 1500, nil }
+	c.probe = fakePathMTU(1420, &probes)
+	c.Budget, _ = fixedBudget(3, 0)
+
+	r := c.Collect(context.Background())[0]
+	if !errors.Is(r.Error, ErrBudgetExceeded) {
+		t.Errorf("error = %v, want ErrBudgetExceeded", r.Error)
+	}
+	if n := probes.Load(); n != 3 {
+		t.Errorf("%d probes sent, want the 3 the budget allows", n)
+	}
+}
+
+func TestQuotesDestination(t *testing.T) {
+	quoted := make([]byte, 28)
+	copy(quoted[16:20], net.IPv4(192, 0, 2, 1).To4())
+	fragNeeded := &icmp.Message{Type: ipv4.ICMPTypeDestinationUnreachable, Code: 4, Body: &icmp.DstUnreach{Data: quoted}}
+	if !quotesDestination(fragNeeded, net.ParseIP("192.0.2.1")) {
+		t.Error("fragmentation needed for our probe not recognized")
+	}
+	if quotesDestination(fragNeeded, net.ParseIP("192.0.2.2")) {
+		t.Error("a quote of another packet matched")
+	}
+	portUnreach := &icmp.Message{Type: ipv4.ICMPTypeDestinationUnreachable, Code: 3, Body: &icmp.DstUnreach{Data: quoted}}
+	if quotesDestination(portUnreach, net.ParseIP("192.0.2.1")) {
+		t.Error("only code 4 means the packet was too big")
+	}
+}
@@ -1,6 +1,7 @@
 package collector
 
 import (
+	"errors"
 	"fmt"
 	"net"
 	"time"
@@ -21,11 +22,35 @@ const (
 	NatBehindNat          NatType = "Behind NAT (Type Unknown)"
 )
 
+// AllNatTypes lists every NAT type the collector can report
+var AllNatTypes = []NatType{
+	NatOpenInternet, NatFullCone, NatRestrictedCone, NatPortRestrictedCone,
+	NatSymmetric, NatUdpBlocked, NatUnknown, NatBehindNat,
+}
+
+var natExplanations = map[NatType]string{
+	NatOpenInternet:       "No NAT: your device has a public address. Peer-to-peer works, but make sure a firewall protects it.",
+	NatFullCone:           "Any host can reach you once you send a packet out. Games, calls and P2P apps work well.",
+	NatRestrictedCone:     "Only hosts you have contacted can reach you. Most P2P apps and calls work via hole punching.",
+	NatPortRestrictedCone: "Only the exact host and port you contacted can reply. P2P usually works, some games report \"moderate\" NAT.",
+	NatSymmetric:          "Each destination gets a different public port. P2P apps and some VPNs will struggle; you may need a relay/TURN server.",
+	NatUdpBlocked:         "No UDP reached the STUN server. Voice/video calls, QUIC and many games will fail or fall back to TCP.",
+	NatUnknown:            "The NAT behaviour could not be determined. Try again or use a different STUN server.",
+	NatBehindNat:          "Your traffic is translated, but the exact NAT type is unknown. P2P may need a relay.",
+}
+
+// Explanation describes in plain words what the NAT type means for the user
+func (t NatType) Explanation() string {
+	return natExplanations[t]
+}
+
 type NatInfo struct {
 	Target   string
 	NatType  NatType
 	PublicIP string
 	LocalIP  string
+	Attempts int           // Binding requests sent before an answer (or giving up)
+	RTT      time.Duration // Binding request to response, from the last transmission
 	Error    error
 }
 
@@ -36,11 +61,21 @@ type StunTarget struct {
 
 type NatCollector struct {
 	Targets []StunTarget
+	Retries int           // Retransmissions after the first binding request
+	RTO     time.Duration // Initial retransmission timeout, doubled after each attempt
+	Timeout time.Duration // Upper bound for one probe, including retransmissions
+	Budget  *Budget       // Shared probe budget, all four tests are reserved per target
 }
 
+// errStunNoResponse means every binding request went unanswered
+var errStunNoResponse = errors.New("no response to stun binding request")
+
 func NewNatCollector(targets []StunTarget) *NatCollector {
 	return &NatCollector{
 		Targets: targets,
+		Retries: 3,
+		RTO:     250 * time.Millisecond,
+		Timeout: 3 * time.Second,
 	}
 }
 
@@ -64,87 +99,68 @@ func (c *NatCollector) Collect() ([]NatInfo, error) {
 	return results, nil
 }
 
+// CHANGE-REQUEST flags, RFC 5780 section 7.2
+const (
+	stunChangeIP   = 0x04
+	stunChangePort = 0x02
+)
+
 func (c *NatCollector) probe(target StunTarget) NatInfo {
 	info := NatInfo{
 		Target:  fmt.Sprintf("%s:%d", target.Host, target.Port),
 		NatType: NatUnknown,
 	}
+	if !c.Budget.Allow(natTestsPerTarget, natTestsPerTarget*stunProbeBytes) {
+		info.Error = ErrBudgetExceeded
+		return info
+	}
 
-	// 1. Resolve and Dial STUN server
-	// We use net.Dial to get the local address and ensure we are connected
+	// 1. Resolve the STUN server and find the local address towards it.
+	// The tests listen unconnected, as the answers to change requests come
+	// from the server's other address.
 	serverAddrStr := net.JoinHostPort(target.Host, fmt.Sprintf("%d", target.Port))
-	conn, err := net.Dial("udp4", serverAddrStr)
+	serverAddr, err := net.ResolveUDPAddr("udp4", serverAddrStr)
 	if err != nil {
 		info.Error = fmt.Errorf("dialing stun host: %w", err)
 		return info
 	}
-
-	// Get Local IP
-	localAddr, ok := conn.LocalAddr().(*net.UDPAddr)
-	if !ok {
-		conn.Close()
-		info.Error = fmt.Errorf("failed to cast local address to UDPAddr")
+	route, err := net.DialUDP("udp4", nil, serverAddr)
+	if err != nil {
+		info.Error = fmt.Errorf("dialing stun host: %w", err)
 		return info
 	}
-	info.LocalIP = localAddr.IP.String()
+	localIP := route.LocalAddr().(*net.UDPAddr).IP
+	route.Close()
+	info.LocalIP = localIP.String()
 
-	// 3. Create STUN Client
-	client, err := stun.NewClient(conn)
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: localIP})
 	if err != nil {
-		conn.Close()
-		info.Error = fmt.Errorf("creating stun client: %w", err)
+		info.Error = fmt.Errorf("dialing stun host: %w", err)
 		return info
 	}
-	defer client.Close()
-
-	// Building the request
-	message := stun.MustBuild(stun.TransactionID, stun.BindingRequest)
+	defer conn.Close()
 
-	var xorAddr stun.XORMappedAddress
-	var otherAddr stun.OtherAddress
-	var mappedAddr stun.MappedAddress
-
-	// Channel to receive signal when callback is done
-	doneCh := make(chan struct{})
-
-	err = client.Do(message, func(res stun.Event) {
-		defer close(doneCh)
-		if res.Error != nil {
-			return
-		}
-
-		if getErr := xorAddr.GetFrom(res.Message); getErr == nil {
-			info.PublicIP = xorAddr.IP.String()
-		} else if getErr := mappedAddr.GetFrom(res.Message); getErr == nil {
-			info.PublicIP = mappedAddr.IP.String()
-		}
-
-		// Check for OtherAddress (RFC 5780) for further tests
-		otherAddr.GetFrom(res.Message)
-	})
-
-	if err != nil {
+	// 2. Test I: send the binding request, retransmitting on lossy paths
+	res, _, attempts, rtt, err := stunBinding(conn, serverAddr, 0, c.Retries, c.RTO, c.Timeout)
+	info.Attempts, info.RTT = attempts, rtt
+	if errors.Is(err, errStunNoResponse) {
+		// Only conclude UDP is blocked once all retransmissions went unanswered
 		info.NatType = NatUdpBlocked
-		info.Error = fmt.Errorf("stun request failed: %w", err)
+		info.Error = fmt.Errorf("stun request failed after %d attempts: %w", attempts, err)
 		return info
 	}
-
-	// Wait for response or timeout
-	select {
-	case <-doneCh:
-		// Callback finished
-	case <-time.After(2 * time.Second):
-		info.Error = fmt.Errorf("stun request timed out")
+	if err != nil {
+		info.Error = fmt.Errorf("stun request failed: %w", err)
 		return info
 	}
 
-	if info.PublicIP == "" {
+	mapped := mappedAddress(res)
+	if mapped == nil {
 		info.NatType = NatUnknown
-		if info.Error == nil {
-			info.Error = fmt.Errorf("failed to get public ip")
-		}
+		info.Error = fmt.Errorf("failed to get public ip")
 		return info
 	}
+	info.PublicIP = mapped.IP.String()
 
 	// Basic Check: Public IP vs Local IP
 	if info.PublicIP == info.LocalIP {
@@ -152,12 +168,126 @@ func (c *NatCollector) probe(target StunTarget) NatInfo {
 		return info
 	}
 
-	// If we are here, we are behind NAT.
-	if otherAddr.IP != nil {
-		info.NatType = NatBehindNat
-	} else {
+	// If we are here, we are behind NAT; RFC 5780 servers tell which kind
+	var other stun.OtherAddress
+	if other.GetFrom(res) != nil {
 		info.NatType = NatBehindNat
+		return info
 	}
-
+	info.NatType = c.classify(conn, serverAddr, mapped, &net.UDPAddr{IP: other.IP, Port: other.Port})
 	return info
 }
+
+// classify runs the classic tests against a server that has a second
+// address. Test II asks for the answer from the other IP and port, which
+// only a full cone lets through. Test I against the other address shows
+// whether the mapping depends on the destination, i.e. a symmetric NAT.
+// Test III asks for the answer from the other port only, which passes a
+// restricted cone but not a port restricted one. Answers from the wrong
+// source mean the server ignores CHANGE-REQUEST, leaving the type unknown.
+// The tests run strictly in this order: a packet to the other address
+// before Test II has finished would open the NAT filter for its answer
+// and pass any cone off as a full cone.
+func (c *NatCollector) classify(conn net.PacketConn, server, mapped, other *net.UDPAddr) NatType {
+	if other.IP == nil || other.IP.IsUnspecified() || other.IP.Equal(server.IP) {
+		return NatBehindNat
+	}
+	binding := func(to *net.UDPAddr, change uint32) (*stun.Message, *net.UDPAddr, error) {
+		res, from, _, _, err := stunBinding(conn, to, change, c.Retries, c.RTO, c.Timeout)
+		if err != nil {
+			return nil, nil, err
+		}
+		addr, _ := from.(*net.UDPAddr)
+		return res, addr, nil
+	}
+
+	// Test II
+	if _, from, err := binding(server, stunChangeIP|stunChangePort); err == nil {
+		if from == nil || from.IP.Equal(server.IP) {
+			return NatBehindNat
+		}
+		return NatFullCone
+	}
+
+	// Test I against the other address
+	res, _, err := binding(other, 0)
+	if err != nil {
+		return NatBehindNat
+	}
+	if m := mappedAddress(res); m == nil || !m.IP.Equal(mapped.IP) || m.Port != mapped.Port {
+		return NatSymmetric
+	}
+
+	// Test III
+	if _, from, err := binding(server, stunChangePort); err == nil {
+		if from == nil || from.Port == server.Port {
+			return NatBehindNat
+		}
+		return NatRestrictedCone
+	}
+	return NatPortRestrictedCone
+}
+
+// mappedAddress returns the reflexive address of a binding response,
+// preferring XOR-MAPPED-ADDRESS over the legacy MAPPED-ADDRESS
+func mappedAddress(res *stun.Message) *net.UDPAddr {
+	var xorAddr stun.XORMappedAddress
+	if xorAddr.GetFrom(res) == nil {
+		return &net.UDPAddr{IP: xorAddr.IP, Port: xorAddr.Port}
+	}
+	var addr stun.MappedAddress
+	if addr.GetFrom(res) == nil {
+		return &net.UDPAddr{IP: addr.IP, Port: addr.Port}
+	}
+	return nil
+}
+
+// stunBinding sends a binding request to server and waits for the matching
+// response, from whichever address it comes. Unanswered requests are
+// retransmitted (same transaction) with an RTO that doubles each time,
+// never exceeding timeout overall. change sets a CHANGE-REQUEST. The round
+// trip is timed from the last transmission; an answer to an earlier one
+// can only make it look shorter.
+func stunBinding(conn net.PacketConn, server net.Addr, change uint32, retries int, rto, timeout time.Duration) (*stun.Message, net.Addr, int, time.Duration, error) {
+	deadline := time.Now().Add(timeout)
+	setters := []stun.Setter{stun.TransactionID, stun.BindingRequest}
+	if change != 0 {
+		setters = append(setters, stun.RawAttribute{Type: stun.AttrChangeRequest, Value: []byte{0, 0, 0, byte(change)}})
+	}
+	req := stun.MustBuild(setters...)
+	buf := make([]byte, 1500)
+
+	attempts := 0
+	for attempts <= retries && time.Now().Before(deadline) {
+		attempts++
+		sent := time.Now()
+		if _, err := conn.WriteTo(req.Raw, server); err != nil {
+			return nil, nil, attempts, 0, err
+		}
+
+		wait := time.Now().Add(rto)
+		if wait.After(deadline) {
+			wait = deadline
+		}
+		conn.SetReadDeadline(wait)
+
+		for {
+			n, from, err := conn.ReadFrom(buf)
+			if err != nil {
+				var netErr net.Error
+				if errors.As(err, &netErr) && netErr.Timeout() {
+					break // Retransmit
+				}
+				return nil, nil, attempts, 0, err
+			}
+			res := &stun.Message{Raw: append([]byte(nil), buf[:n]...)}
+			if res.Decode() != nil || res.TransactionID != req.TransactionID {
+				continue // Not ours, keep waiting
+			}
+			return res, from, attempts, time.Since(sent), nil
+		}
+		rto *= 2
+	}
+
+	return nil, nil, attempts, 0, errStunNoResponse
+}
@@ -1,7 +1,13 @@
 package collector
 
 import (
+	"net"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/pion/stun/v3"
 )
 
 func TestNatCollector_Collect(t *testing.T) {
@@ -46,3 +52,254 @@ func TestNatCollector_Collect(t *testing.T) {
 		t.Logf("NAT Type: %s, Public: %s, Local: %s", res.NatType, res.PublicIP, res.LocalIP)
 	}
 }
+
+func TestNatType_Explanation(t *testing.T) {
+	for _, nt := range AllNatTypes {
+		if nt.Explanation() == "" {
+			t.Errorf("NatType %q has no explanation", nt)
+		}
+	}
+}
+
+// startLossySTUN runs a STUN server on loopback that drops the first `drop`
+// binding requests. It returns the port and a counter of received requests.
+func startLossySTUN(t *testing.T, drop int) (int, *atomic.Int32) {
+	t.Helper()
+	pc, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pc.Close() })
+
+	var received atomic.Int32
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if int(received.Add(1)) <= drop {
+				continue // Simulate loss
+			}
+			req := &stun.Message{Raw: append([]byte(nil), buf[:n]...)}
+			if req.Decode() != nil {
+				continue
+			}
+			udpAddr := addr.(*net.UDPAddr)
+			res := stun.MustBuild(
+				stun.NewTransactionIDSetter(req.TransactionID),
+				stun.BindingSuccess,
+				&stun.XORMappedAddress{IP: udpAddr.IP, Port: udpAddr.Port},
+			)
+			pc.WriteTo(res.Raw, addr)
+		}
+	}()
+
+	return pc.LocalAddr().(*net.UDPAddr).Port, &received
+}
+
+func TestNatCollector_RetransmitsOnLoss(t *testing.T) {
+	port, received := startLossySTUN(t, 1)
+
+	c := NewNatCollector([]StunTarget{{Host: "127.0.0.1", Port: port}})
+	c.RTO = 50 * time.Millisecond
+	c.Timeout = 2 * time.Second
+
+	info := c.probe(c.Targets[0])
+	if info.Error != nil {
+		t.Fatalf("probe failed despite retransmission: %v", info.Error)
+	}
+	if info.Attempts != 2 || received.Load() != 2 {
+		t.Errorf("attempts = %d, server saw %d requests, want 2", info.Attempts, received.Load())
+	}
+	if info.PublicIP != "127.0.0.1" || info.NatType != NatOpenInternet {
+		t.Errorf("unexpected result: %+v", info)
+	}
+}
+
+func TestNatCollector_RTT(t *testing.T) {
+	port, _ := startLossySTUN(t, 0)
+
+	c := NewNatCollector([]StunTarget{{Host: "127.0.0.1", Port: port}})
+	info := c.probe(c.Targets[0])
+	if info.Error != nil {
+		t.Fatalf("probe failed: %v", info.Error)
+	}
+	if info.RTT <= 0 || info.RTT >= c.RTO {
+		t.Errorf("RTT = %s, want a loopback round trip", info.RTT)
+	}
+}
+
+func TestNatCollector_UDPBlockedAfterRetries(t *testing.T) {
+	port, received := startLossySTUN(t, 1000)
+
+	c := NewNatCollector([]StunTarget{{Host: "127.0.0.1", Port: port}})
+	c.Retries = 2
+	c.RTO = 20 * time.Millisecond
+	c.Timeout = 2 * time.Second
+
+	info := c.probe(c.Targets[0])
+	if info.NatType != NatUdpBlocked {
+		t.Errorf("NatType = %s, want %s", info.NatType, NatUdpBlocked)
+	}
+	if info.Attempts != 3 {
+		t.Errorf("attempts = %d, want 3", info.Attempts)
+	}
+	// Give the last datagram time to arrive
+	time.Sleep(20 * time.Millisecond)
+	if received.Load() != 3 {
+		t.Errorf("server saw %d requests, want 3", received.Load())
+	}
+}
+
+func TestNatCollector_TimeoutBoundsRetries(t *testing.T) {
+	port, _ := startLossySTUN(t, 1000)
+
+	c := NewNatCollector([]StunTarget{{Host: "127.0.0.1", Port: port}})
+	c.Retries = 10
+	c.RTO = 100 * time.Millisecond
+	c.Timeout = 300 * time.Millisecond
+
+	start := time.Now()
+	info := c.probe(c.Targets[0])
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("probe took %s, should be bounded by the 300ms timeout", elapsed)
+	}
+	if info.NatType != NatUdpBlocked {
+		t.Errorf("NatType = %s, want %s", info.NatType, NatUdpBlocked)
+	}
+}
+
+// natBehavior is the NAT a mock RFC 5780 server pretends the client sits behind
+type natBehavior struct {
+	name           string
+	symmetric      bool // Another mapping per destination
+	allowOtherIP   bool // Answers from another IP get through, a full cone
+	allowOtherPort bool // Answers from another port of the same IP get through
+	stateful       bool // Like a real NAT, also let through answers from where the client has sent to
+	ignoresChange  bool // The server answers change requests from where it was asked
+	noOtherAddress bool // The server predates RFC 5780
+	wantNatType    NatType
+}
+
+// startRFC5780STUN serves STUN on two IPs and two ports each, answering
+// change requests from the requested address. The client's mapped address
+// and which answers reach it follow b. It returns the primary port.
+func startRFC5780STUN(t *testing.T, b natBehavior) int {
+	t.Helper()
+	listen := func(ip string) *net.UDPConn {
+		pc, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP(ip)})
+		if err != nil {
+			t.Skipf("cannot listen on %s: %v", ip, err)
+		}
+		t.Cleanup(func() { pc.Close() })
+		return pc
+	}
+	// socks[ip][port]: 0 is the primary, 1 the other
+	socks := [2][2]*net.UDPConn{
+		{listen("127.0.0.1"), listen("127.0.0.1")},
+		{listen("127.0.0.2"), listen("127.0.0.2")},
+	}
+	other := socks[1][1].LocalAddr().(*net.UDPAddr)
+
+	// contacted[ip][port] records the server sockets the client has sent
+	// to, which a stateful filter opens up for
+	var mu sync.Mutex
+	var contacted [2][2]bool
+	passes := func(ip, port int) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return contacted[ip][port] || b.allowOtherPort && (contacted[ip][0] || contacted[ip][1])
+	}
+
+	for ip := range socks {
+		for port := range socks[ip] {
+			go func() {
+				pc := socks[ip][port]
+				buf := make([]byte, 1500)
+				for {
+					n, addr, err := pc.ReadFrom(buf)
+					if err != nil {
+						return
+					}
+					req := &stun.Message{Raw: append([]byte(nil), buf[:n]...)}
+					if req.Decode() != nil {
+						continue
+					}
+					mu.Lock()
+					contacted[ip][port] = true
+					mu.Unlock()
+					var change byte
+					if v, err := req.Get(stun.AttrChangeRequest); err == nil && len(v) == 4 {
+						change = v[3]
+					}
+					respIP, respPort := ip, port
+					if !b.ignoresChange {
+						if change&stunChangeIP != 0 {
+							respIP = 1 - ip
+						}
+						if change&stunChangePort != 0 {
+							respPort = 1 - port
+						}
+					}
+					// The emulated NAT filters answers from elsewhere
+					if b.stateful {
+						if !b.allowOtherIP && !passes(respIP, respPort) {
+							continue
+						}
+					} else if respIP != ip && !b.allowOtherIP || respIP == ip && respPort != port && !b.allowOtherPort {
+						continue
+					}
+					mappedPort := 40000
+					if b.symmetric && ip == 1 {
+						mappedPort = 40001
+					}
+					setters := []stun.Setter{
+						stun.NewTransactionIDSetter(req.TransactionID),
+						stun.BindingSuccess,
+						&stun.XORMappedAddress{IP: net.ParseIP("203.0.113.5"), Port: mappedPort},
+					}
+					if !b.noOtherAddress {
+						setters = append(setters, &stun.OtherAddress{IP: other.IP, Port: other.Port})
+					}
+					socks[respIP][respPort].WriteTo(stun.MustBuild(setters...).Raw, addr)
+				}
+			}()
+		}
+	}
+	return socks[0][0].LocalAddr().(*net.UDPAddr).Port
+}
+
+func TestNatCollector_Classify(t *testing.T) {
+	tests := []natBehavior{
+		{name: "full cone", allowOtherIP: true, allowOtherPort: true, wantNatType: NatFullCone},
+		{name: "restricted cone", allowOtherPort: true, wantNatType: NatRestrictedCone},
+		{name: "port restricted cone", wantNatType: NatPortRestrictedCone},
+		{name: "symmetric", symmetric: true, wantNatType: NatSymmetric},
+		{name: "stateful restricted cone", stateful: true, allowOtherPort: true, wantNatType: NatRestrictedCone},
+		{name: "stateful port restricted cone", stateful: true, wantNatType: NatPortRestrictedCone},
+		{name: "server without other address", allowOtherIP: true, noOtherAddress: true, wantNatType: NatBehindNat},
+		{name: "server ignoring change requests", ignoresChange: true, wantNatType: NatBehindNat},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			port := startRFC5780STUN(t, tt)
+			c := NewNatCollector([]StunTarget{{Host: "127.0.0.1", Port: port}})
+			c.Retries = 1
+			c.RTO = 20 * time.Millisecond
+			c.Timeout = 200 * time.Millisecond
+
+			info := c.probe(c.Targets[0])
+			if info.Error != nil {
+				t.Fatalf("probe failed: %v", info.Error)
+			}
+			if info.PublicIP != "203.0.113.5" {
+				t.Errorf("PublicIP = %q", info.PublicIP)
+			}
+			if info.NatType != tt.wantNatType {
+				t.Errorf("NatType = %s, want %s", info.NatType, tt.wantNatType)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// procNetDevPath is the kernel's per-interface counter table
+const procNetDevPath = "/proc/net/dev"
+
+// readProcNetDev reads and parses a /proc/net/dev style file
+func readProcNetDev(path string) (map[string]InterfaceTraffic, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseProcNetDev(f)
+}
+
+// parseProcNetDev parses /proc/net/dev into raw interface counters (no rates).
+// Each line after the two header lines is "iface: 8 receive fields 8 transmit fields":
+// rx bytes packets errs drop fifo frame compressed multicast,
+// tx bytes packets errs drop fifo colls carrier compressed.
+func parseProcNetDev(r io.Reader) (map[string]InterfaceTraffic, error) {
+	stats := make(map[string]InterfaceTraffic)
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		if lineNo <= 2 {
+			continue // Headers
+		}
+		name, rest, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) < 16 {
+			return nil, fmt.Errorf("/proc/net/dev line %d: expected 16 fields, got %d", lineNo, len(fields))
+		}
+		var v [16]uint64
+		for i := range v {
+			n, err := strconv.ParseUint(fields[i], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("/proc/net/dev line %d: %v", lineNo, err)
+			}
+			v[i] = n
+		}
+		stats[strings.TrimSpace(name)] = InterfaceTraffic{
+			RxBytes:       v[0],
+			TxBytes:       v[8],
+			RxPackets:     v[1],
+			TxPackets:     v[9],
+			Errors:        v[2] + v[10],
+			Drop:          v[3] + v[11],
+			FifoErrors:    v[4] + v[12],
+			FrameErrors:   v[5],
+			Multicast:     v[7],
+			Collisions:    v[13],
+			CarrierErrors: v[14],
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
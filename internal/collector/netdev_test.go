@@ -0,0 +1,53 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+)
+
+const sampleProcNetDev = `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo: 7826412   65432    0    0    0     0          0         0  7826412   65432    0    0    0     0       0          0
+  eth0: 1234567890 987654   12    3    4     5          0       678 987654321 456789    6    7    8     9      10          0
+wlan0:       0       0    0    0    0     0          0         0        0       0    0    0    0     0       0          0
+`
+
+func TestParseProcNetDev(t *testing.T) {
+	stats, err := parseProcNetDev(strings.NewReader(sampleProcNetDev))
+	if err != nil {
+		t.Fatalf("parseProcNetDev() error = %v", err)
+	}
+	if len(stats) != 3 {
+		t.Fatalf("expected 3 interfaces, got %d", len(stats))
+	}
+
+	want := InterfaceTraffic{
+		RxBytes:       1234567890,
+		TxBytes:       987654321,
+		RxPackets:     987654,
+		TxPackets:     456789,
+		Errors:        12 + 6,
+		Drop:          3 + 7,
+		FifoErrors:    4 + 8,
+		FrameErrors:   5,
+		Multicast:     678,
+		Collisions:    9,
+		CarrierErrors: 10,
+	}
+	if got := stats["eth0"]; got != want {
+		t.Errorf("eth0 = %+v, want %+v", got, want)
+	}
+	if stats["lo"].RxBytes != 7826412 {
+		t.Errorf("lo RxBytes = %d", stats["lo"].RxBytes)
+	}
+	if _, ok := stats["wlan0"]; !ok {
+		t.Error("wlan0 missing (no space after name padding)")
+	}
+}
+
+func TestParseProcNetDev_Malformed(t *testing.T) {
+	input := "header\nheader\n  eth0: 1 2 3\n"
+	if _, err := parseProcNetDev(strings.NewReader(input)); err == nil {
+		t.Error("expected error for truncated line")
+	}
+}
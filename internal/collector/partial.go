@@ -0,0 +1,21 @@
+package collector
+
+import "fmt"
+
+// recoverPartial turns a panic in collector name into an error, keeping the
+// data gathered before it. It must be deferred directly, as
+//
+//	defer recoverPartial("SystemCollector", &info.Partial, &info.Error, &err)
+//
+// so the named results still hold the in-progress struct.
+func recoverPartial(name string, partial *bool, field, err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	*partial = true
+	*err = fmt.Errorf("panic in %s: %v", name, r)
+	if field != nil {
+		*field = *err
+	}
+}
@@ -0,0 +1,228 @@
+package collector
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sysatom/lnd/internal/config"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// PayloadPattern is how the ICMP echo payload is filled
+type PayloadPattern string
+
+const (
+	PayloadDefault      PayloadPattern = ""             // pro-bing's timestamp and tracker, then 0x01 bytes
+	PayloadZeros        PayloadPattern = "zeros"        // All 0x00
+	PayloadRandom       PayloadPattern = "random"       // Fresh random bytes per probe, defeats link compression
+	PayloadIncrementing PayloadPattern = "incrementing" // 0x00, 0x01, ... 0xff, 0x00, ...
+)
+
+// minDefaultPayload is the smallest payload pro-bing can send, its
+// timestamp and tracker UUID
+const minDefaultPayload = 24
+
+// Ping defaults for zero options, the same as the config's
+const (
+	pingsPerTarget      = config.DefaultPingCount
+	defaultPingInterval = config.DefaultPingInterval
+	defaultPingTimeout  = config.DefaultPingTimeout
+)
+
+// PingOptions shapes the ICMP echo requests of the connectivity pings
+type PingOptions struct {
+	DSCP       int            // DSCP code point for outgoing pings, 0 = unmarked
+	Size       int            // ICMP payload bytes like ping -s, 0 keeps the 24 byte default
+	Pattern    PayloadPattern // Payload fill, the default keeps pro-bing's
+	Count      int            // Echo requests per target, 0 = 3
+	Interval   time.Duration  // Between echo requests, 0 = 1s
+	Timeout    time.Duration  // Wait for each reply, 0 = 2s
+	Privileged bool           // Raw ICMP sockets; otherwise unprivileged ping sockets (net.ipv4.ping_group_range)
+}
+
+func (o PingOptions) count() int {
+	if o.Count <= 0 {
+		return pingsPerTarget
+	}
+	return o.Count
+}
+
+func (o PingOptions) interval() time.Duration {
+	if o.Interval <= 0 {
+		return defaultPingInterval
+	}
+	return o.Interval
+}
+
+func (o PingOptions) timeout() time.Duration {
+	if o.Timeout <= 0 {
+		return defaultPingTimeout
+	}
+	return o.Timeout
+}
+
+// duration bounds one target's pings: the last request goes out after
+// count-1 intervals and may take the full timeout to be answered
+func (o PingOptions) duration() time.Duration {
+	return time.Duration(o.count()-1)*o.interval() + o.timeout()
+}
+
+// payloadSize is the payload length actually sent
+func (o PingOptions) payloadSize() int {
+	if o.Pattern == PayloadDefault && o.Size < minDefaultPayload {
+		return minDefaultPayload
+	}
+	return o.Size
+}
+
+// probeBytes is what one echo request and its reply cost the budget, both
+// carry the payload beyond pro-bing's default
+func (o PingOptions) probeBytes() int {
+	return pingProbeBytes + 2*(o.payloadSize()-minDefaultPayload)
+}
+
+// String describes non-default options, e.g. "1472 byte payload, zeros"
+// or "10 pings every 500ms"
+func (o PingOptions) String() string {
+	var parts []string
+	if o.Count > 0 || o.Interval > 0 {
+		parts = append(parts, fmt.Sprintf("%d pings every %s", o.count(), o.interval()))
+	}
+	if o.Size != 0 || o.Pattern != PayloadDefault {
+		parts = append(parts, fmt.Sprintf("%d byte payload", o.payloadSize()))
+	}
+	if o.Pattern != PayloadDefault {
+		parts = append(parts, string(o.Pattern))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// fillPayload returns size bytes of pattern
+func fillPayload(pattern PayloadPattern, size int) ([]byte, error) {
+	b := make([]byte, size)
+	switch pattern {
+	case PayloadRandom:
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+	case PayloadIncrementing:
+		for i := range b {
+			b[i] = byte(i)
+		}
+	case PayloadZeros:
+	default:
+		return nil, fmt.Errorf("unknown payload pattern %q", pattern)
+	}
+	return b, nil
+}
+
+// patternPing sends opts.Count echo requests carrying the pattern
+// payload over a raw socket, for patterns pro-bing cannot send. A reply only
+// counts if it echoes the payload unchanged, so corruption on the path shows
+// up as loss.
+func patternPing(target string, opts PingOptions, tclass int, src *SourceInterface) (PingResult, error) {
+	res := PingResult{Target: target}
+	dst, err := net.ResolveIPAddr("ip", target)
+	if err != nil {
+		return res, err
+	}
+
+	v4 := dst.IP.To4() != nil
+	network, laddr := "ip6:ipv6-icmp", "::"
+	echo, reply := icmp.Type(ipv6.ICMPTypeEchoRequest), icmp.Type(ipv6.ICMPTypeEchoReply)
+	proto := 58
+	if v4 {
+		network, laddr = "ip4:icmp", "0.0.0.0"
+		echo, reply = ipv4.ICMPTypeEcho, ipv4.ICMPTypeEchoReply
+		proto = 1
+	}
+	if ip := src.sourceFor(dst.IP.String()); ip != nil {
+		laddr = ip.String()
+	}
+	conn, err := icmp.ListenPacket(network, laddr)
+	if err != nil {
+		return res, err
+	}
+	defer conn.Close()
+	if tclass != 0 {
+		if v4 {
+			err = conn.IPv4PacketConn().SetTOS(tclass)
+		} else {
+			err = conn.IPv6PacketConn().SetTrafficClass(tclass)
+		}
+		if err != nil {
+			return res, fmt.Errorf("set traffic class: %w", err)
+		}
+	}
+
+	var idBuf [2]byte
+	rand.Read(idBuf[:])
+	id := (int(idBuf[0])<<8 | int(idBuf[1])) ^ os.Getpid()&0xffff
+	var rtts []time.Duration
+	var start time.Time
+	buf := make([]byte, 65536)
+	count := opts.count()
+	for seq := 1; seq <= count; seq++ {
+		if seq > 1 {
+			time.Sleep(time.Until(start.Add(opts.interval())))
+		}
+		payload, err := fillPayload(opts.Pattern, opts.Size)
+		if err != nil {
+			return res, err
+		}
+		wire, err := (&icmp.Message{Type: echo, Body: &icmp.Echo{ID: id, Seq: seq, Data: payload}}).Marshal(nil)
+		if err != nil {
+			return res, err
+		}
+		start = time.Now()
+		if _, err := conn.WriteTo(wire, dst); err != nil {
+			return res, err
+		}
+		conn.SetReadDeadline(start.Add(opts.timeout()))
+		for {
+			n, peer, err := conn.ReadFrom(buf)
+			if err != nil {
+				var netErr net.Error
+				if errors.As(err, &netErr) && netErr.Timeout() {
+					break // Lost
+				}
+				return res, err
+			}
+			msg, err := icmp.ParseMessage(proto, buf[:n])
+			if err != nil || msg.Type != reply {
+				continue
+			}
+			body, ok := msg.Body.(*icmp.Echo)
+			if !ok || body.ID != id || body.Seq != seq || peer.String() != dst.String() {
+				continue
+			}
+			if string(body.Data) == string(payload) {
+				rtts = append(rtts, time.Since(start))
+			}
+			break
+		}
+	}
+
+	res.PacketLoss = float64(count-len(rtts)) / float64(count) * 100
+	var sum time.Duration
+	for i, rtt := range rtts {
+		if i == 0 || rtt < res.MinRtt {
+			res.MinRtt = rtt
+		}
+		res.MaxRtt = max(res.MaxRtt, rtt)
+		sum += rtt
+	}
+	if len(rtts) > 0 {
+		res.AvgRtt = sum / time.Duration(len(rtts))
+	}
+	res.StdDevRtt = rttStdDev(rtts)
+	res.Jitter = rttJitter(rtts)
+	return res, nil
+}
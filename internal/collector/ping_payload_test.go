@@ -0,0 +1,91 @@
+package collector
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/sysatom/lnd/internal/config"
+)
+
+func TestNewICMPPinger_PayloadSize(t *testing.T) {
+	tests := []struct {
+		opts PingOptions
+		want int
+	}{
+		{PingOptions{}, minDefaultPayload},
+		{PingOptions{Size: 8}, minDefaultPayload}, // pro-bing needs room for its tracker
+		{PingOptions{Size: 1472}, 1472},
+		{PingOptions{Size: 8, Pattern: PayloadZeros}, 8},
+	}
+	for _, tt := range tests {
+		pinger, err := newICMPPinger("127.0.0.1", 0, tt.opts, nil)
+		if err != nil {
+			t.Fatalf("newICMPPinger() error = %v", err)
+		}
+		if pinger.Size != tt.want {
+			t.Errorf("%+v: pinger.Size = %d, want %d", tt.opts, pinger.Size, tt.want)
+		}
+	}
+}
+
+func TestFillPayload(t *testing.T) {
+	zeros, err := fillPayload(PayloadZeros, 4)
+	if err != nil || !bytes.Equal(zeros, []byte{0, 0, 0, 0}) {
+		t.Errorf("zeros = %v, %v", zeros, err)
+	}
+	inc, err := fillPayload(PayloadIncrementing, 258)
+	if err != nil || inc[0] != 0 || inc[1] != 1 || inc[255] != 255 || inc[256] != 0 || inc[257] != 1 {
+		t.Errorf("incrementing wraps wrong: %v, %v", inc[250:], err)
+	}
+	a, _ := fillPayload(PayloadRandom, 32)
+	b, _ := fillPayload(PayloadRandom, 32)
+	if len(a) != 32 || bytes.Equal(a, b) {
+		t.Error("random payloads should be fresh per probe")
+	}
+	if _, err := fillPayload("stripes", 4); err == nil {
+		t.Error("expected an error for an unknown pattern")
+	}
+}
+
+func TestPingOptions_String(t *testing.T) {
+	if s := (PingOptions{DSCP: 46}).String(); s != "" {
+		t.Errorf("default payload described as %q", s)
+	}
+	if s := (PingOptions{Size: 1472, Pattern: PayloadRandom}).String(); s != "1472 byte payload, random" {
+		t.Errorf("String() = %q", s)
+	}
+	if s := (PingOptions{Count: 10, Interval: 500 * time.Millisecond}).String(); s != "10 pings every 500ms" {
+		t.Errorf("String() = %q", s)
+	}
+}
+
+func TestNewICMPPinger_Timing(t *testing.T) {
+	tests := []struct {
+		opts              PingOptions
+		count             int
+		interval, timeout time.Duration
+	}{
+		{PingOptions{}, 3, time.Second, 4 * time.Second},
+		{PingOptions{Count: 10, Interval: 200 * time.Millisecond, Timeout: time.Second}, 10, 200 * time.Millisecond, 2800 * time.Millisecond},
+		{PingOptions{Count: 1, Timeout: 500 * time.Millisecond}, 1, time.Second, 500 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		pinger, err := newICMPPinger("127.0.0.1", 0, tt.opts, nil)
+		if err != nil {
+			t.Fatalf("newICMPPinger() error = %v", err)
+		}
+		if pinger.Count != tt.count || pinger.Interval != tt.interval || pinger.Timeout != tt.timeout {
+			t.Errorf("%+v: count %d, interval %s, timeout %s; want %d, %s, %s",
+				tt.opts, pinger.Count, pinger.Interval, pinger.Timeout, tt.count, tt.interval, tt.timeout)
+		}
+	}
+}
+
+func TestFillPayload_ConfigPatterns(t *testing.T) {
+	for _, p := range config.PingPatterns {
+		if _, err := fillPayload(PayloadPattern(p), 8); err != nil {
+			t.Errorf("config accepts ping pattern %q the collector cannot send: %v", p, err)
+		}
+	}
+}
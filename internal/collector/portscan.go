@@ -0,0 +1,233 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sysatom/lnd/internal/config"
+)
+
+// DefaultScanPorts are the common service ports of the quick scan
+var DefaultScanPorts = []int{21, 22, 25, 53, 80, 110, 143, 443, 445, 993, 3306, 3389, 5432, 6379, 8080, 8443}
+
+// MaxScanPorts caps the port list, shared with the config check
+const MaxScanPorts = config.MaxScanPorts
+
+// scanServices names the ports a user is likely to recognize
+var scanServices = map[int]string{
+	21: "ftp", 22: "ssh", 23: "telnet", 25: "smtp", 53: "dns", 80: "http", 110: "pop3",
+	143: "imap", 443: "https", 445: "smb", 465: "smtps", 587: "submission", 853: "dns-tls",
+	993: "imaps", 995: "pop3s", 1433: "mssql", 1521: "oracle", 3306: "mysql", 3389: "rdp",
+	5432: "postgres", 5672: "amqp", 5900: "vnc", 6379: "redis", 8080: "http-alt",
+	8443: "https-alt", 9200: "elasticsearch", 11211: "memcached", 27017: "mongodb",
+}
+
+// bannerHTTPPorts are the plain HTTP ports, which say nothing until asked
+var bannerHTTPPorts = map[int]bool{80: true, 8000: true, 8008: true, 8080: true, 8888: true, 9200: true}
+
+// bannerSilentPorts wait for the client to speak first, TLS handshakes and
+// binary protocols, so banner grabbing would only sit out the deadline
+var bannerSilentPorts = map[int]bool{
+	53: true, 443: true, 445: true, 465: true, 853: true, 993: true, 995: true, 1433: true,
+	3389: true, 5432: true, 6379: true, 8443: true, 11211: true, 27017: true,
+}
+
+// Banner grabbing limits: services that greet (SSH, SMTP, FTP) do so at
+// once, the rest stay silent until the deadline
+const (
+	maxBannerBytes = 512
+	bannerTimeout  = time.Second
+)
+
+// errScanTimeLimit marks ports the scan did not get to before its deadline
+var errScanTimeLimit = errors.New("not probed, scan time limit reached")
+
+// PortState is the verdict for one port
+type PortState struct {
+	Port         int
+	Service      string // Well-known name, empty if none
+	Reachability Reachability
+	Latency      time.Duration // Connect or refusal time, zero if filtered
+	Banner       string        // What the service said first, printable, empty if nothing or not grabbed
+	Error        error
+}
+
+// PortScan is the quick TCP connect scan of one target
+type PortScan struct {
+	Target  string
+	Address string      // The resolved address every port was dialed on
+	Ports   []PortState // In the order of the scanned port list
+	Error   error       // The target could not be resolved
+}
+
+// Open returns the open ports
+func (s PortScan) Open() []int {
+	var open []int
+	for _, p := range s.Ports {
+		if p.Reachability == ReachOpen {
+			open = append(open, p.Port)
+		}
+	}
+	return open
+}
+
+// PortScanner runs bounded TCP connect scans of a short port list
+type PortScanner struct {
+	Ports       []int
+	Timeout     time.Duration // Per connection attempt
+	TimeLimit   time.Duration // For the whole scan
+	Concurrency int
+	Source      *SourceInterface
+	GrabBanner  bool    // Read what open ports say, asking HTTP ports with a HEAD request
+	Budget      *Budget // Shared probe budget, ports over it are not probed
+}
+
+func NewPortScanner() *PortScanner {
+	return &PortScanner{
+		Ports:       DefaultScanPorts,
+		Timeout:     2 * time.Second,
+		TimeLimit:   10 * time.Second,
+		Concurrency: 16,
+	}
+}
+
+// Scan resolves target once and connects to each port, at most Concurrency
+// at a time, classifying each as open, closed or filtered
+func (s *PortScanner) Scan(ctx context.Context, target string) PortScan {
+	scan := PortScan{Target: target}
+	ports := s.Ports
+	if len(ports) > MaxScanPorts {
+		ports = ports[:MaxScanPorts]
+	}
+
+	host, _, err := normalizeTCPTarget(target)
+	if err != nil {
+		scan.Error = err
+		return scan
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.TimeLimit)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		scan.Error = err
+		return scan
+	}
+	if len(addrs) == 0 {
+		scan.Error = fmt.Errorf("no address for %s", host)
+		return scan
+	}
+	scan.Address = addrs[0].String()
+
+	scan.Ports = make([]PortState, len(ports))
+	sem := make(chan struct{}, max(s.Concurrency, 1))
+	var wg sync.WaitGroup
+	for i, port := range ports {
+		scan.Ports[i] = PortState{Port: port, Service: scanServices[port]}
+		if !s.Budget.Allow(1, tcpProbeBytes) {
+			scan.Ports[i].Error = ErrBudgetExceeded
+			continue
+		}
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			scan.Ports[i].Error = errScanTimeLimit
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			s.probe(ctx, scan.Address, &scan.Ports[i])
+		}()
+	}
+	wg.Wait()
+	return scan
+}
+
+// probe connects once, with the same verdicts as the TCP ping fallback
+func (s *PortScanner) probe(ctx context.Context, addr string, state *PortState) {
+	dialer := &markedDialer{Source: s.Source, Timeout: s.Timeout}
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(addr, strconv.Itoa(state.Port)))
+	elapsed := time.Since(start)
+	if conn != nil {
+		if s.GrabBanner && !bannerSilentPorts[state.Port] {
+			state.Banner = grabBanner(ctx, conn, state.Port)
+		}
+		conn.Close()
+	}
+	state.Reachability = classifyDialError(err)
+	switch state.Reachability {
+	case ReachOpen, ReachClosed:
+		state.Latency = elapsed
+	default:
+		state.Error = err
+	}
+}
+
+// grabBanner reads the first thing a service says, at most maxBannerBytes
+// within bannerTimeout. HTTP ports get a HEAD request first. The result is
+// the first line, or for HTTP the status line and Server header.
+func grabBanner(ctx context.Context, conn net.Conn, port int) string {
+	deadline := time.Now().Add(bannerTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+
+	isHTTP := bannerHTTPPorts[port]
+	if isHTTP {
+		if _, err := conn.Write([]byte("HEAD / HTTP/1.0\r\n\r\n")); err != nil {
+			return ""
+		}
+	}
+	buf := make([]byte, 0, maxBannerBytes)
+	for len(buf) < maxBannerBytes {
+		n, err := conn.Read(buf[len(buf):maxBannerBytes])
+		buf = buf[:len(buf)+n]
+		if err != nil || (!isHTTP && bytes.IndexByte(buf, '\n') >= 0) || (isHTTP && bytes.Contains(buf, []byte("\r\n\r\n"))) {
+			break
+		}
+	}
+	if isHTTP && bytes.HasPrefix(buf, []byte("HTTP/")) {
+		return httpBanner(buf)
+	}
+	line, _, _ := bytes.Cut(buf, []byte("\n"))
+	return sanitizeBanner(line)
+}
+
+// httpBanner reduces a response to its status line and Server header,
+// e.g. "HTTP/1.1 200 OK, Server: nginx/1.24.0"
+func httpBanner(resp []byte) string {
+	status, rest, _ := bytes.Cut(resp, []byte("\n"))
+	banner := sanitizeBanner(status)
+	for _, line := range bytes.Split(rest, []byte("\n")) {
+		name, value, ok := bytes.Cut(line, []byte(":"))
+		if ok && textproto.CanonicalMIMEHeaderKey(string(bytes.TrimSpace(name))) == "Server" {
+			return banner + ", Server: " + sanitizeBanner(value)
+		}
+	}
+	return banner
+}
+
+// sanitizeBanner keeps printable ASCII, a terminal must not be handed a
+// service's control sequences
+func sanitizeBanner(b []byte) string {
+	s := strings.Map(func(r rune) rune {
+		switch {
+		case r == '\t':
+			return ' '
+		case r < 0x20 || r > 0x7e:
+			return '.'
+		}
+		return r
+	}, string(bytes.TrimRight(b, "\r")))
+	return strings.TrimSpace(s)
+}
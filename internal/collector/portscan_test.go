@@ -0,0 +1,123 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestPortScanner_LocalListener(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen: %v", err)
+	}
+	defer ln.Close()
+	open := ln.Addr().(*net.TCPAddr).Port
+
+	// Free ports, nothing listens on them
+	var closed []int
+	for range 3 {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		closed = append(closed, l.Addr().(*net.TCPAddr).Port)
+		l.Close()
+	}
+
+	s := NewPortScanner()
+	s.Ports = append([]int{closed[0], open}, closed[1:]...)
+	scan := s.Scan(context.Background(), "127.0.0.1")
+	if scan.Error != nil {
+		t.Fatalf("Scan() error = %v", scan.Error)
+	}
+	if got := scan.Open(); !slices.Equal(got, []int{open}) {
+		t.Errorf("open ports = %v, want [%d]", got, open)
+	}
+	for _, p := range scan.Ports {
+		if p.Port != open && p.Reachability != ReachClosed {
+			t.Errorf("port %d = %q (err %v), want closed", p.Port, p.Reachability, p.Error)
+		}
+	}
+}
+
+func TestPortScanner_CapsPortList(t *testing.T) {
+	s := NewPortScanner()
+	s.Ports = make([]int, MaxScanPorts+10)
+	for i := range s.Ports {
+		s.Ports[i] = 1 + i
+	}
+	scan := s.Scan(context.Background(), "127.0.0.1")
+	if scan.Error != nil {
+		t.Fatalf("Scan() error = %v", scan.Error)
+	}
+	if len(scan.Ports) != MaxScanPorts {
+		t.Errorf("scanned %d ports, want %d", len(scan.Ports), MaxScanPorts)
+	}
+}
+
+func TestPortScanner_Budget(t *testing.T) {
+	s := NewPortScanner()
+	s.Ports = []int{1, 2, 3}
+	s.Budget, _ = fixedBudget(2, 0)
+	scan := s.Scan(context.Background(), "127.0.0.1")
+	if scan.Error != nil {
+		t.Fatalf("Scan() error = %v", scan.Error)
+	}
+	var skipped []int
+	for _, p := range scan.Ports {
+		if errors.Is(p.Error, ErrBudgetExceeded) {
+			skipped = append(skipped, p.Port)
+		}
+	}
+	if !slices.Equal(skipped, []int{3}) {
+		t.Errorf("ports over the budget = %v, want [3]", skipped)
+	}
+}
+
+func TestPortScanner_Banner(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte("SSH-2.0-OpenSSH_9.6\x1b[2J\r\nrest"))
+			conn.Close()
+		}
+	}()
+
+	s := NewPortScanner()
+	s.Ports = []int{ln.Addr().(*net.TCPAddr).Port}
+	s.GrabBanner = true
+	scan := s.Scan(context.Background(), "127.0.0.1")
+	if len(scan.Ports) != 1 || scan.Ports[0].Banner != "SSH-2.0-OpenSSH_9.6.[2J" {
+		t.Errorf("scan = %+v, want the sanitized SSH banner", scan)
+	}
+}
+
+func TestGrabBanner_HTTP(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	go func() {
+		defer server.Close()
+		req := make([]byte, 64)
+		n, _ := server.Read(req)
+		if !strings.HasPrefix(string(req[:n]), "HEAD / HTTP/1.0\r\n") {
+			return
+		}
+		server.Write([]byte("HTTP/1.1 301 Moved Permanently\r\nLocation: https://example.com/\r\nserver: nginx/1.24.0\r\n\r\n"))
+	}()
+
+	if got, want := grabBanner(context.Background(), client, 80), "HTTP/1.1 301 Moved Permanently, Server: nginx/1.24.0"; got != want {
+		t.Errorf("grabBanner() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,65 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// powerSupplyPath lists the kernel's power supplies, AC adapters and batteries
+const powerSupplyPath = "/sys/class/power_supply"
+
+// PowerSource is where the host draws power from
+type PowerSource string
+
+const (
+	PowerAC      PowerSource = "ac"
+	PowerBattery PowerSource = "battery"
+	PowerUnknown PowerSource = "unknown" // No battery, e.g. a desktop or VM, or no sysfs
+)
+
+// ReadPowerSource reports whether the host runs on battery. A discharging
+// battery decides it; a charging or full battery or an online adapter
+// means AC. Peripheral batteries, a wireless mouse or headset with scope
+// Device, say nothing about the host and are skipped.
+func ReadPowerSource(dir string) PowerSource {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return PowerUnknown
+	}
+	source := PowerUnknown
+	for _, e := range entries {
+		supply := filepath.Join(dir, e.Name())
+		if readSysfs(supply, "scope") == "Device" {
+			continue
+		}
+		switch readSysfs(supply, "type") {
+		case "Battery":
+			switch readSysfs(supply, "status") {
+			case "Discharging":
+				return PowerBattery
+			case "Charging", "Full", "Not charging":
+				source = PowerAC
+			}
+		case "Mains", "USB":
+			if readSysfs(supply, "online") == "1" {
+				source = PowerAC
+			}
+		}
+	}
+	return source
+}
+
+// PowerSourceNow reads the host's power supplies
+func PowerSourceNow() PowerSource {
+	return ReadPowerSource(powerSupplyPath)
+}
+
+// readSysfs returns a trimmed sysfs attribute, empty if unreadable
+func readSysfs(dir, name string) string {
+	b, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
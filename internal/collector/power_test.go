@@ -0,0 +1,69 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeSupply creates a fake /sys/class/power_supply entry
+func writeSupply(t *testing.T, root, name string, attrs map[string]string) {
+	t.Helper()
+	dir := filepath.Join(root, name)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range attrs {
+		if err := os.WriteFile(filepath.Join(dir, k), []byte(v+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReadPowerSource(t *testing.T) {
+	tests := []struct {
+		name     string
+		supplies map[string]map[string]string
+		want     PowerSource
+	}{
+		{"discharging", map[string]map[string]string{
+			"AC":   {"type": "Mains", "online": "0"},
+			"BAT0": {"type": "Battery", "status": "Discharging"},
+		}, PowerBattery},
+		{"charging", map[string]map[string]string{
+			"AC":   {"type": "Mains", "online": "1"},
+			"BAT0": {"type": "Battery", "status": "Charging"},
+		}, PowerAC},
+		{"full without adapter entry", map[string]map[string]string{
+			"BAT0": {"type": "Battery", "status": "Full"},
+		}, PowerAC},
+		{"second battery discharging", map[string]map[string]string{
+			"BAT0": {"type": "Battery", "status": "Not charging"},
+			"BAT1": {"type": "Battery", "status": "Discharging"},
+		}, PowerBattery},
+		{"desktop with a wireless mouse", map[string]map[string]string{
+			"hidpp_battery_0": {"type": "Battery", "scope": "Device", "status": "Discharging"},
+		}, PowerUnknown},
+		{"laptop on AC with a headset", map[string]map[string]string{
+			"AC":                  {"type": "Mains", "online": "1"},
+			"BAT0":                {"type": "Battery", "scope": "System", "status": "Full"},
+			"hid-headset-battery": {"type": "Battery", "scope": "Device", "status": "Discharging"},
+		}, PowerAC},
+		{"desktop", map[string]map[string]string{}, PowerUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			for name, attrs := range tt.supplies {
+				writeSupply(t, root, name, attrs)
+			}
+			if got := ReadPowerSource(root); got != tt.want {
+				t.Errorf("ReadPowerSource() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if got := ReadPowerSource(filepath.Join(t.TempDir(), "missing")); got != PowerUnknown {
+		t.Errorf("missing sysfs = %q, want unknown", got)
+	}
+}
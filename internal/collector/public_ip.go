@@ -2,6 +2,7 @@ package collector
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
@@ -13,10 +14,22 @@ type PublicIPInfo struct {
 	IP       string
 	Provider string
 	Error    error
+
+	// Filled by Geolocate, a failure there leaves the IP intact
+	ASN      string // e.g. AS13335, empty if the lookup had none
+	Org      string // Network operator
+	City     string
+	Country  string // ISO 3166 code as returned by the endpoint
+	GeoError error
 }
 
+// DefaultGeoURL is the geolocation endpoint, %s is replaced by the IP
+const DefaultGeoURL = "https://ipinfo.io/%s/json"
+
 type PublicIPCollector struct {
 	providers []string
+	GeoURL    string  // Geolocation endpoint in ipinfo.io's JSON format, %s is the IP
+	Budget    *Budget // Shared probe budget, each provider tried counts
 }
 
 func NewPublicIPCollector() *PublicIPCollector {
@@ -32,6 +45,7 @@ func NewPublicIPCollector() *PublicIPCollector {
 			"https://whatismyip.akamai.com",
 			"https://myexternalip.com/raw",
 		},
+		GeoURL: DefaultGeoURL,
 	}
 }
 
@@ -41,6 +55,9 @@ func (c *PublicIPCollector) Collect() PublicIPInfo {
 	defer cancel()
 
 	for _, url := range c.providers {
+		if !c.Budget.Allow(1, httpsProbeBytes) {
+			return PublicIPInfo{Error: ErrBudgetExceeded}
+		}
 		ip, err := c.fetchIP(ctx, url)
 		if err == nil && ip != "" {
 			return PublicIPInfo{
@@ -55,28 +72,43 @@ func (c *PublicIPCollector) Collect() PublicIPInfo {
 	}
 }
 
-func (c *PublicIPCollector) fetchIP(ctx context.Context, url string) (string, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return "", err
+// Geolocate looks up the ASN, operator and rough location of info.IP. It is
+// separate from Collect so that a failing geolocation endpoint only sets
+// GeoError and the IP stays.
+func (c *PublicIPCollector) Geolocate(info PublicIPInfo) PublicIPInfo {
+	if info.Error != nil || info.IP == "" {
+		return info
 	}
-	req.Header.Set("User-Agent", "curl/7.68.0") // Some services block unknown UAs
-
-	client := &http.Client{
-		Timeout: 3 * time.Second,
+	if !c.Budget.Allow(1, httpsProbeBytes) {
+		info.GeoError = ErrBudgetExceeded
+		return info
 	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
 
-	resp, err := client.Do(req)
+	body, err := c.get(ctx, strings.ReplaceAll(c.GeoURL, "%s", info.IP))
 	if err != nil {
-		return "", err
+		info.GeoError = err
+		return info
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("status code %d", resp.StatusCode)
+	var geo struct {
+		City    string `json:"city"`
+		Country string `json:"country"`
+		Org     string `json:"org"` // "AS13335 Cloudflare, Inc."
+	}
+	if err := json.Unmarshal(body, &geo); err != nil {
+		info.GeoError = fmt.Errorf("parsing geolocation: %w", err)
+		return info
 	}
+	info.City, info.Country, info.Org = geo.City, geo.Country, geo.Org
+	if asn, org, ok := strings.Cut(geo.Org, " "); ok && strings.HasPrefix(asn, "AS") {
+		info.ASN, info.Org = asn, org
+	}
+	return info
+}
 
-	body, err := io.ReadAll(resp.Body)
+func (c *PublicIPCollector) fetchIP(ctx context.Context, url string) (string, error) {
+	body, err := c.get(ctx, url)
 	if err != nil {
 		return "", err
 	}
@@ -89,3 +121,28 @@ func (c *PublicIPCollector) fetchIP(ctx context.Context, url string) (string, er
 
 	return ip, nil
 }
+
+// get fetches url with the User-Agent the providers accept
+func (c *PublicIPCollector) get(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "curl/7.68.0") // Some services block unknown UAs
+
+	client := &http.Client{
+		Timeout: 3 * time.Second,
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status code %d", resp.StatusCode)
+	}
+
+	return io.ReadAll(resp.Body)
+}
@@ -0,0 +1,44 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPublicIPCollector_Geolocate(t *testing.T) {
+	var path, agent string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, agent = r.URL.Path, r.UserAgent()
+		fmt.Fprint(w, `{"ip":"203.0.113.45","city":"Berlin","country":"DE","org":"AS3320 Deutsche Telekom AG"}`)
+	}))
+	defer ts.Close()
+
+	c := NewPublicIPCollector()
+	c.GeoURL = ts.URL + "/%s/json"
+	info := c.Geolocate(PublicIPInfo{IP: "203.0.113.45", Provider: "test"})
+	if info.GeoError != nil {
+		t.Fatalf("Geolocate() error = %v", info.GeoError)
+	}
+	if path != "/203.0.113.45/json" || agent != "curl/7.68.0" {
+		t.Errorf("request path %q, User-Agent %q", path, agent)
+	}
+	if info.ASN != "AS3320" || info.Org != "Deutsche Telekom AG" || info.City != "Berlin" || info.Country != "DE" {
+		t.Errorf("info = %+v", info)
+	}
+
+	// A failing endpoint keeps the IP
+	ts.Close()
+	info = c.Geolocate(PublicIPInfo{IP: "203.0.113.45", Provider: "test"})
+	if info.GeoError == nil || info.IP != "203.0.113.45" {
+		t.Errorf("info = %+v, want the IP with a GeoError", info)
+	}
+
+	// Nothing to locate without an IP
+	failed := PublicIPInfo{Error: errors.New("no provider")}
+	if info := c.Geolocate(failed); info.GeoError != nil {
+		t.Errorf("Geolocate() of a failed lookup set GeoError %v", info.GeoError)
+	}
+}
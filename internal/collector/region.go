@@ -0,0 +1,111 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// RegionEndpoint is a well-known host used as a latency landmark
+type RegionEndpoint struct {
+	Name    string
+	Address string // host:port, dialed over TCP
+}
+
+// DefaultRegionEndpoints mixes anycast edges with fixed cloud regions
+var DefaultRegionEndpoints = []RegionEndpoint{
+	{Name: "Cloudflare (anycast)", Address: "1.1.1.1:443"},
+	{Name: "Google (anycast)", Address: "8.8.8.8:443"},
+	{Name: "AWS us-east-1 (Virginia)", Address: "ec2.us-east-1.amazonaws.com:443"},
+	{Name: "AWS us-west-2 (Oregon)", Address: "ec2.us-west-2.amazonaws.com:443"},
+	{Name: "AWS eu-west-1 (Ireland)", Address: "ec2.eu-west-1.amazonaws.com:443"},
+	{Name: "AWS eu-central-1 (Frankfurt)", Address: "ec2.eu-central-1.amazonaws.com:443"},
+	{Name: "AWS ap-northeast-1 (Tokyo)", Address: "ec2.ap-northeast-1.amazonaws.com:443"},
+	{Name: "AWS ap-southeast-1 (Singapore)", Address: "ec2.ap-southeast-1.amazonaws.com:443"},
+	{Name: "AWS ap-south-1 (Mumbai)", Address: "ec2.ap-south-1.amazonaws.com:443"},
+	{Name: "AWS sa-east-1 (Sao Paulo)", Address: "ec2.sa-east-1.amazonaws.com:443"},
+}
+
+// RegionLatency is the TCP connect time to one region endpoint
+type RegionLatency struct {
+	Name    string
+	Address string
+	Latency time.Duration
+	Error   error
+}
+
+type RegionCollector struct {
+	Endpoints []RegionEndpoint
+	Timeout   time.Duration // Upper bound for the whole measurement
+	Budget    *Budget       // Shared probe budget, endpoints over it are skipped
+	dial      func(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+func NewRegionCollector(endpoints []RegionEndpoint) *RegionCollector {
+	if len(endpoints) == 0 {
+		endpoints = DefaultRegionEndpoints
+	}
+	return &RegionCollector{
+		Endpoints: endpoints,
+		Timeout:   3 * time.Second,
+		dial:      (&net.Dialer{}).DialContext,
+	}
+}
+
+// Collect measures all endpoints concurrently and returns them sorted by latency.
+// Unreachable endpoints are listed last.
+func (c *RegionCollector) Collect() []RegionLatency {
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+	defer cancel()
+
+	results := make([]RegionLatency, len(c.Endpoints))
+	var wg sync.WaitGroup
+
+	for i, ep := range c.Endpoints {
+		wg.Add(1)
+		go func(i int, ep RegionEndpoint) {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					results[i] = RegionLatency{Name: ep.Name, Address: ep.Address, Error: fmt.Errorf("panic: %v", r)}
+				}
+			}()
+			results[i] = c.measure(ctx, ep)
+		}(i, ep)
+	}
+
+	wg.Wait()
+	sortRegionLatencies(results)
+	return results
+}
+
+func (c *RegionCollector) measure(ctx context.Context, ep RegionEndpoint) RegionLatency {
+	res := RegionLatency{Name: ep.Name, Address: ep.Address}
+	if !c.Budget.Allow(1, tcpProbeBytes) {
+		res.Error = ErrBudgetExceeded
+		return res
+	}
+	start := time.Now()
+	conn, err := c.dial(ctx, "tcp", ep.Address)
+	if err != nil {
+		res.Error = err
+		return res
+	}
+	res.Latency = time.Since(start)
+	conn.Close()
+	return res
+}
+
+// sortRegionLatencies orders reachable endpoints by latency, failures last
+func sortRegionLatencies(results []RegionLatency) {
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if (a.Error == nil) != (b.Error == nil) {
+			return a.Error == nil
+		}
+		return a.Latency < b.Latency
+	})
+}
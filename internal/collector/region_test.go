@@ -0,0 +1,69 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRegionCollector_Collect(t *testing.T) {
+	delays := map[string]time.Duration{
+		"slow.example:443":   60 * time.Millisecond,
+		"fast.example:443":   5 * time.Millisecond,
+		"medium.example:443": 30 * time.Millisecond,
+		"hang.example:443":   time.Hour, // Must be cut off by the total timeout
+	}
+
+	c := NewRegionCollector([]RegionEndpoint{
+		{Name: "Slow", Address: "slow.example:443"},
+		{Name: "Down", Address: "down.example:443"},
+		{Name: "Fast", Address: "fast.example:443"},
+		{Name: "Hang", Address: "hang.example:443"},
+		{Name: "Medium", Address: "medium.example:443"},
+	})
+	c.Timeout = 500 * time.Millisecond
+	c.dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+		delay, ok := delays[address]
+		if !ok {
+			return nil, fmt.Errorf("connection refused")
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		client, server := net.Pipe()
+		server.Close()
+		return client, nil
+	}
+
+	start := time.Now()
+	results := c.Collect()
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Collect took %s, expected it to be bounded by the timeout", elapsed)
+	}
+
+	want := []string{"Fast", "Medium", "Slow"}
+	if len(results) != 5 {
+		t.Fatalf("expected 5 results, got %d", len(results))
+	}
+	for i, name := range want {
+		if results[i].Name != name || results[i].Error != nil {
+			t.Errorf("results[%d] = %s (err %v), want %s", i, results[i].Name, results[i].Error, name)
+		}
+	}
+	for _, res := range results[len(want):] {
+		if res.Error == nil {
+			t.Errorf("%s: expected error, got latency %s", res.Name, res.Latency)
+		}
+	}
+}
+
+func TestNewRegionCollector_Defaults(t *testing.T) {
+	c := NewRegionCollector(nil)
+	if len(c.Endpoints) != len(DefaultRegionEndpoints) {
+		t.Errorf("expected default endpoints, got %d", len(c.Endpoints))
+	}
+}
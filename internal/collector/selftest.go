@@ -0,0 +1,151 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/vishvananda/netlink"
+)
+
+// Self-test check names, in display order
+const (
+	SelfCheckICMP     = "Raw ICMP sockets"
+	SelfCheckSNMP     = "/proc/net/snmp"
+	SelfCheckSockDiag = "Netlink socket diag"
+	SelfCheckInternet = "Internet reachability"
+	SelfCheckDNS      = "DNS resolution"
+)
+
+// SelfCheck is one line of the startup capability checklist
+type SelfCheck struct {
+	Name   string
+	OK     bool
+	Detail string // What works, or what degrades when the check fails
+	Error  error
+}
+
+// SelfTestCollector verifies the assumptions lnd's features rely on in the
+// current environment: privileges, kernel interfaces and network access
+type SelfTestCollector struct {
+	ProcRoot string // /proc, replaceable in tests
+	Timeout  time.Duration
+
+	openRawICMP func() error
+	socketDiag  func() error
+	dial        func(ctx context.Context, network, address string) (net.Conn, error)
+	lookupHost  func(ctx context.Context, host string) ([]string, error)
+}
+
+func NewSelfTestCollector() *SelfTestCollector {
+	dialer := &net.Dialer{}
+	return &SelfTestCollector{
+		ProcRoot:    "/proc",
+		Timeout:     3 * time.Second,
+		openRawICMP: openRawICMP,
+		socketDiag: func() error {
+			_, err := netlink.SocketDiagTCPInfo(syscall.AF_INET)
+			return err
+		},
+		dial:       dialer.DialContext,
+		lookupHost: net.DefaultResolver.LookupHost,
+	}
+}
+
+// Run performs every check concurrently, keeping the display order
+func (c *SelfTestCollector) Run(ctx context.Context) []SelfCheck {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	checks := []func(context.Context) SelfCheck{
+		c.checkICMP,
+		c.checkSNMP,
+		c.checkSockDiag,
+		c.checkInternet,
+		c.checkDNS,
+	}
+	results := make([]SelfCheck, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = check(ctx)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// openRawICMP opens and closes the raw socket privileged pings use
+func openRawICMP() error {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_RAW, syscall.IPPROTO_ICMP)
+	if err != nil {
+		return err
+	}
+	return syscall.Close(fd)
+}
+
+// permissionHint explains a permission error, other errors pass through
+func permissionHint(err error, hint string) string {
+	if errors.Is(err, syscall.EPERM) || errors.Is(err, syscall.EACCES) {
+		return hint
+	}
+	return err.Error()
+}
+
+func (c *SelfTestCollector) checkICMP(context.Context) SelfCheck {
+	if err := c.openRawICMP(); err != nil {
+		return SelfCheck{Name: SelfCheckICMP, Error: err,
+			Detail: permissionHint(err, "needs root or CAP_NET_RAW") + ", pings fall back to TCP connects"}
+	}
+	return SelfCheck{Name: SelfCheckICMP, OK: true, Detail: "ICMP ping and DSCP marking available"}
+}
+
+func (c *SelfTestCollector) checkSNMP(context.Context) SelfCheck {
+	f, err := os.Open(filepath.Join(c.ProcRoot, "net/snmp"))
+	if err != nil {
+		return SelfCheck{Name: SelfCheckSNMP, Error: err, Detail: "Kernel tab and retransmission stats unavailable"}
+	}
+	defer f.Close()
+	if _, err := f.Read(make([]byte, 1)); err != nil {
+		return SelfCheck{Name: SelfCheckSNMP, Error: err, Detail: "Kernel tab and retransmission stats unavailable"}
+	}
+	return SelfCheck{Name: SelfCheckSNMP, OK: true, Detail: "TCP/UDP kernel counters readable"}
+}
+
+func (c *SelfTestCollector) checkSockDiag(context.Context) SelfCheck {
+	if err := c.socketDiag(); err != nil {
+		return SelfCheck{Name: SelfCheckSockDiag, Error: err,
+			Detail: permissionHint(err, "blocked by the sandbox or missing permission") + ", MSS check unavailable"}
+	}
+	return SelfCheck{Name: SelfCheckSockDiag, OK: true, Detail: "per-connection TCP info available"}
+}
+
+// checkInternet connects to a well-known anycast address, so it does not
+// depend on DNS working
+func (c *SelfTestCollector) checkInternet(ctx context.Context) SelfCheck {
+	conn, err := c.dial(ctx, "tcp", "1.1.1.1:443")
+	if err != nil {
+		return SelfCheck{Name: SelfCheckInternet, Error: err, Detail: "public IP, NAT, regions and remote probes will fail"}
+	}
+	conn.Close()
+	return SelfCheck{Name: SelfCheckInternet, OK: true, Detail: "TCP to 1.1.1.1:443 works"}
+}
+
+func (c *SelfTestCollector) checkDNS(ctx context.Context) SelfCheck {
+	addrs, err := c.lookupHost(ctx, "example.com")
+	if err == nil && len(addrs) == 0 {
+		err = fmt.Errorf("no addresses for example.com")
+	}
+	if err != nil {
+		return SelfCheck{Name: SelfCheckDNS, Error: err, Detail: "hostname targets will fail, IP targets still work"}
+	}
+	return SelfCheck{Name: SelfCheckDNS, OK: true, Detail: "system resolver answers"}
+}
@@ -0,0 +1,85 @@
+package collector
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// simulatedSelfTest returns a self-test whose probes all succeed
+func simulatedSelfTest(t *testing.T) *SelfTestCollector {
+	t.Helper()
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "net"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "net/snmp"), []byte("Tcp: RtoAlgorithm\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewSelfTestCollector()
+	c.ProcRoot = root
+	c.openRawICMP = func() error { return nil }
+	c.socketDiag = func() error { return nil }
+	c.dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+		client, server := net.Pipe()
+		server.Close()
+		return client, nil
+	}
+	c.lookupHost = func(ctx context.Context, host string) ([]string, error) {
+		return []string{"192.0.2.1"}, nil
+	}
+	return c
+}
+
+func TestSelfTest_AllAvailable(t *testing.T) {
+	checks := simulatedSelfTest(t).Run(context.Background())
+	want := []string{SelfCheckICMP, SelfCheckSNMP, SelfCheckSockDiag, SelfCheckInternet, SelfCheckDNS}
+	if len(checks) != len(want) {
+		t.Fatalf("got %d checks, want %d", len(checks), len(want))
+	}
+	for i, check := range checks {
+		if check.Name != want[i] {
+			t.Errorf("check %d = %q, want %q", i, check.Name, want[i])
+		}
+		if !check.OK || check.Error != nil {
+			t.Errorf("%s failed: %v", check.Name, check.Error)
+		}
+	}
+}
+
+func TestSelfTest_Degraded(t *testing.T) {
+	c := simulatedSelfTest(t)
+	c.ProcRoot = t.TempDir() // No net/snmp, e.g. /proc not mounted
+	c.openRawICMP = func() error { return syscall.EPERM }
+	c.socketDiag = func() error { return syscall.EACCES }
+	c.dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+		return nil, syscall.ENETUNREACH
+	}
+	c.lookupHost = func(ctx context.Context, host string) ([]string, error) {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	checks := c.Run(context.Background())
+	for _, check := range checks {
+		if check.OK || check.Error == nil {
+			t.Errorf("%s should fail", check.Name)
+		}
+	}
+	if !strings.Contains(checks[0].Detail, "CAP_NET_RAW") || !strings.Contains(checks[0].Detail, "TCP") {
+		t.Errorf("ICMP detail should explain the permission and the fallback, got %q", checks[0].Detail)
+	}
+	if !strings.Contains(checks[2].Detail, "permission") {
+		t.Errorf("socket diag detail should mention permissions, got %q", checks[2].Detail)
+	}
+
+	// Failures other than permissions are reported as is
+	c.openRawICMP = func() error { return syscall.EAFNOSUPPORT }
+	if check := c.checkICMP(context.Background()); strings.Contains(check.Detail, "CAP_NET_RAW") {
+		t.Errorf("non-permission error got a permission hint: %q", check.Detail)
+	}
+}
@@ -0,0 +1,261 @@
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	procSoftirqsPath   = "/proc/softirqs"
+	procInterruptsPath = "/proc/interrupts"
+	procStatPath       = "/proc/stat"
+
+	// A core spending this much of its time in softirq context has no room left
+	softirqSaturatedPct = 50.0
+	// One core taking this share of all NET_RX work means receive processing
+	// is not spread (no RSS/RPS)
+	netRXSkewShare = 0.8
+	// Below this many NET_RX softirqs per second the skew is not worth reporting
+	netRXSkewMinRate = 1000.0
+)
+
+// CPUNetLoad is the network interrupt load of one CPU over the last interval
+type CPUNetLoad struct {
+	CPU        int
+	NetRXRate  float64 // NET_RX softirqs per second
+	NetTXRate  float64 // NET_TX softirqs per second
+	IRQRate    float64 // NIC hardware interrupts per second
+	RXShare    float64 // Fraction of all NET_RX softirqs handled by this CPU
+	SoftirqPct float64 // Time spent in softirq context, all softirq types, 0-100
+	Saturated  bool    // Busy with softirqs while carrying network receive work
+}
+
+// SoftirqStats correlates per-CPU network interrupt work with traffic
+type SoftirqStats struct {
+	CPUs       []CPUNetLoad
+	SingleCore int // CPU handling nearly all NET_RX work, -1 if receive work is spread or light
+	Error      error
+}
+
+// softirqSample is one reading of the per-CPU counters
+type softirqSample struct {
+	netRX, netTX []uint64
+	irqs         []uint64 // NIC hardware interrupts per CPU
+	softirqTime  []uint64 // /proc/stat softirq jiffies per CPU
+	totalTime    []uint64 // /proc/stat all jiffies per CPU
+}
+
+// SoftirqCollector samples /proc/softirqs, /proc/interrupts and /proc/stat
+// and turns consecutive samples into rates
+type SoftirqCollector struct {
+	mu       sync.Mutex
+	last     *softirqSample
+	lastTime time.Time
+}
+
+func NewSoftirqCollector() *SoftirqCollector {
+	return &SoftirqCollector{}
+}
+
+// Collect returns rates since the previous call; the first call only primes
+// the counters and returns no CPUs
+func (c *SoftirqCollector) Collect() (stats SoftirqStats, err error) {
+	stats.SingleCore = -1
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	cur, err := readSoftirqSample()
+	if err != nil {
+		stats.Error = err
+		return stats, err
+	}
+	if c.last != nil {
+		stats = netLoad(*c.last, *cur, now.Sub(c.lastTime))
+	}
+	c.last, c.lastTime = cur, now
+	return stats, nil
+}
+
+// readSoftirqSample reads the counters; NIC interrupts and CPU times are best effort
+func readSoftirqSample() (*softirqSample, error) {
+	f, err := os.Open(procSoftirqsPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	softirqs, err := parseSoftirqs(f)
+	if err != nil {
+		return nil, err
+	}
+	s := &softirqSample{netRX: softirqs["NET_RX"], netTX: softirqs["NET_TX"]}
+
+	if f, err := os.Open(procInterruptsPath); err == nil {
+		s.irqs, _ = parseNICInterrupts(f, nicIRQs())
+		f.Close()
+	}
+	if f, err := os.Open(procStatPath); err == nil {
+		s.softirqTime, s.totalTime, _ = parseCPUTimes(f)
+		f.Close()
+	}
+	return s, nil
+}
+
+// parseSoftirqs parses /proc/softirqs into per-CPU counters by softirq name.
+// The first line names the CPUs, each other line is "NAME: count per CPU".
+func parseSoftirqs(r io.Reader) (map[string][]uint64, error) {
+	scanner := bufio.NewScanner(r)
+	if !scanner.Scan() {
+		return nil, fmt.Errorf("/proc/softirqs: empty")
+	}
+	cpus := len(strings.Fields(scanner.Text()))
+	result := make(map[string][]uint64)
+	for scanner.Scan() {
+		name, rest, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) < cpus {
+			return nil, fmt.Errorf("/proc/softirqs %s: expected %d CPUs, got %d", strings.TrimSpace(name), cpus, len(fields))
+		}
+		counts := make([]uint64, cpus)
+		for i := range counts {
+			n, err := strconv.ParseUint(fields[i], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("/proc/softirqs %s: %v", strings.TrimSpace(name), err)
+			}
+			counts[i] = n
+		}
+		result[strings.TrimSpace(name)] = counts
+	}
+	return result, scanner.Err()
+}
+
+// parseNICInterrupts sums the /proc/interrupts rows of the given IRQ numbers
+// per CPU. Rows are "IRQ: count per CPU, chip, hwirq, name".
+func parseNICInterrupts(r io.Reader, irqs map[string]bool) ([]uint64, error) {
+	scanner := bufio.NewScanner(r)
+	if !scanner.Scan() {
+		return nil, fmt.Errorf("/proc/interrupts: empty")
+	}
+	cpus := len(strings.Fields(scanner.Text()))
+	sums := make([]uint64, cpus)
+	for scanner.Scan() {
+		irq, rest, ok := strings.Cut(scanner.Text(), ":")
+		if !ok || !irqs[strings.TrimSpace(irq)] {
+			continue
+		}
+		fields := strings.Fields(rest)
+		for i := 0; i < cpus && i < len(fields); i++ {
+			n, err := strconv.ParseUint(fields[i], 10, 64)
+			if err != nil {
+				break
+			}
+			sums[i] += n
+		}
+	}
+	return sums, scanner.Err()
+}
+
+// nicIRQs returns the IRQ numbers of the network devices' MSI vectors. Virtio
+// NICs keep them on the parent PCI device.
+func nicIRQs() map[string]bool {
+	irqs := make(map[string]bool)
+	ifaces, _ := os.ReadDir("/sys/class/net")
+	for _, iface := range ifaces {
+		dev := filepath.Join("/sys/class/net", iface.Name(), "device")
+		for _, dir := range []string{filepath.Join(dev, "msi_irqs"), filepath.Join(dev, "..", "msi_irqs")} {
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				continue
+			}
+			for _, e := range entries {
+				irqs[e.Name()] = true
+			}
+			break
+		}
+	}
+	return irqs
+}
+
+// parseCPUTimes returns the softirq and total jiffies of each "cpuN" line of /proc/stat
+func parseCPUTimes(r io.Reader) (softirq, total []uint64, err error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 8 || !strings.HasPrefix(fields[0], "cpu") || fields[0] == "cpu" {
+			continue
+		}
+		var sum uint64
+		for _, f := range fields[1:] {
+			n, err := strconv.ParseUint(f, 10, 64)
+			if err != nil {
+				return nil, nil, fmt.Errorf("/proc/stat %s: %v", fields[0], err)
+			}
+			sum += n
+		}
+		si, _ := strconv.ParseUint(fields[7], 10, 64) // user nice system idle iowait irq softirq
+		softirq = append(softirq, si)
+		total = append(total, sum)
+	}
+	return softirq, total, scanner.Err()
+}
+
+// netLoad turns two samples taken elapsed apart into per-CPU rates and flags
+// cores saturated by network softirqs
+func netLoad(prev, cur softirqSample, elapsed time.Duration) SoftirqStats {
+	stats := SoftirqStats{SingleCore: -1}
+	secs := elapsed.Seconds()
+	if secs <= 0 {
+		return stats
+	}
+	rate := func(prev, cur []uint64, i int) float64 {
+		if i >= len(prev) || i >= len(cur) || cur[i] < prev[i] {
+			return 0
+		}
+		return float64(cur[i]-prev[i]) / secs
+	}
+
+	var totalRX float64
+	for i := range cur.netRX {
+		load := CPUNetLoad{
+			CPU:       i,
+			NetRXRate: rate(prev.netRX, cur.netRX, i),
+			NetTXRate: rate(prev.netTX, cur.netTX, i),
+			IRQRate:   rate(prev.irqs, cur.irqs, i),
+		}
+		if busy, all := rate(prev.softirqTime, cur.softirqTime, i), rate(prev.totalTime, cur.totalTime, i); all > 0 {
+			load.SoftirqPct = busy / all * 100
+		}
+		totalRX += load.NetRXRate
+		stats.CPUs = append(stats.CPUs, load)
+	}
+
+	for i := range stats.CPUs {
+		cpu := &stats.CPUs[i]
+		if totalRX > 0 {
+			cpu.RXShare = cpu.NetRXRate / totalRX
+		}
+		cpu.Saturated = cpu.SoftirqPct >= softirqSaturatedPct && cpu.NetRXRate > 0
+	}
+	if len(stats.CPUs) > 1 && totalRX >= netRXSkewMinRate {
+		busiest := 0
+		for i, cpu := range stats.CPUs {
+			if cpu.RXShare > stats.CPUs[busiest].RXShare {
+				busiest = i
+			}
+		}
+		if stats.CPUs[busiest].RXShare >= netRXSkewShare {
+			stats.SingleCore = busiest
+		}
+	}
+	return stats
+}
@@ -0,0 +1,134 @@
+package collector
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sampleSoftirqsCaptured is /proc/softirqs as read on a single-CPU Linux
+// 6.18 VM, including the trailing spaces of the header
+const sampleSoftirqsCaptured = `                    CPU0       
+          HI:          0
+       TIMER:      64797
+      NET_TX:          3
+      NET_RX:      11169
+       BLOCK:          0
+    IRQ_POLL:          0
+     TASKLET:         12
+       SCHED:          0
+     HRTIMER:        460
+         RCU:     117235
+`
+
+// Synthetic 4-core samples in the /proc/softirqs layout, one second apart,
+// as with RSS off: CPU1 takes all receive work
+const (
+	sampleSoftirqsBefore = `                    CPU0       CPU1       CPU2       CPU3
+          HI:          0          1          0          0
+       TIMER:     100983     120034      98812     101223
+      NET_TX:          4        120          2          1
+      NET_RX:      30152    8812345      12001      11876
+       BLOCK:      12345       2345       3456       4567
+     SCHED:      50000      60000      55000      52000
+`
+	sampleSoftirqsAfter = `                    CPU0       CPU1       CPU2       CPU3
+          HI:          0          1          0          0
+       TIMER:     101983     121034      99812     102223
+      NET_TX:          4        220          2          1
+      NET_RX:      30252    8832345      12101      11976
+       BLOCK:      12345       2345       3456       4567
+     SCHED:      50100      60100      55100      52100
+`
+)
+
+func TestParseSoftirqs(t *testing.T) {
+	irqs, err := parseSoftirqs(strings.NewReader(sampleSoftirqsBefore))
+	if err != nil {
+		t.Fatalf("parseSoftirqs() error = %v", err)
+	}
+	rx := irqs["NET_RX"]
+	if len(rx) != 4 || rx[1] != 8812345 || rx[3] != 11876 {
+		t.Errorf("NET_RX = %v", rx)
+	}
+
+	irqs, err = parseSoftirqs(strings.NewReader(sampleSoftirqsCaptured))
+	if err != nil {
+		t.Fatalf("parseSoftirqs() on the captured sample: %v", err)
+	}
+	if rx, tx := irqs["NET_RX"], irqs["NET_TX"]; len(rx) != 1 || rx[0] != 11169 || len(tx) != 1 || tx[0] != 3 {
+		t.Errorf("captured NET_RX = %v, NET_TX = %v", rx, tx)
+	}
+	if _, err := parseSoftirqs(strings.NewReader("CPU0 CPU1\nNET_RX: 1\n")); err == nil {
+		t.Error("expected error for a row with fewer CPUs than the header")
+	}
+}
+
+func TestNetLoad_SingleCoreBottleneck(t *testing.T) {
+	sample := func(text string, softirq, total []uint64) softirqSample {
+		irqs, err := parseSoftirqs(strings.NewReader(text))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return softirqSample{netRX: irqs["NET_RX"], netTX: irqs["NET_TX"], softirqTime: softirq, totalTime: total}
+	}
+	prev := sample(sampleSoftirqsBefore, []uint64{10, 500, 10, 10}, []uint64{1000, 1000, 1000, 1000})
+	cur := sample(sampleSoftirqsAfter, []uint64{11, 570, 11, 11}, []uint64{1100, 1100, 1100, 1100})
+
+	stats := netLoad(prev, cur, 2*time.Second)
+	if len(stats.CPUs) != 4 {
+		t.Fatalf("got %d CPUs, want 4", len(stats.CPUs))
+	}
+	wantRX := []float64{50, 10000, 50, 50}
+	for i, want := range wantRX {
+		if got := stats.CPUs[i].NetRXRate; got != want {
+			t.Errorf("CPU%d NET_RX = %.1f/s, want %.1f/s", i, got, want)
+		}
+	}
+	if got := stats.CPUs[1].NetTXRate; got != 50 {
+		t.Errorf("CPU1 NET_TX = %.1f/s, want 50/s", got)
+	}
+	if got := stats.CPUs[1].RXShare; math.Abs(got-10000.0/10150) > 1e-9 {
+		t.Errorf("CPU1 RX share = %.3f", got)
+	}
+	if got := stats.CPUs[1].SoftirqPct; got != 70 {
+		t.Errorf("CPU1 softirq = %.1f%%, want 70%%", got)
+	}
+	if !stats.CPUs[1].Saturated || stats.CPUs[0].Saturated {
+		t.Errorf("saturated = %v %v, want only CPU1", stats.CPUs[0].Saturated, stats.CPUs[1].Saturated)
+	}
+	if stats.SingleCore != 1 {
+		t.Errorf("SingleCore = %d, want 1", stats.SingleCore)
+	}
+}
+
+func TestNetLoad_SpreadReceive(t *testing.T) {
+	prev := softirqSample{netRX: []uint64{0, 0, 0, 0}}
+	cur := softirqSample{netRX: []uint64{5000, 5000, 4000, 6000}}
+	stats := netLoad(prev, cur, time.Second)
+	if stats.SingleCore != -1 {
+		t.Errorf("SingleCore = %d, want -1 with RSS spreading the load", stats.SingleCore)
+	}
+	for _, cpu := range stats.CPUs {
+		if cpu.Saturated {
+			t.Errorf("CPU%d saturated without softirq time", cpu.CPU)
+		}
+	}
+}
+
+func TestParseNICInterrupts(t *testing.T) {
+	input := `           CPU0       CPU1
+ 40:        774         10  PCI-MSIX-0000:00:04.0   0-edge      eth0-rx-0
+ 41:        729         20  PCI-MSIX-0000:00:04.0   1-edge      eth0-tx-0
+ 42:          5          5  IO-APIC   4-edge      ttyS0
+NMI:          0          0   Non-maskable interrupts
+`
+	sums, err := parseNICInterrupts(strings.NewReader(input), map[string]bool{"40": true, "41": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sums) != 2 || sums[0] != 774+729 || sums[1] != 30 {
+		t.Errorf("sums = %v", sums)
+	}
+}
@@ -0,0 +1,120 @@
+package collector
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+)
+
+// SourceInterface scopes diagnostics to one uplink by binding their sockets
+// to that interface's addresses. It is shared by collectors and safe for
+// concurrent use; a nil or unset SourceInterface leaves the choice to the kernel.
+type SourceInterface struct {
+	mu   sync.RWMutex
+	name string
+	v4   net.IP
+	v6   net.IP
+}
+
+// Set binds to the named interface, "" clears the binding. The interface
+// needs at least one non link-local unicast address.
+func (s *SourceInterface) Set(name string) error {
+	if name == "" {
+		return s.setAddrs("", nil)
+	}
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return err
+	}
+	if iface.Flags&net.FlagUp == 0 {
+		return fmt.Errorf("interface %s is down", name)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return err
+	}
+	return s.setAddrs(name, addrs)
+}
+
+func (s *SourceInterface) setAddrs(name string, addrs []net.Addr) error {
+	var v4, v6 net.IP
+	for _, a := range addrs {
+		ipNet, ok := a.(*net.IPNet)
+		if !ok || ipNet.IP.IsLinkLocalUnicast() || ipNet.IP.IsUnspecified() || ipNet.IP.IsMulticast() {
+			continue
+		}
+		if ip4 := ipNet.IP.To4(); ip4 != nil {
+			if v4 == nil {
+				v4 = ip4
+			}
+		} else if v6 == nil {
+			v6 = ipNet.IP
+		}
+	}
+	if name != "" && v4 == nil && v6 == nil {
+		return fmt.Errorf("interface %s has no usable address", name)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.name, s.v4, s.v6 = name, v4, v6
+	return nil
+}
+
+// Name returns the bound interface, "" if unbound
+func (s *SourceInterface) Name() string {
+	if s == nil {
+		return ""
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.name
+}
+
+// Addrs returns the bound IPv4 and IPv6 source addresses, either may be nil
+func (s *SourceInterface) Addrs() (net.IP, net.IP) {
+	if s == nil {
+		return nil, nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.v4, s.v6
+}
+
+// sourceFor picks the source address of the same family as dst. Hostnames
+// get the IPv4 source if there is one; the dialer then only tries
+// addresses of that family.
+func (s *SourceInterface) sourceFor(dst string) net.IP {
+	v4, v6 := s.Addrs()
+	if v4 == nil && v6 == nil {
+		return nil
+	}
+	if ip := net.ParseIP(strings.Split(dst, "%")[0]); ip != nil {
+		if ip.To4() != nil {
+			return v4
+		}
+		return v6
+	}
+	if v4 != nil {
+		return v4
+	}
+	return v6
+}
+
+// localAddr is the dialer LocalAddr for reaching address (host:port) over
+// network, nil when unbound
+func (s *SourceInterface) localAddr(network, address string, port int) net.Addr {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+	ip := s.sourceFor(host)
+	if ip == nil {
+		return nil
+	}
+	if strings.HasPrefix(network, "udp") {
+		return &net.UDPAddr{IP: ip, Port: port}
+	}
+	return &net.TCPAddr{IP: ip, Port: port}
+}
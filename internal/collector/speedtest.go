@@ -0,0 +1,236 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Speed test defaults. Each direction stops at whichever of duration and
+// data volume is reached first.
+const (
+	DefaultSpeedTestDownload = "https://speed.cloudflare.com/__down?bytes=25000000"
+	DefaultSpeedTestUpload   = "https://speed.cloudflare.com/__up"
+	DefaultSpeedTestDuration = 10 * time.Second
+	DefaultSpeedTestMaxBytes = 500 << 20
+
+	speedTestIdleSamples      = 5
+	speedTestProgressInterval = 200 * time.Millisecond
+)
+
+// SpeedTestServer is a pair of endpoints serving and accepting bulk data
+type SpeedTestServer struct {
+	Name        string
+	DownloadURL string // Fetched repeatedly, a known-size payload
+	UploadURL   string // Accepts POSTs, empty skips the upload
+}
+
+// DefaultSpeedTestServers are used when the config lists none
+var DefaultSpeedTestServers = []SpeedTestServer{
+	{Name: "Cloudflare", DownloadURL: DefaultSpeedTestDownload, UploadURL: DefaultSpeedTestUpload},
+}
+
+// SpeedTestPhase is the part of a speed test that is running
+type SpeedTestPhase string
+
+const (
+	SpeedTestIdle     SpeedTestPhase = "idle latency"
+	SpeedTestDownload SpeedTestPhase = "download"
+	SpeedTestUpload   SpeedTestPhase = "upload"
+)
+
+// SpeedTestProgress is a running phase so far
+type SpeedTestProgress struct {
+	Server  string
+	Phase   SpeedTestPhase
+	Elapsed time.Duration
+	Bytes   int64
+	Mbps    float64 // Average since the phase started
+}
+
+// SpeedTestResult is the throughput in both directions and the latency
+// while idle and under each load
+type SpeedTestResult struct {
+	Server          string
+	Idle            time.Duration // Median idle RTT to the server
+	DownloadMbps    float64
+	UploadMbps      float64
+	DownloadLatency time.Duration // Median RTT while downloading
+	UploadLatency   time.Duration // Median RTT while uploading
+	Downloaded      int64
+	Uploaded        int64
+	Stopped         bool // Cancelled before the end, the numbers cover what ran
+	Error           error
+}
+
+type SpeedTestCollector struct {
+	Duration time.Duration // Length of each direction
+	MaxBytes int64         // Upper bound per direction
+	Streams  int           // Concurrent transfers
+	Budget   *Budget       // Shared probe budget, MaxBytes is reserved up front
+	PingOptions
+	Source *SourceInterface // Binds the latency samples to one interface's addresses
+
+	client *http.Client
+	rtt    func(ctx context.Context, target string) (time.Duration, error)
+}
+
+func NewSpeedTestCollector() *SpeedTestCollector {
+	c := &SpeedTestCollector{
+		Duration: DefaultSpeedTestDuration,
+		MaxBytes: DefaultSpeedTestMaxBytes,
+		Streams:  4,
+		client:   &http.Client{},
+	}
+	c.Privileged = true
+	c.rtt = func(ctx context.Context, target string) (time.Duration, error) {
+		return sampleRTT(ctx, target, c.PingOptions, c.Source)
+	}
+	return c
+}
+
+// Run measures the idle latency to server, then downloads and uploads for
+// Duration each. progress, if set, is called about every 200ms while a
+// transfer runs. Cancelling ctx stops the test and keeps what was measured.
+func (c *SpeedTestCollector) Run(ctx context.Context, server SpeedTestServer, progress func(SpeedTestProgress)) SpeedTestResult {
+	res := SpeedTestResult{Server: server.Name}
+	u, err := url.Parse(server.DownloadURL)
+	if err != nil || u.Hostname() == "" {
+		res.Error = fmt.Errorf("invalid download URL %q", server.DownloadURL)
+		return res
+	}
+	target := u.Hostname()
+	phases := 1
+	if server.UploadURL != "" {
+		phases = 2
+	}
+	// The RTT samples are reserved up front, the transfers are charged as
+	// they move data and stop when the budget runs out
+	probes := speedTestIdleSamples + phases*int(c.Duration/speedTestProgressInterval)
+	if !c.Budget.Allow(probes, int64(probes*pingProbeBytes)) {
+		res.Error = ErrBudgetExceeded
+		return res
+	}
+	if progress == nil {
+		progress = func(SpeedTestProgress) {}
+	}
+
+	progress(SpeedTestProgress{Server: server.Name, Phase: SpeedTestIdle})
+	var idle []time.Duration
+	for i := 0; i < speedTestIdleSamples && ctx.Err() == nil; i++ {
+		if rtt, err := c.rtt(ctx, target); err == nil {
+			idle = append(idle, rtt)
+		}
+		sleepCtx(ctx, speedTestProgressInterval)
+	}
+	res.Idle = median(idle)
+
+	var mbps float64
+	res.Downloaded, mbps, res.DownloadLatency, err = c.phase(ctx, server.Name, SpeedTestDownload, target, progress,
+		func(ctx context.Context, account func(int64) bool) error {
+			return loadDownload(ctx, c.client, server.DownloadURL, account)
+		})
+	res.DownloadMbps = mbps
+	if err == nil && server.UploadURL != "" && ctx.Err() == nil {
+		res.Uploaded, mbps, res.UploadLatency, err = c.phase(ctx, server.Name, SpeedTestUpload, target, progress,
+			func(ctx context.Context, account func(int64) bool) error {
+				return loadUpload(ctx, c.client, server.UploadURL, account)
+			})
+		res.UploadMbps = mbps
+	}
+	res.Stopped = ctx.Err() != nil
+	if !res.Stopped {
+		res.Error = err
+	}
+	return res
+}
+
+// phase runs Streams transfers for Duration or MaxBytes while sampling the
+// RTT to target, and returns the bytes moved, their rate and the median RTT.
+// A phase cut short by the budget keeps its numbers and returns an error
+// wrapping ErrBudgetExceeded.
+func (c *SpeedTestCollector) phase(ctx context.Context, server string, phase SpeedTestPhase, target string,
+	progress func(SpeedTestProgress), load func(context.Context, func(int64) bool) error) (int64, float64, time.Duration, error) {
+	loadCtx, cancel := context.WithTimeout(ctx, c.Duration)
+	defer cancel()
+
+	var moved atomic.Int64
+	var overBudget atomic.Bool
+	account := func(n int64) bool {
+		if !c.Budget.Allow(0, n) {
+			overBudget.Store(true)
+			cancel()
+			return false
+		}
+		if moved.Add(n) >= c.MaxBytes {
+			cancel()
+			return false
+		}
+		return true
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var loadErr error
+	var latencies []time.Duration
+	for i := 0; i < c.Streams; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := load(loadCtx, account); err != nil {
+				mu.Lock()
+				if loadErr == nil {
+					loadErr = err
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for sleepCtx(loadCtx, speedTestProgressInterval) {
+			if rtt, err := c.rtt(loadCtx, target); err == nil && loadCtx.Err() == nil {
+				mu.Lock()
+				latencies = append(latencies, rtt)
+				mu.Unlock()
+			}
+		}
+	}()
+
+	start := time.Now()
+	rate := func() float64 {
+		if elapsed := time.Since(start).Seconds(); elapsed > 0 {
+			return float64(moved.Load()) * 8 / elapsed / 1e6
+		}
+		return 0
+	}
+	ticker := time.NewTicker(speedTestProgressInterval)
+	for running := true; running; {
+		select {
+		case <-loadCtx.Done():
+			running = false
+		case <-ticker.C:
+			progress(SpeedTestProgress{Server: server, Phase: phase, Elapsed: time.Since(start), Bytes: moved.Load(), Mbps: rate()})
+		}
+	}
+	ticker.Stop()
+	mbps := rate()
+	wg.Wait()
+
+	bytes := moved.Load()
+	if overBudget.Load() {
+		return bytes, mbps, median(latencies), fmt.Errorf("%s stopped early: %w", phase, ErrBudgetExceeded)
+	}
+	if bytes == 0 && ctx.Err() == nil {
+		if loadErr != nil {
+			return 0, 0, 0, fmt.Errorf("%s failed: %v", phase, loadErr)
+		}
+		return 0, 0, 0, fmt.Errorf("%s moved no data", phase)
+	}
+	return bytes, mbps, median(latencies), nil
+}
@@ -0,0 +1,96 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func newTestSpeedTestCollector(t *testing.T) (*SpeedTestCollector, SpeedTestServer) {
+	srv := throttledServer(t)
+	c := NewSpeedTestCollector()
+	c.Duration = time.Second
+	c.Streams = 2
+	c.client = srv.Client()
+	c.rtt = func(ctx context.Context, target string) (time.Duration, error) {
+		return 15 * time.Millisecond, nil
+	}
+	return c, SpeedTestServer{Name: "Test", DownloadURL: srv.URL + "/__down", UploadURL: srv.URL + "/__up"}
+}
+
+func TestSpeedTestCollector_Run(t *testing.T) {
+	c, server := newTestSpeedTestCollector(t)
+
+	var updates []SpeedTestProgress
+	res := c.Run(context.Background(), server, func(p SpeedTestProgress) { updates = append(updates, p) })
+	if res.Error != nil || res.Stopped {
+		t.Fatalf("Run() error = %v, stopped = %v", res.Error, res.Stopped)
+	}
+	if res.Downloaded == 0 || res.Uploaded == 0 || res.DownloadMbps <= 0 || res.UploadMbps <= 0 {
+		t.Errorf("expected throughput both ways: %+v", res)
+	}
+	// Two streams at about 1.6 MB/s each, roughly 26 Mbps
+	if res.DownloadMbps > 100 {
+		t.Errorf("DownloadMbps = %.1f, more than the server sends", res.DownloadMbps)
+	}
+	if res.Idle != 15*time.Millisecond || res.DownloadLatency != 15*time.Millisecond {
+		t.Errorf("latency idle %s, loaded %s", res.Idle, res.DownloadLatency)
+	}
+
+	phases := map[SpeedTestPhase]int{}
+	for _, p := range updates {
+		phases[p.Phase]++
+	}
+	// A second per direction at 200ms intervals
+	if phases[SpeedTestDownload] < 3 || phases[SpeedTestUpload] < 3 {
+		t.Errorf("progress updates per phase = %v", phases)
+	}
+}
+
+func TestSpeedTestCollector_Stop(t *testing.T) {
+	c, server := newTestSpeedTestCollector(t)
+	c.Duration = 30 * time.Second
+
+	ctx, cancel := context.WithCancel(context.Background())
+	start := time.Now()
+	res := c.Run(ctx, server, func(p SpeedTestProgress) {
+		if p.Phase == SpeedTestDownload && p.Bytes > 0 {
+			cancel()
+		}
+	})
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("stopping took %s", elapsed)
+	}
+	if !res.Stopped || res.Error != nil || res.Downloaded == 0 || res.Uploaded != 0 {
+		t.Errorf("stopped result = %+v", res)
+	}
+}
+
+func TestSpeedTestCollector_Unreachable(t *testing.T) {
+	c, _ := newTestSpeedTestCollector(t)
+	c.client = &http.Client{Timeout: time.Second}
+	res := c.Run(context.Background(), SpeedTestServer{Name: "Down", DownloadURL: "http://127.0.0.1:1/__down"}, nil)
+	if res.Error == nil {
+		t.Error("expected an error without a server")
+	}
+}
+
+func TestSpeedTestCollector_BudgetStopsTransfer(t *testing.T) {
+	c, server := newTestSpeedTestCollector(t)
+	c.Duration = 10 * time.Second
+	c.Budget = NewBudget(0, 256<<10) // Far below the 2 x MaxBytes a test may move
+
+	start := time.Now()
+	res := c.Run(context.Background(), server, nil)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("the budget should end the download early, took %s", elapsed)
+	}
+	if !errors.Is(res.Error, ErrBudgetExceeded) {
+		t.Fatalf("Run() error = %v, want ErrBudgetExceeded", res.Error)
+	}
+	if res.Downloaded == 0 || res.Downloaded > 256<<10 || res.Uploaded != 0 {
+		t.Errorf("Downloaded = %d, Uploaded = %d, want the download capped by the budget and no upload", res.Downloaded, res.Uploaded)
+	}
+}
@@ -6,8 +6,11 @@ import (
 	"io/ioutil"
 	"net"
 	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -16,19 +19,17 @@ import (
 	"github.com/vishvananda/netlink"
 )
 
-type SystemCollector struct{}
+type SystemCollector struct {
+	carrierChanges map[string]uint64 // Per interface, from the previous collection
+	mu             sync.Mutex
+}
 
 func NewSystemCollector() *SystemCollector {
-	return &SystemCollector{}
+	return &SystemCollector{carrierChanges: make(map[string]uint64)}
 }
 
 func (c *SystemCollector) Collect() (info HostInfo, err error) {
-	defer func() {
-		if r := recover(); r != nil {
-			err = fmt.Errorf("panic in SystemCollector: %v", r)
-			info.Error = err
-		}
-	}()
+	defer recoverPartial("SystemCollector", &info.Partial, &info.Error, &err)
 
 	info = HostInfo{
 		SysctlParams: make(map[string]string),
@@ -84,7 +85,14 @@ func (c *SystemCollector) Collect() (info HostInfo, err error) {
 		}
 	}
 
+	// TCP Congestion Control
+	if current, available, err := readCongestionControl("/proc/sys"); err == nil {
+		info.TCPCongestion = current
+		info.TCPCongestionAvail = available
+	}
+
 	// Network Interfaces
+	source := outgoingIPv6Source()
 	links, err := netlink.LinkList()
 	if err == nil {
 		for _, link := range links {
@@ -101,12 +109,25 @@ func (c *SystemCollector) Collect() (info HostInfo, err error) {
 				Offload: make(map[string]bool),
 			}
 
+			// Operational state and carrier flaps since the last collection
+			iface.OperState, iface.CarrierChanges = readLinkState("/sys", attrs.Name)
+			if iface.OperState == "" {
+				iface.OperState = attrs.OperState.String()
+			}
+			iface.CarrierFlaps = c.carrierFlaps(attrs.Name, iface.CarrierChanges)
+
 			// Get IP
 			addrs, err := netlink.AddrList(link, netlink.FAMILY_V4)
 			if err == nil && len(addrs) > 0 {
 				iface.IP = addrs[0].IP.String()
 			}
 
+			// IPv6 addresses with scope and lifetimes
+			if addrs6, err := netlink.AddrList(link, netlink.FAMILY_V6); err == nil {
+				iface.IPv6 = parseIPv6Addrs(addrs6)
+			}
+			iface.IPv6Privacy = readIPv6Privacy("/proc/sys", attrs.Name, iface.IPv6, source)
+
 			// Driver Info (Try via sysfs)
 			// /sys/class/net/<iface>/device/driver/module -> points to module name
 			// /sys/class/net/<iface>/device/uevent -> DRIVER=xxx
@@ -114,23 +135,131 @@ func (c *SystemCollector) Collect() (info HostInfo, err error) {
 				iface.Driver = driver
 			}
 
-			// Try to get version if possible (often not in sysfs easily without ethtool ioctl)
-			// We will leave version empty if not found, or implement ethtool ioctl later if critical.
-			// For now, we stick to sysfs for safety.
+			// Driver and firmware version (ethtool -i) and offloads (ethtool -k)
+			collectDriverInfo(&iface)
 
-			// Offload (Check /sys/class/net/<iface>/features/...)
-			// This is complex to map exactly to TSO/GSO without ethtool, but we can try.
-			// Actually, ethtool is the standard way. Since we can't use external binaries,
-			// and implementing full ethtool netlink/ioctl is complex, we will try to read what we can.
-			// For now, we'll mark them as unknown or try to read /sys/class/net/<iface>/features/* if they exist (kernel dependent).
+			// Link speed and duplex (ethtool <iface>)
+			collectLinkSpeed(&iface)
+
+			// Ring sizes and per-queue counters (ethtool -g / -S)
+			collectQueueInfo(&iface)
 
 			info.Interfaces = append(info.Interfaces, iface)
 		}
 	}
 
+	// Routes of the main table, compared against a baseline
+	if routes, err := netlink.RouteList(nil, netlink.FAMILY_ALL); err == nil {
+		info.Routes = formatRoutes(routes, func(index int) string {
+			if link, err := netlink.LinkByIndex(index); err == nil {
+				return link.Attrs().Name
+			}
+			return strconv.Itoa(index)
+		})
+	}
+
 	return info, nil
 }
 
+// formatRoutes renders the main table routes like ip route does, e.g.
+// "default via 192.168.1.1 dev eth0", sorted and without duplicates
+func formatRoutes(routes []netlink.Route, linkName func(index int) string) []string {
+	var out []string
+	for _, r := range routes {
+		if r.Table != 0 && r.Table != syscall.RT_TABLE_MAIN {
+			continue
+		}
+		dst := "default"
+		if !isDefaultRoute(r) {
+			dst = r.Dst.String()
+		}
+		line := dst
+		if r.Gw != nil {
+			line += " via " + r.Gw.String()
+		}
+		if r.LinkIndex > 0 {
+			line += " dev " + linkName(r.LinkIndex)
+		}
+		out = append(out, line)
+	}
+	slices.Sort(out)
+	return slices.Compact(out)
+}
+
+// readCongestionControl reads the active and available TCP congestion control
+// algorithms from a procfs sysctl root (normally /proc/sys)
+func readCongestionControl(root string) (string, []string, error) {
+	current, err := ioutil.ReadFile(filepath.Join(root, "net/ipv4/tcp_congestion_control"))
+	if err != nil {
+		return "", nil, err
+	}
+
+	var available []string
+	if content, err := ioutil.ReadFile(filepath.Join(root, "net/ipv4/tcp_available_congestion_control")); err == nil {
+		available = strings.Fields(string(content))
+	}
+
+	return strings.TrimSpace(string(current)), available, nil
+}
+
+// outgoingIPv6Source returns the source address the kernel selects for global
+// IPv6 traffic. Connecting a UDP socket sends nothing.
+func outgoingIPv6Source() string {
+	conn, err := net.Dial("udp6", "[2001:4860:4860::8888]:53")
+	if err != nil {
+		return ""
+	}
+	defer conn.Close()
+	if addr, ok := conn.LocalAddr().(*net.UDPAddr); ok {
+		return addr.IP.String()
+	}
+	return ""
+}
+
+// readIPv6Privacy reads use_tempaddr for iface from a procfs sysctl root and
+// classifies source if it is one of the interface's addresses
+func readIPv6Privacy(root, iface string, addrs []IPv6Address, source string) *IPv6Privacy {
+	content, err := ioutil.ReadFile(filepath.Join(root, "net/ipv6/conf", iface, "use_tempaddr"))
+	if err != nil {
+		return nil
+	}
+	val, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil {
+		return nil
+	}
+
+	privacy := &IPv6Privacy{Mode: IPv6PrivacyDisabled}
+	if val >= 2 {
+		privacy.Mode = IPv6PrivacyPreferred
+	} else if val == 1 {
+		privacy.Mode = IPv6PrivacyEnabled
+	}
+	privacy.SourceKind = classifyIPv6Source(addrs, source)
+	if privacy.SourceKind != IPv6SourceNone {
+		privacy.Source = source
+	}
+	return privacy
+}
+
+// classifyIPv6Source reports whether source is a temporary or stable address
+// of the interface, IPv6SourceNone if it is not on the interface
+func classifyIPv6Source(addrs []IPv6Address, source string) IPv6SourceKind {
+	ip := net.ParseIP(source)
+	if ip == nil {
+		return IPv6SourceNone
+	}
+	for _, addr := range addrs {
+		if !ip.Equal(net.ParseIP(addr.Address)) {
+			continue
+		}
+		if addr.Temporary {
+			return IPv6SourceTemporary
+		}
+		return IPv6SourceStable
+	}
+	return IPv6SourceNone
+}
+
 func getDriverName(iface string) (string, error) {
 	path := fmt.Sprintf("/sys/class/net/%s/device/uevent", iface)
 	file, err := os.Open(path)
@@ -148,3 +277,142 @@ func getDriverName(iface string) (string, error) {
 	}
 	return "", fmt.Errorf("driver not found")
 }
+
+// readLinkState reads the operational state (up, down, lowerlayerdown,
+// dormant, unknown, ...) and the carrier change counter of an interface from
+// /sys/class/net/<iface>
+func readLinkState(sysRoot, iface string) (string, uint64) {
+	dir := filepath.Join(sysRoot, "class/net", iface)
+	state := ""
+	if b, err := os.ReadFile(filepath.Join(dir, "operstate")); err == nil {
+		state = strings.TrimSpace(string(b))
+	}
+	var changes uint64
+	if b, err := os.ReadFile(filepath.Join(dir, "carrier_changes")); err == nil {
+		changes, _ = strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+	}
+	return state, changes
+}
+
+// LinkState is the operational state of an interface, as in InterfaceInfo
+type LinkState struct {
+	OperState      string
+	CarrierChanges uint64
+	CarrierFlaps   uint64
+	IPv6           []IPv6Address
+	IPv6Privacy    *IPv6Privacy
+	IPv6Read       bool         // False if netlink failed, the last collection's addresses stand
+	Queues         []QueueStats // Per-queue packet counters, nil if they could not be read
+	// Link speed and duplex as in InterfaceInfo, renegotiated after a cable
+	// or port change
+	SpeedMbps        int
+	Duplex           string
+	MaxSpeedMbps     int
+	PeerMaxSpeedMbps int
+}
+
+// LinkStates re-reads only the operational state, the carrier counter, the
+// link speed, the IPv6 addresses and the per-queue packet counters of the
+// named interfaces,
+// a light refresh between two full collections. Flaps are counted since the
+// previous read by either; the address lifetimes count down and rotated
+// temporary addresses show up.
+func (c *SystemCollector) LinkStates(names []string) map[string]LinkState {
+	states := c.linkStates("/sys", names)
+	source := outgoingIPv6Source()
+	for name, state := range states {
+		state.Queues = readQueueCounters(name)
+		speed := InterfaceInfo{Name: name}
+		collectLinkSpeed(&speed)
+		state.SpeedMbps, state.Duplex = speed.SpeedMbps, speed.Duplex
+		state.MaxSpeedMbps, state.PeerMaxSpeedMbps = speed.MaxSpeedMbps, speed.PeerMaxSpeedMbps
+		states[name] = state
+		link, err := netlink.LinkByName(name)
+		if err != nil {
+			continue
+		}
+		addrs, err := netlink.AddrList(link, netlink.FAMILY_V6)
+		if err != nil {
+			continue
+		}
+		state.IPv6 = parseIPv6Addrs(addrs)
+		state.IPv6Privacy = readIPv6Privacy("/proc/sys", name, state.IPv6, source)
+		state.IPv6Read = true
+		states[name] = state
+	}
+	return states
+}
+
+func (c *SystemCollector) linkStates(sysRoot string, names []string) map[string]LinkState {
+	states := make(map[string]LinkState, len(names))
+	for _, name := range names {
+		state, changes := readLinkState(sysRoot, name)
+		if state == "" {
+			continue // Gone, or no sysfs; keep what the last collection saw
+		}
+		states[name] = LinkState{OperState: state, CarrierChanges: changes, CarrierFlaps: c.carrierFlaps(name, changes)}
+	}
+	return states
+}
+
+// carrierFlaps returns how often the carrier of iface changed since the
+// previous collection and remembers the counter for the next one. The first
+// sighting and a counter that went backwards (the device was recreated)
+// count as no change.
+func (c *SystemCollector) carrierFlaps(iface string, changes uint64) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	last, seen := c.carrierChanges[iface]
+	c.carrierChanges[iface] = changes
+	if !seen || changes < last {
+		return 0
+	}
+	return changes - last
+}
+
+// infiniteLifetime is the netlink value (0xFFFFFFFF) for a lifetime that never expires
+const infiniteLifetime = 0xFFFFFFFF
+
+func parseIPv6Addrs(addrs []netlink.Addr) []IPv6Address {
+	var result []IPv6Address
+	for _, addr := range addrs {
+		if addr.IPNet == nil || addr.IP.To4() != nil {
+			continue
+		}
+		ones, _ := addr.Mask.Size()
+		result = append(result, IPv6Address{
+			Address:      addr.IP.String(),
+			PrefixLen:    ones,
+			Scope:        classifyIPv6Scope(addr.IP),
+			Temporary:    addr.Flags&syscall.IFA_F_TEMPORARY != 0,
+			Deprecated:   addr.Flags&syscall.IFA_F_DEPRECATED != 0,
+			PreferredLft: addrLifetime(addr.PreferedLft),
+			ValidLft:     addrLifetime(addr.ValidLft),
+		})
+	}
+	return result
+}
+
+func classifyIPv6Scope(ip net.IP) IPv6Scope {
+	switch {
+	case ip.IsLoopback():
+		return IPv6ScopeLoopback
+	case ip.IsMulticast():
+		return IPv6ScopeMulticast
+	case ip.IsLinkLocalUnicast():
+		return IPv6ScopeLinkLocal
+	case len(ip) == net.IPv6len && ip[0] == 0xfe && ip[1]&0xc0 == 0xc0:
+		return IPv6ScopeSiteLocal
+	case ip.IsPrivate():
+		return IPv6ScopeUniqueLocal
+	default:
+		return IPv6ScopeGlobal
+	}
+}
+
+func addrLifetime(seconds int) time.Duration {
+	if uint32(seconds) == infiniteLifetime {
+		return IPv6LifetimeForever
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -1,7 +1,16 @@
 package collector
 
 import (
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"syscall"
 	"testing"
+	"time"
+
+	"github.com/vishvananda/netlink"
 )
 
 func TestSystemCollector_Collect(t *testing.T) {
@@ -22,3 +31,220 @@ func TestSystemCollector_Collect(t *testing.T) {
 	}
 	// Note: Interfaces might be empty in some container environments, so we don't strictly assert len > 0
 }
+
+func TestParseIPv6Addrs(t *testing.T) {
+	mustAddr := func(s string) netlink.Addr {
+		a, err := netlink.ParseAddr(s)
+		if err != nil {
+			t.Fatalf("ParseAddr(%q): %v", s, err)
+		}
+		return *a
+	}
+
+	linkLocal := mustAddr("fe80::1c2b:3ff:fe4d:5e6f/64")
+	forever := uint32(infiniteLifetime)
+	linkLocal.PreferedLft = int(int32(forever)) // As netlink stores it, -1 where int has 32 bits
+	linkLocal.ValidLft = int(int32(forever))
+
+	global := mustAddr("2001:db8::1/64")
+	global.PreferedLft = 3600
+	global.ValidLft = 86400
+
+	temporary := mustAddr("2001:db8::abcd/64")
+	temporary.Flags = syscall.IFA_F_TEMPORARY
+	temporary.PreferedLft = 600
+	temporary.ValidLft = 1200
+
+	ula := mustAddr("fd12:3456::1/64")
+	v4 := mustAddr("192.168.1.10/24")
+
+	addrs := parseIPv6Addrs([]netlink.Addr{linkLocal, global, temporary, ula, v4})
+	if len(addrs) != 4 {
+		t.Fatalf("expected 4 IPv6 addresses, got %d", len(addrs))
+	}
+
+	tests := []struct {
+		scope     IPv6Scope
+		temporary bool
+		preferred time.Duration
+		valid     time.Duration
+	}{
+		{IPv6ScopeLinkLocal, false, IPv6LifetimeForever, IPv6LifetimeForever},
+		{IPv6ScopeGlobal, false, time.Hour, 24 * time.Hour},
+		{IPv6ScopeGlobal, true, 10 * time.Minute, 20 * time.Minute},
+		{IPv6ScopeUniqueLocal, false, 0, 0},
+	}
+	for i, tt := range tests {
+		got := addrs[i]
+		if got.Scope != tt.scope {
+			t.Errorf("%s: scope = %s, want %s", got.Address, got.Scope, tt.scope)
+		}
+		if got.Temporary != tt.temporary {
+			t.Errorf("%s: temporary = %v, want %v", got.Address, got.Temporary, tt.temporary)
+		}
+		if got.PreferredLft != tt.preferred || got.ValidLft != tt.valid {
+			t.Errorf("%s: lifetimes = %s/%s, want %s/%s", got.Address, got.PreferredLft, got.ValidLft, tt.preferred, tt.valid)
+		}
+	}
+	if addrs[0].PrefixLen != 64 {
+		t.Errorf("prefix length = %d, want 64", addrs[0].PrefixLen)
+	}
+}
+
+func TestReadCongestionControl(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "net/ipv4")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "tcp_congestion_control"), []byte("cubic\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "tcp_available_congestion_control"), []byte("reno cubic bbr\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	current, available, err := readCongestionControl(root)
+	if err != nil {
+		t.Fatalf("readCongestionControl() error = %v", err)
+	}
+	if current != "cubic" {
+		t.Errorf("current = %q, want cubic", current)
+	}
+	if len(available) != 3 {
+		t.Errorf("available = %v, want 3 entries", available)
+	}
+
+	info := HostInfo{TCPCongestion: current, TCPCongestionAvail: available}
+	if !info.BBRAvailable() {
+		t.Error("expected BBR to be available")
+	}
+	if (HostInfo{TCPCongestionAvail: []string{"reno", "cubic"}}).BBRAvailable() {
+		t.Error("expected BBR to be unavailable")
+	}
+
+	if _, _, err := readCongestionControl(t.TempDir()); err == nil {
+		t.Error("expected error for missing sysctl files")
+	}
+}
+
+func TestReadIPv6Privacy(t *testing.T) {
+	root := t.TempDir()
+	for iface, val := range map[string]string{"eth0": "2\n", "wlan0": "0\n", "wg0": "-1\n"} {
+		dir := filepath.Join(root, "net/ipv6/conf", iface)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "use_tempaddr"), []byte(val), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	addrs := []IPv6Address{
+		{Address: "fe80::1", Scope: IPv6ScopeLinkLocal},
+		{Address: "2001:db8::1", Scope: IPv6ScopeGlobal},
+		{Address: "2001:db8::abcd", Scope: IPv6ScopeGlobal, Temporary: true},
+	}
+
+	tests := []struct {
+		iface  string
+		source string
+		mode   IPv6PrivacyMode
+		kind   IPv6SourceKind
+	}{
+		{"eth0", "2001:db8::abcd", IPv6PrivacyPreferred, IPv6SourceTemporary},
+		{"wlan0", "2001:db8::1", IPv6PrivacyDisabled, IPv6SourceStable},
+		{"wg0", "2001:db8:ffff::1", IPv6PrivacyDisabled, IPv6SourceNone},
+		{"eth0", "", IPv6PrivacyPreferred, IPv6SourceNone},
+	}
+	for _, tt := range tests {
+		p := readIPv6Privacy(root, tt.iface, addrs, tt.source)
+		if p == nil {
+			t.Fatalf("%s: no privacy status", tt.iface)
+		}
+		if p.Mode != tt.mode {
+			t.Errorf("%s: mode = %v, want %v", tt.iface, p.Mode, tt.mode)
+		}
+		if p.SourceKind != tt.kind {
+			t.Errorf("%s/%s: source kind = %q, want %q", tt.iface, tt.source, p.SourceKind, tt.kind)
+		}
+		if tt.kind == IPv6SourceNone && p.Source != "" {
+			t.Errorf("%s: source = %q for an address not on the interface", tt.iface, p.Source)
+		}
+	}
+
+	if p := readIPv6Privacy(root, "missing0", addrs, ""); p != nil {
+		t.Errorf("expected nil for an interface without IPv6 sysctls, got %+v", p)
+	}
+}
+
+func TestReadLinkState(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "class/net/eth0")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, "operstate"), []byte("lowerlayerdown\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "carrier_changes"), []byte("7\n"), 0o644)
+
+	if state, changes := readLinkState(root, "eth0"); state != "lowerlayerdown" || changes != 7 {
+		t.Errorf("readLinkState(eth0) = %q, %d, want lowerlayerdown, 7", state, changes)
+	}
+	if state, changes := readLinkState(root, "wlan0"); state != "" || changes != 0 {
+		t.Errorf("readLinkState(wlan0) = %q, %d, want nothing", state, changes)
+	}
+}
+
+func TestSystemCollector_CarrierFlaps(t *testing.T) {
+	c := NewSystemCollector()
+	for i, tt := range []struct {
+		changes, flaps uint64
+	}{
+		{4, 0}, // First sighting, the count since boot is no flap
+		{4, 0},
+		{7, 3},
+		{1, 0}, // Device recreated, the counter restarted
+		{2, 1},
+	} {
+		if got := c.carrierFlaps("eth0", tt.changes); got != tt.flaps {
+			t.Errorf("collection %d: carrierFlaps(%d) = %d, want %d", i, tt.changes, got, tt.flaps)
+		}
+	}
+}
+
+func TestSystemCollector_LinkStates(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "class/net/eth0")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	c := NewSystemCollector()
+	c.carrierFlaps("eth0", 4) // Seen by the full collection
+
+	os.WriteFile(filepath.Join(dir, "operstate"), []byte("down\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "carrier_changes"), []byte("6\n"), 0o644)
+	states := c.linkStates(root, []string{"eth0", "wlan0"})
+	if got, want := states["eth0"], (LinkState{OperState: "down", CarrierChanges: 6, CarrierFlaps: 2}); !reflect.DeepEqual(got, want) {
+		t.Errorf("eth0 = %+v, want %+v", got, want)
+	}
+	if _, ok := states["wlan0"]; ok {
+		t.Error("an interface without sysfs entries should be left out")
+	}
+}
+
+func TestFormatRoutes(t *testing.T) {
+	_, lan, _ := net.ParseCIDR("192.168.1.0/24")
+	_, vpn, _ := net.ParseCIDR("10.8.0.0/24")
+	routes := []netlink.Route{
+		{Dst: lan, LinkIndex: 2, Table: syscall.RT_TABLE_MAIN},
+		{Gw: net.ParseIP("192.168.1.1"), LinkIndex: 2, Table: syscall.RT_TABLE_MAIN},
+		{Dst: vpn, LinkIndex: 3, Table: 100}, // Policy routing table, not main
+		{Dst: lan, LinkIndex: 2, Table: syscall.RT_TABLE_MAIN},
+	}
+	names := map[int]string{2: "eth0", 3: "tun0"}
+	got := formatRoutes(routes, func(i int) string { return names[i] })
+	want := []string{"192.168.1.0/24 dev eth0", "default via 192.168.1.1 dev eth0"}
+	if !slices.Equal(got, want) {
+		t.Errorf("formatRoutes() = %q, want %q", got, want)
+	}
+}
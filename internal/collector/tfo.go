@@ -0,0 +1,172 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	tcpFastOpenConnect = 30   // TCP_FASTOPEN_CONNECT, Linux 4.11+
+	tcpiOptSynData     = 0x20 // TCPI_OPT_SYN_DATA: data in our SYN was acknowledged
+	tfoClientEnable    = 0x1  // net.ipv4.tcp_fastopen bits
+	tfoServerEnable    = 0x2
+)
+
+// TFOSysctl is the kernel's net.ipv4.tcp_fastopen setting
+type TFOSysctl struct {
+	Value  int
+	Client bool // Outgoing connections may send data in the SYN
+	Server bool // Listeners may accept data in the SYN
+}
+
+// TFOResult tells whether a target accepted data in our SYN. The first
+// connection only fetches a cookie, so Accepted needs the second one to
+// carry data that the server acknowledged; a middlebox stripping the option
+// or a server without TFO leaves it false.
+type TFOResult struct {
+	Target   string // host:port
+	Accepted bool
+	Error    error
+}
+
+// TFOReport is the kernel setting and the probe of every target
+type TFOReport struct {
+	Kernel      TFOSysctl
+	KernelError error
+	Results     []TFOResult
+}
+
+type TFOCollector struct {
+	Targets  []string // host:port
+	Timeout  time.Duration
+	ProcRoot string  // /proc, replaceable in tests
+	Payload  []byte  // Sent in the SYN, answered by HTTP servers
+	Budget   *Budget // Shared probe budget, targets over it are skipped
+}
+
+func NewTFOCollector(targets []string) *TFOCollector {
+	return &TFOCollector{
+		Targets:  targets,
+		Timeout:  3 * time.Second,
+		ProcRoot: "/proc",
+		Payload:  []byte("HEAD / HTTP/1.0\r\n\r\n"),
+	}
+}
+
+// Collect reads the sysctl and, if the client side is enabled, probes every
+// target concurrently
+func (c *TFOCollector) Collect(ctx context.Context) TFOReport {
+	var report TFOReport
+	report.Kernel, report.KernelError = readTFOSysctl(c.ProcRoot)
+	if report.KernelError != nil {
+		return report
+	}
+
+	report.Results = make([]TFOResult, len(c.Targets))
+	var wg sync.WaitGroup
+	for i, target := range c.Targets {
+		if !report.Kernel.Client {
+			report.Results[i] = TFOResult{Target: target,
+				Error: fmt.Errorf("client TFO disabled (net.ipv4.tcp_fastopen = %d)", report.Kernel.Value)}
+			continue
+		}
+		if !c.Budget.Allow(2, 2*tcpProbeBytes) { // Cookie request and use
+			report.Results[i] = TFOResult{Target: target, Error: ErrBudgetExceeded}
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			report.Results[i] = c.probe(ctx, target)
+		}()
+	}
+	wg.Wait()
+	return report
+}
+
+// readTFOSysctl parses <root>/sys/net/ipv4/tcp_fastopen
+func readTFOSysctl(root string) (TFOSysctl, error) {
+	data, err := os.ReadFile(filepath.Join(root, "sys/net/ipv4/tcp_fastopen"))
+	if err != nil {
+		return TFOSysctl{}, err
+	}
+	v, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return TFOSysctl{}, fmt.Errorf("parse tcp_fastopen: %w", err)
+	}
+	return TFOSysctl{Value: v, Client: v&tfoClientEnable != 0, Server: v&tfoServerEnable != 0}, nil
+}
+
+// probe connects twice: once to obtain a cookie, once to use it
+func (c *TFOCollector) probe(ctx context.Context, target string) TFOResult {
+	res := TFOResult{Target: target}
+	for range 2 {
+		accepted, err := c.connect(ctx, target)
+		if err != nil {
+			res.Error = err
+			return res
+		}
+		if accepted {
+			res.Accepted = true
+			return res
+		}
+	}
+	return res
+}
+
+// connect opens a TCP_FASTOPEN_CONNECT socket, so the SYN goes out with the
+// first write, and reports whether the data in it was acknowledged
+func (c *TFOCollector) connect(ctx context.Context, target string) (bool, error) {
+	dialer := net.Dialer{Timeout: c.Timeout, Control: tfoControl}
+	conn, err := dialer.DialContext(ctx, "tcp", target)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(c.Timeout))
+	if _, err := conn.Write(c.Payload); err != nil {
+		return false, err
+	}
+	// Any answer means the handshake completed
+	conn.Read(make([]byte, 1))
+
+	info, err := tcpInfoFor(conn.LocalAddr().(*net.TCPAddr), conn.RemoteAddr().(*net.TCPAddr))
+	if err != nil {
+		return false, fmt.Errorf("socket diag: %w", err)
+	}
+	return info.Options&tcpiOptSynData != 0, nil
+}
+
+// tfoControl enables TCP_FASTOPEN_CONNECT before the socket connects
+func tfoControl(network, address string, c syscall.RawConn) error {
+	var sockErr error
+	err := c.Control(func(fd uintptr) {
+		sockErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_TCP, tcpFastOpenConnect, 1)
+	})
+	if err != nil {
+		return err
+	}
+	if sockErr != nil {
+		return fmt.Errorf("TCP_FASTOPEN_CONNECT: %w", sockErr)
+	}
+	return nil
+}
+
+// TFOTargets turns connectivity targets into probe addresses, port 80
+// unless a target has its own
+func TFOTargets(targets []string) []string {
+	var out []string
+	for _, t := range targets {
+		out = append(out, tcpProbeAddress(t, 80))
+	}
+	return out
+}
@@ -0,0 +1,138 @@
+package collector
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func writeTFOSysctl(t *testing.T, value string) string {
+	t.Helper()
+	root := t.TempDir()
+	dir := filepath.Join(root, "sys/net/ipv4")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "tcp_fastopen"), []byte(value), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func TestReadTFOSysctl(t *testing.T) {
+	tests := []struct {
+		value          string
+		client, server bool
+	}{
+		{"0\n", false, false},
+		{"1\n", true, false},
+		{"3\n", true, true},
+		{"1027\n", true, true}, // 0x403, server without cookies
+	}
+	for _, tt := range tests {
+		got, err := readTFOSysctl(writeTFOSysctl(t, tt.value))
+		if err != nil {
+			t.Fatalf("readTFOSysctl(%q) error = %v", tt.value, err)
+		}
+		if got.Client != tt.client || got.Server != tt.server {
+			t.Errorf("readTFOSysctl(%q) = %+v, want client %v server %v", tt.value, got, tt.client, tt.server)
+		}
+	}
+	if _, err := readTFOSysctl(writeTFOSysctl(t, "on")); err == nil {
+		t.Error("expected a parse error")
+	}
+}
+
+func TestTFOCollector_ClientDisabled(t *testing.T) {
+	c := NewTFOCollector([]string{"192.0.2.1:80"})
+	c.ProcRoot = writeTFOSysctl(t, "0")
+	report := c.Collect(context.Background())
+	if len(report.Results) != 1 || report.Results[0].Error == nil {
+		t.Fatalf("disabled client TFO should be reported, got %+v", report)
+	}
+}
+
+const tcpFastOpen = 23 // TCP_FASTOPEN, the listener's TFO queue length
+
+// listenTFO starts a loopback listener with server-side TFO and answers
+// every connection
+func listenTFO(t *testing.T) net.Listener {
+	t.Helper()
+	lc := net.ListenConfig{Control: func(network, address string, c syscall.RawConn) error {
+		var serr error
+		err := c.Control(func(fd uintptr) {
+			serr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_TCP, tcpFastOpen, 16)
+		})
+		if err != nil {
+			return err
+		}
+		return serr
+	}}
+	ln, err := lc.Listen(context.Background(), "tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("TFO listener unsupported: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Read(make([]byte, 64))
+			conn.Write([]byte("HTTP/1.0 200 OK\r\n\r\n"))
+			conn.Close()
+		}
+	}()
+	return ln
+}
+
+func TestTFOCollector_Probe(t *testing.T) {
+	kernel, err := readTFOSysctl("/proc")
+	if err != nil || !kernel.Client || !kernel.Server {
+		t.Skipf("kernel TFO client and server not both enabled (%+v, %v)", kernel, err)
+	}
+	ln := listenTFO(t)
+
+	c := NewTFOCollector([]string{ln.Addr().String()})
+	c.Timeout = time.Second
+	report := c.Collect(context.Background())
+	res := report.Results[0]
+	if res.Error != nil {
+		t.Skipf("TFO probe unavailable: %v", res.Error)
+	}
+	if !res.Accepted {
+		t.Error("a TFO listener should accept data in the SYN on the second connection")
+	}
+}
+
+func TestTFOCollector_NoServerSupport(t *testing.T) {
+	kernel, err := readTFOSysctl("/proc")
+	if err != nil || !kernel.Client {
+		t.Skipf("kernel TFO client disabled (%+v, %v)", kernel, err)
+	}
+	ln := listenWithMSS(t, 0) // Plain listener, no TFO
+
+	c := NewTFOCollector([]string{ln.Addr().String()})
+	c.Timeout = 200 * time.Millisecond
+	res := c.Collect(context.Background()).Results[0]
+	if res.Error != nil {
+		t.Skipf("TFO probe unavailable: %v", res.Error)
+	}
+	if res.Accepted {
+		t.Error("a listener without TFO cannot accept data in the SYN")
+	}
+}
+
+func TestTFOTargets(t *testing.T) {
+	got := TFOTargets([]string{"bing.com", "example.com:8080", "2606:4700:4700::1111"})
+	want := []string{"bing.com:80", "example.com:8080", "[2606:4700:4700::1111]:80"}
+	if !slices.Equal(got, want) {
+		t.Errorf("TFOTargets() = %q, want %q", got, want)
+	}
+}
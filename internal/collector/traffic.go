@@ -1,74 +1,157 @@
 package collector
 
 import (
-	"fmt"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/shirou/gopsutil/v3/net"
+	"github.com/vishvananda/netlink"
+)
+
+// TrafficSource selects where interface counters are read from
+type TrafficSource string
+
+const (
+	TrafficSourceGopsutil TrafficSource = "gopsutil" // Portable, default
+	TrafficSourceProcfs   TrafficSource = "procfs"   // Direct /proc/net/dev parsing (Linux)
 )
 
 type TrafficCollector struct {
-	lastTime  time.Time
-	lastStats map[string]net.IOCountersStat
-	mu        sync.Mutex
+	Source       TrafficSource
+	lastTime     time.Time
+	lastStats    map[string]InterfaceTraffic
+	sessionStart time.Time
+	mu           sync.Mutex
 }
 
 func NewTrafficCollector() *TrafficCollector {
 	return &TrafficCollector{
-		lastStats: make(map[string]net.IOCountersStat),
+		Source:    TrafficSourceGopsutil,
+		lastStats: make(map[string]InterfaceTraffic),
 	}
 }
 
 func (c *TrafficCollector) Collect() (stats TrafficStats, err error) {
-	defer func() {
-		if r := recover(); r != nil {
-			err = fmt.Errorf("panic in TrafficCollector: %v", r)
-		}
-	}()
+	defer recoverPartial("TrafficCollector", &stats.Partial, &stats.Error, &err)
 
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	now := time.Now()
+	if c.sessionStart.IsZero() {
+		c.sessionStart = now
+	}
 	stats = TrafficStats{
-		Interfaces: make(map[string]InterfaceTraffic),
-		Timestamp:  now,
+		Interfaces:   make(map[string]InterfaceTraffic),
+		Timestamp:    now,
+		SessionStart: c.sessionStart,
 	}
 
-	counters, err := net.IOCounters(true) // per interface
+	counters, err := c.readCounters()
 	if err != nil {
 		return stats, err
 	}
 
-	for _, counter := range counters {
-		t := InterfaceTraffic{
-			RxBytes:    counter.BytesRecv,
-			TxBytes:    counter.BytesSent,
-			Drop:       counter.Dropin + counter.Dropout,
-			Errors:     counter.Errin + counter.Errout,
-			Collisions: 0, // gopsutil might not have collisions in all versions, check struct
+	linkUp := linkStates()
+
+	for name, t := range counters {
+		t.Up = linkUp[name]
+
+		// Interfaces count from when they are first seen
+		if last, ok := c.lastStats[name]; ok {
+			t.Session = updateSession(last, t)
 		}
 
 		// Calculate Rate
 		if !c.lastTime.IsZero() {
 			duration := now.Sub(c.lastTime).Seconds()
 			if duration > 0 {
-				if last, ok := c.lastStats[counter.Name]; ok {
-					if counter.BytesRecv >= last.BytesRecv {
-						t.RxRate = float64(counter.BytesRecv-last.BytesRecv) / duration
+				if last, ok := c.lastStats[name]; ok {
+					if t.RxBytes >= last.RxBytes {
+						t.RxRate = float64(t.RxBytes-last.RxBytes) / duration
 					}
-					if counter.BytesSent >= last.BytesSent {
-						t.TxRate = float64(counter.BytesSent-last.BytesSent) / duration
+					if t.TxBytes >= last.TxBytes {
+						t.TxRate = float64(t.TxBytes-last.TxBytes) / duration
 					}
 				}
 			}
 		}
 
-		stats.Interfaces[counter.Name] = t
-		c.lastStats[counter.Name] = counter
+		stats.Interfaces[name] = t
+		c.lastStats[name] = t
 	}
 
 	c.lastTime = now
 	return stats, nil
 }
+
+// updateSession adds the traffic between two samples to last's session
+// totals. Counters that went backwards were reset, so everything they show
+// now was transferred since the reset and is counted from zero.
+func updateSession(last, cur InterfaceTraffic) SessionTraffic {
+	s := last.Session
+	if cur.RxBytes < last.RxBytes || cur.TxBytes < last.TxBytes ||
+		cur.RxPackets < last.RxPackets || cur.TxPackets < last.TxPackets {
+		s.Resets++
+		last = InterfaceTraffic{}
+	}
+	s.RxBytes += cur.RxBytes - last.RxBytes
+	s.TxBytes += cur.TxBytes - last.TxBytes
+	s.RxPackets += cur.RxPackets - last.RxPackets
+	s.TxPackets += cur.TxPackets - last.TxPackets
+	return s
+}
+
+// readCounters returns raw per-interface counters from the configured source
+func (c *TrafficCollector) readCounters() (map[string]InterfaceTraffic, error) {
+	if c.Source == TrafficSourceProcfs {
+		return readProcNetDev(procNetDevPath)
+	}
+
+	counters, err := net.IOCounters(true) // per interface
+	if err != nil {
+		return nil, err
+	}
+
+	// gopsutil omits collisions and the detailed error counters; take them
+	// from /proc/net/dev when it is available (best effort, Linux only)
+	extra, _ := readProcNetDev(procNetDevPath)
+
+	result := make(map[string]InterfaceTraffic, len(counters))
+	for _, counter := range counters {
+		t := InterfaceTraffic{
+			RxBytes:   counter.BytesRecv,
+			TxBytes:   counter.BytesSent,
+			RxPackets: counter.PacketsRecv,
+			TxPackets: counter.PacketsSent,
+			Drop:      counter.Dropin + counter.Dropout,
+			Errors:    counter.Errin + counter.Errout,
+		}
+		if e, ok := extra[counter.Name]; ok {
+			t.Collisions = e.Collisions
+			t.FifoErrors = e.FifoErrors
+			t.FrameErrors = e.FrameErrors
+			t.CarrierErrors = e.CarrierErrors
+			t.Multicast = e.Multicast
+		}
+		result[counter.Name] = t
+	}
+	return result, nil
+}
+
+// linkStates maps interface names to whether the link is up
+func linkStates() map[string]bool {
+	states := make(map[string]bool)
+	links, err := netlink.LinkList()
+	if err != nil {
+		return states
+	}
+	for _, link := range links {
+		attrs := link.Attrs()
+		adminUp := attrs.RawFlags&syscall.IFF_UP != 0
+		operDown := attrs.OperState == netlink.OperDown || attrs.OperState == netlink.OperLowerLayerDown
+		states[attrs.Name] = adminUp && !operDown
+	}
+	return states
+}
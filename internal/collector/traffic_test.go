@@ -30,3 +30,28 @@ func TestTrafficCollector_Collect(t *testing.T) {
 		t.Error("Timestamp went backwards")
 	}
 }
+
+func TestUpdateSession(t *testing.T) {
+	samples := []InterfaceTraffic{
+		{RxBytes: 1000, TxBytes: 500, RxPackets: 10, TxPackets: 5}, // At startup
+		{RxBytes: 1600, TxBytes: 700, RxPackets: 16, TxPackets: 7},
+		{RxBytes: 300, TxBytes: 100, RxPackets: 3, TxPackets: 1}, // Counters reset
+		{RxBytes: 400, TxBytes: 150, RxPackets: 4, TxPackets: 2},
+	}
+	last := samples[0]
+	for _, cur := range samples[1:] {
+		cur.Session = updateSession(last, cur)
+		last = cur
+	}
+
+	want := SessionTraffic{
+		RxBytes:   600 + 300 + 100,
+		TxBytes:   200 + 100 + 50,
+		RxPackets: 6 + 3 + 1,
+		TxPackets: 2 + 1 + 1,
+		Resets:    1,
+	}
+	if last.Session != want {
+		t.Errorf("Session = %+v, want %+v", last.Session, want)
+	}
+}
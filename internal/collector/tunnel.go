@@ -2,7 +2,9 @@ package collector
 
 import (
 	"bufio"
+	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"io"
 	"net"
@@ -10,6 +12,7 @@ import (
 	"time"
 
 	"github.com/pion/dtls/v3"
+	dtlsnet "github.com/pion/dtls/v3/pkg/net"
 	"github.com/sysatom/lnd/internal/config"
 	"golang.org/x/net/proxy"
 )
@@ -22,10 +25,13 @@ type TunnelResult struct {
 	Status    string // "OK" or "Error"
 	Latency   time.Duration
 	Error     error
+	DSCPError error     // DSCP marking could not be applied, the probe was sent unmarked
+	CertInfo  *CertInfo `report:"detail"` // Server certificate of a TLS, QUIC or tls app handshake
 }
 
 type TunnelCollector struct {
 	Config []config.TunnelConfig
+	Budget *Budget // Shared probe budget, tunnels over it are skipped this cycle
 }
 
 func NewTunnelCollector(cfg []config.TunnelConfig) *TunnelCollector {
@@ -35,8 +41,20 @@ func NewTunnelCollector(cfg []config.TunnelConfig) *TunnelCollector {
 func (c *TunnelCollector) Collect() []TunnelResult {
 	var results []TunnelResult
 	for _, cfg := range c.Config {
+		if !c.Budget.Allow(1, tunnelProbeBytes) {
+			results = append(results, TunnelResult{
+				Name:      cfg.Name,
+				App:       cfg.App,
+				Transport: cfg.Transport,
+				Target:    cfg.Target,
+				Status:    "Skipped",
+				Error:     ErrBudgetExceeded,
+			})
+			continue
+		}
+		dialer := &markedDialer{DSCP: cfg.DSCP, Timeout: 5 * time.Second}
 		start := time.Now()
-		err := c.testTunnel(cfg)
+		cert, err := c.testTunnel(cfg, dialer)
 		latency := time.Since(start)
 
 		status := "OK"
@@ -52,45 +70,108 @@ func (c *TunnelCollector) Collect() []TunnelResult {
 			Status:    status,
 			Latency:   latency,
 			Error:     err,
+			DSCPError: dialer.MarkErr,
+			CertInfo:  cert,
 		})
 	}
 	return results
 }
 
-func (c *TunnelCollector) testTunnel(cfg config.TunnelConfig) error {
+// testTunnel returns the certificate of the innermost TLS handshake, nil
+// if the tunnel has none
+func (c *TunnelCollector) testTunnel(cfg config.TunnelConfig, dialer *markedDialer) (*CertInfo, error) {
 	// 1. Establish Transport (Protocol B)
-	conn, err := c.dialTransport(cfg)
+	conn, err := c.dialTransport(cfg, dialer)
 	if err != nil {
-		return fmt.Errorf("transport error: %w", err)
+		return nil, fmt.Errorf("transport error: %w", err)
 	}
 	defer conn.Close()
+	cert := connCertInfo(conn)
 
 	// 2. Perform Application Check (Protocol A)
-	return c.checkApplication(conn, cfg)
+	appCert, err := c.checkApplicationCert(conn, cfg)
+	if appCert != nil {
+		cert = appCert
+	}
+	return cert, err
+}
+
+// connCertInfo reads the server certificate of a TLS, DTLS or QUIC transport
+func connCertInfo(conn net.Conn) *CertInfo {
+	switch conn := conn.(type) {
+	case *tls.Conn:
+		return getCertInfo(conn.ConnectionState())
+	case *quicConn:
+		return getCertInfo(conn.conn.ConnectionState().TLS)
+	case *dtls.Conn:
+		// DTLS keeps the raw certificates, parse the leaf for getCertInfo
+		state, ok := conn.ConnectionState()
+		if !ok || len(state.PeerCertificates) == 0 {
+			return nil
+		}
+		leaf, err := x509.ParseCertificate(state.PeerCertificates[0])
+		if err != nil {
+			return nil
+		}
+		return getCertInfo(tls.ConnectionState{
+			PeerCertificates: []*x509.Certificate{leaf},
+			CipherSuite:      uint16(state.CipherSuiteID),
+		})
+	}
+	return nil
 }
 
-func (c *TunnelCollector) dialTransport(cfg config.TunnelConfig) (net.Conn, error) {
-	timeout := 5 * time.Second
+// dialTransport opens the transport through dialer, which applies the tunnel's DSCP marking
+func (c *TunnelCollector) dialTransport(cfg config.TunnelConfig, dialer *markedDialer) (net.Conn, error) {
+	timeout := dialer.Timeout
 
 	switch cfg.Transport {
 	case "tcp":
-		return net.DialTimeout("tcp", cfg.Target, timeout)
+		return dialer.Dial("tcp", cfg.Target)
 	case "udp":
-		return net.DialTimeout("udp", cfg.Target, timeout)
+		return dialer.Dial("udp", cfg.Target)
 	case "tls":
 		// TLS over TCP
-		dialer := &net.Dialer{Timeout: timeout}
-		return tls.DialWithDialer(dialer, "tcp", cfg.Target, &tls.Config{
-			InsecureSkipVerify: true, // For diagnostics, we might want to allow this or make it configurable
+		raw, err := dialer.Dial("tcp", cfg.Target)
+		if err != nil {
+			return nil, err
+		}
+		conn := tls.Client(raw, &tls.Config{
+			InsecureSkipVerify: cfg.SkipVerify(),
+			ServerName:         targetHost(cfg.Target),
 		})
+		conn.SetDeadline(time.Now().Add(timeout))
+		if err := conn.Handshake(); err != nil {
+			raw.Close()
+			return nil, err
+		}
+		conn.SetDeadline(time.Time{})
+		return conn, nil
 	case "dtls":
-		addr, err := net.ResolveUDPAddr("udp", cfg.Target)
+		raw, err := dialer.Dial("udp", cfg.Target)
 		if err != nil {
 			return nil, err
 		}
-		return dtls.Dial("udp", addr, &dtls.Config{
-			InsecureSkipVerify: true,
+		conn, err := dtls.Client(dtlsnet.PacketConnFromConn(raw), raw.RemoteAddr(), &dtls.Config{
+			InsecureSkipVerify: cfg.SkipVerify(),
+			ServerName:         targetHost(cfg.Target),
 		})
+		if err != nil {
+			raw.Close()
+			return nil, err
+		}
+		// Handshake now rather than on first use, so its certificate is known
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		if err := conn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return conn, nil
+	case "kcp":
+		return dialKCP(cfg, dialer)
+	case "quic":
+		return dialQUIC(cfg, dialer)
 	case "socks5":
 		if cfg.Proxy == "" {
 			return nil, fmt.Errorf("proxy address required for socks5")
@@ -102,17 +183,17 @@ func (c *TunnelCollector) dialTransport(cfg config.TunnelConfig) (net.Conn, erro
 				Password: cfg.Password,
 			}
 		}
-		dialer, err := proxy.SOCKS5("tcp", cfg.Proxy, auth, proxy.Direct)
+		socks, err := proxy.SOCKS5("tcp", cfg.Proxy, auth, dialer)
 		if err != nil {
 			return nil, err
 		}
-		return dialer.Dial("tcp", cfg.Target)
+		return socks.Dial("tcp", cfg.Target)
 	case "http":
 		if cfg.Proxy == "" {
 			return nil, fmt.Errorf("proxy address required for http proxy")
 		}
 		// Connect to Proxy
-		proxyConn, err := net.DialTimeout("tcp", cfg.Proxy, timeout)
+		proxyConn, err := dialer.Dial("tcp", cfg.Proxy)
 		if err != nil {
 			return nil, err
 		}
@@ -154,12 +235,18 @@ func (c *TunnelCollector) dialTransport(cfg config.TunnelConfig) (net.Conn, erro
 		}
 		return proxyConn, nil
 	default:
-		// TODO: Add support for kcp (requires github.com/xtaci/kcp-go)
 		return nil, fmt.Errorf("unsupported transport protocol: %s", cfg.Transport)
 	}
 }
 
 func (c *TunnelCollector) checkApplication(conn net.Conn, cfg config.TunnelConfig) error {
+	_, err := c.checkApplicationCert(conn, cfg)
+	return err
+}
+
+// checkApplicationCert is checkApplication returning the certificate of
+// the tls app's handshake
+func (c *TunnelCollector) checkApplicationCert(conn net.Conn, cfg config.TunnelConfig) (*CertInfo, error) {
 	// Set a deadline for the application check
 	conn.SetDeadline(time.Now().Add(5 * time.Second))
 
@@ -167,12 +254,12 @@ func (c *TunnelCollector) checkApplication(conn net.Conn, cfg config.TunnelConfi
 	case "tcp", "udp":
 		// Connection established is enough for basic check
 		// Optionally send a ping if needed, but for now just return nil
-		return nil
+		return nil, nil
 	case "http":
 		// Send a simple HTTP GET request
 		req, err := http.NewRequest("GET", "http://"+cfg.Target, nil)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		// Create a custom transport that uses our existing connection
@@ -181,20 +268,20 @@ func (c *TunnelCollector) checkApplication(conn net.Conn, cfg config.TunnelConfi
 
 		err = req.Write(conn)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		// Read response
 		resp, err := http.ReadResponse(bufio.NewReader(conn), req)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		defer resp.Body.Close()
 
 		if resp.StatusCode >= 200 && resp.StatusCode < 400 {
-			return nil
+			return nil, nil
 		}
-		return fmt.Errorf("http status: %s", resp.Status)
+		return nil, fmt.Errorf("http status: %s", resp.Status)
 
 	case "ws":
 		// Basic WebSocket Handshake
@@ -221,51 +308,59 @@ func (c *TunnelCollector) checkApplication(conn net.Conn, cfg config.TunnelConfi
 		// Read response
 		resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		if resp.StatusCode != 101 {
-			return fmt.Errorf("websocket upgrade failed: %s", resp.Status)
+			return nil, fmt.Errorf("websocket upgrade failed: %s", resp.Status)
 		}
-		return nil
+		return nil, nil
 
 	case "socks5":
 		// Simple SOCKS5 Handshake Check
 		// Client: Ver(5) | NMethods(1) | Methods(0x00)
 		_, err := conn.Write([]byte{0x05, 0x01, 0x00})
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		buf := make([]byte, 2)
 		_, err = io.ReadFull(conn, buf)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		if buf[0] != 0x05 {
-			return fmt.Errorf("invalid socks version: %x", buf[0])
+			return nil, fmt.Errorf("invalid socks version: %x", buf[0])
 		}
 		if buf[1] == 0xFF {
-			return fmt.Errorf("socks5 no acceptable methods")
+			return nil, fmt.Errorf("socks5 no acceptable methods")
 		}
-		return nil
+		return nil, nil
 
 	case "tls":
 		// Perform TLS Handshake
-		host := cfg.Target
-		if h, _, err := net.SplitHostPort(cfg.Target); err == nil {
-			host = h
-		}
-
 		tlsConn := tls.Client(conn, &tls.Config{
-			InsecureSkipVerify: true,
-			ServerName:         host,
+			InsecureSkipVerify: cfg.SkipVerify(),
+			ServerName:         targetHost(cfg.Target),
 		})
 		// We rely on the underlying connection deadline
-		return tlsConn.Handshake()
+		if err := tlsConn.Handshake(); err != nil {
+			return nil, err
+		}
+		return getCertInfo(tlsConn.ConnectionState()), nil
+
+	case "h3":
+		return nil, checkH3(conn, cfg)
 
 	default:
-		// TODO: Add support for kcp (requires github.com/xtaci/kcp-go)
-		return fmt.Errorf("unsupported application protocol: %s", cfg.App)
+		return nil, fmt.Errorf("unsupported application protocol: %s", cfg.App)
+	}
+}
+
+// targetHost strips the port from a host:port target
+func targetHost(target string) string {
+	if h, _, err := net.SplitHostPort(target); err == nil {
+		return h
 	}
+	return target
 }
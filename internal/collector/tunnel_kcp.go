@@ -0,0 +1,51 @@
+package collector
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"net"
+	"time"
+
+	dtlsnet "github.com/pion/dtls/v3/pkg/net"
+	"github.com/sysatom/lnd/internal/config"
+	"github.com/xtaci/kcp-go/v5"
+)
+
+// dialKCP opens a KCP session to the target over a UDP socket from dialer,
+// so the tunnel's DSCP marking applies. KCP has no handshake; the
+// application check is the first traffic the server sees. The session runs
+// in stream mode like kcptun, so byte stream checks such as HTTP work
+// unchanged. Without tuning the kcp-go defaults apply.
+func dialKCP(cfg config.TunnelConfig, dialer *markedDialer) (net.Conn, error) {
+	raw, err := dialer.Dial("udp", cfg.Target)
+	if err != nil {
+		return nil, err
+	}
+	var convid uint32
+	if err := binary.Read(rand.Reader, binary.LittleEndian, &convid); err != nil {
+		raw.Close()
+		return nil, err
+	}
+	sess, err := kcp.NewConn4(convid, raw.RemoteAddr(), nil, 0, 0, true, dtlsnet.PacketConnFromConn(raw))
+	if err != nil {
+		raw.Close()
+		return nil, err
+	}
+
+	sess.SetStreamMode(true)
+	sess.SetWriteDelay(false)
+	if t := cfg.KCP; t.NoDelay {
+		interval := 10 * time.Millisecond
+		if t.Interval > 0 {
+			interval = t.Interval
+		}
+		// Fast mode: fast resend after 2 duplicate ACKs, no congestion window
+		sess.SetNoDelay(1, int(interval/time.Millisecond), 2, 1)
+	} else if t.Interval > 0 {
+		sess.SetNoDelay(0, int(t.Interval/time.Millisecond), 0, 0)
+	}
+	if t := cfg.KCP; t.SendWindow > 0 || t.RecvWindow > 0 {
+		sess.SetWindowSize(t.SendWindow, t.RecvWindow)
+	}
+	return sess, nil
+}
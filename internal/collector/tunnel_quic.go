@@ -0,0 +1,128 @@
+package collector
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	dtlsnet "github.com/pion/dtls/v3/pkg/net"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+	"github.com/sysatom/lnd/internal/config"
+)
+
+// quicConn is a QUIC connection as a net.Conn. Reads and writes go to one
+// bidirectional stream opened on first use, so the byte stream checks work
+// over it; the h3 check talks HTTP/3 on the connection itself.
+type quicConn struct {
+	conn     *quic.Conn
+	raw      net.Conn // UDP socket underneath, closed with the connection
+	stream   *quic.Stream
+	deadline time.Time
+}
+
+// dialQUIC handshakes QUIC with ALPN h3 over a UDP socket from dialer, so
+// the tunnel's DSCP marking applies
+func dialQUIC(cfg config.TunnelConfig, dialer *markedDialer) (net.Conn, error) {
+	raw, err := dialer.Dial("udp", cfg.Target)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), dialer.Timeout)
+	defer cancel()
+	tlsConf := &tls.Config{
+		InsecureSkipVerify: cfg.SkipVerify(),
+		ServerName:         targetHost(cfg.Target),
+		NextProtos:         []string{http3.NextProtoH3},
+	}
+	conn, err := quic.Dial(ctx, dtlsnet.PacketConnFromConn(raw), raw.RemoteAddr(), tlsConf, &quic.Config{HandshakeIdleTimeout: dialer.Timeout})
+	if err != nil {
+		raw.Close()
+		return nil, err
+	}
+	return &quicConn{conn: conn, raw: raw}, nil
+}
+
+// openStream opens the stream behind Read and Write once
+func (c *quicConn) openStream() error {
+	if c.stream != nil {
+		return nil
+	}
+	ctx := context.Background()
+	if !c.deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, c.deadline)
+		defer cancel()
+	}
+	stream, err := c.conn.OpenStreamSync(ctx)
+	if err != nil {
+		return err
+	}
+	stream.SetDeadline(c.deadline)
+	c.stream = stream
+	return nil
+}
+
+func (c *quicConn) Read(b []byte) (int, error) {
+	if err := c.openStream(); err != nil {
+		return 0, err
+	}
+	return c.stream.Read(b)
+}
+
+func (c *quicConn) Write(b []byte) (int, error) {
+	if err := c.openStream(); err != nil {
+		return 0, err
+	}
+	return c.stream.Write(b)
+}
+
+func (c *quicConn) Close() error {
+	err := c.conn.CloseWithError(0, "")
+	c.raw.Close()
+	return err
+}
+
+func (c *quicConn) LocalAddr() net.Addr  { return c.conn.LocalAddr() }
+func (c *quicConn) RemoteAddr() net.Addr { return c.conn.RemoteAddr() }
+
+func (c *quicConn) SetDeadline(t time.Time) error {
+	c.deadline = t
+	if c.stream != nil {
+		return c.stream.SetDeadline(t)
+	}
+	return nil
+}
+
+func (c *quicConn) SetReadDeadline(t time.Time) error  { return c.SetDeadline(t) }
+func (c *quicConn) SetWriteDeadline(t time.Time) error { return c.SetDeadline(t) }
+
+// checkH3 sends a GET for the target over HTTP/3 on a quic transport
+func checkH3(conn net.Conn, cfg config.TunnelConfig) error {
+	qc, ok := conn.(*quicConn)
+	if !ok {
+		return fmt.Errorf("h3 needs the quic transport, not %s", cfg.Transport)
+	}
+	ctx := context.Background()
+	if !qc.deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, qc.deadline)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://"+cfg.Target+"/", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := (&http3.Transport{}).NewClientConn(qc.conn).RoundTrip(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 400 {
+		return nil
+	}
+	return fmt.Errorf("http status: %s", resp.Status)
+}
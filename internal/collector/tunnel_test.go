@@ -2,6 +2,7 @@ package collector
 
 import (
 	"bufio"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"net"
@@ -9,7 +10,11 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/pion/dtls/v3"
+	"github.com/pion/dtls/v3/pkg/crypto/selfsign"
+	"github.com/quic-go/quic-go/http3"
 	"github.com/sysatom/lnd/internal/config"
+	"github.com/xtaci/kcp-go/v5"
 )
 
 func TestTunnelCollector_HTTP_TCP(t *testing.T) {
@@ -40,6 +45,112 @@ func TestTunnelCollector_HTTP_TCP(t *testing.T) {
 	}
 }
 
+func TestTunnelCollector_HTTP_KCP(t *testing.T) {
+	l, err := kcp.ListenWithOptions("127.0.0.1:0", nil, 0, 0)
+	if err != nil {
+		t.Skipf("cannot listen for KCP: %v", err)
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})}
+	go srv.Serve(l)
+	defer srv.Close()
+
+	cfg := []config.TunnelConfig{
+		{
+			Name:      "Test KCP",
+			Target:    l.Addr().String(),
+			App:       "http",
+			Transport: "kcp",
+			KCP:       config.KCPConfig{NoDelay: true, SendWindow: 64, RecvWindow: 64},
+		},
+	}
+
+	c := NewTunnelCollector(cfg)
+	results := c.Collect()
+
+	if len(results) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(results))
+	}
+	if results[0].Status != "OK" {
+		t.Errorf("expected OK, got %s (err: %v)", results[0].Status, results[0].Error)
+	}
+}
+
+func TestTunnelCollector_H3_QUIC(t *testing.T) {
+	tlsSrv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer tlsSrv.Close()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen: %v", err)
+	}
+	h3 := &http3.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}),
+		TLSConfig: http3.ConfigureTLSConfig(&tls.Config{Certificates: tlsSrv.TLS.Certificates}),
+	}
+	go h3.Serve(pc)
+	defer h3.Close()
+
+	verify := false
+	cfg := []config.TunnelConfig{
+		{Name: "Test H3", Target: pc.LocalAddr().String(), App: "h3", Transport: "quic"},
+		{Name: "Verified", Target: pc.LocalAddr().String(), App: "tcp", Transport: "quic", InsecureSkipVerify: &verify},
+	}
+
+	results := NewTunnelCollector(cfg).Collect()
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	if results[0].Status != "OK" {
+		t.Errorf("expected OK, got %s (err: %v)", results[0].Status, results[0].Error)
+	}
+	if results[0].CertInfo == nil || results[0].CertInfo.Issuer == "" {
+		t.Errorf("CertInfo = %+v, want the handshake certificate", results[0].CertInfo)
+	}
+	// The test certificate is self-signed, so verification fails
+	if results[1].Status != "Error" {
+		t.Errorf("verified tunnel: expected Error, got %s", results[1].Status)
+	}
+}
+
+func TestTunnelCollector_UDP_DTLS(t *testing.T) {
+	cert, err := selfsign.GenerateSelfSigned()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := dtls.Listen("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}, &dtls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Skipf("cannot listen: %v", err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			// Reading drives the server side of the handshake
+			go io.Copy(io.Discard, conn)
+		}
+	}()
+
+	cfg := []config.TunnelConfig{
+		{Name: "Test DTLS", Target: l.Addr().String(), App: "udp", Transport: "dtls"},
+	}
+	results := NewTunnelCollector(cfg).Collect()
+	if len(results) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(results))
+	}
+	if results[0].Status != "OK" {
+		t.Errorf("expected OK, got %s (err: %v)", results[0].Status, results[0].Error)
+	}
+	if c := results[0].CertInfo; c == nil || c.NotAfter.IsZero() {
+		t.Errorf("CertInfo = %+v, want the DTLS peer certificate", c)
+	}
+}
+
 func TestTunnelCollector_WS_TCP(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Header.Get("Upgrade") != "websocket" {
@@ -350,4 +461,7 @@ func TestTunnelCollector_TLS_TCP(t *testing.T) {
 	if results[0].Status != "OK" {
 		t.Errorf("expected OK, got %s (err: %v)", results[0].Status, results[0].Error)
 	}
+	if results[0].CertInfo == nil {
+		t.Error("expected the tls app's certificate in CertInfo")
+	}
 }
@@ -1,32 +1,262 @@
 package config
 
 import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
 type DNSServerConfig struct {
-	Name    string `yaml:"name"`
-	Address string `yaml:"address"`
-	Proto   string `yaml:"proto"`
+	Name       string `yaml:"name,omitempty"`
+	Address    string `yaml:"address,omitempty"`
+	Proto      string `yaml:"proto,omitempty"`
+	SourcePort int    `yaml:"source_port,omitempty"` // Bind queries to this local port (UDP/TCP only)
+	H3Fallback bool   `yaml:"h3_fallback,omitempty"` // Retry DoH3 over HTTP/2 when QUIC is blocked
+	Family     string `yaml:"family,omitempty"`      // auto (default), ipv4 or ipv6
 }
 
 type TunnelConfig struct {
-	Name      string `yaml:"name"`
-	Target    string `yaml:"target"`
-	App       string `yaml:"app"`       // http, ws, tcp, udp, socks5, tls
-	Transport string `yaml:"transport"` // tcp, udp, tls, dtls, socks5, http
-	Proxy     string `yaml:"proxy"`     // Address for socks5/http proxy
-	User      string `yaml:"user"`      // Proxy user
-	Password  string `yaml:"password"`  // Proxy password
+	Name      string    `yaml:"name,omitempty"`
+	Target    string    `yaml:"target,omitempty"`
+	App       string    `yaml:"app,omitempty"`       // http, ws, tcp, udp, socks5, tls, h3 (quic transport only)
+	Transport string    `yaml:"transport,omitempty"` // tcp, udp, tls, dtls, kcp, quic, socks5, http
+	Proxy     string    `yaml:"proxy,omitempty"`     // Address for socks5/http proxy
+	User      string    `yaml:"user,omitempty"`      // Proxy user
+	Password  string    `yaml:"password,omitempty"`  // Proxy password
+	DSCP      int       `yaml:"dscp,omitempty"`      // DSCP code point (0-63) for outgoing packets, 0 = unmarked
+	KCP       KCPConfig `yaml:"kcp,omitempty"`       // Tuning for the kcp transport
+
+	// InsecureSkipVerify accepts any server certificate in TLS, DTLS and
+	// QUIC handshakes, nil = true as diagnostics often target self-signed
+	// endpoints
+	InsecureSkipVerify *bool `yaml:"insecure_skip_verify,omitempty"`
+}
+
+// SkipVerify reports whether server certificates go unchecked
+func (t TunnelConfig) SkipVerify() bool {
+	return t.InsecureSkipVerify == nil || *t.InsecureSkipVerify
+}
+
+// KCPConfig tunes a kcp transport. Zero keeps the kcp-go default.
+type KCPConfig struct {
+	NoDelay    bool          `yaml:"nodelay,omitempty"`     // Fast mode: no delayed ACKs, fast resend, no congestion control
+	Interval   time.Duration `yaml:"interval,omitempty"`    // Internal update interval, 10ms in fast mode
+	SendWindow int           `yaml:"send_window,omitempty"` // Send window in packets
+	RecvWindow int           `yaml:"recv_window,omitempty"` // Receive window in packets
+}
+
+// STUNConfig tunes the STUN binding request used for NAT detection
+type STUNConfig struct {
+	Retries int           `yaml:"retries,omitempty"` // Retransmissions after the first request
+	RTO     time.Duration `yaml:"rto,omitempty"`     // Initial retransmission timeout, doubled per attempt
+	Timeout time.Duration `yaml:"timeout,omitempty"` // Upper bound for one probe
+}
+
+// PingConfig tunes the connectivity pings
+type PingConfig struct {
+	DSCP       int           `yaml:"dscp,omitempty"`        // DSCP code point (0-63) for outgoing pings, 0 = unmarked
+	Size       int           `yaml:"size,omitempty"`        // ICMP payload bytes, 0 = 24
+	Pattern    string        `yaml:"pattern,omitempty"`     // Payload fill: zeros, random or incrementing, empty = default
+	Count      int           `yaml:"count,omitempty"`       // Echo requests per target, 0 = 3
+	Interval   time.Duration `yaml:"interval,omitempty"`    // Between echo requests, 0 = 1s
+	Timeout    time.Duration `yaml:"timeout,omitempty"`     // Wait for each reply, 0 = 2s
+	Privileged *bool         `yaml:"privileged,omitempty"`  // Raw ICMP sockets (default); false uses unprivileged ping sockets
+	ReverseDNS *bool         `yaml:"reverse_dns,omitempty"` // Name IP targets by their PTR records (default); false skips the lookups
+}
+
+// MaxPingDuration bounds one target's pings, count-1 intervals plus the
+// reply timeout, so a refresh cannot hold up the UI for long
+const MaxPingDuration = 30 * time.Second
+
+// Ping defaults for zero values, also the collector's
+const (
+	DefaultPingCount    = 3
+	DefaultPingInterval = time.Second
+	DefaultPingTimeout  = 2 * time.Second
+)
+
+// Duration is how long one target's pings may take with the defaults filled in
+func (p PingConfig) Duration() time.Duration {
+	count := cmp.Or(p.Count, DefaultPingCount)
+	interval, timeout := cmp.Or(p.Interval, DefaultPingInterval), cmp.Or(p.Timeout, DefaultPingTimeout)
+	return time.Duration(count-1)*interval + timeout
+}
+
+// PingPatterns are the payload fills accepted under ping.pattern
+var PingPatterns = []string{"zeros", "random", "incrementing"}
+
+// Themes are the color themes accepted under theme:, the color-blind ones
+// mark status with symbols as well. The palettes live in ui, whose tests
+// check that ui.ThemeNames matches this list.
+var Themes = []string{"default", "deuteranopia", "protanopia", "tritanopia"}
+
+// ConnectivityDNSConfig picks the resolvers timed by the connectivity DNS
+// check. An empty server keeps the default.
+type ConnectivityDNSConfig struct {
+	Domain string          `yaml:"domain,omitempty"` // Name looked up, default google.com
+	Local  DNSServerConfig `yaml:"local,omitempty"`  // Default: the system resolver
+	Public DNSServerConfig `yaml:"public,omitempty"` // Default: 1.1.1.1 over UDP
+}
+
+// ConnectivityConfig is the short form of the connectivity DNS check
+// settings. dns_probe_domain is an alias of dns_check.domain and
+// public_resolver of dns_check.public; Validate rejects a config that
+// sets both keys of a pair.
+type ConnectivityConfig struct {
+	DNSProbeDomain string `yaml:"dns_probe_domain,omitempty"` // Name the resolvers are timed with, default google.com
+	PublicResolver string `yaml:"public_resolver,omitempty"`  // host[:port] over UDP or an https:// DoH URL
+}
+
+// ParseResolver reads a resolver given as one string, as in
+// connectivity.public_resolver: an https:// URL is DoH, anything else
+// host[:port] over UDP with port 53 by default
+func ParseResolver(spec string) (DNSServerConfig, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return DNSServerConfig{}, errors.New("address is required")
+	}
+	s := DNSServerConfig{Name: spec, Address: spec, Proto: "UDP"}
+	if strings.HasPrefix(spec, "https://") {
+		s.Proto = "DoH"
+	} else if _, _, err := net.SplitHostPort(spec); err != nil {
+		s.Address = net.JoinHostPort(strings.Trim(spec, "[]"), "53")
+	}
+	if err := validateDNSServer(s); err != nil {
+		return DNSServerConfig{}, err
+	}
+	return s, nil
+}
+
+// DNSQueryConfig sets the EDNS0 options of DNS tab queries
+type DNSQueryConfig struct {
+	UDPSize  int  `yaml:"udp_size,omitempty"` // Advertised UDP payload size, 512-65535, default 4096
+	DNSSEC   bool `yaml:"dnssec,omitempty"`   // Set the DO bit by default, Alt+d toggles it
+	Wildcard bool `yaml:"wildcard,omitempty"` // Probe a random sibling name for wildcards by default, Alt+w toggles it
+}
+
+// PublicIPConfig tunes the public IP lookup
+type PublicIPConfig struct {
+	GeoURL string `yaml:"geo_url,omitempty"` // ipinfo.io style JSON endpoint, %s is the IP; default https://ipinfo.io/%s/json
+}
+
+// BufferbloatConfig points the bufferbloat test at other endpoints
+type BufferbloatConfig struct {
+	Target      string        `yaml:"target,omitempty"`       // Host pinged during the test
+	DownloadURL string        `yaml:"download_url,omitempty"` // Large file fetched to load the downlink
+	UploadURL   string        `yaml:"upload_url,omitempty"`   // Endpoint accepting POSTs to load the uplink
+	Duration    time.Duration `yaml:"duration,omitempty"`     // Length of the load phase
+	MaxMB       int64         `yaml:"max_mb,omitempty"`       // Data volume cap for the load phase
+}
+
+// BudgetConfig caps the probe traffic of all collectors together, for
+// metered or very constrained links. Zero leaves a dimension unlimited.
+type BudgetConfig struct {
+	ProbesPerMinute int   `yaml:"probes_per_minute,omitempty"` // Pings, queries and handshakes
+	KBPerMinute     int64 `yaml:"kb_per_minute,omitempty"`     // Estimated bytes sent and received
+}
+
+// BaselineConfig sets how far live values may drift from a saved baseline
+// before they are flagged. Zero keeps the default.
+type BaselineConfig struct {
+	RTT            time.Duration `yaml:"rtt,omitempty"`             // Absolute RTT increase, default 20ms
+	RTTPercent     float64       `yaml:"rtt_percent,omitempty"`     // Relative RTT increase, default 50
+	LossPercent    float64       `yaml:"loss_percent,omitempty"`    // Packet loss increase in points, default 5
+	RetransPercent float64       `yaml:"retrans_percent,omitempty"` // Retransmission rate increase in points, default 1
+}
+
+// MaxScanPorts caps the port list, the quick scan is never a full range scan
+const MaxScanPorts = 64
+
+// PortScanConfig sets the ports of the quick port scan
+type PortScanConfig struct {
+	Ports   []int `yaml:"ports,omitempty"`   // Replaces the common service ports, at most MaxScanPorts
+	Banners bool  `yaml:"banners,omitempty"` // Read what open ports say, e.g. the SSH version or HTTP server
+}
+
+// SpeedTestServerConfig is an endpoint pair for the speed test
+type SpeedTestServerConfig struct {
+	Name        string `yaml:"name,omitempty"`
+	DownloadURL string `yaml:"download_url,omitempty"` // Payload of known size, fetched repeatedly
+	UploadURL   string `yaml:"upload_url,omitempty"`   // Accepts POSTs, empty skips the upload
+}
+
+// PowerSaveConfig slows the refresh on battery and while no key was
+// pressed for a while. Zero keeps the default.
+type PowerSaveConfig struct {
+	Disabled  bool          `yaml:"disabled,omitempty"`   // Always refresh at full speed
+	Battery   int           `yaml:"battery,omitempty"`    // Interval multiplier on battery, default 3
+	Idle      int           `yaml:"idle,omitempty"`       // Interval multiplier when idle, default 5
+	IdleAfter time.Duration `yaml:"idle_after,omitempty"` // Time without input until idle, default 5m
+}
+
+// Collectors that can be turned off under collectors:, e.g. no STUN on
+// restricted networks or no public IP lookup for privacy
+const (
+	CollectorTraffic  = "traffic"
+	CollectorKernel   = "kernel"
+	CollectorSTUN     = "stun"
+	CollectorPublicIP = "public_ip"
+	CollectorDHCP     = "dhcp"
+	CollectorDNS      = "dns"
+	CollectorTunnels  = "tunnels"
+)
+
+// CollectorNames lists the collectors accepted under collectors:
+var CollectorNames = []string{
+	CollectorTraffic, CollectorKernel, CollectorSTUN, CollectorPublicIP,
+	CollectorDHCP, CollectorDNS, CollectorTunnels,
+}
+
+// ReportConfig controls the diagnostic bundle
+type ReportConfig struct {
+	RedactPublicIPs bool `yaml:"redact_public_ips,omitempty"` // Mask public IPv4/IPv6 addresses
+}
+
+// RegionConfig is a latency landmark for the region latency list
+type RegionConfig struct {
+	Name    string `yaml:"name,omitempty"`
+	Address string `yaml:"address,omitempty"` // host:port, dialed over TCP
 }
 
 type Config struct {
-	StunServers []string          `yaml:"stun_servers"`
-	DNSServers  []DNSServerConfig `yaml:"dns_servers"`
-	Tunnels     []TunnelConfig    `yaml:"tunnels"`
+	StunServers   []string                `yaml:"stun_servers,omitempty"`
+	DNSServers    []DNSServerConfig       `yaml:"dns_servers,omitempty"`
+	Tunnels       []TunnelConfig          `yaml:"tunnels,omitempty"`
+	Ping          PingConfig              `yaml:"ping,omitempty"`
+	DNSCheck      ConnectivityDNSConfig   `yaml:"dns_check,omitempty"`
+	Connectivity  ConnectivityConfig      `yaml:"connectivity,omitempty"`
+	DNSQuery      DNSQueryConfig          `yaml:"dns_query,omitempty"`
+	PublicIP      PublicIPConfig          `yaml:"public_ip,omitempty"`
+	STUN          STUNConfig              `yaml:"stun,omitempty"`
+	Bufferbloat   BufferbloatConfig       `yaml:"bufferbloat,omitempty"`
+	Budget        BudgetConfig            `yaml:"budget,omitempty"`
+	Baseline      BaselineConfig          `yaml:"baseline,omitempty"`
+	PortScan      PortScanConfig          `yaml:"port_scan,omitempty"`
+	PowerSave     PowerSaveConfig         `yaml:"power_save,omitempty"`
+	SpeedTest     []SpeedTestServerConfig `yaml:"speedtest_servers,omitempty"` // Replaces the built-in Cloudflare endpoints
+	Collectors    map[string]bool         `yaml:"collectors,omitempty"`        // false turns a collector off, all are on by default
+	Report        ReportConfig            `yaml:"report,omitempty"`
+	Targets       []string                `yaml:"targets,omitempty"`        // Connectivity targets, replaces the built-in list
+	TargetsFile   string                  `yaml:"targets_file,omitempty"`   // Plain text/CSV file with one target per line
+	Regions       []RegionConfig          `yaml:"regions,omitempty"`        // Region latency endpoints, replaces the built-in list
+	TrafficSource string                  `yaml:"traffic_source,omitempty"` // gopsutil (default) or procfs
+	TrafficUnits  string                  `yaml:"traffic_units,omitempty"`  // bytes (default, KB/s) or bits (Mb/s)
+	Theme         string                  `yaml:"theme,omitempty"`          // Color theme, see Themes
+
+	Path        string   `yaml:"-"` // File the config was loaded from, used by Save
+	Warnings    []string `yaml:"-"` // Unknown keys found by Load, ignored
+	fileTargets []string // Targets merged in from targets files, not written back
 }
 
 func Default() *Config {
@@ -40,30 +270,186 @@ func Default() *Config {
 	}
 }
 
+// Load reads the config at path, ~/.lnd.yaml if empty. A missing file
+// gives the defaults. Settings that decode but cannot work are reported
+// together in a *ValidationError; keys the config does not know are
+// listed in Warnings and otherwise ignored.
 func Load(path string) (*Config, error) {
+	return load(path, false)
+}
+
+// LoadStrict is Load failing on unknown keys at any level instead of
+// warning about the top-level ones
+func LoadStrict(path string) (*Config, error) {
+	return load(path, true)
+}
+
+func load(path string, strict bool) (*Config, error) {
 	cfg := Default()
 
 	if path == "" {
 		// Try default locations
-		home, err := os.UserHomeDir()
-		if err != nil {
+		path = DefaultPath()
+		if path == "" {
 			return cfg, nil
 		}
-		path = filepath.Join(home, ".lnd.yaml")
 	}
+	cfg.Path = path
 
-	f, err := os.Open(path)
+	data, err := os.ReadFile(path)
 	if os.IsNotExist(err) {
 		return cfg, nil
 	}
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
 
-	if err := yaml.NewDecoder(f).Decode(cfg); err != nil {
+	// The node tree gives validation problems their line numbers
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(strict)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	cfg.Warnings = unknownKeys(&doc)
+
+	if err := cfg.Validate(); err != nil {
+		var verr *ValidationError
+		if errors.As(err, &verr) {
+			verr.locate(&doc)
+		}
 		return nil, err
 	}
 
+	if cfg.TargetsFile != "" {
+		targetsPath := cfg.TargetsFile
+		if !filepath.IsAbs(targetsPath) {
+			targetsPath = filepath.Join(filepath.Dir(path), targetsPath)
+		}
+		targets, err := LoadTargets(targetsPath)
+		if err != nil {
+			return nil, err
+		}
+		cfg.AddTargets(targets)
+	}
+
 	return cfg, nil
 }
+
+// isHTTPURL reports whether s is an absolute http or https URL
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// CollectorEnabled reports whether the named collector should run
+func (c *Config) CollectorEnabled(name string) bool {
+	if c == nil {
+		return true
+	}
+	enabled, ok := c.Collectors[name]
+	return !ok || enabled
+}
+
+// DefaultPath is ~/.lnd.yaml, empty if the home directory is unknown
+func DefaultPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".lnd.yaml")
+}
+
+// AddTargets merges targets read from a targets file. They are remembered
+// so that Save does not copy them into the YAML file.
+func (c *Config) AddTargets(targets []string) {
+	seen := make(map[string]bool)
+	for _, t := range c.Targets {
+		seen[t] = true
+	}
+	for _, t := range targets {
+		if !seen[t] {
+			seen[t] = true
+			c.fileTargets = append(c.fileTargets, t)
+		}
+	}
+	c.Targets = MergeTargets(c.Targets, targets)
+}
+
+// LoadTargets reads ping/DNS targets from a plain text or CSV file.
+// One target per line (first CSV column), blank lines and '#' comments are ignored.
+func LoadTargets(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var targets []string
+	scanner := bufio.NewScanner(f)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		if idx := strings.Index(line, "#"); idx != -1 {
+			line = line[:idx]
+		}
+		if idx := strings.Index(line, ","); idx != -1 {
+			line = line[:idx]
+		}
+		target := strings.TrimSpace(line)
+		if target == "" {
+			continue
+		}
+		if !validTarget(target) {
+			return nil, fmt.Errorf("%s:%d: invalid target %q", path, lineNo, target)
+		}
+		targets = append(targets, target)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return targets, nil
+}
+
+// MergeTargets appends extra targets to base, skipping duplicates
+func MergeTargets(base, extra []string) []string {
+	seen := make(map[string]bool)
+	var merged []string
+	for _, t := range append(append([]string{}, base...), extra...) {
+		if seen[t] {
+			continue
+		}
+		seen[t] = true
+		merged = append(merged, t)
+	}
+	return merged
+}
+
+// validTarget accepts an IP address or an RFC 1123 hostname
+func validTarget(s string) bool {
+	if net.ParseIP(s) != nil {
+		return true
+	}
+	if len(s) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(strings.TrimSuffix(s, "."), ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return false
+		}
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,204 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLoadTargets(t *testing.T) {
+	content := `# Office uplinks
+8.8.8.8
+  example.com   # trailing comment
+
+gateway.internal,Office gateway
+2606:4700:4700::1111
+8.8.8.8
+`
+	path := filepath.Join(t.TempDir(), "targets.txt")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	targets, err := LoadTargets(path)
+	if err != nil {
+		t.Fatalf("LoadTargets() error = %v", err)
+	}
+
+	want := []string{"8.8.8.8", "example.com", "gateway.internal", "2606:4700:4700::1111", "8.8.8.8"}
+	if !reflect.DeepEqual(targets, want) {
+		t.Errorf("LoadTargets() = %v, want %v", targets, want)
+	}
+
+	merged := MergeTargets([]string{"1.1.1.1", "8.8.8.8"}, targets)
+	wantMerged := []string{"1.1.1.1", "8.8.8.8", "example.com", "gateway.internal", "2606:4700:4700::1111"}
+	if !reflect.DeepEqual(merged, wantMerged) {
+		t.Errorf("MergeTargets() = %v, want %v", merged, wantMerged)
+	}
+}
+
+func TestLoadTargets_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "targets.txt")
+	if err := os.WriteFile(path, []byte("example.com\nnot a host\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadTargets(path); err == nil {
+		t.Error("expected error for invalid target")
+	}
+}
+
+func TestLoad_TargetsFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "targets.txt"), []byte("9.9.9.9\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfgPath := filepath.Join(dir, "lnd.yaml")
+	if err := os.WriteFile(cfgPath, []byte("targets:\n  - 1.1.1.1\ntargets_file: targets.txt\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(cfgPath)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	want := []string{"1.1.1.1", "9.9.9.9"}
+	if !reflect.DeepEqual(cfg.Targets, want) {
+		t.Errorf("Targets = %v, want %v", cfg.Targets, want)
+	}
+}
+
+func TestLoad_STUNOptions(t *testing.T) {
+	cfgPath := filepath.Join(t.TempDir(), "lnd.yaml")
+	if err := os.WriteFile(cfgPath, []byte("stun:\n  retries: 5\n  rto: 200ms\n  timeout: 4s\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(cfgPath)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	want := STUNConfig{Retries: 5, RTO: 200 * time.Millisecond, Timeout: 4 * time.Second}
+	if cfg.STUN != want {
+		t.Errorf("STUN = %+v, want %+v", cfg.STUN, want)
+	}
+}
+
+func TestLoad_Collectors(t *testing.T) {
+	cfgPath := filepath.Join(t.TempDir(), "lnd.yaml")
+	if err := os.WriteFile(cfgPath, []byte("collectors:\n  stun: false\n  public_ip: false\n  kernel: true\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(cfgPath)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.CollectorEnabled(CollectorSTUN) || cfg.CollectorEnabled(CollectorPublicIP) {
+		t.Error("stun and public_ip should be disabled")
+	}
+	if !cfg.CollectorEnabled(CollectorKernel) || !cfg.CollectorEnabled(CollectorDNS) {
+		t.Error("kernel and unlisted collectors should stay enabled")
+	}
+
+	if err := os.WriteFile(cfgPath, []byte("collectors:\n  stunn: false\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(cfgPath); err == nil {
+		t.Error("an unknown collector name should be rejected")
+	}
+}
+
+func TestLoad_PingPayload(t *testing.T) {
+	cfgPath := filepath.Join(t.TempDir(), "lnd.yaml")
+	if err := os.WriteFile(cfgPath, []byte("ping:\n  size: 1472\n  pattern: random\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(cfgPath)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Ping.Size != 1472 || cfg.Ping.Pattern != "random" {
+		t.Errorf("Ping = %+v", cfg.Ping)
+	}
+
+	if err := os.WriteFile(cfgPath, []byte("ping:\n  pattern: stripes\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(cfgPath); err == nil {
+		t.Error("an unknown ping pattern should be rejected")
+	}
+}
+
+func TestLoad_PingTiming(t *testing.T) {
+	cfgPath := filepath.Join(t.TempDir(), "lnd.yaml")
+	if err := os.WriteFile(cfgPath, []byte("ping:\n  count: 10\n  interval: 500ms\n  timeout: 1s\n  privileged: false\n  reverse_dns: false\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(cfgPath)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if p := cfg.Ping; p.Count != 10 || p.Interval != 500*time.Millisecond || p.Timeout != time.Second || p.Privileged == nil || *p.Privileged || p.ReverseDNS == nil || *p.ReverseDNS {
+		t.Errorf("Ping = %+v", p)
+	}
+	if d := cfg.Ping.Duration(); d != 5500*time.Millisecond {
+		t.Errorf("Duration() = %s, want 5.5s", d)
+	}
+	if d := (PingConfig{}).Duration(); d != 4*time.Second {
+		t.Errorf("default Duration() = %s, want 4s", d)
+	}
+
+	for _, bad := range []string{"ping:\n  count: 100\n", "ping:\n  interval: 40s\n", "ping:\n  count: -1\n"} {
+		if err := os.WriteFile(cfgPath, []byte(bad), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(cfgPath); err == nil {
+			t.Errorf("Load(%q) should fail", bad)
+		}
+	}
+}
+
+func TestLoad_DNSQuery(t *testing.T) {
+	cfgPath := filepath.Join(t.TempDir(), "lnd.yaml")
+	if err := os.WriteFile(cfgPath, []byte("dns_query:\n  udp_size: 1232\n  dnssec: true\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(cfgPath)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if q := cfg.DNSQuery; q.UDPSize != 1232 || !q.DNSSEC {
+		t.Errorf("DNSQuery = %+v", q)
+	}
+
+	if err := os.WriteFile(cfgPath, []byte("dns_query:\n  udp_size: 100\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(cfgPath); err == nil {
+		t.Error("Load() should reject a udp_size below 512")
+	}
+}
+
+func TestLoad_SpeedTestServers(t *testing.T) {
+	cfgPath := filepath.Join(t.TempDir(), "lnd.yaml")
+	yml := "speedtest_servers:\n  - name: Office\n    download_url: http://speed.lan/100mb.bin\n  - name: Cloudflare\n    download_url: https://speed.cloudflare.com/__down?bytes=25000000\n    upload_url: https://speed.cloudflare.com/__up\n"
+	if err := os.WriteFile(cfgPath, []byte(yml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(cfgPath)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(cfg.SpeedTest) != 2 || cfg.SpeedTest[0].UploadURL != "" || cfg.SpeedTest[1].UploadURL != "https://speed.cloudflare.com/__up" {
+		t.Errorf("SpeedTest = %+v", cfg.SpeedTest)
+	}
+
+	if err := os.WriteFile(cfgPath, []byte("speedtest_servers:\n  - name: Bad\n    download_url: speed.lan/file\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(cfgPath); err == nil {
+		t.Error("a download URL without scheme should be rejected")
+	}
+}
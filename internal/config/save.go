@@ -0,0 +1,226 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Save writes cfg to path. If the file already exists, its comments and key
+// order are kept: known keys get their new values in place, keys that are
+// no longer set are dropped and new keys are appended. Numbers and booleans
+// set to 0 or false stay in the file, although omitempty leaves them out.
+// Keys still at their Default value are only written when the file already
+// has them, so a default can change in a later version.
+func Save(cfg *Config, path string) error {
+	if path == "" {
+		return fmt.Errorf("no config file path")
+	}
+
+	out := *cfg
+	out.Targets = withoutTargets(cfg.Targets, cfg.fileTargets)
+
+	var fresh yaml.Node
+	if err := fresh.Encode(&out); err != nil {
+		return err
+	}
+
+	mode := os.FileMode(0o600) // May hold proxy passwords
+	var old yaml.Node
+	merge := false
+	if existing, err := os.ReadFile(path); err == nil {
+		merge = yaml.Unmarshal(existing, &old) == nil && len(old.Content) == 1 && old.Content[0].Kind == yaml.MappingNode
+		if st, err := os.Stat(path); err == nil {
+			mode = st.Mode().Perm()
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	var inFile *yaml.Node
+	if merge {
+		inFile = old.Content[0]
+	}
+	if err := dropDefaults(&fresh, inFile); err != nil {
+		return err
+	}
+	doc := &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{&fresh}}
+	if merge {
+		mergeMapping(old.Content[0], &fresh)
+		doc = &old
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+
+	// Write a sibling file and rename it, so a failed write keeps the old config
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".lnd-*.yaml")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// dropDefaults removes the top-level keys of fresh that hold their Default
+// value, unless the existing mapping old already lists them
+func dropDefaults(fresh, old *yaml.Node) error {
+	var defaults yaml.Node
+	if err := defaults.Encode(Default()); err != nil {
+		return err
+	}
+	inFile := make(map[string]bool)
+	if old != nil {
+		for i := 0; i+1 < len(old.Content); i += 2 {
+			inFile[old.Content[i].Value] = true
+		}
+	}
+	defaultValues := make(map[string]*yaml.Node)
+	for i := 0; i+1 < len(defaults.Content); i += 2 {
+		defaultValues[defaults.Content[i].Value] = defaults.Content[i+1]
+	}
+
+	var content []*yaml.Node
+	for i := 0; i+1 < len(fresh.Content); i += 2 {
+		key, value := fresh.Content[i], fresh.Content[i+1]
+		if def, ok := defaultValues[key.Value]; ok && !inFile[key.Value] && sameNode(value, def) {
+			continue
+		}
+		content = append(content, key, value)
+	}
+	fresh.Content = content
+	return nil
+}
+
+// sameNode reports whether two encoded values are equal, comments aside
+func sameNode(a, b *yaml.Node) bool {
+	if a.Kind != b.Kind || a.Value != b.Value || len(a.Content) != len(b.Content) {
+		return false
+	}
+	for i := range a.Content {
+		if !sameNode(a.Content[i], b.Content[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeMapping updates old in place with the keys and values of fresh,
+// carrying comments over from the replaced nodes
+func mergeMapping(old, fresh *yaml.Node) {
+	freshValues := make(map[string]*yaml.Node)
+	for i := 0; i+1 < len(fresh.Content); i += 2 {
+		freshValues[fresh.Content[i].Value] = fresh.Content[i+1]
+	}
+
+	var content []*yaml.Node
+	seen := make(map[string]bool)
+	for i := 0; i+1 < len(old.Content); i += 2 {
+		key, value := old.Content[i], old.Content[i+1]
+		newValue, ok := freshValues[key.Value]
+		if !ok {
+			if newValue, ok = zeroValue(value); !ok {
+				continue
+			}
+		}
+		seen[key.Value] = true
+		content = append(content, key, mergeNode(value, newValue))
+	}
+	for i := 0; i+1 < len(fresh.Content); i += 2 {
+		if !seen[fresh.Content[i].Value] {
+			content = append(content, fresh.Content[i], fresh.Content[i+1])
+		}
+	}
+	old.Content = content
+}
+
+// mergeNode returns the node to keep in place of old: old itself updated for
+// mappings and sequences (matched by position), otherwise fresh with old's comments
+func mergeNode(old, fresh *yaml.Node) *yaml.Node {
+	switch {
+	case old.Kind == yaml.MappingNode && fresh.Kind == yaml.MappingNode:
+		mergeMapping(old, fresh)
+		return old
+	case old.Kind == yaml.SequenceNode && fresh.Kind == yaml.SequenceNode:
+		items := fresh.Content
+		for i := range items {
+			if i < len(old.Content) {
+				items[i] = mergeNode(old.Content[i], items[i])
+			}
+		}
+		old.Content = items
+		return old
+	}
+	keepComments(old, fresh)
+	return fresh
+}
+
+// zeroValue returns the zero value for a number or boolean node, or a
+// mapping holding some. omitempty drops those from the fresh encoding, yet
+// dscp: 0 or banners: false is an explicit setting, not an unset key.
+func zeroValue(n *yaml.Node) (*yaml.Node, bool) {
+	if n.Kind == yaml.MappingNode {
+		mergeMapping(n, &yaml.Node{Kind: yaml.MappingNode})
+		return n, len(n.Content) > 0
+	}
+	if n.Kind != yaml.ScalarNode {
+		return nil, false
+	}
+	switch tag := n.ShortTag(); tag {
+	case "!!int", "!!float":
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: "0"}, true
+	case "!!bool":
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: "false"}, true
+	}
+	return nil, false
+}
+
+func keepComments(from, to *yaml.Node) {
+	if to.HeadComment == "" {
+		to.HeadComment = from.HeadComment
+	}
+	if to.LineComment == "" {
+		to.LineComment = from.LineComment
+	}
+	if to.FootComment == "" {
+		to.FootComment = from.FootComment
+	}
+}
+
+// withoutTargets returns targets minus the ones in skip
+func withoutTargets(targets, skip []string) []string {
+	if len(skip) == 0 {
+		return targets
+	}
+	drop := make(map[string]bool)
+	for _, t := range skip {
+		drop[t] = true
+	}
+	var kept []string
+	for _, t := range targets {
+		if !drop[t] {
+			kept = append(kept, t)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,163 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSave_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lnd.yaml")
+	cfg := Default()
+	cfg.DNSServers = []DNSServerConfig{
+		{Name: "Quad9 DoT", Address: "9.9.9.9:853", Proto: "DoT"},
+		{Name: "Local", Address: "192.168.1.1:53", Proto: "UDP", SourcePort: 5353, Family: "ipv4"},
+	}
+	cfg.Tunnels = []TunnelConfig{{Name: "Office", Target: "10.0.0.1:443", App: "http", Transport: "tls", DSCP: 46}}
+	cfg.Targets = []string{"1.1.1.1", "example.com"}
+	cfg.STUN = STUNConfig{Retries: 5, RTO: 300 * time.Millisecond, Timeout: 2 * time.Second}
+	cfg.TrafficUnits = "bits"
+
+	if err := Save(cfg, path); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	cfg.Path = path
+	if !reflect.DeepEqual(loaded, cfg) {
+		t.Errorf("round trip mismatch:\n got %+v\nwant %+v", loaded, cfg)
+	}
+}
+
+func TestSave_LeavesDefaultsOut(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lnd.yaml")
+	cfg := Default()
+	cfg.TrafficUnits = "bits"
+	if err := Save(cfg, path); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "stun_servers") || !strings.Contains(string(data), "traffic_units: bits") {
+		t.Errorf("a new file should hold only the changed settings:\n%s", data)
+	}
+
+	// A default the user wrote down stays in their file
+	os.WriteFile(path, []byte("stun_servers:\n  - stun3.l.google.com:19302\n  - stun.l.google.com:19302\n"), 0o644)
+	if err := Save(cfg, path); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	data, _ = os.ReadFile(path)
+	if !strings.Contains(string(data), "stun_servers") {
+		t.Errorf("stun_servers dropped from the existing file:\n%s", data)
+	}
+}
+
+func TestSave_PreservesComments(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "lnd.yaml")
+	original := `# My lnd setup
+stun_servers:
+  - stun.example.com:3478 # company STUN
+dns_servers:
+  - name: Old
+    address: 192.0.2.1:53
+    proto: UDP
+targets_file: targets.txt # shared with the NOC
+`
+	os.WriteFile(path, []byte(original), 0o644)
+	os.WriteFile(filepath.Join(dir, "targets.txt"), []byte("192.0.2.10\n"), 0o644)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.DNSServers = append(cfg.DNSServers, DNSServerConfig{Name: "New", Address: "198.51.100.1:53", Proto: "TCP"})
+	cfg.Targets = append(cfg.Targets, "example.org")
+	if err := Save(cfg, path); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := os.ReadFile(path)
+	out := string(data)
+	for _, want := range []string{"# My lnd setup", "# company STUN", "# shared with the NOC", "name: New", "- example.org"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("saved config lacks %q:\n%s", want, out)
+		}
+	}
+	// Targets from the targets file stay in the file
+	if strings.Contains(out, "192.0.2.10") {
+		t.Errorf("targets file entries were written back:\n%s", out)
+	}
+	if st, _ := os.Stat(path); st.Mode().Perm() != 0o644 {
+		t.Errorf("mode = %v, want the original 0644", st.Mode().Perm())
+	}
+}
+
+func TestSave_KeepsZeroValues(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lnd.yaml")
+	original := `tunnels:
+  - name: Office
+    target: 10.0.0.1:443
+    app: http
+    transport: tls
+    dscp: 46 # expedited forwarding
+`
+	os.WriteFile(path, []byte(original), 0o644)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Tunnels[0].DSCP = 0
+	if err := Save(cfg, path); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := os.ReadFile(path)
+	out := string(data)
+	if !strings.Contains(out, "dscp: 0 # expedited forwarding") {
+		t.Errorf("saved config lacks the zero dscp:\n%s", out)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Tunnels[0].DSCP != 0 {
+		t.Errorf("reloaded dscp %d, want 0", loaded.Tunnels[0].DSCP)
+	}
+}
+
+func TestSave_PortScanBanners(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lnd.yaml")
+	os.WriteFile(path, []byte("port_scan:\n  banners: true # grab greetings\n"), 0o644)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.PortScan.Banners {
+		t.Fatal("banners: true not loaded")
+	}
+	cfg.PortScan.Banners = false
+	if err := Save(cfg, path); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), "banners: false # grab greetings") {
+		t.Errorf("saved config lacks the turned off banners:\n%s", data)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.PortScan.Banners {
+		t.Error("reloaded banners still on")
+	}
+}
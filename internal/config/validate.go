@@ -0,0 +1,265 @@
+package config
+
+import (
+	"cmp"
+	"fmt"
+	"net"
+	"net/netip"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TunnelApps and TunnelTransports are the tunnel app and transport values
+// the tunnel collector implements
+var (
+	TunnelApps       = []string{"http", "ws", "tcp", "udp", "socks5", "tls", "h3"}
+	TunnelTransports = []string{"tcp", "udp", "tls", "dtls", "kcp", "quic", "socks5", "http"}
+)
+
+// DNSProtocols are the proto values of a DNS server, empty means UDP
+var DNSProtocols = []string{"UDP", "TCP", "DoT", "DoH", "DoH3", "DoQ"}
+
+// Problem is one invalid setting
+type Problem struct {
+	Key     string // YAML path of the setting, e.g. tunnels[1].transport
+	Line    int    // Line in the config file, 0 if unknown
+	Message string // Names the offending entry, e.g. tunnel "Office": ...
+}
+
+func (p Problem) String() string {
+	if p.Line > 0 {
+		return fmt.Sprintf("line %d: %s", p.Line, p.Message)
+	}
+	return p.Message
+}
+
+// ValidationError lists every problem found in a config
+type ValidationError struct {
+	Path     string
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	prefix := ""
+	if e.Path != "" {
+		prefix = e.Path + ": "
+	}
+	if len(e.Problems) == 1 {
+		return prefix + e.Problems[0].String()
+	}
+	lines := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		lines[i] = "  " + p.String()
+	}
+	return fmt.Sprintf("%s%d problems:\n%s", prefix, len(e.Problems), strings.Join(lines, "\n"))
+}
+
+// Validate checks the settings that decode fine but cannot work: unknown
+// names, out of range numbers, malformed addresses and duplicate tunnels.
+// All problems are reported at once in a *ValidationError.
+func (c *Config) Validate() error {
+	var problems []Problem
+	add := func(key, format string, args ...any) {
+		problems = append(problems, Problem{Key: key, Message: fmt.Sprintf(format, args...)})
+	}
+
+	for name := range c.Collectors {
+		if !slices.Contains(CollectorNames, name) {
+			add("collectors."+name, "unknown collector %q, valid collectors: %s", name, strings.Join(CollectorNames, ", "))
+		}
+	}
+	if p := c.Ping.Pattern; p != "" && !slices.Contains(PingPatterns, p) {
+		add("ping.pattern", "unknown ping pattern %q, valid patterns: %s", p, strings.Join(PingPatterns, ", "))
+	}
+	if t := c.Theme; t != "" && !slices.Contains(Themes, t) {
+		add("theme", "unknown theme %q, valid themes: %s", t, strings.Join(Themes, ", "))
+	}
+	if c.Ping.Size < 0 || c.Ping.Size > 65500 {
+		add("ping.size", "ping size %d out of range 0-65500", c.Ping.Size)
+	}
+	if c.Ping.Count < 0 || c.Ping.Interval < 0 || c.Ping.Timeout < 0 {
+		add("ping", "ping count, interval and timeout must not be negative")
+	} else if d := c.Ping.Duration(); d > MaxPingDuration {
+		add("ping", "ping count, interval and timeout take up to %s per target, at most %s allowed", d, MaxPingDuration)
+	}
+	if u := c.PublicIP.GeoURL; u != "" && !isHTTPURL(strings.ReplaceAll(u, "%s", "192.0.2.1")) {
+		add("public_ip.geo_url", "public_ip geo_url %q is not an http(s) URL", u)
+	}
+	if n := c.DNSQuery.UDPSize; n != 0 && (n < 512 || n > 65535) {
+		add("dns_query.udp_size", "dns_query udp_size %d out of range 512-65535", n)
+	}
+	if n := len(c.PortScan.Ports); n > MaxScanPorts {
+		add("port_scan.ports", "port_scan lists %d ports, at most %d allowed", n, MaxScanPorts)
+	}
+	for i, port := range c.PortScan.Ports {
+		if port < 1 || port > 65535 {
+			add(fmt.Sprintf("port_scan.ports[%d]", i), "port_scan port %d out of range 1-65535", port)
+		}
+	}
+	for i, st := range c.SpeedTest {
+		if !isHTTPURL(st.DownloadURL) || (st.UploadURL != "" && !isHTTPURL(st.UploadURL)) {
+			add(fmt.Sprintf("speedtest_servers[%d]", i), "speedtest server %q needs an http(s) download_url and optional upload_url", st.Name)
+		}
+	}
+	if ps := c.PowerSave; ps.Battery < 0 || ps.Idle < 0 || ps.IdleAfter < 0 {
+		add("power_save", "power_save values must not be negative")
+	}
+
+	for i, s := range c.DNSServers {
+		key := fmt.Sprintf("dns_servers[%d]", i)
+		if err := validateDNSServer(s); err != nil {
+			add(key, "dns server %q: %v", s.Name, err)
+		}
+	}
+
+	if r := c.Connectivity.PublicResolver; r != "" {
+		if _, err := ParseResolver(r); err != nil {
+			add("connectivity.public_resolver", "public resolver: %v", err)
+		}
+		if c.DNSCheck.Public.Address != "" {
+			add("connectivity.public_resolver", "public_resolver and dns_check.public both set, keep one")
+		}
+	}
+	if c.Connectivity.DNSProbeDomain != "" && c.DNSCheck.Domain != "" {
+		add("connectivity.dns_probe_domain", "dns_probe_domain and dns_check.domain both set, keep one")
+	}
+
+	seen := make(map[string]bool)
+	for i, t := range c.Tunnels {
+		key := fmt.Sprintf("tunnels[%d]", i)
+		if seen[t.Name] {
+			add(key+".name", "tunnel %q: duplicate name, results are told apart by name", t.Name)
+		}
+		seen[t.Name] = true
+		if !slices.Contains(TunnelApps, t.App) {
+			add(key+".app", "tunnel %q: unknown app %q, valid apps: %s", t.Name, t.App, strings.Join(TunnelApps, ", "))
+		} else if t.App == "h3" && t.Transport != "quic" {
+			add(key+".app", "tunnel %q: app h3 needs the quic transport, not %q", t.Name, t.Transport)
+		}
+		if !slices.Contains(TunnelTransports, t.Transport) {
+			add(key+".transport", "tunnel %q: unknown transport %q, valid transports: %s", t.Name, t.Transport, strings.Join(TunnelTransports, ", "))
+		} else if (t.Transport == "socks5" || t.Transport == "http") && t.Proxy == "" {
+			add(key+".transport", "tunnel %q: the %s transport needs a proxy address", t.Name, t.Transport)
+		}
+		if t.DSCP < 0 || t.DSCP > 63 {
+			add(key+".dscp", "tunnel %q: dscp %d out of range 0-63", t.Name, t.DSCP)
+		}
+		if k := t.KCP; k.Interval < 0 || k.SendWindow < 0 || k.RecvWindow < 0 {
+			add(key+".kcp", "tunnel %q: kcp values must not be negative", t.Name)
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Path: c.Path, Problems: problems}
+}
+
+// validateDNSServer checks a server's protocol and that its address fits
+// it: an https:// URL for DoH and DoH3, host[:port] otherwise. The port
+// may be left out, the collector then uses 53, or 853 for DoT and DoQ.
+func validateDNSServer(s DNSServerConfig) error {
+	proto := cmp.Or(s.Proto, "UDP")
+	if !slices.Contains(DNSProtocols, proto) {
+		return fmt.Errorf("unknown proto %q, valid protos: %s", s.Proto, strings.Join(DNSProtocols, ", "))
+	}
+	if proto == "DoH" || proto == "DoH3" {
+		if !strings.HasPrefix(s.Address, "https://") || !isHTTPURL(s.Address) {
+			return fmt.Errorf("%s needs an https:// URL, got %q", proto, s.Address)
+		}
+		return nil
+	}
+	host := strings.TrimSpace(s.Address)
+	if h, port, err := net.SplitHostPort(host); err == nil {
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return fmt.Errorf("invalid port %q", port)
+		}
+		host = h
+	}
+	if _, err := netip.ParseAddr(host); err == nil {
+		return nil // IPv6 included
+	}
+	if host == "" || strings.ContainsAny(host, " /:@") {
+		return fmt.Errorf("%s needs host[:port], got %q", proto, s.Address)
+	}
+	return nil
+}
+
+// locate fills in the line of each problem from the parsed document and
+// orders the problems as they appear in the file
+func (e *ValidationError) locate(doc *yaml.Node) {
+	for i, p := range e.Problems {
+		if n := lookupKey(doc, p.Key); n != nil {
+			e.Problems[i].Line = n.Line
+		}
+	}
+	slices.SortStableFunc(e.Problems, func(a, b Problem) int { return cmp.Compare(a.Line, b.Line) })
+}
+
+// lookupKey finds the node at a path like tunnels[1].transport, or the
+// deepest node along it that exists; nil if the top-level key is absent
+func lookupKey(doc *yaml.Node, key string) *yaml.Node {
+	if doc == nil || len(doc.Content) == 0 {
+		return nil
+	}
+	node := doc.Content[0]
+	for depth, part := range strings.Split(key, ".") {
+		name, index, hasIndex := strings.Cut(part, "[")
+		next := mappingValue(node, name)
+		if next == nil {
+			if depth == 0 {
+				return nil // Not in the file, a default
+			}
+			return node
+		}
+		node = next
+		if hasIndex {
+			i, err := strconv.Atoi(strings.TrimSuffix(index, "]"))
+			if err != nil || node.Kind != yaml.SequenceNode || i >= len(node.Content) {
+				return node
+			}
+			node = node.Content[i]
+		}
+	}
+	return node
+}
+
+// mappingValue returns the value of key in a mapping node, nil if absent
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// unknownKeys warns about top-level keys Config has no field for, which
+// the decoder would otherwise drop without a word, e.g. a misspelt tunnels:
+func unknownKeys(doc *yaml.Node) []string {
+	if doc == nil || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil
+	}
+	known := make(map[string]bool)
+	t := reflect.TypeOf(Config{})
+	for i := range t.NumField() {
+		if name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ","); name != "" && name != "-" {
+			known[name] = true
+		}
+	}
+	var warnings []string
+	root := doc.Content[0]
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if k := root.Content[i]; !known[k.Value] {
+			warnings = append(warnings, fmt.Sprintf("line %d: unknown key %q is ignored", k.Line, k.Value))
+		}
+	}
+	return warnings
+}
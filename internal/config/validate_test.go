@@ -0,0 +1,122 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	cfg := Default()
+	cfg.DNSServers = []DNSServerConfig{
+		{Name: "Quad9", Address: "9.9.9.9:53", Proto: "UDP"},
+		{Name: "No port", Address: "9.9.9.9", Proto: "TCP"},
+		{Name: "Bare DoT", Address: "1.1.1.1", Proto: "DoT"},
+		{Name: "Bare host", Address: "dns.quad9.net", Proto: "DoQ"},
+		{Name: "IPv6", Address: "[2620:fe::fe]:853", Proto: "DoT"},
+		{Name: "Bare IPv6", Address: "2620:fe::fe", Proto: "DoT"},
+		{Name: "Bad host", Address: "dns/quad9", Proto: "TCP"},
+		{Name: "Plain DoH", Address: "http://dns.example/dns-query", Proto: "DoH"},
+		{Name: "Lowercase", Address: "9.9.9.9:53", Proto: "tcp"},
+		{Name: "Default proto", Address: "192.168.1.1:53"},
+	}
+	cfg.Tunnels = []TunnelConfig{
+		{Name: "Office", Target: "10.0.0.1:443", App: "http", Transport: "tls"},
+		{Name: "Office", Target: "10.0.0.2:443", App: "http", Transport: "tcp"},
+		{Name: "Typo", Target: "10.0.0.3:443", App: "http", Transport: "tpc"},
+		{Name: "H3", Target: "10.0.0.4:443", App: "h3", Transport: "udp"},
+		{Name: "Proxy", Target: "10.0.0.5:80", App: "http", Transport: "socks5"},
+	}
+	cfg.Connectivity.PublicResolver = "9.9.9.9:99999"
+	cfg.Connectivity.DNSProbeDomain = "example.org"
+	cfg.DNSCheck = ConnectivityDNSConfig{Domain: "example.com", Public: DNSServerConfig{Address: "1.1.1.1:53"}}
+
+	err := cfg.Validate()
+	var invalid *ValidationError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("Validate() = %v, want a *ValidationError", err)
+	}
+	want := []string{
+		`dns server "Bad host": TCP needs host[:port]`,
+		`dns server "Plain DoH": DoH needs an https:// URL`,
+		`dns server "Lowercase": unknown proto "tcp"`,
+		`tunnel "Office": duplicate name`,
+		`tunnel "Typo": unknown transport "tpc"`,
+		`tunnel "H3": app h3 needs the quic transport`,
+		`tunnel "Proxy": the socks5 transport needs a proxy address`,
+		`public resolver: invalid port "99999"`,
+		`public_resolver and dns_check.public both set`,
+		`dns_probe_domain and dns_check.domain both set`,
+	}
+	if len(invalid.Problems) != len(want) {
+		t.Errorf("%d problems, want %d:\n%v", len(invalid.Problems), len(want), err)
+	}
+	for _, w := range want {
+		if !strings.Contains(err.Error(), w) {
+			t.Errorf("missing %q in:\n%v", w, err)
+		}
+	}
+
+	if err := Default().Validate(); err != nil {
+		t.Errorf("default config: %v", err)
+	}
+}
+
+func TestLoad_ProblemLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lnd.yaml")
+	data := `theme: default
+tunnels:
+  - name: Office
+    target: 10.0.0.1:443
+    app: http
+    transport: tpc
+dns_servers:
+  - name: Router
+    address: 192.168.1.1:0
+`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := Load(path)
+	var invalid *ValidationError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("Load() = %v, want a *ValidationError", err)
+	}
+	lines := make(map[string]int)
+	for _, p := range invalid.Problems {
+		lines[p.Key] = p.Line
+	}
+	if lines["tunnels[0].transport"] != 6 || lines["dns_servers[0]"] != 8 {
+		t.Errorf("problem lines = %v, want transport on 6 and the server on 8", lines)
+	}
+	if !strings.HasPrefix(err.Error(), path+": 2 problems:\n  line 6: ") {
+		t.Errorf("error = %q", err)
+	}
+
+	if err := os.WriteFile(path, []byte("tunnels:\n  - name: [\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), path+": yaml: line ") {
+		t.Errorf("syntax error = %v, want the path and line", err)
+	}
+}
+
+func TestLoad_UnknownKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lnd.yaml")
+	if err := os.WriteFile(path, []byte("theme: default\ntunels: []\nping:\n  cout: 5\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(cfg.Warnings) != 1 || cfg.Warnings[0] != `line 2: unknown key "tunels" is ignored` {
+		t.Errorf("Warnings = %q", cfg.Warnings)
+	}
+
+	if _, err := LoadStrict(path); err == nil || !strings.Contains(err.Error(), "field tunels not found") || !strings.Contains(err.Error(), "field cout not found") {
+		t.Errorf("LoadStrict() error = %v, want both unknown fields", err)
+	}
+}
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadDelay lets the burst of events from one save settle before the
+// file is read, editors often truncate and write in separate steps
+const reloadDelay = 200 * time.Millisecond
+
+// Watcher reloads a config file when it changes
+type Watcher struct {
+	fsw  *fsnotify.Watcher
+	done chan struct{}
+}
+
+// Watch reloads the config at path with load, Load or LoadStrict as at
+// startup, whenever the file is written and hands the result to onChange.
+// A file that no longer loads, e.g. invalid YAML, goes to onError instead
+// and the caller keeps its current config. The directory is watched rather
+// than the file, so editors and Save, which rename a new file over the old
+// one, are seen as well.
+func Watch(path string, load func(string) (*Config, error), onChange func(*Config), onError func(error)) (*Watcher, error) {
+	if path == "" {
+		return nil, fmt.Errorf("no config file path")
+	}
+	path = filepath.Clean(path)
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := fsw.Add(filepath.Dir(path)); err != nil {
+		fsw.Close()
+		return nil, err
+	}
+	w := &Watcher{fsw: fsw, done: make(chan struct{})}
+	go w.run(path, load, onChange, onError)
+	return w, nil
+}
+
+func (w *Watcher) run(path string, load func(string) (*Config, error), onChange func(*Config), onError func(error)) {
+	defer close(w.done)
+	reload := func() {
+		cfg, err := load(path)
+		if err != nil {
+			onError(err)
+			return
+		}
+		onChange(cfg)
+	}
+	var timer *time.Timer
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	for {
+		select {
+		case ev, ok := <-w.fsw.Events:
+			if !ok {
+				return
+			}
+			// Removing or renaming the file away is not a new config,
+			// the editor's next create or write is
+			if filepath.Clean(ev.Name) != path || !ev.Has(fsnotify.Write|fsnotify.Create) {
+				continue
+			}
+			if timer == nil {
+				timer = time.AfterFunc(reloadDelay, reload)
+			} else {
+				timer.Reset(reloadDelay)
+			}
+		case err, ok := <-w.fsw.Errors:
+			if !ok {
+				return
+			}
+			onError(err)
+		}
+	}
+}
+
+// Close stops watching and cancels a reload that has not started yet
+func (w *Watcher) Close() error {
+	err := w.fsw.Close()
+	<-w.done
+	return err
+}
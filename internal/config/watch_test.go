@@ -0,0 +1,98 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lnd.yaml")
+	if err := os.WriteFile(path, []byte("stun_servers: [stun.example.com:3478]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	changes := make(chan *Config, 4)
+	errs := make(chan error, 4)
+	w, err := Watch(path, Load, func(c *Config) { changes <- c }, func(err error) { errs <- err })
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+	defer w.Close()
+
+	// Other files in the directory are ignored
+	if err := os.WriteFile(filepath.Join(filepath.Dir(path), "other.yaml"), []byte("x: 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("stun_servers: [stun.example.net:3478]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case c := <-changes:
+		if len(c.StunServers) != 1 || c.StunServers[0] != "stun.example.net:3478" {
+			t.Errorf("reloaded StunServers = %v", c.StunServers)
+		}
+	case err := <-errs:
+		t.Fatalf("reload error = %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reload after the file changed")
+	}
+
+	// Saving renames a new file over the config
+	cfg := Default()
+	cfg.StunServers = []string{"stun.example.org:3478"}
+	if err := Save(cfg, path); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case c := <-changes:
+		if len(c.StunServers) != 1 || c.StunServers[0] != "stun.example.org:3478" {
+			t.Errorf("reloaded StunServers after Save = %v", c.StunServers)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reload after Save")
+	}
+
+	if err := os.WriteFile(path, []byte("stun_servers: [unclosed\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Error("invalid YAML reported a nil error")
+		}
+	case c := <-changes:
+		t.Fatalf("invalid YAML reloaded as %+v", c)
+	case <-time.After(5 * time.Second):
+		t.Fatal("invalid YAML was not reported")
+	}
+}
+
+func TestWatch_Strict(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lnd.yaml")
+	if err := os.WriteFile(path, []byte("stun_servers: [stun.example.com:3478]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	changes := make(chan *Config, 4)
+	errs := make(chan error, 4)
+	w, err := Watch(path, LoadStrict, func(c *Config) { changes <- c }, func(err error) { errs <- err })
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+	defer w.Close()
+
+	// A typo that Load only warns about
+	if err := os.WriteFile(path, []byte("stun:\n  retires: 3\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Error("unknown key reported a nil error")
+		}
+	case c := <-changes:
+		t.Fatalf("unknown key reloaded in strict mode as %+v", c)
+	case <-time.After(5 * time.Second):
+		t.Fatal("unknown key was not reported")
+	}
+}
@@ -0,0 +1,170 @@
+// Package metrics exposes collector results as Prometheus metrics.
+package metrics
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sysatom/lnd/internal/collector"
+)
+
+const namespace = "lnd"
+
+// Metrics holds the metric definitions on a private registry
+type Metrics struct {
+	Registry *prometheus.Registry
+
+	pingRTT        *prometheus.GaugeVec
+	pingLoss       *prometheus.GaugeVec
+	pingUp         *prometheus.GaugeVec
+	dnsResolve     *prometheus.GaugeVec
+	dnsUp          *prometheus.GaugeVec
+	ifaceRxBytes   *prometheus.GaugeVec
+	ifaceTxBytes   *prometheus.GaugeVec
+	ifaceErrors    *prometheus.GaugeVec
+	ifaceDrops     *prometheus.GaugeVec
+	tcpRetransRate prometheus.Gauge
+	tcpEstablished prometheus.Gauge
+	tcpTimeWait    prometheus.Gauge
+	udpRcvbufErrs  prometheus.Gauge
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		Registry: prometheus.NewRegistry(),
+		pingRTT: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace, Name: "ping_rtt_seconds", Help: "Average round-trip time to a connectivity target.",
+		}, []string{"target"}),
+		pingLoss: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace, Name: "ping_packet_loss_ratio", Help: "Packet loss to a connectivity target (0-1).",
+		}, []string{"target"}),
+		pingUp: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace, Name: "ping_up", Help: "Whether a connectivity target is reachable.",
+		}, []string{"target"}),
+		dnsResolve: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace, Name: "dns_resolve_seconds", Help: "Time to resolve a well-known name.",
+		}, []string{"resolver"}),
+		dnsUp: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace, Name: "dns_up", Help: "Whether a resolver answered the well-known name.",
+		}, []string{"resolver"}),
+		ifaceRxBytes: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace, Name: "interface_receive_bytes", Help: "Bytes received by an interface.",
+		}, []string{"interface"}),
+		ifaceTxBytes: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace, Name: "interface_transmit_bytes", Help: "Bytes sent by an interface.",
+		}, []string{"interface"}),
+		ifaceErrors: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace, Name: "interface_errors", Help: "Receive and transmit errors of an interface.",
+		}, []string{"interface"}),
+		ifaceDrops: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace, Name: "interface_drops", Help: "Dropped packets of an interface.",
+		}, []string{"interface"}),
+		tcpRetransRate: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace, Name: "tcp_retransmission_percent", Help: "TCP segments retransmitted, in percent of segments sent.",
+		}),
+		tcpEstablished: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace, Name: "tcp_established", Help: "TCP connections in ESTABLISHED state.",
+		}),
+		tcpTimeWait: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace, Name: "tcp_time_wait", Help: "TCP connections in TIME_WAIT state.",
+		}),
+		udpRcvbufErrs: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace, Name: "udp_receive_buffer_errors", Help: "UDP datagrams dropped because the receive buffer was full.",
+		}),
+	}
+
+	m.Registry.MustRegister(
+		m.pingRTT, m.pingLoss, m.pingUp, m.dnsResolve, m.dnsUp,
+		m.ifaceRxBytes, m.ifaceTxBytes, m.ifaceErrors, m.ifaceDrops,
+		m.tcpRetransRate, m.tcpEstablished, m.tcpTimeWait, m.udpRcvbufErrs,
+	)
+	return m
+}
+
+// Collectors are the data sources sampled by Sample. Nil collectors are skipped.
+type Collectors struct {
+	Connectivity *collector.ConnectivityCollector
+	Traffic      *collector.TrafficCollector
+	Kernel       *collector.KernelCollector
+}
+
+// Sample runs the collectors and updates the metrics. A collector that
+// fails is reported to onError, if set, and its metrics keep their values.
+func (m *Metrics) Sample(c Collectors, onError func(error)) {
+	report := func(source string, err error) {
+		if onError != nil {
+			onError(fmt.Errorf("%s: %w", source, err))
+		}
+	}
+	if c.Connectivity != nil {
+		if stats, err := c.Connectivity.Collect(); err != nil {
+			report("connectivity", err)
+		} else {
+			m.UpdateConnectivity(stats)
+		}
+	}
+	if c.Traffic != nil {
+		if stats, err := c.Traffic.Collect(); err != nil {
+			report("traffic", err)
+		} else {
+			m.UpdateTraffic(stats)
+		}
+	}
+	if c.Kernel != nil {
+		if stats, err := c.Kernel.Collect(); err != nil {
+			report("kernel", err)
+		} else {
+			m.UpdateKernel(stats)
+		}
+	}
+}
+
+func (m *Metrics) UpdateConnectivity(stats collector.ConnectivityStats) {
+	m.pingRTT.Reset()
+	m.pingLoss.Reset()
+	m.pingUp.Reset()
+	for target, res := range stats.Targets {
+		up := 0.0
+		if res.Error == nil && res.PacketLoss < 100 {
+			up = 1
+			m.pingRTT.WithLabelValues(target).Set(res.AvgRtt.Seconds())
+		}
+		m.pingUp.WithLabelValues(target).Set(up)
+		m.pingLoss.WithLabelValues(target).Set(res.PacketLoss / 100)
+	}
+	// A failed lookup drops its latency and shows in dns_up instead
+	m.dnsResolve.Reset()
+	m.dnsUp.Reset()
+	m.dnsUp.WithLabelValues("local").Set(upValue(stats.DNS.Error))
+	m.dnsUp.WithLabelValues("public").Set(upValue(stats.DNS.PublicError))
+	if stats.DNS.Error == nil {
+		m.dnsResolve.WithLabelValues("local").Set(stats.DNS.LocalResolverTime.Seconds())
+	}
+	if stats.DNS.PublicError == nil {
+		m.dnsResolve.WithLabelValues("public").Set(stats.DNS.PublicResolverTime.Seconds())
+	}
+}
+
+// upValue is 1 for a check that succeeded, 0 for one that failed
+func upValue(err error) float64 {
+	if err != nil {
+		return 0
+	}
+	return 1
+}
+
+func (m *Metrics) UpdateTraffic(stats collector.TrafficStats) {
+	for name, t := range stats.Interfaces {
+		m.ifaceRxBytes.WithLabelValues(name).Set(float64(t.RxBytes))
+		m.ifaceTxBytes.WithLabelValues(name).Set(float64(t.TxBytes))
+		m.ifaceErrors.WithLabelValues(name).Set(float64(t.Errors))
+		m.ifaceDrops.WithLabelValues(name).Set(float64(t.Drop))
+	}
+}
+
+func (m *Metrics) UpdateKernel(stats collector.KernelStats) {
+	m.tcpRetransRate.Set(stats.TCPRetransRate)
+	m.tcpEstablished.Set(float64(stats.TCPEstablished))
+	m.tcpTimeWait.Set(float64(stats.TCPTimeWait))
+	m.udpRcvbufErrs.Set(float64(stats.UDPRcvbufErrors))
+}
@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/push"
+	"github.com/prometheus/common/expfmt"
+)
+
+// PushConfig describes where and how often metrics are pushed
+type PushConfig struct {
+	URL      string // Pushgateway base URL, e.g. http://pushgateway:9091
+	Job      string
+	Instance string
+	Interval time.Duration
+}
+
+// Pusher periodically pushes metrics to a Prometheus Pushgateway
+type Pusher struct {
+	cfg     PushConfig
+	metrics *Metrics
+	client  *http.Client
+}
+
+func NewPusher(cfg PushConfig, m *Metrics) *Pusher {
+	if cfg.Job == "" {
+		cfg.Job = "lnd"
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = 15 * time.Second
+	}
+	return &Pusher{cfg: cfg, metrics: m, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Push sends the current metric values (PUT, replacing every metric in the
+// group, so series gone from this sample do not linger on the gateway)
+func (p *Pusher) Push(ctx context.Context) error {
+	pusher := push.New(p.cfg.URL, p.cfg.Job).
+		Gatherer(p.metrics.Registry).
+		Client(p.client).
+		Format(expfmt.NewFormat(expfmt.TypeTextPlain))
+	if p.cfg.Instance != "" {
+		pusher = pusher.Grouping("instance", p.cfg.Instance)
+	}
+	return pusher.PushContext(ctx)
+}
+
+// Run samples the collectors and pushes every interval until ctx is done.
+// Collector and push errors are reported to onError and do not stop the loop.
+func (p *Pusher) Run(ctx context.Context, c Collectors, onError func(error)) {
+	ticker := time.NewTicker(p.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		p.metrics.Sample(c, onError)
+		if err := p.Push(ctx); err != nil && onError != nil && ctx.Err() == nil {
+			onError(fmt.Errorf("push failed: %w", err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
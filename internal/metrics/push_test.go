@@ -0,0 +1,108 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/expfmt"
+	"github.com/sysatom/lnd/internal/collector"
+)
+
+func TestPusher_Push(t *testing.T) {
+	var method, path, body string
+	gw := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer gw.Close()
+
+	m := New()
+	m.UpdateConnectivity(collector.ConnectivityStats{
+		Targets: map[string]collector.PingResult{
+			"8.8.8.8":  {Target: "8.8.8.8", AvgRtt: 20 * time.Millisecond},
+			"10.0.0.9": {Target: "10.0.0.9", PacketLoss: 100},
+		},
+	})
+	m.UpdateKernel(collector.KernelStats{TCPRetransRate: 0.5, TCPEstablished: 12})
+
+	p := NewPusher(PushConfig{URL: gw.URL, Job: "edge", Instance: "host-1"}, m)
+	if err := p.Push(context.Background()); err != nil {
+		t.Fatalf("Push() error = %v", err)
+	}
+
+	if method != http.MethodPut {
+		t.Errorf("method = %s, want PUT", method)
+	}
+	if path != "/metrics/job/edge/instance/host-1" {
+		t.Errorf("path = %s", path)
+	}
+	for _, want := range []string{
+		`lnd_ping_rtt_seconds{target="8.8.8.8"} 0.02`,
+		`lnd_ping_up{target="10.0.0.9"} 0`,
+		`lnd_ping_packet_loss_ratio{target="10.0.0.9"} 1`,
+		`lnd_tcp_retransmission_percent 0.5`,
+		`lnd_tcp_established 12`,
+		`# TYPE lnd_ping_up gauge`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("payload missing %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestUpdateConnectivity_DNS(t *testing.T) {
+	m := New()
+	m.UpdateConnectivity(collector.ConnectivityStats{DNS: collector.DNSResult{LocalResolverTime: 30 * time.Millisecond, PublicResolverTime: 20 * time.Millisecond}})
+	m.UpdateConnectivity(collector.ConnectivityStats{DNS: collector.DNSResult{Error: errors.New("timeout"), PublicResolverTime: 25 * time.Millisecond}})
+	m.UpdateConnectivity(collector.ConnectivityStats{DNS: collector.DNSResult{LocalResolverTime: 10 * time.Millisecond, PublicError: errors.New("timeout"), PublicResolverTime: 2 * time.Second}})
+
+	var b strings.Builder
+	families, err := m.Registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mf := range families {
+		expfmt.MetricFamilyToText(&b, mf)
+	}
+	out := b.String()
+	for _, want := range []string{`lnd_dns_up{resolver="local"} 1`, `lnd_dns_up{resolver="public"} 0`, `lnd_dns_resolve_seconds{resolver="local"} 0.01`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, `lnd_dns_resolve_seconds{resolver="public"}`) {
+		t.Errorf("latency of the failed public lookup published:\n%s", out)
+	}
+}
+
+func TestSample_ReportsErrors(t *testing.T) {
+	conn := collector.NewConnectivityCollector()
+	conn.Budget = collector.NewBudget(1, 0)
+	conn.Budget.Allow(1, 0) // Drained, the cycle is skipped and nothing is sent
+
+	var errs []error
+	New().Sample(Collectors{Connectivity: conn}, func(err error) { errs = append(errs, err) })
+	if len(errs) != 1 || !errors.Is(errs[0], collector.ErrBudgetExceeded) || !strings.HasPrefix(errs[0].Error(), "connectivity: ") {
+		t.Errorf("errors = %v, want the skipped connectivity cycle", errs)
+	}
+}
+
+func TestPusher_PushError(t *testing.T) {
+	gw := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad", http.StatusBadRequest)
+	}))
+	defer gw.Close()
+
+	p := NewPusher(PushConfig{URL: gw.URL}, New())
+	if err := p.Push(context.Background()); err == nil {
+		t.Error("expected error for non-2xx pushgateway response")
+	}
+}
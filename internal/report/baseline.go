@@ -0,0 +1,217 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/sysatom/lnd/internal/collector"
+	"github.com/sysatom/lnd/internal/config"
+)
+
+// Baseline is a named summary of a known good snapshot. Live snapshots are
+// summarized the same way and diffed against it. Empty fields and nil maps
+// mean "not collected" and are not compared.
+type Baseline struct {
+	Name        string
+	Time        time.Time
+	RTT         map[string]time.Duration // Average ping RTT of each answering target
+	Loss        map[string]float64       // Packet loss percentage of each pinged target
+	Gateway     string                   // "<ip> via <iface>", "none" without a default route
+	NATType     string
+	PublicIP    string
+	RetransRate *float64 // TCP retransmission rate in percent
+	Routes      []string // Main table routes, sorted
+}
+
+// NewBaseline summarizes s under name
+func NewBaseline(name string, s Snapshot) Baseline {
+	b := Baseline{Name: name, Time: s.Time}
+	for target, res := range s.Connectivity.Targets {
+		if res.Error != nil {
+			continue
+		}
+		if b.Loss == nil {
+			b.RTT, b.Loss = make(map[string]time.Duration), make(map[string]float64)
+		}
+		b.Loss[target] = res.PacketLoss
+		if res.PacketLoss < 100 {
+			b.RTT[target] = res.AvgRtt
+		}
+	}
+	if gw := s.Gateway; gw != nil {
+		switch {
+		case gw.Gateway == "" && gw.Error == nil:
+			b.Gateway = "none"
+		case gw.Interface != "":
+			b.Gateway = gw.Gateway + " via " + gw.Interface
+		default:
+			b.Gateway = gw.Gateway
+		}
+	}
+	for _, nat := range s.NAT {
+		if nat.Error == nil && nat.NatType != "" {
+			b.NATType = string(nat.NatType)
+			break
+		}
+	}
+	if s.PublicIP.Error == nil {
+		b.PublicIP = s.PublicIP.IP
+	}
+	b.Routes = s.Host.Routes
+	if s.Kernel.Error == nil && s.Kernel != (collector.KernelStats{}) {
+		rate := s.Kernel.TCPRetransRate
+		b.RetransRate = &rate
+	}
+	return b
+}
+
+// Tolerances are how far live values may drift from the baseline before
+// they are reported
+type Tolerances struct {
+	RTT        time.Duration // Absolute RTT increase per target
+	RTTPercent float64       // Relative RTT increase; both limits must be exceeded
+	Loss       float64       // Packet loss increase in percentage points
+	Retrans    float64       // Retransmission rate increase in percentage points
+}
+
+// NewTolerances fills the unset limits of c with defaults
+func NewTolerances(c config.BaselineConfig) Tolerances {
+	t := Tolerances{RTT: 20 * time.Millisecond, RTTPercent: 50, Loss: 5, Retrans: 1}
+	if c.RTT > 0 {
+		t.RTT = c.RTT
+	}
+	if c.RTTPercent > 0 {
+		t.RTTPercent = c.RTTPercent
+	}
+	if c.LossPercent > 0 {
+		t.Loss = c.LossPercent
+	}
+	if c.RetransPercent > 0 {
+		t.Retrans = c.RetransPercent
+	}
+	return t
+}
+
+// Deviation is a live value outside the tolerance of its baseline value
+type Deviation struct {
+	Metric   string // e.g. "RTT 8.8.8.8" or "NAT type"
+	Baseline string
+	Live     string
+}
+
+// Diff compares live against base and returns the deviations sorted by
+// metric. Values missing on either side are skipped.
+func Diff(base, live Baseline, tol Tolerances) []Deviation {
+	var out []Deviation
+	add := func(metric, baseline, live string) {
+		out = append(out, Deviation{Metric: metric, Baseline: baseline, Live: live})
+	}
+
+	for target, baseLoss := range base.Loss {
+		liveLoss, ok := live.Loss[target]
+		if !ok {
+			continue
+		}
+		if liveLoss-baseLoss > tol.Loss {
+			add("Loss "+target, fmt.Sprintf("%.0f%%", baseLoss), fmt.Sprintf("%.0f%%", liveLoss))
+		}
+		baseRTT, ok := base.RTT[target]
+		if !ok {
+			continue
+		}
+		liveRTT, ok := live.RTT[target]
+		if !ok {
+			continue
+		}
+		increase := liveRTT - baseRTT
+		if increase > tol.RTT && (baseRTT <= 0 || float64(increase)/float64(baseRTT)*100 > tol.RTTPercent) {
+			add("RTT "+target, formatRTT(baseRTT), formatRTT(liveRTT))
+		}
+	}
+
+	changed := func(metric, baseline, live string) {
+		if baseline != "" && live != "" && baseline != live {
+			add(metric, baseline, live)
+		}
+	}
+	changed("Default route", base.Gateway, live.Gateway)
+	changed("NAT type", base.NATType, live.NATType)
+	changed("Public IP", base.PublicIP, live.PublicIP)
+
+	if base.RetransRate != nil && live.RetransRate != nil && *live.RetransRate-*base.RetransRate > tol.Retrans {
+		add("TCP retransmissions", fmt.Sprintf("%.2f%%", *base.RetransRate), fmt.Sprintf("%.2f%%", *live.RetransRate))
+	}
+
+	if base.Routes != nil && live.Routes != nil {
+		for _, r := range live.Routes {
+			if !slices.Contains(base.Routes, r) {
+				add("Route "+r, "absent", "added")
+			}
+		}
+		for _, r := range base.Routes {
+			if !slices.Contains(live.Routes, r) {
+				add("Route "+r, "present", "missing")
+			}
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Metric < out[j].Metric })
+	return out
+}
+
+func formatRTT(d time.Duration) string {
+	return fmt.Sprintf("%.1fms", float64(d.Microseconds())/1000)
+}
+
+var baselineName = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// BaselinePath is ~/.lnd/baselines/<name>.json
+func BaselinePath(name string) (string, error) {
+	if !baselineName.MatchString(name) || name == "." || name == ".." {
+		return "", fmt.Errorf("invalid baseline name %q, use letters, digits, '.', '_' and '-'", name)
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".lnd", "baselines", name+".json"), nil
+}
+
+// SaveBaseline writes b to its path, replacing an older baseline of the same name
+func SaveBaseline(b Baseline) (string, error) {
+	path, err := BaselinePath(b.Name)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	return path, os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// LoadBaseline reads the baseline saved under name
+func LoadBaseline(name string) (Baseline, error) {
+	var b Baseline
+	path, err := BaselinePath(name)
+	if err != nil {
+		return b, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return b, err
+	}
+	if err := json.Unmarshal(data, &b); err != nil {
+		return b, fmt.Errorf("%s: %w", path, err)
+	}
+	return b, nil
+}
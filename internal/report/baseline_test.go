@@ -0,0 +1,108 @@
+package report
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/sysatom/lnd/internal/collector"
+	"github.com/sysatom/lnd/internal/config"
+)
+
+func baselineSnapshot(rtt time.Duration, loss float64, nat collector.NatType, retrans float64) Snapshot {
+	s := NewSnapshot()
+	s.Connectivity = collector.ConnectivityStats{Targets: map[string]collector.PingResult{
+		"8.8.8.8": {Target: "8.8.8.8", AvgRtt: rtt, PacketLoss: loss},
+	}}
+	s.Gateway = &collector.GatewayHealth{Gateway: "192.168.1.1", Interface: "eth0"}
+	s.NAT = []collector.NatInfo{{Target: "stun.l.google.com:19302", NatType: nat}}
+	s.Kernel = collector.KernelStats{TCPRetransRate: retrans, TCPEstablished: 12}
+	return s
+}
+
+func TestDiff_FlagsValuesBeyondTolerance(t *testing.T) {
+	base := NewBaseline("home", baselineSnapshot(20*time.Millisecond, 0, collector.NatFullCone, 0.5))
+	tol := NewTolerances(config.BaselineConfig{})
+
+	// Within tolerance: +10ms RTT, +0.5 points retransmissions
+	live := NewBaseline("", baselineSnapshot(30*time.Millisecond, 0, collector.NatFullCone, 1.0))
+	if devs := Diff(base, live, tol); len(devs) != 0 {
+		t.Errorf("Diff() within tolerance = %+v, want none", devs)
+	}
+
+	live = NewBaseline("", baselineSnapshot(80*time.Millisecond, 10, collector.NatSymmetric, 3.0))
+	devs := Diff(base, live, tol)
+	var metrics []string
+	for _, d := range devs {
+		metrics = append(metrics, d.Metric)
+	}
+	want := []string{"Loss 8.8.8.8", "NAT type", "RTT 8.8.8.8", "TCP retransmissions"}
+	if !slices.Equal(metrics, want) {
+		t.Fatalf("Diff() metrics = %v, want %v", metrics, want)
+	}
+	if rtt := devs[2]; rtt.Baseline != "20.0ms" || rtt.Live != "80.0ms" {
+		t.Errorf("RTT deviation = %+v", rtt)
+	}
+
+	// A tighter configured tolerance flags the smaller regression too
+	tight := NewTolerances(config.BaselineConfig{RTT: 5 * time.Millisecond, RTTPercent: 10})
+	live = NewBaseline("", baselineSnapshot(30*time.Millisecond, 0, collector.NatFullCone, 0.5))
+	if devs := Diff(base, live, tight); len(devs) != 1 || devs[0].Metric != "RTT 8.8.8.8" {
+		t.Errorf("Diff() with tight tolerance = %+v, want the RTT regression", devs)
+	}
+}
+
+func TestDiff_SkipsValuesNotCollected(t *testing.T) {
+	base := NewBaseline("home", baselineSnapshot(20*time.Millisecond, 0, collector.NatFullCone, 0.5))
+	live := NewBaseline("", NewSnapshot()) // Nothing collected yet
+	if devs := Diff(base, live, NewTolerances(config.BaselineConfig{})); len(devs) != 0 {
+		t.Errorf("Diff() against an empty snapshot = %+v, want none", devs)
+	}
+
+	s := baselineSnapshot(20*time.Millisecond, 0, collector.NatFullCone, 0.5)
+	s.Gateway = &collector.GatewayHealth{}
+	devs := Diff(base, NewBaseline("", s), NewTolerances(config.BaselineConfig{}))
+	if len(devs) != 1 || devs[0].Metric != "Default route" || devs[0].Live != "none" {
+		t.Errorf("Diff() after losing the default route = %+v", devs)
+	}
+}
+
+func TestSaveLoadBaseline(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	b := NewBaseline("office-wifi", baselineSnapshot(20*time.Millisecond, 0, collector.NatFullCone, 0.5))
+	if _, err := SaveBaseline(b); err != nil {
+		t.Fatalf("SaveBaseline() error = %v", err)
+	}
+	got, err := LoadBaseline("office-wifi")
+	if err != nil {
+		t.Fatalf("LoadBaseline() error = %v", err)
+	}
+	if got.RTT["8.8.8.8"] != 20*time.Millisecond || got.Gateway != "192.168.1.1 via eth0" || *got.RetransRate != 0.5 {
+		t.Errorf("LoadBaseline() = %+v", got)
+	}
+	if _, err := BaselinePath("../etc"); err == nil {
+		t.Error("BaselinePath() accepted a name with a path separator")
+	}
+}
+
+func TestDiff_FlagsRouteChanges(t *testing.T) {
+	s := baselineSnapshot(20*time.Millisecond, 0, collector.NatFullCone, 0.5)
+	s.Host.Routes = []string{"192.168.1.0/24 dev eth0", "default via 192.168.1.1 dev eth0"}
+	base := NewBaseline("home", s)
+
+	s.Host.Routes = []string{"10.8.0.0/24 dev tun0", "192.168.1.0/24 dev eth0"}
+	devs := Diff(base, NewBaseline("", s), NewTolerances(config.BaselineConfig{}))
+	want := []Deviation{
+		{Metric: "Route 10.8.0.0/24 dev tun0", Baseline: "absent", Live: "added"},
+		{Metric: "Route default via 192.168.1.1 dev eth0", Baseline: "present", Live: "missing"},
+	}
+	if !slices.Equal(devs, want) {
+		t.Errorf("Diff() = %+v, want %+v", devs, want)
+	}
+
+	// A baseline saved before routes were recorded compares nothing
+	base.Routes = nil
+	if devs := Diff(base, NewBaseline("", s), NewTolerances(config.BaselineConfig{})); len(devs) != 0 {
+		t.Errorf("Diff() without baseline routes = %+v, want none", devs)
+	}
+}
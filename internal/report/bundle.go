@@ -0,0 +1,151 @@
+package report
+
+import (
+	"fmt"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sysatom/lnd/internal/config"
+)
+
+const redacted = "REDACTED"
+
+// BundleOptions controls what is stripped from a diagnostic bundle
+type BundleOptions struct {
+	RedactPublicIPs bool
+}
+
+// Bundle renders s as Markdown ready to paste into a GitHub issue: a short
+// summary followed by the full snapshot as collapsible JSON. Secrets in the
+// config are always redacted.
+func Bundle(s Snapshot, opts BundleOptions) (string, error) {
+	s.Config = RedactConfig(s.Config)
+	data, err := JSON(s)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.WriteString("### lnd diagnostic bundle\n\n")
+	fmt.Fprintf(&b, "- Version: %s (commit %s)\n", s.Version, s.Commit)
+	fmt.Fprintf(&b, "- OS: %s", s.OS)
+	if h := s.Host; h.Platform != "" {
+		fmt.Fprintf(&b, ", %s %s", h.Platform, h.PlatformVersion)
+	}
+	if s.Host.KernelVersion != "" {
+		fmt.Fprintf(&b, ", kernel %s", s.Host.KernelVersion)
+	}
+	b.WriteString("\n")
+	fmt.Fprintf(&b, "- Collected: %s\n", s.Time.Format(time.RFC3339))
+	if len(s.Errors) > 0 {
+		fmt.Fprintf(&b, "- Recent errors: %d\n", len(s.Errors))
+	}
+	b.WriteString("\n<details><summary>Snapshot (JSON)</summary>\n\n```json\n")
+	b.Write(data)
+	b.WriteString("```\n\n</details>\n")
+
+	out := b.String()
+	if opts.RedactPublicIPs {
+		out = RedactPublicIPs(out)
+	}
+	return out, nil
+}
+
+// DefaultBundlePath is ~/lnd-bundle-<timestamp>.md, falling back to the
+// working directory if the home directory is unknown
+func DefaultBundlePath(t time.Time) string {
+	return homePath("lnd-bundle-" + t.Format("20060102-150405") + ".md")
+}
+
+// homePath is name in the user's home directory, or name alone if it is unknown
+func homePath(name string) string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return name
+	}
+	return filepath.Join(home, name)
+}
+
+// RedactConfig returns a copy of cfg with proxy passwords and URL credentials
+// replaced. cfg itself is not modified.
+func RedactConfig(cfg *config.Config) *config.Config {
+	if cfg == nil {
+		return nil
+	}
+	out := *cfg
+	out.Tunnels = append([]config.TunnelConfig(nil), cfg.Tunnels...)
+	for i := range out.Tunnels {
+		t := &out.Tunnels[i]
+		if t.Password != "" {
+			t.Password = redacted
+		}
+		t.Proxy = redactURL(t.Proxy)
+		t.Target = redactURL(t.Target)
+	}
+	out.DNSServers = append([]config.DNSServerConfig(nil), cfg.DNSServers...)
+	for i := range out.DNSServers {
+		out.DNSServers[i].Address = redactURL(out.DNSServers[i].Address)
+	}
+	return &out
+}
+
+// redactURL hides the password of a user:pass@ URL, anything else is returned as is
+func redactURL(s string) string {
+	if !strings.Contains(s, "@") {
+		return s
+	}
+	raw := s
+	if !strings.Contains(raw, "://") {
+		raw = "//" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.User == nil {
+		return s
+	}
+	if _, ok := u.User.Password(); !ok {
+		return s
+	}
+	u.User = url.UserPassword(u.User.Username(), redacted)
+	return strings.TrimPrefix(u.String(), "//")
+}
+
+var ipCandidate = regexp.MustCompile(`[0-9A-Fa-f:.]*[:.][0-9A-Fa-f:.]*`)
+
+// RedactPublicIPs masks globally routable IPv4 and IPv6 addresses in text.
+// Private, loopback and link-local addresses stay, they help debugging and
+// identify nobody.
+func RedactPublicIPs(text string) string {
+	return ReplacePublicIPs(text, func(addr netip.Addr) string {
+		if addr.Is4() {
+			return "x.x.x.x"
+		}
+		return "x:x:x:x"
+	})
+}
+
+// ReplacePublicIPs replaces every globally routable address in text with
+// mask(addr), leaving private, loopback and link-local addresses alone
+func ReplacePublicIPs(text string, mask func(netip.Addr) string) string {
+	return ipCandidate.ReplaceAllStringFunc(text, func(s string) string {
+		candidate := strings.Trim(s, ".:")
+		addr, err := netip.ParseAddr(candidate)
+		if err != nil {
+			// 8.8.8.8:53
+			addrPort, err := netip.ParseAddrPort(candidate)
+			if err != nil || !addrPort.Addr().Is4() {
+				return s
+			}
+			addr = addrPort.Addr()
+			candidate = addr.String()
+		}
+		if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+			return s
+		}
+		return strings.Replace(s, candidate, mask(addr), 1)
+	})
+}
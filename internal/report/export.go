@@ -0,0 +1,27 @@
+package report
+
+import (
+	"os"
+	"time"
+)
+
+// ExportSnapshot writes s as indented JSON to path, the same encoding as
+// the --json report: errors as their message, durations as text. Secrets
+// in the config are redacted as in a bundle.
+func ExportSnapshot(s Snapshot, path string, opts BundleOptions) error {
+	s.Config = RedactConfig(s.Config)
+	data, err := JSON(s)
+	if err != nil {
+		return err
+	}
+	if opts.RedactPublicIPs {
+		data = []byte(RedactPublicIPs(string(data)))
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+// DefaultExportPath is ~/lnd-report-<timestamp>.json, falling back to the
+// working directory if the home directory is unknown
+func DefaultExportPath(t time.Time) string {
+	return homePath("lnd-report-" + t.Format("20060102-150405") + ".json")
+}
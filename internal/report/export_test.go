@@ -0,0 +1,67 @@
+package report
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sysatom/lnd/internal/collector"
+	"github.com/sysatom/lnd/internal/config"
+)
+
+func TestExportSnapshot(t *testing.T) {
+	cfg := config.Default()
+	cfg.Tunnels = []config.TunnelConfig{{Name: "office", Target: "10.0.0.5:22", Transport: "socks5", Proxy: "proxy.example.com:1080", User: "alice", Password: "hunter2"}}
+
+	s := NewSnapshot()
+	s.Config = cfg
+	s.Host = collector.HostInfo{Hostname: "testhost"}
+	s.Connectivity = collector.ConnectivityStats{Targets: map[string]collector.PingResult{
+		"1.1.1.1": {Target: "1.1.1.1", PacketLoss: 100, Error: errors.New("timeout")},
+	}}
+	s.NAT = []collector.NatInfo{{Target: "stun.example.com:3478", NatType: collector.NatFullCone}}
+
+	path := filepath.Join(t.TempDir(), "report.json")
+	if err := ExportSnapshot(s, path, BundleOptions{}); err != nil {
+		t.Fatalf("ExportSnapshot() error = %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Time         time.Time
+		Version      string
+		Host         struct{ Hostname string }
+		Connectivity struct {
+			Targets map[string]struct{ Error string }
+		}
+	}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("export is not valid JSON: %v", err)
+	}
+	if got.Time.IsZero() || got.Version != s.Version || got.Host.Hostname != "testhost" {
+		t.Errorf("export lacks the timestamp, version or host: %+v", got)
+	}
+	if e := got.Connectivity.Targets["1.1.1.1"].Error; e != "timeout" {
+		t.Errorf("error serialized as %q, want its message", e)
+	}
+	if strings.Contains(string(data), "hunter2") {
+		t.Error("export leaks the proxy password")
+	}
+
+	if err := ExportSnapshot(s, filepath.Join(t.TempDir(), "missing", "report.json"), BundleOptions{}); err == nil {
+		t.Error("writing into a missing directory should fail")
+	}
+}
+
+func TestDefaultExportPath(t *testing.T) {
+	got := DefaultExportPath(time.Date(2024, 5, 1, 13, 4, 5, 0, time.UTC))
+	if filepath.Base(got) != "lnd-report-20240501-130405.json" {
+		t.Errorf("DefaultExportPath() = %q", got)
+	}
+}
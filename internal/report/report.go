@@ -0,0 +1,188 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"runtime"
+	"time"
+
+	"github.com/sysatom/lnd/internal/build"
+	"github.com/sysatom/lnd/internal/collector"
+	"github.com/sysatom/lnd/internal/config"
+)
+
+// Snapshot is everything lnd has collected at one point in time
+type Snapshot struct {
+	Time    time.Time
+	Version string
+	Commit  string
+	OS      string // GOOS/GOARCH of the binary
+	Config  *config.Config
+
+	Host           collector.HostInfo
+	Connectivity   collector.ConnectivityStats
+	Gateway        *collector.GatewayHealth
+	Traffic        collector.TrafficStats
+	Softirqs       collector.SoftirqStats
+	Kernel         collector.KernelStats
+	ListenSockets  *collector.ListenResult
+	NAT            []collector.NatInfo
+	PublicIP       collector.PublicIPInfo
+	DHCP           *collector.DHCPInfo
+	Matrix         *collector.ConnectivityMatrix
+	Regions        []collector.RegionLatency
+	DNSBreakdown   *collector.DNSBreakdown
+	Bufferbloat    *collector.BufferbloatResult
+	SpeedTest      *collector.SpeedTestResult
+	MSS            []collector.MSSResult
+	Egress         []collector.EgressRoute
+	TFO            *collector.TFOReport
+	ICMPQueries    []collector.ICMPQueryResult
+	PathMTU        []collector.PathMTUResult
+	Tunnels        []collector.TunnelResult
+	DNSLookup      *collector.DNSLookupResult
+	URLDiagnosis   *collector.URLDiagnosis
+	ZoneTransfer   *collector.ZoneTransferResult
+	DNSTrace       *collector.DNSTraceResult
+	DNSCache       *collector.DNSCacheResult
+	PortScan       *collector.PortScan
+	DNSBatch       *collector.DNSBatchResult
+	DNSCompare     *collector.DNSComparison
+	ResolveConnect *collector.ResolveConnectResult
+	Errors         []string // Recent collector errors, oldest first
+}
+
+// NewSnapshot returns a snapshot stamped with the time and build information
+func NewSnapshot() Snapshot {
+	return Snapshot{
+		Time:    time.Now(),
+		Version: build.Version,
+		Commit:  build.Commit,
+		OS:      runtime.GOOS + "/" + runtime.GOARCH,
+	}
+}
+
+// JSON encodes v indented. Errors become their message, durations their
+// String() form and NaN/Inf floats null; struct fields keep their order.
+func JSON(v any) ([]byte, error) {
+	return encode(plain(reflect.ValueOf(v), true))
+}
+
+func encode(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+var (
+	errorType    = reflect.TypeOf((*error)(nil)).Elem()
+	durationType = reflect.TypeOf(time.Duration(0))
+	timeType     = reflect.TypeOf(time.Time{})
+)
+
+// object is a JSON object that keeps its keys in insertion order
+type object []field
+
+type field struct {
+	key   string
+	value any
+}
+
+func (o object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(f.key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(f.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// plain converts v into values encoding/json handles the way a reader
+// expects. Without detail, struct fields tagged report:"detail" are dropped.
+func plain(v reflect.Value, detail bool) any {
+	if !v.IsValid() {
+		return nil
+	}
+	if v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		if v.Type().Implements(errorType) {
+			return v.Interface().(error).Error()
+		}
+		return plain(v.Elem(), detail)
+	}
+
+	switch v.Type() {
+	case durationType:
+		return time.Duration(v.Int()).String()
+	case timeType:
+		t := v.Interface().(time.Time)
+		if t.IsZero() {
+			return nil
+		}
+		return t.Format(time.RFC3339)
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		var obj object
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			if !f.IsExported() || f.Tag.Get("yaml") == "-" || (!detail && f.Tag.Get("report") == "detail") {
+				continue
+			}
+			obj = append(obj, field{f.Name, plain(v.Field(i), detail)})
+		}
+		return obj
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		m := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			m[fmt.Sprint(iter.Key().Interface())] = plain(iter.Value(), detail)
+		}
+		return m
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		items := make([]any, v.Len())
+		for i := range items {
+			items[i] = plain(v.Index(i), detail)
+		}
+		return items
+	case reflect.Float32, reflect.Float64:
+		if f := v.Float(); !math.IsNaN(f) && !math.IsInf(f, 0) {
+			return f
+		}
+		return nil
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		return nil
+	}
+	return v.Interface()
+}
@@ -0,0 +1,101 @@
+package report
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// metadataFields describe the snapshot itself and are always included,
+// Errors so a failed section is told apart from an empty one
+var metadataFields = map[string]bool{"Time": true, "Version": true, "Commit": true, "OS": true, "Errors": true}
+
+// sectionAliases expand shorthand section names
+var sectionAliases = map[string][]string{
+	"dns": {"dnsbreakdown", "dnslookup"},
+}
+
+// sectionKey normalizes a section name: lower case without separators, so
+// public_ip, public-ip and PublicIP all name the PublicIP field
+func sectionKey(name string) string {
+	return strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(strings.TrimSpace(name)))
+}
+
+// Sections lists the snapshot sections a field selection can name
+func Sections() []string {
+	t := reflect.TypeOf(Snapshot{})
+	var out []string
+	for i := 0; i < t.NumField(); i++ {
+		if name := t.Field(i).Name; !metadataFields[name] {
+			out = append(out, sectionKey(name))
+		}
+	}
+	return out
+}
+
+// ParseFields turns a comma separated section list, e.g. "dns,connectivity",
+// into section keys. An empty list selects every section.
+func ParseFields(list string) ([]string, error) {
+	if strings.TrimSpace(list) == "" {
+		return Sections(), nil
+	}
+	known := make(map[string]bool)
+	for _, s := range Sections() {
+		known[s] = true
+	}
+
+	seen := make(map[string]bool)
+	var out []string
+	for _, name := range strings.Split(list, ",") {
+		key := sectionKey(name)
+		if key == "" {
+			continue
+		}
+		keys, ok := sectionAliases[key]
+		if !ok {
+			keys = []string{key}
+		}
+		for _, k := range keys {
+			if !known[k] {
+				valid := append(Sections(), "dns")
+				sort.Strings(valid)
+				return nil, fmt.Errorf("unknown section %q, valid sections: %s", strings.TrimSpace(name), strings.Join(valid, ", "))
+			}
+			if !seen[k] {
+				seen[k] = true
+				out = append(out, k)
+			}
+		}
+	}
+	return out, nil
+}
+
+// SelectOptions prunes a snapshot for headless output
+type SelectOptions struct {
+	Fields  []string // Section keys from ParseFields, nil for all
+	Verbose bool     // Keep per-record detail such as DNS message sections and per-queue stats
+}
+
+// SelectJSON encodes the metadata and the selected sections of s
+func SelectJSON(s Snapshot, opts SelectOptions) ([]byte, error) {
+	selected := make(map[string]bool)
+	for _, f := range opts.Fields {
+		selected[f] = true
+	}
+
+	v := reflect.ValueOf(s)
+	var obj object
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		if !metadataFields[f.Name] && opts.Fields != nil && !selected[sectionKey(f.Name)] {
+			continue
+		}
+		value := v.Field(i)
+		if f.Name == "Config" {
+			value = reflect.ValueOf(RedactConfig(s.Config))
+		}
+		obj = append(obj, field{f.Name, plain(value, opts.Verbose)})
+	}
+	return encode(obj)
+}
@@ -0,0 +1,77 @@
+package report
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sysatom/lnd/internal/collector"
+	"github.com/sysatom/lnd/internal/config"
+)
+
+func TestParseFields(t *testing.T) {
+	got, err := ParseFields("dns, Connectivity,public_ip,dns")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"dnsbreakdown", "dnslookup", "connectivity", "publicip"}
+	if !slices.Equal(got, want) {
+		t.Errorf("ParseFields() = %v, want %v", got, want)
+	}
+
+	if all, err := ParseFields(""); err != nil || !slices.Equal(all, Sections()) {
+		t.Errorf("empty list should select every section, got %v (%v)", all, err)
+	}
+	if _, err := ParseFields("dns,bogus"); err == nil || !strings.Contains(err.Error(), "bogus") {
+		t.Errorf("expected an unknown section error, got %v", err)
+	}
+}
+
+func TestSelectJSON_Subset(t *testing.T) {
+	s := NewSnapshot()
+	s.Config = config.Default()
+	s.Host = collector.HostInfo{Hostname: "testhost"}
+	s.Connectivity = collector.ConnectivityStats{Targets: map[string]collector.PingResult{
+		"8.8.8.8": {Target: "8.8.8.8", AvgRtt: 12 * time.Millisecond},
+	}}
+	s.DNSLookup = &collector.DNSLookupResult{
+		Records:  []string{"example.com. 60 IN A 192.0.2.1"},
+		Question: []string{"example.com. IN A"},
+	}
+
+	fields, err := ParseFields("dns,connectivity")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := SelectJSON(s, SelectOptions{Fields: fields})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out map[string]json.RawMessage
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, data)
+	}
+	var keys []string
+	for k := range out {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	want := []string{"Commit", "Connectivity", "DNSBreakdown", "DNSLookup", "Errors", "OS", "Time", "Version"}
+	if !slices.Equal(keys, want) {
+		t.Errorf("keys = %v, want %v", keys, want)
+	}
+
+	// Summaries drop per-record detail, verbose keeps it
+	if strings.Contains(string(out["DNSLookup"]), "Question") {
+		t.Error("summary output should not include the DNS question section")
+	}
+	data, err = SelectJSON(s, SelectOptions{Fields: fields, Verbose: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"Question"`) {
+		t.Error("verbose output should include the DNS question section")
+	}
+}
@@ -0,0 +1,68 @@
+package components
+
+import (
+	"fmt"
+	"math"
+)
+
+var (
+	byteRateUnits = []string{"B/s", "KB/s", "MB/s", "GB/s", "TB/s", "PB/s"}
+	bitRateUnits  = []string{"b/s", "Kb/s", "Mb/s", "Gb/s", "Tb/s", "Pb/s"}
+	byteUnits     = []string{"B", "KB", "MB", "GB", "TB", "PB"}
+	bitUnits      = []string{"b", "Kb", "Mb", "Gb", "Tb", "Pb"}
+)
+
+// FormatRate renders a bytes-per-second rate with an auto-scaled unit.
+// In bits mode the value is multiplied by 8 and scaled by 1000 (Mb/s, as
+// link speeds are quoted), otherwise it is scaled by 1024 (KB/s, MB/s).
+func FormatRate(bytesPerSec float64, bits bool) string {
+	if math.IsNaN(bytesPerSec) || math.IsInf(bytesPerSec, 0) {
+		return "-"
+	}
+	if bytesPerSec < 0 {
+		bytesPerSec = 0
+	}
+
+	value, base, units := bytesPerSec, 1024.0, byteRateUnits
+	if bits {
+		value, base, units = bytesPerSec*8, 1000.0, bitRateUnits
+	}
+	return formatScaled(value, base, units)
+}
+
+// FormatBytes renders a transfer volume in the same units as FormatRate,
+// e.g. "1.50 MB" or "12.0 Mb"
+func FormatBytes(bytes uint64, bits bool) string {
+	value, base, units := float64(bytes), 1024.0, byteUnits
+	if bits {
+		value, base, units = float64(bytes)*8, 1000.0, bitUnits
+	}
+	return formatScaled(value, base, units)
+}
+
+// formatScaled divides value by base until it fits its unit
+func formatScaled(value, base float64, units []string) string {
+	// Compare the rounded value so 1023.9 B/s shows as "1.00 KB/s", not "1024 B/s"
+	i := 0
+	for i < len(units)-1 && math.Round(value) >= base {
+		value /= base
+		i++
+	}
+	if i == 0 {
+		// Fractions of the smallest unit are noise
+		return fmt.Sprintf("%.0f %s", value, units[0])
+	}
+	return fmt.Sprintf("%s %s", formatMagnitude(value), units[i])
+}
+
+// formatMagnitude keeps about three significant digits
+func formatMagnitude(v float64) string {
+	switch {
+	case v < 10:
+		return fmt.Sprintf("%.2f", v)
+	case v < 100:
+		return fmt.Sprintf("%.1f", v)
+	default:
+		return fmt.Sprintf("%.0f", v)
+	}
+}
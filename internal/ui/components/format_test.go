@@ -0,0 +1,64 @@
+package components
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFormatRate(t *testing.T) {
+	tests := []struct {
+		bytesPerSec float64
+		bits        bool
+		want        string
+	}{
+		// Bytes, 1024 based
+		{0, false, "0 B/s"},
+		{0.3, false, "0 B/s"},
+		{512, false, "512 B/s"},
+		{1023, false, "1023 B/s"},
+		{1023.9, false, "1.00 KB/s"},
+		{1024, false, "1.00 KB/s"},
+		{15 * 1024, false, "15.0 KB/s"},
+		{150 * 1024, false, "150 KB/s"},
+		{1024 * 1024, false, "1.00 MB/s"},
+		{2.5 * 1024 * 1024 * 1024, false, "2.50 GB/s"},
+		{math.Pow(1024, 6), false, "1024 PB/s"},
+
+		// Bits, 1000 based
+		{1, true, "8 b/s"},
+		{124, true, "992 b/s"},
+		{125, true, "1.00 Kb/s"},
+		{125000, true, "1.00 Mb/s"},
+		{12.5e6, true, "100 Mb/s"},
+		{125e6, true, "1.00 Gb/s"},
+		{1.25e12, true, "10.0 Tb/s"},
+
+		// Garbage in
+		{-5, false, "0 B/s"},
+		{math.NaN(), true, "-"},
+		{math.Inf(1), false, "-"},
+	}
+	for _, tt := range tests {
+		if got := FormatRate(tt.bytesPerSec, tt.bits); got != tt.want {
+			t.Errorf("FormatRate(%v, bits=%v) = %q, want %q", tt.bytesPerSec, tt.bits, got, tt.want)
+		}
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		bytes uint64
+		bits  bool
+		want  string
+	}{
+		{0, false, "0 B"},
+		{1536, false, "1.50 KB"},
+		{3 << 30, false, "3.00 GB"},
+		{1500000, true, "12.0 Mb"},
+	}
+	for _, tt := range tests {
+		if got := FormatBytes(tt.bytes, tt.bits); got != tt.want {
+			t.Errorf("FormatBytes(%d, bits=%v) = %q, want %q", tt.bytes, tt.bits, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package components
+
+import (
+	"math"
+	"strings"
+
+	"github.com/sysatom/lnd/internal/ui"
+)
+
+// sparkBlocks are the eighth-block glyphs from lowest to highest
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// Sparkline renders the most recent values as a single-line graph exactly width
+// cells wide, auto-scaled so the minimum maps to the lowest glyph and the maximum
+// to the highest. Missing history is padded on the left with spaces.
+func Sparkline(values []float64, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	if len(values) > width {
+		values = values[len(values)-width:]
+	}
+
+	min, max := math.Inf(1), math.Inf(-1)
+	for _, v := range values {
+		min = math.Min(min, v)
+		max = math.Max(max, v)
+	}
+
+	var b strings.Builder
+	b.WriteString(strings.Repeat(" ", width-len(values)))
+	for _, v := range values {
+		b.WriteRune(sparkBlocks[sparkIndex(v, min, max)])
+	}
+	return ui.GraphStyle.Render(b.String())
+}
+
+func sparkIndex(v, min, max float64) int {
+	top := len(sparkBlocks) - 1
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0
+	}
+	// Flat data: draw a baseline for zero, a mid-level line otherwise
+	if max-min == 0 {
+		if v == 0 {
+			return 0
+		}
+		return top / 2
+	}
+	idx := int(math.Round((v - min) / (max - min) * float64(top)))
+	if idx < 0 {
+		idx = 0
+	}
+	if idx > top {
+		idx = top
+	}
+	return idx
+}
+
+// BarGraph renders value relative to max as a horizontal bar width cells wide
+func BarGraph(value, max float64, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	filled := 0
+	if max > 0 && value > 0 {
+		filled = int(math.Round(value / max * float64(width)))
+	}
+	if filled > width {
+		filled = width
+	}
+	return ui.GraphStyle.Render(strings.Repeat("█", filled)) +
+		ui.GraphEmptyStyle.Render(strings.Repeat("░", width-filled))
+}
@@ -0,0 +1,69 @@
+package components
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+func TestSparkline_Width(t *testing.T) {
+	tests := []struct {
+		values []float64
+		width  int
+	}{
+		{nil, 10},
+		{[]float64{1, 2, 3}, 10},
+		{[]float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}, 10},
+		{[]float64{5, 5, 5}, 3},
+	}
+	for _, tt := range tests {
+		got := Sparkline(tt.values, tt.width)
+		if w := lipgloss.Width(got); w != tt.width {
+			t.Errorf("Sparkline(%v, %d) width = %d, want %d", tt.values, tt.width, w, tt.width)
+		}
+	}
+	if Sparkline([]float64{1}, 0) != "" {
+		t.Error("zero width should render nothing")
+	}
+}
+
+func TestSparkline_Extremes(t *testing.T) {
+	got := []rune(strings.TrimSpace(Sparkline([]float64{10, 50, 90}, 3)))
+	if len(got) != 3 {
+		t.Fatalf("expected 3 glyphs, got %q", string(got))
+	}
+	if got[0] != '▁' {
+		t.Errorf("min glyph = %q, want ▁", got[0])
+	}
+	if got[2] != '█' {
+		t.Errorf("max glyph = %q, want █", got[2])
+	}
+
+	flat := strings.TrimSpace(Sparkline([]float64{0, 0, 0}, 3))
+	if flat != "▁▁▁" {
+		t.Errorf("flat zero data = %q, want ▁▁▁", flat)
+	}
+}
+
+func TestBarGraph(t *testing.T) {
+	tests := []struct {
+		value, max float64
+		filled     int
+	}{
+		{0, 100, 0},
+		{50, 100, 5},
+		{100, 100, 10},
+		{250, 100, 10}, // Clamped
+		{10, 0, 0},     // No scale
+	}
+	for _, tt := range tests {
+		got := BarGraph(tt.value, tt.max, 10)
+		if w := lipgloss.Width(got); w != 10 {
+			t.Errorf("BarGraph(%v, %v) width = %d, want 10", tt.value, tt.max, w)
+		}
+		if n := strings.Count(got, "█"); n != tt.filled {
+			t.Errorf("BarGraph(%v, %v) filled = %d, want %d", tt.value, tt.max, n, tt.filled)
+		}
+	}
+}
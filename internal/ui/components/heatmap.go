@@ -0,0 +1,61 @@
+package components
+
+import (
+	"math"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/sysatom/lnd/internal/ui"
+)
+
+// LatencyBuckets are the upper bounds in milliseconds of the heatmap colors;
+// anything slower falls into the last color
+var LatencyBuckets = []float64{20, 50, 100, 200}
+
+// LatencyBucket maps a latency in milliseconds to an index into ui.HeatColors.
+// Lost samples (NaN or negative) return -1.
+func LatencyBucket(ms float64) int {
+	if math.IsNaN(ms) || ms < 0 {
+		return -1
+	}
+	for i, bound := range LatencyBuckets {
+		if ms < bound {
+			return i
+		}
+	}
+	return len(LatencyBuckets)
+}
+
+// Heatmap renders one colored cell per latency sample (milliseconds, NaN for
+// loss), the most recent on the right, exactly width cells wide
+func Heatmap(samples []float64, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	if len(samples) > width {
+		samples = samples[len(samples)-width:]
+	}
+
+	var b strings.Builder
+	b.WriteString(strings.Repeat(" ", width-len(samples)))
+	for _, ms := range samples {
+		bucket := LatencyBucket(ms)
+		if bucket < 0 {
+			b.WriteString(ui.ErrorStyle.Render("×"))
+			continue
+		}
+		b.WriteString(lipgloss.NewStyle().Foreground(ui.HeatColors[bucket]).Render("█"))
+	}
+	return b.String()
+}
+
+// HeatmapLegend explains the heatmap colors
+func HeatmapLegend() string {
+	labels := []string{"<20ms", "<50ms", "<100ms", "<200ms", "slower"}
+	var parts []string
+	for i, label := range labels {
+		parts = append(parts, lipgloss.NewStyle().Foreground(ui.HeatColors[i]).Render("█")+" "+label)
+	}
+	parts = append(parts, ui.ErrorStyle.Render("×")+" lost")
+	return strings.Join(parts, "  ")
+}
@@ -0,0 +1,45 @@
+package components
+
+import (
+	"math"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+func TestLatencyBucket(t *testing.T) {
+	tests := []struct {
+		ms   float64
+		want int
+	}{
+		{0, 0},
+		{19.9, 0},
+		{20, 1},
+		{49, 1},
+		{50, 2},
+		{99.5, 2},
+		{100, 3},
+		{199, 3},
+		{200, 4},
+		{2500, 4},
+		{math.NaN(), -1},
+		{-1, -1},
+	}
+	for _, tt := range tests {
+		if got := LatencyBucket(tt.ms); got != tt.want {
+			t.Errorf("LatencyBucket(%v) = %d, want %d", tt.ms, got, tt.want)
+		}
+	}
+}
+
+func TestHeatmap_Width(t *testing.T) {
+	samples := []float64{5, 30, math.NaN(), 150, 800}
+	for _, width := range []int{3, 5, 12} {
+		if w := lipgloss.Width(Heatmap(samples, width)); w != width {
+			t.Errorf("Heatmap width %d rendered %d cells", width, w)
+		}
+	}
+	if Heatmap(samples, 0) != "" {
+		t.Error("zero width should render nothing")
+	}
+}
@@ -0,0 +1,301 @@
+package components
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/sysatom/lnd/internal/ui"
+)
+
+// tableGap separates the columns of a table
+const tableGap = "  "
+
+// Column describes one table column
+type Column struct {
+	Title string
+	Width int  // Cells wide, 0 fits the widest cell
+	Right bool // Right-align, for numbers
+
+	// Less orders two cells when sorting, nil compares leading numbers
+	// numerically ("9 ms" before "10 ms") and anything else as text
+	Less func(a, b string) bool
+}
+
+// Table is a scrollable table with a selected row and an optional sort
+// column. Cells are plain text; the table adds the styling.
+type Table struct {
+	Columns []Column
+	Height  int // Visible rows below the header, 0 shows all
+
+	rows     [][]string
+	order    []int // Row indices in display order
+	cursor   int   // Position of the selected row in order
+	offset   int   // First visible position
+	sortCol  int   // -1 when unsorted
+	sortDesc bool
+}
+
+func NewTable(columns []Column, height int) *Table {
+	return &Table{Columns: columns, Height: height, sortCol: -1}
+}
+
+// SetRows replaces the rows, keeping the sort order and, as far as it
+// still exists, the cursor position
+func (t *Table) SetRows(rows [][]string) {
+	t.rows = rows
+	t.order = make([]int, len(rows))
+	for i := range t.order {
+		t.order[i] = i
+	}
+	t.sort()
+	t.MoveCursor(0)
+}
+
+// Len returns the number of rows
+func (t *Table) Len() int {
+	return len(t.rows)
+}
+
+// Cursor returns the display position of the selected row
+func (t *Table) Cursor() int {
+	return t.cursor
+}
+
+// SelectedRow returns the selected row, false for an empty table
+func (t *Table) SelectedRow() ([]string, bool) {
+	if len(t.order) == 0 {
+		return nil, false
+	}
+	return t.rows[t.order[t.cursor]], true
+}
+
+// MoveCursor moves the selection delta rows, clamped to the table, and
+// scrolls it into view
+func (t *Table) MoveCursor(delta int) {
+	t.cursor = max(0, min(t.cursor+delta, len(t.order)-1))
+	if t.Height <= 0 {
+		t.offset = 0
+		return
+	}
+	if t.cursor < t.offset {
+		t.offset = t.cursor
+	}
+	if t.cursor >= t.offset+t.Height {
+		t.offset = t.cursor - t.Height + 1
+	}
+	t.offset = max(0, min(t.offset, len(t.order)-t.Height))
+}
+
+// SortBy sorts by column col, ascending first; sorting by the same column
+// again reverses the order. The selected row stays selected.
+func (t *Table) SortBy(col int) {
+	if col < 0 || col >= len(t.Columns) {
+		return
+	}
+	if t.sortCol == col {
+		t.sortDesc = !t.sortDesc
+	} else {
+		t.sortCol, t.sortDesc = col, false
+	}
+
+	selected := -1
+	if len(t.order) > 0 {
+		selected = t.order[t.cursor]
+	}
+	t.sort()
+	for pos, i := range t.order {
+		if i == selected {
+			t.cursor = pos
+		}
+	}
+	t.MoveCursor(0)
+}
+
+// SortColumn returns the sort column, -1 when unsorted, and whether the
+// order is descending
+func (t *Table) SortColumn() (int, bool) {
+	return t.sortCol, t.sortDesc
+}
+
+// sort orders the rows by the sort column; equal cells keep their order
+func (t *Table) sort() {
+	if t.sortCol < 0 {
+		return
+	}
+	less := t.Columns[t.sortCol].Less
+	if less == nil {
+		less = lessCell
+	}
+	col := t.sortCol
+	cell := func(i int) string {
+		if col < len(t.rows[i]) {
+			return t.rows[i][col]
+		}
+		return ""
+	}
+	slices.SortStableFunc(t.order, func(a, b int) int {
+		if t.sortDesc {
+			a, b = b, a
+		}
+		switch {
+		case less(cell(a), cell(b)):
+			return -1
+		case less(cell(b), cell(a)):
+			return 1
+		}
+		return 0
+	})
+}
+
+// lessCell compares leading numbers numerically, the rest as text. Cells
+// without a number sort after those with one.
+func lessCell(a, b string) bool {
+	na, okA := leadingNumber(a)
+	nb, okB := leadingNumber(b)
+	switch {
+	case okA && okB && na != nb:
+		return na < nb
+	case okA != okB:
+		return okA
+	}
+	return a < b
+}
+
+// leadingNumber parses the number a cell starts with, e.g. 12.5 of "12.5 ms"
+func leadingNumber(s string) (float64, bool) {
+	s = strings.TrimSpace(s)
+	end := 0
+	for end < len(s) && (s[end] >= '0' && s[end] <= '9' || s[end] == '.' || end == 0 && s[end] == '-') {
+		end++
+	}
+	n, err := strconv.ParseFloat(s[:end], 64)
+	return n, err == nil
+}
+
+// HandleKey applies a navigation or sort key and reports whether it was
+// one: up/down, pgup/pgdown, home/end, and 1-9 to sort by that column
+func (t *Table) HandleKey(key string) bool {
+	page := t.Height
+	if page <= 0 {
+		page = len(t.order)
+	}
+	switch key {
+	case "up":
+		t.MoveCursor(-1)
+	case "down":
+		t.MoveCursor(1)
+	case "pgup":
+		t.MoveCursor(-page)
+	case "pgdown":
+		t.MoveCursor(page)
+	case "home":
+		t.MoveCursor(-len(t.order))
+	case "end":
+		t.MoveCursor(len(t.order))
+	default:
+		if len(key) == 1 && key[0] >= '1' && key[0] <= '9' {
+			t.SortBy(int(key[0] - '1'))
+			return true
+		}
+		return false
+	}
+	return true
+}
+
+// ColumnAt returns the column under x cells from the left edge of the
+// table, -1 for the gaps and beyond the last column
+func (t *Table) ColumnAt(x int) int {
+	left := 0
+	for i, w := range t.widths() {
+		if x >= left && x < left+w {
+			return i
+		}
+		left += w + len(tableGap)
+	}
+	return -1
+}
+
+// ClickHeader sorts by the column under x, as for a mouse click on the header
+func (t *Table) ClickHeader(x int) {
+	t.SortBy(t.ColumnAt(x))
+}
+
+// widths returns the width of each column, fitting those without one
+func (t *Table) widths() []int {
+	widths := make([]int, len(t.Columns))
+	for i, c := range t.Columns {
+		if c.Width > 0 {
+			widths[i] = c.Width
+			continue
+		}
+		widths[i] = lipgloss.Width(c.Title) + 2 // Room for the sort arrow
+		for _, row := range t.rows {
+			if i < len(row) {
+				widths[i] = max(widths[i], lipgloss.Width(row[i]))
+			}
+		}
+	}
+	return widths
+}
+
+// View renders the header and the visible rows, one per line
+func (t *Table) View() string {
+	widths := t.widths()
+
+	header := make([]string, len(t.Columns))
+	for i, c := range t.Columns {
+		title := c.Title
+		if i == t.sortCol {
+			if t.sortDesc {
+				title += " ▼"
+			} else {
+				title += " ▲"
+			}
+		}
+		header[i] = alignCell(title, widths[i], c.Right)
+	}
+	lines := []string{ui.TableHeaderStyle.Render(strings.Join(header, tableGap))}
+
+	end := len(t.order)
+	if t.Height > 0 {
+		end = min(end, t.offset+t.Height)
+	}
+	for pos := t.offset; pos < end; pos++ {
+		row := t.rows[t.order[pos]]
+		cells := make([]string, len(t.Columns))
+		for i, c := range t.Columns {
+			var cell string
+			if i < len(row) {
+				cell = row[i]
+			}
+			cells[i] = alignCell(cell, widths[i], c.Right)
+		}
+		line := strings.Join(cells, tableGap)
+		if pos == t.cursor {
+			line = ui.TableSelectedStyle.Render(line)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// alignCell pads s to width, cutting it with an ellipsis when longer
+func alignCell(s string, width int, right bool) string {
+	if lipgloss.Width(s) > width {
+		runes := []rune(s)
+		for len(runes) > 0 && lipgloss.Width(string(runes))+1 > width {
+			runes = runes[:len(runes)-1]
+		}
+		s = string(runes) + "…"
+		if width <= 0 {
+			s = ""
+		}
+	}
+	pad := strings.Repeat(" ", max(0, width-lipgloss.Width(s)))
+	if right {
+		return pad + s
+	}
+	return s + pad
+}
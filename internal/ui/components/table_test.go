@@ -0,0 +1,122 @@
+package components
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+func testTable(height int) *Table {
+	t := NewTable([]Column{
+		{Title: "Name"},
+		{Title: "RTT", Width: 8, Right: true},
+	}, height)
+	t.SetRows([][]string{
+		{"gateway", "2 ms"},
+		{"google.com", "18 ms"},
+		{"cloudflare.com", "9 ms"},
+		{"example.org", "120 ms"},
+	})
+	return t
+}
+
+// names returns the first cell of every rendered row, in display order
+func names(t *Table) []string {
+	var out []string
+	for _, line := range strings.Split(t.View(), "\n")[1:] {
+		out = append(out, strings.Fields(line)[0])
+	}
+	return out
+}
+
+func TestTable_Alignment(t *testing.T) {
+	lines := strings.Split(testTable(0).View(), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("got %d lines, want header and 4 rows:\n%s", len(lines), strings.Join(lines, "\n"))
+	}
+	width := lipgloss.Width(lines[0])
+	for _, line := range lines {
+		if w := lipgloss.Width(line); w != width {
+			t.Errorf("line %q is %d wide, want %d", line, w, width)
+		}
+	}
+	// Name fits "cloudflare.com", RTT is right-aligned in its 8 cells
+	if !strings.HasPrefix(lines[1], "gateway         ") || !strings.HasSuffix(lines[1], "    2 ms") {
+		t.Errorf("misaligned row %q", lines[1])
+	}
+	if got := alignCell("cloudflare.com", 6, false); got != "cloud…" {
+		t.Errorf("alignCell() = %q, want cloud…", got)
+	}
+}
+
+func TestTable_SortToggle(t *testing.T) {
+	tbl := testTable(0)
+	tbl.SortBy(1)
+	if got, want := names(tbl), []string{"gateway", "cloudflare.com", "google.com", "example.org"}; !slices.Equal(got, want) {
+		t.Errorf("ascending RTT = %v, want %v", got, want)
+	}
+	if !strings.Contains(tbl.View(), "RTT ▲") {
+		t.Error("header should mark the ascending sort")
+	}
+
+	tbl.SortBy(1)
+	if got, want := names(tbl), []string{"example.org", "google.com", "cloudflare.com", "gateway"}; !slices.Equal(got, want) {
+		t.Errorf("descending RTT = %v, want %v", got, want)
+	}
+	if col, desc := tbl.SortColumn(); col != 1 || !desc {
+		t.Errorf("SortColumn() = %d, %v", col, desc)
+	}
+
+	// A header click on the first column sorts by name, ascending again
+	tbl.ClickHeader(0)
+	if got, want := names(tbl), []string{"cloudflare.com", "example.org", "gateway", "google.com"}; !slices.Equal(got, want) {
+		t.Errorf("by name = %v, want %v", got, want)
+	}
+	if tbl.ColumnAt(15) != -1 || tbl.ColumnAt(16) != 1 {
+		t.Errorf("ColumnAt() gap = %d, RTT = %d", tbl.ColumnAt(15), tbl.ColumnAt(16))
+	}
+}
+
+func TestTable_Selection(t *testing.T) {
+	tbl := testTable(2)
+	if row, _ := tbl.SelectedRow(); row[0] != "gateway" {
+		t.Errorf("initial selection %v", row)
+	}
+
+	tbl.HandleKey("down")
+	tbl.HandleKey("down")
+	if row, _ := tbl.SelectedRow(); tbl.Cursor() != 2 || row[0] != "cloudflare.com" {
+		t.Errorf("after two downs: cursor %d, row %v", tbl.Cursor(), row)
+	}
+	// Two visible rows, scrolled to keep the selection in view
+	if got := names(tbl); !slices.Equal(got, []string{"google.com", "cloudflare.com"}) {
+		t.Errorf("visible rows %v", got)
+	}
+
+	tbl.HandleKey("end")
+	tbl.HandleKey("down")
+	if tbl.Cursor() != 3 {
+		t.Errorf("cursor past the end = %d, want 3", tbl.Cursor())
+	}
+	tbl.HandleKey("home")
+	if tbl.Cursor() != 0 || names(tbl)[0] != "gateway" {
+		t.Errorf("home: cursor %d, rows %v", tbl.Cursor(), names(tbl))
+	}
+
+	// Sorting keeps the selected row selected
+	tbl.HandleKey("down") // google.com
+	tbl.HandleKey("2")
+	if row, _ := tbl.SelectedRow(); row[0] != "google.com" || tbl.Cursor() != 2 {
+		t.Errorf("after sort: cursor %d, row %v", tbl.Cursor(), row)
+	}
+	if tbl.HandleKey("x") {
+		t.Error("unrelated keys should not be handled")
+	}
+
+	tbl.SetRows(nil)
+	if _, ok := tbl.SelectedRow(); ok || tbl.Cursor() != 0 {
+		t.Error("empty table should have no selection")
+	}
+}
@@ -3,48 +3,99 @@ package ui
 import "github.com/charmbracelet/lipgloss"
 
 var (
-	// Colors
-	PrimaryColor   = lipgloss.Color("#7D56F4")
-	SecondaryColor = lipgloss.Color("#04B575")
-	ErrorColor     = lipgloss.Color("#FF0000")
-	WarningColor   = lipgloss.Color("#FFA500")
-	SubtleColor    = lipgloss.Color("#626262")
+	// Colors, set by the active theme
+	PrimaryColor   lipgloss.Color
+	SecondaryColor lipgloss.Color
+	ErrorColor     lipgloss.Color
+	WarningColor   lipgloss.Color
+	SubtleColor    lipgloss.Color
 
 	// Text Styles
+	TitleStyle    lipgloss.Style
+	SubtitleStyle lipgloss.Style
+	ErrorStyle    lipgloss.Style
+	WarningStyle  lipgloss.Style
+	SubtleStyle   lipgloss.Style
+
+	// Layout Styles
+	BoxStyle       lipgloss.Style
+	TabStyle       lipgloss.Style
+	ActiveTabStyle lipgloss.Style
+	DividerStyle   lipgloss.Style
+
+	// Graph Styles
+	GraphStyle      lipgloss.Style
+	GraphEmptyStyle lipgloss.Style
+
+	// Table Styles
+	TableHeaderStyle   lipgloss.Style
+	TableSelectedStyle lipgloss.Style
+
+	// HeatColors go from fast to slow
+	HeatColors []lipgloss.Color
+)
+
+func init() {
+	applyTheme(Themes[0])
+}
+
+// applyTheme rebuilds every style from the colors of t
+func applyTheme(t Theme) {
+	current = t
+	PrimaryColor = t.Primary
+	SecondaryColor = t.OK
+	ErrorColor = t.Error
+	WarningColor = t.Warning
+	SubtleColor = t.Subtle
+	HeatColors = t.Heat
+
 	TitleStyle = lipgloss.NewStyle().
-			Foreground(PrimaryColor).
-			Bold(true).
-			Padding(0, 1)
+		Foreground(PrimaryColor).
+		Bold(true).
+		Padding(0, 1)
 
 	SubtitleStyle = lipgloss.NewStyle().
-			Foreground(SecondaryColor).
-			Bold(true)
+		Foreground(SecondaryColor).
+		Bold(true)
 
 	ErrorStyle = lipgloss.NewStyle().
-			Foreground(ErrorColor)
+		Foreground(ErrorColor)
 
 	WarningStyle = lipgloss.NewStyle().
-			Foreground(WarningColor)
+		Foreground(WarningColor)
 
 	SubtleStyle = lipgloss.NewStyle().
-			Foreground(SubtleColor)
+		Foreground(SubtleColor)
 
-	// Layout Styles
 	BoxStyle = lipgloss.NewStyle().
-			Border(lipgloss.RoundedBorder()).
-			BorderForeground(SubtleColor).
-			Padding(0, 1)
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(SubtleColor).
+		Padding(0, 1)
 
 	TabStyle = lipgloss.NewStyle().
-			Border(lipgloss.HiddenBorder()).
-			Padding(0, 1)
+		Border(lipgloss.HiddenBorder()).
+		Padding(0, 1)
 
-	ActiveTabStyle = TabStyle.Copy().
-			Border(lipgloss.NormalBorder()).
-			BorderForeground(PrimaryColor).
-			Foreground(PrimaryColor).
-			Bold(true)
+	ActiveTabStyle = TabStyle.
+		Border(lipgloss.NormalBorder()).
+		BorderForeground(PrimaryColor).
+		Foreground(PrimaryColor).
+		Bold(true)
 
 	DividerStyle = lipgloss.NewStyle().
-			Foreground(SubtleColor)
-)
+		Foreground(SubtleColor)
+
+	GraphStyle = lipgloss.NewStyle().
+		Foreground(SecondaryColor)
+
+	GraphEmptyStyle = lipgloss.NewStyle().
+		Foreground(SubtleColor)
+
+	TableHeaderStyle = lipgloss.NewStyle().
+		Foreground(PrimaryColor).
+		Bold(true)
+
+	// Reverse video marks the row without relying on color
+	TableSelectedStyle = lipgloss.NewStyle().
+		Reverse(true)
+}
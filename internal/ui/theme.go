@@ -0,0 +1,122 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Theme is a color palette. The color-blind themes avoid telling status
+// apart by red and green alone and also mark it with a symbol.
+type Theme struct {
+	Name    string
+	Primary lipgloss.Color
+	OK      lipgloss.Color
+	Error   lipgloss.Color
+	Warning lipgloss.Color
+	Subtle  lipgloss.Color
+	Heat    []lipgloss.Color // Fast to slow
+	Symbols bool             // Prefix status with ✓, ! or ✗
+}
+
+// Themes lists the selectable themes, the first is the default. The
+// color-blind palettes are taken from Okabe and Ito.
+var Themes = []Theme{
+	{
+		Name: "default", Primary: "#7D56F4", OK: "#04B575", Error: "#FF0000", Warning: "#FFA500", Subtle: "#626262",
+		Heat: []lipgloss.Color{"#04B575", "#A3D900", "#FFD700", "#FFA500", "#FF0000"},
+	},
+	{
+		// Red-green deficiency with weak green cones: blue against orange
+		Name: "deuteranopia", Primary: "#CC79A7", OK: "#0072B2", Error: "#D55E00", Warning: "#E69F00", Subtle: "#626262",
+		Heat:    []lipgloss.Color{"#0072B2", "#56B4E9", "#F0E442", "#E69F00", "#D55E00"},
+		Symbols: true,
+	},
+	{
+		// Red-green deficiency with weak red cones: reds look dark, so error is a bright yellow
+		Name: "protanopia", Primary: "#56B4E9", OK: "#0072B2", Error: "#F0E442", Warning: "#CC79A7", Subtle: "#767676",
+		Heat:    []lipgloss.Color{"#0072B2", "#56B4E9", "#CC79A7", "#E69F00", "#F0E442"},
+		Symbols: true,
+	},
+	{
+		// Blue-yellow deficiency: teal against red and pink
+		Name: "tritanopia", Primary: "#56B4E9", OK: "#009E73", Error: "#D55E00", Warning: "#CC79A7", Subtle: "#626262",
+		Heat:    []lipgloss.Color{"#009E73", "#66C2A5", "#CC79A7", "#E7298A", "#D55E00"},
+		Symbols: true,
+	},
+}
+
+// current is the applied theme
+var current Theme
+
+// ThemeNames lists the names accepted by SetTheme
+func ThemeNames() []string {
+	names := make([]string, len(Themes))
+	for i, t := range Themes {
+		names[i] = t.Name
+	}
+	return names
+}
+
+// SetTheme switches every style to the named theme, "" keeps the default
+func SetTheme(name string) error {
+	if name == "" {
+		name = Themes[0].Name
+	}
+	for _, t := range Themes {
+		if t.Name == name {
+			applyTheme(t)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown theme %q, valid themes: %s", name, strings.Join(ThemeNames(), ", "))
+}
+
+// Level is the severity of a status
+type Level int
+
+const (
+	LevelUnknown Level = iota
+	LevelOK
+	LevelWarn
+	LevelFail
+)
+
+// statusSymbols spell out each level for themes that do not rely on color
+var statusSymbols = map[Level]string{LevelUnknown: "?", LevelOK: "✓", LevelWarn: "!", LevelFail: "✗"}
+
+// Status renders text in the color of level, prefixed with the level's
+// symbol when the theme asks for it
+func Status(level Level, text string) string {
+	if current.Symbols {
+		text = statusSymbols[level] + " " + text
+	}
+	return levelStyle(level).Render(text)
+}
+
+// Mark renders text that shows its level by itself, such as [✗] or the
+// detail next to it, in the color of level. No theme adds a symbol.
+func Mark(level Level, text string) string {
+	return levelStyle(level).Render(text)
+}
+
+// StatusPrefixWidth is how many columns Status adds in front of its text
+func StatusPrefixWidth() int {
+	if current.Symbols {
+		return 2
+	}
+	return 0
+}
+
+func levelStyle(level Level) lipgloss.Style {
+	switch level {
+	case LevelOK:
+		return SubtitleStyle
+	case LevelWarn:
+		return WarningStyle
+	case LevelFail:
+		return ErrorStyle
+	}
+	return SubtleStyle
+}
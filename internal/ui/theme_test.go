@@ -0,0 +1,46 @@
+package ui
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sysatom/lnd/internal/config"
+)
+
+func TestStatus_ColorBlindSymbols(t *testing.T) {
+	defer SetTheme("")
+
+	for _, name := range []string{"deuteranopia", "protanopia", "tritanopia"} {
+		if err := SetTheme(name); err != nil {
+			t.Fatal(err)
+		}
+		ok, warn, fail := Status(LevelOK, "OK"), Status(LevelWarn, "SLOW"), Status(LevelFail, "DOWN")
+		if !strings.Contains(ok, "✓ OK") || !strings.Contains(warn, "! SLOW") || !strings.Contains(fail, "✗ DOWN") {
+			t.Errorf("%s: status without symbols: %q %q %q", name, ok, warn, fail)
+		}
+		if SecondaryColor == ErrorColor || WarningColor == ErrorColor {
+			t.Errorf("%s: status levels share a color", name)
+		}
+	}
+
+	SetTheme("default")
+	if s := Status(LevelOK, "OK"); strings.Contains(s, "✓") {
+		t.Errorf("default theme added a symbol: %q", s)
+	}
+}
+
+func TestSetTheme_Unknown(t *testing.T) {
+	if err := SetTheme("sepia"); err == nil {
+		t.Error("expected an error for an unknown theme")
+	}
+	if current.Name != "default" {
+		t.Errorf("an unknown theme changed the active one to %q", current.Name)
+	}
+}
+
+func TestThemeNames_MatchConfig(t *testing.T) {
+	if !slices.Equal(ThemeNames(), config.Themes) {
+		t.Errorf("ThemeNames() = %v, config accepts %v", ThemeNames(), config.Themes)
+	}
+}